@@ -0,0 +1,243 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package alarmd publishes the threshold alarms of the machine's sensors and
+// logs their events.
+package alarmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/platinasystems/go/goes/cmd"
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/parms"
+	"github.com/platinasystems/go/internal/threshold"
+	"github.com/platinasystems/log"
+	"github.com/platinasystems/redis"
+	"github.com/platinasystems/redis/publisher"
+)
+
+func limit(v float64) *float64 { return &v }
+
+// Machines has the sensor table of each machine, by the redis "machine"
+// value, for alarmd without -table. The sensor numbers of SEL events are
+// above those of the ipmigod SDRs.
+var Machines = map[string]*threshold.Table{
+	"platina-mk1-bmc": {
+		Sensors: []*threshold.Sensor{
+			{
+				Field: "hwmon.front.temp.units.C",
+				Upper: threshold.Limit{
+					Warning:  limit(60),
+					Critical: limit(70),
+					Fatal:    limit(80),
+				},
+				Hyst:     2,
+				Debounce: 2,
+				Type:     0x01,
+				Num:      0x80,
+			},
+			{
+				Field: "hwmon.rear.temp.units.C",
+				Upper: threshold.Limit{
+					Warning:  limit(60),
+					Critical: limit(70),
+					Fatal:    limit(80),
+				},
+				Hyst:     2,
+				Debounce: 2,
+				Type:     0x01,
+				Num:      0x81,
+			},
+			{
+				Field: "psu1.temp1.units.C",
+				Upper: threshold.Limit{
+					Warning:  limit(70),
+					Critical: limit(85),
+				},
+				Hyst:     2,
+				Debounce: 2,
+				Type:     0x01,
+				Num:      0x82,
+			},
+			{
+				Field: "psu2.temp1.units.C",
+				Upper: threshold.Limit{
+					Warning:  limit(70),
+					Critical: limit(85),
+				},
+				Hyst:     2,
+				Debounce: 2,
+				Type:     0x01,
+				Num:      0x83,
+			},
+			{
+				Field:  "psu1.status",
+				States: map[string]threshold.Level{"powered_off": threshold.Critical},
+				Type:   0x08,
+				Num:    0x84,
+			},
+			{
+				Field:  "psu2.status",
+				States: map[string]threshold.Level{"powered_off": threshold.Critical},
+				Type:   0x08,
+				Num:    0x85,
+			},
+			fanTray(1),
+			fanTray(2),
+			fanTray(3),
+			fanTray(4),
+		},
+	},
+}
+
+func fanTray(n int) *threshold.Sensor {
+	return &threshold.Sensor{
+		Field: fmt.Sprint("fan_tray.", n, ".status"),
+		States: map[string]threshold.Level{
+			"warning":       threshold.Critical,
+			"not installed": threshold.Warning,
+		},
+		Debounce: 2,
+		Type:     0x04,
+		Num:      uint8(0x85 + n),
+	}
+}
+
+type Command struct {
+	// Table of the sensors, Machines[machine] if nil
+	Table *threshold.Table
+	// Sel adds the events to the ipmigod SEL
+	Sel bool
+
+	pub     *publisher.Publisher
+	monitor *threshold.Monitor
+	last    map[string]string
+	stop    chan struct{}
+}
+
+func (*Command) String() string { return "alarmd" }
+
+func (*Command) Usage() string { return "alarmd [-sel] [-table FILE]" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "sensor threshold alarm daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Compare the redis values of the machine's sensors to their warning,
+	critical and fatal limits, or the level of their status strings.
+
+	The alarm of each sensor is published as alarm.FIELD and the worst of
+	all as alarm.system, e.g.
+
+	    alarm.hwmon.front.temp.units.C: critical
+	    alarm.system: critical
+
+	An alarm changes after its debounce count of samples and clears once
+	the value is back within its limit by the hysteresis. Each change is
+	logged with the priority of its level.
+
+OPTIONS
+	-sel	Also add the events to the ipmigod system event log.
+
+	-table FILE
+		Load the JSON machine table of sensors; see
+		internal/threshold.Table for its format.`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-sel")
+	parm, args := parms.New(args, "-table")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if flag.ByName["-sel"] {
+		c.Sel = true
+	}
+	if fn := parm.ByName["-table"]; len(fn) > 0 {
+		t, err := threshold.LoadTable(fn)
+		if err != nil {
+			return err
+		}
+		c.Table = t
+	}
+	if c.Table == nil {
+		m, _ := redis.Hget(redis.DefaultHash, "machine")
+		c.Table = Machines[m]
+	}
+	if c.Table == nil {
+		return fmt.Errorf("no sensor table")
+	}
+
+	err := redis.IsReady()
+	if err != nil {
+		return err
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	c.monitor = threshold.NewMonitor(c.Table)
+	c.last = make(map[string]string)
+	c.stop = make(chan struct{})
+
+	t := time.NewTicker(5 * time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return nil
+		case now := <-t.C:
+			c.update(func(field string) (string, error) {
+				return redis.Hget(redis.DefaultHash, field)
+			}, now)
+		}
+	}
+}
+
+func (c *Command) Close() error {
+	close(c.stop)
+	return nil
+}
+
+// update the alarms with the values of get, log their events and publish
+// the changed fields.
+func (c *Command) update(get func(string) (string, error), now time.Time) {
+	for _, e := range c.monitor.Update(get, now) {
+		log.Print(e.Priority(), ": ", e.String())
+		if c.Sel {
+			if _, err := threshold.SendSel(&e); err != nil {
+				log.Print("sel: ", err)
+			}
+		}
+	}
+	for k, v := range c.fields() {
+		if v != c.last[k] {
+			if c.pub != nil {
+				c.pub.Print(k, ": ", v)
+			}
+			c.last[k] = v
+		}
+	}
+}
+
+// fields of each alarm as alarm.FIELD and the worst as alarm.system
+func (c *Command) fields() map[string]string {
+	m := make(map[string]string)
+	for _, s := range c.Table.Sensors {
+		m["alarm."+s.Field] = c.monitor.Alarm(s.Field).Level.String()
+	}
+	m["alarm.system"] = c.monitor.Worst().String()
+	return m
+}
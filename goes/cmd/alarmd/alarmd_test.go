@@ -0,0 +1,52 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package alarmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/platinasystems/go/internal/threshold"
+)
+
+func TestMachines(t *testing.T) {
+	for m, tbl := range Machines {
+		if err := tbl.Check(); err != nil {
+			t.Error(m, ": ", err)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tbl := Machines["platina-mk1-bmc"]
+	c := &Command{
+		Table:   tbl,
+		monitor: threshold.NewMonitor(tbl),
+		last:    make(map[string]string),
+	}
+	values := map[string]string{
+		"hwmon.front.temp.units.C": "72.5",
+		"fan_tray.1.status":        "ok.front->back",
+		"psu1.status":              "powered_off",
+	}
+	var now time.Time
+	for i := 0; i < 2; i++ {
+		now = now.Add(5 * time.Second)
+		c.update(func(field string) (string, error) {
+			return values[field], nil
+		}, now)
+	}
+	for k, want := range map[string]string{
+		"alarm.hwmon.front.temp.units.C": "critical",
+		"alarm.hwmon.rear.temp.units.C":  "ok",
+		"alarm.fan_tray.1.status":        "ok",
+		"alarm.psu1.status":              "critical",
+		"alarm.system":                   "critical",
+	} {
+		if got := c.last[k]; got != want {
+			t.Errorf("%s: got %q, want %q", k, got, want)
+		}
+	}
+}
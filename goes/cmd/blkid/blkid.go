@@ -0,0 +1,163 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package blkid
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/parms"
+	"github.com/platinasystems/go/internal/partitions"
+	"github.com/platinasystems/redis"
+	"github.com/platinasystems/redis/publisher"
+)
+
+// Prefix of the published redis fields
+const Prefix = "blk."
+
+type Command struct{}
+
+func (Command) String() string { return "blkid" }
+
+func (Command) Usage() string {
+	return "blkid [-publish] [-s TAG] [-t SPEC | DEVICE...]"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "locate/print block device attributes",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Print the filesystem type, UUID and LABEL, and the partition table
+	type, PARTUUID and PARTLABEL of the given or all block devices.
+
+OPTIONS
+	-s TAG	print only the value of the tag, e.g. UUID
+	-t SPEC	print the device path matching a UUID=, LABEL=, PARTUUID=
+		or PARTLABEL= specifier
+	-publish
+		publish the inventory to the local redis server as
+		blk.DEVICE.TAG fields along with blk.uuid.UUID,
+		blk.label.LABEL and blk.partuuid.PARTUUID device paths`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-publish")
+	parm, args := parms.New(args, "-s", "-t")
+
+	devs, err := partitions.BlockDevices()
+	if err != nil {
+		return err
+	}
+	if flag.ByName["-publish"] {
+		if len(args) > 0 {
+			return fmt.Errorf("%v: unexpected", args)
+		}
+		return publish(devs)
+	}
+	if spec := parm.ByName["-t"]; len(spec) > 0 {
+		if len(args) > 0 {
+			return fmt.Errorf("%v: unexpected", args)
+		}
+		d, err := partitions.Find(devs, spec)
+		if err != nil {
+			return err
+		}
+		fmt.Println(d.Path)
+		return nil
+	}
+	if len(args) > 0 {
+		var sel []*partitions.BlockDevice
+		for _, arg := range args {
+			d, err := partitions.Find(devs, arg)
+			if err != nil {
+				return err
+			}
+			sel = append(sel, d)
+		}
+		devs = sel
+	}
+	tag := strings.ToUpper(parm.ByName["-s"])
+	for _, d := range devs {
+		if len(tag) > 0 {
+			if v := Tags(d)[tag]; len(v) > 0 {
+				fmt.Println(v)
+			}
+		} else if len(d.FsType) > 0 || len(d.PTType) > 0 ||
+			len(args) > 0 {
+			fmt.Println(d.Blkid())
+		}
+	}
+	return nil
+}
+
+// Tags returns the blkid tags of the device.
+func Tags(d *partitions.BlockDevice) map[string]string {
+	return map[string]string{
+		"TYPE":      d.FsType,
+		"UUID":      d.UUID,
+		"LABEL":     d.Label,
+		"PTTYPE":    d.PTType,
+		"PARTUUID":  d.PartUUID,
+		"PARTLABEL": d.PartLabel,
+	}
+}
+
+// publish the devices and delete the fields of those that are gone.
+func publish(devs []*partitions.BlockDevice) error {
+	if err := redis.IsReady(); err != nil {
+		return err
+	}
+	pub, err := publisher.New()
+	if err != nil {
+		return err
+	}
+	defer pub.Close()
+
+	fields := make(map[string]string)
+	for _, d := range devs {
+		fields[Prefix+d.Name+".path"] = d.Path
+		fields[Prefix+d.Name+".size"] = fmt.Sprint(d.Size)
+		fields[Prefix+d.Name+".type"] = d.Type
+		if len(d.MountPoint) > 0 {
+			fields[Prefix+d.Name+".mountpoint"] = d.MountPoint
+		}
+		for tag, v := range Tags(d) {
+			if len(v) > 0 {
+				fields[Prefix+d.Name+"."+strings.ToLower(tag)] = v
+			}
+		}
+		for _, x := range []struct{ k, v string }{
+			{"uuid", d.UUID},
+			{"label", d.Label},
+			{"partuuid", d.PartUUID},
+		} {
+			if len(x.v) > 0 {
+				fields[Prefix+x.k+"."+x.v] = d.Path
+			}
+		}
+	}
+	keys, err := redis.Hkeys(redis.DefaultHash)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, found := fields[k]; strings.HasPrefix(k, Prefix) && !found {
+			pub.Print("delete: ", k)
+		}
+	}
+	for k, v := range fields {
+		pub.Print(k, ": ", v)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package dhcpcd
+
+import (
+	"errors"
+	"net"
+
+	"github.com/d2g/dhcp4"
+	"github.com/d2g/dhcp4client"
+)
+
+var errNAK = errors.New("NAK")
+
+// client4 builds its own DHCPv4 messages so that every DISCOVER and
+// REQUEST includes the parameter request list, vendor class and client
+// identifier; github.com/d2g/dhcp4client still does the transport.
+type client4 struct {
+	*dhcp4client.Client
+	mac net.HardwareAddr
+	// params are the option codes requested from the server
+	params []byte
+	// opts are sent with every DISCOVER and REQUEST
+	opts []dhcp4.Option
+}
+
+var defaultParams = []byte{
+	byte(dhcp4.OptionSubnetMask),
+	byte(dhcp4.OptionRouter),
+	byte(dhcp4.OptionDomainNameServer),
+	byte(dhcp4.OptionHostName),
+	byte(dhcp4.OptionDomainName),
+	byte(dhcp4.OptionInterfaceMTU),
+	byte(dhcp4.OptionBroadcastAddress),
+	byte(dhcp4.OptionNetworkTimeProtocolServers),
+	byte(dhcp4.OptionIPAddressLeaseTime),
+	byte(dhcp4.OptionServerIdentifier),
+	byte(dhcp4.OptionRenewalTimeValue),
+	byte(dhcp4.OptionRebindingTimeValue),
+	optDomainSearch,
+	optClasslessRoute,
+	byte(dhcp4.OptionTFTPServerName),
+	byte(dhcp4.OptionBootFileName),
+	byte(dhcp4.OptionVendorSpecificInformation),
+	optProvisioning,
+}
+
+func (c *client4) packet(mt dhcp4.MessageType, xid []byte) dhcp4.Packet {
+	p := dhcp4.NewPacket(dhcp4.BootRequest)
+	p.SetCHAddr(c.mac)
+	p.SetXId(xid)
+	p.SetBroadcast(true)
+	p.AddOption(dhcp4.OptionDHCPMessageType, []byte{byte(mt)})
+	if mt == dhcp4.Discover || mt == dhcp4.Request {
+		p.AddOption(dhcp4.OptionParameterRequestList, c.params)
+		for _, o := range c.opts {
+			p.AddOption(o.Code, o.Value)
+		}
+	}
+	return p
+}
+
+func (c *client4) xid() []byte {
+	xid := make([]byte, 4)
+	dhcp4client.CryptoGenerateXID(xid)
+	return xid
+}
+
+// ack waits for the acknowledgement of the given request.
+func (c *client4) ack(req dhcp4.Packet) (dhcp4.Packet, error) {
+	req.PadToMinSize()
+	if err := c.SendPacket(req); err != nil {
+		return nil, err
+	}
+	ack, err := c.GetAcknowledgement(&req)
+	if err != nil {
+		return nil, err
+	}
+	mt := parseOptions(ack)[dhcp4.OptionDHCPMessageType]
+	if len(mt) != 1 || dhcp4.MessageType(mt[0]) != dhcp4.ACK {
+		return nil, errNAK
+	}
+	return ack, nil
+}
+
+// discover a server and request the address that it offers
+func (c *client4) discover() (dhcp4.Packet, error) {
+	discover := c.packet(dhcp4.Discover, c.xid())
+	discover.PadToMinSize()
+	if err := c.SendPacket(discover); err != nil {
+		return nil, err
+	}
+	offer, err := c.GetOffer(&discover)
+	if err != nil {
+		return nil, err
+	}
+	req := c.packet(dhcp4.Request, offer.XId())
+	req.AddOption(dhcp4.OptionRequestedIPAddress, offer.YIAddr().To4())
+	req.AddOption(dhcp4.OptionServerIdentifier,
+		parseOptions(offer)[dhcp4.OptionServerIdentifier])
+	return c.ack(req)
+}
+
+// reboot requests a previous, unexpired lease (INIT-REBOOT).
+func (c *client4) reboot(l *lease) (dhcp4.Packet, error) {
+	req := c.packet(dhcp4.Request, c.xid())
+	req.AddOption(dhcp4.OptionRequestedIPAddress, l.addr.IP.To4())
+	return c.ack(req)
+}
+
+// extend the lease (RENEWING or REBINDING). Since our socket always
+// broadcasts, the only difference is whether a server other than the one
+// that granted the lease may answer.
+func (c *client4) extend(l *lease) (dhcp4.Packet, error) {
+	req := c.packet(dhcp4.Request, c.xid())
+	req.SetCIAddr(l.addr.IP)
+	return c.ack(req)
+}
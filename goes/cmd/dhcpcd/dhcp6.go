@@ -0,0 +1,529 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package dhcpcd
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/jpillora/backoff"
+)
+
+// RFC 8415 message types
+const (
+	dhcp6Solicit     = 1
+	dhcp6Advertise   = 2
+	dhcp6Request     = 3
+	dhcp6Renew       = 5
+	dhcp6Rebind      = 6
+	dhcp6Reply       = 7
+	dhcp6InfoRequest = 11
+)
+
+// RFC 8415 and RFC 3646 option codes
+const (
+	opt6ClientID    = 1
+	opt6ServerID    = 2
+	opt6IANA        = 3
+	opt6IAAddr      = 5
+	opt6ORO         = 6
+	opt6Elapsed     = 8
+	opt6Status      = 13
+	opt6DNS         = 23
+	opt6DomainList  = 24
+	opt6InfoRefresh = 32
+)
+
+const (
+	dhcp6ClientPort = 546
+	dhcp6ServerPort = 547
+	// the minimum and default information refresh times of RFC 8415
+	minInfoRefresh     = 600 * time.Second
+	defaultInfoRefresh = 86400 * time.Second
+)
+
+var allDHCPServers = net.ParseIP("ff02::1:2")
+
+type opt6 struct {
+	code uint16
+	data []byte
+}
+
+type msg6 struct {
+	typ  byte
+	xid  [3]byte
+	opts []opt6
+}
+
+func (m *msg6) bytes() []byte {
+	b := []byte{m.typ, m.xid[0], m.xid[1], m.xid[2]}
+	return append(b, encodeOpts6(m.opts)...)
+}
+
+func encodeOpts6(opts []opt6) []byte {
+	var b []byte
+	for _, o := range opts {
+		var hdr [4]byte
+		binary.BigEndian.PutUint16(hdr[0:2], o.code)
+		binary.BigEndian.PutUint16(hdr[2:4], uint16(len(o.data)))
+		b = append(b, hdr[:]...)
+		b = append(b, o.data...)
+	}
+	return b
+}
+
+func decodeOpts6(b []byte) ([]opt6, error) {
+	var opts []opt6
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return opts, errTruncated
+		}
+		code := binary.BigEndian.Uint16(b[0:2])
+		n := int(binary.BigEndian.Uint16(b[2:4]))
+		if len(b) < 4+n {
+			return opts, errTruncated
+		}
+		opts = append(opts, opt6{code, b[4 : 4+n]})
+		b = b[4+n:]
+	}
+	return opts, nil
+}
+
+func parseMsg6(b []byte) (*msg6, error) {
+	if len(b) < 4 {
+		return nil, errTruncated
+	}
+	m := &msg6{typ: b[0]}
+	copy(m.xid[:], b[1:4])
+	opts, err := decodeOpts6(b[4:])
+	m.opts = opts
+	return m, err
+}
+
+// opt returns the first instance of the option.
+func (m *msg6) opt(code uint16) []byte {
+	for _, o := range m.opts {
+		if o.code == code {
+			return o.data
+		}
+	}
+	return nil
+}
+
+// status returns an error for a Status Code option other than Success.
+func status6(opts []opt6) error {
+	for _, o := range opts {
+		if o.code == opt6Status && len(o.data) >= 2 {
+			if code := binary.BigEndian.Uint16(o.data); code != 0 {
+				return fmt.Errorf("status %d: %s", code,
+					string(o.data[2:]))
+			}
+		}
+	}
+	return nil
+}
+
+type lease6 struct {
+	acquired time.Time
+	server   []byte
+	// iana is the IA_NA option to present when renewing
+	iana []byte
+	addr net.IP
+
+	preferred, valid, t1, t2 time.Duration
+
+	dns     []net.IP
+	search  []string
+	refresh time.Duration
+}
+
+func (l *lease6) renew() time.Time   { return l.acquired.Add(l.t1) }
+func (l *lease6) rebind() time.Time  { return l.acquired.Add(l.t2) }
+func (l *lease6) expires() time.Time { return l.acquired.Add(l.valid) }
+
+// newLease6 returns the configuration in a Reply; for an Information
+// Request reply, there is no address.
+func newLease6(m *msg6, acquired time.Time) (*lease6, error) {
+	if err := status6(m.opts); err != nil {
+		return nil, err
+	}
+	l := &lease6{
+		acquired: acquired,
+		server:   m.opt(opt6ServerID),
+		refresh:  defaultInfoRefresh,
+	}
+	l.dns = ipList(m.opt(opt6DNS), net.IPv6len)
+	if b := m.opt(opt6DomainList); len(b) > 0 {
+		l.search, _ = domainList(b)
+	}
+	if b := m.opt(opt6InfoRefresh); len(b) == 4 {
+		l.refresh = time.Duration(binary.BigEndian.Uint32(b)) *
+			time.Second
+		if l.refresh < minInfoRefresh {
+			l.refresh = minInfoRefresh
+		}
+	}
+	iana := m.opt(opt6IANA)
+	if len(iana) == 0 {
+		return l, nil
+	}
+	if len(iana) < 12 {
+		return nil, errTruncated
+	}
+	l.iana = iana
+	l.t1 = time.Duration(binary.BigEndian.Uint32(iana[4:8])) * time.Second
+	l.t2 = time.Duration(binary.BigEndian.Uint32(iana[8:12])) * time.Second
+	opts, err := decodeOpts6(iana[12:])
+	if err != nil {
+		return nil, err
+	}
+	if err = status6(opts); err != nil {
+		return nil, err
+	}
+	for _, o := range opts {
+		if o.code != opt6IAAddr || len(o.data) < 24 {
+			continue
+		}
+		l.addr = net.IP(append([]byte{}, o.data[:16]...))
+		l.preferred = time.Duration(binary.BigEndian.Uint32(
+			o.data[16:20])) * time.Second
+		l.valid = time.Duration(binary.BigEndian.Uint32(
+			o.data[20:24])) * time.Second
+		break
+	}
+	if l.addr == nil {
+		return nil, errors.New("no address in IA_NA")
+	}
+	// RFC 8415 21.4: the client chooses T1 and T2 if they're zero
+	if l.t1 == 0 {
+		l.t1 = l.preferred / 2
+	}
+	if l.t2 == 0 || l.t2 < l.t1 {
+		l.t2 = l.preferred * 4 / 5
+	}
+	return l, nil
+}
+
+type client6 struct {
+	conn *net.UDPConn
+	dst  *net.UDPAddr
+	duid []byte
+	iaid uint32
+}
+
+func newClient6(dev string, mac net.HardwareAddr) (*client6, error) {
+	ifi, err := net.InterfaceByName(dev)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var ll net.IP
+	for _, a := range addrs {
+		if ipn, ok := a.(*net.IPNet); ok &&
+			ipn.IP.To4() == nil && ipn.IP.IsLinkLocalUnicast() {
+			ll = ipn.IP
+			break
+		}
+	}
+	if ll == nil {
+		return nil, fmt.Errorf("%s: no link-local address", dev)
+	}
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{
+		IP:   ll,
+		Port: dhcp6ClientPort,
+		Zone: dev,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// DUID-LL (RFC 8415 11.4) of an ethernet address
+	duid := append([]byte{0, 3, 0, 1}, mac...)
+	return &client6{
+		conn: conn,
+		dst: &net.UDPAddr{
+			IP:   allDHCPServers,
+			Port: dhcp6ServerPort,
+			Zone: dev,
+		},
+		duid: duid,
+		iaid: binary.BigEndian.Uint32(mac[len(mac)-4:]),
+	}, nil
+}
+
+func (c *client6) Close() error { return c.conn.Close() }
+
+// exchange sends the message, retransmitting with exponential backoff, and
+// returns the first response of the wanted type.
+func (c *client6) exchange(typ byte, opts []opt6, want byte,
+	done <-chan struct{}) (*msg6, error) {
+	m := &msg6{typ: typ}
+	if _, err := rand.Read(m.xid[:]); err != nil {
+		return nil, err
+	}
+	b := &backoff.Backoff{
+		Min:    1 * time.Second,
+		Max:    30 * time.Second,
+		Factor: 2,
+		Jitter: true,
+	}
+	start := time.Now()
+	buf := make([]byte, 1500)
+	for try := 0; try < 5; try++ {
+		elapsed := make([]byte, 2)
+		cs := time.Since(start) / (10 * time.Millisecond)
+		if cs > 0xffff {
+			cs = 0xffff
+		}
+		binary.BigEndian.PutUint16(elapsed, uint16(cs))
+		m.opts = append([]opt6{
+			{opt6ClientID, c.duid},
+			{opt6Elapsed, elapsed},
+		}, opts...)
+		if _, err := c.conn.WriteToUDP(m.bytes(), c.dst); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(b.Duration())
+		c.conn.SetReadDeadline(deadline)
+		for {
+			select {
+			case <-done:
+				return nil, nil
+			default:
+			}
+			n, _, err := c.conn.ReadFromUDP(buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			r, err := parseMsg6(buf[:n])
+			if err != nil || r.typ != want || r.xid != m.xid {
+				continue
+			}
+			if string(r.opt(opt6ClientID)) != string(c.duid) {
+				continue
+			}
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("no DHCPv6 reply in %v", time.Since(start))
+}
+
+func (c *client6) oro() opt6 {
+	return opt6{opt6ORO, []byte{
+		0, opt6DNS,
+		0, opt6DomainList,
+		0, opt6InfoRefresh,
+	}}
+}
+
+func (c *client6) iana() opt6 {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint32(b, c.iaid)
+	return opt6{opt6IANA, b}
+}
+
+// solicit an address and request it from the first server that advertises
+func (c *client6) solicit(done <-chan struct{}) (*lease6, error) {
+	adv, err := c.exchange(dhcp6Solicit, []opt6{c.oro(), c.iana()},
+		dhcp6Advertise, done)
+	if adv == nil || err != nil {
+		return nil, err
+	}
+	if err = status6(adv.opts); err != nil {
+		return nil, err
+	}
+	server := adv.opt(opt6ServerID)
+	iana := adv.opt(opt6IANA)
+	if server == nil || iana == nil {
+		return nil, errors.New("incomplete DHCPv6 advertisement")
+	}
+	reply, err := c.exchange(dhcp6Request, []opt6{
+		{opt6ServerID, server},
+		c.oro(),
+		{opt6IANA, iana},
+	}, dhcp6Reply, done)
+	if reply == nil || err != nil {
+		return nil, err
+	}
+	return newLease6(reply, time.Now())
+}
+
+// extend renews the lease with its server or, if rebind, any server.
+func (c *client6) extend(l *lease6, rebind bool,
+	done <-chan struct{}) (*lease6, error) {
+	typ := byte(dhcp6Renew)
+	opts := []opt6{c.oro(), {opt6IANA, l.iana}}
+	if rebind {
+		typ = dhcp6Rebind
+	} else {
+		opts = append(opts, opt6{opt6ServerID, l.server})
+	}
+	reply, err := c.exchange(typ, opts, dhcp6Reply, done)
+	if reply == nil || err != nil {
+		return nil, err
+	}
+	return newLease6(reply, time.Now())
+}
+
+// info requests the nameservers and domain search list.
+func (c *client6) info(done <-chan struct{}) (*lease6, error) {
+	reply, err := c.exchange(dhcp6InfoRequest, []opt6{c.oro()},
+		dhcp6Reply, done)
+	if reply == nil || err != nil {
+		return nil, err
+	}
+	return newLease6(reply, time.Now())
+}
+
+// slaac enables router advertisements and address autoconfiguration.
+func slaac(dev string) {
+	dir := filepath.Join("/proc/sys/net/ipv6/conf", dev)
+	for _, x := range []struct{ name, value string }{
+		{"disable_ipv6", "0"},
+		{"accept_ra", "1"},
+		{"autoconf", "1"},
+	} {
+		err := ioutil.WriteFile(filepath.Join(dir, x.name),
+			[]byte(x.value), 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+func (c *Command) main6(dev string, mac net.HardwareAddr, slaacOnly bool) error {
+	slaac(dev)
+	b := &backoff.Backoff{
+		Min:    1 * time.Second,
+		Max:    60 * time.Second,
+		Factor: 2,
+		Jitter: false,
+	}
+	// the link-local address may still be tentative
+	var cl *client6
+	for {
+		var err error
+		if cl, err = newClient6(dev, mac); err == nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", dev, err)
+		if !c.sleep(b.Duration()) {
+			return nil
+		}
+	}
+	defer cl.Close()
+	b.Reset()
+
+	if slaacOnly {
+		for {
+			l, err := cl.info(c.done)
+			if l == nil && err == nil {
+				return nil
+			}
+			wait := b.Duration()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", dev, err)
+			} else {
+				b.Reset()
+				wait = l.refresh
+				c.apply6(dev, l, nil)
+			}
+			if !c.sleep(wait) {
+				return nil
+			}
+		}
+	}
+
+	var applied *lease6
+	defer func() {
+		c.apply6(dev, nil, applied)
+	}()
+	for {
+		l, err := cl.solicit(c.done)
+		if l == nil && err == nil {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", dev, err)
+			if !c.sleep(b.Duration()) {
+				return nil
+			}
+			continue
+		}
+		b.Reset()
+		for l != nil {
+			fmt.Printf("%s: address %s/128 valid %v\n", dev, l.addr,
+				l.valid)
+			if err = c.apply6(dev, l, applied); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", dev, err)
+			}
+			applied = l
+			if !c.sleep(time.Until(l.renew())) {
+				return nil
+			}
+			var next *lease6
+			for next == nil && time.Now().Before(l.expires()) {
+				rebind := !time.Now().Before(l.rebind())
+				next, err = cl.extend(l, rebind, c.done)
+				if next == nil && err == nil {
+					return nil
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", dev, err)
+					if !c.sleep(b.Duration()) {
+						return nil
+					}
+				}
+			}
+			l = next
+		}
+		c.apply6(dev, nil, applied)
+		applied = nil
+	}
+}
+
+// apply6 configures the leased address, if any, and resolv.conf.
+func (c *Command) apply6(dev string, l, old *lease6) error {
+	var firstErr error
+	if old != nil && old.addr != nil && (l == nil || !l.addr.Equal(old.addr)) {
+		firstErr = c.g.Main("ip", "address", "delete",
+			old.addr.String()+"/128", "dev", dev)
+	}
+	if l == nil {
+		return firstErr
+	}
+	if l.addr != nil {
+		// replace to update the lifetimes of a renewed address
+		err := c.g.Main("ip", "address", "replace",
+			l.addr.String()+"/128", "dev", dev,
+			"valid_lft", strconv.Itoa(int(l.valid/time.Second)),
+			"preferred_lft",
+			strconv.Itoa(int(l.preferred/time.Second)))
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	rc := &resolvConf{
+		search:      l.search,
+		nameservers: l.dns,
+	}
+	if err := rc.write(dev); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
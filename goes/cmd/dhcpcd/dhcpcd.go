@@ -2,18 +2,25 @@
 // Use of this source code is governed by the GPL-2 license described in the
 // LICENSE file.
 
-// Package dhcpcd is a simple dhcp client
+// Package dhcpcd is a simple dhcp client.
+//
+// In its default, DHCPv4 mode it renews and rebinds leases at the T1 and T2
+// times, configures the interface address, routes (including RFC 3442
+// classless static routes), MTU, hostname and /etc/resolv.conf, and saves
+// each lease in /var/lib/dhcpcd to request it again after restart.
+//
+// With -6 it runs a stateful DHCPv6 client alongside SLAAC; with -slaac it
+// relies on router advertisements for addresses and only asks a DHCPv6
+// server for nameservers and the domain search list.
 package dhcpcd
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"math/bits"
 	"net"
 	"os"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 	"unsafe"
@@ -25,7 +32,10 @@ import (
 	"github.com/platinasystems/go/goes"
 	"github.com/platinasystems/go/goes/cmd"
 	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/flags"
 	"github.com/platinasystems/go/internal/parms"
+	"github.com/platinasystems/redis"
+	"github.com/platinasystems/redis/publisher"
 )
 
 type Command struct {
@@ -35,7 +45,10 @@ type Command struct {
 
 func (*Command) String() string { return "dhcpcd" }
 
-func (*Command) Usage() string { return "dhcpcd" }
+func (*Command) Usage() string {
+	return "dhcpcd [-i INTERFACE] [-6 | -slaac] [-hostname NAME] " +
+		"[-vendor-class STRING] [-client-id STRING]"
+}
 
 func (*Command) Apropos() lang.Alt {
 	return lang.Alt{
@@ -43,6 +56,61 @@ func (*Command) Apropos() lang.Alt {
 	}
 }
 
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Configure the interface with DHCPv4 or, with -6 or -slaac, DHCPv6.
+
+OPTIONS
+	-i INTERFACE
+		Default: eth0
+
+	-6	Run a stateful DHCPv6 client with SLAAC.
+
+	-slaac	Configure addresses with SLAAC and only request the
+		nameservers and domain search list with DHCPv6.
+
+	-hostname NAME
+		Send NAME as the DHCPv4 host name option (12).
+
+	-vendor-class STRING
+		Send STRING as the DHCPv4 vendor class identifier (60).
+		Default: goes
+
+	-client-id STRING
+		Send STRING as the DHCPv4 client identifier (61) instead of
+		the interface's hardware address.
+
+ZERO TOUCH PROVISIONING
+	With the first DHCPv4 lease, dhcpcd fetches and runs a goes script
+	named by the vendor specific information (43) sub-options,
+
+		1	script URL
+		2	script hash
+		3	image URL
+		4	image hash
+
+	or else, the provisioning URL option (239); or else, the TFTP server
+	(66) and boot file name (67). The URL may be anything accepted by
+	'cli', and a hash is a SHA-256 or SHA-512 hexadecimal digest,
+	optionally prefaced by "sha256:" or "sha512:". The script is run with
+	ZTP_INTERFACE and, if there's an image, ZTP_IMAGE set to the
+	downloaded file. Progress is published to redis as ztp.status.
+
+	A failed provisioning is retried with the next lease or renewal. It's
+	skipped once it has completed or if /etc/default/goes has ZTP=no.
+
+FILES
+	/var/lib/dhcpcd/INTERFACE.lease
+		The last DHCPv4 acknowledgement, used to request the same
+		address after restart if the lease hasn't expired.
+
+	/var/lib/ztp/done
+		The time and URL of the completed provisioning script.`,
+	}
+}
+
 func (c *Command) Close() error {
 	close(c.done)
 	return nil
@@ -59,84 +127,13 @@ type ifreq struct {
 	ifrNewname [IFNAMSIZ]byte
 }
 
-func (c *Command) parseACK(ack dhcp4.Packet) (myIP string, rtrIP string, dnsIP string, lt uint32, err error) {
-	myIP = ack.YIAddr().String()
-	opt := ack.ParseOptions()
-	nm := opt[1]
-	fmt.Printf("Option[1] netmask: %v\n", nm)
-	if len(nm) == 4 {
-		myIP = myIP + "/" + strconv.Itoa(bits.LeadingZeros32(^binary.BigEndian.Uint32(nm)))
-	}
-	fmt.Printf("Got address %s\n", myIP)
-
-	rtr := opt[3]
-	fmt.Printf("Option[3] router: %v\n", rtr)
-	if len(rtr) == 4 {
-		ip := net.IP(rtr)
-		if !ip.Equal(net.IPv4(0, 0, 0, 0)) {
-			rtrIP = ip.String()
-		}
-	}
-
-	ltOpt := opt[51]
-	fmt.Printf("Got lease %v\n", ltOpt)
-	lt = uint32(86400)
-	if len(ltOpt) == 4 {
-		lt = binary.BigEndian.Uint32(ltOpt)
-		fmt.Printf("Lease time %d\n", lt)
-	}
-	dns := opt[6]
-	fmt.Printf("Got DNS %v\n", dns)
-	for i := 0; i < len(dns) && len(dns[i:]) >= 4; i += 4 {
-		dnsIP = dnsIP + "nameserver " + net.IP(dns[i:i+4]).String() + "\n"
-	}
-	fmt.Printf("DNS resolved to %v\n", dnsIP)
-
-	return
-}
-
-func (c *Command) updateParm(dev string, myIP string, myLastIP string, rtrIP string, rtrLastIP string, dnsIP string, dnsLastIP string) (err error) {
-	if myIP != myLastIP {
-		if myIP != "" {
-			err = c.g.Main("ip", "address", "add", myIP, "dev", dev)
-			if err != nil {
-				return err
-			}
-		}
-		if myLastIP != "" {
-			err = c.g.Main("ip", "address", "delete", myLastIP, "dev", dev)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	if rtrIP != rtrLastIP {
-		if rtrIP != "" {
-			err := c.g.Main("ip", "route", "add", "0.0.0.0/0", "via", rtrIP)
-			if err != nil {
-				return err
-			}
-		}
-		if rtrLastIP != "" {
-			err := c.g.Main("ip", "route", "delete", "0.0.0.0/0", "via", rtrLastIP)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	if dnsIP != dnsLastIP {
-		if dnsIP != "" {
-			err := ioutil.WriteFile("/etc/resolv.conf", []byte(dnsIP), 0644)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
 func (c *Command) Main(args ...string) error {
-	parm, args := parms.New(args, "-i")
+	flag, args := flags.New(args, "-6", "-slaac")
+	parm, args := parms.New(args, "-i", "-hostname", "-vendor-class",
+		"-client-id")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
 	i := "eth0"
 	if parm.ByName["-i"] != "" {
 		i = parm.ByName["-i"]
@@ -159,7 +156,8 @@ func (c *Command) Main(args ...string) error {
 	if e != 0 {
 		return e
 	}
-	mac := net.HardwareAddr(dev.ifrNewname[2:7])
+	// struct sockaddr: sa_family (2 bytes) then the 6 byte address
+	mac := net.HardwareAddr(dev.ifrNewname[2:8])
 
 	fmt.Printf("Got %s\n", mac)
 
@@ -173,6 +171,10 @@ func (c *Command) Main(args ...string) error {
 		_ = c.g.Main("ip", "link", "change", i, "down")
 	}()
 
+	if flag.ByName["-6"] || flag.ByName["-slaac"] {
+		return c.main6(i, mac, flag.ByName["-slaac"])
+	}
+
 	err = c.g.Main("ip", "route", "add", "255.255.255.255/32", "dev", i)
 	if err != nil {
 		return err
@@ -192,108 +194,302 @@ func (c *Command) Main(args ...string) error {
 	}
 	defer cl.Close()
 
+	cl4 := &client4{
+		Client: cl,
+		mac:    mac,
+		params: defaultParams,
+	}
+	vendorClass := "goes"
+	if s := parm.ByName["-vendor-class"]; len(s) > 0 {
+		vendorClass = s
+	}
+	cl4.opts = append(cl4.opts, dhcp4.Option{
+		Code:  dhcp4.OptionVendorClassIdentifier,
+		Value: []byte(vendorClass),
+	})
+	// RFC 2132 9.14: the type is 0 for other than hardware addresses
+	clientID := append([]byte{1}, mac...)
+	if s := parm.ByName["-client-id"]; len(s) > 0 {
+		clientID = append([]byte{0}, s...)
+	}
+	cl4.opts = append(cl4.opts, dhcp4.Option{
+		Code:  dhcp4.OptionClientIdentifier,
+		Value: clientID,
+	})
+	if s := parm.ByName["-hostname"]; len(s) > 0 {
+		cl4.opts = append(cl4.opts, dhcp4.Option{
+			Code:  dhcp4.OptionHostName,
+			Value: []byte(s),
+		})
+	}
+
 	b := &backoff.Backoff{
 		Min:    1 * time.Second,
 		Max:    60 * time.Second,
 		Factor: 2,
 		Jitter: false,
 	}
-	var myIP, rtrIP, dnsIP string
-	var lt uint32
-	var ack dhcp4.Packet
-	var success bool
+
+	saved, err := loadLease(i)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	var applied *lease
+	defer func() {
+		c.apply(i, nil, applied)
+	}()
+	// ztp is cleared once provisioning succeeds; until then, it's
+	// retried with each new or extended lease.
+	ztp := ztpEnabled()
+	var ztpc chan error
 
 	for {
-		success, ack, err = cl.Request()
-		if err == nil {
-			if success {
-				myIP, rtrIP, dnsIP, lt, err = c.parseACK(ack)
-				if err == nil {
-					err := c.updateParm(i, myIP, "", rtrIP, "", dnsIP, "")
-					if err == nil {
-						break
-					} else {
-						fmt.Fprintf(os.Stderr, "Error in updateParm: %s\n", err)
-					}
-				} else {
-					fmt.Fprintf(os.Stderr, "Error in parseACK: %s\n", err)
+		// INIT or, with a saved lease, INIT-REBOOT
+		var l *lease
+		for l == nil {
+			var ack dhcp4.Packet
+			if saved != nil {
+				ack, err = cl4.reboot(saved)
+				saved = nil
+			} else {
+				ack, err = cl4.discover()
+			}
+			if err == nil {
+				l, err = newLease(ack, time.Now())
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", i, err)
+				if !c.sleep(b.Duration()) {
+					return nil
 				}
 			}
-		} else {
-			fmt.Fprintf(os.Stderr, "Error in Request: %s\n", err)
 		}
-
-		if !func() bool {
-			t := time.NewTicker(b.Duration())
-			defer t.Stop()
-
+		b.Reset()
+
+		// BOUND
+		for l != nil {
+			fmt.Printf("%s: %s\n", i, strings.Replace(l.String(),
+				"\n", "\n\t", -1))
+			if err = c.apply(i, l, applied); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", i, err)
+			}
+			applied = l
+			if err = l.save(i); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
 			select {
-			case <-c.done:
-				return false
-			case <-t.C:
-				return true
+			case err := <-ztpc:
+				ztpc = nil
+				ztp = err != nil
+			default:
+			}
+			if ztp && ztpc == nil {
+				ztpc = make(chan error, 1)
+				go func(ch chan<- error, l *lease) {
+					ch <- c.ztp(i, l)
+				}(ztpc, l)
+			}
+			var ok bool
+			if l, ok = c.extend(i, cl4, l); !ok {
+				return nil
 			}
-		}() {
-			return nil
 		}
-	}
 
-	defer func() {
-		c.updateParm(i, "", myIP, "", rtrIP, "", dnsIP)
-	}()
+		// the lease expired or was refused
+		c.apply(i, nil, applied)
+		applied = nil
+		os.Remove(leaseFile(i))
+	}
+}
 
+// extend the lease at T1 and, failing that, at T2. It returns the new
+// lease; or nil if the lease expired or the server refused it; or false if
+// the daemon was stopped.
+func (c *Command) extend(dev string, cl4 *client4, l *lease) (*lease, bool) {
+	if !c.sleep(time.Until(l.renew())) {
+		return nil, false
+	}
 	for {
-		if !func() bool {
-			t := time.NewTicker(time.Duration(lt) * time.Second)
-			defer t.Stop()
-
-			select {
-			case <-c.done:
-				return false
-			case <-t.C:
-				return true
+		now := time.Now()
+		if !now.Before(l.expires()) {
+			fmt.Fprintf(os.Stderr, "%s: lease expired\n", dev)
+			return nil, true
+		}
+		end := l.rebind()
+		if !now.Before(end) {
+			end = l.expires()
+		}
+		ack, err := cl4.extend(l)
+		if err == errNAK {
+			fmt.Fprintf(os.Stderr, "%s: lease refused\n", dev)
+			return nil, true
+		}
+		if err == nil {
+			var next *lease
+			if next, err = newLease(ack, now); err == nil {
+				return next, true
 			}
-		}() {
-			return nil
 		}
-		myLastIP := myIP
-		rtrLastIP := rtrIP
-		dnsLastIP := dnsIP
+		fmt.Fprintf(os.Stderr, "%s: %v\n", dev, err)
+		// RFC 2131 4.4.5: wait half the time remaining until T2, or
+		// expiry, down to a minimum of 60 seconds.
+		wait := time.Until(end) / 2
+		if wait < time.Minute {
+			wait = time.Minute
+		}
+		if left := time.Until(l.expires()); wait > left {
+			wait = left
+		}
+		if !c.sleep(wait) {
+			return nil, false
+		}
+	}
+}
 
-		for {
-			success, ack, err = cl.Renew(ack)
-			if err == nil {
-				if success {
-					myIP, rtrIP, dnsIP, lt, err = c.parseACK(ack)
-					if err == nil {
-						err = c.updateParm(i, myIP, myLastIP, rtrIP, rtrLastIP, dnsIP, dnsLastIP)
-						if err == nil {
-							break
-						} else {
-							fmt.Fprintf(os.Stderr, "Error in updateParm: %s\n", err)
-						}
-					} else {
-						fmt.Fprintf(os.Stderr, "Error in parseACK: %s\n", err)
-					}
-				}
-			} else {
-				fmt.Fprintf(os.Stderr, "Error in Renew: %s\n", err)
-			}
-			if !func() bool {
-				t := time.NewTicker(b.Duration())
-				defer t.Stop()
-
-				select {
-				case <-c.done:
-					return false
-				case <-t.C:
-					return true
-				}
-			}() {
-				return nil
+// sleep returns false if the daemon was stopped before the given duration.
+func (c *Command) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-c.done:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// apply the new lease, removing what's left of the old one. Either may be
+// nil.
+func (c *Command) apply(dev string, l, old *lease) error {
+	var firstErr error
+	do := func(args ...string) {
+		if err := c.g.Main(args...); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %v",
+				strings.Join(args, " "), err)
+		}
+	}
+	var addr, oldAddr string
+	if l != nil {
+		addr = l.addr.String()
+	}
+	if old != nil {
+		oldAddr = old.addr.String()
+	}
+	if addr != oldAddr {
+		if len(addr) > 0 {
+			do("ip", "address", "add", addr, "dev", dev)
+		}
+		if len(oldAddr) > 0 {
+			do("ip", "address", "delete", oldAddr, "dev", dev)
+		}
+	}
+	if l != nil && l.mtu > 0 && (old == nil || old.mtu != l.mtu) {
+		do("ip", "link", "change", dev, "mtu", strconv.Itoa(l.mtu))
+	}
+	routes := make(map[string]route)
+	oldRoutes := make(map[string]route)
+	if l != nil {
+		for _, r := range l.routes {
+			routes[r.String()] = r
+		}
+	}
+	if old != nil && oldAddr == addr {
+		for _, r := range old.routes {
+			oldRoutes[r.String()] = r
+		}
+	}
+	for k, r := range oldRoutes {
+		if _, found := routes[k]; !found {
+			do(routeArgs("delete", dev, r)...)
+		}
+	}
+	for k, r := range routes {
+		if _, found := oldRoutes[k]; !found {
+			do(routeArgs("add", dev, r)...)
+		}
+	}
+	if l != nil && len(l.hostname) > 0 &&
+		(old == nil || old.hostname != l.hostname) {
+		if err := syscall.Sethostname([]byte(l.hostname)); err != nil &&
+			firstErr == nil {
+			firstErr = err
+		}
+	}
+	if l != nil {
+		rc := &resolvConf{
+			domain:      l.domain,
+			search:      l.search,
+			nameservers: l.dns,
+		}
+		if old == nil || string(rc.Bytes(dev)) !=
+			string((&resolvConf{
+				domain:      old.domain,
+				search:      old.search,
+				nameservers: old.dns,
+			}).Bytes(dev)) {
+			if err := rc.write(dev); err != nil && firstErr == nil {
+				firstErr = err
 			}
 		}
 	}
+	publish(dev, l)
+	return firstErr
+}
+
+func routeArgs(op, dev string, r route) []string {
+	args := []string{"ip", "route", op, r.dst.String()}
+	if r.gw != nil && !r.gw.IsUnspecified() {
+		args = append(args, "via", r.gw.String())
+	}
+	return append(args, "dev", dev)
+}
 
-	return nil
+// publish the lease to the local redis server, if it's running, as
+// dhcp.INTERFACE.FIELD
+func publish(dev string, l *lease) {
+	if redis.IsReady() != nil {
+		return
+	}
+	pub, err := publisher.New()
+	if err != nil {
+		return
+	}
+	defer pub.Close()
+	join := func(ips []net.IP) string {
+		s := make([]string, len(ips))
+		for i, ip := range ips {
+			s[i] = ip.String()
+		}
+		return strings.Join(s, " ")
+	}
+	prefix := "dhcp." + dev + "."
+	if l == nil {
+		pub.Print(prefix, "address: ")
+		return
+	}
+	var routes []string
+	for _, r := range l.routes {
+		routes = append(routes, r.String())
+	}
+	for _, x := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"address", l.addr},
+		{"server", l.server},
+		{"routes", strings.Join(routes, ", ")},
+		{"dns", join(l.dns)},
+		{"ntp", join(l.ntp)},
+		{"hostname", l.hostname},
+		{"domain", l.domain},
+		{"search", strings.Join(l.search, " ")},
+		{"mtu", l.mtu},
+		{"expires", l.expires().Format(time.RFC3339)},
+	} {
+		pub.Print(prefix, x.name, ": ", x.value)
+	}
 }
@@ -0,0 +1,207 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package dhcpcd
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/d2g/dhcp4"
+)
+
+func TestDomainList(t *testing.T) {
+	// RFC 3397 example: eng.apple.com and marketing.apple.com with
+	// compression
+	b := []byte{
+		3, 'e', 'n', 'g', 5, 'a', 'p', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		9, 'm', 'a', 'r', 'k', 'e', 't', 'i', 'n', 'g', 0xc0, 4,
+	}
+	names, err := domainList(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"eng.apple.com", "marketing.apple.com"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, want %q", names, want)
+	}
+	if _, err = domainList([]byte{0xc0, 0}); err == nil {
+		t.Error("pointer loop not detected")
+	}
+	if _, err = domainList([]byte{3, 'c', 'o'}); err == nil {
+		t.Error("truncation not detected")
+	}
+}
+
+func TestClasslessRoutes(t *testing.T) {
+	routes, err := classlessRoutes([]byte{
+		0, 192, 168, 1, 1,
+		24, 10, 0, 1, 192, 168, 1, 2,
+		32, 10, 1, 2, 3, 0, 0, 0, 0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range routes {
+		got = append(got, r.String())
+	}
+	want := []string{
+		"0.0.0.0/0 via 192.168.1.1",
+		"10.0.1.0/24 via 192.168.1.2",
+		"10.1.2.3/32",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err = classlessRoutes([]byte{24, 10, 0}); err == nil {
+		t.Error("truncation not detected")
+	}
+}
+
+func TestLease(t *testing.T) {
+	p := dhcp4.NewPacket(dhcp4.BootReply)
+	p.SetYIAddr(net.IPv4(10, 0, 0, 5))
+	p.AddOption(dhcp4.OptionDHCPMessageType, []byte{byte(dhcp4.ACK)})
+	p.AddOption(dhcp4.OptionSubnetMask, []byte{255, 255, 255, 0})
+	p.AddOption(dhcp4.OptionRouter, []byte{10, 0, 0, 1})
+	p.AddOption(dhcp4.OptionDomainNameServer, []byte{8, 8, 8, 8, 8, 8, 4, 4})
+	p.AddOption(dhcp4.OptionIPAddressLeaseTime, []byte{0, 0, 0x0e, 0x10})
+	p.AddOption(dhcp4.OptionInterfaceMTU, []byte{0x23, 0x28})
+	p.AddOption(dhcp4.OptionHostName, []byte("switch1\x00"))
+	// a search list split in two as in RFC 3396
+	p.AddOption(optDomainSearch, []byte{3, 'l', 'a', 'b'})
+	p.AddOption(optDomainSearch, []byte{0})
+	now := time.Now()
+	l, err := newLease(p, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := l.addr.String(); s != "10.0.0.5/24" {
+		t.Error("address:", s)
+	}
+	if len(l.routes) != 1 || l.routes[0].String() != "0.0.0.0/0 via 10.0.0.1" {
+		t.Error("routes:", l.routes)
+	}
+	if len(l.dns) != 2 || !l.dns[1].Equal(net.IPv4(8, 8, 4, 4)) {
+		t.Error("dns:", l.dns)
+	}
+	if l.duration != time.Hour || l.t1 != 30*time.Minute ||
+		l.t2 != 52*time.Minute+30*time.Second {
+		t.Error("times:", l.duration, l.t1, l.t2)
+	}
+	if l.mtu != 9000 || l.hostname != "switch1" {
+		t.Error("mtu, hostname:", l.mtu, l.hostname)
+	}
+	if !reflect.DeepEqual(l.search, []string{"lab"}) {
+		t.Error("search:", l.search)
+	}
+
+	// classless routes replace the router option
+	p.AddOption(optClasslessRoute, []byte{8, 10, 10, 0, 0, 2})
+	if l, err = newLease(p, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.routes) != 1 || l.routes[0].String() != "10.0.0.0/8 via 10.0.0.2" {
+		t.Error("classless routes:", l.routes)
+	}
+}
+
+func TestResolvConf(t *testing.T) {
+	rc := &resolvConf{
+		domain: "example.com",
+		search: []string{"lab.example.com"},
+		nameservers: []net.IP{
+			net.IPv4(10, 0, 0, 1),
+			net.ParseIP("2001:db8::1"),
+		},
+	}
+	want := `# Generated by dhcpcd from eth0
+search example.com lab.example.com
+nameserver 10.0.0.1
+nameserver 2001:db8::1
+`
+	if got := string(rc.Bytes("eth0")); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLease6(t *testing.T) {
+	addr := net.ParseIP("2001:db8::5")
+	iaaddr := append([]byte{}, addr...)
+	iaaddr = append(iaaddr, 0, 0, 0x0e, 0x10, 0, 0, 0x1c, 0x20)
+	iana := append([]byte{0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+		encodeOpts6([]opt6{{opt6IAAddr, iaaddr}})...)
+	m := &msg6{
+		typ: dhcp6Reply,
+		opts: []opt6{
+			{opt6ServerID, []byte{0, 3, 0, 1, 1, 2, 3, 4, 5, 6}},
+			{opt6IANA, iana},
+			{opt6DNS, net.ParseIP("2001:db8::53")},
+			{opt6DomainList, []byte{3, 'l', 'a', 'b', 0}},
+		},
+	}
+	m, err := parseMsg6(m.bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := newLease6(m, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.addr.Equal(addr) || l.preferred != time.Hour ||
+		l.valid != 2*time.Hour {
+		t.Error("address:", l.addr, l.preferred, l.valid)
+	}
+	if l.t1 != 30*time.Minute || l.t2 != 48*time.Minute {
+		t.Error("times:", l.t1, l.t2)
+	}
+	if len(l.dns) != 1 || !reflect.DeepEqual(l.search, []string{"lab"}) {
+		t.Error("dns, search:", l.dns, l.search)
+	}
+
+	m.opts = append(m.opts, opt6{opt6Status, []byte{0, 2, 'n', 'o'}})
+	if _, err = newLease6(m, time.Now()); err == nil {
+		t.Error("status not detected")
+	}
+}
+
+func TestZtpSources(t *testing.T) {
+	p := dhcp4.NewPacket(dhcp4.BootReply)
+	p.SetYIAddr(net.IPv4(10, 0, 0, 5))
+	p.SetSIAddr(net.IPv4(10, 0, 0, 2))
+	p.AddOption(dhcp4.OptionBootFileName, []byte("/ztp/start.goes"))
+	l, err := newLease(p, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if script, _, _, _ := l.ztpSources(); script != "tftp://10.0.0.2/ztp/start.goes" {
+		t.Error("boot file:", script)
+	}
+
+	p.AddOption(optProvisioning, []byte("http://ztp/start.goes"))
+	p.AddOption(dhcp4.OptionVendorSpecificInformation, []byte{
+		ztpImageURL, 9, 'f', 'i', 'l', 'e', ':', '/', '/', '/', 'x',
+		ztpImageHash, 32,
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
+	})
+	if l, err = newLease(p, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	script, scriptHash, image, imageHash := l.ztpSources()
+	if script != "http://ztp/start.goes" || len(scriptHash) != 0 {
+		t.Error("script:", script, scriptHash)
+	}
+	if image != "file:///x" || imageHash != "sha256:000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" {
+		t.Error("image:", image, imageHash)
+	}
+	if _, _, err = newHash(imageHash); err != nil {
+		t.Error(err)
+	}
+	if _, _, err = newHash("md5:00"); err == nil {
+		t.Error("unsupported hash accepted")
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package dhcpcd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/d2g/dhcp4"
+)
+
+// LeaseDir is where the last acknowledgement is saved for each interface.
+const LeaseDir = "/var/lib/dhcpcd"
+
+const defaultLeaseTime = 86400 * time.Second
+
+type lease struct {
+	ack      dhcp4.Packet
+	acquired time.Time
+
+	addr     *net.IPNet
+	server   net.IP
+	routes   []route
+	dns      []net.IP
+	ntp      []net.IP
+	hostname string
+	domain   string
+	search   []string
+	mtu      int
+
+	// boot and provisioning options
+	tftpServer   string
+	bootFile     string
+	provisioning string
+	vendor       map[byte][]byte
+
+	duration, t1, t2 time.Duration
+}
+
+// parseOptions is like dhcp4.Packet.ParseOptions but concatenates the
+// values of repeated options as described by RFC 3396.
+func parseOptions(p dhcp4.Packet) dhcp4.Options {
+	opts := make(dhcp4.Options)
+	if len(p) < 240 {
+		return opts
+	}
+	b := p.Options()
+	for len(b) >= 2 && dhcp4.OptionCode(b[0]) != dhcp4.End {
+		if dhcp4.OptionCode(b[0]) == dhcp4.Pad {
+			b = b[1:]
+			continue
+		}
+		code, size := dhcp4.OptionCode(b[0]), int(b[1])
+		if len(b) < 2+size {
+			break
+		}
+		opts[code] = append(opts[code], b[2:2+size]...)
+		b = b[2+size:]
+	}
+	return opts
+}
+
+func newLease(ack dhcp4.Packet, acquired time.Time) (*lease, error) {
+	if len(ack) < 240 {
+		return nil, errors.New("short acknowledgement")
+	}
+	opt := parseOptions(ack)
+	l := &lease{
+		ack:      ack,
+		acquired: acquired,
+		duration: defaultLeaseTime,
+	}
+	ip := ack.YIAddr().To4()
+	if ip == nil || ip.IsUnspecified() {
+		return nil, errors.New("no address in acknowledgement")
+	}
+	mask := net.IPMask(opt[dhcp4.OptionSubnetMask])
+	if len(mask) != net.IPv4len {
+		mask = ip.DefaultMask()
+	}
+	l.addr = &net.IPNet{IP: append(net.IP{}, ip...), Mask: mask}
+	if ips := ipList(opt[dhcp4.OptionServerIdentifier], 4); len(ips) > 0 {
+		l.server = ips[0]
+	}
+	if b := opt[dhcp4.OptionIPAddressLeaseTime]; len(b) == 4 {
+		l.duration = time.Duration(binary.BigEndian.Uint32(b)) *
+			time.Second
+	}
+	l.t1 = l.duration / 2
+	if b := opt[dhcp4.OptionRenewalTimeValue]; len(b) == 4 {
+		l.t1 = time.Duration(binary.BigEndian.Uint32(b)) * time.Second
+	}
+	l.t2 = l.duration * 7 / 8
+	if b := opt[dhcp4.OptionRebindingTimeValue]; len(b) == 4 {
+		l.t2 = time.Duration(binary.BigEndian.Uint32(b)) * time.Second
+	}
+	if l.t2 > l.duration {
+		l.t2 = l.duration * 7 / 8
+	}
+	if l.t1 > l.t2 {
+		l.t1 = l.t2 / 2
+	}
+	// RFC 3442: the router option is ignored if there are classless
+	// static routes.
+	if b := opt[optClasslessRoute]; len(b) > 0 {
+		routes, err := classlessRoutes(b)
+		if err != nil {
+			return nil, fmt.Errorf("option %d: %v",
+				optClasslessRoute, err)
+		}
+		l.routes = routes
+	} else {
+		for _, gw := range ipList(opt[dhcp4.OptionRouter], 4) {
+			if !gw.IsUnspecified() {
+				l.routes = append(l.routes, route{
+					dst: &net.IPNet{
+						IP:   net.IPv4zero.To4(),
+						Mask: net.CIDRMask(0, 32),
+					},
+					gw: gw,
+				})
+				break
+			}
+		}
+	}
+	l.dns = ipList(opt[dhcp4.OptionDomainNameServer], 4)
+	l.ntp = ipList(opt[dhcp4.OptionNetworkTimeProtocolServers], 4)
+	l.hostname = str(opt[dhcp4.OptionHostName])
+	l.domain = str(opt[dhcp4.OptionDomainName])
+	if b := opt[optDomainSearch]; len(b) > 0 {
+		search, err := domainList(b)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "option %d: %v\n",
+				optDomainSearch, err)
+		}
+		l.search = search
+	}
+	if b := opt[dhcp4.OptionInterfaceMTU]; len(b) == 2 {
+		// RFC 2132 says that the minimum legal value is 68
+		if mtu := int(binary.BigEndian.Uint16(b)); mtu >= 68 {
+			l.mtu = mtu
+		}
+	}
+	l.tftpServer = str(opt[dhcp4.OptionTFTPServerName])
+	l.bootFile = str(opt[dhcp4.OptionBootFileName])
+	if len(l.bootFile) == 0 {
+		// the BOOTP file field, unless overloaded with options
+		if o := opt[dhcp4.OptionOverload]; len(o) == 0 || o[0]&1 == 0 {
+			l.bootFile = str(ack[108:236])
+		}
+	}
+	l.provisioning = str(opt[optProvisioning])
+	if b := opt[dhcp4.OptionVendorSpecificInformation]; len(b) > 0 {
+		vendor, err := encapsulated(b)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "option %d: %v\n",
+				dhcp4.OptionVendorSpecificInformation, err)
+		}
+		l.vendor = vendor
+	}
+	return l, nil
+}
+
+func (l *lease) renew() time.Time   { return l.acquired.Add(l.t1) }
+func (l *lease) rebind() time.Time  { return l.acquired.Add(l.t2) }
+func (l *lease) expires() time.Time { return l.acquired.Add(l.duration) }
+
+func (l *lease) String() string {
+	var s []string
+	s = append(s, fmt.Sprint("address ", l.addr))
+	for _, r := range l.routes {
+		s = append(s, fmt.Sprint("route ", r))
+	}
+	for _, ip := range l.dns {
+		s = append(s, fmt.Sprint("nameserver ", ip))
+	}
+	for _, ip := range l.ntp {
+		s = append(s, fmt.Sprint("ntp ", ip))
+	}
+	if len(l.hostname) > 0 {
+		s = append(s, "hostname "+l.hostname)
+	}
+	if len(l.domain) > 0 {
+		s = append(s, "domain "+l.domain)
+	}
+	if len(l.search) > 0 {
+		s = append(s, "search "+strings.Join(l.search, " "))
+	}
+	if l.mtu > 0 {
+		s = append(s, fmt.Sprint("mtu ", l.mtu))
+	}
+	s = append(s, fmt.Sprint("lease ", l.duration,
+		" renew ", l.t1, " rebind ", l.t2))
+	return strings.Join(s, "\n")
+}
+
+func leaseFile(dev string) string {
+	return filepath.Join(LeaseDir, dev+".lease")
+}
+
+// save the acknowledgement with its modification time as the time that the
+// lease was acquired
+func (l *lease) save(dev string) error {
+	if err := os.MkdirAll(LeaseDir, 0755); err != nil {
+		return err
+	}
+	fn := leaseFile(dev)
+	tmp := fn + ".tmp"
+	if err := ioutil.WriteFile(tmp, l.ack, 0644); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp, l.acquired, l.acquired); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// loadLease returns the saved lease of the given interface if it hasn't
+// expired.
+func loadLease(dev string) (*lease, error) {
+	fn := leaseFile(dev)
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	l, err := newLease(dhcp4.Packet(b), fi.ModTime())
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(l.expires()) {
+		return nil, fmt.Errorf("%s: expired", fn)
+	}
+	return l, nil
+}
@@ -0,0 +1,155 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package dhcpcd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DHCPv4 options that aren't named by github.com/d2g/dhcp4
+const (
+	optDomainSearch   = 119 // RFC 3397
+	optClasslessRoute = 121 // RFC 3442
+	optProvisioning   = 239 // site specific, a provisioning script URL
+)
+
+var errTruncated = errors.New("truncated option")
+
+type route struct {
+	dst *net.IPNet
+	gw  net.IP
+}
+
+func (r route) String() string {
+	if r.gw == nil || r.gw.IsUnspecified() {
+		return r.dst.String()
+	}
+	return r.dst.String() + " via " + r.gw.String()
+}
+
+// ipList decodes an option with a list of addresses of the given size
+func ipList(b []byte, size int) []net.IP {
+	var ips []net.IP
+	for ; len(b) >= size; b = b[size:] {
+		ip := make(net.IP, size)
+		copy(ip, b[:size])
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+// str decodes a string option, ignoring any NUL terminator
+func str(b []byte) string {
+	return strings.TrimRight(string(b), "\x00")
+}
+
+// domainList decodes a list of RFC 1035 encoded names, with compression
+// pointers, like DHCPv4 option 119 and DHCPv6 option 24.
+func domainList(b []byte) ([]string, error) {
+	var names []string
+	for i := 0; i < len(b); {
+		name, next, err := domainName(b, i)
+		if err != nil {
+			return names, err
+		}
+		if len(name) > 0 {
+			names = append(names, name)
+		}
+		i = next
+	}
+	return names, nil
+}
+
+// domainName decodes the name at b[i:] returning the name and the index
+// that follows it, not counting the labels referenced by pointers.
+func domainName(b []byte, i int) (string, int, error) {
+	var labels []string
+	next := -1
+	for hops := 0; ; hops++ {
+		if i >= len(b) {
+			return "", 0, errTruncated
+		}
+		if hops > len(b) {
+			return "", 0, errors.New("domain name pointer loop")
+		}
+		l := int(b[i])
+		switch {
+		case l == 0:
+			if next < 0 {
+				next = i + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case l&0xc0 == 0xc0:
+			if i+1 >= len(b) {
+				return "", 0, errTruncated
+			}
+			if next < 0 {
+				next = i + 2
+			}
+			i = (l&^0xc0)<<8 | int(b[i+1])
+		case l&0xc0 != 0:
+			return "", 0, fmt.Errorf("%#x: unsupported label type", l)
+		default:
+			if i+1+l > len(b) {
+				return "", 0, errTruncated
+			}
+			labels = append(labels, string(b[i+1:i+1+l]))
+			i += 1 + l
+		}
+	}
+}
+
+// encapsulated decodes the code, length, value sub-options of the vendor
+// specific information (43).
+func encapsulated(b []byte) (map[byte][]byte, error) {
+	m := make(map[byte][]byte)
+	for len(b) > 0 {
+		code := b[0]
+		if code == 0 { // pad
+			b = b[1:]
+			continue
+		}
+		if code == 255 { // end
+			break
+		}
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return m, errTruncated
+		}
+		m[code] = append(m[code], b[2:2+int(b[1])]...)
+		b = b[2+int(b[1]):]
+	}
+	return m, nil
+}
+
+// classlessRoutes decodes RFC 3442 option 121.
+func classlessRoutes(b []byte) ([]route, error) {
+	var routes []route
+	for len(b) > 0 {
+		width := int(b[0])
+		if width > 32 {
+			return nil, fmt.Errorf("%d: invalid prefix length", width)
+		}
+		octets := (width + 7) / 8
+		if len(b) < 1+octets+4 {
+			return nil, errTruncated
+		}
+		dst := make(net.IP, 4)
+		copy(dst, b[1:1+octets])
+		gw := make(net.IP, 4)
+		copy(gw, b[1+octets:1+octets+4])
+		routes = append(routes, route{
+			dst: &net.IPNet{
+				IP:   dst,
+				Mask: net.CIDRMask(width, 32),
+			},
+			gw: gw,
+		})
+		b = b[1+octets+4:]
+	}
+	return routes, nil
+}
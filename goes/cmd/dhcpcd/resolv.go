@@ -0,0 +1,81 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package dhcpcd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const ResolvConf = "/etc/resolv.conf"
+
+// The resolver ignores more than these many nameservers and search domains.
+const (
+	maxNameservers = 3
+	maxSearch      = 6
+)
+
+type resolvConf struct {
+	domain      string
+	search      []string
+	nameservers []net.IP
+}
+
+func (rc *resolvConf) empty() bool {
+	return len(rc.nameservers) == 0 && len(rc.domain) == 0 &&
+		len(rc.search) == 0
+}
+
+func (rc *resolvConf) Bytes(dev string) []byte {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "# Generated by dhcpcd from %s\n", dev)
+	// domain and search are mutually exclusive, the last one wins; so
+	// if there's a search list, it leads with the domain.
+	search := rc.search
+	if len(search) > 0 && len(rc.domain) > 0 && search[0] != rc.domain {
+		search = append([]string{rc.domain}, search...)
+	}
+	if len(search) > maxSearch {
+		search = search[:maxSearch]
+	}
+	if len(search) > 0 {
+		fmt.Fprintln(buf, "search", strings.Join(search, " "))
+	} else if len(rc.domain) > 0 {
+		fmt.Fprintln(buf, "domain", rc.domain)
+	}
+	for i, ip := range rc.nameservers {
+		if i == maxNameservers {
+			break
+		}
+		fmt.Fprintln(buf, "nameserver", ip)
+	}
+	return buf.Bytes()
+}
+
+// write resolv.conf through a temporary file so that the resolver never
+// sees a partial file
+func (rc *resolvConf) write(dev string) error {
+	if rc.empty() {
+		return nil
+	}
+	fn := ResolvConf
+	if dst, err := filepath.EvalSymlinks(fn); err == nil {
+		fn = dst
+	}
+	tmp := fn + ".dhcpcd"
+	if err := ioutil.WriteFile(tmp, rc.Bytes(dev), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, fn); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
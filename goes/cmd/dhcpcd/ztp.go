@@ -0,0 +1,256 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package dhcpcd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/go/internal/url"
+	"github.com/platinasystems/redis"
+	"github.com/platinasystems/redis/publisher"
+)
+
+// Zero touch provisioning runs a goes script named by the first DHCPv4
+// lease unless disabled with ZTP=no in /etc/default/goes or already done.
+//
+// The script, and an optional image, are named by these vendor specific
+// (43) sub-options,
+//
+//	1	script URL
+//	2	script hash
+//	3	image URL
+//	4	image hash
+//
+// or else, the site specific provisioning URL option (239), or else the TFTP
+// server (66) and boot file name (67). A hash is the hexadecimal SHA-256 or
+// SHA-512 digest of the file, optionally prefaced by "sha256:" or
+// "sha512:"; a 32 byte binary value is also taken as SHA-256.
+const (
+	ztpScriptURL byte = 1 + iota
+	ztpScriptHash
+	ztpImageURL
+	ztpImageHash
+)
+
+var errNoZtp = errors.New("no provisioning script")
+
+const (
+	ZtpDir         = "/var/lib/ztp"
+	EtcDefaultGoes = "/etc/default/goes"
+)
+
+// ztpEnabled returns false if /etc/default/goes has ZTP set to no, false,
+// off, disable or 0; or if provisioning already completed.
+func ztpEnabled() bool {
+	if _, err := os.Stat(filepath.Join(ZtpDir, "done")); err == nil {
+		return false
+	}
+	f, err := os.Open(EtcDefaultGoes)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	enabled := true
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		line = strings.TrimPrefix(line, "export ")
+		if !strings.HasPrefix(line, "ZTP=") {
+			continue
+		}
+		v := strings.Trim(strings.TrimPrefix(line, "ZTP="), `"'`)
+		switch strings.ToLower(v) {
+		case "no", "false", "off", "disable", "disabled", "0":
+			enabled = false
+		default:
+			enabled = true
+		}
+	}
+	return enabled
+}
+
+// ztpSources returns the script and image URLs and hashes of the lease.
+func (l *lease) ztpSources() (script, scriptHash, image, imageHash string) {
+	if l.vendor != nil {
+		script = str(l.vendor[ztpScriptURL])
+		scriptHash = ztpHash(l.vendor[ztpScriptHash])
+		image = str(l.vendor[ztpImageURL])
+		imageHash = ztpHash(l.vendor[ztpImageHash])
+	}
+	if len(script) == 0 {
+		script = l.provisioning
+	}
+	if len(script) == 0 && len(l.bootFile) > 0 {
+		script = l.bootFile
+		if !strings.Contains(script, "://") {
+			server := l.tftpServer
+			if len(server) == 0 {
+				if ip := l.ack.SIAddr(); !ip.IsUnspecified() {
+					server = ip.String()
+				}
+			}
+			if len(server) > 0 {
+				script = "tftp://" + server + "/" +
+					strings.TrimPrefix(script, "/")
+			}
+		}
+	}
+	return
+}
+
+func ztpHash(b []byte) string {
+	if len(b) == sha256.Size {
+		return "sha256:" + hex.EncodeToString(b)
+	}
+	return str(b)
+}
+
+// newHash returns the hash for the given "[ALGORITHM:]HEX" digest.
+func newHash(digest string) (hash.Hash, []byte, error) {
+	alg := ""
+	if i := strings.Index(digest, ":"); i > 0 {
+		alg, digest = strings.ToLower(digest[:i]), digest[i+1:]
+	}
+	sum, err := hex.DecodeString(digest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%q: %v", digest, err)
+	}
+	switch {
+	case alg == "sha256" || (alg == "" && len(sum) == sha256.Size):
+		if len(sum) == sha256.Size {
+			return sha256.New(), sum, nil
+		}
+	case alg == "sha512" || (alg == "" && len(sum) == sha512.Size):
+		if len(sum) == sha512.Size {
+			return sha512.New(), sum, nil
+		}
+	case alg != "":
+		return nil, nil, fmt.Errorf("%q: unsupported hash", alg)
+	}
+	return nil, nil, fmt.Errorf("%q: wrong digest length", digest)
+}
+
+// fetch the URL into a file of ZtpDir verifying the optional digest. The
+// file is named by the URL digest so that those with the same base name
+// don't overwrite each other.
+func fetch(src, digest string) (string, error) {
+	var h hash.Hash
+	var sum []byte
+	if len(digest) > 0 {
+		var err error
+		if h, sum, err = newHash(digest); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(ZtpDir, 0755); err != nil {
+		return "", err
+	}
+	name := path.Base(src)
+	if name == "/" || name == "." {
+		name = "script"
+	}
+	key := sha256.Sum256([]byte(src))
+	fn := filepath.Join(ZtpDir, hex.EncodeToString(key[:8])+"-"+name)
+	r, err := url.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	tmp := fn + ".tmp"
+	w, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	var dst io.Writer = w
+	if h != nil {
+		dst = io.MultiWriter(w, h)
+	}
+	_, err = io.Copy(dst, r)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && h != nil {
+		if got := h.Sum(nil); string(got) != string(sum) {
+			err = fmt.Errorf("%s: hash mismatch, got %x", src, got)
+		}
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return fn, os.Rename(tmp, fn)
+}
+
+func ztpPublish(field string, value interface{}) {
+	fmt.Printf("ztp.%s: %v\n", field, value)
+	if redis.IsReady() != nil {
+		return
+	}
+	pub, err := publisher.New()
+	if err != nil {
+		return
+	}
+	defer pub.Close()
+	pub.Print("ztp.", field, ": ", value)
+}
+
+// ztp fetches and runs the provisioning script of the lease. It returns
+// an error if there wasn't a script or it failed, to retry with a later
+// lease.
+func (c *Command) ztp(dev string, l *lease) error {
+	script, scriptHash, image, imageHash := l.ztpSources()
+	if len(script) == 0 {
+		return errNoZtp
+	}
+	fail := func(err error) error {
+		ztpPublish("status", fmt.Sprint("failed: ", err))
+		return err
+	}
+	ztpPublish("url", script)
+	env := append(os.Environ(), "ZTP_INTERFACE="+dev)
+	if len(image) > 0 {
+		ztpPublish("image", image)
+		ztpPublish("status", "downloading image")
+		fn, err := fetch(image, imageHash)
+		if err != nil {
+			return fail(err)
+		}
+		env = append(env, "ZTP_IMAGE="+fn)
+	}
+	ztpPublish("status", "downloading script")
+	fn, err := fetch(script, scriptHash)
+	if err != nil {
+		return fail(err)
+	}
+	ztpPublish("status", "running")
+	x := c.g.Fork("cli", fn)
+	x.Env = env
+	x.Stdout = os.Stdout
+	x.Stderr = os.Stderr
+	if err = x.Run(); err != nil {
+		return fail(err)
+	}
+	done := fmt.Sprintln(time.Now().Format(time.RFC3339), script)
+	err = ioutil.WriteFile(filepath.Join(ZtpDir, "done"), []byte(done),
+		0644)
+	if err != nil {
+		return fail(err)
+	}
+	ztpPublish("status", "done")
+	return nil
+}
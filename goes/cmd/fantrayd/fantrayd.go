@@ -15,6 +15,7 @@ import (
 	"github.com/platinasystems/atsock"
 	"github.com/platinasystems/go/goes/cmd"
 	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/thermal"
 	"github.com/platinasystems/log"
 	"github.com/platinasystems/redis"
 	"github.com/platinasystems/redis/publisher"
@@ -158,10 +159,7 @@ func (c *Command) update() error {
 	return nil
 }
 
-const (
-	fanTrayLeds = 0x33
-	minRpm      = 2000
-)
+const fanTrayLeds = 0x33
 
 var fanTrayLedOff = []uint8{0x0, 0x0, 0x0, 0x0}
 var fanTrayLedGreen = []uint8{0x20, 0x02, 0x20, 0x02}
@@ -294,13 +292,14 @@ func (h *I2cDev) FanTrayStatus(i uint8) (string, error) {
 			}
 		}
 
-		//check fan speed is above minimum
+		//check fan speed is above the minimum of the thermal policy
+		minRpm := thermal.MinRpm()
 		f1 := "fan_tray." + strconv.Itoa(int(i+1)) + ".1.speed.units.rpm"
 		f2 := "fan_tray." + strconv.Itoa(int(i+1)) + ".2.speed.units.rpm"
 		s1, _ := redis.Hget(redis.DefaultHash, f1)
 		s2, _ := redis.Hget(redis.DefaultHash, f2)
-		r1, _ := strconv.ParseInt(s1, 10, 64)
-		r2, _ := strconv.ParseInt(s2, 10, 64)
+		r1, _ := strconv.ParseFloat(s1, 64)
+		r2, _ := strconv.ParseFloat(s2, 64)
 
 		if s1 == "" && s2 == "" {
 			o |= fanTrayLedYellow[i]
@@ -310,7 +309,7 @@ func (h *I2cDev) FanTrayStatus(i uint8) (string, error) {
 		} else if mismatch {
 			w = "ok" + "." + f
 			o |= fanTrayLedYellow[i]
-		} else if (r1 <= minRpm) || (r2 < minRpm) {
+		} else if (r1 <= minRpm) || (r2 <= minRpm) {
 			w = "warning low rpm detected"
 			o |= fanTrayLedYellow[i]
 		}
@@ -9,6 +9,7 @@ import (
 	"time"
 	"unsafe"
 
+	"github.com/platinasystems/go/internal/i2cbus"
 	"github.com/platinasystems/i2c"
 	"github.com/platinasystems/log"
 )
@@ -136,13 +137,11 @@ func clearJ() {
 }
 
 func readStopped() byte {
-	var data = [34]byte{0, 0, 0, 0}
-	j[0] = I{true, i2c.Write, 0, i2c.ByteData, data, int(0x98), int(0), 0}
-	err := DoI2cRpc()
-	if err != nil {
+	paused, err := i2cbus.Paused()
+	if err != nil || paused {
 		return 1
 	}
-	return s[0].D[0]
+	return 0
 }
 
 func DoI2cRpc() error {
@@ -0,0 +1,126 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package findmnt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/fstab"
+	"github.com/platinasystems/go/internal/parms"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "findmnt" }
+
+func (Command) Usage() string {
+	return "findmnt [-l] [-t FSTYPE[,...]] [TARGET|SOURCE]"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "list mounted filesystems",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	List the mounts of /proc/self/mountinfo as a tree of TARGET,
+	SOURCE, FSTYPE and OPTIONS; or just those of the given TARGET or
+	SOURCE.
+
+OPTIONS
+	-l	list without the tree
+	-t FSTYPE[,...]
+		only list mounts of these types`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-l")
+	parm, args := parms.New(args, "-t")
+	if len(args) > 1 {
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+
+	mounts, err := fstab.ReadMountinfo(fstab.MountinfoFile)
+	if err != nil {
+		return err
+	}
+	types := fstab.Split(parm.ByName["-t"])
+	show := func(m *fstab.Mount) bool {
+		if len(types) > 0 {
+			found := false
+			for _, t := range types {
+				found = found || t == m.Type
+			}
+			if !found {
+				return false
+			}
+		}
+		return len(args) == 0 || args[0] == m.Target ||
+			args[0] == m.Source
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "TARGET\tSOURCE\tFSTYPE\tOPTIONS")
+	line := func(indent string, m *fstab.Mount) {
+		source := m.Source
+		if m.Root != "/" {
+			source += "[" + m.Root + "]"
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", indent, m.Target, source,
+			m.Type, strings.Join(m.Options, ","))
+	}
+	if flag.ByName["-l"] || len(args) > 0 || len(types) > 0 {
+		for i := range mounts {
+			if show(&mounts[i]) {
+				line("", &mounts[i])
+			}
+		}
+		return nil
+	}
+
+	ids := make(map[int]bool)
+	for _, m := range mounts {
+		ids[m.ID] = true
+	}
+	var walk func(parent int, prefix string)
+	walk = func(parent int, prefix string) {
+		var children []*fstab.Mount
+		for i := range mounts {
+			m := &mounts[i]
+			if m.Parent == parent && m.ID != parent {
+				children = append(children, m)
+			}
+		}
+		for i, m := range children {
+			last := i+1 == len(children)
+			branch, next := "├─", "│ "
+			if last {
+				branch, next = "└─", "  "
+			}
+			line(prefix+branch, m)
+			walk(m.ID, prefix+next)
+		}
+	}
+	// the roots are those whose parent is outside of this namespace
+	for i := range mounts {
+		m := &mounts[i]
+		if !ids[m.Parent] || m.Parent == m.ID {
+			line("", m)
+			walk(m.ID, "")
+		}
+	}
+	return nil
+}
@@ -9,7 +9,6 @@ package fspd
 import (
 	"encoding/hex"
 	"fmt"
-	"math"
 	"net/rpc"
 	"strconv"
 	"strings"
@@ -20,6 +19,7 @@ import (
 	"github.com/platinasystems/atsock"
 	"github.com/platinasystems/go/goes/cmd"
 	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/pmbus"
 	"github.com/platinasystems/gpio"
 	"github.com/platinasystems/log"
 	"github.com/platinasystems/redis"
@@ -352,9 +352,9 @@ func (c *Command) updateMon() error {
 					if err != nil {
 						return err
 					}
-					if v != c.lastu[k] {
+					if v != c.lasts[k] {
 						c.pub.Print(k, ": ", v)
-						c.lastu[k] = v
+						c.lasts[k] = v
 					}
 				}
 				if strings.Contains(k, "status_vout") {
@@ -362,9 +362,9 @@ func (c *Command) updateMon() error {
 					if err != nil {
 						return err
 					}
-					if v != c.lastu[k] {
+					if v != c.lasts[k] {
 						c.pub.Print(k, ": ", v)
-						c.lastu[k] = v
+						c.lasts[k] = v
 					}
 				}
 				if strings.Contains(k, "status_iout") {
@@ -372,9 +372,9 @@ func (c *Command) updateMon() error {
 					if err != nil {
 						return err
 					}
-					if v != c.lastu[k] {
+					if v != c.lasts[k] {
 						c.pub.Print(k, ": ", v)
-						c.lastu[k] = v
+						c.lasts[k] = v
 					}
 				}
 				if strings.Contains(k, "status_input") {
@@ -382,9 +382,9 @@ func (c *Command) updateMon() error {
 					if err != nil {
 						return err
 					}
-					if v != c.lastu[k] {
+					if v != c.lasts[k] {
 						c.pub.Print(k, ": ", v)
-						c.lastu[k] = v
+						c.lasts[k] = v
 					}
 				}
 				if strings.Contains(k, "v_in") {
@@ -432,9 +432,9 @@ func (c *Command) updateMon() error {
 					if err != nil {
 						return err
 					}
-					if v != c.lastu[k] {
+					if v != c.lasts[k] {
 						c.pub.Print(k, ": ", v)
-						c.lastu[k] = v
+						c.lasts[k] = v
 					}
 				}
 				if strings.Contains(k, "p_out") {
@@ -502,9 +502,9 @@ func (c *Command) updateMon() error {
 					if err != nil {
 						return err
 					}
-					if v != c.lastu[k] {
+					if v != c.lasts[k] {
 						c.pub.Print(k, ": ", v)
-						c.lastu[k] = v
+						c.lasts[k] = v
 					}
 				}
 				if strings.Contains(k, "temp1") {
@@ -545,75 +545,32 @@ func (c *Command) updateMon() error {
 }
 
 func (h *I2cDev) convertVoutMode(voutMode uint8, vout uint16) float64 {
-	var nn float64
-	n := voutMode & 0x1f
-	if n > 0xf {
-		n = ((n ^ 0x1f) + 1) & 0x1f
-		nn = float64(n) * (-1)
-	} else {
-		nn = float64(n)
-	}
-	vv := (float64(vout) * (math.Exp2(nn)))
-	vv, _ = strconv.ParseFloat(fmt.Sprintf("%.3f", vv), 64)
-	return vv
+	vv, _ := pmbus.Mode(voutMode & 0x1f).Linear16(vout)
+	return round(vv)
 }
 
 func (h *I2cDev) convertLinear(v uint16) (float64, error) {
-	var nn int
-	var y int
-	if (v >> 11) > 0xf {
-		nn = int(((v>>11)^0x1f)+1) * (-1)
-	} else {
-		nn = int(v >> 11)
-	}
-	v = v & 0x7ff
-	if v > 0x3ff {
-		y = int(v^0x7ff+1) * (-1)
-	} else {
-		y = int(v)
-	}
-	vv := float64(y) * (math.Exp2(float64(nn)))
-	vv, _ = strconv.ParseFloat(fmt.Sprintf("%.3f", vv), 64)
-	return vv, nil
+	return round(pmbus.Linear11(v)), nil
+}
+
+// round to the published millivolts, milliamps and milliwatts.
+func round(v float64) float64 {
+	v, _ = strconv.ParseFloat(fmt.Sprintf("%.3f", v), 64)
+	return v
 }
 
 func (h *I2cDev) convert(v uint16) (float64, error) {
 	if strings.Contains(h.Id, "Great Wall") {
-		var nn int
-		var y int
-		if (v >> 11) > 0xf {
-			nn = int(((v>>11)^0x1f)+1) * (-1)
-		} else {
-			nn = int(v >> 11)
-		}
-		v = v & 0x7ff
-		if v > 0x3ff {
-			y = int(v^0x7ff+1) * (-1)
-		} else {
-			y = int(v)
-		}
-		vv := float64(y) * (math.Exp2(float64(nn)))
-		vv, _ = strconv.ParseFloat(fmt.Sprintf("%.3f", vv), 64)
-		return vv, nil
+		return h.convertLinear(v)
 	} else if strings.Contains(h.Id, "FSP") {
 		r := getRegs()
-		var nn float64
 		r.VoutMode.get(h)
 		closeMux(h)
 		err := DoI2cRpc()
 		if err != nil {
 			return 0, err
 		}
-		n := (uint16(s[1].D[0])) & 0x1f
-		if n > 0xf {
-			n = ((n ^ 0x1f) + 1) & 0x1f
-			nn = float64(n) * (-1)
-		} else {
-			nn = float64(n)
-		}
-		vv := (float64(v) * (math.Exp2(nn)))
-		vv, _ = strconv.ParseFloat(fmt.Sprintf("%.3f", vv), 64)
-		return vv, nil
+		return h.convertVoutMode(s[1].D[0], v), nil
 	} else {
 		return 0, nil
 	}
@@ -642,76 +599,55 @@ func (h *I2cDev) PageWr(i uint16) error {
 	return nil
 }
 
-func (h *I2cDev) StatusWord() (uint16, error) {
-	r := getRegs()
-	r.StatusWord.get(h)
-	closeMux(h)
-	err := DoI2cRpc()
+// StatusWord returns the named faults of STATUS_WORD's summary bits and
+// their STATUS registers, e.g. STATUS_VOUT.VOUT_OV_FAULT, or "none".
+func (h *I2cDev) StatusWord() (string, error) {
+	faults, err := h.device().Faults()
 	if err != nil {
-		return 0, err
+		return "", err
 	}
-	t := uint16(s[1].D[0]) + (uint16(s[1].D[1]) << 8)
-	return uint16(t), nil
+	return faultString(faults), nil
 }
 
-func (h *I2cDev) StatusVout() (uint16, error) {
-	r := getRegs()
-	r.StatusVout.get(h)
-	closeMux(h)
-	err := DoI2cRpc()
-	if err != nil {
-		return 0, err
-	}
-	t := uint16(s[1].D[0])
-	return uint16(t), nil
+func (h *I2cDev) StatusVout() (string, error) {
+	return h.status(pmbus.StatusVout)
 }
 
-func (h *I2cDev) StatusIout() (uint16, error) {
-	r := getRegs()
-	r.StatusIout.get(h)
-	closeMux(h)
-	err := DoI2cRpc()
-	if err != nil {
-		return 0, err
-	}
-	t := uint16(s[1].D[0])
-	return uint16(t), nil
+func (h *I2cDev) StatusIout() (string, error) {
+	return h.status(pmbus.StatusIout)
 }
 
-func (h *I2cDev) StatusInput() (uint16, error) {
-	r := getRegs()
-	r.StatusInput.get(h)
-	closeMux(h)
-	err := DoI2cRpc()
-	if err != nil {
-		return 0, err
-	}
-	t := uint16(s[1].D[0])
-	return uint16(t), nil
+func (h *I2cDev) StatusInput() (string, error) {
+	return h.status(pmbus.StatusInput)
 }
 
-func (h *I2cDev) StatusTemp() (uint16, error) {
-	r := getRegs()
-	r.StatusTemp.get(h)
-	closeMux(h)
-	err := DoI2cRpc()
+func (h *I2cDev) StatusTemp() (string, error) {
+	return h.status(pmbus.StatusTemperature)
+}
+
+func (h *I2cDev) StatusFans() (string, error) {
+	return h.status(pmbus.StatusFans12)
+}
+
+// device is the PSU as a pmbus.Device behind its mux.
+func (h *I2cDev) device() *pmbus.Device {
+	return &pmbus.Device{Bus: h.Bus, Addr: h.Addr, Backend: h}
+}
+
+// status returns the named faults of the STATUS register, or "none".
+func (h *I2cDev) status(c pmbus.Cmd) (string, error) {
+	b, err := h.device().ReadByteData(c)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
-	t := uint16(s[1].D[0])
-	return uint16(t), nil
+	return faultString(pmbus.Faults(c, uint16(b))), nil
 }
 
-func (h *I2cDev) StatusFans() (uint16, error) {
-	r := getRegs()
-	r.StatusFans.get(h)
-	closeMux(h)
-	err := DoI2cRpc()
-	if err != nil {
-		return 0, err
+func faultString(faults []string) string {
+	if len(faults) == 0 {
+		return "none"
 	}
-	t := uint16(s[1].D[0])
-	return uint16(t), nil
+	return strings.Join(faults, ",")
 }
 
 func (h *I2cDev) Vin() (string, error) {
@@ -956,11 +892,10 @@ func (h *I2cDev) MfgIdent() (string, error) {
 		return "FSP", nil
 	}
 	n := s[1].D[1] + 2
-	t := string(s[1].D[2:n])
+	t := pmbus.Text(s[1].D[2:n])
 	if t == "Not Supported" {
 		t = "FSP"
 	}
-	t = strings.Trim(t, "#")
 	h.Id = t
 	return t, nil
 }
@@ -978,11 +913,10 @@ func (h *I2cDev) MfgModel() (string, error) {
 		return "FSP", nil
 	}
 	n := s[1].D[1] + 2
-	t := string(s[1].D[2:n])
+	t := pmbus.Text(s[1].D[2:n])
 	if t == "Not Supported" {
 		t = "FSP"
 	}
-	t = strings.Trim(t, "#")
 	h.Model = t
 	return t, nil
 }
@@ -5,10 +5,9 @@
 package fspd
 
 import (
-	"net/rpc"
-	"time"
 	"unsafe"
 
+	"github.com/platinasystems/go/internal/i2cbus"
 	"github.com/platinasystems/i2c"
 	"github.com/platinasystems/log"
 )
@@ -43,9 +42,6 @@ var dummy byte
 var regsPointer = unsafe.Pointer(&dummy)
 var regsAddr = uintptr(unsafe.Pointer(&dummy))
 
-var clientA *rpc.Client
-var dialed int = 0
-
 // offset function has divide by two for 16-bit offset struct
 func getRegs() *regs {
 	clearJ()
@@ -158,6 +154,27 @@ func (r *reg16r) set(h *I2cDev, v uint16) {
 	x++
 }
 
+// Do the op behind the device's mux, as the i2cbus.Backend of its
+// pmbus.Device.
+func (h *I2cDev) Do(bus, addr int, rw i2c.RW, cmd uint8, size i2c.SMBusSize,
+	data *i2c.SMBusData) error {
+	var d = [34]byte{0, 0, 0, 0}
+
+	clearJ()
+	d[0] = byte(h.MuxValue)
+	j[x] = I{true, i2c.Write, 0, i2c.ByteData, d, h.MuxBus, h.MuxAddr, 5}
+	x++
+	copy(d[:], data[:])
+	j[x] = I{true, rw, cmd, size, d, bus, addr, 0}
+	x++
+	closeMux(h)
+	if err := DoI2cRpc(); err != nil {
+		return err
+	}
+	copy(data[:], s[1].D[:])
+	return nil
+}
+
 func clearJ() {
 	x = 0
 	for k := 0; k < MAXOPS; k++ {
@@ -166,51 +183,33 @@ func clearJ() {
 }
 
 func readStopped() byte {
-	var data = [34]byte{0, 0, 0, 0}
-	j[0] = I{true, i2c.Write, 0, i2c.ByteData, data, int(0x98), int(0), 0}
-	err := DoI2cRpc()
-	if err != nil {
+	if i2cbus.Stopped() {
 		return 1
 	}
-	return s[0].D[0]
+	return 0
 }
 
 func stopI2c() error {
-	var data = [34]byte{0, 0, 0, 0}
-	j[0] = I{true, i2c.Write, 0, 0, data, int(0x99), int(1), 0}
-	err := DoI2cRpc()
-	if err != nil {
-		return err
-	}
-	return nil
+	return i2cbus.Pause()
 }
 
 func startI2c() error {
-	var data = [34]byte{0, 0, 0, 0}
-	j[0] = I{true, i2c.Write, 0, 0, data, int(0x99), int(0), 0}
-	err := DoI2cRpc()
-	if err != nil {
-		return err
-	}
-	return nil
+	return i2cbus.Resume()
 }
 
 func DoI2cRpc() error {
-	if dialed == 0 {
-		client, err := rpc.DialHTTP("tcp", "127.0.0.1"+":1233")
-		if err != nil {
-			log.Print("dialing:", err)
-			return err
-		}
-		clientA = client
-		dialed = 1
-		time.Sleep(time.Millisecond * time.Duration(50))
+	var g [MAXOPS]i2cbus.I
+	var f [MAXOPS]i2cbus.R
+	for k := range j {
+		g[k] = i2cbus.I(j[k])
 	}
-	err := clientA.Call("I2cReq.ReadWrite", &j, &s)
-	if err != nil {
+	if err := i2cbus.ReadWrite(&g, &f); err != nil {
 		log.Print("i2cReq error:", err)
 		return err
 	}
+	for k := range s {
+		s[k] = R(f[k])
+	}
 	clearJ()
 	return nil
 }
@@ -0,0 +1,100 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fspd
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/go/internal/i2cbus/i2cbustest"
+)
+
+var testDev = I2cDev{
+	Bus:      1,
+	Addr:     0x58,
+	AddrProm: 0x50,
+	MuxBus:   0,
+	MuxAddr:  0x76,
+	MuxValue: 0x01,
+}
+
+func TestPsu(t *testing.T) {
+	i2cbustest.Simulate(t, "testdata/psu.sim")
+	h := testDev
+
+	id, err := h.MfgIdent()
+	if err != nil || id != "FSP-GROUP" || h.Id != id {
+		t.Fatalf("MfgIdent: %q, %v", id, err)
+	}
+	if model, err := h.MfgModel(); err != nil || model != "YM-2851F" {
+		t.Errorf("MfgModel: %q, %v", model, err)
+	}
+	if w, err := h.StatusWord(); err != nil || w != "none" {
+		t.Errorf("StatusWord: %q, %v", w, err)
+	}
+	for _, x := range []struct {
+		name string
+		f    func() (string, error)
+		want string
+	}{
+		{"Vin", h.Vin, "13.500"},
+		{"Vout", h.Vout, "12.000"},
+		{"Iout", h.Iout, "12.500"},
+		{"Temp1", h.Temp1, "35.000"},
+		{"Temp2", h.Temp2, "41.000"},
+		{"FanSpeed", h.FanSpeed, "5000"},
+	} {
+		if v, err := x.f(); err != nil || v != x.want {
+			t.Errorf("%s: %q, %v", x.name, v, err)
+		}
+	}
+	v, err := h.Eeprom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := hex.DecodeString(v)
+	if len(b) != 256 || !strings.HasPrefix(string(b), "FSP550-20FM") {
+		t.Errorf("Eeprom: %q", b)
+	}
+}
+
+func TestPsuFaults(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/psu.sim")
+	h := testDev
+
+	psu := sim.Device(1, 0x58)
+	psu.SetWord(0, 0x79, 0x8000)
+	psu.SetBytes(0, 0x7a, 0x90)
+	if w, err := h.StatusWord(); err != nil ||
+		w != "STATUS_VOUT.VOUT_OV_FAULT,STATUS_VOUT.VOUT_UV_FAULT" {
+		t.Errorf("StatusWord of VOUT faults: %q, %v", w, err)
+	}
+	if v, err := h.StatusVout(); err != nil ||
+		v != "VOUT_OV_FAULT,VOUT_UV_FAULT" {
+		t.Errorf("StatusVout: %q, %v", v, err)
+	}
+
+	// and the STATUS_BYTE fallback
+	psu.Inject(i2cbus.Nak, 2)
+	if _, err := h.StatusWord(); err == nil {
+		t.Error("StatusWord of NAKing PSU")
+	}
+	if _, err := h.StatusWord(); err != nil {
+		t.Error("StatusWord after NAK:", err)
+	}
+
+	sim.Device(0, 0x76).Inject(i2cbus.Stuck, 1)
+	if _, err := h.Vin(); err == nil {
+		t.Error("Vin of stuck bus")
+	}
+
+	// a PSU without MFR_ID reads as all ones
+	sim.Device(1, 0x58).Inject(i2cbus.Garbage, 1)
+	if id, err := h.MfgIdent(); err != nil || id != "FSP" {
+		t.Errorf("MfgIdent of garbage: %q, %v", id, err)
+	}
+}
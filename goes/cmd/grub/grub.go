@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -30,9 +31,11 @@ import (
 	"github.com/platinasystems/go/goes/cmd/grub/initrd"
 	"github.com/platinasystems/go/goes/cmd/grub/insmod"
 	"github.com/platinasystems/go/goes/cmd/grub/linux"
+	"github.com/platinasystems/go/goes/cmd/grub/load_env"
 	"github.com/platinasystems/go/goes/cmd/grub/loadfont"
 	"github.com/platinasystems/go/goes/cmd/grub/menuentry"
 	"github.com/platinasystems/go/goes/cmd/grub/recordfail"
+	"github.com/platinasystems/go/goes/cmd/grub/save_env"
 	"github.com/platinasystems/go/goes/cmd/grub/search"
 	"github.com/platinasystems/go/goes/cmd/grub/set"
 	"github.com/platinasystems/go/goes/cmd/grub/submenu"
@@ -46,13 +49,21 @@ import (
 	"github.com/platinasystems/go/goes/lang"
 
 	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/grubenv"
 	"github.com/platinasystems/go/internal/parms"
 	"github.com/platinasystems/go/internal/url"
 
 	"github.com/platinasystems/liner"
 )
 
-type Command struct{}
+type Command struct {
+	// envFile is the grubenv of a local configuration, and env its
+	// variables.
+	envFile string
+	env     map[string]string
+	// entry is the path of the chosen menu entry, e.g. "Advanced>1"
+	entry string
+}
 
 var Goes = &goes.Goes{
 	NAME: "grub",
@@ -75,9 +86,11 @@ var Goes = &goes.Goes{
 		"insmod":           insmod.Command{},
 		"kexec":            kexec.Command{},
 		"linux":            Linux,
+		"load_env":         LoadEnv,
 		"loadfont":         loadfont.Command{},
 		"menuentry":        Menuentry,
-		"recordfail":       recordfail.Command{},
+		"recordfail":       Recordfail,
+		"save_env":         SaveEnv,
 		"search":           &search.Command{},
 		"set":              &set.Command{},
 		"submenu":          submenu.Command{M: Menuentry},
@@ -94,18 +107,30 @@ var Initrd = &initrd.Command{}
 
 var Menuentry = &menuentry.Command{}
 
+var LoadEnv = &load_env.Command{}
+
+var SaveEnv = &save_env.Command{}
+
+var Recordfail = &recordfail.Command{}
+
 func (c *Command) Apropos() lang.Alt {
 	return Goes.Apropos()
 }
 
 func (c *Command) Main(args ...string) error {
 	parm, args := parms.New(args, "-t")
-	flag, args := flags.New(args, "--daemon")
+	flag, args := flags.New(args, "--daemon", "--headless")
 
 	n := "/boot/grub/grub.cfg"
 	if len(args) > 0 {
 		n = args[0]
 	}
+	if !strings.Contains(n, "://") {
+		c.envFile = filepath.Join(filepath.Dir(n), "grubenv")
+		LoadEnv.File = c.envFile
+		SaveEnv.File = c.envFile
+		Recordfail.File = c.envFile
+	}
 	script, err := url.Open(n)
 	if err != nil {
 		return err
@@ -129,6 +154,7 @@ func (c *Command) Main(args ...string) error {
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Grub script returned %s\n", err)
 	}
+	c.loadEnv()
 
 	root := Goes.EnvMap["root"]
 	fmt.Printf("Root is %s translated %s\n", root, c.GetRoot())
@@ -170,17 +196,29 @@ func (c *Command) Usage() string {
 }
 
 func (c *Command) RunMenu(m []menuentry.Entry, parm *parms.Parms, flag *flags.Flags) (err error) {
+	def, why := grubenv.Default(c.env, Goes.EnvMap["default"])
+	if len(why) > 0 {
+		fmt.Printf("Default entry %q from %s\n", def, why)
+		if why == grubenv.NextEntry {
+			c.saveEnv()
+		}
+	}
+	path := strings.Split(def, ">")
+	var chosen []string
 	for len(m) != 0 {
 		for i, me := range m {
 			fmt.Printf("[%d]   %s\n", i, me.Name)
 		}
+		d := "0"
+		if len(path) > 0 {
+			if i := findEntry(m, path[0]); i >= 0 {
+				d = strconv.Itoa(i)
+			}
+			path = path[1:]
+		}
 		var menuItem int
 		err = func() error {
-			def := Goes.EnvMap["default"]
-			if def == "" {
-				def = "0"
-			}
-			mi, err := c.readline(parm, flag, fmt.Sprintf("Menu item [%s]? ", def), def)
+			mi, err := c.readline(parm, flag, fmt.Sprintf("Menu item [%s]? ", d), d)
 			if err != nil {
 				return err
 			}
@@ -193,10 +231,12 @@ func (c *Command) RunMenu(m []menuentry.Entry, parm *parms.Parms, flag *flags.Fl
 		if err != nil {
 			return
 		}
-		if menuItem >= len(m) {
+		if menuItem < 0 || menuItem >= len(m) {
 			return errors.New("Menu item out of range")
 		}
 		me := m[menuItem]
+		chosen = append(chosen, me.Name)
+		c.entry = strings.Join(chosen, ">")
 		Menuentry.Menus = Menuentry.Menus[:0]
 		err = me.RunFun(os.Stdin, os.Stdout, os.Stderr, false, false)
 		fmt.Printf("Kernel defined: %s\n", Linux.Kern)
@@ -211,6 +251,46 @@ func (c *Command) RunMenu(m []menuentry.Entry, parm *parms.Parms, flag *flags.Fl
 	return
 }
 
+// findEntry returns the index of the entry with the given index, title or
+// id; or -1 if there's no such entry.
+func findEntry(m []menuentry.Entry, s string) int {
+	if i, err := strconv.Atoi(s); err == nil {
+		if i >= 0 && i < len(m) {
+			return i
+		}
+		return -1
+	}
+	for i, me := range m {
+		if s == me.Name || (len(me.ID) > 0 && s == me.ID) {
+			return i
+		}
+	}
+	return -1
+}
+
+// loadEnv loads the grubenv of a local configuration, if any.
+func (c *Command) loadEnv() {
+	c.env = make(map[string]string)
+	if len(c.envFile) == 0 {
+		return
+	}
+	env, err := grubenv.Read(c.envFile)
+	if err == nil {
+		c.env = env
+	} else if !os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func (c *Command) saveEnv() {
+	if len(c.envFile) == 0 {
+		return
+	}
+	if err := grubenv.Write(c.envFile, c.env); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
 func (c *Command) AskKernel(parm *parms.Parms, flag *flags.Flags) (err error) {
 	if len(Linux.Kern) > 0 {
 		kexec := c.KexecCommand()
@@ -220,6 +300,9 @@ func (c *Command) AskKernel(parm *parms.Parms, flag *flags.Flags) (err error) {
 		}
 		if strings.HasPrefix(yn, "Y") ||
 			strings.HasPrefix(yn, "y") {
+			// count the attempt before the kexec that won't return
+			grubenv.Booting(c.env, c.entry)
+			c.saveEnv()
 			err := Goes.Main(kexec...)
 			return err
 		}
@@ -245,9 +328,9 @@ func (c *Command) GetRoot() string {
 		if len(r) == 5 {
 			unit, err := strconv.Atoi(r[3])
 			if err == nil {
-				devSD = "/dev/sd" + string(97+unit) + r[4]
-				devHD = "/dev/hd" + string(97+unit) + r[4]
-				devVD = "/dev/vd" + string(97+unit) + r[4]
+				devSD = "/dev/sd" + string(rune(97+unit)) + r[4]
+				devHD = "/dev/hd" + string(rune(97+unit)) + r[4]
+				devVD = "/dev/vd" + string(rune(97+unit)) + r[4]
 			}
 		}
 	}
@@ -316,6 +399,10 @@ func (c *Command) readline(parm *parms.Parms, flag *flags.Flags, prompt string,
 		}
 	}
 
+	if flag.ByName["--headless"] {
+		fmt.Println(prompt + def)
+		return def, nil
+	}
 	if flag.ByName["--daemon"] == false {
 		line := liner.NewLiner()
 		defer line.Close()
@@ -0,0 +1,81 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package load_env
+
+import (
+	"errors"
+
+	"github.com/platinasystems/go/goes"
+	"github.com/platinasystems/go/goes/cmd"
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/grubenv"
+	"github.com/platinasystems/go/internal/parms"
+)
+
+type Command struct {
+	g *goes.Goes
+	// File is the default environment block, grubenv of the
+	// configuration's directory.
+	File string
+}
+
+func (c *Command) String() string { return "load_env" }
+
+func (c *Command) Usage() string {
+	return "load_env [--file FILE] [--skip-sig] [NAME]..."
+}
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "load variables from an environment block",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: Man,
+	}
+}
+
+const Man = `Set the named, or all, variables of the environment block file.
+Signatures aren't checked, so --skip-sig is for script compatibility.`
+
+func (*Command) Kind() cmd.Kind { return cmd.DontFork }
+
+func (c *Command) Goes(g *goes.Goes) { c.g = g }
+
+func (c *Command) Main(args ...string) error {
+	_, args = flags.New(args, "--skip-sig")
+	parm, args := parms.New(args, "--file", "-f")
+	fn := c.File
+	for _, k := range []string{"--file", "-f"} {
+		if s := parm.ByName[k]; len(s) > 0 {
+			fn = s
+		}
+	}
+	if len(fn) == 0 {
+		return errors.New("FILE: missing")
+	}
+	env, err := grubenv.Read(fn)
+	if err != nil {
+		return err
+	}
+	if c.g.EnvMap == nil {
+		c.g.EnvMap = make(map[string]string)
+	}
+	if len(args) == 0 {
+		for k, v := range env {
+			c.g.EnvMap[k] = v
+		}
+		return nil
+	}
+	for _, k := range args {
+		if v, found := env[k]; found {
+			c.g.EnvMap[k] = v
+		}
+	}
+	return nil
+}
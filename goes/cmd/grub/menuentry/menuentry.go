@@ -18,7 +18,9 @@ import (
 )
 
 type Entry struct {
-	Name   string
+	Name string
+	// ID of --id, an alternative to Name for default and saved_entry
+	ID     string
 	RunFun func(stdin io.Reader, stdout io.Writer, stderr io.Writer, isFirst bool, isLast bool) error
 }
 
@@ -72,7 +74,7 @@ func (c *Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List, fun
 		return nil, nil, errors.New("menuentry: missing {")
 	}
 
-	_, args = parms.New(args, "--class", "--users", "--hotkey", "--id")
+	parm, args := parms.New(args, "--class", "--users", "--hotkey", "--id")
 	_, args = flags.New(args, "--unrestricted")
 
 	//	fmt.Printf("menuentry: name: %v, parm: %v, flags: %v, args: %v\n",
@@ -119,7 +121,7 @@ func (c *Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List, fun
 		}
 		return nil
 	}
-	e := Entry{Name: name, RunFun: runfun}
+	e := Entry{Name: name, ID: parm.ByName["--id"], RunFun: runfun}
 
 	deffun := func(stdin io.Reader, stdout io.Writer, stderr io.Writer, isFirst bool, isLast bool) error {
 		c.Menus = append(c.Menus, e)
@@ -5,31 +5,53 @@
 package recordfail
 
 import (
+	"github.com/platinasystems/go/goes"
+	"github.com/platinasystems/go/goes/cmd"
 	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/grubenv"
 )
 
-type Command struct{}
+type Command struct {
+	g *goes.Goes
+	// File is the environment block, if any, that records the failure.
+	File string
+}
 
-func (c Command) String() string { return "recordfail" }
+func (c *Command) String() string { return "recordfail" }
 
-func (c Command) Usage() string {
-	return "NOP"
+func (c *Command) Usage() string {
+	return "recordfail"
 }
 
-func (Command) Apropos() lang.Alt {
+func (*Command) Apropos() lang.Alt {
 	return lang.Alt{
-		lang.EnUS: "NOP",
+		lang.EnUS: "record a boot attempt",
 	}
 }
 
-func (Command) Man() lang.Alt {
+func (*Command) Man() lang.Alt {
 	return lang.Alt{
 		lang.EnUS: Man,
 	}
 }
 
-const Man = "NOP command for script compatibility\n"
+const Man = `Set recordfail=1 and save it to the environment block, as with the
+recordfail function of Debian's grub.cfg. The booted system is expected
+to unset it.`
+
+func (*Command) Kind() cmd.Kind { return cmd.DontFork }
+
+func (c *Command) Goes(g *goes.Goes) { c.g = g }
 
-func (Command) Main(args ...string) error {
+func (c *Command) Main(args ...string) error {
+	if c.g.EnvMap == nil {
+		c.g.EnvMap = make(map[string]string)
+	}
+	c.g.EnvMap["recordfail"] = "1"
+	if len(c.File) == 0 {
+		return nil
+	}
+	// like grub, don't fail the entry if the block isn't writable
+	grubenv.Update(c.File, map[string]string{"recordfail": "1"})
 	return nil
 }
@@ -0,0 +1,68 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package save_env
+
+import (
+	"errors"
+
+	"github.com/platinasystems/go/goes"
+	"github.com/platinasystems/go/goes/cmd"
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/grubenv"
+	"github.com/platinasystems/go/internal/parms"
+)
+
+type Command struct {
+	g *goes.Goes
+	// File is the default environment block, grubenv of the
+	// configuration's directory.
+	File string
+}
+
+func (c *Command) String() string { return "save_env" }
+
+func (c *Command) Usage() string {
+	return "save_env [--file FILE] NAME..."
+}
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "save variables to an environment block",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: Man,
+	}
+}
+
+const Man = `Save the named variables to the environment block file; those that are
+unset or empty are removed from the block.`
+
+func (*Command) Kind() cmd.Kind { return cmd.DontFork }
+
+func (c *Command) Goes(g *goes.Goes) { c.g = g }
+
+func (c *Command) Main(args ...string) error {
+	parm, args := parms.New(args, "--file", "-f")
+	fn := c.File
+	for _, k := range []string{"--file", "-f"} {
+		if s := parm.ByName[k]; len(s) > 0 {
+			fn = s
+		}
+	}
+	if len(fn) == 0 {
+		return errors.New("FILE: missing")
+	}
+	if len(args) == 0 {
+		return errors.New("NAME: missing")
+	}
+	vars := make(map[string]string)
+	for _, k := range args {
+		vars[k] = c.g.EnvMap[k]
+	}
+	return grubenv.Update(fn, vars)
+}
@@ -5,10 +5,7 @@
 package search
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"strings"
 
 	"github.com/platinasystems/go/goes"
 	"github.com/platinasystems/go/goes/cmd"
@@ -56,35 +53,27 @@ func (c Command) Main(args ...string) error {
 	if v == "" {
 		v = "root"
 	}
-	if len(args) != 1 {
-		return fmt.Errorf("Unexpected %v\n")
+	spec := ""
+	if label := parm.ByName["--label"]; len(label) > 0 {
+		spec = "LABEL=" + label
+	} else if len(args) == 1 {
+		spec = "UUID=" + args[0]
 	}
-	f, err := os.Open("/proc/partitions")
+	if len(args) > 1 || len(spec) == 0 {
+		return fmt.Errorf("Unexpected %v\n", args)
+	}
+	devs, err := partitions.BlockDevices()
 	if err != nil {
-		fmt.Printf("opening /proc/partitions: %s\n", err)
 		return err
 	}
-	defer f.Close()
-	scanner := bufio.NewScanner(f)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		if len(fields) < 4 || fields[0] == "major" {
-			continue
-		}
-		fileName := fields[3]
-		sb, err := partitions.ReadSuperBlock("/dev/" + fileName)
-		if err == nil {
-			u, _ := sb.UUID()
-			if u.String() == args[0] {
-				if c.g.EnvMap == nil {
-					c.g.EnvMap = make(map[string]string)
-				}
-				c.g.EnvMap[v] = "/dev/" + fileName
-				return nil
-			}
-		}
+	d, err := partitions.Find(devs, spec)
+	if err != nil {
+		// not found isn't an error
+		return nil
+	}
+	if c.g.EnvMap == nil {
+		c.g.EnvMap = make(map[string]string)
 	}
+	c.g.EnvMap[v] = d.Path
 	return nil
 }
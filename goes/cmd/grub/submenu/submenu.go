@@ -68,7 +68,7 @@ func (c Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List, func
 		return nil, nil, errors.New("submenu: missing {")
 	}
 
-	_, args = parms.New(args, "--class", "--users", "--hotkey", "--id")
+	parm, args := parms.New(args, "--class", "--users", "--hotkey", "--id")
 	_, args = flags.New(args, "--unrestricted")
 
 	if len(cl.Cmds) > 0 {
@@ -105,7 +105,7 @@ func (c Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List, func
 		ls = *nextls
 	}
 
-	e := menuentry.Entry{Name: name}
+	e := menuentry.Entry{Name: name, ID: parm.ByName["--id"]}
 	e.RunFun = func(stdin io.Reader, stdout io.Writer, stderr io.Writer, isFirst bool, isLast bool) error {
 		for _, runent := range funList {
 			err := runent(stdin, stdout, stderr)
@@ -0,0 +1,108 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package grub_reboot
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/grubenv"
+	"github.com/platinasystems/go/internal/parms"
+)
+
+const DefaultFile = "/boot/grub/grubenv"
+
+type Command struct{}
+
+func (Command) String() string { return "grub-reboot" }
+
+func (Command) Usage() string {
+	return "grub-reboot [-f FILE] [-default] ENTRY | -success | -show"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "set the grub entry of the next boot",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Set the next_entry of the grub environment block for the next boot
+	only. ENTRY is a menu title, --id or index; a submenu entry is
+	prefaced by its submenu and '>', e.g. "1>2".
+
+	Each boot by goes grub increments boot_count and records its
+	entry as boot_entry. After boot_limit (default 3) boots without
+	success, grub falls back to the known_good_entry. A booted system
+	marks its success with -success, which makes its boot_entry the
+	known_good_entry.
+
+OPTIONS
+	-f FILE		environment block, default /boot/grub/grubenv
+	-default	set the saved_entry of every boot, as with
+			grub-set-default, rather than just the next
+	-success	mark the last boot as successful
+	-show		print the environment block`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-default", "-success", "-show")
+	parm, args := parms.New(args, "-f")
+	fn := parm.ByName["-f"]
+	if len(fn) == 0 {
+		fn = DefaultFile
+	}
+
+	env, err := grubenv.Read(fn)
+	if os.IsNotExist(err) && !flag.ByName["-show"] {
+		env, err = make(map[string]string), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case flag.ByName["-show"]:
+		if len(args) > 0 {
+			return fmt.Errorf("%v: unexpected", args)
+		}
+		names := make([]string, 0, len(env))
+		for name := range env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s=%s\n", name, env[name])
+		}
+		return nil
+	case flag.ByName["-success"]:
+		if len(args) > 0 {
+			return fmt.Errorf("%v: unexpected", args)
+		}
+		grubenv.Booted(env)
+	default:
+		switch len(args) {
+		case 0:
+			return fmt.Errorf("ENTRY: missing")
+		case 1:
+		default:
+			return fmt.Errorf("%v: unexpected", args[1:])
+		}
+		if flag.ByName["-default"] {
+			env[grubenv.SavedEntry] = args[0]
+			delete(env, grubenv.NextEntry)
+		} else {
+			env[grubenv.NextEntry] = args[0]
+		}
+	}
+	return grubenv.Write(fn, env)
+}
@@ -14,12 +14,14 @@ import (
 	"github.com/platinasystems/go/goes/cmd"
 	"github.com/platinasystems/go/goes/cmd/platina/mk1/bootc"
 	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/flags"
 )
 
 type Command struct {
-	g      *goes.Goes
-	done   chan struct{}
-	mounts []*bootMnt
+	g        *goes.Goes
+	done     chan struct{}
+	mounts   []*bootMnt
+	headless bool
 }
 
 type bootMnt struct {
@@ -31,7 +33,7 @@ type bootMnt struct {
 func (*Command) String() string { return "grubd" }
 
 func (*Command) Usage() string {
-	return "grubd [PATH]..."
+	return "grubd [-headless] [PATH]..."
 }
 
 func (*Command) Apropos() lang.Alt {
@@ -50,6 +52,8 @@ func (c *Command) Goes(g *goes.Goes) { c.g = g }
 func (*Command) Kind() cmd.Kind { return cmd.Daemon }
 
 func (c *Command) Main(args ...string) (err error) {
+	flag, args := flags.New(args, "-headless")
+	c.headless = flag.ByName["-headless"]
 
 	mp := "/mountd"
 	if len(args) > 0 {
@@ -94,6 +98,10 @@ func (c *Command) Main(args ...string) (err error) {
 			for _, m := range c.mounts {
 				if m.hasGrub {
 					args := []string{"grub", "--daemon"}
+					if c.headless {
+						// boot the default without a console
+						args = append(args, "--headless")
+					}
 					args = append(args, m.mnt+"/grub/grub.cfg")
 					fmt.Printf("%v\n", args)
 					x := c.g.Fork(args...)
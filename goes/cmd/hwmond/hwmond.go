@@ -0,0 +1,184 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package hwmond publishes the sensors of the kernel's hwmon devices.
+package hwmond
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/platinasystems/go/goes/cmd"
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/hwmon"
+	"github.com/platinasystems/go/internal/parms"
+	"github.com/platinasystems/log"
+	"github.com/platinasystems/redis"
+	"github.com/platinasystems/redis/publisher"
+)
+
+const DefaultInterval = 5 * time.Second
+
+// Machines has the sensor map of each machine, by the redis "machine"
+// value, for hwmond without -map.
+var Machines = map[string]*hwmon.Map{
+	"platina-mk1-bmc": {
+		// imx6d publishes bmc.temperature.units.C
+		Ignore: []string{"imx_thermal_zone"},
+	},
+}
+
+type Command struct {
+	// Root of the hwmon devices, hwmon.Root if empty
+	Root string
+	// Map of the sensors, Machines[machine] if nil
+	Map *hwmon.Map
+	// Interval of updates, DefaultInterval if zero
+	Interval time.Duration
+
+	pub     *publisher.Publisher
+	last    map[string]string
+	lastErr string
+	stop    chan struct{}
+}
+
+func (*Command) String() string { return "hwmond" }
+
+func (*Command) Usage() string {
+	return "hwmond [-interval DURATION] [-map FILE] [-root DIR]"
+}
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "hwmon sensor daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Publish the temperature, voltage, fan, power and current sensors of the
+	kernel's hwmon devices.
+
+	The input, average, min, max, lcrit and crit attributes of each sensor
+	are published in degrees C, V, rpm, W and A as,
+
+	    hwmon.CHIP.SENSOR.units.UNITS
+	    hwmon.CHIP.SENSOR.ATTR.units.UNITS
+
+	CHIP is the device name, suffixed by its bus address if other devices
+	have the same name. SENSOR is the label, or the sysfs channel, e.g.
+	temp1, of unlabeled sensors. A machine map may rename, scale or ignore
+	sensors.
+
+OPTIONS
+	-interval DURATION
+		Time between updates; default 5s.
+
+	-map FILE
+		Load the JSON machine map of the sensors; see
+		internal/hwmon.Map for its format.
+
+	-root DIR
+		Read the hwmon devices of DIR rather than /sys/class/hwmon.`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-interval", "-map", "-root")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if s := parm.ByName["-interval"]; len(s) > 0 {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return fmt.Errorf("%s: invalid interval", s)
+		}
+		c.Interval = d
+	}
+	if fn := parm.ByName["-map"]; len(fn) > 0 {
+		m, err := hwmon.LoadMap(fn)
+		if err != nil {
+			return err
+		}
+		c.Map = m
+	}
+	if dir := parm.ByName["-root"]; len(dir) > 0 {
+		c.Root = dir
+	}
+	if len(c.Root) == 0 {
+		c.Root = hwmon.Root
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+
+	err := redis.IsReady()
+	if err != nil {
+		return err
+	}
+	if c.Map == nil {
+		m, _ := redis.Hget(redis.DefaultHash, "machine")
+		c.Map = Machines[m]
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	c.last = make(map[string]string)
+	c.stop = make(chan struct{})
+
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return nil
+		case <-t.C:
+			err = c.update()
+			if err != nil && err.Error() != c.lastErr {
+				log.Print("hwmond: ", err)
+			}
+			c.lastErr = ""
+			if err != nil {
+				c.lastErr = err.Error()
+			}
+		}
+	}
+}
+
+func (c *Command) Close() error {
+	close(c.stop)
+	return nil
+}
+
+// update publishes the changed sensor fields.
+func (c *Command) update() error {
+	readings, err := hwmon.Read(c.Root)
+	if err != nil {
+		return err
+	}
+	fields := c.Map.Fields(readings)
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := fields[k]
+		if v != c.last[k] {
+			if c.pub != nil {
+				c.pub.Print(k, ": ", v)
+			}
+			c.last[k] = v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package hwmond
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platinasystems/go/internal/hwmon"
+)
+
+func TestUpdate(t *testing.T) {
+	root, err := ioutil.TempDir("", "hwmond")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	dir := filepath.Join(root, "hwmon0")
+	if err = os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(fn, s string) {
+		err := ioutil.WriteFile(filepath.Join(dir, fn), []byte(s), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("name", "lm75\n")
+	write("temp1_input", "41500\n")
+	c := &Command{
+		Root: root,
+		Map: &hwmon.Map{
+			Rename: map[string]string{"lm75.temp1": "bmc.temperature"},
+		},
+		last: make(map[string]string),
+	}
+	if err = c.update(); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.last["bmc.temperature.units.C"]; v != "41.5" {
+		t.Error("temp:", v)
+	}
+	write("temp1_input", "43000\n")
+	if err = c.update(); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.last["bmc.temperature.units.C"]; v != "43" {
+		t.Error("temp:", v)
+	}
+	for m, hm := range Machines {
+		if err = hm.Check(); err != nil {
+			t.Error(m, ": ", err)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/i2cbus"
 	"github.com/platinasystems/i2c"
 )
 
@@ -71,31 +72,21 @@ func (Command) Main(args ...string) error {
 		block, args = 1, args[1:]
 	}
 	if args[0] == "STOP" {
-		sd[0] = 0
-		j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(1), 0}
-		err := DoI2cRpc()
-		if err != nil {
-			return err
-		}
-		return nil
+		return i2cbus.Pause()
 	}
 	if args[0] == "START" {
-		sd[0] = 0
-		j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(0), 0}
-		err := DoI2cRpc()
-		if err != nil {
-			return err
-		}
-		return nil
+		return i2cbus.Resume()
 	}
 	if args[0] == "READ" {
-		sd[0] = 0
-		j[0] = I{true, i2c.Write, 0, 0, sd, int(0x98), int(0), 0}
-		err := DoI2cRpc()
+		paused, err := i2cbus.Paused()
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Stop polling bit is 0x%x\n", s[0].D[0])
+		stop := 0
+		if paused {
+			stop = 1
+		}
+		fmt.Printf("Stop polling bit is 0x%x\n", stop)
 		return nil
 	}
 
@@ -2,13 +2,16 @@
 // Use of this source code is governed by the GPL-2 license described in the
 // LICENSE file.
 
-// Package ucd9090 provides access to the UCD9090 Power Sequencer/Monitor chip
+// Package i2cd serves the i2c transactions of the sensor daemons with a
+// worker queue of each bus.
 package i2cd
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 	"net/rpc"
+	"sort"
 	"sync"
 	"syscall"
 	"time"
@@ -16,21 +19,45 @@ import (
 	"github.com/platinasystems/go/goes/cmd"
 	"github.com/platinasystems/go/goes/cmd/iocmd"
 	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/go/internal/parms"
 	"github.com/platinasystems/gpio"
 	"github.com/platinasystems/i2c"
 	"github.com/platinasystems/log"
 	"github.com/platinasystems/redis"
+	"github.com/platinasystems/redis/publisher"
 )
 
+// Machines has the mux topology of each machine, by the redis "machine"
+// value, for i2cd without -topology.
+var Machines = map[string]*i2cbus.Topology{
+	"platina-mk1-bmc": {
+		Muxes: []i2cbus.Mux{
+			{Name: "main", Bus: 0, Addr: 0x76},
+			{Name: "fantray", Bus: 1, Addr: 0x72},
+		},
+	},
+}
+
 type Command struct {
 	Gpio func()
-	gpio sync.Once
-	done chan struct{}
+	// Backend of requests, i2cbus.Default if nil
+	Backend i2cbus.Backend
+	// Topology of the muxes, Machines[machine] if nil
+	Topology *i2cbus.Topology
+	// Timeout of ops without their own, DefaultTimeout if zero
+	Timeout time.Duration
+
+	gpio   sync.Once
+	server *server
+	pub    *publisher.Publisher
+	last   map[dev]Counters
+	done   chan struct{}
 }
 
 func (*Command) String() string { return "i2cd" }
 
-func (*Command) Usage() string { return "i2cd" }
+func (*Command) Usage() string { return "i2cd [-sim FILE] [-topology FILE]" }
 
 func (*Command) Apropos() lang.Alt {
 	return lang.Alt{
@@ -38,6 +65,34 @@ func (*Command) Apropos() lang.Alt {
 	}
 }
 
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Serve i2c transactions of the sensor daemons.
+
+	Each bus has a worker queue so transactions of independent buses
+	proceed concurrently. A transaction holds all of its buses, including
+	those of its muxes, so no other op may come between a mux channel
+	select and the ops behind it. Each op has a timeout.
+
+	I2cReq.Pause and I2cReq.Resume RPCs advise the daemons to skip their
+	updates, e.g. during a firmware upgrade.
+
+	The error and timeout counts of each device are published to redis as
+	i2cd.BUS.ADDR.errors and i2cd.BUS.ADDR.timeouts.
+
+OPTIONS
+	-sim FILE
+		Simulate the devices of the script file rather than use
+		/dev/i2c-N; see internal/i2cbus.Sim for its format.
+
+	-topology FILE
+		Load the JSON machine description of the muxes; see
+		internal/i2cbus.Topology for its format.`,
+	}
+}
+
 func (c *Command) Close() error {
 	close(c.done)
 	return nil
@@ -45,13 +100,42 @@ func (c *Command) Close() error {
 
 func (*Command) Kind() cmd.Kind { return cmd.Daemon }
 
-func (c *Command) Main(...string) error {
+func (c *Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-sim", "-topology")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if fn := parm.ByName["-sim"]; len(fn) > 0 {
+		sim, err := i2cbus.LoadSim(fn)
+		if err != nil {
+			return err
+		}
+		c.Backend = sim
+	}
+	if fn := parm.ByName["-topology"]; len(fn) > 0 {
+		t, err := i2cbus.LoadTopology(fn)
+		if err != nil {
+			return err
+		}
+		c.Topology = t
+	}
+	if c.Topology == nil {
+		m, _ := redis.Hget(redis.DefaultHash, "machine")
+		c.Topology = Machines[m]
+	}
+
 	var si syscall.Sysinfo_t
 	err := syscall.Sysinfo(&si)
 	if err != nil {
 		return err
 	}
 
+	c.init()
+	if c.pub, err = publisher.New(); err != nil {
+		log.Print("publisher: ", err)
+		c.pub = nil
+	}
+
 	c.done = make(chan struct{})
 	i2cReq := &I2cReq{c}
 	rpc.Register(i2cReq)
@@ -63,18 +147,61 @@ func (c *Command) Main(...string) error {
 	log.Print("listen OKAY")
 	go http.Serve(l, nil)
 
-	t := time.NewTicker(20 * time.Millisecond)
+	t := time.NewTicker(5 * time.Second)
 	defer t.Stop()
 	for {
 		select {
 		case <-c.done:
 			return nil
 		case <-t.C:
+			c.publish()
 		}
 	}
 	return nil
 }
 
+// init the server of the command's backend and topology.
+func (c *Command) init() {
+	backend := c.Backend
+	if backend == nil {
+		backend = i2cbus.Default
+	}
+	c.server = newServer(backend, c.Topology, c.Timeout)
+	if c.Backend == nil {
+		c.server.recover = c.resetMux
+	}
+	c.last = make(map[dev]Counters)
+}
+
+// publish the changed error counters of each device.
+func (c *Command) publish() {
+	if c.pub == nil {
+		return
+	}
+	counters := c.server.snapshot()
+	devs := make([]dev, 0, len(counters))
+	for k := range counters {
+		devs = append(devs, k)
+	}
+	sort.Slice(devs, func(i, j int) bool {
+		if devs[i].bus != devs[j].bus {
+			return devs[i].bus < devs[j].bus
+		}
+		return devs[i].addr < devs[j].addr
+	})
+	for _, k := range devs {
+		v, last := counters[k], c.last[k]
+		prefix := fmt.Sprintf("i2cd.%d.%#x.", k.bus, k.addr)
+		if v.Errors != last.Errors {
+			c.pub.Print(prefix, "errors: ", v.Errors)
+		}
+		if v.Timeouts != last.Timeouts {
+			c.pub.Print(prefix, "timeouts: ", v.Timeouts)
+		}
+		c.last[k] = v
+	}
+}
+
 const MAXOPS = 30
 
 type I struct {
@@ -96,103 +223,113 @@ type I2cReq struct {
 	c *Command
 }
 
-var b = [34]byte{0}
-var i = I{false, i2c.RW(0), 0, 0, b, 0, 0, 0}
-var j [MAXOPS]I
-var r = R{b, nil}
-var s [MAXOPS]R
-var x int
-var stopped byte = 0
-var mutex = &sync.Mutex{}
+// Do the transaction.
+func (t *I2cReq) Do(tx *i2cbus.Tx, reply *i2cbus.TxReply) error {
+	data, err := t.c.server.do(tx)
+	if err != nil {
+		log.Print("i2c transaction: ", err)
+		return err
+	}
+	reply.Data = data
+	return nil
+}
 
-func (t *I2cReq) ReadWrite(g *[MAXOPS]I, f *[MAXOPS]R) error {
-	mutex.Lock()
-	defer mutex.Unlock()
+// Pause the daemons after the transactions in flight.
+func (t *I2cReq) Pause(_ int, _ *int) error {
+	t.c.server.pause()
+	return nil
+}
+
+func (t *I2cReq) Resume(_ int, _ *int) error {
+	t.c.server.resume()
+	return nil
+}
+
+func (t *I2cReq) Paused(_ int, paused *bool) error {
+	*paused = t.c.server.isPaused()
+	return nil
+}
 
-	var bus i2c.Bus
-	var data i2c.SMBusData
-	if g[0].Bus == 0x99 {
-		stopped = byte(g[0].Addr)
+// ReadWrite the in-use requests of the batch as a transaction. This is the
+// legacy interface where bus 0x99 pauses (Addr 1) or resumes (Addr 0) and
+// bus 0x98 reads the paused flag.
+func (t *I2cReq) ReadWrite(g *[MAXOPS]I, f *[MAXOPS]R) error {
+	switch g[0].Bus {
+	case 0x99:
+		if g[0].Addr != 0 {
+			t.c.server.pause()
+		} else {
+			t.c.server.resume()
+		}
 		return nil
-	}
-	if g[0].Bus == 0x98 {
-		f[0].D[0] = stopped
+	case 0x98:
+		f[0].D[0] = 0
+		if t.c.server.isPaused() {
+			f[0].D[0] = 1
+		}
 		return nil
 	}
-	for x := 0; x < MAXOPS; x++ {
-		if g[x].InUse == true {
-			err := bus.Open(g[x].Bus)
-			if err != nil {
-				log.Print("Error opening I2C bus")
-				return err
-			}
-			defer bus.Close()
-
-			err = bus.ForceSlaveAddress(g[x].Addr)
-			if err != nil {
-				log.Print("ERR2")
-				log.Print("Error setting I2C slave address")
-				return err
-			}
-			data[0] = g[x].Data[0]
-			data[1] = g[x].Data[1]
-			data[2] = g[x].Data[2]
-			data[3] = g[x].Data[3]
-			err = bus.Do(g[x].RW, g[x].RegOffset, g[x].BusSize, &data)
-			if err != nil {
-				for y := 0; y < x; y++ {
-					log.Printf("I2C R/W before Error: bus 0x%x addr 0x%x offset 0x%x data 0x%x RW %d BusSize %d delay %d", g[y].Bus, g[y].Addr, g[y].RegOffset, g[y].Data[0], g[y].RW, g[y].BusSize, g[y].Delay)
-				}
-				log.Printf("Error doing I2C R/W: bus 0x%x addr 0x%x offset 0x%x data 0x%x RW %d BusSize %d delay %d", g[x].Bus, g[x].Addr, g[x].RegOffset, data[0], g[x].RW, g[x].BusSize, g[x].Delay)
-				m, _ := redis.Hget(redis.DefaultHash, "machine")
-
-				switch m {
-				case "platina-mk1":
-					d, err := iocmd.Io_reg_rd(0x603, 1)
-					if err == nil {
-						iocmd.Io_reg_wr(0x603, uint64(d[0]&0xb0), 0x1)
-						time.Sleep(10 * time.Microsecond)
-						iocmd.Io_reg_wr(0x603, uint64(d[0]|0x40), 0x1)
-					}
-				case "platina-mk1-bmc":
-					t.c.gpio.Do(t.c.Gpio)
-					pin, found := gpio.Pins["FRU_I2C_MUX_RST_L"]
-					if found {
-						pin.SetValue(false)
-						time.Sleep(10 * time.Microsecond)
-						pin.SetValue(true)
-					}
-
-					pin, found = gpio.Pins["MAIN_I2C_MUX_RST_L"]
-					if found {
-						pin.SetValue(false)
-						time.Sleep(10 * time.Microsecond)
-						pin.SetValue(true)
-					}
-				default:
-				}
-				return err
-			}
-			f[x].D[0] = data[0]
-			f[x].D[1] = data[1]
-			if g[x].BusSize == i2c.I2CBlockData {
-				for y := 2; y < 34; y++ {
-					f[x].D[y] = data[y]
-				}
-			}
-			bus.Close()
-			if g[x].Delay > 0 {
-				time.Sleep(time.Duration(g[x].Delay) * time.Millisecond)
-			}
+	var tx i2cbus.Tx
+	var index []int
+	for x := range g {
+		if !g[x].InUse {
+			continue
+		}
+		op := i2cbus.Op{
+			RW:    g[x].RW,
+			Cmd:   g[x].RegOffset,
+			Size:  g[x].BusSize,
+			Bus:   g[x].Bus,
+			Addr:  g[x].Addr,
+			Delay: time.Duration(g[x].Delay) * time.Millisecond,
+		}
+		copy(op.Data[:], g[x].Data[:])
+		tx.Ops = append(tx.Ops, op)
+		index = append(index, x)
+	}
+	data, err := t.c.server.do(&tx)
+	if err != nil {
+		log.Print("Error doing I2C R/W: ", err)
+		return err
+	}
+	for y, x := range index {
+		f[x].D[0] = data[y][0]
+		f[x].D[1] = data[y][1]
+		switch g[x].BusSize {
+		case i2c.BlockData, i2c.I2CBlockData:
+			copy(f[x].D[2:], data[y][2:])
 		}
 	}
 	return nil
 }
 
-func clearJS() {
-	x = 0
-	for k := 0; k < MAXOPS; k++ {
-		j[k] = i
-		s[k] = r
+// resetMux of the machine after a failed request.
+func (c *Command) resetMux() {
+	m, _ := redis.Hget(redis.DefaultHash, "machine")
+
+	switch m {
+	case "platina-mk1":
+		d, err := iocmd.Io_reg_rd(0x603, 1)
+		if err == nil {
+			iocmd.Io_reg_wr(0x603, uint64(d[0]&0xb0), 0x1)
+			time.Sleep(10 * time.Microsecond)
+			iocmd.Io_reg_wr(0x603, uint64(d[0]|0x40), 0x1)
+		}
+	case "platina-mk1-bmc":
+		c.gpio.Do(c.Gpio)
+		pin, found := gpio.Pins["FRU_I2C_MUX_RST_L"]
+		if found {
+			pin.SetValue(false)
+			time.Sleep(10 * time.Microsecond)
+			pin.SetValue(true)
+		}
+
+		pin, found = gpio.Pins["MAIN_I2C_MUX_RST_L"]
+		if found {
+			pin.SetValue(false)
+			time.Sleep(10 * time.Microsecond)
+			pin.SetValue(true)
+		}
+	default:
 	}
 }
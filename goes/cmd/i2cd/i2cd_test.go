@@ -0,0 +1,269 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package i2cd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/i2c"
+)
+
+func newCommand(t *testing.T, b i2cbus.Backend) *Command {
+	c := &Command{
+		Backend: b,
+		Topology: &i2cbus.Topology{
+			Muxes: []i2cbus.Mux{
+				{Name: "main", Bus: 0, Addr: 0x76},
+			},
+		},
+		Timeout: 50 * time.Millisecond,
+	}
+	c.init()
+	return c
+}
+
+func TestReadWrite(t *testing.T) {
+	var g [MAXOPS]I
+	var f [MAXOPS]R
+
+	sim := i2cbus.NewSim()
+	err := sim.Load(strings.NewReader(`
+device 0 0x76
+device 0 0x58
+word 0x79 0x0843
+block 0x99 "FSP"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &I2cReq{newCommand(t, sim)}
+
+	g[0] = I{InUse: true, RW: i2c.Write, BusSize: i2c.ByteData,
+		Data: [34]byte{1}, Bus: 0, Addr: 0x76}
+	g[1] = I{InUse: true, RW: i2c.Read, RegOffset: 0x79,
+		BusSize: i2c.WordData, Bus: 0, Addr: 0x58}
+	g[3] = I{InUse: true, RW: i2c.Read, RegOffset: 0x99,
+		BusSize: i2c.I2CBlockData, Data: [34]byte{15}, Bus: 0,
+		Addr: 0x58}
+	if err = req.ReadWrite(&g, &f); err != nil {
+		t.Fatal(err)
+	}
+	if f[1].D[0] != 0x43 || f[1].D[1] != 0x08 {
+		t.Error("word:", f[1].D[:2])
+	}
+	if f[3].D[1] != 3 || string(f[3].D[2:5]) != "FSP" {
+		t.Errorf("block: %q", f[3].D[:5])
+	}
+
+	sim.Device(0, 0x58).Inject(i2cbus.Nak, 1)
+	if err = req.ReadWrite(&g, &f); err == nil {
+		t.Error("NAK didn't fail")
+	}
+	if c := req.c.server.snapshot()[dev{0, 0x58}]; c.Errors != 1 {
+		t.Error("errors:", c.Errors)
+	}
+
+	var stop [MAXOPS]I
+	stop[0] = I{InUse: true, Bus: 0x99, Addr: 1}
+	if err = req.ReadWrite(&stop, &f); err != nil {
+		t.Fatal(err)
+	}
+	stop[0] = I{InUse: true, Bus: 0x98}
+	if err = req.ReadWrite(&stop, &f); err != nil || f[0].D[0] != 1 {
+		t.Error("stopped:", f[0].D[0], err)
+	}
+	var paused bool
+	if req.Paused(0, &paused); !paused {
+		t.Error("not paused")
+	}
+	req.Resume(0, nil)
+	if req.Paused(0, &paused); paused {
+		t.Error("still paused")
+	}
+}
+
+// muxed models a PCA9548 at 0.0x76 with a device on each channel whose
+// address is 0x50 plus the channel number. It fails ops of a device whose
+// channel isn't the one selected.
+type muxed struct {
+	mutex    sync.Mutex
+	selected byte
+}
+
+func (m *muxed) Do(bus, addr int, rw i2c.RW, cmd uint8, size i2c.SMBusSize,
+	data *i2c.SMBusData) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if bus == 0 && addr == 0x76 {
+		m.selected = data[0]
+		return nil
+	}
+	if m.selected != 1<<uint(addr-0x50) {
+		return fmt.Errorf("%#x: channel %#x selected", addr,
+			m.selected)
+	}
+	data[0] = byte(addr)
+	// give others a chance to come between select and op
+	m.mutex.Unlock()
+	time.Sleep(100 * time.Microsecond)
+	m.mutex.Lock()
+	return nil
+}
+
+func TestMux(t *testing.T) {
+	c := newCommand(t, &muxed{})
+	var wg sync.WaitGroup
+	for ch := 0; ch < 8; ch++ {
+		wg.Add(1)
+		go func(ch int) {
+			defer wg.Done()
+			tx := &i2cbus.Tx{
+				Ops: []i2cbus.Op{
+					{
+						RW:     i2c.Read,
+						Size:   i2c.ByteData,
+						Bus:    0,
+						Addr:   0x50 + ch,
+						Mux:    "main",
+						Select: 1 << uint(ch),
+					},
+				},
+				Deselect: true,
+			}
+			for n := 0; n < 20; n++ {
+				var reply i2cbus.TxReply
+				err := (&I2cReq{c}).Do(tx, &reply)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if reply.Data[0][0] != byte(0x50+ch) {
+					t.Error("channel", ch, reply.Data[0][0])
+				}
+			}
+		}(ch)
+	}
+	wg.Wait()
+
+	_, err := c.server.do(&i2cbus.Tx{
+		Ops: []i2cbus.Op{{Bus: 0, Addr: 0x50, Mux: "fantray"}},
+	})
+	if err == nil {
+		t.Error("unknown mux")
+	}
+}
+
+// blocking blocks ops of bus 0 until released.
+type blocking struct {
+	release chan struct{}
+}
+
+func (b *blocking) Do(bus, addr int, rw i2c.RW, cmd uint8,
+	size i2c.SMBusSize, data *i2c.SMBusData) error {
+	if bus == 0 {
+		<-b.release
+	}
+	data[0] = byte(bus)
+	return nil
+}
+
+func TestBuses(t *testing.T) {
+	b := &blocking{make(chan struct{})}
+	c := newCommand(t, b)
+	c.server.timeout = time.Minute
+
+	done := make(chan error)
+	go func() {
+		_, err := c.server.do(&i2cbus.Tx{
+			Ops: []i2cbus.Op{{RW: i2c.Read, Bus: 0, Addr: 0x50}},
+		})
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	data, err := c.server.do(&i2cbus.Tx{
+		Ops: []i2cbus.Op{{RW: i2c.Read, Bus: 1, Addr: 0x50}},
+	})
+	if err != nil || data[0][0] != 1 {
+		t.Error("bus 1 behind bus 0:", err)
+	}
+	select {
+	case <-done:
+		t.Fatal("bus 0 didn't block")
+	default:
+	}
+	close(b.release)
+	if err = <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	b := &blocking{make(chan struct{})}
+	c := newCommand(t, b)
+	tx := &i2cbus.Tx{
+		Ops: []i2cbus.Op{
+			{
+				RW:      i2c.Read,
+				Bus:     0,
+				Addr:    0x50,
+				Timeout: 10 * time.Millisecond,
+			},
+		},
+	}
+	if _, err := c.server.do(tx); err == nil ||
+		!strings.Contains(err.Error(), ErrTimeout.Error()) {
+		t.Fatal("blocked op:", err)
+	}
+	// the next op waits for the one abandoned
+	if _, err := c.server.do(tx); err == nil {
+		t.Fatal("op behind the abandoned one")
+	}
+	close(b.release)
+	if _, err := c.server.do(tx); err != nil {
+		t.Fatal("after release:", err)
+	}
+	counters := c.server.snapshot()[dev{0, 0x50}]
+	if counters.Ops != 3 || counters.Errors != 2 ||
+		counters.Timeouts != 2 {
+		t.Errorf("counters: %+v", counters)
+	}
+}
+
+func TestPause(t *testing.T) {
+	b := &blocking{make(chan struct{})}
+	c := newCommand(t, b)
+	c.server.timeout = time.Minute
+
+	done := make(chan error)
+	go func() {
+		_, err := c.server.do(&i2cbus.Tx{
+			Ops: []i2cbus.Op{{RW: i2c.Read, Bus: 0, Addr: 0x50}},
+		})
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	paused := make(chan struct{})
+	go func() {
+		(&I2cReq{c}).Pause(0, nil)
+		close(paused)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-paused:
+		t.Fatal("paused before the transaction in flight")
+	default:
+	}
+	close(b.release)
+	<-done
+	<-paused
+	if !c.server.isPaused() {
+		t.Error("not paused")
+	}
+}
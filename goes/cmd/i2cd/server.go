@@ -0,0 +1,285 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package i2cd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/i2c"
+)
+
+// DefaultTimeout of an op without its own.
+const DefaultTimeout = time.Second
+
+var ErrTimeout = errors.New("i2c timeout")
+
+// Counters of a device's ops.
+type Counters struct {
+	Ops      uint64
+	Errors   uint64
+	Timeouts uint64
+}
+
+type dev struct {
+	bus, addr int
+}
+
+// server queues transactions to a worker of each bus. A transaction holds
+// the workers of all of its buses, taken in ascending order, so those of
+// other buses proceed concurrently and no other op may come between its mux
+// select and device ops.
+type server struct {
+	backend  i2cbus.Backend
+	topology *i2cbus.Topology
+	timeout  time.Duration
+	// recover, if not nil, is called with the held buses after an error
+	recover func()
+
+	mutex    sync.Mutex
+	workers  map[int]*worker
+	paused   bool
+	counters map[dev]*Counters
+}
+
+type worker struct {
+	jobs chan func()
+	// inflight has the result of an op abandoned at its timeout
+	inflight chan error
+}
+
+func newServer(backend i2cbus.Backend, topology *i2cbus.Topology,
+	timeout time.Duration) *server {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &server{
+		backend:  backend,
+		topology: topology,
+		timeout:  timeout,
+		workers:  make(map[int]*worker),
+		counters: make(map[dev]*Counters),
+	}
+}
+
+func (s *server) worker(bus int) *worker {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	w, found := s.workers[bus]
+	if !found {
+		w = &worker{jobs: make(chan func(), 16)}
+		go w.run()
+		s.workers[bus] = w
+	}
+	return w
+}
+
+func (w *worker) run() {
+	for job := range w.jobs {
+		job()
+	}
+}
+
+// hold the worker's bus until the release is closed.
+func (w *worker) hold(release <-chan struct{}) {
+	held := make(chan struct{})
+	w.jobs <- func() {
+		close(held)
+		<-release
+	}
+	<-held
+}
+
+// holdBuses in ascending order, returning their workers by bus.
+func (s *server) holdBuses(buses []int,
+	release <-chan struct{}) map[int]*worker {
+	sort.Ints(buses)
+	ws := make(map[int]*worker, len(buses))
+	for _, bus := range buses {
+		if _, found := ws[bus]; !found {
+			w := s.worker(bus)
+			w.hold(release)
+			ws[bus] = w
+		}
+	}
+	return ws
+}
+
+// do the transaction, returning the data of each op.
+func (s *server) do(tx *i2cbus.Tx) ([]i2c.SMBusData, error) {
+	if len(tx.Ops) == 0 {
+		return nil, nil
+	}
+	muxes := make([]*i2cbus.Mux, len(tx.Ops))
+	var buses []int
+	for i, op := range tx.Ops {
+		if len(op.Mux) > 0 {
+			m, found := s.topology.Mux(op.Mux)
+			if !found {
+				return nil, fmt.Errorf("%s: unknown mux", op.Mux)
+			}
+			muxes[i] = m
+			buses = append(buses, m.Bus)
+		}
+		buses = append(buses, op.Bus)
+	}
+	release := make(chan struct{})
+	defer close(release)
+	ws := s.holdBuses(buses, release)
+
+	data := make([]i2c.SMBusData, len(tx.Ops))
+	var selected []*i2cbus.Mux
+	err := func() error {
+		for i := range tx.Ops {
+			op := &tx.Ops[i]
+			if m := muxes[i]; m != nil {
+				err := s.selectMux(ws[m.Bus], m, op.Select,
+					op.Timeout)
+				if err != nil {
+					return fmt.Errorf("op %d: mux %s: %v", i,
+						m.Name, err)
+				}
+				selected = appendMux(selected, m)
+			}
+			data[i] = op.Data
+			err := s.op(ws[op.Bus], op.Bus, op.Addr, op.RW, op.Cmd,
+				op.Size, &data[i], op.Timeout)
+			if err != nil {
+				return fmt.Errorf("op %d: %d.%#x: %v", i, op.Bus,
+					op.Addr, err)
+			}
+			if op.Delay > 0 {
+				time.Sleep(op.Delay)
+			}
+		}
+		return nil
+	}()
+	if err != nil && s.recover != nil {
+		s.recover()
+	}
+	if tx.Deselect {
+		for _, m := range selected {
+			derr := s.selectMux(ws[m.Bus], m, 0, 0)
+			if err == nil && derr != nil {
+				err = fmt.Errorf("mux %s: %v", m.Name, derr)
+			}
+		}
+	}
+	return data, err
+}
+
+func appendMux(muxes []*i2cbus.Mux, m *i2cbus.Mux) []*i2cbus.Mux {
+	for _, x := range muxes {
+		if x == m {
+			return muxes
+		}
+	}
+	return append(muxes, m)
+}
+
+func (s *server) selectMux(w *worker, m *i2cbus.Mux, v byte,
+	timeout time.Duration) error {
+	var data i2c.SMBusData
+	data[0] = v
+	return s.op(w, m.Bus, m.Addr, i2c.Write, 0, i2c.ByteData, &data,
+		timeout)
+}
+
+// op of the held bus. At its timeout, the op is abandoned and the next op
+// of the bus first waits for it.
+func (s *server) op(w *worker, bus, addr int, rw i2c.RW, cmd uint8,
+	size i2c.SMBusSize, data *i2c.SMBusData, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = s.timeout
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	err := func() error {
+		if w.inflight != nil {
+			select {
+			case <-w.inflight:
+				w.inflight = nil
+			case <-t.C:
+				return ErrTimeout
+			}
+		}
+		result := *data
+		done := make(chan error, 1)
+		go func() {
+			done <- s.backend.Do(bus, addr, rw, cmd, size, &result)
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				*data = result
+			}
+			return err
+		case <-t.C:
+			w.inflight = done
+			return ErrTimeout
+		}
+	}()
+	s.count(bus, addr, err)
+	return err
+}
+
+func (s *server) count(bus, addr int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	k := dev{bus, addr}
+	c, found := s.counters[k]
+	if !found {
+		c = new(Counters)
+		s.counters[k] = c
+	}
+	c.Ops++
+	if err != nil {
+		c.Errors++
+		if err == ErrTimeout {
+			c.Timeouts++
+		}
+	}
+}
+
+// snapshot returns a copy of each device's counters.
+func (s *server) snapshot() map[dev]Counters {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m := make(map[dev]Counters, len(s.counters))
+	for k, c := range s.counters {
+		m[k] = *c
+	}
+	return m
+}
+
+// pause after the transactions in flight.
+func (s *server) pause() {
+	s.mutex.Lock()
+	s.paused = true
+	buses := make([]int, 0, len(s.workers))
+	for bus := range s.workers {
+		buses = append(buses, bus)
+	}
+	s.mutex.Unlock()
+	release := make(chan struct{})
+	s.holdBuses(buses, release)
+	close(release)
+}
+
+func (s *server) resume() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.paused = false
+}
+
+func (s *server) isPaused() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.paused
+}
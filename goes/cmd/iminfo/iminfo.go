@@ -7,26 +7,51 @@ package iminfo
 import (
 	"fmt"
 	"io/ioutil"
+	"sort"
+	"strings"
 
 	"github.com/platinasystems/go/goes/lang"
 	"github.com/platinasystems/go/internal/fit"
+	"github.com/platinasystems/go/internal/parms"
 )
 
 type Command struct{}
 
 func (Command) String() string { return "iminfo" }
 
-func (Command) Usage() string { return "iminfo" }
+func (Command) Usage() string {
+	return "iminfo [-keyring FILE] [-x IMAGE [-o FILE]] FIT"
+}
 
 func (Command) Apropos() lang.Alt {
 	return lang.Alt{
-		lang.EnUS: "FIXME",
+		lang.EnUS: "print or extract Flattened Image Tree contents",
 	}
 }
 
-func (Command) Main(args ...string) error {
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Print the images and configurations of a Flattened Image Tree (FIT)
+	with the status of their hashes and signatures. Unlike kexec, iminfo
+	lists and extracts images whose hashes or signatures don't verify.
+
+OPTIONS
+	-keyring FILE
+		PEM encoded public keys, in addition to those compiled in, to
+		verify the signed configurations
+	-x IMAGE
+		extract the named image data
+	-o FILE
+		extracted image file, default is the IMAGE name`,
+	}
+}
+
+func (Command) Main(args ...string) (err error) {
+	parm, args := parms.New(args, "-keyring", "-x", "-o")
 	if n := len(args); n == 0 {
-		return fmt.Errorf("DESTINATION: missing")
+		return fmt.Errorf("FIT: missing")
 	} else if n > 1 {
 		return fmt.Errorf("%v: unexpected", args[1:])
 	}
@@ -36,29 +61,114 @@ func (Command) Main(args ...string) error {
 		return err
 	}
 
-	fit := fit.Parse(b)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", itb, r)
+		}
+	}()
+	f := fit.ParseUnverified(b)
+
+	keys := fit.DefaultKeyring
+	if fn := parm.ByName["-keyring"]; len(fn) > 0 {
+		keys, err = fit.ReadKeyring(fn)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, fit.DefaultKeyring...)
+	}
+	f.Verify(keys)
+
+	if name := parm.ByName["-x"]; len(name) > 0 {
+		image, found := f.Images[name]
+		if !found {
+			return fmt.Errorf("%s: image not found", name)
+		}
+		fn := parm.ByName["-o"]
+		if len(fn) == 0 {
+			fn = name
+		}
+		return ioutil.WriteFile(fn, image.Data, 0644)
+	}
 
-	fmt.Printf("Description = %s\nAddressCells = %d\nTimeStamp = %s\n", fit.Description, fit.AddressCells, fit.TimeStamp)
+	fmt.Printf("Description = %s\nAddressCells = %d\nTimeStamp = %s\n", f.Description, f.AddressCells, f.TimeStamp)
+	fmt.Printf("DefaultConfig = %s\n", f.DefaultConfig)
 
-	for name, cfg := range fit.Configs {
+	names := make([]string, 0, len(f.Images))
+	for name := range f.Images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("Images:")
+	for _, name := range names {
+		listImage(f, f.Images[name])
+	}
+
+	names = names[:0]
+	for name := range f.Configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cfg := f.Configs[name]
 		fmt.Printf("Configuration %s:%s\n", name, (*cfg).Description)
-		listImages(cfg.ImageList)
+		for _, image := range cfg.ImageList {
+			fmt.Printf("  %s: %s\n", image.Type, image.Name)
+		}
+		switch {
+		case cfg.Verified:
+			fmt.Printf("  Signature verified with key %q\n",
+				cfg.KeyName)
+		case cfg.Signed:
+			fmt.Printf("  Signature not verified: %v\n",
+				cfg.SignatureErr)
+		default:
+			fmt.Printf("  Unsigned\n")
+		}
 	}
 	return nil
 }
 
-func listImages(imageList []*fit.Image) {
-	for _, image := range imageList {
-		fmt.Printf(`  %s:
+func listImage(f *fit.Fit, image *fit.Image) {
+	hashes := "none"
+	if image.HashErr != nil {
+		hashes = image.HashErr.Error()
+	} else if h := image.VerifiedHashes(); len(h) > 0 {
+		hashes = strings.Join(h, ", ") + " verified"
+	}
+	// the first signed configuration of the image that verified
+	names := make([]string, 0, len(f.Configs))
+	for name := range f.Configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	signature := "unsigned"
+configs:
+	for _, name := range names {
+		cfg := f.Configs[name]
+		for _, i := range cfg.ImageList {
+			if i != image || !cfg.Signed {
+				continue
+			}
+			if cfg.Verified {
+				signature = fmt.Sprintf("verified by %s with key %q",
+					name, cfg.KeyName)
+				break configs
+			}
+			signature = "not verified"
+		}
+	}
+	fmt.Printf(`  %s:
     Description=%s
     Type=%s
     Arch=%s
     OS=%s
     Compression=%s
     LoadAddr=%x
+    Size=%d
+    Hashes=%s
+    Signature=%s
 `,
-			image.Name, image.Description, image.Type,
-			image.Arch, image.Os, image.Compression,
-			image.LoadAddr)
-	}
+		image.Name, image.Description, image.Type,
+		image.Arch, image.Os, image.Compression,
+		image.LoadAddr, len(image.Data), hashes, signature)
 }
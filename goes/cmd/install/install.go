@@ -240,6 +240,9 @@ func install_default() error {
 # See also, $(goes man redisd)
 
 #ARGS=""
+
+# ZTP: set to "no" to disable zero touch provisioning by dhcpcd
+#ZTP="no"
 `[1:])
 	return err
 }
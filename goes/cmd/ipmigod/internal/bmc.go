@@ -8,8 +8,14 @@ package internal
 import (
 	"encoding/binary"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"time"
+
+	"github.com/platinasystems/go/internal/eeprom"
+	"github.com/platinasystems/go/internal/fru"
 )
 
 // Used for artificial qemu environment
@@ -97,6 +103,7 @@ type mcT struct {
 	sel            selT
 	mainSdrs       sdrsT
 	sensors        [4][255]*sensorT
+	fru            []uint8 // FRU device 0 inventory
 }
 
 var mc mcT
@@ -127,6 +134,8 @@ func bmcInit() {
 	mc.sel.maxCount = 1000
 	mc.sel.nextEntry = 1
 
+	fruInit()
+
 	// Initially this is a simulated set of sensors.
 	// In production, a similar scheme could be used or
 	// perhaps a more dynamic scheme where the sysclass fs is
@@ -225,6 +234,46 @@ func bmcInit() {
 	}()
 }
 
+// FruFile persists the FRU inventory written by Write FRU Data commands.
+// Until then, the inventory is generated from the ONIE TlvInfo of the
+// OnieEeprom.
+var (
+	FruFile    = "/var/lib/ipmigod/fru.bin"
+	OnieEeprom = eeprom.Device{BusIndex: 0, BusAddress: 0x55}
+)
+
+const FRU_INVENTORY_SIZE = 1024
+
+func fruInit() {
+	b, err := ioutil.ReadFile(FruFile)
+	if os.IsNotExist(err) {
+		var info *eeprom.TlvInfo
+		d := OnieEeprom
+		if info, err = d.ReadTlvInfo(); err == nil {
+			b, err = fru.FromTlvInfo(info).Bytes()
+		}
+	}
+	if err != nil {
+		fmt.Println("fru:", err)
+		return
+	}
+
+	// Pad as erased EEPROM to leave room for writes
+	mc.fru = make([]uint8, FRU_INVENTORY_SIZE)
+	for i := copy(mc.fru, b); i < len(mc.fru); i++ {
+		mc.fru[i] = 0xff
+	}
+	mc.deviceSupport |= IPMI_DEVID_FRU_INVENTORY_DEV
+}
+
+func fruSave() error {
+	err := os.MkdirAll(filepath.Dir(FruFile), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(FruFile, mc.fru, 0644)
+	}
+	return err
+}
+
 func sensorAdd(bmc uint8, lun uint8, num uint8, stype uint8, code uint8) {
 	sensor := new(sensorT)
 	sensor.mc = bmc
@@ -422,3 +471,27 @@ func addToSel(recordType uint8, recordData []uint8) (err, recordId uint16) {
 	}
 	return 0, e.recordId
 }
+
+// Sel adds the system event records of other daemons, e.g. the threshold
+// alarms of alarmd, by RPC to the "ipmigod" socket.
+type Sel struct{}
+
+type selAdd struct {
+	record [16]uint8
+	done   chan [2]uint16
+}
+
+// selAdds are served by the message loop so they don't race IPMI requests.
+var selAdds = make(chan selAdd)
+
+// Add the record to the SEL and return its record id.
+func (Sel) Add(record [16]uint8, recordId *uint16) error {
+	done := make(chan [2]uint16, 1)
+	selAdds <- selAdd{record, done}
+	r := <-done
+	if r[0] != 0 {
+		return fmt.Errorf("add sel: completion code %#x", r[0])
+	}
+	*recordId = r[1]
+	return nil
+}
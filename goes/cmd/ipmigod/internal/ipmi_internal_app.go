@@ -77,6 +77,8 @@ func getBtInterfaceCapabilties(msg *msgT) {
 }
 
 func getSystemGuid(msg *msgT) {
+	var data [17]uint8
+
 	// no session only allowed with authtype_none
 	if msg.rmcp.session.sid == 0 {
 		if msg.rmcp.session.authType != IPMI_AUTHTYPE_NONE {
@@ -86,6 +88,9 @@ func getSystemGuid(msg *msgT) {
 		}
 	}
 
+	data[0] = 0
+	copy(data[1:], lanserv.guid[:])
+	msg.returnRspData(nil, data[0:17], 17)
 }
 
 func getChannelAuthCapabilties(msg *msgT) {
@@ -106,10 +111,6 @@ func getChannelAuthCapabilties(msg *msgT) {
 
 	dataStart := msg.dataStart
 	do_rmcpp := (msg.data[dataStart] >> 7) & 1
-	if do_rmcpp > 0 {
-		fmt.Println("get chan auth caps: rmcpp requested")
-		return
-	}
 
 	channel := msg.data[dataStart] & 0xf
 	priv := msg.data[msg.dataStart+1] & 0xf
@@ -125,15 +126,31 @@ func getChannelAuthCapabilties(msg *msgT) {
 			lanserv.chanNum)
 		msg.returnErr(nil, IPMI_INVALID_DATA_FIELD_CC)
 	} else {
+		var auths uint16
+
+		// Auth types and user names of the users with the privilege;
+		// per-message and user-level authentication are on and there
+		// is no anonymous support.
+		for i := 1; i <= MAX_USERS; i++ {
+			user := &lanserv.users[i]
+			if !user.valid || user.maxPriv < priv {
+				continue
+			}
+			auths |= user.allowedAuths
+			if isAuthvalNull(user.username) {
+				data[3] |= 0x2 // null user names enabled
+			} else {
+				data[3] |= 0x4 // non-null user names enabled
+			}
+		}
+
 		data[0] = 0
 		data[1] = channel
-		data[2] = 0x17 //HACK lanserv.chan_priv_allowed_auths[priv-1]
-		data[3] = 0x6  // HACK per-message authentication is on,
-		// Only RMCP for now (no RMCPP)
-		// user-level authenitcation is on,
-		// non-null user names disabled,
-		// no anonymous support.
-		data[4] = 0
+		data[2] = uint8(auths) & 0x37
+		if do_rmcpp > 0 {
+			data[2] |= 0x80 // IPMI v2.0 extended capabilities
+			data[4] = 0x3   // IPMI v1.5 and v2.0 connections
+		}
 		data[5] = 0
 		data[6] = 0
 		data[7] = 0
@@ -322,20 +339,18 @@ func activateSession(msg *msgT) {
 		session.userid = user.idx
 		session.timeLeft = lanserv.defaultSessionTimeout
 
+		if err := session.newSid(); err != nil {
+			fmt.Println("Activate session fail:", err)
+			session.active = false
+			msg.returnErr(&dummySession, 0x81) // No session slot
+			return
+		}
 		lanserv.activeSessions++
 		if debug {
 			fmt.Printf("Activate session: Session opened\n")
 			fmt.Printf("0x%x, max priv %d\n", userIdx, maxPriv)
 		}
 
-		if lanserv.sidSeq == 0 {
-			lanserv.sidSeq++
-		}
-		session.sid =
-			uint32((lanserv.sidSeq << (SESSION_BITS_REQ + 1)) |
-				(session.handle << 1))
-		lanserv.sidSeq++
-
 		// Build response and send back
 		data[0] = 0
 		data[1] = session.authtype
@@ -542,6 +557,8 @@ func masterReadWrite(msg *msgT) {
 }
 
 func getChannelCipherSuites(msg *msgT) {
+	var data [2 + 16]uint8
+
 	// no session only allowed with authtype_none
 	if msg.rmcp.session.sid == 0 {
 
@@ -552,6 +569,29 @@ func getChannelCipherSuites(msg *msgT) {
 		}
 	}
 
+	dataStart := msg.dataStart
+	channel := msg.data[dataStart] & 0xf
+	if channel == 0xe { // means use "this channel"
+		channel = lanserv.chanNum
+	}
+	if channel != lanserv.chanNum ||
+		msg.data[dataStart+1] != RMCPP_PAYLOAD_IPMI {
+		msg.returnErr(nil, IPMI_INVALID_DATA_FIELD_CC)
+		return
+	}
+
+	// 16 bytes of the records per list index; fewer end the list
+	bySuite := msg.data[dataStart+2]&0x80 != 0
+	index := int(msg.data[dataStart+2]&0x3f) * 16
+	records := cipherSuiteRecords(bySuite)
+	if index > len(records) {
+		index = len(records)
+	}
+
+	data[0] = 0
+	data[1] = channel
+	n := 2 + copy(data[2:], records[index:])
+	msg.returnRspData(nil, data[0:n], uint(n))
 }
 
 func suspendResumePayloadEncryption(msg *msgT) {
@@ -7,8 +7,11 @@ package internal
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
+	"time"
 )
 
 //
@@ -53,8 +56,8 @@ type lanservT struct {
 	chanPrivAllowedAuths  [5]uint8
 	activeSessions        uint8
 	nextChallSeq          uint32
-	sidSeq                uint32
 	defaultSessionTimeout uint32
+	guid                  [16]uint8
 	users                 [MAX_USERS + 1]userT
 	sessions              [MAX_SESSIONS + 1]sessionT
 }
@@ -100,6 +103,12 @@ func findUser(username []uint8, nameOnlyLookup bool, priv uint8) *userT {
 	return foundUser
 }
 
+// key of the user's RAKP and session integrity codes, the password less
+// its zero padding.
+func (user *userT) key() []uint8 {
+	return bytes.TrimRight(user.pw, "\x00")
+}
+
 type sessionT struct {
 	active    bool
 	inStartup bool
@@ -124,9 +133,32 @@ type sessionT struct {
 	remSid        uint32
 	auth          uint8
 	conf          uint8
-	integ         uint
+	integ         uint8
 	priv          uint8
 	maxPriv       uint8
+	recvWindow    uint32 // received bitmap of recvSeq and prior
+	lastTime      time.Time // of the last packet, idle timeLeft seconds
+
+	/* RAKP data */
+	role     uint8
+	username []uint8
+	rm       [16]uint8 // remote console random number
+	rc       [16]uint8 // managed system random number
+	sik      []uint8
+	k1       []uint8 // integrity key
+	k2       []uint8 // confidentiality key
+}
+
+// newSid of the session, its handle with random bits so that the managed
+// system's session ids can't be predicted.
+func (session *sessionT) newSid() error {
+	var b [4]uint8
+	if _, err := io.ReadFull(rmcppRand, b[:]); err != nil {
+		return err
+	}
+	session.sid = binary.LittleEndian.Uint32(b[:])<<(SESSION_BITS_REQ+1) |
+		session.handle<<1
+	return nil
 }
 
 type msgT struct {
@@ -17,15 +17,78 @@ const (
 )
 
 func getFruInventoryAreaInfo(msg *msgT) {
-	fmt.Println("storageNetfn not supported", msg.rmcp.message.cmd)
+	var data [4]uint8
+
+	dataStart := msg.dataStart
+	if msg.data[dataStart] != 0 || mc.fru == nil {
+		msg.returnErr(nil, IPMI_NOT_PRESENT_CC)
+		return
+	}
+
+	data[0] = 0
+	binary.LittleEndian.PutUint16(data[1:3], uint16(len(mc.fru)))
+	data[3] = 0 // accessed by bytes
+	msg.returnRspData(nil, data[0:4], 4)
 }
 
 func readFruData(msg *msgT) {
-	fmt.Println("storageNetfn not supported", msg.rmcp.message.cmd)
+	var data [2 + 255]uint8
+
+	dataStart := msg.dataStart
+	if msg.data[dataStart] != 0 || mc.fru == nil {
+		msg.returnErr(nil, IPMI_NOT_PRESENT_CC)
+		return
+	}
+
+	offset := uint(binary.LittleEndian.Uint16(
+		msg.data[dataStart+1 : dataStart+3]))
+	count := uint(msg.data[dataStart+3])
+	if offset >= uint(len(mc.fru)) {
+		msg.returnErr(nil, IPMI_PARAMETER_OUT_OF_RANGE_CC)
+		return
+	}
+	if offset+count > uint(len(mc.fru)) {
+		count = uint(len(mc.fru)) - offset
+	}
+
+	data[0] = 0
+	data[1] = uint8(count)
+	copy(data[2:], mc.fru[offset:offset+count])
+	msg.returnRspData(nil, data[0:count+2], count+2)
 }
 
 func writeFruData(msg *msgT) {
-	fmt.Println("storageNetfn not supported", msg.rmcp.message.cmd)
+	var data [2]uint8
+
+	dataStart := msg.dataStart
+	// Request data precedes the message checksum
+	if msg.dataLen < dataStart+4 {
+		msg.returnErr(nil, IPMI_REQUEST_DATA_LENGTH_INVALID_CC)
+		return
+	}
+	if msg.data[dataStart] != 0 || mc.fru == nil {
+		msg.returnErr(nil, IPMI_NOT_PRESENT_CC)
+		return
+	}
+
+	offset := uint(binary.LittleEndian.Uint16(
+		msg.data[dataStart+1 : dataStart+3]))
+	count := msg.dataLen - 1 - (dataStart + 3)
+	if offset+count > uint(len(mc.fru)) || count > 0xff {
+		msg.returnErr(nil, IPMI_PARAMETER_OUT_OF_RANGE_CC)
+		return
+	}
+
+	copy(mc.fru[offset:], msg.data[dataStart+3:dataStart+3+count])
+	if err := fruSave(); err != nil {
+		fmt.Println("writeFruData:", err)
+		msg.returnErr(nil, IPMI_UNKNOWN_ERR_CC)
+		return
+	}
+
+	data[0] = 0
+	data[1] = uint8(count)
+	msg.returnRspData(nil, data[0:2], 2)
 }
 
 func getSdrRepositoryInfo(msg *msgT) {
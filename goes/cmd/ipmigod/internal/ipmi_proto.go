@@ -6,10 +6,20 @@
 package internal
 
 import (
+	"bufio"
+	crand "crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/platinasystems/atsock"
 )
 
 const (
@@ -76,7 +86,6 @@ func ipmiLanInit() {
 
 	lanserv.chanNum = 1
 	lanserv.defaultSessionTimeout = 30
-	lanserv.sidSeq = 0
 	lanserv.nextChallSeq = 0
 	lanserv.chanPrivLimit = IPMI_PRIVILEGE_ADMIN
 	lanserv.chanPrivAllowedAuths[IPMI_PRIVILEGE_CALLBACK-1] =
@@ -95,6 +104,90 @@ func ipmiLanInit() {
 	}
 }
 
+// UsersFile replaces the default users with a line of
+//
+//	NAME PASSWORD [user|operator|admin]
+//
+// for each user of RMCP+ sessions; the privilege defaults to user.
+// Without it, the managed system refuses RMCP+ sessions.
+var UsersFile = "/etc/goes/ipmigod-users"
+
+func loadUsers(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var users [MAX_USERS + 1]userT
+	idx := 0
+	privs := map[string]uint8{
+		"user":     IPMI_PRIVILEGE_USER,
+		"operator": IPMI_PRIVILEGE_OPERATOR,
+		"admin":    IPMI_PRIVILEGE_ADMIN,
+	}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		priv := IPMI_PRIVILEGE_USER
+		if len(fields) == 3 {
+			priv = int(privs[fields[2]])
+		}
+		if len(fields) < 2 || len(fields) > 3 || priv == 0 ||
+			len(fields[0]) > 16 || len(fields[1]) > 20 {
+			return fmt.Errorf("%s:%d: invalid user", fn, line)
+		}
+		if idx++; idx > MAX_USERS {
+			return fmt.Errorf("%s:%d: too many users", fn, line)
+		}
+		user := &users[idx]
+		user.idx = uint8(idx)
+		user.username = make([]uint8, 16)
+		copy(user.username, fields[0])
+		user.pw = make([]uint8, 20)
+		copy(user.pw, fields[1])
+		user.maxPriv = uint8(priv)
+		user.allowedAuths = 1 << IPMI_AUTHTYPE_RMCP_PLUS
+		user.valid = true
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	lanserv.users = users
+	return nil
+}
+
+// GuidFile persists the system GUID, generated at random on the first
+// start of ipmigod so that each system has its own.
+var GuidFile = "/var/lib/ipmigod/guid"
+
+func loadGuid(fn string) error {
+	b, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		b = make([]uint8, len(lanserv.guid))
+		if _, err = io.ReadFull(crand.Reader, b); err != nil {
+			return err
+		}
+		copy(lanserv.guid[:], b)
+		err = os.MkdirAll(filepath.Dir(fn), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(fn, b, 0644)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if len(b) != len(lanserv.guid) {
+		return fmt.Errorf("%s: invalid guid", fn)
+	}
+	copy(lanserv.guid[:], b)
+	return nil
+}
+
 func init() {
 	// daemon setup
 	// Do startup initialization for daemon
@@ -107,7 +200,7 @@ func init() {
 	// allowed_auths_user none md2 md5 straight
 	// allowed_auths_operator none md2 md5 straight
 	// allowed_auths_admin none md2 md5 straight
-	// guid a123456789abcdefa123456789abcdef
+	// guid from GuidFile
 	//  user 2 true  "ipmiusr" "test" admin    10 none md2 md5 straight
 	ipmiLanInit()
 }
@@ -128,9 +221,24 @@ func Ipmigod(mmCardMode bool, cardNum int) {
 		}
 	}
 
+	if err := loadUsers(UsersFile); err != nil && !os.IsNotExist(err) {
+		log.Fatal(err)
+	}
+	if err := loadGuid(GuidFile); err != nil {
+		fmt.Println("guid:", err)
+	}
+
 	// Initialize BMC SDRs/Sensors
 	bmcInit()
 
+	// Serve SEL records of other daemons
+	rpc.Register(Sel{})
+	rpcServer, err := atsock.NewRpcServer("ipmigod")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rpcServer.Close()
+
 	// Listen on UDP port 623 on all interfaces.
 	serverAddr, err := net.ResolveUDPAddr("udp", ":623")
 	if err != nil {
@@ -168,6 +276,9 @@ func Ipmigod(mmCardMode bool, cardNum int) {
 		select {
 		case msg := <-udpMessages:
 			msg.ipmiHandleMsg()
+		case a := <-selAdds:
+			cc, recordId := addToSel(a.record[2], a.record[:])
+			a.done <- [2]uint16{cc, recordId}
 		default:
 			if Signaled() {
 				fmt.Println("Got kill signal - returning")
@@ -191,8 +302,7 @@ func (msg *msgT) ipmiHandleMsg() {
 	msg.ipmiParseMsg()
 
 	if msg.authtype == IPMI_AUTHTYPE_RMCP_PLUS {
-		//ipmi_handle_rmcpp_msg(lan, &msg);
-		fmt.Println("Received RMCP+ message!")
+		msg.ipmiHandleRmcppMsg()
 	} else {
 		if debug {
 			fmt.Println("Received RMCP message!")
@@ -210,8 +320,9 @@ func (msg *msgT) ipmiParseMsg() {
 	} else if msg.data[dataStart+3] == 7 {
 		// Peek ahead to see if we have an RMCP or RMCP+ message
 		if msg.data[dataStart+4] == IPMI_AUTHTYPE_RMCP_PLUS {
-			fmt.Println("LAN msg not supported RMCP+")
-			//ipmi_parse_rmcpp_msg(msg)
+			// Parsed with its integrity and confidentiality
+			// by ipmiHandleRmcppMsg
+			msg.authtype = IPMI_AUTHTYPE_RMCP_PLUS
 		} else {
 			msg.ipmiParseRmcpMsg()
 		}
@@ -256,9 +367,13 @@ func (msg *msgT) ipmiParseRmcpMsg() {
 	} else {
 		msg.dataStart += 10
 	}
-	dataStart = msg.dataStart
+	msg.ipmiParseMessage()
+}
+
+// ipmiParseMessage loads the IPMI Message fields that precede the data.
+func (msg *msgT) ipmiParseMessage() {
+	dataStart := msg.dataStart
 
-	// Load IPMI Message fields
 	msg.rmcp.message.rsAddr = msg.data[dataStart]
 	msg.rmcp.message.netfn = msg.data[dataStart+1] >> 2
 	msg.rmcp.message.rsLun = msg.data[dataStart+1] & 0x3
@@ -272,15 +387,17 @@ func (msg *msgT) ipmiParseRmcpMsg() {
 func (msg *msgT) returnRsp(session *sessionT, rsp *rspMsgDataT) {
 	var (
 		data         [MAX_MSG_RETURN_DATA]uint8
-		csum         int8
 		dummySession sessionT
-		len          int
 	)
 
 	if session == nil {
 		session = sidToSession(msg.sid)
 	}
-	if session != nil && session.rmcpplus {
+	if msg.authtype == IPMI_AUTHTYPE_RMCP_PLUS {
+		msg.returnRmcppPayload(session, RMCPP_PAYLOAD_IPMI,
+			msg.rspMessage(rsp))
+		return
+	} else if session != nil && session.rmcpplus {
 		//rmcp plus not currently supported
 		fmt.Println("RMCP+ returnRsp not supported!")
 		return
@@ -321,36 +438,10 @@ func (msg *msgT) returnRsp(session *sessionT, rsp *rspMsgDataT) {
 		dcur += 16 // sizeof rmcp.session.auth_code[]
 	}
 	// Add message structure length to specified payload length
-	len = int(rsp.dataLen + 7) // rmcp.message layer size
-	data[dcur] = uint8(len)
-	dcur++
-	startOfMsg := dcur
-	data[dcur] = msg.rmcp.message.rqAddr
-	dcur++
-	data[dcur] = (rsp.netfn << 2) | msg.rmcp.message.rqLun
-	dcur++
-	data[dcur] = uint8(ipmiChecksum(data[startOfMsg:startOfMsg+2], 2, 0))
-	if debug {
-		fmt.Printf("csum1: %x\n", data[dcur])
-	}
-	dcur++
-	data[dcur] = msg.rmcp.message.rsAddr
-	dcur++
-	data[dcur] = (msg.rmcp.message.rqSeq << 2) | msg.rmcp.message.rsLun
-	dcur++
-	data[dcur] = rsp.cmd
-	dcur++
-	// copy the response payload data into msg data
-	copy(data[dcur:], rsp.data[0:rsp.dataLen])
-	csum = -ipmiChecksum(data[dcur-3:dcur], 3, 0)
-	csum = ipmiChecksum(data[dcur:dcur+int(rsp.dataLen)],
-		int(rsp.dataLen), csum)
-	dcur += int(rsp.dataLen)
-	data[dcur] = uint8(csum)
-	if debug {
-		fmt.Printf("csum2: %x\n", data[dcur])
-	}
+	message := msg.rspMessage(rsp)
+	data[dcur] = uint8(len(message))
 	dcur++
+	dcur += copy(data[dcur:], message)
 	if session.authtype != IPMI_AUTHTYPE_NONE {
 		// authgen needed for real authtype
 		//rv = auth_gen(session, data+13,
@@ -365,6 +456,22 @@ func (msg *msgT) returnRsp(session *sessionT, rsp *rspMsgDataT) {
 	msg.conn.WriteToUDP(data[0:dcur], msg.remoteAddr)
 }
 
+// rspMessage of the IPMI Message layer with its checksums.
+func (msg *msgT) rspMessage(rsp *rspMsgDataT) []uint8 {
+	message := make([]uint8, 0, 7+rsp.dataLen)
+	message = append(message, msg.rmcp.message.rqAddr,
+		(rsp.netfn<<2)|msg.rmcp.message.rqLun)
+	message = append(message, uint8(ipmiChecksum(message, 2, 0)))
+	message = append(message, msg.rmcp.message.rsAddr,
+		(msg.rmcp.message.rqSeq<<2)|msg.rmcp.message.rsLun, rsp.cmd)
+	message = append(message, rsp.data[0:rsp.dataLen]...)
+	csum := ipmiChecksum(message[3:], len(message)-3, 0)
+	if debug {
+		fmt.Printf("csum1: %x csum2: %x\n", message[2], uint8(csum))
+	}
+	return append(message, uint8(csum))
+}
+
 func (msg *msgT) returnErr(session *sessionT, err uint8) {
 
 	var rsp rspMsgDataT
@@ -417,7 +524,12 @@ var chassisProcessors = map[uint8]chassisProcessor{
 }
 
 func chassisNetfn(msg *msgT) {
-	(chassisProcessors[msg.rmcp.message.cmd])(msg)
+	processor, found := chassisProcessors[msg.rmcp.message.cmd]
+	if !found {
+		msg.returnErr(nil, IPMI_INVALID_CMD_CC)
+		return
+	}
+	processor(msg)
 }
 
 type bridgeProcessor func(*msgT)
@@ -495,7 +607,12 @@ var sensorProcessors = map[uint8]sensorProcessor{
 }
 
 func sensorEventNetfn(msg *msgT) {
-	(sensorProcessors[msg.rmcp.message.cmd])(msg)
+	processor, found := sensorProcessors[msg.rmcp.message.cmd]
+	if !found {
+		msg.returnErr(nil, IPMI_INVALID_CMD_CC)
+		return
+	}
+	processor(msg)
 }
 
 type appProcessor func(*msgT)
@@ -557,7 +674,12 @@ var appProcessors = map[uint8]appProcessor{
 }
 
 func appNetfn(msg *msgT) {
-	(appProcessors[msg.rmcp.message.cmd])(msg)
+	processor, found := appProcessors[msg.rmcp.message.cmd]
+	if !found {
+		msg.returnErr(nil, IPMI_INVALID_CMD_CC)
+		return
+	}
+	processor(msg)
 }
 
 func firmwareNetfn(msg *msgT) {
@@ -601,7 +723,12 @@ var storageProcessors = map[uint8]storageProcessor{
 }
 
 func storageNetfn(msg *msgT) {
-	(storageProcessors[msg.rmcp.message.cmd])(msg)
+	processor, found := storageProcessors[msg.rmcp.message.cmd]
+	if !found {
+		msg.returnErr(nil, IPMI_INVALID_CMD_CC)
+		return
+	}
+	processor(msg)
 }
 
 type transportProcessor func(*msgT)
@@ -631,8 +758,12 @@ var transportProcessors = map[uint8]transportProcessor{
 }
 
 func transportNetfn(msg *msgT) {
-	fmt.Println("transportNetfn not supported",
-		msg.rmcp.message.cmd)
+	processor, found := transportProcessors[msg.rmcp.message.cmd]
+	if !found {
+		msg.returnErr(nil, IPMI_INVALID_CMD_CC)
+		return
+	}
+	processor(msg)
 }
 
 func groupExtensionNetfn(msg *msgT) {
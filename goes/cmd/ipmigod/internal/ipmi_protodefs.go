@@ -257,3 +257,47 @@ const (
 	IPMI_PRIVILEGE_ADMIN    = 4
 	IPMI_PRIVILEGE_OEM      = 5
 )
+
+// RMCP+ payload types
+const (
+	RMCPP_PAYLOAD_IPMI             = 0x00
+	RMCPP_PAYLOAD_OEM_EXPLICIT     = 0x02
+	RMCPP_PAYLOAD_OPEN_SESSION_REQ = 0x10
+	RMCPP_PAYLOAD_OPEN_SESSION_RSP = 0x11
+	RMCPP_PAYLOAD_RAKP1            = 0x12
+	RMCPP_PAYLOAD_RAKP2            = 0x13
+	RMCPP_PAYLOAD_RAKP3            = 0x14
+	RMCPP_PAYLOAD_RAKP4            = 0x15
+
+	RMCPP_PAYLOAD_TYPE_MASK     = 0x3f
+	RMCPP_PAYLOAD_AUTHENTICATED = 0x40
+	RMCPP_PAYLOAD_ENCRYPTED     = 0x80
+)
+
+// RMCP+ authentication, integrity and confidentiality algorithms
+const (
+	RMCPP_AUTH_RAKP_NONE        = 0x00
+	RMCPP_AUTH_RAKP_HMAC_SHA1   = 0x01
+	RMCPP_AUTH_RAKP_HMAC_SHA256 = 0x03
+
+	RMCPP_INTEG_NONE            = 0x00
+	RMCPP_INTEG_HMAC_SHA1_96    = 0x01
+	RMCPP_INTEG_HMAC_SHA256_128 = 0x04
+
+	RMCPP_CONF_NONE        = 0x00
+	RMCPP_CONF_AES_CBC_128 = 0x01
+)
+
+// RMCP+ and RAKP message status codes
+const (
+	RMCPP_NO_ERRORS               = 0x00
+	RMCPP_INSUFFICIENT_RESOURCES  = 0x01
+	RMCPP_INVALID_SESSION_ID      = 0x02
+	RMCPP_INVALID_ROLE            = 0x09
+	RMCPP_UNAUTHORIZED_ROLE       = 0x0a
+	RMCPP_INVALID_NAME_LENGTH     = 0x0c
+	RMCPP_UNAUTHORIZED_NAME       = 0x0d
+	RMCPP_INVALID_INTEGRITY_CHECK = 0x0f
+	RMCPP_NO_CIPHER_SUITE_MATCH   = 0x11
+	RMCPP_ILLEGAL_PARAMETER       = 0x12
+)
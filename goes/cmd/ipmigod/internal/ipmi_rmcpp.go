@@ -0,0 +1,835 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package contains IPMI 2.0 spec RMCP+ implementation
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// AllowCipherSuite0 permits RMCP+ sessions without authentication,
+// integrity or confidentiality.
+var AllowCipherSuite0 = false
+
+// AllowCipherSuiteNoIntegrity permits the authenticated RMCP+ sessions of
+// cipher suites 1 and 15 without integrity or confidentiality.
+var AllowCipherSuiteNoIntegrity = false
+
+// rmcppRand is the source of the session ids, RAKP random numbers and
+// AES initialization vectors of the managed system.
+var rmcppRand io.Reader = crand.Reader
+
+const (
+	RMCPP_HDR_LENGTH = 16 // RMCP header through payload length
+	RAKP_NAME_LENGTH = 16
+	RMCPP_NEXT_HDR   = 0x07
+)
+
+type cipherSuiteT struct {
+	id    uint8
+	auth  uint8
+	integ uint8
+	conf  uint8
+}
+
+var cipherSuites = []cipherSuiteT{
+	{0, RMCPP_AUTH_RAKP_NONE, RMCPP_INTEG_NONE, RMCPP_CONF_NONE},
+	{1, RMCPP_AUTH_RAKP_HMAC_SHA1, RMCPP_INTEG_NONE, RMCPP_CONF_NONE},
+	{2, RMCPP_AUTH_RAKP_HMAC_SHA1, RMCPP_INTEG_HMAC_SHA1_96,
+		RMCPP_CONF_NONE},
+	{3, RMCPP_AUTH_RAKP_HMAC_SHA1, RMCPP_INTEG_HMAC_SHA1_96,
+		RMCPP_CONF_AES_CBC_128},
+	{15, RMCPP_AUTH_RAKP_HMAC_SHA256, RMCPP_INTEG_NONE, RMCPP_CONF_NONE},
+	{16, RMCPP_AUTH_RAKP_HMAC_SHA256, RMCPP_INTEG_HMAC_SHA256_128,
+		RMCPP_CONF_NONE},
+	{17, RMCPP_AUTH_RAKP_HMAC_SHA256, RMCPP_INTEG_HMAC_SHA256_128,
+		RMCPP_CONF_AES_CBC_128},
+}
+
+// Commands that may be sent outside of an RMCP+ session
+var rmcppSessionless = map[uint16]bool{
+	APP_NETFN<<8 | GET_CHANNEL_AUTH_CAPABILITIES_CMD: true,
+	APP_NETFN<<8 | GET_CHANNEL_CIPHER_SUITES_CMD:     true,
+	APP_NETFN<<8 | GET_SYSTEM_GUID_CMD:               true,
+}
+
+// Privilege required of each RMCP+ session command per IPMI 2.0
+// Appendix G; the managed system rejects those not listed, including
+// the local only and the bridge (ICMB) commands and those without a
+// processor.
+var rmcppCmdPrivs = map[uint16]uint8{
+	CHASSIS_NETFN<<8 | GET_CHASSIS_CAPABILITIES_CMD: IPMI_PRIVILEGE_USER,
+	CHASSIS_NETFN<<8 | CHASSIS_CONTROL_CMD:          IPMI_PRIVILEGE_OPERATOR,
+	CHASSIS_NETFN<<8 | CHASSIS_RESET_CMD:            IPMI_PRIVILEGE_OPERATOR,
+	CHASSIS_NETFN<<8 | CHASSIS_IDENTIFY_CMD:         IPMI_PRIVILEGE_OPERATOR,
+	CHASSIS_NETFN<<8 | SET_CHASSIS_CAPABILITIES_CMD: IPMI_PRIVILEGE_ADMIN,
+	CHASSIS_NETFN<<8 | SET_POWER_RESTORE_POLICY_CMD: IPMI_PRIVILEGE_OPERATOR,
+	CHASSIS_NETFN<<8 | GET_SYSTEM_RESTART_CAUSE_CMD: IPMI_PRIVILEGE_USER,
+	CHASSIS_NETFN<<8 | SET_SYSTEM_BOOT_OPTIONS_CMD:  IPMI_PRIVILEGE_OPERATOR,
+	CHASSIS_NETFN<<8 | GET_SYSTEM_BOOT_OPTIONS_CMD:  IPMI_PRIVILEGE_OPERATOR,
+
+	SENSOR_EVENT_NETFN<<8 | SET_EVENT_RECEIVER_CMD:          IPMI_PRIVILEGE_ADMIN,
+	SENSOR_EVENT_NETFN<<8 | GET_EVENT_RECEIVER_CMD:          IPMI_PRIVILEGE_USER,
+	SENSOR_EVENT_NETFN<<8 | PLATFORM_EVENT_CMD:              IPMI_PRIVILEGE_OPERATOR,
+	SENSOR_EVENT_NETFN<<8 | GET_PEF_CAPABILITIES_CMD:        IPMI_PRIVILEGE_USER,
+	SENSOR_EVENT_NETFN<<8 | ARM_PEF_POSTPONE_TIMER_CMD:      IPMI_PRIVILEGE_ADMIN,
+	SENSOR_EVENT_NETFN<<8 | SET_PEF_CONFIG_PARMS_CMD:        IPMI_PRIVILEGE_ADMIN,
+	SENSOR_EVENT_NETFN<<8 | GET_PEF_CONFIG_PARMS_CMD:        IPMI_PRIVILEGE_OPERATOR,
+	SENSOR_EVENT_NETFN<<8 | SET_LAST_PROCESSED_EVENT_ID_CMD: IPMI_PRIVILEGE_ADMIN,
+	SENSOR_EVENT_NETFN<<8 | GET_LAST_PROCESSED_EVENT_ID_CMD: IPMI_PRIVILEGE_ADMIN,
+	SENSOR_EVENT_NETFN<<8 | ALERT_IMMEDIATE_CMD:             IPMI_PRIVILEGE_ADMIN,
+	SENSOR_EVENT_NETFN<<8 | GET_SENSOR_READING_FACTORS_CMD:  IPMI_PRIVILEGE_USER,
+	SENSOR_EVENT_NETFN<<8 | SET_SENSOR_HYSTERESIS_CMD:       IPMI_PRIVILEGE_OPERATOR,
+	SENSOR_EVENT_NETFN<<8 | GET_SENSOR_HYSTERESIS_CMD:       IPMI_PRIVILEGE_USER,
+	SENSOR_EVENT_NETFN<<8 | SET_SENSOR_THRESHOLD_CMD:        IPMI_PRIVILEGE_OPERATOR,
+	SENSOR_EVENT_NETFN<<8 | GET_SENSOR_THRESHOLD_CMD:        IPMI_PRIVILEGE_USER,
+	SENSOR_EVENT_NETFN<<8 | SET_SENSOR_EVENT_ENABLE_CMD:     IPMI_PRIVILEGE_OPERATOR,
+	SENSOR_EVENT_NETFN<<8 | GET_SENSOR_EVENT_ENABLE_CMD:     IPMI_PRIVILEGE_USER,
+	SENSOR_EVENT_NETFN<<8 | REARM_SENSOR_EVENTS_CMD:         IPMI_PRIVILEGE_OPERATOR,
+	SENSOR_EVENT_NETFN<<8 | GET_SENSOR_EVENT_STATUS_CMD:     IPMI_PRIVILEGE_USER,
+	SENSOR_EVENT_NETFN<<8 | GET_SENSOR_READING_CMD:          IPMI_PRIVILEGE_USER,
+	SENSOR_EVENT_NETFN<<8 | SET_SENSOR_TYPE_CMD:             IPMI_PRIVILEGE_OPERATOR,
+	SENSOR_EVENT_NETFN<<8 | GET_SENSOR_TYPE_CMD:             IPMI_PRIVILEGE_USER,
+
+	APP_NETFN<<8 | GET_DEVICE_ID_CMD:                     IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | COLD_RESET_CMD:                        IPMI_PRIVILEGE_ADMIN,
+	APP_NETFN<<8 | WARM_RESET_CMD:                        IPMI_PRIVILEGE_ADMIN,
+	APP_NETFN<<8 | GET_SELF_TEST_RESULTS_CMD:             IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | MANUFACTURING_TEST_ON_CMD:             IPMI_PRIVILEGE_ADMIN,
+	APP_NETFN<<8 | SET_ACPI_POWER_STATE_CMD:              IPMI_PRIVILEGE_ADMIN,
+	APP_NETFN<<8 | GET_ACPI_POWER_STATE_CMD:              IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | GET_DEVICE_GUID_CMD:                   IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | RESET_WATCHDOG_TIMER_CMD:              IPMI_PRIVILEGE_OPERATOR,
+	APP_NETFN<<8 | SET_WATCHDOG_TIMER_CMD:                IPMI_PRIVILEGE_OPERATOR,
+	APP_NETFN<<8 | GET_WATCHDOG_TIMER_CMD:                IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | GET_BMC_GLOBAL_ENABLES_CMD:            IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | SEND_MSG_CMD:                          IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | GET_BT_INTERFACE_CAPABILITIES_CMD:     IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | GET_SYSTEM_GUID_CMD:                   IPMI_PRIVILEGE_CALLBACK,
+	APP_NETFN<<8 | GET_CHANNEL_AUTH_CAPABILITIES_CMD:     IPMI_PRIVILEGE_CALLBACK,
+	APP_NETFN<<8 | GET_SESSION_CHALLENGE_CMD:             IPMI_PRIVILEGE_CALLBACK,
+	APP_NETFN<<8 | ACTIVATE_SESSION_CMD:                  IPMI_PRIVILEGE_CALLBACK,
+	APP_NETFN<<8 | SET_SESSION_PRIVILEGE_CMD:             IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | CLOSE_SESSION_CMD:                     IPMI_PRIVILEGE_CALLBACK,
+	APP_NETFN<<8 | GET_SESSION_INFO_CMD:                  IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | GET_AUTHCODE_CMD:                      IPMI_PRIVILEGE_OPERATOR,
+	APP_NETFN<<8 | SET_CHANNEL_ACCESS_CMD:                IPMI_PRIVILEGE_ADMIN,
+	APP_NETFN<<8 | GET_CHANNEL_ACCESS_CMD:                IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | GET_CHANNEL_INFO_CMD:                  IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | SET_USER_ACCESS_CMD:                   IPMI_PRIVILEGE_ADMIN,
+	APP_NETFN<<8 | GET_USER_ACCESS_CMD:                   IPMI_PRIVILEGE_OPERATOR,
+	APP_NETFN<<8 | SET_USER_NAME_CMD:                     IPMI_PRIVILEGE_ADMIN,
+	APP_NETFN<<8 | GET_USER_NAME_CMD:                     IPMI_PRIVILEGE_OPERATOR,
+	APP_NETFN<<8 | SET_USER_PASSWORD_CMD:                 IPMI_PRIVILEGE_ADMIN,
+	APP_NETFN<<8 | ACTIVATE_PAYLOAD_CMD:                  IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | DEACTIVATE_PAYLOAD_CMD:                IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | GET_PAYLOAD_ACTIVATION_STATUS_CMD:     IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | GET_PAYLOAD_INSTANCE_INFO_CMD:         IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | SET_USER_PAYLOAD_ACCESS_CMD:           IPMI_PRIVILEGE_ADMIN,
+	APP_NETFN<<8 | GET_USER_PAYLOAD_ACCESS_CMD:           IPMI_PRIVILEGE_OPERATOR,
+	APP_NETFN<<8 | GET_CHANNEL_PAYLOAD_SUPPORT_CMD:       IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | GET_CHANNEL_PAYLOAD_VERSION_CMD:       IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | GET_CHANNEL_OEM_PAYLOAD_INFO_CMD:      IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | MASTER_READ_WRITE_CMD:                 IPMI_PRIVILEGE_OPERATOR,
+	APP_NETFN<<8 | GET_CHANNEL_CIPHER_SUITES_CMD:         IPMI_PRIVILEGE_CALLBACK,
+	APP_NETFN<<8 | SUSPEND_RESUME_PAYLOAD_ENCRYPTION_CMD: IPMI_PRIVILEGE_USER,
+	APP_NETFN<<8 | SET_CHANNEL_SECURITY_KEY_CMD:          IPMI_PRIVILEGE_ADMIN,
+	APP_NETFN<<8 | GET_SYSTEM_INTERFACE_CAPABILITIES_CMD: IPMI_PRIVILEGE_USER,
+
+	STORAGE_NETFN<<8 | GET_FRU_INVENTORY_AREA_INFO_CMD:   IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | READ_FRU_DATA_CMD:                 IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | WRITE_FRU_DATA_CMD:                IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | GET_SDR_REPOSITORY_INFO_CMD:       IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | GET_SDR_REPOSITORY_ALLOC_INFO_CMD: IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | RESERVE_SDR_REPOSITORY_CMD:        IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | GET_SDR_CMD:                       IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | ADD_SDR_CMD:                       IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | PARTIAL_ADD_SDR_CMD:               IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | DELETE_SDR_CMD:                    IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | CLEAR_SDR_REPOSITORY_CMD:          IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | GET_SDR_REPOSITORY_TIME_CMD:       IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | SET_SDR_REPOSITORY_TIME_CMD:       IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | ENTER_SDR_REPOSITORY_UPDATE_CMD:   IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | EXIT_SDR_REPOSITORY_UPDATE_CMD:    IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | RUN_INITIALIZATION_AGENT_CMD:      IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | GET_SEL_INFO_CMD:                  IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | GET_SEL_ALLOCATION_INFO_CMD:       IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | RESERVE_SEL_CMD:                   IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | GET_SEL_ENTRY_CMD:                 IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | ADD_SEL_ENTRY_CMD:                 IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | PARTIAL_ADD_SEL_ENTRY_CMD:         IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | DELETE_SEL_ENTRY_CMD:              IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | CLEAR_SEL_CMD:                     IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | GET_SEL_TIME_CMD:                  IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | SET_SEL_TIME_CMD:                  IPMI_PRIVILEGE_OPERATOR,
+	STORAGE_NETFN<<8 | GET_AUXILIARY_LOG_STATUS_CMD:      IPMI_PRIVILEGE_USER,
+	STORAGE_NETFN<<8 | SET_AUXILIARY_LOG_STATUS_CMD:      IPMI_PRIVILEGE_ADMIN,
+
+	TRANSPORT_NETFN<<8 | SET_LAN_CONFIG_PARMS_CMD:         IPMI_PRIVILEGE_ADMIN,
+	TRANSPORT_NETFN<<8 | GET_LAN_CONFIG_PARMS_CMD:         IPMI_PRIVILEGE_OPERATOR,
+	TRANSPORT_NETFN<<8 | SUSPEND_BMC_ARPS_CMD:             IPMI_PRIVILEGE_ADMIN,
+	TRANSPORT_NETFN<<8 | GET_IP_UDP_RMCP_STATS_CMD:        IPMI_PRIVILEGE_USER,
+	TRANSPORT_NETFN<<8 | SET_SERIAL_MODEM_CONFIG_CMD:      IPMI_PRIVILEGE_ADMIN,
+	TRANSPORT_NETFN<<8 | GET_SERIAL_MODEM_CONFIG_CMD:      IPMI_PRIVILEGE_OPERATOR,
+	TRANSPORT_NETFN<<8 | SET_SERIAL_MODEM_MUX_CMD:         IPMI_PRIVILEGE_OPERATOR,
+	TRANSPORT_NETFN<<8 | GET_TAP_RESPONSE_CODES_CMD:       IPMI_PRIVILEGE_USER,
+	TRANSPORT_NETFN<<8 | SET_PPP_UDP_PROXY_XMIT_DATA_CMD:  IPMI_PRIVILEGE_OPERATOR,
+	TRANSPORT_NETFN<<8 | GET_PPP_UDP_PROXY_XMIT_DATA_CMD:  IPMI_PRIVILEGE_OPERATOR,
+	TRANSPORT_NETFN<<8 | SEND_PPP_UDP_PROXY_PACKET_CMD:    IPMI_PRIVILEGE_OPERATOR,
+	TRANSPORT_NETFN<<8 | GET_PPP_UDP_PROXY_RECV_DATA_CMD:  IPMI_PRIVILEGE_OPERATOR,
+	TRANSPORT_NETFN<<8 | SET_USER_CALLBACK_OPTIONS_CMD:    IPMI_PRIVILEGE_ADMIN,
+	TRANSPORT_NETFN<<8 | GET_USER_CALLBACK_OPTIONS_CMD:    IPMI_PRIVILEGE_USER,
+	TRANSPORT_NETFN<<8 | SET_SOL_CONFIGURATION_PARAMETERS: IPMI_PRIVILEGE_ADMIN,
+	TRANSPORT_NETFN<<8 | GET_SOL_CONFIGURATION_PARAMETERS: IPMI_PRIVILEGE_USER,
+}
+
+// rmcppUsers returns true if any user may authenticate RMCP+ sessions.
+func rmcppUsers() bool {
+	for i := 1; i <= MAX_USERS; i++ {
+		user := &lanserv.users[i]
+		if user.valid &&
+			user.allowedAuths&(1<<IPMI_AUTHTYPE_RMCP_PLUS) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed cipher suite of new sessions
+func (suite *cipherSuiteT) allowed() bool {
+	switch {
+	case suite.auth == RMCPP_AUTH_RAKP_NONE:
+		return AllowCipherSuite0
+	case suite.integ == RMCPP_INTEG_NONE:
+		return AllowCipherSuiteNoIntegrity
+	}
+	return true
+}
+
+func findCipherSuite(auth, integ, conf uint8) *cipherSuiteT {
+	for i := range cipherSuites {
+		suite := &cipherSuites[i]
+		if suite.auth == auth && suite.integ == integ &&
+			suite.conf == conf {
+			if !suite.allowed() {
+				return nil
+			}
+			return suite
+		}
+	}
+	return nil
+}
+
+// cipherSuiteRecords of Get Channel Cipher Suites, by suite or algorithm.
+func cipherSuiteRecords(bySuite bool) []uint8 {
+	var records []uint8
+	seen := make(map[uint8]bool)
+	for _, suite := range cipherSuites {
+		if !suite.allowed() {
+			continue
+		}
+		algs := []uint8{suite.auth, 0x40 | suite.integ,
+			0x80 | suite.conf}
+		if bySuite {
+			records = append(records, 0xc0, suite.id)
+			records = append(records, algs...)
+			continue
+		}
+		for _, alg := range algs {
+			if !seen[alg] {
+				seen[alg] = true
+				records = append(records, alg)
+			}
+		}
+	}
+	return records
+}
+
+// authHash of the RAKP authentication algorithm and the length of its
+// RAKP4 integrity check value.
+func authHash(auth uint8) (func() hash.Hash, int) {
+	switch auth {
+	case RMCPP_AUTH_RAKP_HMAC_SHA1:
+		return sha1.New, 12
+	case RMCPP_AUTH_RAKP_HMAC_SHA256:
+		return sha256.New, 16
+	}
+	return nil, 0
+}
+
+// integHash of the integrity algorithm and the length of its AuthCode.
+func integHash(integ uint8) (func() hash.Hash, int) {
+	switch integ {
+	case RMCPP_INTEG_HMAC_SHA1_96:
+		return sha1.New, 12
+	case RMCPP_INTEG_HMAC_SHA256_128:
+		return sha256.New, 16
+	}
+	return nil, 0
+}
+
+// hmacSum of the data, or nil without a hash for RAKP-none.
+func hmacSum(h func() hash.Hash, key []uint8, data ...[]uint8) []uint8 {
+	if h == nil {
+		return nil
+	}
+	mac := hmac.New(h, key)
+	for _, b := range data {
+		mac.Write(b)
+	}
+	return mac.Sum(nil)
+}
+
+func le32(u uint32) []uint8 {
+	var b [4]uint8
+	binary.LittleEndian.PutUint32(b[:], u)
+	return b[:]
+}
+
+// rakpKeys derives the session integrity key, K1, and confidentiality
+// key, K2, from the SIK.
+func (session *sessionT) rakpKeys(kg []uint8) {
+	h, _ := authHash(session.auth)
+	if h == nil {
+		return
+	}
+	name := append([]uint8{session.role, uint8(len(session.username))},
+		session.username...)
+	session.sik = hmacSum(h, kg, session.rm[:], session.rc[:], name)
+	n := h().Size()
+	const1 := make([]uint8, n)
+	const2 := make([]uint8, n)
+	for i := 0; i < n; i++ {
+		const1[i] = 1
+		const2[i] = 2
+	}
+	session.k1 = hmacSum(h, session.sik, const1)
+	session.k2 = hmacSum(h, session.sik, const2)
+}
+
+// acceptSeq of an authenticated packet if it isn't a replay of one of
+// the last 32.
+func (session *sessionT) acceptSeq(seq uint32) bool {
+	switch {
+	case seq == 0:
+		return false
+	case seq > session.recvSeq:
+		d := seq - session.recvSeq
+		if d > 32 && session.recvSeq != 0 {
+			return false
+		}
+		if d < 32 {
+			session.recvWindow = session.recvWindow<<d | 1
+		} else {
+			session.recvWindow = 1
+		}
+		session.recvSeq = seq
+	case session.recvSeq-seq < 32:
+		bit := uint32(1) << (session.recvSeq - seq)
+		if session.recvWindow&bit != 0 {
+			return false
+		}
+		session.recvWindow |= bit
+	default:
+		return false
+	}
+	return true
+}
+
+func aesEncrypt(key, plain []uint8) ([]uint8, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]uint8, aes.BlockSize,
+		aes.BlockSize+len(plain)+aes.BlockSize)
+	if _, err = io.ReadFull(rmcppRand, b); err != nil {
+		return nil, err
+	}
+	b = append(b, plain...)
+	var pad uint8
+	for (len(b)+1)%aes.BlockSize != 0 {
+		pad++
+		b = append(b, pad)
+	}
+	b = append(b, pad)
+	cipher.NewCBCEncrypter(block, b[:aes.BlockSize]).
+		CryptBlocks(b[aes.BlockSize:], b[aes.BlockSize:])
+	return b, nil
+}
+
+func aesDecrypt(key, b []uint8) ([]uint8, bool) {
+	if len(b) < 2*aes.BlockSize || len(b)%aes.BlockSize != 0 {
+		return nil, false
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, false
+	}
+	plain := make([]uint8, len(b)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, b[:aes.BlockSize]).
+		CryptBlocks(plain, b[aes.BlockSize:])
+	pad := int(plain[len(plain)-1])
+	if pad >= aes.BlockSize {
+		return nil, false
+	}
+	for i := 0; i < pad; i++ {
+		if plain[len(plain)-1-pad+i] != uint8(i+1) {
+			return nil, false
+		}
+	}
+	return plain[:len(plain)-1-pad], true
+}
+
+// rmcppDecode the payload of the packet, checking the integrity and
+// decrypting the confidentiality of an established session.
+func rmcppDecode(session *sessionT, pkt []uint8) ([]uint8, bool) {
+	var integ, conf uint8
+
+	if len(pkt) < RMCPP_HDR_LENGTH {
+		return nil, false
+	}
+	ptype := pkt[5]
+	n := int(binary.LittleEndian.Uint16(pkt[14:16]))
+	if RMCPP_HDR_LENGTH+n > len(pkt) {
+		return nil, false
+	}
+	if session != nil {
+		integ = session.integ
+		conf = session.conf
+	}
+	authenticated := ptype&RMCPP_PAYLOAD_AUTHENTICATED != 0
+	encrypted := ptype&RMCPP_PAYLOAD_ENCRYPTED != 0
+	if authenticated != (integ != RMCPP_INTEG_NONE) ||
+		encrypted != (conf != RMCPP_CONF_NONE) {
+		return nil, false
+	}
+	if authenticated {
+		h, authLen := integHash(integ)
+		end := len(pkt) - authLen
+		if end < RMCPP_HDR_LENGTH+n+2 || pkt[end-1] != RMCPP_NEXT_HDR {
+			return nil, false
+		}
+		pad := int(pkt[end-2])
+		if RMCPP_HDR_LENGTH+n+pad+2 != end {
+			return nil, false
+		}
+		authCode := hmacSum(h, session.k1, pkt[4:end])[:authLen]
+		if !hmac.Equal(authCode, pkt[end:]) {
+			return nil, false
+		}
+		seq := binary.LittleEndian.Uint32(pkt[10:14])
+		if !session.acceptSeq(seq) {
+			return nil, false
+		}
+	}
+	payload := pkt[RMCPP_HDR_LENGTH : RMCPP_HDR_LENGTH+n]
+	if encrypted {
+		return aesDecrypt(session.k2[:16], payload)
+	}
+	return payload, true
+}
+
+// rmcppEncode the payload in a packet of the session, or outside of a
+// session if nil or starting up.
+func rmcppEncode(session *sessionT, ptype uint8, payload []uint8) ([]uint8,
+	error) {
+	var sid, seq uint32
+
+	if session != nil && !session.inStartup {
+		sid = session.remSid
+		if session.conf == RMCPP_CONF_AES_CBC_128 {
+			var err error
+			payload, err = aesEncrypt(session.k2[:16], payload)
+			if err != nil {
+				return nil, err
+			}
+			ptype |= RMCPP_PAYLOAD_ENCRYPTED
+		}
+		if session.integ != RMCPP_INTEG_NONE {
+			ptype |= RMCPP_PAYLOAD_AUTHENTICATED
+			seq = session.xmitSeq
+			session.xmitSeq++
+			if session.xmitSeq == 0 {
+				session.xmitSeq++
+			}
+		} else {
+			seq = session.unauthXmitSeq
+			session.unauthXmitSeq++
+		}
+	}
+
+	pkt := make([]uint8, RMCPP_HDR_LENGTH,
+		RMCPP_HDR_LENGTH+len(payload)+32)
+	pkt[0] = 6    /* RMCP version. */
+	pkt[2] = 0xff /* No seq num */
+	pkt[3] = 7    /* IPMI msg class */
+	pkt[4] = IPMI_AUTHTYPE_RMCP_PLUS
+	pkt[5] = ptype
+	binary.LittleEndian.PutUint32(pkt[6:10], sid)
+	binary.LittleEndian.PutUint32(pkt[10:14], seq)
+	binary.LittleEndian.PutUint16(pkt[14:16], uint16(len(payload)))
+	pkt = append(pkt, payload...)
+	if ptype&RMCPP_PAYLOAD_AUTHENTICATED != 0 {
+		// Integrity pad from the auth type through next header
+		var pad uint8
+		for (len(pkt)-4+2)%4 != 0 {
+			pkt = append(pkt, 0xff)
+			pad++
+		}
+		pkt = append(pkt, pad, RMCPP_NEXT_HDR)
+		h, authLen := integHash(session.integ)
+		pkt = append(pkt, hmacSum(h, session.k1, pkt[4:])[:authLen]...)
+	}
+	return pkt, nil
+}
+
+func (msg *msgT) returnRmcppPayload(session *sessionT, ptype uint8,
+	payload []uint8) {
+
+	pkt, err := rmcppEncode(session, ptype, payload)
+	if err != nil {
+		fmt.Println("returnRmcppPayload:", err)
+		return
+	}
+	if debug {
+		fmt.Println("Sending", len(pkt), " bytes to", msg.remoteAddr)
+	}
+	msg.conn.WriteToUDP(pkt, msg.remoteAddr)
+}
+
+func (msg *msgT) ipmiHandleRmcppMsg() {
+	var session *sessionT
+
+	pkt := msg.data[msg.dataStart:msg.dataLen]
+	if len(pkt) < RMCPP_HDR_LENGTH {
+		fmt.Println("RMCP+ msg failure: message too short", len(pkt))
+		return
+	}
+	ptype := pkt[5]
+	msg.rmcpp.payload = ptype & RMCPP_PAYLOAD_TYPE_MASK
+	msg.rmcpp.authenticated = (ptype >> 6) & 1
+	msg.rmcpp.encrypted = (ptype >> 7) & 1
+	msg.sid = binary.LittleEndian.Uint32(pkt[6:10])
+	msg.rmcp.session.sid = msg.sid
+	msg.rmcp.session.seq = binary.LittleEndian.Uint32(pkt[10:14])
+
+	if msg.sid != 0 {
+		session = sidToSession(msg.sid)
+		if session == nil || !session.rmcpplus || session.inStartup {
+			fmt.Printf("RMCP+ msg failure: no session %x\n",
+				msg.sid)
+			return
+		}
+	}
+	payload, ok := rmcppDecode(session, pkt)
+	if !ok {
+		fmt.Printf("RMCP+ msg failure: invalid session %x packet\n",
+			msg.sid)
+		return
+	}
+	if session != nil {
+		session.timeLeft = lanserv.defaultSessionTimeout
+		session.lastTime = time.Now()
+	}
+
+	switch msg.rmcpp.payload {
+	case RMCPP_PAYLOAD_IPMI:
+		if len(payload) < 7 {
+			fmt.Println("RMCP+ msg failure: IPMI msg too short")
+			return
+		}
+		// Handlers expect the message in place of the packet
+		msg.dataLen = uint(copy(msg.data[:], payload))
+		msg.dataStart = 0
+		msg.ipmiParseMessage()
+		netfn := msg.rmcp.message.netfn
+		cmd := uint16(netfn)<<8 | uint16(msg.rmcp.message.cmd)
+		if session == nil && !rmcppSessionless[cmd] {
+			fmt.Printf("RMCP+ msg failure: no session for %x\n", cmd)
+			return
+		}
+		processor, found := netfuncProcessors[netfn]
+		if !found {
+			msg.returnErr(session, IPMI_INVALID_CMD_CC)
+			return
+		}
+		if session != nil {
+			priv, listed := rmcppCmdPrivs[cmd]
+			if !listed {
+				msg.returnErr(session, IPMI_INVALID_CMD_CC)
+				return
+			}
+			if priv > session.priv {
+				msg.returnErr(session,
+					IPMI_INSUFFICIENT_PRIVILEGE_CC)
+				return
+			}
+		}
+		processor(msg)
+	case RMCPP_PAYLOAD_OPEN_SESSION_REQ:
+		if session == nil {
+			rmcppOpenSession(msg, payload)
+		}
+	case RMCPP_PAYLOAD_RAKP1:
+		if session == nil {
+			rakp1(msg, payload)
+		}
+	case RMCPP_PAYLOAD_RAKP3:
+		if session == nil {
+			rakp3(msg, payload)
+		}
+	default:
+		fmt.Println("RMCP+ msg not supported payload",
+			msg.rmcpp.payload)
+	}
+}
+
+func closeRmcppSession(session *sessionT) {
+	session.active = false
+	session.inStartup = false
+	lanserv.activeSessions--
+}
+
+// rmcppStartupSession of the managed system session id from RAKP 1 or 3.
+func rmcppStartupSession(sid uint32) *sessionT {
+	session := sidToSession(sid)
+	if session == nil || !session.rmcpplus || !session.inStartup {
+		return nil
+	}
+	return session
+}
+
+func rmcppOpenSession(msg *msgT, req []uint8) {
+	var (
+		data  [36]uint8
+		algs  [3]uint8
+		suite *cipherSuiteT
+	)
+
+	if len(req) < 32 {
+		fmt.Println("Open session fail: message too short")
+		return
+	}
+
+	// Reclaim sessions abandoned during RAKP or idle past their timeout
+	for i := 1; i <= MAX_SESSIONS; i++ {
+		s := &lanserv.sessions[i]
+		timeout := time.Duration(s.timeLeft) * time.Second
+		if s.active && s.rmcpplus && time.Since(s.lastTime) > timeout {
+			fmt.Printf("Session %d closed: idle\n", s.handle)
+			closeRmcppSession(s)
+		}
+	}
+
+	data[0] = req[0] // message tag
+	copy(data[4:8], req[4:8])
+	remSid := binary.LittleEndian.Uint32(req[4:8])
+	role := req[1] & 0xf
+	status := uint8(RMCPP_NO_ERRORS)
+	for i := range algs {
+		payload := req[8+8*i : 16+8*i]
+		if payload[0] != uint8(i) || payload[3] != 8 {
+			status = RMCPP_ILLEGAL_PARAMETER
+		}
+		algs[i] = payload[4] & 0x3f
+	}
+	if status == RMCPP_NO_ERRORS {
+		suite = findCipherSuite(algs[0], algs[1], algs[2])
+	}
+
+	switch {
+	case status != RMCPP_NO_ERRORS:
+	case !rmcppUsers():
+		status = RMCPP_UNAUTHORIZED_NAME
+	case suite == nil:
+		status = RMCPP_NO_CIPHER_SUITE_MATCH
+	case remSid == 0:
+		status = RMCPP_INVALID_SESSION_ID
+	case role > IPMI_PRIVILEGE_OEM:
+		status = RMCPP_INVALID_ROLE
+	case lanserv.activeSessions >= MAX_SESSIONS:
+		status = RMCPP_INSUFFICIENT_RESOURCES
+	}
+	var session *sessionT
+	if status == RMCPP_NO_ERRORS {
+		if session = findFreeSession(); session == nil {
+			status = RMCPP_INSUFFICIENT_RESOURCES
+		}
+	}
+	if status != RMCPP_NO_ERRORS {
+		fmt.Println("Open session fail: status", status)
+		data[1] = status
+		msg.returnRmcppPayload(nil, RMCPP_PAYLOAD_OPEN_SESSION_RSP,
+			data[0:8])
+		return
+	}
+
+	if role == 0 || role > lanserv.chanPrivLimit {
+		role = lanserv.chanPrivLimit
+	}
+	*session = sessionT{
+		handle:        session.handle,
+		active:        true,
+		inStartup:     true,
+		rmcpplus:      true,
+		remSid:        remSid,
+		auth:          suite.auth,
+		integ:         suite.integ,
+		conf:          suite.conf,
+		maxPriv:       role,
+		priv:          IPMI_PRIVILEGE_USER,
+		xmitSeq:       1,
+		unauthXmitSeq: 1,
+		timeLeft:      lanserv.defaultSessionTimeout,
+		lastTime:      time.Now(),
+	}
+	if err := session.newSid(); err != nil {
+		fmt.Println("Open session fail:", err)
+		session.active = false
+		data[1] = RMCPP_INSUFFICIENT_RESOURCES
+		msg.returnRmcppPayload(nil, RMCPP_PAYLOAD_OPEN_SESSION_RSP,
+			data[0:8])
+		return
+	}
+	lanserv.activeSessions++
+
+	data[2] = role
+	binary.LittleEndian.PutUint32(data[8:12], session.sid)
+	for i, alg := range []uint8{suite.auth, suite.integ, suite.conf} {
+		payload := data[12+8*i : 20+8*i]
+		payload[0] = uint8(i)
+		payload[3] = 8
+		payload[4] = alg
+	}
+	msg.returnRmcppPayload(nil, RMCPP_PAYLOAD_OPEN_SESSION_RSP, data[:])
+}
+
+func rakp1(msg *msgT, req []uint8) {
+	var (
+		data [40 + sha256.Size]uint8
+		user *userT
+	)
+
+	if len(req) < 28 {
+		fmt.Println("RAKP1 fail: message too short")
+		return
+	}
+	data[0] = req[0] // message tag
+	session := rmcppStartupSession(binary.LittleEndian.Uint32(req[4:8]))
+	if session == nil || session.username != nil {
+		data[1] = RMCPP_INVALID_SESSION_ID
+		msg.returnRmcppPayload(nil, RMCPP_PAYLOAD_RAKP2, data[0:8])
+		return
+	}
+	binary.LittleEndian.PutUint32(data[4:8], session.remSid)
+
+	role := req[24]
+	priv := role & 0xf
+	nameLen := int(req[27])
+	status := uint8(RMCPP_NO_ERRORS)
+	switch {
+	case nameLen > RAKP_NAME_LENGTH || 28+nameLen > len(req):
+		status = RMCPP_INVALID_NAME_LENGTH
+	case role&^0x1f != 0 || priv == 0 || priv > IPMI_PRIVILEGE_OEM:
+		status = RMCPP_INVALID_ROLE
+	default:
+		var name [RAKP_NAME_LENGTH]uint8
+		copy(name[:], req[28:28+nameLen])
+		user = findUser(name[:], true, 0)
+		if user == nil || !user.valid ||
+			user.allowedAuths&(1<<IPMI_AUTHTYPE_RMCP_PLUS) == 0 {
+			status = RMCPP_UNAUTHORIZED_NAME
+		} else if priv > user.maxPriv || priv > session.maxPriv {
+			status = RMCPP_UNAUTHORIZED_ROLE
+		}
+	}
+	if status != RMCPP_NO_ERRORS {
+		fmt.Println("RAKP1 fail: status", status)
+		data[1] = status
+		msg.returnRmcppPayload(nil, RMCPP_PAYLOAD_RAKP2, data[0:8])
+		closeRmcppSession(session)
+		return
+	}
+
+	session.role = role
+	session.username = append([]uint8{}, req[28:28+nameLen]...)
+	session.userid = user.idx
+	session.maxPriv = priv
+	copy(session.rm[:], req[8:24])
+	if _, err := io.ReadFull(rmcppRand, session.rc[:]); err != nil {
+		fmt.Println("RAKP1 fail:", err)
+		closeRmcppSession(session)
+		return
+	}
+
+	// The BMC key, Kg, is null so the SIK is keyed by the user's password
+	h, _ := authHash(session.auth)
+	authCode := hmacSum(h, user.key(),
+		le32(session.remSid), le32(session.sid),
+		session.rm[:], session.rc[:], lanserv.guid[:],
+		[]uint8{role, uint8(nameLen)}, session.username)
+	session.rakpKeys(user.key())
+
+	copy(data[8:24], session.rc[:])
+	copy(data[24:40], lanserv.guid[:])
+	n := 40 + copy(data[40:], authCode)
+	msg.returnRmcppPayload(nil, RMCPP_PAYLOAD_RAKP2, data[0:n])
+}
+
+func rakp3(msg *msgT, req []uint8) {
+	var data [8 + 16]uint8
+
+	if len(req) < 8 {
+		fmt.Println("RAKP3 fail: message too short")
+		return
+	}
+	data[0] = req[0] // message tag
+	session := rmcppStartupSession(binary.LittleEndian.Uint32(req[4:8]))
+	if session == nil || session.username == nil {
+		data[1] = RMCPP_INVALID_SESSION_ID
+		msg.returnRmcppPayload(nil, RMCPP_PAYLOAD_RAKP4, data[0:8])
+		return
+	}
+	binary.LittleEndian.PutUint32(data[4:8], session.remSid)
+
+	if req[1] != RMCPP_NO_ERRORS {
+		fmt.Println("RAKP3 fail: remote console status", req[1])
+		closeRmcppSession(session)
+		return
+	}
+
+	user := &lanserv.users[session.userid]
+	h, icvLen := authHash(session.auth)
+	authCode := hmacSum(h, user.key(), session.rc[:],
+		le32(session.remSid),
+		[]uint8{session.role, uint8(len(session.username))},
+		session.username)
+	if !user.valid || !hmac.Equal(authCode, req[8:]) {
+		fmt.Println("RAKP3 fail: invalid auth code for user",
+			session.userid)
+		data[1] = RMCPP_INVALID_INTEGRITY_CHECK
+		msg.returnRmcppPayload(nil, RMCPP_PAYLOAD_RAKP4, data[0:8])
+		closeRmcppSession(session)
+		return
+	}
+
+	icv := hmacSum(h, session.sik, session.rm[:], le32(session.sid),
+		lanserv.guid[:])
+	n := 8 + copy(data[8:], icv[:icvLen])
+	msg.returnRmcppPayload(nil, RMCPP_PAYLOAD_RAKP4, data[0:n])
+	session.inStartup = false
+	fmt.Printf("\nSession %d activated\n", session.handle)
+}
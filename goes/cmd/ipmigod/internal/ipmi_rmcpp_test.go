@@ -0,0 +1,593 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package internal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// console is the remote console side of an RMCP+ session.
+type console struct {
+	t      *testing.T
+	server *net.UDPConn
+	client *net.UDPConn
+	s      *conSession // nil outside of a session
+	rqSeq  uint8
+}
+
+// conSession packets are encoded and decoded as the remote console,
+// independently of rmcppEncode and rmcppDecode.
+type conSession struct {
+	sid     uint32 // the managed system's
+	integ   uint8
+	conf    uint8
+	h       func() hash.Hash
+	authLen int
+	k1      []uint8
+	k2      []uint8
+	seq     uint32
+	iv      []uint8
+}
+
+func conMac(h func() hash.Hash, key []uint8, data ...[]uint8) []uint8 {
+	m := hmac.New(h, key)
+	for _, b := range data {
+		m.Write(b)
+	}
+	return m.Sum(nil)
+}
+
+// encode the payload in a packet of the session, or outside of one if nil.
+func (s *conSession) encode(ptype uint8, payload []uint8) []uint8 {
+	var sid, seq uint32
+	if s != nil {
+		sid = s.sid
+		if s.conf == RMCPP_CONF_AES_CBC_128 {
+			var pad uint8
+			plain := append([]uint8{}, payload...)
+			for (len(plain)+1)%aes.BlockSize != 0 {
+				pad++
+				plain = append(plain, pad)
+			}
+			plain = append(plain, pad)
+			block, _ := aes.NewCipher(s.k2[:16])
+			payload = append(append([]uint8{}, s.iv...), plain...)
+			cipher.NewCBCEncrypter(block, s.iv).
+				CryptBlocks(payload[16:], payload[16:])
+			ptype |= RMCPP_PAYLOAD_ENCRYPTED
+		}
+		if s.integ != RMCPP_INTEG_NONE {
+			s.seq++
+			seq = s.seq
+			ptype |= RMCPP_PAYLOAD_AUTHENTICATED
+		}
+	}
+	pkt := []uint8{6, 0, 0xff, 7, IPMI_AUTHTYPE_RMCP_PLUS, ptype}
+	pkt = binary.LittleEndian.AppendUint32(pkt, sid)
+	pkt = binary.LittleEndian.AppendUint32(pkt, seq)
+	pkt = binary.LittleEndian.AppendUint16(pkt, uint16(len(payload)))
+	pkt = append(pkt, payload...)
+	if ptype&RMCPP_PAYLOAD_AUTHENTICATED != 0 {
+		var pad uint8
+		for (len(pkt)-4+int(pad)+2)%4 != 0 {
+			pad++
+		}
+		pkt = append(pkt, bytes.Repeat([]uint8{0xff}, int(pad))...)
+		pkt = append(pkt, pad, RMCPP_NEXT_HDR)
+		pkt = append(pkt, conMac(s.h, s.k1, pkt[4:])[:s.authLen]...)
+	}
+	return pkt
+}
+
+// decode the payload of a packet of the session, or outside of one if nil.
+func (s *conSession) decode(pkt []uint8) ([]uint8, bool) {
+	if len(pkt) < 16 || pkt[4] != IPMI_AUTHTYPE_RMCP_PLUS {
+		return nil, false
+	}
+	ptype := pkt[5]
+	n := int(binary.LittleEndian.Uint16(pkt[14:16]))
+	if 16+n > len(pkt) {
+		return nil, false
+	}
+	payload := pkt[16 : 16+n]
+	authenticated := ptype&RMCPP_PAYLOAD_AUTHENTICATED != 0
+	encrypted := ptype&RMCPP_PAYLOAD_ENCRYPTED != 0
+	if s == nil {
+		return payload, !authenticated && !encrypted
+	}
+	if authenticated != (s.integ != RMCPP_INTEG_NONE) ||
+		encrypted != (s.conf != RMCPP_CONF_NONE) {
+		return nil, false
+	}
+	if authenticated {
+		end := len(pkt) - s.authLen
+		if end < 16+n+2 || !hmac.Equal(pkt[end:],
+			conMac(s.h, s.k1, pkt[4:end])[:s.authLen]) {
+			return nil, false
+		}
+	}
+	if encrypted {
+		if len(payload) < 32 || len(payload)%aes.BlockSize != 0 {
+			return nil, false
+		}
+		block, _ := aes.NewCipher(s.k2[:16])
+		plain := make([]uint8, len(payload)-16)
+		cipher.NewCBCDecrypter(block, payload[:16]).
+			CryptBlocks(plain, payload[16:])
+		pad := int(plain[len(plain)-1])
+		if pad+1 > len(plain) {
+			return nil, false
+		}
+		payload = plain[:len(plain)-1-pad]
+	}
+	return payload, true
+}
+
+// testUsers of RMCP+ sessions in place of UsersFile
+const testUsers = `# NAME PASSWORD [user|operator|admin]
+ipmiusr test admin
+`
+
+func newConsole(t *testing.T) *console {
+	c := &console{t: t}
+	ipmiLanInit()
+	f, err := ioutil.TempFile("", "ipmigod-users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(testUsers)
+	f.Close()
+	if err = loadUsers(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	if c.server, err = net.ListenUDP("udp", addr); err != nil {
+		t.Fatal(err)
+	}
+	if c.client, err = net.ListenUDP("udp", addr); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func (c *console) close() {
+	c.server.Close()
+	c.client.Close()
+}
+
+// exchange the packet with the handler, returning its response, if any.
+func (c *console) exchange(pkt []uint8) []uint8 {
+	msg := new(msgT)
+	msg.dataLen = uint(copy(msg.data[:], pkt))
+	msg.conn = c.server
+	msg.remoteAddr = c.client.LocalAddr().(*net.UDPAddr)
+	msg.ipmiHandleMsg()
+
+	var rsp [MAX_MSG_RETURN_DATA]uint8
+	c.client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	n, _, err := c.client.ReadFromUDP(rsp[:])
+	if err != nil {
+		return nil
+	}
+	return rsp[:n]
+}
+
+// payload exchange of the type, returning the response payload.
+func (c *console) payload(s *conSession, ptype uint8, req []uint8) []uint8 {
+	rsp := c.exchange(s.encode(ptype, req))
+	if rsp == nil {
+		return nil
+	}
+	payload, ok := s.decode(rsp)
+	if !ok {
+		c.t.Fatalf("invalid response % x", rsp)
+	}
+	return payload
+}
+
+// request message of the command from the remote console.
+func request(rqSeq, netfn, cmd uint8, data ...uint8) []uint8 {
+	req := []uint8{0x20, netfn << 2, 0, 0x81, rqSeq << 2, cmd}
+	req[2] = uint8(ipmiChecksum(req, 2, 0))
+	req = append(req, data...)
+	return append(req, uint8(ipmiChecksum(req[3:], len(req)-3, 0)))
+}
+
+// cmd request in the session returning the completion code and data.
+func (c *console) cmd(netfn, cmd uint8, data ...uint8) (uint8, []uint8) {
+	req := request(c.rqSeq, netfn, cmd, data...)
+	c.rqSeq++
+	rsp := c.payload(c.s, RMCPP_PAYLOAD_IPMI, req)
+	if len(rsp) < 8 || rsp[5] != cmd {
+		c.t.Fatalf("invalid response % x", rsp)
+	}
+	return rsp[6], rsp[7 : len(rsp)-1]
+}
+
+func openSession(auth, integ, conf uint8) []uint8 {
+	req := make([]uint8, 32)
+	req[0] = 0x42 // tag
+	req[1] = IPMI_PRIVILEGE_ADMIN
+	copy(req[4:8], []uint8{0x78, 0x56, 0x34, 0x12})
+	for i, alg := range []uint8{auth, integ, conf} {
+		req[8+8*i] = uint8(i)
+		req[11+8*i] = 8
+		req[12+8*i] = alg
+	}
+	return req
+}
+
+// login with the cipher suite, user and password, returning the RAKP4
+// status.
+func (c *console) login(suite int, name, pw string) uint8 {
+	var h func() hash.Hash
+	cs := cipherSuites[suite]
+	icvLen := 12
+	if cs.auth == RMCPP_AUTH_RAKP_HMAC_SHA1 {
+		h = sha1.New
+	} else {
+		h, icvLen = sha256.New, 16
+	}
+	mac := func(key []uint8, data ...[]uint8) []uint8 {
+		return conMac(h, key, data...)
+	}
+
+	c.s = nil
+	rsp := c.payload(nil, RMCPP_PAYLOAD_OPEN_SESSION_REQ,
+		openSession(cs.auth, cs.integ, cs.conf))
+	if len(rsp) != 36 || rsp[1] != RMCPP_NO_ERRORS {
+		c.t.Fatalf("open session: % x", rsp)
+	}
+	conSid := []uint8{0x78, 0x56, 0x34, 0x12}
+	bmcSid := append([]uint8{}, rsp[8:12]...)
+
+	rm := bytes.Repeat([]uint8{0x5a}, 16)
+	role := []uint8{0x10 | IPMI_PRIVILEGE_ADMIN, uint8(len(name))}
+	req := make([]uint8, 28)
+	copy(req[4:8], bmcSid)
+	copy(req[8:24], rm)
+	req[24] = role[0]
+	req[27] = role[1]
+	req = append(req, name...)
+	rsp = c.payload(nil, RMCPP_PAYLOAD_RAKP1, req)
+	if len(rsp) < 40 || rsp[1] != RMCPP_NO_ERRORS {
+		c.t.Fatalf("RAKP2: % x", rsp)
+	}
+	rc, guid := rsp[8:24], rsp[24:40]
+	want := mac([]uint8(pw), conSid, bmcSid, rm, rc, guid, role,
+		[]uint8(name))
+	if pw == "test" && !bytes.Equal(rsp[40:], want) {
+		c.t.Fatalf("RAKP2 auth code % x, want % x", rsp[40:], want)
+	}
+
+	req = make([]uint8, 8)
+	copy(req[4:8], bmcSid)
+	req = append(req, mac([]uint8(pw), rc, conSid, role,
+		[]uint8(name))...)
+	rsp = c.payload(nil, RMCPP_PAYLOAD_RAKP3, req)
+	if len(rsp) < 8 {
+		c.t.Fatalf("RAKP4: % x", rsp)
+	}
+	if rsp[1] != RMCPP_NO_ERRORS {
+		return rsp[1]
+	}
+	sik := mac([]uint8(pw), rm, rc, role, []uint8(name))
+	icv := mac(sik, rm, bmcSid, guid)[:icvLen]
+	if !bytes.Equal(rsp[8:], icv) {
+		c.t.Fatalf("RAKP4 ICV % x, want % x", rsp[8:], icv)
+	}
+
+	c.s = &conSession{
+		sid:     binary.LittleEndian.Uint32(bmcSid),
+		integ:   cs.integ,
+		conf:    cs.conf,
+		h:       h,
+		authLen: icvLen,
+		k1:      mac(sik, bytes.Repeat([]uint8{1}, h().Size())),
+		k2:      mac(sik, bytes.Repeat([]uint8{2}, h().Size())),
+		iv:      bytes.Repeat([]uint8{0xc5}, aes.BlockSize),
+	}
+	return rsp[1]
+}
+
+func TestRmcppSession(t *testing.T) {
+	c := newConsole(t)
+	defer c.close()
+
+	for _, suite := range []int{3, 6} {
+		active := lanserv.activeSessions
+		if status := c.login(suite, "ipmiusr", "test"); status != 0 {
+			t.Fatalf("suite %d: status %#x", suite, status)
+		}
+		cc, _ := c.cmd(STORAGE_NETFN, WRITE_FRU_DATA_CMD, 0, 0, 0, 1)
+		if cc != IPMI_INSUFFICIENT_PRIVILEGE_CC {
+			t.Errorf("suite %d: user write fru %#x", suite, cc)
+		}
+		cc, _ = c.cmd(STORAGE_NETFN, DELETE_SEL_ENTRY_CMD, 0, 0, 1, 0)
+		if cc != IPMI_INSUFFICIENT_PRIVILEGE_CC {
+			t.Errorf("suite %d: user delete sel %#x", suite, cc)
+		}
+		cc, _ = c.cmd(APP_NETFN, CLEAR_MSG_FLAGS_CMD, 0xff)
+		if cc != IPMI_INVALID_CMD_CC {
+			t.Errorf("suite %d: local clear msg flags %#x", suite, cc)
+		}
+		cc, _ = c.cmd(CHASSIS_NETFN, GET_POH_COUNTER_CMD)
+		if cc != IPMI_INVALID_CMD_CC {
+			t.Errorf("suite %d: unsupported poh counter %#x", suite, cc)
+		}
+		cc, data := c.cmd(APP_NETFN, SET_SESSION_PRIVILEGE_CMD,
+			IPMI_PRIVILEGE_ADMIN)
+		if cc != 0 || data[0] != IPMI_PRIVILEGE_ADMIN {
+			t.Errorf("suite %d: set priv %#x % x", suite, cc, data)
+		}
+		cc, data = c.cmd(APP_NETFN, GET_SYSTEM_GUID_CMD)
+		if cc != 0 || !bytes.Equal(data, lanserv.guid[:]) {
+			t.Errorf("suite %d: guid %#x % x", suite, cc, data)
+		}
+
+		// replayed packets are dropped
+		req := request(0, APP_NETFN, GET_SYSTEM_GUID_CMD)
+		pkt := c.s.encode(RMCPP_PAYLOAD_IPMI, req)
+		if c.exchange(pkt) == nil || c.exchange(pkt) != nil {
+			t.Errorf("suite %d: replay", suite)
+		}
+
+		cc, _ = c.cmd(APP_NETFN, CLOSE_SESSION_CMD,
+			binary.LittleEndian.AppendUint32(nil, c.s.sid)...)
+		if cc != 0 || lanserv.activeSessions != active {
+			t.Errorf("suite %d: close %#x", suite, cc)
+		}
+	}
+}
+
+func TestRmcppReject(t *testing.T) {
+	c := newConsole(t)
+	defer c.close()
+	active := lanserv.activeSessions
+
+	rsp := c.payload(nil, RMCPP_PAYLOAD_OPEN_SESSION_REQ,
+		openSession(0, 0, 0))
+	if len(rsp) < 2 || rsp[1] != RMCPP_NO_CIPHER_SUITE_MATCH {
+		t.Errorf("cipher suite 0: % x", rsp)
+	}
+	suite1 := openSession(RMCPP_AUTH_RAKP_HMAC_SHA1, RMCPP_INTEG_NONE,
+		RMCPP_CONF_NONE)
+	rsp = c.payload(nil, RMCPP_PAYLOAD_OPEN_SESSION_REQ, suite1)
+	if len(rsp) < 2 || rsp[1] != RMCPP_NO_CIPHER_SUITE_MATCH {
+		t.Errorf("cipher suite 1: % x", rsp)
+	}
+	AllowCipherSuiteNoIntegrity = true
+	rsp = c.payload(nil, RMCPP_PAYLOAD_OPEN_SESSION_REQ, suite1)
+	AllowCipherSuiteNoIntegrity = false
+	if len(rsp) != 36 || rsp[1] != RMCPP_NO_ERRORS {
+		t.Errorf("allowed cipher suite 1: % x", rsp)
+	} else {
+		closeRmcppSession(sidToSession(
+			binary.LittleEndian.Uint32(rsp[8:12])))
+	}
+	if status := c.login(3, "ipmiusr", "wrong"); status !=
+		RMCPP_INVALID_INTEGRITY_CHECK {
+		t.Errorf("wrong password: status %#x", status)
+	}
+	if lanserv.activeSessions != active {
+		t.Error("sessions leaked", lanserv.activeSessions)
+	}
+
+	// the built-in users may not authenticate RMCP+ sessions
+	ipmiLanInit()
+	rsp = c.payload(nil, RMCPP_PAYLOAD_OPEN_SESSION_REQ,
+		openSession(RMCPP_AUTH_RAKP_HMAC_SHA1,
+			RMCPP_INTEG_HMAC_SHA1_96, RMCPP_CONF_AES_CBC_128))
+	if len(rsp) < 2 || rsp[1] != RMCPP_UNAUTHORIZED_NAME {
+		t.Errorf("without users file: % x", rsp)
+	}
+
+	// outside of a session, only the commands to start one
+	c.s = nil
+	cc, data := c.cmd(APP_NETFN, GET_CHANNEL_CIPHER_SUITES_CMD,
+		0xe, 0, 0x80)
+	if cc != 0 || len(data) != 1+16 || data[1] != 0xc0 || data[2] != 2 {
+		t.Errorf("cipher suites %#x % x", cc, data)
+	}
+	cc, data = c.cmd(APP_NETFN, GET_CHANNEL_CIPHER_SUITES_CMD,
+		0xe, 0, 0x81)
+	if cc != 0 || len(data) != 1+4 || data[1] != 17 {
+		t.Errorf("cipher suites %#x % x", cc, data)
+	}
+	req := request(0, STORAGE_NETFN, WRITE_FRU_DATA_CMD, 0, 0, 0, 1)
+	if rsp := c.exchange(c.s.encode(RMCPP_PAYLOAD_IPMI, req)); rsp != nil {
+		t.Errorf("sessionless write fru: % x", rsp)
+	}
+}
+
+// rmcppVector of a cipher suite 3 session of ipmiusr, from the remote
+// console's open session request through Get System GUID, with the
+// managed system's random session id, Rc and IV. The RAKP auth codes,
+// integrity and AES-CBC-128 of the packets were computed with the openssl
+// command line rather than this package.
+var rmcppVector = struct {
+	rand string
+	guid string
+	pkts []string // of each request and response
+}{
+	rand: "a1b2c3d4" +
+		"c0c1c2c3c4c5c6c7c8c9cacbcccdcecf" +
+		"e0e1e2e3e4e5e6e7e8e9eaebecedeeef",
+	guid: "0123456789abcdeffedcba9876543210",
+	pkts: []string{
+		// open session request
+		"0600ff070610000000000000000020004204000078563412" +
+			"000000080100000001000008010000000200000801000000",
+		// open session response
+		"0600ff070611000000000000000024004200040078563412" +
+			"8250d9610000000801000000010000080100000002000008" +
+			"01000000",
+		// RAKP 1
+		"0600ff07061200000000000000002300430000008250d961" +
+			"101112131415161718191a1b1c1d1e1f1400000769706d69" +
+			"757372",
+		// RAKP 2
+		"0600ff07061300000000000000003c004300000078563412" +
+			"c0c1c2c3c4c5c6c7c8c9cacbcccdcecf0123456789abcdef" +
+			"fedcba98765432100dd3086a7a9ebe8d392d2bcd4e2dc844" +
+			"b41ba85f",
+		// RAKP 3
+		"0600ff07061400000000000000001c00440000008250d961" +
+			"c58ddfd07d900ed4a596056b5b75006910e0e481",
+		// RAKP 4
+		"0600ff070615000000000000000014004400000078563412" +
+			"28bfea7aeaf53a308f6c0805",
+		// Get System GUID request
+		"0600ff0706c08250d961010000002000f0f1f2f3f4f5f6f7" +
+			"f8f9fafbfcfdfeff1c73b6451897b6df7f9474e03164a548" +
+			"ffff020763e5e9ae9fb02e34a1be5272",
+		// Get System GUID response
+		"0600ff0706c078563412010000003000e0e1e2e3e4e5e6e7" +
+			"e8e9eaebecedeeeff6ff12a8aff5dbe6d149b012cc91bfdb" +
+			"032203287aad5a728ae8ee07e7b41590ffff0207d108af5e" +
+			"59fc1b89cca66302",
+	},
+}
+
+func TestRmcppVector(t *testing.T) {
+	c := newConsole(t)
+	defer c.close()
+	defer func(r io.Reader) { rmcppRand = r }(rmcppRand)
+	b, _ := hex.DecodeString(rmcppVector.rand)
+	rmcppRand = bytes.NewReader(b)
+	b, _ = hex.DecodeString(rmcppVector.guid)
+	copy(lanserv.guid[:], b)
+	active := lanserv.activeSessions
+
+	pkts := rmcppVector.pkts
+	for i := 0; i < len(pkts); i += 2 {
+		req, _ := hex.DecodeString(pkts[i])
+		want, _ := hex.DecodeString(pkts[i+1])
+		if rsp := c.exchange(req); !bytes.Equal(rsp, want) {
+			t.Fatalf("%d: % x\nwant % x", i/2, rsp, want)
+		}
+	}
+	if session := sidToSession(0x61d95082); session == nil {
+		t.Error("no session")
+	} else {
+		closeRmcppSession(session)
+	}
+	if lanserv.activeSessions != active {
+		t.Error("sessions leaked", lanserv.activeSessions)
+	}
+}
+
+func TestRmcppIdle(t *testing.T) {
+	c := newConsole(t)
+	defer c.close()
+	active := lanserv.activeSessions
+
+	if status := c.login(3, "ipmiusr", "test"); status != 0 {
+		t.Fatalf("status %#x", status)
+	}
+	idle := c.s
+	sidToSession(idle.sid).lastTime = time.Now().Add(-time.Minute)
+
+	// open session reclaims the idle session
+	rsp := c.payload(nil, RMCPP_PAYLOAD_OPEN_SESSION_REQ,
+		openSession(RMCPP_AUTH_RAKP_HMAC_SHA1,
+			RMCPP_INTEG_HMAC_SHA1_96, RMCPP_CONF_AES_CBC_128))
+	if len(rsp) != 36 || rsp[1] != RMCPP_NO_ERRORS {
+		t.Fatalf("open session: % x", rsp)
+	}
+	defer closeRmcppSession(sidToSession(
+		binary.LittleEndian.Uint32(rsp[8:12])))
+	if lanserv.activeSessions != active+1 {
+		t.Error("idle session wasn't closed", lanserv.activeSessions)
+	}
+	req := request(0, APP_NETFN, GET_SYSTEM_GUID_CMD)
+	if rsp = c.exchange(idle.encode(RMCPP_PAYLOAD_IPMI, req)); rsp != nil {
+		t.Errorf("idle session: % x", rsp)
+	}
+}
+
+func TestRmcppCmdPrivs(t *testing.T) {
+	for cmd := range rmcppCmdPrivs {
+		var found bool
+		switch netfn, c := uint8(cmd>>8), uint8(cmd); netfn {
+		case CHASSIS_NETFN:
+			_, found = chassisProcessors[c]
+		case SENSOR_EVENT_NETFN:
+			_, found = sensorProcessors[c]
+		case APP_NETFN:
+			_, found = appProcessors[c]
+		case STORAGE_NETFN:
+			_, found = storageProcessors[c]
+		case TRANSPORT_NETFN:
+			_, found = transportProcessors[c]
+		}
+		if !found {
+			t.Errorf("%#04x: no processor", cmd)
+		}
+	}
+}
+
+func TestLoadGuid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipmigod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "ipmigod", "guid")
+
+	lanserv.guid = [16]uint8{}
+	if err = loadGuid(fn); err != nil {
+		t.Fatal(err)
+	}
+	guid := lanserv.guid
+	if guid == [16]uint8{} {
+		t.Error("zero guid")
+	}
+	lanserv.guid = [16]uint8{}
+	if err = loadGuid(fn); err != nil || lanserv.guid != guid {
+		t.Errorf("reloaded guid % x, want % x: %v", lanserv.guid, guid,
+			err)
+	}
+}
+
+func TestAcceptSeq(t *testing.T) {
+	var s sessionT
+	for _, x := range []struct {
+		seq    uint32
+		accept bool
+	}{
+		{0, false},
+		{1, true},
+		{1, false},
+		{5, true},
+		{3, true},
+		{3, false},
+		{2, true},
+		{50, false},
+		{37, true},
+		{5, false},
+		{6, true},
+	} {
+		if s.acceptSeq(x.seq) != x.accept {
+			t.Errorf("%d: want %v", x.seq, x.accept)
+		}
+	}
+}
@@ -5,10 +5,13 @@
 package kexec
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -17,6 +20,7 @@ import (
 	"github.com/platinasystems/go/internal/flags"
 	"github.com/platinasystems/go/internal/kexec"
 	"github.com/platinasystems/go/internal/parms"
+	"github.com/platinasystems/redis"
 )
 
 type Command struct{}
@@ -31,9 +35,40 @@ func (Command) Apropos() lang.Alt {
 	}
 }
 
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Load a new kernel, from a kernel and initramfs or a Flattened Image
+	Tree, for later execution.
+
+	The device tree of a FIT configuration is fixed up before loading
+	with: the -c command line as /chosen/bootargs; the location of the
+	configuration's ramdisk as /chosen/linux,initrd-start and end;
+	random /chosen/kaslr-seed and rng-seed; and, from the eeprom's
+	base MAC address, the local-mac-address of each ethernetN alias.
+
+OPTIONS
+	-k KERNEL	kernel file
+	-i INITRAMFS	initramfs file, required with -k
+	-c CMDLINE	kernel command line; a leading '+' appends to the
+			current command line
+	-l FIT		Flattened Image Tree (FIT) file
+	-x CONFIG	FIT configuration, default is the FIT's default
+	-keyring FILE	PEM encoded public keys, in addition to those compiled
+			in, to verify the FIT's signed configurations
+	-signed		refuse a FIT configuration without a verified signature
+	-memory		replace the FIT device tree's /memory with the System
+			RAM of /proc/iomem
+	-e		execute the loaded kernel
+	-f		execute the loaded kernel without preparation`,
+	}
+}
+
 func (Command) Main(args ...string) error {
-	flag, args := flags.New(args, "-e", "-f")
-	parm, args := parms.New(args, "-c", "-i", "-k", "-l", "-x")
+	flag, args := flags.New(args, "-e", "-f", "-signed", "-memory")
+	parm, args := parms.New(args, "-c", "-i", "-k", "-l", "-x",
+		"-keyring")
 
 	if len(args) > 0 {
 		return fmt.Errorf("%v: unexpected", args)
@@ -57,7 +92,17 @@ func (Command) Main(args ...string) error {
 	}
 
 	if image := parm.ByName["-l"]; len(image) > 0 {
-		err = loadFit(image, parm.ByName["-x"])
+		fixups := fit.Fixups{
+			Bootargs: parm.ByName["-c"],
+			Memory:   flag.ByName["-memory"],
+			Seeds:    true,
+			MACs:     eepromMACs(),
+		}
+		if len(fixups.Bootargs) > 0 {
+			fixups.Bootargs = cmdline
+		}
+		err = loadFit(image, parm.ByName["-x"], parm.ByName["-keyring"],
+			flag.ByName["-signed"], fixups)
 		if err != nil {
 			return err
 		}
@@ -79,20 +124,63 @@ func (Command) Main(args ...string) error {
 	return err
 }
 
-func loadFit(image, x string) error {
+func loadFit(image, x, keyring string, signed bool, fixups fit.Fixups) error {
 	b, err := ioutil.ReadFile(image)
 	if err != nil {
 		return err
 	}
 
-	fit := fit.Parse(b)
+	f := fit.Parse(b)
+
+	if len(keyring) > 0 {
+		keys, err := fit.ReadKeyring(keyring)
+		if err != nil {
+			return err
+		}
+		f.Verify(append(keys, fit.DefaultKeyring...))
+	}
+	if signed {
+		f.Policy = fit.RequireSigned
+	}
+	f.Fixups = fixups
 
 	if len(x) == 0 {
-		x = fit.DefaultConfig
+		x = f.DefaultConfig
+	}
+	config, found := f.Configs[x]
+	if !found {
+		return fmt.Errorf("%s: not found", x)
 	}
-	config := fit.Configs[x]
 
-	return fit.KexecLoadConfig(config, 0x0)
+	return f.KexecLoadConfig(config, 0x0)
+}
+
+// eepromMACs returns the eeprom's published range of MAC addresses, if any.
+func eepromMACs() []net.HardwareAddr {
+	s, err := redis.Hget(redis.DefaultHash, "eeprom.BaseEthernetAddress")
+	if err != nil {
+		return nil
+	}
+	base, err := net.ParseMAC(s)
+	if err != nil || len(base) != 6 {
+		return nil
+	}
+	n := 1
+	s, err = redis.Hget(redis.DefaultHash, "eeprom.NEthernetAddress")
+	if err == nil {
+		if i, err := strconv.Atoi(s); err == nil && i > 0 {
+			n = i
+		}
+	}
+	b := make([]byte, 8)
+	copy(b[2:], base)
+	u := binary.BigEndian.Uint64(b)
+	macs := make([]net.HardwareAddr, n)
+	for i := range macs {
+		binary.BigEndian.PutUint64(b, u+uint64(i))
+		macs[i] = net.HardwareAddr(append([]byte{}, b[2:]...))
+	}
+	return macs
 }
 
 func loadKernel(kernel, initramfs, cmdline string) error {
@@ -0,0 +1,115 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package lsblk
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/partitions"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "lsblk" }
+
+func (Command) Usage() string { return "lsblk [-b] [-f] [DEVICE]..." }
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "list block devices",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	List the given or all disks, each followed by its partitions.
+
+OPTIONS
+	-b	print sizes in bytes
+	-f	list filesystem type, label, UUID and PARTUUID`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-b", "-f")
+
+	devs, err := partitions.BlockDevices()
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		var sel []*partitions.BlockDevice
+		for _, arg := range args {
+			d, err := partitions.Find(devs, arg)
+			if err != nil {
+				return err
+			}
+			sel = append(sel, d)
+			for _, p := range devs {
+				if d.Type == "disk" && p.Parent == d.Name {
+					sel = append(sel, p)
+				}
+			}
+		}
+		devs = sel
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	defer w.Flush()
+	if flag.ByName["-f"] {
+		fmt.Fprintln(w, "NAME\tFSTYPE\tLABEL\tUUID\tPARTUUID\tMOUNTPOINT")
+	} else {
+		fmt.Fprintln(w, "NAME\tSIZE\tRO\tRM\tTYPE\tMOUNTPOINT")
+	}
+	for i, d := range devs {
+		name := d.Name
+		if len(d.Parent) > 0 {
+			name = "├─" + name
+			if i+1 == len(devs) || devs[i+1].Parent != d.Parent {
+				name = "└─" + d.Name
+			}
+		}
+		if flag.ByName["-f"] {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name,
+				d.FsType, d.Label, d.UUID, d.PartUUID,
+				d.MountPoint)
+			continue
+		}
+		size := humanize(d.Size)
+		if flag.ByName["-b"] {
+			size = fmt.Sprint(d.Size)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, size,
+			bit(d.ReadOnly), bit(d.Removable), d.Type, d.MountPoint)
+	}
+	return nil
+}
+
+func bit(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// humanize the size with a binary unit suffix, as lsblk
+func humanize(n int64) string {
+	const units = "BKMGTPE"
+	f := float64(n)
+	i := 0
+	for ; f >= 1024 && i < len(units)-1; i++ {
+		f /= 1024
+	}
+	s := fmt.Sprintf("%.1f", f)
+	s = strings.TrimSuffix(s, ".0")
+	return s + units[i:i+1]
+}
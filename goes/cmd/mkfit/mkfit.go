@@ -0,0 +1,101 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package mkfit
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/fit"
+	"github.com/platinasystems/go/internal/parms"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "mkfit" }
+
+func (Command) Usage() string { return "mkfit [-k KEYDIR] ITS FIT" }
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "make a Flattened Image Tree",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Make a Flattened Image Tree (FIT) from an image tree source (ITS),
+	like "mkimage -f ITS FIT". The source is the device tree source
+	subset of:
+
+		/dts-v1/;
+		/ {
+			description = "...";
+			images {
+				kernel-1 {
+					description = "...";
+					data = /incbin/("vmlinuz");
+					type = "kernel";
+					arch = "arm64";
+					os = "linux";
+					compression = "none";
+					load = <0x80080000>;
+					entry = <0x80080000>;
+					hash-1 {
+						algo = "sha256";
+					};
+				};
+				...
+			};
+			configurations {
+				default = "conf-1";
+				conf-1 {
+					kernel = "kernel-1";
+					fdt = "fdt-1";
+					ramdisk = "ramdisk-1";
+					signature-1 {
+						algo = "sha256,rsa2048";
+						key-name-hint = "dev";
+					};
+				};
+			};
+		};
+
+	The value of each hash node is set with its algo of: crc32, md5,
+	sha1, sha256, sha384 or sha512.
+
+OPTIONS
+	-k KEYDIR
+		Sign each configuration with the PEM encoded private key of
+		KEYDIR/HINT.key, where HINT is the signature's key-name-hint.
+		Signatures are one of [sha1|sha256|sha384|sha512], followed
+		by one of rsa2048, rsa3072, rsa4096 or ecdsa256.`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-k")
+	switch len(args) {
+	case 0:
+		return fmt.Errorf("ITS: missing")
+	case 1:
+		return fmt.Errorf("FIT: missing")
+	case 2:
+	default:
+		return fmt.Errorf("%v: unexpected", args[2:])
+	}
+	t, err := fit.ReadITS(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := fit.Make(t, parm.ByName["-k"])
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(args[1], b, 0644)
+}
@@ -8,11 +8,14 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"syscall"
 
 	"github.com/platinasystems/go/goes/lang"
 	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/fstab"
+	"github.com/platinasystems/go/internal/loop"
 	"github.com/platinasystems/go/internal/parms"
 	"github.com/platinasystems/go/internal/partitions"
 )
@@ -37,6 +40,18 @@ func (Command) Man() lang.Alt {
 DESCRIPTION
 	Mount a filesystem on a target directory.
 
+	With -a, mount the entries of /etc/fstab that aren't noauto or
+	already mounted. Entries are mounted after those of their parent
+	directories, bind sources, overlay directories and
+	x-systemd.requires-mounts-for paths; with -F, independent entries
+	are mounted in parallel. A failed nofail entry doesn't fail -a.
+	Given just a DEVICE or DIRECTORY, mount its /etc/fstab entry.
+
+	DEVICE may be a UUID=, LABEL=, PARTUUID= or PARTLABEL=
+	specifier. A regular file, or a DEVICE with the loop option, is
+	mounted through a loop device with any offset and sizelimit
+	options. An overlay mount makes its upperdir and workdir.
+
 OPTIONS
 	--fake
 	-v		verbose
@@ -49,6 +64,8 @@ OPTIONS
 			after the Linux device name.
 
 	Where MATCH, FSTYPE and FSOPT are comma separated lists.
+	FSOPT may be any fstab(5) option, e.g. ro, noexec, bind or
+	lowerdir=DIR; those unknown to mount are passed to the filesystem.
 
 FSTYPE
 	May be anything listed in /proc/filesystems; for example:
@@ -165,7 +182,7 @@ func (Command) Main(args ...string) error {
 			}
 		case 2:
 			r := fs.mountone(fs.parms.ByName["-t"], args[0],
-				args[1])
+				args[1], nil)
 			r.ShowResult()
 			err = r.err
 		default:
@@ -179,13 +196,6 @@ func (Command) Main(args ...string) error {
 const MS_NOUSER uintptr = (1 << 31)
 const procFilesystems = "/proc/filesystems"
 
-type fstabEntry struct {
-	fsSpec  string
-	fsFile  string
-	fsType  string
-	mntOpts string
-}
-
 type fsType struct {
 	name  string
 	nodev bool
@@ -268,48 +278,102 @@ func (r *MountResult) ShowResult() {
 	}
 }
 
-func pollMountResults(c chan *MountResult) (i int) {
+func pollMountResults(c chan *MountResult, seen func(*MountResult)) (i int) {
 	for {
 		select {
 		case r := <-c:
 			r.ShowResult()
+			if seen != nil {
+				seen(r)
+			}
 			i++
 		default:
 			return i
 		}
 	}
-	return i
 }
 
-func flushMountResults(c chan *MountResult, complete, count int) {
+func flushMountResults(c chan *MountResult, complete, count int,
+	seen func(*MountResult)) {
 	for i := complete; i < count; i++ {
 		r := <-c
 		r.ShowResult()
+		if seen != nil {
+			seen(r)
+		}
 	}
 }
 
 func (fs *filesystems) mountall() error {
-	fstab, err := loadFstab()
+	entries, err := fstab.Read(fstab.File)
 	if err != nil {
 		return err
 	}
-
-	count := len(fstab)
-	cap := 1
-	if fs.flags.ByName["-F"] {
-		cap = count
+	levels, err := fstab.Order(entries)
+	if err != nil {
+		return err
 	}
+	mounts, _ := fstab.ReadMountinfo(fstab.MountinfoFile)
+	match := fstab.Split(fs.parms.ByName["-match"])
 
-	complete := 0
-	rchan := make(chan *MountResult, cap)
-
-	for _, x := range fstab {
-		go fs.goMountone(x.fsType, x.fsSpec, x.fsFile, rchan)
-		complete += pollMountResults(rchan)
+	var firstErr error
+	for _, level := range levels {
+		var todo []fstab.Entry
+		for _, x := range level {
+			if x.NoAuto() || !matchType(match, x.Type) ||
+				fstab.Mounted(mounts, x.File) != nil {
+				continue
+			}
+			todo = append(todo, x)
+		}
+		// each level waits for those it depends on
+		count := len(todo)
+		cap := 1
+		if fs.flags.ByName["-F"] {
+			cap = count
+		}
+		complete := 0
+		rchan := make(chan *MountResult, cap)
+		seen := func(r *MountResult) {
+			if r.err == nil || firstErr != nil {
+				return
+			}
+			for _, x := range todo {
+				if x.File == r.dir && !x.NoFail() {
+					firstErr = r.err
+				}
+			}
+		}
+		for _, x := range todo {
+			go fs.goMountone(x.Type, x.Spec, x.File, x.Options,
+				rchan)
+			complete += pollMountResults(rchan, seen)
+		}
+		flushMountResults(rchan, complete, count, seen)
 	}
+	return firstErr
+}
 
-	flushMountResults(rchan, complete, count)
-	return nil
+// matchType returns true if the list is empty or has the type; a "no"
+// prefix on the first excludes all of the listed types, as with mount -t.
+func matchType(list []string, t string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	if strings.HasPrefix(list[0], "no") {
+		for _, x := range list {
+			if strings.TrimPrefix(x, "no") == t {
+				return false
+			}
+		}
+		return true
+	}
+	for _, x := range list {
+		if x == t {
+			return true
+		}
+	}
+	return false
 }
 
 func (fs *filesystems) mountprobe(mountpoint string) error {
@@ -344,55 +408,40 @@ func (fs *filesystems) mountprobe(mountpoint string) error {
 			}
 		}
 		go fs.goMountone(fs.parms.ByName["-t"], "/dev/"+fileName, mp,
-			rchan)
-		complete += pollMountResults(rchan)
+			nil, rchan)
+		complete += pollMountResults(rchan, nil)
 		lines++
 	}
 
-	flushMountResults(rchan, complete, lines)
+	flushMountResults(rchan, complete, lines, nil)
 	return nil
 }
 
 func (fs *filesystems) fstab(name string) error {
-	fstab, err := loadFstab()
+	entries, err := fstab.Read(fstab.File)
 	if err != nil {
 		return err
 	}
-	for _, x := range fstab {
-		if name == x.fsSpec || name == x.fsFile {
-			r := fs.mountone(x.fsType, x.fsSpec, x.fsFile)
+	for _, x := range entries {
+		if name == x.Spec || name == x.File {
+			t := x.Type
+			if ct := fs.parms.ByName["-t"]; ct != "auto" {
+				t = ct
+			}
+			r := fs.mountone(t, x.Spec, x.File, x.Options)
 			r.ShowResult()
 			return r.err
 		}
 	}
-	return nil
-}
-
-func loadFstab() ([]fstabEntry, error) {
-	f, err := os.Open("/etc/fstab")
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	scanner := bufio.NewScanner(f)
-	var fstab []fstabEntry
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Index(line, "#") < 0 {
-			fields := strings.Fields(line)
-			fstab = append(fstab, fstabEntry{
-				fsSpec:  fields[0],
-				fsFile:  fields[1],
-				fsType:  fields[2],
-				mntOpts: fields[3],
-			})
-		}
-	}
-	return fstab, scanner.Err()
+	return fmt.Errorf("%s: not found in %s", name, fstab.File)
 }
 
-func (fs *filesystems) mountone(t, dev, dir string) *MountResult {
-	var flags uintptr
+// mountone mounts the device, UUID=, LABEL=, PARTUUID= or PARTLABEL=
+// specifier, bind source, or image file with the fstab style options
+// followed by those of -o.
+func (fs *filesystems) mountone(t, dev, dir string, opts []string) *MountResult {
+	opts = append(opts, fstab.Split(fs.parms.ByName["-o"])...)
+	flags, data := fstab.Flags(opts)
 	if fs.flags.ByName["-defaults"] {
 		//  rw, suid, dev, exec, auto, nouser, async
 		flags &^= syscall.MS_RDONLY
@@ -414,6 +463,15 @@ func (fs *filesystems) mountone(t, dev, dir string) *MountResult {
 			}
 		}
 	}
+
+	if partitions.IsSpecifier(dev) {
+		path, err := partitions.Resolve(dev)
+		if err != nil {
+			return &MountResult{err, dev, t, dir, fs.flags}
+		}
+		dev = path
+	}
+
 	if fs.flags.ByName["--fake"] {
 		return &MountResult{nil, dev, t, dir, fs.flags}
 	}
@@ -422,8 +480,29 @@ func (fs *filesystems) mountone(t, dev, dir string) *MountResult {
 	if t != "auto" {
 		nodev = fs.isNoDev[t]
 	}
+	bind := flags&(syscall.MS_BIND|syscall.MS_MOVE) != 0 ||
+		flags&syscall.MS_REMOUNT != 0
+	if bind {
+		nodev = true
+		if t == "auto" {
+			t = "none"
+		}
+	}
+
+	if t == "overlay" {
+		if err := overlayDirs(opts); err != nil {
+			return &MountResult{err, dev, t, dir, fs.flags}
+		}
+	}
 
 	if !nodev {
+		if lf, err := fs.loop(dev, opts, flags); err != nil {
+			return &MountResult{err, dev, t, dir, fs.flags}
+		} else if lf != nil {
+			// the loop device is detached after its unmount
+			defer lf.Close()
+			dev = lf.Name()
+		}
 		sb, err := partitions.ReadSuperBlock(dev)
 		if err != nil {
 			return &MountResult{err, dev, t, dir, fs.flags}
@@ -440,14 +519,14 @@ func (fs *filesystems) mountone(t, dev, dir string) *MountResult {
 	}
 
 	var err error
-	err = syscall.Mount(dev, dir, t, flags, fs.parms.ByName["-o"])
+	err = syscall.Mount(dev, dir, t, flags, data)
 	if err == nil {
 		return &MountResult{err, dev, t, dir, fs.flags}
 	}
 	if err == syscall.EACCES && !fs.flags.ByName["-read-write"] &&
 		flags&syscall.MS_RDONLY == 0 {
 		err = syscall.Mount(dev, dir, t, flags|syscall.MS_RDONLY,
-			fs.parms.ByName["-o"])
+			data)
 		if err == nil {
 			return &MountResult{err, dev, t, dir, fs.flags}
 		}
@@ -456,8 +535,60 @@ func (fs *filesystems) mountone(t, dev, dir string) *MountResult {
 	return &MountResult{err, dev, t, dir, fs.flags}
 }
 
-func (fs *filesystems) goMountone(t, dev, dir string, c chan *MountResult) {
-	c <- fs.mountone(t, dev, dir)
+// loop attaches a loop device to the image file with the loop option or any
+// regular file.
+func (fs *filesystems) loop(fn string, opts []string, flags uintptr) (*os.File, error) {
+	_, isLoop := fstab.Option(opts, "loop")
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		if isLoop {
+			return nil, fmt.Errorf("%s: not a regular file", fn)
+		}
+		return nil, nil
+	}
+	var offset, sizelimit uint64
+	for _, x := range []struct {
+		name string
+		p    *uint64
+	}{
+		{"offset", &offset},
+		{"sizelimit", &sizelimit},
+	} {
+		if s, found := fstab.Option(opts, x.name); found {
+			if *x.p, err = strconv.ParseUint(s, 0, 64); err != nil {
+				return nil, fmt.Errorf("%s: %v", x.name, err)
+			}
+		}
+	}
+	return loop.Attach(fn, offset, sizelimit, flags&syscall.MS_RDONLY != 0)
+}
+
+// overlayDirs checks the lowerdir option of an overlay mount and makes its
+// upperdir and workdir, which are often on a fresh tmpfs.
+func overlayDirs(opts []string) error {
+	if _, found := fstab.Option(opts, "lowerdir"); !found {
+		return fmt.Errorf("overlay: lowerdir: missing")
+	}
+	upper, hasUpper := fstab.Option(opts, "upperdir")
+	work, hasWork := fstab.Option(opts, "workdir")
+	if hasUpper != hasWork {
+		return fmt.Errorf("overlay: upperdir and workdir are a pair")
+	}
+	for _, dir := range []string{upper, work} {
+		if len(dir) > 0 {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (fs *filesystems) goMountone(t, dev, dir string, opts []string, c chan *MountResult) {
+	c <- fs.mountone(t, dev, dir, opts)
 }
 
 func show() error {
@@ -5,24 +5,36 @@
 package mountd
 
 import (
-	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
-	"time"
 
 	"github.com/platinasystems/go/goes/cmd"
 	"github.com/platinasystems/go/goes/lang"
-	"github.com/platinasystems/log"
+	"github.com/platinasystems/go/internal/fstab"
+	"github.com/platinasystems/go/internal/nl"
+	"github.com/platinasystems/go/internal/parms"
 	"github.com/platinasystems/go/internal/partitions"
+	"github.com/platinasystems/log"
+	"github.com/platinasystems/redis"
+	"github.com/platinasystems/redis/publisher"
 )
 
+// Prefix of the published redis fields
+const Prefix = "mountd."
+
+// SysClassBlock has the MAJOR:MINOR dev file of each disk and partition.
+var SysClassBlock = "/sys/class/block"
+
 type Command chan struct{}
 
 func (Command) String() string { return "mountd" }
 
-func (Command) Usage() string { return "mountd" }
+func (Command) Usage() string { return "mountd [-rules FILE] [DIRECTORY]" }
 
 func (Command) Apropos() lang.Alt {
 	return lang.Alt{
@@ -30,6 +42,47 @@ func (Command) Apropos() lang.Alt {
 	}
 }
 
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Mount the filesystem of each block device as it's found, or
+	arrives through kernel uevents, on DIRECTORY/NAME, e.g.
+	/mountd/sdb1; then unmount it as the device leaves. Without
+	devtmpfs, mountd also makes and removes the /dev nodes.
+
+	Rules select the devices to mount and their options. The first
+	rule matching a device applies; devices matching none are
+	ignored. Each rule is a line of,
+
+		ACTION MATCH[,MATCH]... [OPTION[,OPTION]...]
+
+	where ACTION is mount or ignore; MATCH is *, TYPE=FSTYPE,
+	REMOVABLE=0|1 or a UUID=, LABEL=, PARTUUID= or PARTLABEL=
+	specifier; and OPTIONs are those of fstab(5). The default
+	rules are,
+
+		ignore	TYPE=swap
+		mount	REMOVABLE=1	nosuid,nodev
+		mount	*
+
+	whereas this would only mount read-only sticks labeled PLATINA,
+
+		mount	LABEL=PLATINA,REMOVABLE=1	ro
+
+	Events are published to redis as,
+
+		mountd.event: ACTION NAME [DIRECTORY]
+		mountd.NAME.{mountpoint,type,uuid,label}
+
+	where ACTION is add, change, mount, unmount or remove.
+
+OPTIONS
+	-rules FILE	default, /etc/goes/mountd
+	DIRECTORY	default, /mountd`,
+	}
+}
+
 func (c Command) Close() error {
 	close(c)
 	return nil
@@ -37,77 +90,301 @@ func (c Command) Close() error {
 
 func (Command) Kind() cmd.Kind { return cmd.Daemon }
 
-func mountone(dev, dir string) (err error) {
-	sb, err := partitions.ReadSuperBlock(dev)
+type mountd struct {
+	dir   string
+	rules []Rule
+	// mounted maps device names to their mount points
+	mounted map[string]string
+	// parent maps mounted partitions to their disk
+	parent map[string]string
+	// mknod if /dev isn't devtmpfs
+	mknod bool
+	pubch chan string
+}
+
+func (c Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-rules")
+	if len(parm.ByName["-rules"]) == 0 {
+		parm.ByName["-rules"] = RulesFile
+	}
+	md := &mountd{
+		dir:     "/mountd",
+		mounted: make(map[string]string),
+		parent:  make(map[string]string),
+		pubch:   make(chan string, 256),
+	}
+	switch len(args) {
+	case 0:
+	case 1:
+		md.dir = args[0]
+	default:
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	rules, err := ReadRules(parm.ByName["-rules"])
 	if err != nil {
 		return err
 	}
-	t := ""
-	if sb != nil {
-		t = sb.Kind()
+	md.rules = rules
+	if err = os.MkdirAll(md.dir, 0755); err != nil {
+		return err
 	}
-	if t == "" {
-		return fmt.Errorf("Unable to determine type of block device on %s", dev)
+	mounts, err := fstab.ReadMountinfo(fstab.MountinfoFile)
+	if err != nil {
+		return err
 	}
-	err = syscall.Mount(dev, dir, t, 0, "")
-	if err == nil {
-		return nil
+	if m := fstab.Mounted(mounts, "/dev"); m == nil ||
+		m.Type != "devtmpfs" {
+		md.mknod = true
 	}
-	if err == syscall.EACCES {
-		err = syscall.Mount(dev, dir, t, syscall.MS_RDONLY, "")
+
+	// listen before the initial scan so as not to miss any arrivals
+	sock, err := nl.NewUeventSock(64)
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+
+	go md.gopub(c)
+
+	if md.mknod {
+		md.mknodAll()
+	}
+	devs, err := partitions.BlockDevices()
+	if err != nil {
+		return err
+	}
+	for _, d := range devs {
+		md.add(d)
+	}
+
+	for {
+		select {
+		case <-c:
+			return nil
+		case u, opened := <-sock.RxCh:
+			if !opened {
+				return sock.Err
+			}
+			md.uevent(u)
+		}
 	}
-	return err
 }
 
-func (Command) mountall(mp string) {
-	pp, err := os.Open("/proc/partitions")
+func (md *mountd) uevent(u *nl.Uevent) {
+	name := u.Env["DEVNAME"]
+	if u.Subsystem() != "block" || len(name) == 0 {
+		return
+	}
+	switch u.Action {
+	case "add":
+		if md.mknod {
+			err := mknod(name, u.Env["MAJOR"], u.Env["MINOR"])
+			if err != nil {
+				log.Print("mountd: ", err)
+			}
+		}
+		md.publish("event", "add ", name)
+		md.probe(name)
+	case "change":
+		// e.g. DISK_MEDIA_CHANGE of a card reader
+		md.publish("event", "change ", name)
+		md.probe(name)
+	case "remove":
+		md.remove(name)
+		md.publish("event", "remove ", name)
+		if md.mknod {
+			os.Remove(filepath.Join(partitions.DevDir, name))
+		}
+	}
+}
+
+// probe the named device; media that's gone is unmounted.
+func (md *mountd) probe(name string) {
+	devs, err := partitions.BlockDevices()
 	if err != nil {
-		log.Print("opening /proc/partitions: %s\n", err)
+		log.Print("mountd: ", err)
 		return
 	}
-	defer pp.Close()
-	scanner := bufio.NewScanner(pp)
-scan:
-	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		if len(fields) < 4 || fields[0] == "major" {
-			continue scan
+	for _, d := range devs {
+		if d.Name == name {
+			md.add(d)
+			return
 		}
-		fileName := fields[3]
-		mpd := mp + "/" + fileName
-		if _, err := os.Stat(mpd); os.IsNotExist(err) {
-			err := os.Mkdir(mpd, os.FileMode(0555))
-			if err != nil {
-				fmt.Println("mkdir", mpd, "err:", err)
-			} else {
-				_ = mountone("/dev/"+fileName, mpd)
+	}
+	md.remove(name)
+	if _, err = os.Stat(filepath.Join(partitions.SysBlock, name)); err == nil {
+		// the disk remains without media, so neither do its partitions
+		for dev := range md.mounted {
+			if md.parent[dev] == name {
+				md.remove(dev)
 			}
 		}
 	}
 }
 
-func (c Command) Main(args ...string) (err error) {
-	mp := "/mountd"
-	if len(args) > 0 {
-		mp = args[0]
+// add mounts the device per the first matching rule.
+func (md *mountd) add(d *partitions.BlockDevice) {
+	if len(d.FsType) == 0 {
+		return
 	}
-	if _, err = os.Stat(mp); os.IsNotExist(err) {
-		err = os.Mkdir(mp, 0755)
+	if _, found := md.mounted[d.Name]; found {
+		return
+	}
+	dir := filepath.Join(md.dir, d.Name)
+	if d.MountPoint == dir {
+		// by a previous mountd
+		md.mounted[d.Name] = dir
+		md.parent[d.Name] = d.Parent
+		return
+	}
+	if len(d.MountPoint) > 0 {
+		return
+	}
+	r := Lookup(md.rules, d)
+	if r == nil || !r.Mount {
+		return
+	}
+	if err := os.MkdirAll(dir, 0555); err != nil {
+		log.Print("mountd: ", err)
+		return
+	}
+	if err := mount(d, dir, r.Options); err != nil {
+		log.Print("mountd: ", d.Path, ": ", err)
+		os.Remove(dir)
+		return
+	}
+	md.mounted[d.Name] = dir
+	md.parent[d.Name] = d.Parent
+	md.publish(d.Name+".mountpoint", dir)
+	md.publish(d.Name+".type", d.FsType)
+	if len(d.UUID) > 0 {
+		md.publish(d.Name+".uuid", d.UUID)
+	}
+	if len(d.Label) > 0 {
+		md.publish(d.Name+".label", d.Label)
+	}
+	md.publish("event", "mount ", d.Name, " ", dir)
+}
+
+// remove lazily unmounts the departed device.
+func (md *mountd) remove(name string) {
+	dir, found := md.mounted[name]
+	if !found {
+		return
+	}
+	delete(md.mounted, name)
+	delete(md.parent, name)
+	if err := syscall.Unmount(dir, syscall.MNT_DETACH); err != nil {
+		log.Print("mountd: ", dir, ": ", err)
+	}
+	os.Remove(dir)
+	for _, k := range []string{"mountpoint", "type", "uuid", "label"} {
+		md.unpublish(name + "." + k)
+	}
+	md.publish("event", "unmount ", name, " ", dir)
+}
+
+func mount(d *partitions.BlockDevice, dir string, opts []string) error {
+	flags, data := fstab.Flags(opts)
+	err := syscall.Mount(d.Path, dir, d.FsType, flags, data)
+	if (err == syscall.EACCES || err == syscall.EROFS) &&
+		flags&syscall.MS_RDONLY == 0 {
+		// e.g. a write protected card
+		err = syscall.Mount(d.Path, dir, d.FsType,
+			flags|syscall.MS_RDONLY, data)
+	}
+	return err
+}
+
+// mknodAll makes the missing /dev nodes of the current block devices.
+func (md *mountd) mknodAll() {
+	fis, err := ioutil.ReadDir(SysClassBlock)
+	if err != nil {
+		log.Print("mountd: ", err)
+		return
+	}
+	for _, fi := range fis {
+		b, err := ioutil.ReadFile(filepath.Join(SysClassBlock,
+			fi.Name(), "dev"))
 		if err != nil {
-			log.Print("err", mp, ": ", err)
+			continue
+		}
+		mm := strings.Split(strings.TrimSpace(string(b)), ":")
+		if len(mm) != 2 {
+			continue
+		}
+		if err = mknod(fi.Name(), mm[0], mm[1]); err != nil {
+			log.Print("mountd: ", err)
+		}
+	}
+}
+
+func mknod(name, major, minor string) error {
+	fn := filepath.Join(partitions.DevDir, name)
+	if _, err := os.Stat(fn); err == nil {
+		return nil
+	}
+	maj, err := strconv.ParseUint(major, 10, 32)
+	if err != nil {
+		return fmt.Errorf("%s: MAJOR: %v", name, err)
+	}
+	min, err := strconv.ParseUint(minor, 10, 32)
+	if err != nil {
+		return fmt.Errorf("%s: MINOR: %v", name, err)
+	}
+	if err = os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return err
+	}
+	return syscall.Mknod(fn, syscall.S_IFBLK|0660, int(mkdev(maj, min)))
+}
+
+// mkdev encodes the device number as the kernel's new_encode_dev.
+func mkdev(major, minor uint64) uint64 {
+	return (minor & 0xff) | (major&0xfff)<<8 | (minor&^0xff)<<12
+}
+
+func (md *mountd) publish(field string, v ...interface{}) {
+	md.send(Prefix + field + ": " + fmt.Sprint(v...))
+}
+
+func (md *mountd) unpublish(field string) {
+	md.send("delete: " + Prefix + field)
+}
+
+func (md *mountd) send(s string) {
+	select {
+	case md.pubch <- s:
+	default:
+		// drop rather than block on a missing redis
+	}
+}
+
+// gopub waits for redis then, after deleting the fields of any previous
+// mountd, publishes those of this one.
+func (md *mountd) gopub(done <-chan struct{}) {
+	if err := redis.IsReady(); err != nil {
+		log.Print("mountd: ", err)
+		return
+	}
+	pub, err := publisher.New()
+	if err != nil {
+		log.Print("mountd: ", err)
+		return
+	}
+	defer pub.Close()
+	if keys, err := redis.Hkeys(redis.DefaultHash); err == nil {
+		for _, k := range keys {
+			if strings.HasPrefix(k, Prefix) {
+				pub.Print("delete: ", k)
+			}
 		}
 	}
-	c.mountall(mp)
-	t := time.NewTicker(2 * time.Second)
-	defer t.Stop()
 	for {
-		c.mountall(mp)
 		select {
-		case <-c:
-			return nil
-		case <-t.C:
+		case <-done:
+			return
+		case s := <-md.pubch:
+			pub.Print(s)
 		}
 	}
-	return nil
 }
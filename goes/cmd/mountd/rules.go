@@ -0,0 +1,138 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package mountd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/platinasystems/go/internal/fstab"
+	"github.com/platinasystems/go/internal/partitions"
+)
+
+const RulesFile = "/etc/goes/mountd"
+
+// DefaultRules are those without a RulesFile.
+const DefaultRules = `
+ignore	TYPE=swap
+mount	REMOVABLE=1	nosuid,nodev
+mount	*
+`
+
+// Rule is a line of the RulesFile,
+//
+//	ACTION MATCH[,MATCH]... [OPTION[,OPTION]...]
+//
+// where ACTION is mount or ignore; MATCH is *, TYPE=FSTYPE, REMOVABLE=0|1,
+// or a UUID=, LABEL=, PARTUUID= or PARTLABEL= specifier; and OPTIONs are
+// those of fstab(5).
+type Rule struct {
+	Mount   bool
+	Match   []string
+	Options []string
+}
+
+// Matches returns true if the device matches all of the rule's MATCHes.
+func (r *Rule) Matches(d *partitions.BlockDevice) bool {
+	for _, m := range r.Match {
+		kv := strings.SplitN(m, "=", 2)
+		switch {
+		case m == "*":
+		case len(kv) == 2 && strings.ToUpper(kv[0]) == "TYPE":
+			if kv[1] != d.FsType {
+				return false
+			}
+		case len(kv) == 2 && strings.ToUpper(kv[0]) == "REMOVABLE":
+			if (kv[1] == "1") != d.Removable {
+				return false
+			}
+		default:
+			if !d.Match(m) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (r *Rule) String() string {
+	s := "ignore"
+	if r.Mount {
+		s = "mount"
+	}
+	s += " " + strings.Join(r.Match, ",")
+	if len(r.Options) > 0 {
+		s += " " + strings.Join(r.Options, ",")
+	}
+	return s
+}
+
+// Lookup returns the first rule matching the device, or nil.
+func Lookup(rules []Rule, d *partitions.BlockDevice) *Rule {
+	for i := range rules {
+		if rules[i].Matches(d) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// ReadRules from the file, or DefaultRules if it doesn't exist.
+func ReadRules(fn string) ([]Rule, error) {
+	f, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		return ParseRules(strings.NewReader(DefaultRules))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rules, err := ParseRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return rules, nil
+}
+
+// ParseRules skips blank and comment lines; a LABEL with spaces has \040
+// escapes, as in fstab(5).
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if len(s) == 0 || s[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(s)
+		var rule Rule
+		switch fields[0] {
+		case "mount":
+			rule.Mount = true
+		case "ignore":
+		default:
+			return nil, fmt.Errorf("line %d: %s: unknown action",
+				line, fields[0])
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: missing MATCH", line)
+		}
+		for _, m := range fstab.Split(fields[1]) {
+			rule.Match = append(rule.Match, fstab.Unescape(m))
+		}
+		if len(fields) > 2 {
+			rule.Options = fstab.Split(fields[2])
+		}
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: %v: unexpected", line,
+				fields[3:])
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
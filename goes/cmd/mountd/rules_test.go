@@ -0,0 +1,59 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package mountd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/platinasystems/go/internal/partitions"
+)
+
+func TestRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(`
+# upgrade sticks
+mount	LABEL=PLATINA\040USB,REMOVABLE=1	ro
+ignore	TYPE=swap
+ignore	UUID=1234-abcd
+mount	REMOVABLE=1	nosuid,nodev
+mount	TYPE=ext4
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []struct {
+		d    partitions.BlockDevice
+		want string
+	}{
+		{partitions.BlockDevice{FsType: "vfat", Label: "PLATINA USB",
+			Removable: true}, "mount LABEL=PLATINA USB,REMOVABLE=1 ro"},
+		{partitions.BlockDevice{FsType: "vfat", Label: "PLATINA USB"},
+			"nil"},
+		{partitions.BlockDevice{FsType: "swap", Removable: true},
+			"ignore TYPE=swap"},
+		{partitions.BlockDevice{FsType: "vfat", UUID: "1234-ABCD",
+			Removable: true}, "ignore UUID=1234-abcd"},
+		{partitions.BlockDevice{FsType: "vfat", Removable: true},
+			"mount REMOVABLE=1 nosuid,nodev"},
+		{partitions.BlockDevice{FsType: "ext4"}, "mount TYPE=ext4"},
+	} {
+		got := "nil"
+		if r := Lookup(rules, &x.d); r != nil {
+			got = r.String()
+		}
+		if got != x.want {
+			t.Errorf("%+v: got %q, want %q", x.d, got, x.want)
+		}
+	}
+	for _, bad := range []string{"umount *", "mount", "mount * ro extra"} {
+		if _, err = ParseRules(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: parsed", bad)
+		}
+	}
+	if rules, err = ParseRules(strings.NewReader(DefaultRules)); err != nil ||
+		len(rules) != 3 {
+		t.Error("DefaultRules:", err)
+	}
+}
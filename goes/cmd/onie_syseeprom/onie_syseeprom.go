@@ -0,0 +1,243 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package onie_syseeprom provides an onie-syseeprom compatible command to
+// show and edit the ONIE TlvInfo of a system EEPROM or its image file.
+package onie_syseeprom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/eeprom"
+	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/parms"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "onie-syseeprom" }
+
+func (Command) Usage() string {
+	return "onie-syseeprom {-file FILE | -i2c BUS.ADDR} [-e] [-json] " +
+		"[-import FILE] [-s CODE=VALUE,...] [-g CODE]"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show or edit the ONIE system EEPROM",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Show, validate or edit the ONIE TlvInfo fields of a system EEPROM on
+	an i2c bus, or of a binary image FILE. Vendor extensions with
+	Platina's IANA private enterprise number are shown by sub-field.
+
+	CODE is a TLV type code, e.g. 0x21, or name, e.g. product-name.
+	VALUE is formatted by type: a string; MAC address; decimal device
+	version or MAC address count; MM/DD/YYYY hh:mm:ss manufacture date;
+	or hex bytes of a vendor extension, e.g. 0x0000bc65 5001 00.
+
+	Edits update the CRC-32 and write the EEPROM or FILE, which is
+	created if absent. A CRC-32 mismatch is shown and is repaired by any
+	edit.
+
+	Examples:
+	    onie-syseeprom -i2c 0.55
+	    onie-syseeprom -i2c 0.55 -s 0x23=PSW1712000042,0x2a=4
+	    onie-syseeprom -i2c 0.55 -g base-mac-address
+	    onie-syseeprom -i2c 0.55 -json >eeprom.json
+	    onie-syseeprom -file eeprom.bin -e -import eeprom.json
+
+OPTIONS
+	-file FILE
+		read and write the binary image rather than an EEPROM
+	-i2c BUS.ADDR
+		read and write the EEPROM at the hex i2c bus and address
+	-e	erase all fields before the import or set
+	-json	print the fields as JSON
+	-import FILE
+		replace the fields with those of the JSON FILE
+	-s CODE=VALUE,...
+		set the fields, or delete those with an empty VALUE
+	-g CODE	print the value of the field`,
+	}
+}
+
+// memory of an EEPROM or image file
+type memory interface {
+	read() (*eeprom.TlvInfo, error)
+	write(*eeprom.TlvInfo) error
+}
+
+type file string
+
+func (fn file) read() (*eeprom.TlvInfo, error) {
+	b, err := ioutil.ReadFile(string(fn))
+	if err != nil {
+		return nil, err
+	}
+	return eeprom.ParseTlvInfo(b)
+}
+
+// write the TlvInfo over the start of the file, erasing the remainder of
+// a shorter TlvInfo.
+func (fn file) write(info *eeprom.TlvInfo) error {
+	b := info.Bytes()
+	if old, err := ioutil.ReadFile(string(fn)); err == nil {
+		for len(b) < len(old) {
+			b = append(b, 0xff)
+		}
+	}
+	return ioutil.WriteFile(string(fn), b, 0644)
+}
+
+type device struct{ *eeprom.Device }
+
+func (d device) read() (*eeprom.TlvInfo, error) {
+	return d.ReadTlvInfo()
+}
+
+func (d device) write(info *eeprom.TlvInfo) error {
+	return d.WriteTlvInfo(info)
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-e", "-json")
+	parm, args := parms.New(args, "-file", "-i2c", "-g", "-s", "-import")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	var m memory
+	switch fn, addr := parm.ByName["-file"], parm.ByName["-i2c"]; {
+	case len(fn) > 0 && len(addr) > 0:
+		return fmt.Errorf("-file and -i2c: exclusive")
+	case len(fn) > 0:
+		m = file(fn)
+	case len(addr) > 0:
+		d := new(eeprom.Device)
+		_, err := fmt.Sscanf(addr, "%x.%x", &d.BusIndex, &d.BusAddress)
+		if err != nil {
+			return fmt.Errorf("%s: invalid BUS.ADDR: %v", addr, err)
+		}
+		m = device{d}
+	default:
+		return fmt.Errorf("-file or -i2c: missing")
+	}
+	erase := flag.ByName["-e"]
+	info, err := m.read()
+	switch {
+	case erase:
+		info, err = eeprom.NewTlvInfo(), nil
+	case err == eeprom.ErrCRC:
+	case err != nil:
+		if _, isFile := m.(file); isFile && os.IsNotExist(err) &&
+			(len(parm.ByName["-s"]) > 0 ||
+				len(parm.ByName["-import"]) > 0) {
+			info, err = eeprom.NewTlvInfo(), nil
+			break
+		}
+		return err
+	}
+	valid := err == nil
+	modified := erase
+	if fn := parm.ByName["-import"]; len(fn) > 0 {
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(b, info); err != nil {
+			return fmt.Errorf("%s: %v", fn, err)
+		}
+		modified = true
+	}
+	if s := parm.ByName["-s"]; len(s) > 0 {
+		if err = set(info, s); err != nil {
+			return err
+		}
+		modified = true
+	}
+	if modified {
+		if err = m.write(info); err != nil {
+			return err
+		}
+		valid = true
+	}
+	return show(os.Stdout, info, valid, parm.ByName["-g"],
+		flag.ByName["-json"])
+}
+
+// set the CODE=VALUE list, deleting fields with empty values.
+func set(info *eeprom.TlvInfo, list string) error {
+	for _, s := range strings.Split(list, ",") {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			return fmt.Errorf("%s: missing =VALUE", s)
+		}
+		t, err := eeprom.Lookup(s[:eq])
+		if err != nil {
+			return err
+		}
+		if eq == len(s)-1 {
+			err = info.Delete(t)
+			if fe, ok := err.(*eeprom.FieldError); ok &&
+				fe.Err == eeprom.ErrNotFound {
+				err = nil
+			}
+		} else {
+			var v []byte
+			if v, err = eeprom.Scan(t, s[eq+1:]); err == nil {
+				err = info.Set(t, v)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// show the field of the code, the JSON, or all fields and checksum status.
+func show(w io.Writer, info *eeprom.TlvInfo, valid bool, code string,
+	asJSON bool) error {
+	if len(code) > 0 {
+		t, err := eeprom.Lookup(code)
+		if err != nil {
+			return err
+		}
+		tlv, found := info.Get(t)
+		if !found {
+			return &eeprom.FieldError{Type: t, Err: eeprom.ErrNotFound}
+		}
+		_, err = fmt.Fprintln(w, eeprom.Format(t, tlv.Value))
+		return err
+	}
+	if asJSON {
+		b, err := json.MarshalIndent(info, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+	info.Print(w)
+	if err := info.Validate(); err != nil {
+		fmt.Fprintln(w, "Invalid field:", err)
+	}
+	if valid {
+		fmt.Fprintln(w, "Checksum is valid.")
+	} else {
+		fmt.Fprintln(w, "Checksum is invalid.")
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package onie_syseeprom
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/platinasystems/go/internal/eeprom"
+)
+
+const image = "../../../internal/eeprom/testdata/platina-mk1.bin"
+
+func TestEdit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "onie-syseeprom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b, err := ioutil.ReadFile(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := filepath.Join(dir, "eeprom.bin")
+	if err = ioutil.WriteFile(fn, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = Command{}.Main("-file", fn,
+		"-s", "serial-number=PSW1712000043,0x26=,0x2f=TAG1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := file(fn).read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	for _, x := range []struct {
+		code, want string
+	}{
+		{"0x23", "PSW1712000043\n"},
+		{"service-tag", "TAG1\n"},
+		{"0x21", "BT-77O\n"},
+	} {
+		out.Reset()
+		if err = show(&out, info, true, x.code, false); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != x.want {
+			t.Errorf("%s: got %q, want %q", x.code, out.String(), x.want)
+		}
+	}
+	if err = show(&out, info, true, "0x26", false); err == nil {
+		t.Error("deleted device version: no error")
+	}
+	if fi, err := os.Stat(fn); err != nil || fi.Size() != int64(len(b)) {
+		t.Error("file size changed")
+	}
+	for _, s := range []string{
+		"0x24=02:46:8a",
+		"0xfe=0x12345678",
+		"bogus=x",
+		"0x21",
+	} {
+		if err = set(info, s); err == nil {
+			t.Errorf("%s: no error", s)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "onie-syseeprom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	info, err := file(image).read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = show(&out, info, true, "", true); err != nil {
+		t.Fatal(err)
+	}
+	js := filepath.Join(dir, "eeprom.json")
+	if err = ioutil.WriteFile(js, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fn := filepath.Join(dir, "new.bin")
+	if err = (Command{}).Main("-file", fn, "-import", js); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, info.Bytes()) {
+		t.Error("imported image mismatch")
+	}
+}
+
+func TestShow(t *testing.T) {
+	b, err := ioutil.ReadFile("../../../internal/eeprom/testdata/bad-crc.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := eeprom.ParseTlvInfo(b)
+	if err != eeprom.ErrCRC {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = show(&out, info, false, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out.String(), "Checksum is invalid.\n") {
+		t.Error(out.String())
+	}
+	if err = (Command{}).Main("-json"); err == nil {
+		t.Error("missing -file or -i2c: no error")
+	}
+}
@@ -33,6 +33,7 @@ const (
 var (
 	lastFanStatus [maxFanTrays]string
 	lastPsuStatus [maxPsu]string
+	lastAlarm     string
 
 	psuLed       = []uint8{0x8, 0x10}
 	psuLedYellow = []uint8{0x8, 0x10}
@@ -422,6 +423,32 @@ func (h *I2cDev) LedStatus() error {
 			}
 		}
 	}
+
+	//if the worst sensor alarm of alarmd is critical or fatal, set front panel SYS led to yellow, otherwise green
+	p, _ := redis.Hget(redis.DefaultHash, "alarm.system")
+	if lastAlarm != p {
+		r.Output[0].get(h)
+		closeMux(h)
+		err := DoI2cRpc()
+		if err != nil {
+			return err
+		}
+		o = s[1].D[0]
+		d = 0xff ^ sysLed
+		o &= d
+		if p == "critical" || p == "fatal" {
+			o |= sysLedYellow
+		} else {
+			o |= sysLedGreen
+		}
+		r.Output[0].set(h, o)
+		closeMux(h)
+		err = DoI2cRpc()
+		if err != nil {
+			return err
+		}
+		lastAlarm = p
+	}
 	return nil
 }
 
@@ -6,10 +6,9 @@
 package ledgpiod
 
 import (
-	"net/rpc"
-	"time"
 	"unsafe"
 
+	"github.com/platinasystems/go/internal/i2cbus"
 	"github.com/platinasystems/i2c"
 	"github.com/platinasystems/log"
 )
@@ -44,9 +43,6 @@ var dummy byte
 var regsPointer = unsafe.Pointer(&dummy)
 var regsAddr = uintptr(unsafe.Pointer(&dummy))
 
-var clientA *rpc.Client
-var dialed int = 0
-
 // offset function has divide by two for 16-bit offset struct
 func getRegs() *regs {
 	clearJ()
@@ -138,31 +134,25 @@ func clearJ() {
 }
 
 func readStopped() byte {
-	var data = [34]byte{0, 0, 0, 0}
-	j[0] = I{true, i2c.Write, 0, i2c.ByteData, data, int(0x98), int(0), 0}
-	err := DoI2cRpc()
-	if err != nil {
+	if i2cbus.Stopped() {
 		return 1
 	}
-	return s[0].D[0]
+	return 0
 }
 
 func DoI2cRpc() error {
-	if dialed == 0 {
-		client, err := rpc.DialHTTP("tcp", "127.0.0.1"+":1233")
-		if err != nil {
-			log.Print("dialing:", err)
-			return err
-		}
-		clientA = client
-		dialed = 1
-		time.Sleep(time.Millisecond * time.Duration(50))
+	var g [MAXOPS]i2cbus.I
+	var f [MAXOPS]i2cbus.R
+	for k := range j {
+		g[k] = i2cbus.I(j[k])
 	}
-	err := clientA.Call("I2cReq.ReadWrite", &j, &s)
-	if err != nil {
+	if err := i2cbus.ReadWrite(&g, &f); err != nil {
 		log.Print("i2cReq error:", err)
 		return err
 	}
+	for k := range s {
+		s[k] = R(f[k])
+	}
 	clearJ()
 	return nil
 }
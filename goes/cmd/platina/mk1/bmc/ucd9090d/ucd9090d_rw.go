@@ -6,10 +6,9 @@
 package ucd9090d
 
 import (
-	"net/rpc"
-	"time"
 	"unsafe"
 
+	"github.com/platinasystems/go/internal/i2cbus"
 	"github.com/platinasystems/i2c"
 	"github.com/platinasystems/log"
 )
@@ -44,9 +43,6 @@ var dummy byte
 var regsPointer = unsafe.Pointer(&dummy)
 var regsAddr = uintptr(unsafe.Pointer(&dummy))
 
-var clientA *rpc.Client
-var dialed int = 0
-
 func getRegs() *regs {
 	clearJ()
 	return (*regs)(regsPointer)
@@ -118,15 +114,15 @@ func (r *reg8) set(h *I2cDev, v uint8) {
 }
 
 /*
-func (r *reg8b) set(h *I2cDev, v []byte) {
-	var data = [34]byte{0, 0, 0, 0}
-
-	data[0] = byte(h.MuxValue)
-	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus, h.MuxAddr, 0}
-	x++
-	j[x] = I{true, i2c.Write, r.offset(), i2c.I2CBlockData, v, h.Bus, h.Addr, 0}
-	x++
-}
+	func (r *reg8b) set(h *I2cDev, v []byte) {
+		var data = [34]byte{0, 0, 0, 0}
+
+		data[0] = byte(h.MuxValue)
+		j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus, h.MuxAddr, 0}
+		x++
+		j[x] = I{true, i2c.Write, r.offset(), i2c.I2CBlockData, v, h.Bus, h.Addr, 0}
+		x++
+	}
 */
 func (r *reg16) set(h *I2cDev, v uint16) {
 	var data = [34]byte{0, 0, 0, 0}
@@ -152,6 +148,27 @@ func (r *reg16r) set(h *I2cDev, v uint16) {
 	x++
 }
 
+// Do the op behind the device's mux, as the i2cbus.Backend of its
+// pmbus.Device.
+func (h *I2cDev) Do(bus, addr int, rw i2c.RW, cmd uint8, size i2c.SMBusSize,
+	data *i2c.SMBusData) error {
+	var d = [34]byte{0, 0, 0, 0}
+
+	clearJ()
+	d[0] = byte(h.MuxValue)
+	j[x] = I{true, i2c.Write, 0, i2c.ByteData, d, h.MuxBus, h.MuxAddr, 0}
+	x++
+	copy(d[:], data[:])
+	j[x] = I{true, rw, cmd, size, d, bus, addr, 0}
+	x++
+	closeMux(h)
+	if err := DoI2cRpc(); err != nil {
+		return err
+	}
+	copy(data[:], s[1].D[:])
+	return nil
+}
+
 func clearJ() {
 	x = 0
 	for k := 0; k < MAXOPS; k++ {
@@ -160,31 +177,25 @@ func clearJ() {
 }
 
 func readStopped() byte {
-	var data = [34]byte{0, 0, 0, 0}
-	j[0] = I{true, i2c.Write, 0, i2c.ByteData, data, int(0x98), int(0), 0}
-	err := DoI2cRpc()
-	if err != nil {
+	if i2cbus.Stopped() {
 		return 1
 	}
-	return s[0].D[0]
+	return 0
 }
 
 func DoI2cRpc() error {
-	if dialed == 0 {
-		client, err := rpc.DialHTTP("tcp", "127.0.0.1"+":1233")
-		if err != nil {
-			log.Print("dialing:", err)
-			return err
-		}
-		clientA = client
-		dialed = 1
-		time.Sleep(time.Millisecond * time.Duration(50))
+	var g [MAXOPS]i2cbus.I
+	var f [MAXOPS]i2cbus.R
+	for k := range j {
+		g[k] = i2cbus.I(j[k])
 	}
-	err := clientA.Call("I2cReq.ReadWrite", &j, &s)
-	if err != nil {
+	if err := i2cbus.ReadWrite(&g, &f); err != nil {
 		log.Print("i2cReq error:", err)
 		return err
 	}
+	for k := range s {
+		s[k] = R(f[k])
+	}
 	clearJ()
 	return nil
 }
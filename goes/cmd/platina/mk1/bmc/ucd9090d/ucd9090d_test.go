@@ -0,0 +1,68 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package ucd9090d
+
+import (
+	"testing"
+	"time"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/go/internal/i2cbus/i2cbustest"
+)
+
+var testDev = I2cDev{
+	Bus:      0,
+	Addr:     0x7e,
+	MuxBus:   0,
+	MuxAddr:  0x76,
+	MuxValue: 0x01,
+}
+
+func TestUcd(t *testing.T) {
+	i2cbustest.Simulate(t, "testdata/ucd.sim")
+	h := testDev
+
+	for i, want := range []float64{3, 1.25} {
+		v, err := h.Vout(uint8(i + 1))
+		if err != nil || v != want {
+			t.Errorf("Vout(%d): %v, %v", i+1, v, err)
+		}
+	}
+
+	for i, want := range []string{
+		"none",
+		"STATUS_VOUT.VOUT_UV_FAULT,STATUS_WORD.VIN_UV_FAULT",
+	} {
+		v, err := h.Faults(uint8(i + 1))
+		if err != nil || v != want {
+			t.Errorf("Faults(%d): %q, %v", i+1, v, err)
+		}
+	}
+
+	// without re-init of the other devices
+	firstLog = 1
+	loggedFaultCount = 0
+	want := time.Unix(1000, 0).Format(time.RFC3339)
+	if v, err := h.PowerCycles(); err != nil || v != want {
+		t.Errorf("PowerCycles: %q, %v", v, err)
+	}
+	if v, err := h.PowerCycles(); err != nil || v != "" {
+		t.Errorf("PowerCycles without new faults: %q, %v", v, err)
+	}
+}
+
+func TestUcdFaults(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/ucd.sim")
+	h := testDev
+
+	sim.Device(0, 0x7e).Inject(i2cbus.Nak, 0)
+	if _, err := h.Vout(1); err == nil {
+		t.Error("Vout of NAKing sequencer")
+	}
+	sim.Device(0, 0x7e).Inject(i2cbus.NoFault, 0)
+	if _, err := h.Vout(1); err != nil {
+		t.Error("Vout after NAK:", err)
+	}
+}
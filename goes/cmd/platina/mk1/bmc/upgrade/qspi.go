@@ -15,6 +15,7 @@ import (
 	"time"
 	"unsafe"
 
+	"github.com/platinasystems/go/internal/i2cbus"
 	"github.com/platinasystems/gpio"
 	"github.com/platinasystems/i2c"
 )
@@ -288,9 +289,7 @@ func selectQSPI(q bool) error {
 	command.gpio.Do(command.Gpio)
 
 	//i2c STOP
-	sd[0] = 0
-	j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(1), 0}
-	err := DoI2cRpc()
+	err := i2cbus.Pause()
 	if err != nil {
 		return err
 	}
@@ -302,9 +301,7 @@ func selectQSPI(q bool) error {
 	time.Sleep(200 * time.Millisecond)
 
 	//i2c START
-	sd[0] = 0
-	j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(0), 0}
-	err = DoI2cRpc()
+	err = i2cbus.Resume()
 	if err != nil {
 		return err
 	}
@@ -48,41 +48,6 @@ func TestGetFile(t *testing.T) {
 	}
 }
 
-type isVerNewer struct {
-	curver string
-	newver string
-	result bool
-}
-
-var z = []byte{0xff, 0xff, 0xff, 0xff}
-
-var IVNtests = []isVerNewer{
-	{"v0.2", "v0.3", true},
-	{"v0.3", "v0.2", false},
-	{"v0.3", "20170901", true},
-	{"20170901", "v0.2", false},
-	{"20170901", "20170902", true},
-	{"20170901", "20170830", false},
-	{string(z), "20170830", true},
-	{"20170830", string(z), false},
-}
-
-func TestIsVersionNewer(t *testing.T) {
-	for _, pair := range IVNtests {
-		v, err := isVersionNewer(pair.curver, pair.newver)
-		if err != nil {
-			t.Errorf("Error: %v", err)
-		}
-		if v != pair.result {
-			t.Error(
-				"For", pair.curver, pair.newver,
-				"expected", pair.result,
-				"got", v,
-			)
-		}
-	}
-}
-
 func TestRmFile(t *testing.T) {
 	fn := "/tmp/tempfile"
 	f, err := os.Create(fn)
@@ -0,0 +1,220 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package upgrade
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/platinasystems/go/goes/cmd/platina/mk1/bootc"
+	"github.com/platinasystems/go/goes/cmd/reboot"
+	"github.com/platinasystems/go/internal/prog"
+	"github.com/platinasystems/go/internal/slot"
+)
+
+const (
+	DfltSlots   = "/boot/slot"
+	DfltGrubenv = "/boot/grub/grubenv"
+	DfltTimeout = 10 * time.Minute
+
+	// names of slot images
+	SlotGoes     = "goes"
+	SlotKernel   = "vmlinuz"
+	SlotInitrd   = "initrd.img"
+	SlotCoreboot = "coreboot.rom"
+)
+
+func openSlots() (*slot.Slots, error) {
+	sl, err := slot.Open(DfltSlots)
+	if err != nil {
+		return nil, err
+	}
+	sl.Grubenv = DfltGrubenv
+	return sl, nil
+}
+
+// stageFile to the inactive slot then remove the downloaded file.
+func stageFile(sl *slot.Slots, name, version, fn, sum string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	err = sl.Stage(name, version, f, sum)
+	f.Close()
+	rmFile(fn)
+	return err
+}
+
+// stageCopy of the file to the inactive slot leaving the original.
+func stageCopy(sl *slot.Slots, name, version, fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return sl.Stage(name, version, f, "")
+}
+
+// changed returns true if the named image of the inactive slot differs
+// from that of the active slot.
+func changed(sl *slot.Slots, name string) bool {
+	img, found := sl.Images[sl.Inactive()][name]
+	return found && img != sl.Images[sl.Active][name]
+}
+
+// flashCoreboot of the active slot unless the same as that of the
+// previous slot.
+func flashCoreboot(sl *slot.Slots) error {
+	img, found := sl.Images[sl.Active][SlotCoreboot]
+	if !found || img == sl.Images[sl.Previous][SlotCoreboot] {
+		return nil
+	}
+	fmt.Printf("Please wait...installing Coreboot into flash\n")
+	return installCoreboot(sl.Path(sl.Active, SlotCoreboot))
+}
+
+// activateGoes of the active slot, unless the same as that of the
+// previous slot, by starting it as the installer of /usr/bin/goes. It
+// returns the installer command, if started.
+func activateGoes(sl *slot.Slots) (*exec.Cmd, error) {
+	img, found := sl.Images[sl.Active][SlotGoes]
+	if !found || img == sl.Images[sl.Previous][SlotGoes] {
+		return nil, nil
+	}
+	fmt.Print("\nACTIVATING GOES, WILL EXIT... type reset, goes\n")
+	cmd := exec.Command(sl.Path(sl.Active, SlotGoes))
+	return cmd, cmd.Start()
+}
+
+func switchSlots(sl *slot.Slots, timeout time.Duration) error {
+	staged := false
+	for _, name := range []string{SlotGoes, SlotKernel, SlotInitrd,
+		SlotCoreboot} {
+		staged = staged || changed(sl, name)
+	}
+	if !staged {
+		return nil
+	}
+	if err := sl.Switch(timeout); err != nil {
+		return err
+	}
+	if _, found := sl.Images[sl.Active][SlotKernel]; found {
+		current := filepath.Join(strings.TrimPrefix(DfltSlots, "/boot/"),
+			slot.Current)
+		err := bootc.UpdateBootcCfg(filepath.Join(current, SlotKernel),
+			filepath.Join(current, SlotInitrd))
+		if err != nil {
+			return err
+		}
+	}
+	if err := flashCoreboot(sl); err != nil {
+		return err
+	}
+	fmt.Printf("Switched to slot %s, reboot then commit by %s\n",
+		sl.Active, sl.Deadline.Format(time.Stamp))
+	_, err := activateGoes(sl)
+	return err
+}
+
+func printStatus() error {
+	sl, err := openSlots()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Active:   %s\n", sl.Active)
+	if len(sl.Previous) > 0 {
+		fmt.Printf("Previous: %s\n", sl.Previous)
+	}
+	if sl.Pending {
+		fmt.Printf("Pending:  commit by %s\n",
+			sl.Deadline.Format(time.Stamp))
+	}
+	for _, s := range []string{slot.A, slot.B} {
+		fmt.Printf("\nSlot %s:\n", s)
+		for _, name := range sl.Names(s) {
+			img := sl.Images[s][name]
+			fmt.Printf("    %-14s %-24s %.12s\n", name, img.Version,
+				img.Sha256)
+		}
+	}
+	return nil
+}
+
+func commitSlot() error {
+	sl, err := openSlots()
+	if err != nil {
+		return err
+	}
+	return sl.Commit()
+}
+
+// rollbackSlot to the previous, waiting for the installation of its goes
+// if about to reboot.
+func rollbackSlot(wait bool) error {
+	sl, err := openSlots()
+	if err != nil {
+		return err
+	}
+	if err = sl.Rollback(); err != nil {
+		return err
+	}
+	if err = flashCoreboot(sl); err != nil {
+		return err
+	}
+	fmt.Printf("Rolled back to slot %s, reboot to complete\n", sl.Active)
+	cmd, err := activateGoes(sl)
+	if err == nil && cmd != nil && wait {
+		err = cmd.Wait()
+	}
+	return err
+}
+
+// StartHook is for the machine's start command. It runs "upgrade -check"
+// in its own session while a slot is pending, so that start continues.
+func StartHook() error {
+	sl, err := openSlots()
+	if err != nil || !sl.Pending {
+		return err
+	}
+	cmd := exec.Command(prog.Name(), "upgrade", "-check")
+	cmd.Dir = "/"
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true,
+	}
+	return cmd.Start()
+}
+
+// checkSlot waits for the pending slot to be committed; otherwise, it
+// rolls back and reboots at the deadline.
+func checkSlot() error {
+	for {
+		sl, err := openSlots()
+		if err != nil {
+			return err
+		}
+		if !sl.Pending {
+			return nil
+		}
+		now := time.Now()
+		if sl.Expired(now) {
+			fmt.Printf("Slot %s wasn't committed, rolling back\n",
+				sl.Active)
+			if err = rollbackSlot(true); err != nil {
+				return err
+			}
+			return reboot.Command{}.Main()
+		}
+		d := sl.Deadline.Sub(now)
+		if d > 10*time.Second {
+			d = 10 * time.Second
+		}
+		time.Sleep(d + time.Millisecond)
+	}
+}
@@ -9,10 +9,11 @@ package upgrade
 
 import (
 	"fmt"
-	"io/ioutil"
+	"time"
 
 	"github.com/platinasystems/go/goes/lang"
 	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/manifest"
 	"github.com/platinasystems/go/internal/parms"
 )
 
@@ -28,6 +29,11 @@ const (
 	GoesInstaller = "goes-" + Machine + "-installer"
 	KernelName    = "linux-image-" + Machine
 	CorebootName  = "coreboot-" + Machine + ".rom"
+
+	// manifest artifact targets
+	TargetGoes     = "goes"
+	TargetKernel   = "kernel"
+	TargetCoreboot = "coreboot"
 )
 
 type Command struct{}
@@ -36,7 +42,8 @@ func (Command) String() string { return "upgrade" }
 
 func (Command) Usage() string {
 	return `
-upgrade [-v VER] [-s SERVER[/dir]] [-r] [-l] [-t] [-a | -g -k -c] [-f]`
+upgrade [-v VER] [-s SERVER[/dir]] [-r] [-l] [-t] [-a | -g -k -c] [-f]
+	[-timeout DURATION] | -status | -commit | -rollback | -check`
 }
 
 func (Command) Apropos() lang.Alt {
@@ -54,34 +61,81 @@ DESCRIPTION
 	The default upgrade version is "LATEST". 
 	Or specify a version using "-v", form YYYYMMDD or vX.X
 
-	The -l flag display version of selected server and version.
+	The -l flag displays the manifest of selected server and version.
 
 	The -r flag prints a report on current version numbers.
 
 	Images are downloaded from "downloads.platinasystems.com",
 	Or from a server using "-s" followed by a URL or IPv4 address.
 
-	Upgrade proceeds only if the selected version number is newer,
-	unless overridden with the "-f" force flag.
+	Each release has a MANIFEST of its images with their version,
+	size, SHA-256 and target; MANIFEST.sig is its ed25519 signature.
+	Upgrade proceeds only if the manifest's signature is valid, each
+	downloaded image matches its size and SHA-256, and the image
+	version is newer than the known current version, unless
+	overridden with the "-f" force flag. The force flag doesn't
+	override the signature or integrity.
+
+	Images are installed in A/B slots, /boot/slot/a and /boot/slot/b,
+	so that a failed upgrade never overwrites those that booted. New
+	images are staged to the inactive slot, along with copies of the
+	other active images, then verified before atomically switching
+	/boot/slot/current and the grubenv saved_entry to that slot.
+	Coreboot is flashed, and goes installed, from the new slot if
+	changed. A kernel package is installed for its modules and initrd,
+	and bootc boots the vmlinuz and initrd.img of /boot/slot/current.
+
+	The new slot is pending until committed with "-commit" after a
+	healthy reboot. Otherwise, "upgrade -check", run at start-up
+	by the machine's start hook, rolls back and reboots after the "-timeout". Grub also boots the
+	known good slot after failed boots of the new one.
 
 OPTIONS
 	-v [VER]          version [YYYYMMDD] or LATEST (default)
 	-s [SERVER[/dir]] IP4 or URL, default downloads.platinasystems.com
 	-t                use TFTP instead of HTTP
-	-l                display version of selected server and version
+	-l                display manifest of selected server and version
 	-r                report current versions of goes, kernel, coreboot
 	-g                upgrade goes
 	-k                upgrade kernel
 	-c                upgrade coreboot
 	-a                upgrade all
-	-f                force upgrade (ignore version check)`,
+	-f                force upgrade (ignore version check)
+	-timeout DURATION commit deadline of the new slot (default 10m)
+	-status           report the slots
+	-commit           commit the pending slot
+	-rollback         switch back to the previous slot
+	-check            wait for commit of the pending slot, otherwise
+	                  rollback and reboot`,
 	}
 }
 
 func (Command) Main(args ...string) error {
 	flag, args := flags.New(args, "-t", "-l", "-f", "-r",
-		"-g", "-c", "-k", "-a")
-	parm, args := parms.New(args, "-v", "-s")
+		"-g", "-c", "-k", "-a",
+		"-status", "-commit", "-rollback", "-check")
+	parm, args := parms.New(args, "-v", "-s", "-timeout")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	switch {
+	case flag.ByName["-status"]:
+		return printStatus()
+	case flag.ByName["-commit"]:
+		return commitSlot()
+	case flag.ByName["-rollback"]:
+		return rollbackSlot(false)
+	case flag.ByName["-check"]:
+		return checkSlot()
+	}
+	timeout := DfltTimeout
+	if s := parm.ByName["-timeout"]; len(s) > 0 {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("-timeout: %v", err)
+		}
+		timeout = d
+	}
 	if len(parm.ByName["-v"]) == 0 {
 		parm.ByName["-v"] = DfltVer
 	}
@@ -115,25 +169,22 @@ func (Command) Main(args ...string) error {
 	}
 	if err := doUpgrade(parm.ByName["-s"], parm.ByName["-v"],
 		flag.ByName["-t"], flag.ByName["-g"], flag.ByName["-k"],
-		flag.ByName["-c"], flag.ByName["-f"]); err != nil {
+		flag.ByName["-c"], flag.ByName["-f"], timeout); err != nil {
 		return err
 	}
 	return nil
 }
 
-var Install_flag bool = false
-
 func showList(s string, v string, t bool) error {
-	fn := "LIST"
-	_, err := getFile(s, v, t, fn)
+	m, err := manifest.Get(s, v, t)
 	if err != nil {
 		return err
 	}
-	l, err := ioutil.ReadFile(fn)
-	if err != nil {
-		return err
+	fmt.Printf("%s %s\n", m.Machine, m.Version)
+	for _, a := range m.Artifacts {
+		fmt.Printf("    %-10s %-32s %-24s %10d %.12s\n", a.Target,
+			a.Name, a.Version, a.Size, a.Sha256)
 	}
-	fmt.Print(string(l))
 	return nil
 }
 
@@ -146,28 +197,36 @@ func reportVersions() error {
 }
 
 func doUpgrade(s string, v string, t bool, g bool, k bool,
-	c bool, f bool) error {
+	c bool, f bool, timeout time.Duration) error {
+	m, err := manifest.Get(s, v, t)
+	if err != nil {
+		return err
+	}
+	if err = m.Check(Machine); err != nil {
+		return err
+	}
+	sl, err := openSlots()
+	if err != nil {
+		return err
+	}
+	if err = sl.Prepare(); err != nil {
+		return err
+	}
 	fmt.Print("\n")
 	if g {
-		if err := upgradeGoes(s, v, t, f); err != nil {
+		if err := upgradeGoes(sl, m, s, v, t, f); err != nil {
 			return err
 		}
 	}
 	if k {
-		if err := upgradeKernel(s, v, t, f); err != nil {
+		if err := upgradeKernel(sl, m, s, v, t, f); err != nil {
 			return err
 		}
 	}
 	if c {
-		if err := upgradeCoreboot(s, v, t, f); err != nil {
+		if err := upgradeCoreboot(sl, m, s, v, t, f); err != nil {
 			return err
 		}
 	}
-	if Install_flag {
-		if err := activateGoes(); err != nil {
-			return err
-		}
-		return nil
-	}
-	return nil
+	return switchSlots(sl, timeout)
 }
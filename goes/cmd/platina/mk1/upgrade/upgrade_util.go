@@ -10,11 +10,13 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
-	"regexp"
+	"path/filepath"
 	"strings"
 	"syscall"
 
-	"github.com/platinasystems/go/goes/cmd/platina/mk1/bootc"
+	"github.com/platinasystems/go/goes"
+	"github.com/platinasystems/go/internal/manifest"
+	"github.com/platinasystems/go/internal/slot"
 	"github.com/platinasystems/go/internal/url"
 )
 
@@ -170,76 +172,78 @@ func getCorebootInfo() (im IMGINFO, err error) {
 	return im, nil
 }
 
-func upgradeGoes(s string, v string, t bool, f bool) error {
+func upgradeGoes(sl *slot.Slots, m *manifest.Manifest, s string, v string,
+	t bool, f bool) error {
 	fmt.Printf("Update Goes\n")
-	if !f {
-		g := getGoesVal("tag", "/go")
-		gr, err := getSrvGoesVer(s, v, t)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("    Goes version currently:  %s\n", g)
-		fmt.Printf("    Goes version on server:  %s\n", gr)
-		if g == gr {
-			fmt.Print("    Versions match, skipping Goes upgrade\n\n")
-			return nil
-		}
-		if len(g) == 0 || len(gr) == 0 {
-			fmt.Print("    No tag found, aborting Goes upgrade\n\n")
-			return nil
+	a := m.Lookup(TargetGoes)
+	if a == nil {
+		fmt.Print("    Not in manifest, skipping Goes upgrade\n\n")
+		return nil
+	}
+	// the active slot has no goes until its first slot upgrade
+	g := sl.Images[sl.Active][SlotGoes].Version
+	if len(g) == 0 {
+		if i := manifest.Installed(TargetGoes); i != nil {
+			g = i.Version
+		} else {
+			g = getGoesVal("tag", "/go")
 		}
 	}
+	if ok, err := manifest.CheckVersion("Goes", g, a, f); !ok {
+		return err
+	}
 
-	if err := installGoes(s, v, t); err != nil {
+	if err := installGoes(sl, a, s, v, t); err != nil {
 		return err
 	}
 	return nil
 }
 
-func upgradeKernel(s string, v string, t bool, f bool) error {
+func upgradeKernel(sl *slot.Slots, m *manifest.Manifest, s string, v string,
+	t bool, f bool) error {
 	fmt.Printf("Update Kernel\n")
-	kr, fn, err := getSrvKernelVer(s, v, t)
-	if err != nil {
-		return err
+	a := m.Lookup(TargetKernel)
+	if a == nil {
+		fmt.Print("    Not in manifest, skipping Kernel upgrade\n\n")
+		return nil
 	}
-	if !f {
-		k, err := getKernelVer()
-		if err != nil {
+	k := sl.Images[sl.Active][SlotKernel].Version
+	if len(k) == 0 {
+		var err error
+		if k, err = getKernelVer(); err != nil {
 			return err
 		}
-		fmt.Printf("    Kernel version currently:  %s\n", k)
-		fmt.Printf("    Kernel version on server:  %s\n", kr)
-		if k == kr {
-			fmt.Print("    Versions match, skipping Kernel upgrade\n\n")
-			return nil
-		}
+	}
+	if ok, err := manifest.CheckVersion("Kernel", k, a, f); !ok {
+		return err
 	}
 
-	if err := installKernel(s, v, t, fn); err != nil {
+	if err := installKernel(sl, a, s, v, t); err != nil {
 		return err
 	}
 	return nil
 }
 
-func upgradeCoreboot(s string, v string, t bool, f bool) error {
+func upgradeCoreboot(sl *slot.Slots, m *manifest.Manifest, s string,
+	v string, t bool, f bool) error {
 	fmt.Printf("Update Coreboot\n")
-	c, err := getCorebootVer()
-	if err != nil {
-		return err
+	a := m.Lookup(TargetCoreboot)
+	if a == nil {
+		fmt.Print("    Not in manifest, skipping Coreboot upgrade\n\n")
+		return nil
 	}
-	cr, err := getSrvCorebootVer(s, v, t)
-	if err != nil {
-		return err
+	c := sl.Images[sl.Active][SlotCoreboot].Version
+	if len(c) == 0 {
+		var err error
+		if c, err = getCorebootVer(); err != nil {
+			return err
+		}
 	}
-	fmt.Printf("    Coreboot version currently:  %s\n", c)
-	fmt.Printf("    Coreboot version on server:  %s\n", cr)
-	if !f && c == cr {
-		fmt.Print("    Versions match, skipping Coreboot upgrade\n\n")
-		return nil
+	if ok, err := manifest.CheckVersion("Coreboot", c, a, f); !ok {
+		return err
 	}
 
-	fmt.Printf("Please wait...installing Coreboot into flash\n")
-	if err := installCoreboot(s, v, t); err != nil {
+	if err := stageArtifact(sl, SlotCoreboot, a, s, v, t); err != nil {
 		return err
 	}
 	return nil
@@ -263,7 +267,10 @@ func getGoesVal(ar string, ir string) (v string) {
 	}
 	return v
 	*/
-	return "FIXME"
+	if ar == "tag" && ir == "/go" {
+		return goes.Version
+	}
+	return ""
 }
 
 func getKernelVer() (string, error) {
@@ -312,166 +319,81 @@ func getCorebootVer() (tag string, err error) {
 	return tag, nil
 }
 
-func getSrvGoesVer(s string, v string, t bool) (string, error) {
-	fn := GoesName
-	n, err := getFile(s, v, t, fn)
-	if err != nil {
-		return "", fmt.Errorf("Error downloading: %v", err)
-	}
-	if n < 1000 {
-		return "", fmt.Errorf("Error file too small: %v", err)
-	}
-	x := ""
-	a, err := ioutil.ReadFile(fn)
-	if err != nil {
-		return "", err
-	}
-	as := string(a)
-	ref := regexp.MustCompile("v([0-9])[.]([0-9])-([0-9]+)-g([0-9a-fA-F]+)")
-	x = ref.FindString(as)
-	if len(x) == 0 {
-		ree := regexp.MustCompile("v([0-9])[.]([0-9])")
-		x = ree.FindString(as)
-	}
-	rmFile(fn)
-	return x, nil
-}
-
-func getSrvKernelVer(s string, v string, t bool) (string, string, error) {
-	fn := KernelName
-	n, err := getFile(s, v, t, fn)
-	if err != nil {
-		return "", "", fmt.Errorf("Error downloading: %v", err)
-	}
-	if n < 10 {
-		return "", "", fmt.Errorf("Error file too small: %v", err)
-	}
-	a, err := ioutil.ReadFile(fn)
-	if err != nil {
-		return "", "", err
-	}
-	u := strings.Split(string(a), "\n")
-	return strings.TrimSpace(u[0]), strings.TrimSpace(u[1]), nil
-}
-
-func getSrvCorebootVer(s string, v string, t bool) (string, error) {
-	fn := CorebootName
-	n, err := getFile(s, v, t, fn)
-	if err != nil {
-		return "", fmt.Errorf("Error downloading: %v", err)
-	}
-	if n < 10000000 {
-		return "", fmt.Errorf("Error file too small: %v", err)
-	}
-
-	a, err := ioutil.ReadFile(CorebootName)
-	if err != nil {
-		return "", err
-	}
-	temp := strings.Split(string(a), "\n")
-	tag := ""
-	for _, j := range temp {
-		if strings.Contains(j, "COREBOOT_VERSION ") {
-			x := strings.Split(j, " ")
-			tag = strings.Replace(x[2], `"`, "", 2)
-		}
-	}
-	return tag, nil
-}
-
-func installGoes(s string, v string, t bool) error {
-	fn := GoesInstaller
-	n, err := getFile(s, v, t, fn)
-	if err != nil {
-		return fmt.Errorf("    Error downloading: %v", err)
-	}
-	if n < 1000 {
-		return fmt.Errorf("    Error file too small: %v", err)
-	}
-
-	Install_flag = true
-	return nil
+func installGoes(sl *slot.Slots, a *manifest.Artifact, s string, v string,
+	t bool) error {
+	return stageArtifact(sl, SlotGoes, a, s, v, t)
 }
 
-func installKernel(s string, v string, t bool, fn string) error {
-	n, err := getFile(s, v, t, fn)
-	if err != nil {
+// installKernel installs the modules of the verified package, and has it
+// generate an initrd, then stages its vmlinuz and initrd to the inactive
+// slot. The modules are in a directory of the kernel release, so they
+// don't disturb those of the other slot.
+func installKernel(sl *slot.Slots, a *manifest.Artifact, s string, v string,
+	t bool) error {
+	fn := a.Name
+	if err := a.Download(s, v, t, fn); err != nil {
 		return fmt.Errorf("    Error downloading: %v", err)
 	}
-	if n < 1000 {
-		return fmt.Errorf("    Error file too small: %v", err)
-	}
+	defer rmFile(fn)
 
-	_, err = exec.Command("dpkg", "-i", fn).Output()
+	dir, err := ioutil.TempDir("", "kernel")
 	if err != nil {
 		return err
 	}
-
-	err = cleanupBootDir(fn)
+	defer os.RemoveAll(dir)
+	_, err = exec.Command("dpkg-deb", "-x", fn, dir).Output()
 	if err != nil {
 		return err
 	}
-
-	_, err = exec.Command("update-grub").Output()
+	vmlinuz, err := filepath.Glob(filepath.Join(dir, "boot", "vmlinuz-*"))
 	if err != nil {
 		return err
 	}
+	if len(vmlinuz) != 1 {
+		return fmt.Errorf("%s: vmlinuz not found", fn)
+	}
+	release := strings.TrimPrefix(filepath.Base(vmlinuz[0]), "vmlinuz-")
 
-	files, err := ioutil.ReadDir("/boot")
+	_, err = exec.Command("dpkg", "-i", fn).Output()
 	if err != nil {
 		return err
 	}
-	k := ""
-	i := ""
-	for _, f := range files {
-		if strings.Contains(f.Name(), "vmlinuz") {
-			k = f.Name()
-		}
+	modules := filepath.Join("/lib/modules", release)
+	if _, err = os.Stat(modules); err != nil {
+		return fmt.Errorf("%s: modules not installed: %v", fn, err)
 	}
-	for _, f := range files {
-		if strings.Contains(f.Name(), "initrd") {
-			i = f.Name()
-		}
+	initrd := filepath.Join("/boot", "initrd.img-"+release)
+	if _, err = os.Stat(initrd); err != nil {
+		return fmt.Errorf("%s: initrd not generated: %v", fn, err)
 	}
 
-	if err := bootc.UpdateBootcCfg(k, i); err != nil {
+	if err = stageFile(sl, SlotKernel, a.Version, vmlinuz[0], ""); err != nil {
 		return err
 	}
+	return stageCopy(sl, SlotInitrd, a.Version, initrd)
+}
 
-	return nil
+// stageArtifact downloads and verifies the artifact then stages it to the
+// inactive slot.
+func stageArtifact(sl *slot.Slots, name string, a *manifest.Artifact,
+	s string, v string, t bool) error {
+	if err := a.Download(s, v, t, a.Name); err != nil {
+		return fmt.Errorf("    Error downloading: %v", err)
+	}
+	return stageFile(sl, name, a.Version, a.Name, a.Sha256)
 }
 
-func installCoreboot(s string, v string, t bool) error {
+func installCoreboot(fn string) error {
 	_, err := exec.Command("/usr/local/sbin/flashrom", "-p",
 		"internal:boardmismatch=force", "-l",
 		"/usr/local/share/flashrom/layouts/platina-mk1.xml",
-		"-i", "bios", "-w", CorebootName, "-A", "-V").Output()
+		"-i", "bios", "-w", fn, "-A", "-V").Output()
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func cleanupBootDir(fn string) error {
-	ref := regexp.MustCompile("([0-9]+)[.]([0-9]+)[.]([0-9]+)")
-	x := ref.FindString(fn)
-	if len(x) == 0 {
-		return fmt.Errorf("Error: version number not found. %v", fn)
-	}
-	files, _ := ioutil.ReadDir("/boot")
-	for _, f := range files {
-		if !f.IsDir() {
-			if !strings.Contains(f.Name(), x) {
-				err := rmFile("/boot/" + f.Name())
-				if err != nil {
-					return err
-				}
-			}
-		}
-	}
-	return nil
-}
-
 func getFile(s string, v string, t bool, fn string) (int, error) {
 	rmFile(fn)
 	urls := "http://" + s + "/" + v + "/" + fn
@@ -504,10 +426,3 @@ func rmFile(f string) error {
 	}
 	return nil
 }
-
-func activateGoes() error {
-	fmt.Print("\nACTIVATING GOES, WILL EXIT... type reset, goes\n")
-	cmd := exec.Command("./" + GoesInstaller)
-	cmd.Start()
-	return nil
-}
@@ -181,46 +181,46 @@ func diagProm() error {
 			//write mac base address
 			h, _ := strconv.ParseUint(v, 16, 64)
 			b := []byte{byte((h >> 40) & 0xff), byte((h >> 32) & 0xff), byte((h >> 24) & 0xff), byte((h >> 16) & 0xff), byte((h >> 8) & 0xff), byte(h & 0xff)}
-			fmt.Printf("%s\n", d.WriteField(c, b))
+			report(d.WriteField(c, b), written)
 		case "26":
 			//write version
 			h, _ := strconv.ParseUint(v, 16, 64)
 			b := []byte{byte(h & 0xff)}
-			fmt.Printf("%s\n", d.WriteField(c, b))
+			report(d.WriteField(c, b), written)
 		case "2a":
 			//write number of macs
 			h, _ := strconv.ParseUint(v, 16, 64)
 			b := []byte{byte((h >> 8) & 0xff), byte(h & 0xff)}
-			fmt.Printf("%s\n", d.WriteField(c, b))
+			report(d.WriteField(c, b), written)
 		case "length":
 			//write onie length field (debug tool to fix invalid format)
 			n, _ := strconv.ParseUint(v, 10, 64)
 			l := []byte{byte(n >> 8), byte(n & 0xff)}
-			fmt.Printf("%s\n", d.WriteField(c, l))
+			report(d.WriteField(c, l), "length written")
 		case "fd":
 			//write vendor extension fields
 			switch v {
 			case "tor1p":
-				fmt.Printf("%s\n", d.WriteField(c, tor1Vedp))
+				report(d.WriteField(c, tor1Vedp), written)
 			case "bde4cp":
-				fmt.Printf("%s\n", d.WriteField(c, bde4cVedp))
+				report(d.WriteField(c, bde4cVedp), written)
 			case "bde2cp":
-				fmt.Printf("%s\n", d.WriteField(c, bde2cVedp))
+				report(d.WriteField(c, bde2cVedp), written)
 			case "tor1ga":
-				fmt.Printf("%s\n", d.WriteField(c, tor1Vedga))
+				report(d.WriteField(c, tor1Vedga), written)
 			case "bde4cga":
-				fmt.Printf("%s\n", d.WriteField(c, bde4cVedga))
+				report(d.WriteField(c, bde4cVedga), written)
 			case "bde2cga":
-				fmt.Printf("%s\n", d.WriteField(c, bde2cVedga))
+				report(d.WriteField(c, bde2cVedga), written)
 
 			case "mc14sch1p":
-				fmt.Printf("%s\n", d.WriteField(c, mc14sCh1Vedp))
+				report(d.WriteField(c, mc14sCh1Vedp), written)
 			case "lc14sch1p":
-				fmt.Printf("%s\n", d.WriteField(c, lc14sCh1Vedp))
+				report(d.WriteField(c, lc14sCh1Vedp), written)
 			case "bde2c4sch1p":
-				fmt.Printf("%s\n", d.WriteField(c, bde2c4sCh1Vedp))
+				report(d.WriteField(c, bde2c4sCh1Vedp), written)
 			case "bde4c4sch1p":
-				fmt.Printf("%s\n", d.WriteField(c, bde4c4sCh1Vedp))
+				report(d.WriteField(c, bde4c4sCh1Vedp), written)
 			default:
 			}
 		case "vsn":
@@ -311,7 +311,7 @@ func diagProm() error {
 			}
 		default:
 			//write any field with value
-			fmt.Printf("%s\n", d.WriteField(c, vByte))
+			report(d.WriteField(c, vByte), written)
 		}
 	} else if writeField && len(argF) == 2 {
 		c = argF[1]
@@ -320,13 +320,13 @@ func diagProm() error {
 		case "onie":
 			//write onie header
 			//fmt.Printf("onie: c: %v, v: %v\n", c, vByte)
-			fmt.Printf("%s\n", d.WriteField(c, vByte))
+			report(d.WriteField(c, vByte), "onie header written")
 		case "crc":
 			//recalculate crc32 and update crc field
-			fmt.Printf("%s\n", d.CalcCrc())
+			report(d.CalcCrc(), "crc update complete")
 		case "addcrc":
 			//add crc field (debug tool to fix a invalid format)
-			fmt.Printf("%s\n", d.AddCrc())
+			report(d.AddCrc(), "crc field added")
 		case "copy":
 			//copy host prom to bmc prom and update vendor extension field
 			var rawData []byte
@@ -381,11 +381,11 @@ func diagProm() error {
 				}
 				i += 2 + tlen
 			}
-			d.CopyAll(rawData)
-			fmt.Printf("data copied\n")
-
-			d.CalcCrc()
-			fmt.Printf("crc updated\n")
+			err := d.CopyAll(rawData)
+			report(err, "data copied")
+			if err == nil {
+				report(d.CalcCrc(), "crc updated")
+			}
 
 			if x86 {
 				gpioSet("CPU_TO_MAIN_I2C_EN", false)
@@ -396,7 +396,7 @@ func diagProm() error {
 	} else if delField && len(argF) == 2 {
 		//delete a field
 		c = argF[1]
-		fmt.Printf("%s\n", d.DeleteField(c))
+		report(d.DeleteField(c), "first matching field deleted")
 	} else {
 		fmt.Printf("Invalid or insufficient arguments\n")
 	}
@@ -406,3 +406,15 @@ func diagProm() error {
 	}
 	return nil
 }
+
+// written field, to be followed by a crc update
+const written = "write complete. run diag prom crc"
+
+// report the error or completion of the eeprom update
+func report(err error, done string) {
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(done)
+}
@@ -0,0 +1,63 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package ledgpiod
+
+import (
+	"testing"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/go/internal/i2cbus/i2cbustest"
+)
+
+var testDev = I2cDev{
+	Bus:      0,
+	Addr:     0x75,
+	MuxBus:   0,
+	MuxAddr:  0x76,
+	MuxValue: 0x02,
+}
+
+func TestLedFpInit(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/led.sim")
+	h := testDev
+	led := sim.Device(0, 0x75)
+
+	if err := h.LedFpInit(); err != nil {
+		t.Fatal(err)
+	}
+	if o, want := led.Byte(0, 0x02), sysLedGreen|fanLedYellow; o != want {
+		t.Errorf("output: %#x, expected %#x", o, want)
+	}
+	want := byte(0xff) &^ (sysLed | fanLed)
+	if c := led.Byte(0, 0x06); c != want {
+		t.Errorf("config: %#x, expected %#x", c, want)
+	}
+
+	// reinit restores the config without changing the output
+	led.SetBytes(0, 0x06, 0xff)
+	led.SetBytes(0, 0x02, 0x55)
+	if err := h.LedFpReinit(); err != nil {
+		t.Fatal(err)
+	}
+	if c := led.Byte(0, 0x06); c != want {
+		t.Errorf("reinit config: %#x, expected %#x", c, want)
+	}
+	if o := led.Byte(0, 0x02); o != 0x55 {
+		t.Errorf("reinit output: %#x", o)
+	}
+}
+
+func TestLedFaults(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/led.sim")
+	h := testDev
+
+	sim.Device(0, 0x75).Inject(i2cbus.Nak, 0)
+	if err := h.LedFpInit(); err == nil {
+		t.Error("LedFpInit of NAKing expander")
+	}
+	if o := sim.Device(0, 0x75).Byte(0, 0x02); o != 0 {
+		t.Errorf("output of NAKing expander: %#x", o)
+	}
+}
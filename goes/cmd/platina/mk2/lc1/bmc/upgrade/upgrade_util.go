@@ -18,9 +18,13 @@ import (
 	"syscall"
 
 	"github.com/platinasystems/go/internal/kexec"
+	"github.com/platinasystems/go/internal/manifest"
 	"github.com/platinasystems/go/internal/url"
 )
 
+// TargetQSPI is the manifest target of the ArchiveName.
+const TargetQSPI = "qspi"
+
 type IMGINFO struct {
 	Name   string
 	Build  string
@@ -54,6 +58,12 @@ func getFile(s string, v string, t bool, fn string) (int, error) {
 	return int(n), nil
 }
 
+// getArchive downloads the ArchiveName of the server's signed manifest,
+// returning its artifact.
+func getArchive(s string, v string, t bool) (*manifest.Artifact, error) {
+	return manifest.GetArtifact(s, v, t, Machine, TargetQSPI, ArchiveName)
+}
+
 func rmFiles() {
 	rmFile(VersionName)
 	return
@@ -164,10 +174,10 @@ func getVerQSPI(q bool) (string, error) {
 	qv := string(b[VERSION_OFFSET:VERSION_LEN])
 	if string(b[VERSION_OFFSET:VERSION_DEV]) == "dev" {
 		qv = "dev"
-	} else {
+	} else if !manifest.Erased(qv) {
 		_, err = strconv.ParseFloat(qv, 64)
 		if err != nil {
-			qv = "00000000"
+			qv = ""
 		}
 	}
 	return qv, nil
@@ -188,26 +198,12 @@ func getInstalledVersions() ([]string, error) {
 }
 
 func getServerVersion(s string, v string, t bool) (string, error) {
-	n, err := getFile(s, v, t, ArchiveName)
+	a, err := getArchive(s, v, t)
 	if err != nil {
-		return "", fmt.Errorf("Error downloading: %v", err)
-	}
-	if n < 1000 {
-		return "", fmt.Errorf("Error file too small: %v", err)
-	}
-	if err := unzip(); err != nil {
-		return "", fmt.Errorf("Error unzipping file: %v", err)
-	}
-	defer rmFiles()
-	l, err := ioutil.ReadFile(VersionName)
-	if err != nil {
-		return "", nil
-	}
-	sv := string(l[VERSION_OFFSET:VERSION_LEN])
-	if string(l[VERSION_OFFSET:VERSION_DEV]) == "dev" {
-		sv = "dev"
+		return "", err
 	}
-	return sv, nil
+	rmFile(ArchiveName)
+	return a.Version, nil
 }
 
 func printVerServer(s string, v string, sv string) {
@@ -236,30 +232,6 @@ func printVerQSPI(iv []string, qspi int) {
 	fmt.Print("\n")
 }
 
-func isVersionNewer(cur string, x string) (n bool, err error) {
-	if cur == "dev" || x == "dev" {
-		return true, nil
-	}
-	var c float64 = 0.0
-	var f float64 = 0.0
-	cur = strings.TrimSpace(cur)
-	cur = strings.Replace(cur, "v", "", -1)
-	c, err = strconv.ParseFloat(cur, 64)
-	if err != nil {
-		c = 0.0
-	}
-	x = strings.TrimSpace(x)
-	x = strings.Replace(x, "v", "", -1)
-	f, err = strconv.ParseFloat(x, 64)
-	if err != nil {
-		f = 0.0
-	}
-	if f > c {
-		return true, nil
-	}
-	return false, nil
-}
-
 func cmpSums(q bool) (err error) {
 	var ImgInfo [5]IMGINFO
 	if q == false {
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/platinasystems/go/internal/eeprom"
+	"github.com/platinasystems/go/internal/i2cbus"
 	"github.com/platinasystems/i2c"
 	"github.com/platinasystems/log"
 )
@@ -296,9 +297,7 @@ func diagI2cCh1Mc() {
 
 	// avoid conflicts w/ interrupt handlers.
         // i2c STOP
-        sd[0] = 0
-        j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(1), 0}
-        err := DoI2cRpc()
+        err := i2cbus.Pause()
         if err != nil {
                 log.Print(err)
         }
@@ -641,9 +640,7 @@ func diagI2cCh1Mc() {
 
 
         //i2c START
-        sd[0] = 0
-        j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(0), 0}
-        err = DoI2cRpc()
+        err = i2cbus.Resume()
         if err != nil {
                 log.Print(err)
         }
@@ -656,9 +653,7 @@ func diagI2cCh1Lc() {
 func diagSwitchConsole() error {
 
 	//i2c STOP
-	sd[0] = 0
-	j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(1), 0}
-	err := DoI2cRpc()
+	err := i2cbus.Pause()
 	if err != nil {
 		return err
 	}
@@ -672,9 +667,7 @@ func diagSwitchConsole() error {
 	time.Sleep(50 * time.Millisecond)
 
 	//i2c START
-	sd[0] = 0
-	j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(0), 0}
-	err = DoI2cRpc()
+	err = i2cbus.Resume()
 	if err != nil {
 		return err
 	}
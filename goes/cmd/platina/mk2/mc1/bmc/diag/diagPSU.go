@@ -8,7 +8,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/platinasystems/i2c"
+	"github.com/platinasystems/go/internal/i2cbus"
 	"github.com/platinasystems/log"
 )
 
@@ -75,9 +75,7 @@ func diagPSU() error {
 func diagPowerCycle() error {
 
 	//i2c STOP
-	sd[0] = 0
-	j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(1), 0}
-	err := DoI2cRpc()
+	err := i2cbus.Pause()
 	if err != nil {
 		return err
 	}
@@ -94,9 +92,7 @@ func diagPowerCycle() error {
 	time.Sleep(100 * time.Millisecond)
 
 	//i2c START
-	sd[0] = 0
-	j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(0), 0}
-	err = DoI2cRpc()
+	err = i2cbus.Resume()
 	if err != nil {
 		return err
 	}
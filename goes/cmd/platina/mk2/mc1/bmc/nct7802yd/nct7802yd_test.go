@@ -0,0 +1,59 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package nct7802yd
+
+import (
+	"testing"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/go/internal/i2cbus/i2cbustest"
+)
+
+var testDev = I2cDev{
+	Bus:      0,
+	Addr:     0x28,
+	MuxBus:   0,
+	MuxAddr:  0x76,
+	MuxValue: 0x80,
+}
+
+func TestHwm(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/hwm.sim")
+	h := testDev
+
+	if v, err := h.FrontTemp(); err != nil || v != "30.250" {
+		t.Errorf("FrontTemp: %q, %v", v, err)
+	}
+	if v, err := h.RearTemp(); err != nil || v != "28.250" {
+		t.Errorf("RearTemp: %q, %v", v, err)
+	}
+	if err := h.SetFanDuty(0x80); err != nil {
+		t.Fatal(err)
+	}
+	if v := sim.Device(0, 0x28).Byte(0, 0x60); v != 0x80 {
+		t.Errorf("fan output value: %#x", v)
+	}
+	if d, err := h.GetFanDuty(); err != nil || d != 0x80 {
+		t.Errorf("GetFanDuty: %#x, %v", d, err)
+	}
+}
+
+func TestHwmFaults(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/hwm.sim")
+	h := testDev
+
+	sim.Device(0, 0x28).Inject(i2cbus.Nak, 1)
+	if _, err := h.FrontTemp(); err == nil {
+		t.Error("FrontTemp of NAKing monitor")
+	}
+	sim.Device(0, 0x28).Inject(i2cbus.Garbage, 1)
+	if v, err := h.RearTemp(); err != nil || v != "255.250" {
+		t.Errorf("RearTemp of garbage: %q, %v", v, err)
+	}
+	sim.Device(0, 0x76).Inject(i2cbus.Stuck, 1)
+	if _, err := h.GetFanDuty(); err == nil {
+		t.Error("GetFanDuty of stuck bus")
+	}
+}
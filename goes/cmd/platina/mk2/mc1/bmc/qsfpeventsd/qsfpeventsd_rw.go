@@ -2,13 +2,12 @@
 // Use of this source code is governed by the GPL-2 license described in the
 // LICENSE file.
 
-//
 package qsfpeventsd
 
 import (
-	"net/rpc"
 	"unsafe"
 
+	"github.com/platinasystems/go/internal/i2cbus"
 	"github.com/platinasystems/i2c"
 	"github.com/platinasystems/log"
 )
@@ -43,11 +42,8 @@ var dummy byte
 var regsPointer = unsafe.Pointer(&dummy)
 var regsAddr = uintptr(unsafe.Pointer(&dummy))
 
-var clientA *rpc.Client
-var dialed int = 0
-
 // offset function has divide by two for 16-bit offset struct
-func getRegs() *regs		 { return (*regs)(regsPointer) }
+func getRegs() *regs { return (*regs)(regsPointer) }
 
 func getRegsLpage0() *regsLpage0 { return (*regsLpage0)(regsPointer) }
 func getRegsUpage0() *regsUpage0 { return (*regsUpage0)(regsPointer) }
@@ -150,27 +146,25 @@ func clearJ() {
 }
 
 func readStopped() byte {
-	var data = [34]byte{0, 0, 0, 0}
-	j[0] = I{true, i2c.Write, 0, i2c.ByteData, data, int(0x98), int(0), 0}
-	DoI2cRpc()
-	return s[0].D[0]
+	if i2cbus.Stopped() {
+		return 1
+	}
+	return 0
 }
 
 func DoI2cRpc() error {
-	if dialed == 0 {
-		client, err := rpc.DialHTTP("tcp", "127.0.0.1"+":1233")
-		if err != nil {
-			log.Print("dialing:", err)
-			return err
-		}
-		clientA = client
-		dialed = 1
+	var g [MAXOPS]i2cbus.I
+	var f [MAXOPS]i2cbus.R
+	for k := range j {
+		g[k] = i2cbus.I(j[k])
 	}
-	err := clientA.Call("I2cReq.ReadWrite", &j, &s)
-	if err != nil {
+	if err := i2cbus.ReadWrite(&g, &f); err != nil {
 		log.Print("i2cReq error:", err)
 		return err
 	}
+	for k := range s {
+		s[k] = R(f[k])
+	}
 	clearJ()
 	return nil
 }
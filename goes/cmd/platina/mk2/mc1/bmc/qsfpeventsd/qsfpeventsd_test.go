@@ -0,0 +1,81 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package qsfpeventsd
+
+import (
+	"testing"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/go/internal/i2cbus/i2cbustest"
+)
+
+var (
+	testModule = I2cDev{
+		Bus:      0,
+		Addr:     0x50,
+		MuxBus:   0,
+		MuxAddr:  0x70,
+		MuxValue: 0x01,
+	}
+	testIo = I2cDev{
+		Bus:      0,
+		Addr:     0x20,
+		MuxBus:   0,
+		MuxAddr:  0x70,
+		MuxValue: 0x01,
+	}
+)
+
+func TestQsfp(t *testing.T) {
+	i2cbustest.Simulate(t, "testdata/qsfp.sim")
+	h := testModule
+
+	if !h.DataReady() {
+		t.Error("DataReady")
+	}
+	if v := h.Compliance(); v != "40GBASE-CR4" {
+		t.Errorf("Compliance: %q", v)
+	}
+	for _, x := range []struct {
+		name string
+		f    func() string
+		want string
+	}{
+		{"Vendor", h.Vendor, "FINISAR CORP.  "},
+		{"PN", h.PN, "FCBN410QE2C01  "},
+		{"SN", h.SN, "ABC1234        "},
+	} {
+		if v := x.f(); v != x.want {
+			t.Errorf("%s: %q", x.name, v)
+		}
+	}
+}
+
+func TestQsfpStatus(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/qsfp.sim")
+	h := testIo
+	io := sim.Device(0, 0x20)
+
+	if err := h.QsfpInit(0x00, 0x00, 0xfe); err != nil {
+		t.Fatal(err)
+	}
+	if c := io.Byte(0, 0x03); c != 0xfe {
+		t.Errorf("config: %#x", c)
+	}
+	if v := h.QsfpStatus(1); v != "installed" {
+		t.Error("QsfpStatus:", v)
+	}
+
+	// an expander reading all ones shows the module as removed
+	io.Inject(i2cbus.Garbage, 1)
+	if v := h.QsfpStatus(1); v != "empty" {
+		t.Error("QsfpStatus of garbage:", v)
+	}
+
+	io.Inject(i2cbus.Nak, 0)
+	if err := h.QsfpInit(0x00, 0x00, 0xfe); err == nil {
+		t.Error("QsfpInit of NAKing expander")
+	}
+}
@@ -7,7 +7,6 @@ package ucd9090d
 
 import (
 	"fmt"
-	"math"
 	"net/rpc"
 	"strconv"
 	"strings"
@@ -20,6 +19,7 @@ import (
 	"github.com/platinasystems/go/goes/cmd/platina/mk2/mc1/bmc/ledgpiod"
 	"github.com/platinasystems/go/goes/cmd/w83795d"
 	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/pmbus"
 	"github.com/platinasystems/log"
 	"github.com/platinasystems/redis"
 	"github.com/platinasystems/redis/publisher"
@@ -165,6 +165,17 @@ func (c *Command) update() error {
 				c.last[k] = v
 			}
 		}
+		if strings.Contains(k, "units.V") {
+			v, err := Vdev.Faults(i)
+			if err != nil {
+				return err
+			}
+			fk := strings.TrimSuffix(k, "units.V") + "faults"
+			if v != c.lasts[fk] {
+				c.pub.Print(fk, ": ", v)
+				c.lasts[fk] = v
+			}
+		}
 		if strings.Contains(k, "poweroff.events") {
 			v, err := Vdev.PowerCycles()
 			if err != nil {
@@ -199,15 +210,32 @@ func (h *I2cDev) Vout(i uint8) (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	n := s[3].D[0] & 0xf
-	n--
-	n = (n ^ 0xf) & 0xf
+	mode := pmbus.Mode(s[3].D[0] & 0x1f)
 	v := uint16(s[5].D[1])<<8 | uint16(s[5].D[0])
 
-	nn := float64(n) * (-1)
-	vv := float64(v) * (math.Exp2(nn))
+	vv, _ := mode.Linear16(v)
 	vv, _ = strconv.ParseFloat(fmt.Sprintf("%.3f", vv), 64)
-	return float64(vv), nil
+	return vv, nil
+}
+
+// Faults of the rail from its STATUS registers, e.g.
+// STATUS_VOUT.VOUT_UV_FAULT, or "none".
+func (h *I2cDev) Faults(i uint8) (string, error) {
+	if i > 10 {
+		panic("Voltage rail subscript out of range\n")
+	}
+	d := &pmbus.Device{Bus: h.Bus, Addr: h.Addr, Backend: h}
+	if err := d.SetPage(i - 1); err != nil {
+		return "", err
+	}
+	faults, err := d.Faults()
+	if err != nil {
+		return "", err
+	}
+	if len(faults) == 0 {
+		return "none", nil
+	}
+	return strings.Join(faults, ","), nil
 }
 
 func (h *I2cDev) PowerCycles() (string, error) {
@@ -9,10 +9,10 @@ package upgrade
 
 import (
 	"fmt"
-	"io/ioutil"
 
 	"github.com/platinasystems/go/goes/lang"
 	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/manifest"
 	"github.com/platinasystems/go/internal/parms"
 )
 
@@ -34,8 +34,13 @@ DESCRIPTION
 	By default, images are downloaded from "downloads.platina.com".
 	Or from a server using "-s" followed by a URL or IPv4 address.
 
-	Upgrade proceeds only if the selected version number is newer,
-	unless overridden with the "-f" force flag.
+	The archive must be listed in the release's MANIFEST, signed by
+	MANIFEST.sig, with its size and SHA-256.
+
+	Upgrade proceeds only if the manifest's version of the archive is
+	newer than the known version of the QSPI, unless overridden with
+	the "-f" force flag. The force flag doesn't override the signature
+	or integrity.
 
 OPTIONS
 	-v [VER]          version [YYYYMMDD] or LATEST (default)
@@ -124,28 +129,12 @@ var (
 )
 
 func reportVerServer(s string, v string, t bool) (err error) {
-	n, err := getFile(s, v, t, ArchiveName)
+	a, err := getArchive(s, v, t)
 	if err != nil {
-		return fmt.Errorf("Server unreachable\n")
-	}
-	if n < 1000 {
-		return fmt.Errorf("Server unreachable\n")
-	}
-	if err := unzip(); err != nil {
-		return fmt.Errorf("Server error: unzipping file: %\n", err)
-	}
-	defer rmFiles()
-
-	l, err := ioutil.ReadFile(VersionName)
-	if err != nil {
-		fmt.Printf("Image version not found on server\n")
-		return nil
-	}
-	sv := string(l[VERSION_OFFSET:VERSION_LEN])
-	if string(l[VERSION_OFFSET:VERSION_DEV]) == "dev" {
-		sv = "dev"
+		return err
 	}
-	printVerServer(s, v, sv)
+	rmFile(ArchiveName)
+	printVerServer(s, v, a.Version)
 	return nil
 }
 
@@ -187,52 +176,21 @@ func compareChecksums() (err error) {
 func doUpgrade(s string, v string, t bool, f bool, q bool) (err error) {
 	fmt.Print("\n")
 
-	n, err := getFile(s, v, t, ArchiveName)
+	a, err := getArchive(s, v, t)
 	if err != nil {
-		return fmt.Errorf("Server unreachable\n")
-	}
-	if n < 1000 {
-		return fmt.Errorf("Server unreachable\n")
+		return err
 	}
 	if err = unzip(); err != nil {
 		return fmt.Errorf("Server error: unzipping file: %v\n", err)
 	}
 	defer rmFiles()
 
-	if !f {
-		qv, err := getVerQSPI(q)
-		if err != nil {
-			return err
-		}
-		if len(qv) == 0 {
-			fmt.Printf("Aborting, couldn't find version in QSPI\n")
-			fmt.Printf("Use -f to force upgrade.\n")
-			return nil
-		}
-
-		l, err := ioutil.ReadFile(VersionName)
-		if err != nil {
-			fmt.Printf("Aborting, couldn't find version number on server\n")
-			fmt.Printf("Use -f to force upgrade.\n")
-			return nil
-		}
-		sv := string(l[VERSION_OFFSET:VERSION_LEN])
-		if string(l[VERSION_OFFSET:VERSION_DEV]) == "dev" {
-			sv = "dev"
-		}
-		if sv != "dev" && qv != "dev" {
-			newer, err := isVersionNewer(qv, sv)
-			if err != nil {
-				fmt.Printf("Aborting, server version error\n", sv)
-				fmt.Printf("Use -f to force upgrade.\n")
-				return nil
-			}
-			if !newer {
-				fmt.Printf("Aborting, server version %s is not newer\n", sv)
-				fmt.Printf("Use -f to force upgrade.\n")
-				return nil
-			}
-		}
+	qv, err := getVerQSPI(q)
+	if err != nil && !f {
+		return err
+	}
+	if ok, err := manifest.CheckVersion("QSPI", qv, a, f); !ok {
+		return err
 	}
 
 	selectQSPI(q)
@@ -19,6 +19,7 @@ import (
 	"github.com/platinasystems/go/goes/cmd"
 	"github.com/platinasystems/go/goes/lang"
 	"github.com/platinasystems/go/internal/eeprom"
+	"github.com/platinasystems/go/internal/thermal"
 	"github.com/platinasystems/log"
 	"github.com/platinasystems/redis"
 	"github.com/platinasystems/redis/publisher"
@@ -267,6 +268,7 @@ func (c *Command) update() error {
 			c.pub.Print(k, ": ", v)
 			c.lasts[k] = v
 		}
+		c.pub.Print(k, thermal.TimeSuffix, ": ", time.Now().Unix())
 
 		k = "fan_tray." + strconv.Itoa(j+1) + ".hwmon.rear.temp.units.C"
 		v, err = Vdev[j].RearTemp()
@@ -277,6 +279,7 @@ func (c *Command) update() error {
 			c.pub.Print(k, ": ", v)
 			c.lasts[k] = v
 		}
+		c.pub.Print(k, thermal.TimeSuffix, ": ", time.Now().Unix())
 
 		k = "fan_tray." + strconv.Itoa(j+1) + ".hwmon.temp.target.units.C"
 		x, err := Vdev[j].GetHwmTarget()
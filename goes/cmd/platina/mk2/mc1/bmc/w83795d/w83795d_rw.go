@@ -7,10 +7,9 @@
 package w83795d
 
 import (
-	"net/rpc"
-	"time"
 	"unsafe"
 
+	"github.com/platinasystems/go/internal/i2cbus"
 	"github.com/platinasystems/i2c"
 	"github.com/platinasystems/log"
 )
@@ -45,12 +44,9 @@ var dummy byte
 var regsPointer = unsafe.Pointer(&dummy)
 var regsAddr = uintptr(unsafe.Pointer(&dummy))
 
-var clientA *rpc.Client
-var dialed int = 0
-
 func getRegsIo() *regsIo {
-        clearJ()
-        return (*regsIo)(regsPointer)
+	clearJ()
+	return (*regsIo)(regsPointer)
 }
 
 func getRegsBank0() *regsBank0 {
@@ -72,8 +68,8 @@ func closeMux(h *I2cDev) {
 	data[0] = byte(0)
 	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
 	x++
-        j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus, h.MuxAddr, 0}
-        x++
+	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus, h.MuxAddr, 0}
+	x++
 }
 
 func (r *reg8) get(h *I2cDev) {
@@ -82,9 +78,9 @@ func (r *reg8) get(h *I2cDev) {
 	data[0] = byte(h.MuxValue)
 	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus, h.MuxAddr, 0}
 	x++
-        data[0] = byte(h.MuxValue2)
-        j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
-        x++
+	data[0] = byte(h.MuxValue2)
+	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
+	x++
 	j[x] = I{true, i2c.Read, r.offset(), i2c.ByteData, data, h.Bus, h.Addr, 0}
 	x++
 }
@@ -95,9 +91,9 @@ func (r *reg16) get(h *I2cDev) {
 	data[0] = byte(h.MuxValue)
 	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus, h.MuxAddr, 0}
 	x++
-        data[0] = byte(h.MuxValue2)
-        j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
-        x++
+	data[0] = byte(h.MuxValue2)
+	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
+	x++
 	j[x] = I{true, i2c.Read, r.offset(), i2c.WordData, data, h.Bus, h.Addr, 0}
 	x++
 }
@@ -108,9 +104,9 @@ func (r *reg16r) get(h *I2cDev) {
 	data[0] = byte(h.MuxValue)
 	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus, h.MuxAddr, 0}
 	x++
-        data[0] = byte(h.MuxValue2)
-        j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
-        x++
+	data[0] = byte(h.MuxValue2)
+	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
+	x++
 	j[x] = I{true, i2c.Read, r.offset(), i2c.WordData, data, h.Bus, h.Addr, 0}
 	x++
 }
@@ -121,9 +117,9 @@ func (r *reg8) set(h *I2cDev, v uint8) {
 	data[0] = byte(h.MuxValue)
 	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus, h.MuxAddr, 0}
 	x++
-        data[0] = byte(h.MuxValue2)
-        j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
-        x++
+	data[0] = byte(h.MuxValue2)
+	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
+	x++
 	data[0] = v
 	j[x] = I{true, i2c.Write, r.offset(), i2c.ByteData, data, h.Bus, h.Addr, 0}
 	x++
@@ -135,9 +131,9 @@ func (r *reg16) set(h *I2cDev, v uint16) {
 	data[0] = byte(h.MuxValue)
 	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus, h.MuxAddr, 0}
 	x++
-        data[0] = byte(h.MuxValue2)
-        j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
-        x++
+	data[0] = byte(h.MuxValue2)
+	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
+	x++
 	data[0] = uint8(v >> 8)
 	data[1] = uint8(v)
 	j[x] = I{true, i2c.Write, r.offset(), i2c.WordData, data, h.Bus, h.Addr, 0}
@@ -150,9 +146,9 @@ func (r *reg16r) set(h *I2cDev, v uint16) {
 	data[0] = byte(h.MuxValue)
 	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus, h.MuxAddr, 0}
 	x++
-        data[0] = byte(h.MuxValue2)
-        j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
-        x++
+	data[0] = byte(h.MuxValue2)
+	j[x] = I{true, i2c.Write, 0, i2c.ByteData, data, h.MuxBus2, h.MuxAddr2, 0}
+	x++
 	data[1] = uint8(v >> 8)
 	data[0] = uint8(v)
 	j[x] = I{true, i2c.Write, r.offset(), i2c.WordData, data, h.Bus, h.Addr, 0}
@@ -167,31 +163,25 @@ func clearJ() {
 }
 
 func readStopped() byte {
-	var data = [34]byte{0, 0, 0, 0}
-	j[0] = I{true, i2c.Write, 0, i2c.ByteData, data, int(0x98), int(0), 0}
-	err := DoI2cRpc()
-	if err != nil {
+	if i2cbus.Stopped() {
 		return 1
 	}
-	return s[0].D[0]
+	return 0
 }
 
 func DoI2cRpc() error {
-	if dialed == 0 {
-		client, err := rpc.DialHTTP("tcp", "127.0.0.1"+":1233")
-		if err != nil {
-			log.Print("dialing:", err)
-			return err
-		}
-		clientA = client
-		dialed = 1
-		time.Sleep(time.Millisecond * time.Duration(50))
+	var g [MAXOPS]i2cbus.I
+	var f [MAXOPS]i2cbus.R
+	for k := range j {
+		g[k] = i2cbus.I(j[k])
 	}
-	err := clientA.Call("I2cReq.ReadWrite", &j, &s)
-	if err != nil {
+	if err := i2cbus.ReadWrite(&g, &f); err != nil {
 		log.Print("i2cReq error:", err)
 		return err
 	}
+	for k := range s {
+		s[k] = R(f[k])
+	}
 	clearJ()
 	return nil
 }
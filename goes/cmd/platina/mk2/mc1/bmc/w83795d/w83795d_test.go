@@ -0,0 +1,65 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package w83795d
+
+import (
+	"testing"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/go/internal/i2cbus/i2cbustest"
+)
+
+var testDev = I2cDev{
+	Bus:       0,
+	Addr:      0x2f,
+	MuxBus:    0,
+	MuxAddr:   0x76,
+	MuxValue:  0x80,
+	MuxBus2:   0,
+	MuxAddr2:  0x77,
+	MuxValue2: 0x01,
+}
+
+func TestHwm(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/hwm.sim")
+	h := testDev
+
+	if v, err := h.FrontTemp(); err != nil || v != "38.250" {
+		t.Errorf("FrontTemp: %q, %v", v, err)
+	}
+	if v, err := h.RearTemp(); err != nil || v != "40.250" {
+		t.Errorf("RearTemp: %q, %v", v, err)
+	}
+	if rpm, err := h.FanCount(1, 0); err != nil || rpm != 3068 {
+		t.Errorf("FanCount: %d, %v", rpm, err)
+	}
+	if err := h.SetFanDuty(0x80); err != nil {
+		t.Fatal(err)
+	}
+	if v := sim.Device(0, 0x2f).Byte(2, 0x11); v != 0x80 {
+		t.Errorf("fan output value: %#x", v)
+	}
+	if d, err := h.GetFanDuty(); err != nil || d != 0x80 {
+		t.Errorf("GetFanDuty: %#x, %v", d, err)
+	}
+}
+
+func TestHwmFaults(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/hwm.sim")
+	h := testDev
+
+	// the second mux NAKs, so the monitor is unreachable
+	sim.Device(0, 0x77).Inject(i2cbus.Nak, 1)
+	if _, err := h.FrontTemp(); err == nil {
+		t.Error("FrontTemp behind NAKing mux")
+	}
+	sim.Device(0, 0x2f).Inject(i2cbus.Stuck, 1)
+	if _, err := h.FanCount(1, 0); err == nil {
+		t.Error("FanCount of stuck bus")
+	}
+	if _, err := h.FanCount(1, 0); err != nil {
+		t.Error("FanCount after stuck bus:", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/i2cbus"
 	"github.com/platinasystems/gpio"
 	"github.com/platinasystems/i2c"
 	"github.com/platinasystems/redis"
@@ -58,9 +59,7 @@ func (cmd) Main(args ...string) error {
 		}
 
 		//i2c STOP
-		sd[0] = 0
-		j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(1), 0}
-		err := DoI2cRpc()
+		err := i2cbus.Pause()
 		if err != nil {
 			return err
 		}
@@ -83,9 +82,7 @@ func (cmd) Main(args ...string) error {
 		time.Sleep(10 * time.Millisecond)
 
 		//i2c START
-		sd[0] = 0
-		j[0] = I{true, i2c.Write, 0, 0, sd, int(0x99), int(0), 0}
-		err = DoI2cRpc()
+		err = i2cbus.Resume()
 		if err != nil {
 			return err
 		}
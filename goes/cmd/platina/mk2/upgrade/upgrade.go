@@ -9,10 +9,10 @@ package upgrade
 
 import (
 	"fmt"
-	"io/ioutil"
 
 	"github.com/platinasystems/go/goes/lang"
 	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/manifest"
 	"github.com/platinasystems/go/internal/parms"
 )
 
@@ -27,21 +27,26 @@ DESCRIPTION
 	The default upgrade version is "LATEST". 
 	Or specify a version using "-v", in the form YYYYMMDD
 
-	The -l flag display version of selected server and version.
+	The -l flag displays the manifest of selected server and version.
 
 	The -r flag prints a report on current version numbers.
 
 	By default, images are downloaded from "downloads.platina.com".
 	Or from a server using "-s" followed by a URL or IPv4 address.
 
-	Upgrade proceeds only if the selected version number is newer,
-	unless overridden with the "-f" force flag.
+	Each release has a MANIFEST of its images with their version,
+	size, SHA-256 and target; MANIFEST.sig is its ed25519 signature.
+	Upgrade proceeds only if the manifest's signature is valid, each
+	downloaded image matches its size and SHA-256, and the image
+	version is newer than the known current version, unless
+	overridden with the "-f" force flag. The force flag doesn't
+	override the signature or integrity.
 
 OPTIONS
 	-v [VER]          version [YYYYMMDD] or LATEST (default)
 	-s [SERVER[/dir]] IP4 or URL, default is downloads.platina.com
 	-t                use TFTP instead of HTTP
-	-l                display version of selected server and version
+	-l                display manifest of selected server and version
 	-r                report current versions of goes, kernel, coreboot
 	-g                upgrade goes
 	-k                upgrade kernel
@@ -60,6 +65,11 @@ OPTIONS
 	GoesInstaller = "goes-" + Machine + "-installer"
 	KernelName    = "linux-image-" + Machine
 	CorebootName  = "coreboot-" + Mach + ".rom"
+
+	// manifest artifact targets
+	TargetGoes     = "goes"
+	TargetKernel   = "kernel"
+	TargetCoreboot = "coreboot"
 )
 
 type Interface interface {
@@ -132,19 +142,19 @@ var (
 	}
 )
 var Install_flag bool = false
+var Installer string = GoesInstaller
 var Reboot_flag bool = false
 
 func showList(s string, v string, t bool) error {
-	fn := "LIST"
-	_, err := getFile(s, v, t, fn)
+	m, err := manifest.Get(s, v, t)
 	if err != nil {
 		return err
 	}
-	l, err := ioutil.ReadFile(fn)
-	if err != nil {
-		return err
+	fmt.Printf("%s %s\n", m.Machine, m.Version)
+	for _, a := range m.Artifacts {
+		fmt.Printf("    %-10s %-32s %-24s %10d %.12s\n", a.Target,
+			a.Name, a.Version, a.Size, a.Sha256)
 	}
-	fmt.Print(string(l))
 	return nil
 }
 
@@ -158,19 +168,26 @@ func reportVersions() error {
 
 func doUpgrade(s string, v string, t bool, g bool, k bool,
 	c bool, f bool) error {
+	m, err := manifest.Get(s, v, t)
+	if err != nil {
+		return err
+	}
+	if err = m.Check(Machine); err != nil {
+		return err
+	}
 	fmt.Print("\n")
 	if g {
-		if err := upgradeGoes(s, v, t, f); err != nil {
+		if err := upgradeGoes(m, s, v, t, f); err != nil {
 			return err
 		}
 	}
 	if k {
-		if err := upgradeKernel(s, v, t, f); err != nil {
+		if err := upgradeKernel(m, s, v, t, f); err != nil {
 			return err
 		}
 	}
 	if c {
-		if err := upgradeCoreboot(s, v, t, f); err != nil {
+		if err := upgradeCoreboot(m, s, v, t, f); err != nil {
 			return err
 		}
 	}
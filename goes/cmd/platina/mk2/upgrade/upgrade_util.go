@@ -14,6 +14,7 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/platinasystems/go/internal/manifest"
 	"github.com/platinasystems/go/internal/url"
 )
 
@@ -70,15 +71,15 @@ func prn(d string, s string) {
 
 func getGoesInfo() (im IMGINFO, err error) {
 	im.Name = GoesName
-	im.Build = getGoesVal("generated.on")
-	im.User = getGoesVal("generated.by")
 	fi, err := os.Stat(GoesBin)
 	if err != nil {
 		return im, err
 	}
 	im.Size = fmt.Sprintf("%d", fi.Size())
-	im.Tag = getGoesVal("tag")
-	im.Commit = getGoesVal("version")
+	if a := manifest.Installed(TargetGoes); a != nil {
+		im.Tag = a.Version
+		im.Chksum = a.Sha256
+	}
 	return im, nil
 }
 
@@ -131,116 +132,73 @@ func getCorebootInfo() (im IMGINFO, err error) {
 	return im, nil
 }
 
-func upgradeGoes(s string, v string, t bool, f bool) error {
+func upgradeGoes(m *manifest.Manifest, s string, v string, t bool,
+	f bool) error {
 	fmt.Printf("Update Goes\n")
-	if !f {
-		g := getGoesVer()
-		gr, err := getSrvGoesVer(s, v, t)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("    Goes version currently:  %s\n", g)
-		fmt.Printf("    Goes version on server:  %s\n", gr)
-		if g == gr {
-			fmt.Print("    Versions match, skipping Goes upgrade\n\n")
-			return nil
-		}
-		if len(g) == 0 || len(gr) == 0 {
-			fmt.Print("    No tag found, aborting Goes upgrade\n\n")
-			return nil
-		}
+	a := m.Lookup(TargetGoes)
+	if a == nil {
+		fmt.Print("    Not in manifest, skipping Goes upgrade\n\n")
+		return nil
+	}
+	if ok, err := manifest.CheckVersion("Goes", installedVer(TargetGoes),
+		a, f); !ok {
+		return err
 	}
 
-	if err := installGoes(s, v, t); err != nil {
+	if err := installGoes(a, s, v, t); err != nil {
 		return err
 	}
 	return nil
 }
 
-func upgradeKernel(s string, v string, t bool, f bool) error {
+func upgradeKernel(m *manifest.Manifest, s string, v string, t bool,
+	f bool) error {
 	fmt.Printf("Update Kernel\n")
-	kr, fn, err := getSrvKernelVer(s, v, t)
+	a := m.Lookup(TargetKernel)
+	if a == nil {
+		fmt.Print("    Not in manifest, skipping Kernel upgrade\n\n")
+		return nil
+	}
+	k, err := getKernelVer()
 	if err != nil {
 		return err
 	}
-	if !f {
-		k, err := getKernelVer()
-		if err != nil {
-			return err
-		}
-		fmt.Printf("    Kernel version currently:  %s\n", k)
-		fmt.Printf("    Kernel version on server:  %s\n", kr)
-		if k == kr {
-			fmt.Print("    Versions match, skipping Kernel upgrade\n\n")
-			return nil
-		}
+	if ok, err := manifest.CheckVersion("Kernel", k, a, f); !ok {
+		return err
 	}
 
-	if err := installKernel(s, v, t, fn); err != nil {
+	if err := installKernel(a, s, v, t); err != nil {
 		return err
 	}
 	return nil
 }
 
-func upgradeCoreboot(s string, v string, t bool, f bool) error {
+func upgradeCoreboot(m *manifest.Manifest, s string, v string, t bool,
+	f bool) error {
 	fmt.Printf("Update Coreboot\n")
-	if !f {
-		c, err := getCorebootVer()
-		if err != nil {
-			return err
-		}
-		cr, err := getSrvCorebootVer(s, v, t)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("    Coreboot version currently:  %s\n", c)
-		fmt.Printf("    Coreboot version on server:  %s\n", cr)
-		if c == cr {
-			fmt.Print("    Versions match, skipping Coreboot upgrade\n\n")
-		}
+	a := m.Lookup(TargetCoreboot)
+	if a == nil {
+		fmt.Print("    Not in manifest, skipping Coreboot upgrade\n\n")
+		return nil
 	}
-
-	if err := installCoreboot(s, v, t); err != nil {
+	if ok, err := manifest.CheckVersion("Coreboot",
+		installedVer(TargetCoreboot), a, f); !ok {
 		return err
 	}
-	return nil
-}
 
-func getGoesVer() (v string) {
-	ar := "tag"
-	maps := []map[string]string{Package}
-	if Packages != nil {
-		maps = append(maps, Packages()...)
-	}
-	for _, m := range maps {
-		if ip, found := m["importpath"]; found {
-			k := strings.TrimLeft(ar, "-")
-			if val, found := m[k]; found {
-				if strings.Contains(ip, "/go") {
-					v = strings.Replace(val, "'", "", 1)
-				}
-			}
-		}
+	if err := installCoreboot(a, s, v, t); err != nil {
+		return err
 	}
-	return v
+	return nil
 }
 
-func getGoesVal(ar string) (v string) {
-	maps := []map[string]string{Package}
-	if Packages != nil {
-		maps = append(maps, Packages()...)
-	}
-	for _, m := range maps {
-		if ip, found := m["importpath"]; found {
-			k := strings.TrimLeft(ar, "-")
-			if val, found := m[k]; found {
-				if strings.Contains(ip, "/go") {
-					v = strings.Replace(val, "'", "", 1)
-				}
-			}
-		}
+// installedVer returns the version of the target's last installed
+// artifact, or empty if unknown.
+func installedVer(target string) string {
+	if a := manifest.Installed(target); a != nil {
+		return a.Version
 	}
-	return v
+	return ""
 }
 
 func getKernelVer() (string, error) {
@@ -259,81 +217,25 @@ func getKernelVal(ar string) (string, error) {
 	return strings.TrimSpace(string(u)), nil
 }
 
-func getCorebootVer() (string, error) { //TODO
-	return "no_tag", nil
-}
-
-func getSrvGoesVer(s string, v string, t bool) (string, error) {
-	fn := GoesName
-
-	n, err := getFile(s, v, t, fn)
-	if err != nil {
-		return "", fmt.Errorf("Error downloading: %v", err)
-	}
-	if n < 1000 {
-		return "", fmt.Errorf("Error file too small: %v", err)
-	}
-	x := ""
-	a, err := ioutil.ReadFile(fn)
-	if err != nil {
-		return "", err
-	}
-	as := string(a)
-	ref := regexp.MustCompile("v([0-9])[.]([0-9])-([0-9]+)-g([0-9a-fA-F]+)")
-	x = ref.FindString(as)
-	if len(x) == 0 {
-		ree := regexp.MustCompile("v([0-9])[.]([0-9])")
-		x = ree.FindString(as)
-	}
-	rmFile(fn)
-	return x, nil
-}
-
-func getSrvKernelVer(s string, v string, t bool) (string, string, error) {
-	fn := KernelName
-	n, err := getFile(s, v, t, fn)
-	if err != nil {
-		return "", "", fmt.Errorf("Error downloading: %v", err)
-	}
-	if n < 10 {
-		return "", "", fmt.Errorf("Error file too small: %v", err)
-	}
-	a, err := ioutil.ReadFile(fn)
-	if err != nil {
-		return "", "", err
-	}
-	u := strings.Split(string(a), "\n")
-	return strings.TrimSpace(u[0]), strings.TrimSpace(u[1]), nil
-}
-
-func getSrvCorebootVer(s string, v string, t bool) (string, error) { //TODO
-	return "no_tag", nil
-}
-
-func installGoes(s string, v string, t bool) error {
-	fn := GoesInstaller
-	n, err := getFile(s, v, t, fn)
-	if err != nil {
+func installGoes(a *manifest.Artifact, s string, v string, t bool) error {
+	if err := a.Download(s, v, t, a.Name); err != nil {
 		return fmt.Errorf("    Error downloading: %v", err)
 	}
-	if n < 1000 {
-		return fmt.Errorf("    Error file too small: %v", err)
+	if err := manifest.Record(a); err != nil {
+		return err
 	}
-
+	Installer = a.Name
 	Install_flag = true
 	return nil
 }
 
-func installKernel(s string, v string, t bool, fn string) error {
-	n, err := getFile(s, v, t, fn)
-	if err != nil {
+func installKernel(a *manifest.Artifact, s string, v string, t bool) error {
+	fn := a.Name
+	if err := a.Download(s, v, t, fn); err != nil {
 		return fmt.Errorf("    Error downloading: %v", err)
 	}
-	if n < 1000 {
-		return fmt.Errorf("    Error file too small: %v", err)
-	}
 
-	_, err = exec.Command("dpkg", "-i", fn).Output()
+	_, err := exec.Command("dpkg", "-i", fn).Output()
 	if err != nil {
 		return err
 	}
@@ -348,18 +250,28 @@ func installKernel(s string, v string, t bool, fn string) error {
 		return err
 	}
 
+	if err = manifest.Record(a); err != nil {
+		return err
+	}
 	Reboot_flag = true
 	return nil
 }
 
-func installCoreboot(s string, v string, t bool) error {
+func installCoreboot(a *manifest.Artifact, s string, v string, t bool) error {
+	if err := a.Download(s, v, t, a.Name); err != nil {
+		return fmt.Errorf("    Error downloading: %v", err)
+	}
+	defer rmFile(a.Name)
 	_, err := exec.Command("/usr/local/sbin/flashrom", "-p",
 		"internal:boardmismatch=force", "-l",
 		"/usr/local/share/flashrom/layouts/platina-mk1.xml",
-		"-i", "bios", "-w", "coreboot.rom", "-A", "-V").Output()
+		"-i", "bios", "-w", a.Name, "-A", "-V").Output()
 	if err != nil {
 		return err
 	}
+	if err = manifest.Record(a); err != nil {
+		return err
+	}
 	Reboot_flag = true
 	return nil
 }
@@ -429,7 +341,7 @@ func reboot() error {
 
 func activateGoes() error {
 	fmt.Print("\nACTIVATING GOES, WILL EXIT... type reset, goes\n")
-	cmd := exec.Command("./" + GoesInstaller)
+	cmd := exec.Command("./" + Installer)
 	cmd.Start()
 	return nil
 }
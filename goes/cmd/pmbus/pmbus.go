@@ -0,0 +1,228 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package pmbus provides a cli command to read and write PMBus devices.
+package pmbus
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/go/internal/parms"
+	"github.com/platinasystems/go/internal/pmbus"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "pmbus" }
+
+func (Command) Usage() string {
+	return "pmbus [-dev] [-pec] [-sim FILE] [-page N] BUS.ADDR [CMD[=VALUE]]..."
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "read/write PMBus devices",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Read or write the standard commands of a PMBus device, decoding
+	LINEAR11 and VOUT_MODE values, MFR strings and STATUS registers.
+	Without CMD, print every readable command that the device supports
+	followed by the named faults of its STATUS registers.
+
+	CMD is a standard command name, e.g. READ_VOUT, or code, e.g. 0x8b.
+	The code of a manufacturer specific command may have a /8, /16 or /b
+	suffix to read or write its byte, word or block.
+
+	VALUE is a number or a string of block commands. Send byte commands,
+	e.g. CLEAR_FAULTS, are given without a value.
+
+	Examples:
+	    pmbus 1.58                    print all
+	    pmbus 1.58 READ_VOUT mfr_id   print output voltage and vendor
+	    pmbus -page 2 0.7e READ_VOUT  print rail 3 of a sequencer
+	    pmbus 1.58 VOUT_COMMAND=12.1  set output voltage
+	    pmbus 1.58 CLEAR_FAULTS
+	    pmbus 1.58 0xd0/16            read manufacturer word
+
+OPTIONS
+	-dev	use /dev/i2c-BUS rather than i2cd
+	-pec	append and check packet error codes
+	-sim FILE
+		simulate the devices of the script; see internal/i2cbus.Sim
+	-page N
+		select the page before the commands`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-dev", "-pec")
+	parm, args := parms.New(args, "-sim", "-page")
+	if len(args) == 0 {
+		return fmt.Errorf("BUS.ADDR: missing")
+	}
+	var bus, addr int
+	if _, err := fmt.Sscanf(args[0], "%x.%x", &bus, &addr); err != nil {
+		return fmt.Errorf("%s: invalid BUS.ADDR: %v", args[0], err)
+	}
+	args = args[1:]
+
+	d := &pmbus.Device{
+		Bus:     bus,
+		Addr:    addr,
+		PEC:     flag.ByName["-pec"],
+		Backend: i2cbus.Remote{},
+	}
+	if flag.ByName["-dev"] {
+		d.Backend = i2cbus.Default
+	}
+	if fn := parm.ByName["-sim"]; len(fn) > 0 {
+		sim, err := i2cbus.LoadSim(fn)
+		if err != nil {
+			return err
+		}
+		d.Backend = sim
+	}
+	if s := parm.ByName["-page"]; len(s) > 0 {
+		page, err := strconv.ParseUint(s, 0, 8)
+		if err != nil {
+			return fmt.Errorf("%s: invalid page: %v", s, err)
+		}
+		if err = d.SetPage(uint8(page)); err != nil {
+			return err
+		}
+	}
+	if len(args) == 0 {
+		return dump(os.Stdout, d)
+	}
+	for _, arg := range args {
+		if err := do(os.Stdout, d, arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dump the readable commands that the device supports and its faults.
+func dump(w io.Writer, d *pmbus.Device) error {
+	var cmds []pmbus.Cmd
+	for c, info := range pmbus.Commands {
+		if info.Access&pmbus.R != 0 {
+			cmds = append(cmds, c)
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i] < cmds[j] })
+	n := 0
+	for _, c := range cmds {
+		v, err := d.Read(c)
+		if err != nil {
+			continue
+		}
+		n++
+		fmt.Fprintf(w, "%02x %-24s %s\n", uint8(c), c, v)
+	}
+	if n == 0 {
+		return fmt.Errorf("%d.%#x: no response", d.Bus, d.Addr)
+	}
+	faults, err := d.Faults()
+	if err != nil {
+		return err
+	}
+	for _, fault := range faults {
+		fmt.Fprintln(w, "fault:", fault)
+	}
+	return nil
+}
+
+// do the read or write of the command argument.
+func do(w io.Writer, d *pmbus.Device, arg string) error {
+	s, value, write := arg, "", false
+	if i := strings.Index(arg, "="); i >= 0 {
+		s, value, write = arg[:i], arg[i+1:], true
+	}
+	suffix := ""
+	if i := strings.Index(s, "/"); i >= 0 {
+		s, suffix = s[:i], s[i+1:]
+	}
+	c, found := pmbus.Lookup(s)
+	if !found {
+		return fmt.Errorf("%s: unknown command", s)
+	}
+	if len(suffix) > 0 {
+		return raw(w, d, c, suffix, value, write)
+	}
+	if write {
+		return d.Write(c, value)
+	}
+	if info := pmbus.Commands[c]; info.Size == pmbus.Send {
+		return d.Send(c)
+	}
+	v, err := d.Read(c)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%02x %-24s %s\n", uint8(c), c, v)
+	return nil
+}
+
+// raw read or write of the command's byte, word or block.
+func raw(w io.Writer, d *pmbus.Device, c pmbus.Cmd, suffix, value string,
+	write bool) error {
+	var bits int
+	switch suffix {
+	case "8":
+		bits = 8
+	case "16":
+		bits = 16
+	case "b":
+		if write {
+			return d.WriteBlockData(c, []byte(value))
+		}
+		b, err := d.ReadBlockData(c)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%02x %-24s % x %q\n", uint8(c), c, b,
+			pmbus.Text(b))
+		return nil
+	default:
+		return fmt.Errorf("%s: invalid width", suffix)
+	}
+	if write {
+		u, err := strconv.ParseUint(value, 0, bits)
+		if err != nil {
+			return fmt.Errorf("%s: invalid: %v", value, err)
+		}
+		if bits == 8 {
+			return d.WriteByteData(c, uint8(u))
+		}
+		return d.WriteWordData(c, uint16(u))
+	}
+	var u uint16
+	var err error
+	if bits == 8 {
+		var b byte
+		b, err = d.ReadByteData(c)
+		u = uint16(b)
+	} else {
+		u, err = d.ReadWordData(c)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%02x %-24s 0x%0*x\n", uint8(c), c, bits/4, u)
+	return nil
+}
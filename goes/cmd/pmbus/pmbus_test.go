@@ -0,0 +1,61 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pmbus
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/go/internal/pmbus"
+)
+
+func TestDo(t *testing.T) {
+	sim := i2cbus.NewSim()
+	err := sim.Load(strings.NewReader(`
+device 1 0x58 psu
+byte 0x20 0x17
+word 0x8b 0x1800
+word 0x79 0x0040
+block 0x99 "FSP"
+word 0xd0 0xbeef
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &pmbus.Device{Bus: 1, Addr: 0x58, Backend: sim}
+
+	var out bytes.Buffer
+	for _, arg := range []string{"read_vout", "MFR_ID", "0xd0/16"} {
+		if err = do(&out, d, arg); err != nil {
+			t.Fatal(arg, err)
+		}
+	}
+	want := `8b READ_VOUT                12.000 V
+99 MFR_ID                   FSP
+d0 MFR_SPECIFIC_00          0xbeef
+`
+	if s := out.String(); s != want {
+		t.Errorf("got:\n%swant:\n%s", s, want)
+	}
+	if err = do(&out, d, "VOUT_COMMAND=5"); err != nil {
+		t.Fatal(err)
+	}
+	if w := sim.Device(1, 0x58).Word(0, 0x21); w != 0x0a00 {
+		t.Errorf("VOUT_COMMAND: %#x", w)
+	}
+	if err = do(&out, d, "READ_VOLTS"); err == nil {
+		t.Error("READ_VOLTS")
+	}
+
+	out.Reset()
+	if err = dump(&out, d); err != nil {
+		t.Fatal(err)
+	}
+	if s := out.String(); !strings.Contains(s, "fault: STATUS_WORD.OFF") {
+		t.Error("dump:\n", s)
+	}
+}
@@ -7,21 +7,24 @@
 package telnetd
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/kr/pty"
 	"github.com/platinasystems/go/goes/cmd"
 	"github.com/platinasystems/go/goes/lang"
-	"github.com/platinasystems/go/internal/telnet/command"
+	"github.com/platinasystems/go/internal/telnet"
 	"github.com/platinasystems/go/internal/telnet/option"
 )
 
+const negotiationTimeout = 2 * time.Second
+
 type Command struct{}
 
 func (Command) String() string { return "telnetd" }
@@ -52,20 +55,50 @@ func (Command) Main(args ...string) error {
 		if err != nil {
 			return err
 		}
-		optionNegotiation(conn)
+		tc := telnet.Server(conn)
+		for _, opt := range []byte{
+			option.ECHO,
+			option.SGA,
+			option.BINARY,
+		} {
+			tc.Will(opt)
+		}
+		for _, opt := range []byte{
+			option.SGA,
+			option.BINARY,
+			option.NAWS,
+			option.TYPE,
+			option.NEWENV,
+		} {
+			tc.Do(opt)
+		}
+		if err = tc.Negotiate(negotiationTimeout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			conn.Close()
+			continue
+		}
 
 		pts, tty, err := pty.Open()
 		if err != nil {
 			return err
 		}
+		if tc.Cols > 0 && tc.Rows > 0 {
+			pty.Setsize(pts, &pty.Winsize{
+				Cols: tc.Cols,
+				Rows: tc.Rows,
+			})
+		}
+		tc.Resize = func(cols, rows uint16) {
+			pty.Setsize(pts, &pty.Winsize{
+				Cols: cols,
+				Rows: rows,
+			})
+		}
 		proc, err := os.StartProcess("/bin/goes",
 			[]string{"goes"},
 			&os.ProcAttr{
 				Dir: dir,
-				Env: []string{
-					"PATH=/usr/bin:/bin",
-					"TERM=xterm",
-				},
+				Env: environ(tc),
 				Files: []*os.File{
 					tty,
 					tty,
@@ -82,10 +115,11 @@ func (Command) Main(args ...string) error {
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			pts.Close()
+			conn.Close()
 			continue
 		}
-		go func() { io.Copy(conn, pts) }()
-		go nullFilteredCopy(pts, conn)
+		go func() { io.Copy(tc, pts) }()
+		go func() { io.Copy(pts, tc) }()
 		go func() {
 			proc.Wait()
 			pts.Close()
@@ -95,60 +129,22 @@ func (Command) Main(args ...string) error {
 	return nil
 }
 
-func optionNegotiation(conn net.Conn) error {
-	ack := make([]byte, 1024)
-	conn.Write([]byte{command.IAC, command.WILL, option.ECHO})
-	n, err := conn.Read(ack)
-	if err != nil {
-		return err
-	}
-	if 0 != bytes.Compare(ack[:n],
-		[]byte{command.IAC, command.DO, option.ECHO}) {
-		conn.Write([]byte("telnet WILL ECHO Failed"))
-		return nil
+// environ returns the shell environment with the client's terminal type and
+// those of its NEW-ENVIRON variables that are safe to pass.
+func environ(tc *telnet.Conn) []string {
+	term := "xterm"
+	if len(tc.Term) > 0 {
+		term = strings.ToLower(tc.Term)
 	}
-	conn.Write([]byte{
-		command.IAC, command.DONT, option.ECHO,
-		command.IAC, command.WILL, option.SGA,
-	})
-	n, err = conn.Read(ack)
-	if err != nil {
-		return err
-	}
-	if 0 != bytes.Compare(ack[:n],
-		[]byte{command.IAC, command.DO, option.SGA}) {
-		conn.Write([]byte("telnet WILL SGA failed"))
-		return nil
-	}
-	conn.Write([]byte{command.IAC, command.DO, option.SGA})
-	n, err = conn.Read(ack)
-	if err != nil {
-		return err
+	env := []string{
+		"PATH=/usr/bin:/bin",
+		"TERM=" + term,
 	}
-	if 0 != bytes.Compare(ack[:n],
-		[]byte{command.IAC, command.WILL, option.SGA}) {
-		conn.Write([]byte("telnet DO SGA failed"))
-		return nil
-	}
-	conn.Write([]byte{command.IAC, command.WONT, option.LINEMODE})
-	return nil
-}
-
-func nullFilteredCopy(w io.Writer, r io.Reader) {
-	buf := make([]byte, 4096)
-	for {
-		n, err := r.Read(buf)
-		if err != nil {
-			return
-		}
-		if n <= 0 {
-			continue
-		}
-		if buf[n-1] == 0 {
-			n -= 1
-		}
-		if _, err = w.Write(buf[:n]); err != nil {
-			return
+	for name, value := range tc.Env {
+		if name == "DISPLAY" || name == "LANG" || name == "TZ" ||
+			strings.HasPrefix(name, "LC_") {
+			env = append(env, name+"="+value)
 		}
 	}
+	return env
 }
@@ -0,0 +1,132 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package tftpd serves a directory with TFTP, for example, to stage images
+// for line cards.
+package tftpd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/platinasystems/go/goes/cmd"
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/parms"
+	"github.com/platinasystems/go/internal/tftp"
+)
+
+const DefaultRoot = "/srv/tftp"
+
+type Command struct {
+	done chan struct{}
+}
+
+func (*Command) String() string { return "tftpd" }
+
+func (*Command) Usage() string {
+	return "tftpd [-w] [-v] [-root DIR] [-addr ADDRESS] [-max-blksize N]"
+}
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "tftp server daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Serve the files of a directory with TFTP (RFC 1350) including the
+	blksize, timeout, tsize and windowsize options (RFC 2347, 2348, 2349
+	and 7440).
+
+OPTIONS
+	-w	Permit clients to create or replace files.
+
+	-v	Log each transfer to standard error.
+
+	-root DIR
+		Default: ` + DefaultRoot + `
+
+	-addr ADDRESS
+		The UDP [HOST]:PORT to listen on.
+		Default: :69
+
+	-max-blksize N
+		Limit the block size requested by clients, e.g. to the MTU
+		less IP and UDP headers.`,
+	}
+}
+
+func (c *Command) Close() error {
+	close(c.done)
+	return nil
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-w", "-v")
+	parm, args := parms.New(args, "-root", "-addr", "-max-blksize")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	s := &tftp.Server{
+		Root:     DefaultRoot,
+		Writable: flag.ByName["-w"],
+	}
+	if len(parm.ByName["-root"]) > 0 {
+		s.Root = parm.ByName["-root"]
+	}
+	if fi, err := os.Stat(s.Root); err != nil {
+		return err
+	} else if !fi.IsDir() {
+		return fmt.Errorf("%s: not a directory", s.Root)
+	}
+	if v := parm.ByName["-max-blksize"]; len(v) > 0 {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < tftp.MinBlockSize || n > tftp.MaxBlockSize {
+			return fmt.Errorf("%s: invalid -max-blksize", v)
+		}
+		s.MaxBlockSize = n
+	}
+	if flag.ByName["-v"] {
+		s.Logf = func(format string, args ...interface{}) {
+			fmt.Fprintf(os.Stderr, "tftpd: "+format+"\n", args...)
+		}
+	}
+	addr := ":69"
+	if len(parm.ByName["-addr"]) > 0 {
+		addr = parm.ByName["-addr"]
+	}
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", a)
+	if err != nil {
+		return err
+	}
+	c.done = make(chan struct{})
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-c.done:
+		case <-stopped:
+		}
+		conn.Close()
+	}()
+	err = s.Serve(conn)
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+	return err
+}
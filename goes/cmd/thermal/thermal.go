@@ -0,0 +1,83 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package thermal provides a cli command to simulate a fan control policy
+// with recorded temperatures.
+package thermal
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/parms"
+	"github.com/platinasystems/go/internal/thermal"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "thermal" }
+
+func (Command) Usage() string {
+	return "thermal -policy FILE [-tick DURATION] RECORDING"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "simulate fan control policy",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Replay the recorded temperatures and fan tray status through the
+	policy and print the elapsed time, fan duty, zone temperatures and
+	the controlling zone or fault at each tick.
+
+	The RECORDING has a line for each sample of the seconds since its
+	start, the redis field and value; a field without value is no longer
+	reported. See internal/thermal for the policy format.
+
+	Example:
+	    thermal -policy /etc/goes/thermal.json hot-host.rec
+
+OPTIONS
+	-policy FILE
+		JSON policy of the zones
+	-tick DURATION
+		interval of policy steps (default 10s)`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-policy", "-tick")
+	switch len(args) {
+	case 0:
+		return fmt.Errorf("RECORDING: missing")
+	case 1:
+	default:
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	if len(parm.ByName["-policy"]) == 0 {
+		return fmt.Errorf("-policy: missing")
+	}
+	p, err := thermal.LoadPolicy(parm.ByName["-policy"])
+	if err != nil {
+		return err
+	}
+	tick := 10 * time.Second
+	if s := parm.ByName["-tick"]; len(s) > 0 {
+		if tick, err = time.ParseDuration(s); err != nil {
+			return err
+		}
+	}
+	r, err := thermal.LoadReplay(args[0])
+	if err != nil {
+		return err
+	}
+	return thermal.Simulate(os.Stdout, p, r, tick)
+}
@@ -0,0 +1,90 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package w83795d
+
+import (
+	"time"
+
+	"github.com/platinasystems/go/internal/thermal"
+)
+
+// Thermal control of the fans at auto speed. The host and qsfp zones of
+// its policy are those temperatures written by hset; the others are read
+// from redis. Its settings are also hset as thermal.FIELD, e.g.
+// thermal.host.curve; see thermal.Policy.Set.
+var Thermal = &thermal.Controller{
+	Policy: &thermal.Policy{
+		Zones: []*thermal.Zone{
+			{
+				Name: "hwmon",
+				Sensors: []string{
+					"hwmon.front.temp.units.C",
+					"hwmon.rear.temp.units.C",
+				},
+				Curve: thermal.Curve{
+					{Temp: 45, Duty: 31},
+					{Temp: 55, Duty: 50},
+					{Temp: 65, Duty: 100},
+				},
+				Hyst: 5,
+				Min:  31,
+			},
+			{
+				Name:    "host",
+				Sensors: []string{"host.temp.units.C"},
+				Curve: thermal.Curve{
+					{Temp: 70, Duty: 31},
+					{Temp: 80, Duty: 100},
+				},
+				Hyst: 5,
+				Min:  31,
+			},
+			{
+				Name:    "qsfp",
+				Sensors: []string{"qsfp.temp.units.C"},
+				Curve: thermal.Curve{
+					{Temp: 60, Duty: 31},
+					{Temp: 75, Duty: 100},
+				},
+				Hyst: 5,
+				Min:  31,
+			},
+		},
+		Trays: []string{
+			"fan_tray.1.status",
+			"fan_tray.2.status",
+			"fan_tray.3.status",
+			"fan_tray.4.status",
+		},
+		Stale: 120,
+	},
+}
+
+// hwmSource has the monitor's own temperatures.
+type hwmSource struct{ h *I2cDev }
+
+func (s hwmSource) Get(field string) (thermal.Sample, error) {
+	var v string
+	var err error
+	switch field {
+	case "hwmon.front.temp.units.C":
+		v, err = s.h.FrontTemp()
+	case "hwmon.rear.temp.units.C":
+		v, err = s.h.RearTemp()
+	default:
+		return thermal.Sample{}, thermal.ErrAbsent
+	}
+	return thermal.Sample{Value: v, Time: time.Now()}, err
+}
+
+// control the fan duty by the policy, returning its status.
+func (h *I2cDev) control() (string, error) {
+	r := Thermal.Step(thermal.Sources{hwmSource{h}, thermal.Redis{}},
+		time.Now())
+	if err := h.SetFanDuty(uint8(r.Duty*0xff/100 + 0.5)); err != nil {
+		return "", err
+	}
+	return r.Status(), nil
+}
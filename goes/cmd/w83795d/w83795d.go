@@ -18,6 +18,8 @@ import (
 	"github.com/platinasystems/atsock"
 	"github.com/platinasystems/go/goes/cmd"
 	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/parms"
+	"github.com/platinasystems/go/internal/thermal"
 	"github.com/platinasystems/gpio"
 	"github.com/platinasystems/log"
 	"github.com/platinasystems/redis"
@@ -27,23 +29,10 @@ import (
 )
 
 var (
-	first          int
-	hostTemp       float64
-	sHostTemp      float64
-	hostTempTarget float64
-	hostHyst       float64
-	qsfpTemp       float64
-	sQsfpTemp      float64
-	qsfpTempTarget float64
-	qsfpHyst       float64
-	thTemp         float64
-	sThTemp        float64
+	first int
 
 	lastSpeed string
 
-	hostCtrl bool
-	thCtrl   bool
-
 	Vdev I2cDev
 
 	VpageByKey map[string]uint8
@@ -79,7 +68,7 @@ type I2cDev struct {
 
 func (*Command) String() string { return "w83795d" }
 
-func (*Command) Usage() string { return "w83795d" }
+func (*Command) Usage() string { return "w83795d [-policy FILE] [-replay FILE]" }
 
 func (*Command) Apropos() lang.Alt {
 	return lang.Alt{
@@ -87,34 +76,66 @@ func (*Command) Apropos() lang.Alt {
 	}
 }
 
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Publish the fan speeds and temperatures of the hardware monitor and
+	control its fans.
+
+	At auto speed, the fan duty follows the thermal policy of the monitor,
+	host and qsfp temperatures. A fan tray that isn't ok, a failed sensor
+	or stale temperature forces the safe duty. The policy settings are
+	published and may be hset as thermal.FIELD, e.g.
+
+	    hset platina thermal.host.curve 65:31,80:100
+	    hset platina thermal.qsfp.pid 60,4,0.1,0
+
+OPTIONS
+	-policy FILE
+		Load the JSON thermal policy; see internal/thermal.Policy.
+
+	-replay FILE
+		Control the fans by recorded temperatures; see
+		internal/thermal.Replay for its format.`,
+	}
+}
+
 func (*Command) Kind() cmd.Kind { return cmd.Daemon }
 
-func (c *Command) Main(...string) error {
+func (c *Command) Main(args ...string) error {
 	var si syscall.Sysinfo_t
 
+	parm, args := parms.New(args, "-policy", "-replay")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+
 	if c.Init != nil {
 		c.init.Do(c.Init)
 	}
 
+	if fn := parm.ByName["-policy"]; len(fn) > 0 {
+		p, err := thermal.LoadPolicy(fn)
+		if err != nil {
+			return err
+		}
+		Thermal.Policy = p
+	}
+	if fn := parm.ByName["-replay"]; len(fn) > 0 {
+		r, err := thermal.LoadReplay(fn)
+		if err != nil {
+			return err
+		}
+		Thermal.Replay(r)
+	}
+
 	err := redis.IsReady()
 	if err != nil {
 		return err
 	}
 
 	first = 1
-	hostTemp = 50
-	sHostTemp = 150
-	hostTempTarget = 70
-	hostHyst = 0
-
-	qsfpTemp = 50
-	sQsfpTemp = 150
-	qsfpTempTarget = 60
-	qsfpHyst = 0
-
-	hostCtrl = false
-	sThTemp = 150
-	thCtrl = false
 
 	c.stop = make(chan struct{})
 	c.last = make(map[string]uint16)
@@ -139,6 +160,13 @@ func (c *Command) Main(...string) error {
 			return err
 		}
 	}
+	err = redis.Assign(redis.DefaultHash+":thermal.", "w83795d", "Info")
+	if err != nil {
+		return err
+	}
+	for k, v := range Thermal.Fields() {
+		c.pub.Print(k, ": ", v)
+	}
 
 	t := time.NewTicker(10 * time.Second)
 	for {
@@ -172,6 +200,17 @@ func (c *Command) update() error {
 		first = 0
 	}
 
+	if lastSpeed == "auto" {
+		v, err := Vdev.control()
+		if err != nil {
+			return err
+		}
+		if v != c.lasts["thermal.control"] {
+			c.pub.Print("thermal.control: ", v)
+			c.lasts["thermal.control"] = v
+		}
+	}
+
 	for k, i := range VpageByKey {
 		if strings.Contains(k, "rpm") {
 			v, err := Vdev.FanCount(i)
@@ -440,14 +479,6 @@ func (h *I2cDev) SetLastSpeed() error {
 	return nil
 }
 func (h *I2cDev) SetFanDuty(d uint8) error {
-	for j := 1; j <= maxFanTrays; j++ {
-		p, _ := redis.Hget(redis.DefaultHash, "fan_tray."+strconv.Itoa(int(j))+".status")
-		if p != "" && !strings.Contains(p, "ok") {
-			return nil
-			break
-		}
-	}
-
 	r2 := getRegsBank2()
 	r2.BankSelect.set(h, 0x82)
 	r2.TempToFanMap1.set(h, 0x0)
@@ -483,61 +514,61 @@ func (h *I2cDev) SetFanSpeed(w string, l bool) error {
 
 	switch w {
 	case "auto":
-		if !hostCtrl {
-			r2.BankSelect.set(h, 0x82)
-			//set thermal cruise
-			r2.FanControlModeSelect1.set(h, 0x00)
-			r2.FanControlModeSelect2.set(h, 0x00)
-			//set step up and down time to 1s
-			r2.FanStepUpTime.set(h, 0x0a)
-			r2.FanStepDownTime.set(h, 0x0a)
-			closeMux(h)
-			err := DoI2cRpc()
-			if err != nil {
-				return err
-			}
+		// program thermal cruise until the policy takes control
+		Thermal.Reset()
+		r2.BankSelect.set(h, 0x82)
+		//set thermal cruise
+		r2.FanControlModeSelect1.set(h, 0x00)
+		r2.FanControlModeSelect2.set(h, 0x00)
+		//set step up and down time to 1s
+		r2.FanStepUpTime.set(h, 0x0a)
+		r2.FanStepDownTime.set(h, 0x0a)
+		closeMux(h)
+		err := DoI2cRpc()
+		if err != nil {
+			return err
+		}
 
-			r2.BankSelect.set(h, 0x82)
-			//set fan start speed
-			r2.FanStartValue1.set(h, 0x30)
-			r2.FanStartValue2.set(h, 0x30)
-			//set fan stop speed
-			r2.FanStopValue1.set(h, 0x30)
-			r2.FanStopValue2.set(h, 0x30)
-			closeMux(h)
-			err = DoI2cRpc()
-			if err != nil {
-				return err
-			}
+		r2.BankSelect.set(h, 0x82)
+		//set fan start speed
+		r2.FanStartValue1.set(h, 0x30)
+		r2.FanStartValue2.set(h, 0x30)
+		//set fan stop speed
+		r2.FanStopValue1.set(h, 0x30)
+		r2.FanStopValue2.set(h, 0x30)
+		closeMux(h)
+		err = DoI2cRpc()
+		if err != nil {
+			return err
+		}
 
-			r2.BankSelect.set(h, 0x82)
-			//set fan stop time to never stop
-			r2.FanStopTime1.set(h, 0x0)
-			r2.FanStopTime2.set(h, 0x0)
-			//set target temps to 50°C
-			r2.TargetTemp1.set(h, 0x32)
-			r2.TargetTemp2.set(h, 0x32)
-			closeMux(h)
-			err = DoI2cRpc()
-			if err != nil {
-				return err
-			}
+		r2.BankSelect.set(h, 0x82)
+		//set fan stop time to never stop
+		r2.FanStopTime1.set(h, 0x0)
+		r2.FanStopTime2.set(h, 0x0)
+		//set target temps to 50°C
+		r2.TargetTemp1.set(h, 0x32)
+		r2.TargetTemp2.set(h, 0x32)
+		closeMux(h)
+		err = DoI2cRpc()
+		if err != nil {
+			return err
+		}
 
-			r2.BankSelect.set(h, 0x82)
-			//set critical temp to set 100% fan speed to 65°C
-			r2.FanCritTemp1.set(h, 0x41)
-			r2.FanCritTemp2.set(h, 0x41)
-			//set target temp hysteresis to +/- 5°C
-			r2.TempHyster1.set(h, 0x55)
-			r2.TempHyster2.set(h, 0x55)
-			//enable temp control of fans
-			r2.TempToFanMap1.set(h, 0xff)
-			r2.TempToFanMap2.set(h, 0xff)
-			closeMux(h)
-			err = DoI2cRpc()
-			if err != nil {
-				return err
-			}
+		r2.BankSelect.set(h, 0x82)
+		//set critical temp to set 100% fan speed to 65°C
+		r2.FanCritTemp1.set(h, 0x41)
+		r2.FanCritTemp2.set(h, 0x41)
+		//set target temp hysteresis to +/- 5°C
+		r2.TempHyster1.set(h, 0x55)
+		r2.TempHyster2.set(h, 0x55)
+		//enable temp control of fans
+		r2.TempToFanMap1.set(h, 0xff)
+		r2.TempToFanMap2.set(h, 0xff)
+		closeMux(h)
+		err = DoI2cRpc()
+		if err != nil {
+			return err
 		}
 		if l {
 			log.Print("notice: fan speed set to ", w)
@@ -600,127 +631,37 @@ func (h *I2cDev) GetFanDuty() (uint8, error) {
 
 }
 
+// GetFanSpeed returns auto while the policy controls the fans, otherwise the
+// static speed of the fan output value.
 func (h *I2cDev) GetFanSpeed() (string, error) {
 	var speed string
-	var duty uint8
 	r2 := getRegsBank2()
 
-	if !hostCtrl {
-		r2.BankSelect.set(h, 0x82)
-		r2.TempToFanMap1.get(h)
-		r2.FanOutValue1.get(h)
-		closeMux(h)
-		err := DoI2cRpc()
-		if err != nil {
-			return "error", err
-		}
-		t := uint8(s[3].D[0])
-		m := uint8(s[5].D[0])
-
-		if t == 0xff {
-			speed = "auto"
-		} else if m == high {
-			speed = "high"
-		} else if m == med {
-			speed = "med"
-		} else if m == low {
-			speed = "low"
-		} else {
-			speed = "invalid " + strconv.Itoa(int(m))
-		}
-	}
-	if hostCtrl || (!hostCtrl && speed == "auto") {
-		hostHot := false
-		hostHotter := false
-		hostHot = hostTemp > hostTempTarget
-		hostHotter = hostTemp > sHostTemp
-		qsfpHot := false
-		qsfpHotter := false
-		qsfpHot = qsfpTemp > qsfpTempTarget
-		qsfpHotter = qsfpTemp > sQsfpTemp
-		if (!hostCtrl && (hostHot || qsfpHot)) || (hostCtrl && (hostHotter || qsfpHotter)) {
-			var err error
-			duty, err = h.GetFanDuty()
-			if err != nil {
-				return "auto", err
-			}
-			if hostHot || hostHotter {
-				hostHyst = 5
-				sHostTemp = hostTemp
-
-			}
-			if qsfpHot || qsfpHotter {
-				qsfpHyst = 5
-				sQsfpTemp = qsfpTemp
-			}
-			if duty < 0xff {
-				if duty <= 0xdf {
-					h.SetFanDuty(duty + 0x20)
-				} else {
-					h.SetFanDuty(0xff)
-				}
-			} else {
-			}
-
-			if !hostCtrl {
-				hostCtrl = true
-			}
-		} else if hostCtrl && (hostTemp <= (hostTempTarget - hostHyst)) && (qsfpTemp <= (qsfpTempTarget - qsfpHyst)) {
-			hostCtrl = false
-			thCtrl = false
-			sHostTemp = 150
-			hostHyst = 0
-			sQsfpTemp = 150
-			qsfpHyst = 0
-			sThTemp = 150
-			//set fan speed to thermal cruise (auto)
-			h.SetFanSpeed("auto", false)
-		}
-		if hostCtrl {
-			ft, err := h.FrontTemp()
-			if err != nil {
-				return "auto", err
-			}
-			f, err := strconv.ParseFloat(ft, 64)
-			if err != nil {
-				return "auto", err
-			}
-			rt, err := h.RearTemp()
-			if err != nil {
-				return "auto", err
-			}
-			r, err := strconv.ParseFloat(rt, 64)
-			if err != nil {
-				return "auto", err
-			}
-			if r > f {
-				thTemp = r
-			} else {
-				thTemp = f
-			}
-			if (!thCtrl && (thTemp > 55)) || (thCtrl && (thTemp > sThTemp)) {
-				//increase fan speed
-				sThTemp = thTemp
-				duty, err = h.GetFanDuty()
-				if err != nil {
-					return "auto", err
-				}
-				if duty < 0xff {
-					if duty <= 0xdf {
-						h.SetFanDuty(duty + 0x20)
-					} else {
-						h.SetFanDuty(0xff)
-					}
-				} else {
-				}
-				if !thCtrl {
-					thCtrl = true
-				}
-			}
-		}
+	if lastSpeed == "auto" {
 		return "auto", nil
 	}
-
+	r2.BankSelect.set(h, 0x82)
+	r2.TempToFanMap1.get(h)
+	r2.FanOutValue1.get(h)
+	closeMux(h)
+	err := DoI2cRpc()
+	if err != nil {
+		return "error", err
+	}
+	t := uint8(s[3].D[0])
+	m := uint8(s[5].D[0])
+
+	if t == 0xff {
+		speed = "auto"
+	} else if m == high {
+		speed = "high"
+	} else if m == med {
+		speed = "med"
+	} else if m == low {
+		speed = "low"
+	} else {
+		speed = "invalid " + strconv.Itoa(int(m))
+	}
 	return speed, nil
 }
 
@@ -768,25 +709,33 @@ func (h *I2cDev) GetHwmTarget() (uint16, error) {
 }
 
 func (h *I2cDev) CheckHostTemp() (string, error) {
-	v := hostTemp
-	return strconv.FormatFloat(v, 'f', 2, 64), nil
+	return reportedTemp("host.temp.units.C"), nil
 }
 
 func (h *I2cDev) CheckQsfpTemp() (string, error) {
-	v := qsfpTemp
-	return strconv.FormatFloat(v, 'f', 2, 64), nil
+	return reportedTemp("qsfp.temp.units.C"), nil
 }
 
 func (h *I2cDev) GetHostTempTarget() (string, error) {
-	v := hostTempTarget
+	v := Thermal.Target("host")
 	return strconv.FormatFloat(v, 'f', 2, 64), nil
 }
 
 func (h *I2cDev) GetQsfpTempTarget() (string, error) {
-	v := qsfpTempTarget
+	v := Thermal.Target("qsfp")
 	return strconv.FormatFloat(v, 'f', 2, 64), nil
 }
 
+// reportedTemp of the field, empty if it hasn't been reported.
+func reportedTemp(field string) string {
+	v := Thermal.Reported(field)
+	if len(v) == 0 {
+		return ""
+	}
+	f, _ := strconv.ParseFloat(v, 64)
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
 func hostReset() error {
 	// FIXME cmd.Init("gpio")
 	log.Print("notice: issue hard reset to host")
@@ -808,26 +757,15 @@ func writeRegs() error {
 			if v == "auto" || v == "high" || v == "med" || v == "low" || v == "max" {
 				Vdev.SetFanSpeed(v, true)
 			}
-		case "host.temp.units.C":
-			f, err := strconv.ParseFloat(v, 64)
+		case "host.temp.units.C", "qsfp.temp.units.C":
+			_, err := strconv.ParseFloat(v, 64)
 			if err == nil {
-				hostTemp = f
+				Thermal.Report(WrRegFn[k], v)
 			}
 		case "host.temp.target.units.C":
-			f, err := strconv.ParseFloat(v, 64)
-			if err == nil {
-				hostTempTarget = f
-			}
-		case "qsfp.temp.units.C":
-			f, err := strconv.ParseFloat(v, 64)
-			if err == nil {
-				qsfpTemp = f
-			}
+			Thermal.Set("host.target", v)
 		case "qsfp.temp.target.units.C":
-			f, err := strconv.ParseFloat(v, 64)
-			if err == nil {
-				qsfpTempTarget = f
-			}
+			Thermal.Set("qsfp.target", v)
 		case "speed.return":
 			if v == "" {
 				Vdev.SetLastSpeed()
@@ -845,6 +783,17 @@ func writeRegs() error {
 }
 
 func (i *Info) Hset(args args.Hset, reply *reply.Hset) error {
+	if strings.HasPrefix(args.Field, "thermal.") {
+		m, err := Thermal.Set(args.Field, string(args.Value))
+		if err != nil {
+			return err
+		}
+		for k, v := range m {
+			i.publish(k, v)
+		}
+		*reply = 1
+		return nil
+	}
 	_, p := WrRegFn[args.Field]
 	if !p {
 		return fmt.Errorf("cannot hset: %s", args.Field)
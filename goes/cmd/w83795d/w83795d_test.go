@@ -0,0 +1,115 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package w83795d
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/go/internal/i2cbus/i2cbustest"
+	"github.com/platinasystems/go/internal/thermal"
+)
+
+var testDev = I2cDev{
+	Bus:      0,
+	Addr:     0x2f,
+	MuxBus:   0,
+	MuxAddr:  0x76,
+	MuxValue: 0x80,
+}
+
+func TestHwm(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/hwm.sim")
+	h := testDev
+
+	if v, err := h.FrontTemp(); err != nil || v != "38.250" {
+		t.Errorf("FrontTemp: %q, %v", v, err)
+	}
+	if v, err := h.RearTemp(); err != nil || v != "40.250" {
+		t.Errorf("RearTemp: %q, %v", v, err)
+	}
+	if rpm, err := h.FanCount(1); err != nil || rpm != 3068 {
+		t.Errorf("FanCount: %d, %v", rpm, err)
+	}
+	if err := h.SetFanDuty(0x80); err != nil {
+		t.Fatal(err)
+	}
+	hwm := sim.Device(0, 0x2f)
+	if v := hwm.Byte(2, 0x11); v != 0x80 {
+		t.Errorf("fan output value: %#x", v)
+	}
+	if d, err := h.GetFanDuty(); err != nil || d != 0x80 {
+		t.Errorf("GetFanDuty: %#x, %v", d, err)
+	}
+	if v := hwm.Byte(0, 0x2e); v != 0x1b {
+		t.Errorf("bank 0 clobbered: %#x", v)
+	}
+}
+
+func TestHwmFaults(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/hwm.sim")
+	h := testDev
+	hwm := sim.Device(0, 0x2f)
+
+	// FanCount votes out a glitched read
+	hwm.Inject(i2cbus.Garbage, 1)
+	if rpm, err := h.FanCount(1); err != nil || rpm != 3068 {
+		t.Errorf("FanCount of glitch: %d, %v", rpm, err)
+	}
+
+	hwm.Inject(i2cbus.Nak, 0)
+	if _, err := h.FrontTemp(); err == nil {
+		t.Error("FrontTemp of NAKing monitor")
+	}
+	hwm.Inject(i2cbus.NoFault, 0)
+
+	sim.Device(0, 0x76).Inject(i2cbus.Stuck, 1)
+	if _, err := h.GetFanDuty(); err == nil {
+		t.Error("GetFanDuty of stuck bus")
+	}
+	if _, err := h.GetFanDuty(); err != nil {
+		t.Error("GetFanDuty after stuck bus:", err)
+	}
+}
+
+func TestControl(t *testing.T) {
+	sim := i2cbustest.Simulate(t, "testdata/hwm.sim")
+	h := testDev
+	hwm := sim.Device(0, 0x2f)
+
+	r, err := thermal.ReadReplay(strings.NewReader(`
+0	hwmon.front.temp.units.C	38.25
+0	host.temp.units.C		75
+0	fan_tray.1.status		ok.front->back
+0	fan_tray.2.status		not installed
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Thermal.Replay(r)
+	defer Thermal.Replay(nil)
+
+	p := Thermal.Policy
+	trays := p.Trays
+	defer func() { p.Trays = trays }()
+	p.Trays = trays[:1]
+	v, err := h.control()
+	if err != nil || v != "host" {
+		t.Errorf("control: %q, %v", v, err)
+	}
+	if v := hwm.Byte(2, 0x10); v != 0xa7 {
+		t.Errorf("host duty: %#x", v)
+	}
+
+	p.Trays = trays[:2]
+	v, err = h.control()
+	if err != nil || !strings.HasPrefix(v, "safe:") {
+		t.Errorf("control: %q, %v", v, err)
+	}
+	if v := hwm.Byte(2, 0x10); v != 0xff {
+		t.Errorf("safe duty: %#x", v)
+	}
+}
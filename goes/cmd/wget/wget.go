@@ -6,6 +6,10 @@ package wget
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
 
 	"github.com/cavaliercoder/grab"
 	"github.com/platinasystems/go/goes/lang"
@@ -31,19 +35,84 @@ func (Command) Main(args ...string) error {
 	}
 
 	reqs := make([]*grab.Request, 0)
-	for _, url := range args {
-		req, err := grab.NewRequest(url)
+	successes := 0
+	var firstErr error
+	for _, u := range args {
+		if !strings.HasPrefix(u, "http://") &&
+			!strings.HasPrefix(u, "https://") {
+			// tftp, scp, sftp, etc.
+			if err := fetch(u); err != nil {
+				fmt.Fprintf(os.Stderr, "Error downloading %s: %v\n",
+					u, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+			} else {
+				successes++
+			}
+			continue
+		}
+		req, err := grab.NewRequest(u)
 		if err != nil {
 			return err
 		}
 		reqs = append(reqs, req)
 	}
 
-	successes, err := url.FetchReqs(0, reqs)
-	if successes == 0 && err != nil {
-		return err
+	if len(reqs) > 0 {
+		n, err := url.FetchReqs(0, reqs)
+		successes += n
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if successes == 0 && firstErr != nil {
+		return firstErr
 	}
 
 	fmt.Printf("%d files successfully downloaded.\n", successes)
 	return nil
 }
+
+// fetch the URL to a file of the same base name in the current directory,
+// unless that's the file of the URL.
+func fetch(u string) error {
+	if !strings.HasPrefix(u, "data:") && !strings.Contains(u, "://") {
+		return fmt.Errorf("not a URL")
+	}
+	name := path.Base(u)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	if strings.HasPrefix(u, "data:") || name == "/" || name == "." {
+		name = "index"
+	}
+	r, err := url.Open(u)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if f, ok := r.(*os.File); ok {
+		src, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if dst, err := os.Stat(name); err == nil && os.SameFile(src, dst) {
+			return fmt.Errorf("%s: same file", name)
+		}
+	}
+	w, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(w, r)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name)
+		return err
+	}
+	fmt.Printf("Finished %s %d bytes\n", name, n)
+	return nil
+}
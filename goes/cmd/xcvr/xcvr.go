@@ -0,0 +1,152 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package xcvr provides a cli command to decode the memory of SFP, QSFP and
+// CMIS transceiver modules.
+package xcvr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/platinasystems/go/goes/lang"
+	"github.com/platinasystems/go/internal/flags"
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/go/internal/parms"
+	"github.com/platinasystems/go/internal/xcvr"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "xcvr" }
+
+func (Command) Usage() string {
+	return "xcvr [-dom] [-hex] [-json] [-dev] [-sim FILE] {-file FILE | PORT}"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "decode transceiver module memory",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Decode the identification, compliance and, with -dom, the digital
+	optical monitors, thresholds and alarms of an SFP (SFF-8472), QSFP+
+	or QSFP28 (SFF-8636), or QSFP-DD or OSFP (CMIS) module.
+
+	PORT is the module's BUS, with the i2cd MUX name and SELECT value of
+	its channel if behind a mux, e.g. 1:qsfp:0x20. The memory is read
+	through i2cd unless -dev, or from a hex dump of -hex with -file.
+	The file may also be a dump of "ethtool -m PORT hex on".
+
+	Examples:
+	    xcvr -dom 1:qsfp:0x20          print module with monitors
+	    xcvr -hex 1:qsfp:0x20 >port1   save hex dump
+	    xcvr -json -file port1         print hex dump as JSON
+	    ethtool -m eth0 hex on >eth0
+	    xcvr -dom -file eth0           decode ethtool dump
+
+OPTIONS
+	-dom	include monitors, thresholds and alarms
+	-hex	print a hex dump of the memory rather than decode it
+	-json	print the decoded module as JSON
+	-dev	use /dev/i2c-BUS rather than i2cd
+	-sim FILE
+		simulate the devices of the script; see internal/i2cbus.Sim
+	-file FILE
+		decode the hex dump rather than read the module`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-dom", "-hex", "-json", "-dev")
+	parm, args := parms.New(args, "-sim", "-file")
+	var img *xcvr.Image
+	var err error
+	if fn := parm.ByName["-file"]; len(fn) > 0 {
+		if len(args) > 0 {
+			return fmt.Errorf("%v: unexpected", args)
+		}
+		img, err = xcvr.LoadImage(fn)
+	} else {
+		if len(args) == 0 {
+			return fmt.Errorf("PORT: missing")
+		}
+		if len(args) > 1 {
+			return fmt.Errorf("%v: unexpected", args[1:])
+		}
+		var r *xcvr.Reader
+		if r, err = port(args[0]); err != nil {
+			return err
+		}
+		if flag.ByName["-dev"] {
+			r.Backend = i2cbus.Default
+		}
+		if fn := parm.ByName["-sim"]; len(fn) > 0 {
+			if r.Backend, err = i2cbus.LoadSim(fn); err != nil {
+				return err
+			}
+		}
+		img, err = r.Read()
+	}
+	if err != nil {
+		return err
+	}
+	return show(os.Stdout, img, flag.ByName["-hex"], flag.ByName["-json"],
+		flag.ByName["-dom"])
+}
+
+// port reader of BUS[:MUX:SELECT]
+func port(s string) (*xcvr.Reader, error) {
+	err := fmt.Errorf("%s: invalid PORT", s)
+	fields := strings.Split(s, ":")
+	if len(fields) != 1 && len(fields) != 3 {
+		return nil, err
+	}
+	bus, berr := strconv.ParseUint(fields[0], 0, 8)
+	if berr != nil {
+		return nil, err
+	}
+	r := &xcvr.Reader{Bus: int(bus)}
+	if len(fields) == 3 {
+		sel, serr := strconv.ParseUint(fields[2], 0, 8)
+		if len(fields[1]) == 0 || serr != nil {
+			return nil, err
+		}
+		r.Mux = fields[1]
+		r.Select = byte(sel)
+	}
+	return r, nil
+}
+
+// show the hex dump, JSON, or description of the image.
+func show(w io.Writer, img *xcvr.Image, hex, asJSON, dom bool) error {
+	if hex {
+		return img.WriteHex(w)
+	}
+	m, err := xcvr.Decode(img)
+	if err != nil {
+		return err
+	}
+	if !asJSON {
+		return m.Print(w, dom)
+	}
+	if !dom {
+		m.DOM = nil
+	}
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
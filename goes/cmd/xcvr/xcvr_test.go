@@ -0,0 +1,85 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package xcvr
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/platinasystems/go/internal/xcvr"
+)
+
+const image = "../../../internal/xcvr/testdata/qsfp28-100g-sr4.hex"
+
+func TestPort(t *testing.T) {
+	r, err := port("1:qsfp:0x20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Bus != 1 || r.Mux != "qsfp" || r.Select != 0x20 {
+		t.Errorf("%+v", r)
+	}
+	for _, s := range []string{"", "x", "1:qsfp", "1::0x20", "1:qsfp:256"} {
+		if _, err = port(s); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
+
+func TestShow(t *testing.T) {
+	img, err := xcvr.LoadImage(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = show(&out, img, false, false, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"PN:                 QSFP28-SR4-100G\n",
+		"Temperature:        41.25 C\n",
+		"Alarm:              rx4 los\n",
+	} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("missing %q in:\n%s", s, out.String())
+		}
+	}
+
+	out.Reset()
+	if err = show(&out, img, false, true, false); err != nil {
+		t.Fatal(err)
+	}
+	var m xcvr.Module
+	if err = json.Unmarshal(out.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.SN != "ACM1923000123" || m.DOM != nil {
+		t.Errorf("%+v", m)
+	}
+
+	out.Reset()
+	if err = show(&out, img, true, false, false); err != nil {
+		t.Fatal(err)
+	}
+	again, err := xcvr.ReadImage(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Identifier() != img.Identifier() {
+		t.Error("hex dump mismatch")
+	}
+}
+
+func TestArgs(t *testing.T) {
+	sim := "../../../internal/xcvr/testdata/sfp-10g-sr.sim"
+	if err := (Command{}).Main("-sim", sim, "0", "1"); err == nil {
+		t.Error("extra argument: no error")
+	}
+	if err := (Command{}).Main("-dom"); err == nil {
+		t.Error("missing PORT: no error")
+	}
+}
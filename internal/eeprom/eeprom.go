@@ -51,7 +51,7 @@ const (
 	pcba_serial_number = 0x54
 )
 
-var ONIEId = "TlvInfo" + string(0x00)
+var ONIEId = "TlvInfo\x00"
 var ONIEVer uint8 = 0x01
 var lengthOffset uint = 9
 
@@ -152,16 +152,44 @@ func (d *Device) getUint16(i uint) uint {
 	return ((b0 << 8) | b1)
 }
 
+// recovered i2c error of getByte
+func recovered(err *error) {
+	if e := recover(); e != nil {
+		*err = e.(error)
+	}
+}
+
 func (d *Device) GetInfo() (err error) {
-	defer func() {
-		if e := recover(); e != nil {
-			err = e.(error)
-		}
-	}()
+	defer recovered(&err)
 	d.getInfo()
 	return
 }
 
+// ReadTlvInfo parses the EEPROM; see ParseTlvInfo.
+func (d *Device) ReadTlvInfo() (info *TlvInfo, err error) {
+	defer recovered(&err)
+	valid, rawData := d.DumpProm()
+	if !valid {
+		return nil, ErrFormat
+	}
+	return ParseTlvInfo(rawData)
+}
+
+// WriteTlvInfo to the EEPROM, skipping the bytes that are unchanged.
+func (d *Device) WriteTlvInfo(info *TlvInfo) (err error) {
+	defer recovered(&err)
+	b := info.Bytes()
+	for i := range b {
+		if d.getByte(uint(i)) == b[i] {
+			continue
+		}
+		if err = d.setByte(uint16(i), b[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
 func (d *Device) getInfo() {
 	f := &d.Fields
 	var i uint
@@ -218,30 +246,7 @@ func (d *Device) getInfo() {
 			f.ServiceTag = string(v)
 		case vendor_extension:
 			if (f.DeviceVersion != 0x00) && (f.DeviceVersion != 0xff) {
-				for j := uint(4); j < uint(len(v)); {
-					etlv, etlen := v[j], uint(v[j+1])
-					ev := v[j+2 : j+2+etlen]
-					switch etlv {
-					case chassis_type:
-						f.ChassisType = ev[0]
-					case board_type:
-						f.BoardType = ev[0]
-					case sub_type:
-						f.SubType = ev[0]
-					case pcba_number:
-						f.PcbaPartNumber = string(ev)
-					case pcba_serial_number:
-						if string(ev[0:3]) == "cpu" {
-							f.Tor1CpuPcbaSerialNumber = string(ev)
-						} else if string(ev[0:3]) == "fan" {
-							f.Tor1FanPcbaSerialNumber = string(ev)
-						} else if string(ev[0:4]) == "main" {
-							f.Tor1MainPcbaSerialNumber = string(ev)
-						}
-					default:
-					}
-					j += 2 + etlen
-				}
+				f.extension(v)
 			}
 			f.VendorExtension = string(v)
 		case crc:
@@ -253,6 +258,33 @@ func (d *Device) getInfo() {
 	return
 }
 
+// extension sub-fields of each vendor extension, whatever its PEN
+func (f *fields) extension(v []byte) {
+	for j := 4; j+2 <= len(v) && j+2+int(v[j+1]) <= len(v); {
+		etlv, etlen := v[j], int(v[j+1])
+		ev := string(v[j+2 : j+2+etlen])
+		switch {
+		case etlen == 0:
+		case etlv == chassis_type:
+			f.ChassisType = ev[0]
+		case etlv == board_type:
+			f.BoardType = ev[0]
+		case etlv == sub_type:
+			f.SubType = ev[0]
+		case etlv == pcba_number:
+			f.PcbaPartNumber = ev
+		case etlv != pcba_serial_number:
+		case strings.HasPrefix(ev, "cpu"):
+			f.Tor1CpuPcbaSerialNumber = ev
+		case strings.HasPrefix(ev, "fan"):
+			f.Tor1FanPcbaSerialNumber = ev
+		case strings.HasPrefix(ev, "main"):
+			f.Tor1MainPcbaSerialNumber = ev
+		}
+		j += 2 + etlen
+	}
+}
+
 func (d *Device) DumpProm() (bool, []byte) {
 	f := &d.Fields
 	var i uint8
@@ -275,48 +307,50 @@ func (d *Device) DumpProm() (bool, []byte) {
 
 }
 
-func (d *Device) CalcCrc() string {
-	f := &d.Fields
-	var i uint8
+func (d *Device) CalcCrc() (err error) {
+	defer recovered(&err)
 
-	//if onie ID is not valid, return
-	for i = 0; i < uint8(len(f.ONIEData)); i++ {
-		f.ONIEData[i] = d.getByte(uint(i))
-		if f.ONIEData[i] != ONIEId[i] {
-			return "Invalid: EEPROM not in ONIE format"
-		}
+	//read ONIE prom, if onie ID is valid
+	valid, rawData := d.DumpProm()
+	if !valid {
+		return ErrFormat
 	}
 
-	//read ONIE prom
-	_, rawData := d.DumpProm()
-
 	//calculate crc32 up old crc value, write new crc value
 	l := uint16(len(rawData))
+	if l < HeaderLen+crcLen || rawData[l-6] != crc || rawData[l-5] != 4 {
+		return &FieldError{crc, ErrNotFound}
+	}
 	checksum := crc32.ChecksumIEEE(rawData[0 : l-4])
-	//checksum := crc32.ChecksumIEEE(rawData)
-	d.setByte(l-4, uint8(checksum>>24))
-	d.setByte(l-3, uint8(checksum>>16))
-	d.setByte(l-2, uint8(checksum>>8))
-	d.setByte(l-1, uint8(checksum&0xff))
-
-	return "crc update complete"
+	for i, v := range []uint8{uint8(checksum >> 24), uint8(checksum >> 16),
+		uint8(checksum >> 8), uint8(checksum & 0xff)} {
+		if err = d.setByte(l-4+uint16(i), v); err != nil {
+			return
+		}
+	}
+	return
 }
 
-func (d *Device) DeleteField(n string) string {
+func (d *Device) DeleteField(n string) (err error) {
+	defer recovered(&err)
+
+	t, err := strconv.ParseUint(n, 16, 8)
+	if err != nil {
+		return fmt.Errorf("%s: %v", n, ErrType)
+	}
 	//do not allow deleting of crc field
-	if n == "fe" {
-		return "Invalid: crc delete not allowed"
+	if t == crc {
+		return &FieldError{crc, ErrReadOnly}
 	}
 
 	var found bool = false
 
 	r, rawData := d.DumpProm()
 	if !r {
-		return "Invalid: EEPROM not in ONIE format"
+		return ErrFormat
 	}
 
 	//delete field + 2 byte header if found, shift remaining fields
-	t, _ := strconv.ParseUint(n, 16, 64)
 	dataLen := d.getUint16(lengthOffset)
 
 	for i := uint(0 + 11); i < uint(len(rawData)); {
@@ -324,7 +358,10 @@ func (d *Device) DeleteField(n string) string {
 		if tlv == byte(t) {
 			//return "found"
 			for j := i; j < (uint(len(rawData)) - 2 - tlen); j++ {
-				d.setByte(uint16(j), rawData[j+2+tlen])
+				err = d.setByte(uint16(j), rawData[j+2+tlen])
+				if err != nil {
+					return
+				}
 			}
 			dataLen -= uint(tlen + 2)
 			found = true
@@ -333,95 +370,85 @@ func (d *Device) DeleteField(n string) string {
 		i += 2 + tlen
 	}
 	if !found {
-		return "field not found"
+		return &FieldError{byte(t), ErrNotFound}
 	}
 	//update length field
-	d.setByte(9, uint8(dataLen>>8))
-	d.setByte(10, uint8(dataLen&0xFF))
-	return "first matching field deleted"
-
+	return d.setBytes(uint16(lengthOffset), uint8(dataLen>>8),
+		uint8(dataLen&0xFF))
 }
-func (d *Device) AddCrc() string {
+
+func (d *Device) AddCrc() (err error) {
+	defer recovered(&err)
 	dataLen := d.getUint16(lengthOffset)
-	d.setByte(uint16(lengthOffset), uint8((dataLen+6)>>8))
-	d.setByte(uint16(lengthOffset+1), uint8((dataLen+6)&0xFF))
-	d.setByte(uint16(dataLen+11), 0xfe)
-	d.setByte(uint16(dataLen+12), 0x4)
-	d.setByte(uint16(dataLen+13), 0x0)
-	d.setByte(uint16(dataLen+14), 0x0)
-	d.setByte(uint16(dataLen+15), 0x0)
-	d.setByte(uint16(dataLen+16), 0x0)
-
-	return "crc field added"
+	err = d.setBytes(uint16(lengthOffset), uint8((dataLen+6)>>8),
+		uint8((dataLen+6)&0xFF))
+	if err != nil {
+		return
+	}
+	return d.setBytes(uint16(dataLen+11), crc, 4, 0, 0, 0, 0)
+}
+
+func (d *Device) CopyAll(rawData []byte) error {
+	return d.setBytes(0, rawData...)
 }
 
-func (d *Device) CopyAll(rawData []byte) string {
-	for j := uint(0); j < uint(len(rawData)); j++ {
-		d.setByte(uint16(j), rawData[j])
+// setBytes from the address, stopping at the first error
+func (d *Device) setBytes(a uint16, v ...uint8) error {
+	for i := range v {
+		if err := d.setByte(a+uint16(i), v[i]); err != nil {
+			return err
+		}
 	}
-	return "copy complete"
+	return nil
 }
 
-func (d *Device) WriteField(n string, v []byte) string {
-	f := &d.Fields
-	var i uint8
+func (d *Device) WriteField(n string, v []byte) (err error) {
+	defer recovered(&err)
 
 	// write onie ID, onie version, length = 6, and placeholder crc32
 	if strings.Contains(n, "onie") {
-		for i = 0; i < uint8(len(ONIEId)); i++ {
-			d.setByte(uint16(i), uint8(ONIEId[i]))
-		}
-		d.setByte(uint16(i), ONIEVer)
-		d.setByte(uint16(i+1), 0)
-		d.setByte(uint16(i+2), 6)
-		d.setByte(uint16(i+3), crc)
-		d.setByte(uint16(i+4), 4)
-		d.setByte(uint16(i+5), 0)
-		d.setByte(uint16(i+6), 0)
-		d.setByte(uint16(i+7), 0)
-		d.setByte(uint16(i+8), 0)
-		return "onie header written"
+		header := append([]byte(ONIEId), ONIEVer, 0, crcLen)
+		return d.setBytes(0, append(header, crc, 4, 0, 0, 0, 0)...)
 	} else if strings.Contains(n, "length") {
-		d.setByte(uint16(9), v[0])
-		d.setByte(uint16(10), v[1])
-		return "length written"
-	} else {
-
-		//if onie ID is not valid, return
-		for i = 0; i < uint8(len(f.ONIEData)); i++ {
-			f.ONIEData[i] = d.getByte(uint(i))
-			if f.ONIEData[i] != ONIEId[i] {
-				return "Invalid: EEPROM not in ONIE format"
-			}
-		}
-
-		t, err := strconv.ParseUint(n, 16, 64)
-		if err == nil {
-			switch uint8(t) {
-			case 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b, 0x2c, 0x2d, 0x2e, 0x2f, 0xfd:
-				dataLen := d.getUint16(lengthOffset)
-				o := uint16(dataLen + 11 - 6)
-				d.setByte(o, uint8(t))
-				d.setByte(o+1, uint8(len(v)))
-				for i = 0; i < uint8(len(v)); i++ {
-					d.setByte(uint16(i+uint8(o+2)), uint8(v[i]))
-				}
-				newLength := uint16(dataLen + uint(len(v)) + 2)
-				d.setByte(uint16(lengthOffset), uint8(newLength>>8))
-				d.setByte(uint16(lengthOffset+1), uint8(newLength&0xFF))
-				d.setByte(newLength+11-6, crc)
-				d.setByte(newLength+11-5, 0x4)
-				d.setByte(newLength+11-4, 0)
-				d.setByte(newLength+11-3, 0)
-				d.setByte(newLength+11-2, 0)
-				d.setByte(newLength+11-1, 0)
-				return "write complete. run diag prom crc"
-			default:
-				return "Invalid: field not recognized"
-			}
+		if len(v) != 2 {
+			return ErrLength
 		}
+		return d.setBytes(uint16(lengthOffset), v...)
+	}
 
+	//if onie ID is not valid, return
+	if valid, _ := d.DumpProm(); !valid {
+		return ErrFormat
 	}
 
-	return "Invalid or incomplete arguments"
+	t, err := strconv.ParseUint(n, 16, 8)
+	if err != nil {
+		return fmt.Errorf("%s: %v", n, ErrType)
+	}
+	if t == crc {
+		return &FieldError{crc, ErrReadOnly}
+	}
+	if _, found := types[byte(t)]; !found {
+		return &FieldError{byte(t), ErrType}
+	}
+	if len(v) > 255 {
+		return &FieldError{byte(t), ErrLength}
+	}
+	dataLen := d.getUint16(lengthOffset)
+	newLength := uint16(dataLen + uint(len(v)) + 2)
+	if HeaderLen+int(newLength) > MaxLen {
+		return &FieldError{byte(t), ErrLength}
+	}
+	// overwrite the crc32 with the field, then append the placeholder
+	o := uint16(dataLen + HeaderLen - crcLen)
+	err = d.setBytes(o, append([]byte{byte(t), byte(len(v))}, v...)...)
+	if err != nil {
+		return
+	}
+	err = d.setBytes(uint16(lengthOffset), uint8(newLength>>8),
+		uint8(newLength&0xFF))
+	if err != nil {
+		return
+	}
+	return d.setBytes(newLength+HeaderLen-crcLen, crc, 4, 0, 0, 0, 0)
 }
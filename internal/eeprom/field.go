@@ -0,0 +1,252 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package eeprom
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kinds of field values
+const (
+	text = iota
+	mac
+	date
+	dec8
+	dec16
+	hex8
+	hexbytes
+	hex32
+)
+
+// DateFormat of the manufacture date.
+const DateFormat = "01/02/2006 15:04:05"
+
+// PlatinaPEN is the IANA private enterprise number of Platina's vendor
+// extension.
+const PlatinaPEN = 0xbc65
+
+type typeInfo struct {
+	name     string
+	kind     int
+	min, max int
+}
+
+// types of the ONIE TlvInfo fields
+var types = map[byte]typeInfo{
+	product_name:          {"Product Name", text, 0, 255},
+	part_number:           {"Part Number", text, 0, 255},
+	serial_number:         {"Serial Number", text, 0, 255},
+	base_ethernet_address: {"Base MAC Address", mac, 6, 6},
+	manufacture_date:      {"Manufacture Date", date, 19, 19},
+	device_version:        {"Device Version", dec8, 1, 1},
+	label_revision:        {"Label Revision", text, 0, 255},
+	platform_name:         {"Platform Name", text, 0, 255},
+	onie_version:          {"ONIE Version", text, 0, 255},
+	n_ethernet_address:    {"MAC Addresses", dec16, 2, 2},
+	manufacturer:          {"Manufacturer", text, 0, 255},
+	country_code:          {"Country Code", text, 2, 2},
+	vendor:                {"Vendor Name", text, 0, 255},
+	diag_version:          {"Diag Version", text, 0, 255},
+	service_tag:           {"Service Tag", text, 0, 255},
+	vendor_extension:      {"Vendor Extension", hexbytes, 4, 255},
+	crc:                   {"CRC-32", hex32, 4, 4},
+}
+
+// platinaTypes of the sub-fields of Platina's vendor extension
+var platinaTypes = map[byte]typeInfo{
+	chassis_type:       {"Chassis Type", hex8, 1, 1},
+	board_type:         {"Board Type", hex8, 1, 1},
+	sub_type:           {"Sub Type", hex8, 1, 1},
+	pcba_number:        {"PCBA Part Number", text, 0, 255},
+	pcba_serial_number: {"PCBA Serial Number", text, 0, 255},
+}
+
+// TypeName of the TLV type, e.g. "Product Name", or its code.
+func TypeName(t byte) string {
+	if info, found := types[t]; found {
+		return info.name
+	}
+	return fmt.Sprintf("0x%02X", t)
+}
+
+// Lookup the TLV type of the code, e.g. "0x21", or name, e.g.
+// "product-name", ignoring case, spaces, hyphens and underscores.
+func Lookup(s string) (byte, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		u, err := strconv.ParseUint(s[2:], 16, 8)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", s, ErrType)
+		}
+		return byte(u), nil
+	}
+	key := fold(s)
+	for t, info := range types {
+		if fold(info.name) == key {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: %v", s, ErrType)
+}
+
+func fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(s))
+}
+
+// Check the length and value of the field with the TLV type. Types other
+// than those of ONIE may have any value.
+func Check(t byte, v []byte) error {
+	info, found := types[t]
+	if !found {
+		return nil
+	}
+	return check(t, info, v)
+}
+
+func check(t byte, info typeInfo, v []byte) error {
+	if len(v) < info.min || len(v) > info.max {
+		return &FieldError{t, ErrLength}
+	}
+	switch info.kind {
+	case date:
+		if _, err := time.Parse(DateFormat, string(v)); err != nil {
+			return &FieldError{t, ErrValue}
+		}
+	case text:
+		for _, c := range v {
+			if c < ' ' || c > '~' {
+				return &FieldError{t, ErrValue}
+			}
+		}
+	}
+	return nil
+}
+
+// Format the value of the field with the TLV type.
+func Format(t byte, v []byte) string {
+	info, found := types[t]
+	if !found {
+		info.kind = hexbytes
+	}
+	return format(info.kind, v)
+}
+
+func format(kind int, v []byte) string {
+	switch {
+	case kind == text, kind == date:
+		return string(v)
+	case kind == mac && len(v) == 6:
+		return strings.ToUpper(net.HardwareAddr(v).String())
+	case kind == dec8 && len(v) == 1:
+		return fmt.Sprint(v[0])
+	case kind == hex8 && len(v) == 1:
+		return fmt.Sprintf("0x%02X", v[0])
+	case kind == dec16 && len(v) == 2:
+		return fmt.Sprint(binary.BigEndian.Uint16(v))
+	case kind == hex32 && len(v) == 4:
+		return fmt.Sprintf("0x%08X", binary.BigEndian.Uint32(v))
+	}
+	return "0x" + hex.EncodeToString(v)
+}
+
+// Scan the formatted value of the field with the TLV type.
+func Scan(t byte, s string) ([]byte, error) {
+	info, found := types[t]
+	if !found {
+		info = typeInfo{TypeName(t), hexbytes, 0, 255}
+	}
+	if t == crc {
+		return nil, &FieldError{t, ErrReadOnly}
+	}
+	v, err := scan(info.kind, s)
+	if err != nil {
+		return nil, &FieldError{t, ErrValue}
+	}
+	if err = check(t, info, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func scan(kind int, s string) ([]byte, error) {
+	switch kind {
+	case mac:
+		return net.ParseMAC(s)
+	case dec8, dec16:
+		bits := 8 * (kind - dec8 + 1)
+		u, err := strconv.ParseUint(s, 0, bits)
+		if err != nil {
+			return nil, err
+		}
+		if kind == dec8 {
+			return []byte{byte(u)}, nil
+		}
+		return []byte{byte(u >> 8), byte(u)}, nil
+	case hex8:
+		u, err := strconv.ParseUint(s, 0, 8)
+		return []byte{byte(u)}, err
+	case hexbytes:
+		s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+		s = strings.NewReplacer(" ", "", ":", "").Replace(s)
+		return hex.DecodeString(s)
+	}
+	return []byte(s), nil
+}
+
+// Extension is a vendor extension of the IANA private enterprise number
+// with the sub-fields of Platina's extension.
+type Extension struct {
+	PEN  uint32
+	Tlvs []Tlv
+	Data []byte
+}
+
+// ParseExtension of the vendor extension value.
+func ParseExtension(v []byte) (*Extension, error) {
+	if len(v) < 4 {
+		return nil, &FieldError{vendor_extension, ErrLength}
+	}
+	x := &Extension{PEN: binary.BigEndian.Uint32(v), Data: v[4:]}
+	if x.PEN != PlatinaPEN {
+		return x, nil
+	}
+	for i := 4; i < len(v); {
+		if i+2 > len(v) || i+2+int(v[i+1]) > len(v) {
+			return nil, &FieldError{vendor_extension, ErrLength}
+		}
+		l := int(v[i+1])
+		x.Tlvs = append(x.Tlvs, Tlv{v[i], v[i+2 : i+2+l]})
+		i += 2 + l
+	}
+	return x, nil
+}
+
+// TypeName of the sub-field of the extension.
+func (x *Extension) TypeName(t byte) string {
+	if info, found := platinaTypes[t]; found {
+		return info.name
+	}
+	return fmt.Sprintf("0x%02X", t)
+}
+
+// Format the value of the sub-field of the extension.
+func (x *Extension) Format(t byte, v []byte) string {
+	info, found := platinaTypes[t]
+	if !found {
+		info.kind = hexbytes
+	}
+	return format(info.kind, v)
+}
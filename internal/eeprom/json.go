@@ -0,0 +1,80 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package eeprom
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonTlvInfo is the JSON form of a TlvInfo, e.g.
+//
+//	{
+//		"Version": 1,
+//		"Fields": [
+//			{ "Code": "0x21", "Name": "Product Name", "Value": "BT-77O" },
+//			{ "Code": "0x24", "Value": "02:46:8A:00:01:02" }
+//		]
+//	}
+//
+// The Name is informational; the Code, if present, otherwise the Name,
+// is the type of the formatted Value. The computed CRC-32 is omitted.
+type jsonTlvInfo struct {
+	Version byte
+	Fields  []jsonField
+}
+
+type jsonField struct {
+	Code  string `json:",omitempty"`
+	Name  string `json:",omitempty"`
+	Value string
+}
+
+// MarshalJSON of the TlvInfo with the formatted value of each field.
+func (info *TlvInfo) MarshalJSON() ([]byte, error) {
+	j := jsonTlvInfo{
+		Version: info.Version,
+		Fields:  make([]jsonField, 0, len(info.Tlvs)),
+	}
+	for _, t := range info.Tlvs {
+		j.Fields = append(j.Fields, jsonField{
+			Code:  fmt.Sprintf("0x%02X", t.Type),
+			Name:  TypeName(t.Type),
+			Value: Format(t.Type, t.Value),
+		})
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON replaces the fields of the TlvInfo with those of the JSON.
+func (info *TlvInfo) UnmarshalJSON(b []byte) error {
+	var j jsonTlvInfo
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	if j.Version == 0 {
+		j.Version = ONIEVer
+	}
+	parsed := &TlvInfo{Version: j.Version}
+	for _, f := range j.Fields {
+		code := f.Code
+		if len(code) == 0 {
+			code = f.Name
+		}
+		t, err := Lookup(code)
+		if err != nil {
+			return err
+		}
+		v, err := Scan(t, f.Value)
+		if err != nil {
+			return err
+		}
+		if err = parsed.Add(t, v); err != nil {
+			return err
+		}
+	}
+	*info = *parsed
+	return nil
+}
@@ -0,0 +1,278 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package eeprom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+)
+
+// Errors of the TlvInfo format and its fields.
+var (
+	ErrFormat   = errors.New("not in ONIE TlvInfo format")
+	ErrCRC      = errors.New("CRC-32 mismatch")
+	ErrLength   = errors.New("invalid length")
+	ErrValue    = errors.New("invalid value")
+	ErrType     = errors.New("unknown type")
+	ErrNotFound = errors.New("not found")
+	ErrReadOnly = errors.New("read only")
+)
+
+// FieldError is an error of the field with the TLV type.
+type FieldError struct {
+	Type byte
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprint(TypeName(e.Type), ": ", e.Err)
+}
+
+const (
+	// HeaderLen of the id, version and length that precede the TLVs.
+	HeaderLen = 11
+	// MaxLen of the TlvInfo memory, including its header.
+	MaxLen = 2048
+	// crcLen of the CRC-32 TLV
+	crcLen = 6
+)
+
+// Tlv is a field of type, length and value.
+type Tlv struct {
+	Type  byte
+	Value []byte
+}
+
+// TlvInfo is the ONIE system EEPROM format: an id of "TlvInfo\0", version,
+// big-endian length, then the TLVs ending with the CRC-32 of all preceding
+// bytes. The Tlvs exclude the CRC-32 that Bytes appends.
+type TlvInfo struct {
+	Version byte
+	Tlvs    []Tlv
+}
+
+// NewTlvInfo returns an empty TlvInfo of the current version.
+func NewTlvInfo() *TlvInfo {
+	return &TlvInfo{Version: ONIEVer}
+}
+
+// ParseTlvInfo of the memory, which may have trailing bytes. If the TLVs
+// are well formed but the CRC-32 mismatches, the TlvInfo is returned with
+// ErrCRC so that it may be shown or repaired.
+func ParseTlvInfo(b []byte) (*TlvInfo, error) {
+	version, n, tlvs, err := parseTlvs(b)
+	if err != nil {
+		return nil, err
+	}
+	last := len(tlvs) - 1
+	if last < 0 || tlvs[last].Type != crc {
+		return nil, &FieldError{crc, ErrNotFound}
+	}
+	if len(tlvs[last].Value) != 4 {
+		return nil, &FieldError{crc, ErrLength}
+	}
+	for _, t := range tlvs[:last] {
+		if t.Type == crc {
+			return nil, &FieldError{crc, ErrValue}
+		}
+	}
+	info := &TlvInfo{Version: version, Tlvs: tlvs[:last]}
+	sum := crc32.ChecksumIEEE(b[:HeaderLen+n-4])
+	if binary.BigEndian.Uint32(tlvs[last].Value) != sum {
+		return info, ErrCRC
+	}
+	return info, nil
+}
+
+// parseTlvs of the memory, with its version and length, without
+// checking the CRC-32.
+func parseTlvs(b []byte) (byte, int, []Tlv, error) {
+	if len(b) < HeaderLen || !bytes.Equal(b[:8], []byte(ONIEId)) {
+		return 0, 0, nil, ErrFormat
+	}
+	n := int(binary.BigEndian.Uint16(b[lengthOffset:]))
+	if n > MaxLen-HeaderLen || HeaderLen+n > len(b) {
+		return 0, 0, nil, ErrLength
+	}
+	var tlvs []Tlv
+	for i := HeaderLen; i < HeaderLen+n; {
+		if i+2 > HeaderLen+n {
+			return 0, 0, nil, ErrLength
+		}
+		t, l := b[i], int(b[i+1])
+		if i+2+l > HeaderLen+n {
+			return 0, 0, nil, &FieldError{t, ErrLength}
+		}
+		v := append([]byte{}, b[i+2:i+2+l]...)
+		tlvs = append(tlvs, Tlv{t, v})
+		i += 2 + l
+	}
+	return b[8], n, tlvs, nil
+}
+
+// Bytes of the TlvInfo memory with the header and trailing CRC-32.
+func (info *TlvInfo) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString(ONIEId)
+	buf.WriteByte(info.Version)
+	binary.Write(buf, binary.BigEndian, uint16(info.len()))
+	for _, t := range info.Tlvs {
+		buf.WriteByte(t.Type)
+		buf.WriteByte(byte(len(t.Value)))
+		buf.Write(t.Value)
+	}
+	buf.Write([]byte{crc, 4})
+	binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	return buf.Bytes()
+}
+
+// len of the TLVs including the CRC-32
+func (info *TlvInfo) len() int {
+	n := crcLen
+	for _, t := range info.Tlvs {
+		n += 2 + len(t.Value)
+	}
+	return n
+}
+
+// Get the first field of the type.
+func (info *TlvInfo) Get(t byte) (Tlv, bool) {
+	for _, tlv := range info.Tlvs {
+		if tlv.Type == t {
+			return tlv, true
+		}
+	}
+	return Tlv{}, false
+}
+
+// Set the value of the first field of the type, or add the field.
+func (info *TlvInfo) Set(t byte, v []byte) error {
+	for i, tlv := range info.Tlvs {
+		if tlv.Type == t {
+			if err := info.check(t, v, len(tlv.Value)); err != nil {
+				return err
+			}
+			info.Tlvs[i].Value = append([]byte{}, v...)
+			return nil
+		}
+	}
+	return info.Add(t, v)
+}
+
+// Add a field of the type, e.g. another vendor extension.
+func (info *TlvInfo) Add(t byte, v []byte) error {
+	if err := info.check(t, v, -2); err != nil {
+		return err
+	}
+	info.Tlvs = append(info.Tlvs, Tlv{t, append([]byte{}, v...)})
+	return nil
+}
+
+// Delete all fields of the type.
+func (info *TlvInfo) Delete(t byte) error {
+	if t == crc {
+		return &FieldError{t, ErrReadOnly}
+	}
+	tlvs := info.Tlvs[:0]
+	for _, tlv := range info.Tlvs {
+		if tlv.Type != t {
+			tlvs = append(tlvs, tlv)
+		}
+	}
+	if len(tlvs) == len(info.Tlvs) {
+		return &FieldError{t, ErrNotFound}
+	}
+	info.Tlvs = tlvs
+	return nil
+}
+
+// check the value of the type and that it fits in place of old bytes.
+func (info *TlvInfo) check(t byte, v []byte, old int) error {
+	if t == crc {
+		return &FieldError{t, ErrReadOnly}
+	}
+	if err := Check(t, v); err != nil {
+		return err
+	}
+	if HeaderLen+info.len()-old+len(v) > MaxLen {
+		return &FieldError{t, ErrLength}
+	}
+	return nil
+}
+
+// Validate the length and value of each field.
+func (info *TlvInfo) Validate() error {
+	for _, t := range info.Tlvs {
+		if err := Check(t.Type, t.Value); err != nil {
+			return err
+		}
+	}
+	if HeaderLen+info.len() > MaxLen {
+		return ErrLength
+	}
+	return nil
+}
+
+// MACs of the base MAC address and count, which is one if absent.
+func (info *TlvInfo) MACs() ([]net.HardwareAddr, error) {
+	base, found := info.Get(base_ethernet_address)
+	if !found {
+		return nil, &FieldError{base_ethernet_address, ErrNotFound}
+	}
+	if len(base.Value) != 6 {
+		return nil, &FieldError{base_ethernet_address, ErrLength}
+	}
+	n := 1
+	if t, found := info.Get(n_ethernet_address); found {
+		if len(t.Value) != 2 {
+			return nil, &FieldError{n_ethernet_address, ErrLength}
+		}
+		n = int(binary.BigEndian.Uint16(t.Value))
+	}
+	var b [8]byte
+	copy(b[2:], base.Value)
+	u := binary.BigEndian.Uint64(b[:])
+	macs := make([]net.HardwareAddr, 0, n)
+	for i := 0; i < n; i++ {
+		binary.BigEndian.PutUint64(b[:], u+uint64(i))
+		macs = append(macs, net.HardwareAddr(append([]byte{}, b[2:]...)))
+	}
+	return macs, nil
+}
+
+// Print the header and fields like onie-syseeprom.
+func (info *TlvInfo) Print(w io.Writer) {
+	b := info.Bytes()
+	fmt.Fprintln(w, "TlvInfo Header:")
+	fmt.Fprintf(w, "   Id String:    %s\n", ONIEId[:7])
+	fmt.Fprintf(w, "   Version:      %d\n", info.Version)
+	fmt.Fprintf(w, "   Total Length: %d\n", len(b)-HeaderLen)
+	fmt.Fprintln(w, "TLV Name             Code Len Value")
+	fmt.Fprintln(w, "-------------------- ---- --- -----")
+	tlvs := append(append([]Tlv{}, info.Tlvs...), Tlv{crc, b[len(b)-4:]})
+	for _, t := range tlvs {
+		fmt.Fprintf(w, "%-20s 0x%02X %3d %s\n", TypeName(t.Type), t.Type,
+			len(t.Value), Format(t.Type, t.Value))
+		if t.Type != vendor_extension {
+			continue
+		}
+		x, err := ParseExtension(t.Value)
+		if err != nil {
+			fmt.Fprintf(w, "%25s%v\n", "", err)
+			continue
+		}
+		fmt.Fprintf(w, "%25sIANA PEN %d\n", "", x.PEN)
+		for _, sub := range x.Tlvs {
+			fmt.Fprintf(w, "%25s%-20s 0x%02X %3d %s\n", "",
+				x.TypeName(sub.Type), sub.Type, len(sub.Value),
+				x.Format(sub.Type, sub.Value))
+		}
+	}
+}
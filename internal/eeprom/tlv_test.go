@@ -0,0 +1,294 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package eeprom
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func load(t *testing.T, fn string) (*TlvInfo, []byte) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ParseTlvInfo(b)
+	if err != nil {
+		t.Fatal(fn, ": ", err)
+	}
+	return info, b
+}
+
+func fieldErr(err error) error {
+	if fe, ok := err.(*FieldError); ok {
+		return fe.Err
+	}
+	return err
+}
+
+func TestParse(t *testing.T) {
+	info, b := load(t, "testdata/platina-mk1.bin")
+	if info.Version != 1 || len(info.Tlvs) != 13 {
+		t.Fatalf("version %d with %d fields", info.Version, len(info.Tlvs))
+	}
+	if err := info.Validate(); err != nil {
+		t.Error(err)
+	}
+	for _, x := range []struct {
+		t    byte
+		want string
+	}{
+		{product_name, "BT-77O"},
+		{base_ethernet_address, "02:46:8A:00:01:FE"},
+		{manufacture_date, "03/09/2017 11:22:33"},
+		{device_version, "1"},
+		{n_ethernet_address, "4"},
+		{vendor_extension, "0x0000bc65500100510100520" +
+			"10a530e3930302d3030303030302d303030" +
+			"54126d61696e2d50534131373132303030313233"},
+	} {
+		tlv, found := info.Get(x.t)
+		if !found {
+			t.Errorf("%s: not found", TypeName(x.t))
+		} else if s := Format(x.t, tlv.Value); s != x.want {
+			t.Errorf("%s: got %q, want %q", TypeName(x.t), s, x.want)
+		}
+	}
+	n := HeaderLen + (int(b[9])<<8 | int(b[10]))
+	if !bytes.Equal(info.Bytes(), b[:n]) {
+		t.Error("Bytes mismatch")
+	}
+
+	macs, err := info.MACs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, mac := range macs {
+		got = append(got, mac.String())
+	}
+	want := []string{
+		"02:46:8a:00:01:fe",
+		"02:46:8a:00:01:ff",
+		"02:46:8a:00:02:00",
+		"02:46:8a:00:02:01",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("MACs:", got)
+	}
+
+	ve, _ := info.Get(vendor_extension)
+	x, err := ParseExtension(ve.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.PEN != PlatinaPEN || len(x.Tlvs) != 5 {
+		t.Fatalf("%+v", x)
+	}
+	if s := x.Format(sub_type, x.Tlvs[2].Value); s != "0x0A" {
+		t.Error("sub type:", s)
+	}
+}
+
+func TestGetInfoFields(t *testing.T) {
+	info, _ := load(t, "testdata/platina-mk1.bin")
+	var f fields
+	ve, _ := info.Get(vendor_extension)
+	f.extension(ve.Value)
+	if f.SubType != 0x0a || f.PcbaPartNumber != "900-000000-000" ||
+		f.Tor1MainPcbaSerialNumber != "main-PSA1712000123" {
+		t.Errorf("%+v", f)
+	}
+	// truncated sub-fields are ignored
+	f = fields{}
+	f.extension(ve.Value[:12])
+	if f.BoardType != 0 || f.SubType != 0 {
+		t.Errorf("%+v", f)
+	}
+}
+
+func TestBadCRC(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/bad-crc.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ParseTlvInfo(b)
+	if err != ErrCRC {
+		t.Fatal("got", err, "want", ErrCRC)
+	}
+	// the fields are intact and Bytes repairs the CRC-32
+	if tlv, _ := info.Get(product_name); string(tlv.Value) != "bT-77O" {
+		t.Error("product name:", string(tlv.Value))
+	}
+	if _, err = ParseTlvInfo(info.Bytes()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	good := NewTlvInfo().Bytes()
+	for _, x := range []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{"short", good[:8], ErrFormat},
+		{"id", append([]byte("TlvInfo!"), good[8:]...), ErrFormat},
+		{"length", append(append([]byte{}, good[:9]...), 0, 7), ErrLength},
+		{"crc length", append(append([]byte{}, good[:9]...), 0, 2,
+			crc, 0), ErrLength},
+		{"truncated", append(append([]byte{}, good[:9]...), 0, 3,
+			product_name, 4, 'x'), ErrLength},
+		{"no crc", append(append([]byte{}, good[:9]...), 0, 3,
+			product_name, 1, 'x'), ErrNotFound},
+	} {
+		_, err := ParseTlvInfo(x.b)
+		if fieldErr(err) != x.err {
+			t.Errorf("%s: got %v, want %v", x.name, err, x.err)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	info, _ := load(t, "testdata/platina-mk1.bin")
+	for _, x := range []struct {
+		t   byte
+		v   string
+		err error
+	}{
+		{base_ethernet_address, "02:46:8a", ErrValue},
+		{manufacture_date, "2017-03-09 11:22:33", ErrValue},
+		{manufacture_date, "03/09/2017", ErrLength},
+		{country_code, "USA", ErrLength},
+		{device_version, "256", ErrValue},
+		{crc, "0x12345678", ErrReadOnly},
+		{product_name, "BT-77O\n", ErrValue},
+	} {
+		if _, err := Scan(x.t, x.v); fieldErr(err) != x.err {
+			t.Errorf("%s=%q: got %v, want %v", TypeName(x.t), x.v, err,
+				x.err)
+		}
+	}
+	v, err := Scan(serial_number, "PSW1712000043")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = info.Set(serial_number, v); err != nil {
+		t.Fatal(err)
+	}
+	err = info.Set(serial_number, make([]byte, 256))
+	if fieldErr(err) != ErrLength {
+		t.Error("long serial number:", err)
+	}
+	if err = info.Set(crc, []byte{1, 2, 3, 4}); fieldErr(err) != ErrReadOnly {
+		t.Error("crc set:", err)
+	}
+	if err = info.Delete(crc); fieldErr(err) != ErrReadOnly {
+		t.Error("crc delete:", err)
+	}
+	if err = info.Delete(service_tag); fieldErr(err) != ErrNotFound {
+		t.Error("missing delete:", err)
+	}
+	if err = info.Delete(device_version); err != nil {
+		t.Error(err)
+	}
+	v, err = Scan(vendor_extension, "0x00002a7c 01 02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = info.Add(vendor_extension, v); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; err == nil; i++ {
+		err = info.Add(vendor_extension, make([]byte, 255))
+		if i > 8 {
+			t.Fatal("no limit")
+		}
+	}
+	if fieldErr(err) != ErrLength {
+		t.Error("over size:", err)
+	}
+
+	again, err := ParseTlvInfo(info.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info, again) {
+		t.Error("edit mismatch")
+	}
+	tlv, _ := again.Get(serial_number)
+	if string(tlv.Value) != "PSW1712000043" {
+		t.Error("serial number:", string(tlv.Value))
+	}
+	if _, found := again.Get(device_version); found {
+		t.Error("device version not deleted")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for s, want := range map[string]byte{
+		"0x21":             product_name,
+		"0XFD":             vendor_extension,
+		"product-name":     product_name,
+		"Base MAC Address": base_ethernet_address,
+		"mac_addresses":    n_ethernet_address,
+	} {
+		if got, err := Lookup(s); err != nil || got != want {
+			t.Errorf("%s: got %#x, %v", s, got, err)
+		}
+	}
+	for _, s := range []string{"0x100", "bogus", ""} {
+		if _, err := Lookup(s); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	info, _ := load(t, "testdata/platina-mk1.bin")
+	b, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := `"Code":"0x24","Name":"Base MAC Address",` +
+		`"Value":"02:46:8A:00:01:FE"`
+	if !strings.Contains(string(b), mac) {
+		t.Error(string(b))
+	}
+	again := new(TlvInfo)
+	if err = json.Unmarshal(b, again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info, again) {
+		t.Error("JSON mismatch")
+	}
+	cc := `{"Fields":[{"Name":"Country Code","Value":"USA"}]}`
+	err = json.Unmarshal([]byte(cc), again)
+	if fieldErr(err) != ErrLength {
+		t.Error("country code:", err)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	info, _ := load(t, "testdata/platina-mk1.bin")
+	var buf bytes.Buffer
+	info.Print(&buf)
+	for _, s := range []string{
+		"   Total Length: 202\n",
+		"Product Name         0x21   6 BT-77O\n",
+		"MAC Addresses        0x2A   2 4\n",
+		"                         IANA PEN 48229\n",
+		"                         PCBA Part Number     0x53  14 900-000000-000\n",
+		"CRC-32               0xFE   4 0xD30DEF22\n",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("missing %q in:\n%s", s, buf.String())
+		}
+	}
+}
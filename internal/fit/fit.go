@@ -9,17 +9,24 @@ import (
 	"bytes"
 	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
+	"hash"
 	"hash/crc32"
-	"strings"
 	"time"
 
 	"github.com/platinasystems/fdt"
 )
 
 type Fit struct {
-	Debug         bool
+	Debug bool
+	// Policy of KexecLoadConfig, initially DefaultPolicy
+	Policy Policy
+	// Fixups of the device tree by KexecLoadConfig
+	Fixups        Fixups
 	fdt           *fdt.Tree
+	blob          []byte
 	Description   string
 	AddressCells  uint32
 	TimeStamp     time.Time
@@ -29,10 +36,19 @@ type Fit struct {
 }
 
 type Config struct {
+	Name        string
 	Description string
 	ImageList   []*Image
 	BaseAddr    uint64
 	NextAddr    uint64
+
+	// Signed is true if the configuration has a signature node, and
+	// Verified if one was verified with the key named by KeyName.
+	// Otherwise, SignatureErr is why not.
+	Signed       bool
+	Verified     bool
+	KeyName      string
+	SignatureErr error
 }
 
 type Image struct {
@@ -44,6 +60,20 @@ type Image struct {
 	Compression string
 	LoadAddr    uint64
 	Data        []byte
+
+	// HashErr is the first hash node that didn't match Data, if any.
+	HashErr error
+
+	// names of the hash nodes that matched Data, and those of them
+	// strong enough for a signature to cover Data
+	verifiedHashes []string
+	strongHashes   []string
+}
+
+// VerifiedHashes returns the names of the image's hash nodes that matched
+// its data.
+func (i *Image) VerifiedHashes() []string {
+	return i.verifiedHashes
 }
 
 func (f *Fit) getProperty(n *fdt.Node, propName string) []byte {
@@ -55,6 +85,7 @@ func (f *Fit) getProperty(n *fdt.Node, propName string) []byte {
 }
 
 // validateHash takes a hash node, and attempts to validate it. It takes
+// the image the hash node belongs to, and records the node as verified.
 func (f *Fit) validateHash(n *fdt.Node, i *Image) (err error) {
 	algo := f.getProperty(n, "algo")
 	value := f.getProperty(n, "value")
@@ -63,21 +94,6 @@ func (f *Fit) validateHash(n *fdt.Node, i *Image) (err error) {
 	if f.Debug {
 		fmt.Printf("Checking %s:%s %v... ", i.Name, algostr, value)
 	}
-	if algostr == "sha1" {
-		shasum := sha1.Sum(i.Data)
-		shaslice := shasum[:]
-		if !bytes.Equal(value, shaslice) {
-			if f.Debug {
-				fmt.Printf("error, calculated %v!\n", shaslice)
-			}
-			return fmt.Errorf("sha1 incorrect, expected %v! calculated %v!\n", value, shaslice)
-		}
-		if f.Debug {
-			fmt.Print("OK!\n")
-		}
-		return
-	}
-
 	if algostr == "crc32" {
 		propsum := f.fdt.PropUint32(value)
 		calcsum := crc32.ChecksumIEEE(i.Data)
@@ -90,50 +106,73 @@ func (f *Fit) validateHash(n *fdt.Node, i *Image) (err error) {
 		if f.Debug {
 			fmt.Printf("OK!\n")
 		}
+		i.verifiedHashes = append(i.verifiedHashes, n.Name)
 		return
 	}
 
-	if algostr == "md5" {
-		md5sum := md5.Sum(i.Data)
-		md5slice := md5sum[:]
-		if !bytes.Equal(value, md5slice) {
-			if f.Debug {
-				fmt.Printf("error, calculated %v!\n", md5slice)
-			}
-			return fmt.Errorf("sha1 incorrect, expected %v! calculated %v!\n", value, md5slice)
-		}
+	newHash, found := imageHashes[algostr]
+	if !found {
 		if f.Debug {
-			fmt.Print("OK!\n")
+			fmt.Printf("Unknown algorithm!\n")
 		}
 		return
 	}
-
+	h := newHash()
+	h.Write(i.Data)
+	sum := h.Sum(nil)
+	if !bytes.Equal(value, sum) {
+		if f.Debug {
+			fmt.Printf("error, calculated %v!\n", sum)
+		}
+		return fmt.Errorf("%s incorrect, expected %x calculated %x", algostr, value, sum)
+	}
 	if f.Debug {
-		fmt.Printf("Unknown algorithm!\n")
+		fmt.Print("OK!\n")
+	}
+	i.verifiedHashes = append(i.verifiedHashes, n.Name)
+	if strongHashes[algostr] {
+		i.strongHashes = append(i.strongHashes, n.Name)
 	}
 	return
 }
 
-func (f *Fit) validateHashes(n *fdt.Node, i *Image) (err error) {
+// strongHashes may stand in for the image data in a signed configuration.
+var strongHashes = map[string]bool{
+	"sha256": true,
+	"sha384": true,
+	"sha512": true,
+}
+
+var imageHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+func (f *Fit) validateHashes(n *fdt.Node, i *Image) {
 	for _, c := range n.Children {
-		if c.Name == "hash" || strings.HasPrefix(c.Name, "hash@") {
-			err = f.validateHash(c, i)
-			if err != nil {
-				return err
+		if isNode(c.Name, "hash") {
+			err := f.validateHash(c, i)
+			if err != nil && i.HashErr == nil {
+				i.HashErr = fmt.Errorf("%s: %v", c.Name, err)
 			}
 		}
 	}
-	return nil
 }
 
 func (f *Fit) parseImage(cfg *Config, imageName string) {
-	i := f.Images[imageName]
+	i, found := f.Images[imageName]
+	if !found {
+		return
+	}
 
 	cfg.ImageList = append(cfg.ImageList, i)
 }
 
 func (f *Fit) parseConfiguration(whichconf string) (err error) {
-	cfg := Config{}
+	cfg := Config{Name: whichconf}
 
 	conf := f.fdt.RootNode.Children["configurations"]
 
@@ -149,6 +188,7 @@ func (f *Fit) parseConfiguration(whichconf string) (err error) {
 
 	description := conf.Properties["description"]
 	if description != nil {
+		cfg.Description = f.fdt.PropString(description)
 		if f.Debug {
 			fmt.Printf("parseConfiguration %s: %s\n", whichconf, f.fdt.PropString(description))
 		}
@@ -172,8 +212,23 @@ func (f *Fit) parseConfiguration(whichconf string) (err error) {
 	return nil
 }
 
+// Parse the image tree, panicking if an image hash doesn't match, then
+// Verify the configurations with the DefaultKeyring.
 func Parse(b []byte) (f *Fit) {
-	fit := Fit{}
+	f = ParseUnverified(b)
+	for _, i := range f.Images {
+		if i.HashErr != nil {
+			panic(fmt.Errorf("%s: %v", i.Name, i.HashErr))
+		}
+	}
+	f.Verify(DefaultKeyring)
+	return
+}
+
+// ParseUnverified parses the image tree recording, rather than panicking
+// on, each image's HashErr, and leaves the configurations for Verify.
+func ParseUnverified(b []byte) (f *Fit) {
+	fit := Fit{Policy: DefaultPolicy, blob: b}
 	f = &fit
 	f.fdt = &fdt.Tree{Debug: false, IsLittleEndian: false}
 	err := f.fdt.Parse(b)
@@ -198,10 +253,7 @@ func Parse(b []byte) (f *Fit) {
 		i.Compression = f.fdt.PropString(f.getProperty(image, "compression"))
 		i.Data = f.getProperty(image, "data")
 
-		err := f.validateHashes(image, &i)
-		if err != nil {
-			panic(err)
-		}
+		f.validateHashes(image, &i)
 		load := f.fdt.PropUint32Slice(image.Properties["load"])
 		entry := f.fdt.PropUint32Slice(image.Properties["entry"])
 
@@ -220,7 +272,7 @@ func Parse(b []byte) (f *Fit) {
 	f.DefaultConfig = f.fdt.PropString(f.getProperty(conf, "default"))
 
 	for _, c := range conf.Children {
-		if c.Name == "conf" || strings.HasPrefix(c.Name, "conf@") {
+		if isNode(c.Name, "conf") || isNode(c.Name, "config") {
 			err := f.parseConfiguration(c.Name)
 			if err != nil {
 				panic(err)
@@ -0,0 +1,335 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fit
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var kernelData = []byte("not really a kernel")
+
+const testITS = `/dts-v1/;
+
+/ {
+	description = "test";
+	#address-cells = <1>;
+
+	images {
+		kernel-1 {
+			description = "kernel";
+			data = /incbin/("kernel");
+			type = "kernel";
+			arch = "arm";
+			os = "linux";
+			compression = "none";
+			load = <0x10008000>;
+			entry = <0x10008000>;
+			hash-1 {
+				algo = "sha256";
+			};
+			hash-2 {
+				algo = "crc32";
+			};
+		};
+	};
+
+	configurations {
+		default = "conf-1";
+		conf-1 {
+			description = "test configuration";
+			kernel = "kernel-1";
+			signature-1 {
+				algo = "%s";
+				key-name-hint = "dev";
+			};
+		};
+	};
+};
+`
+
+// newFit returns a FIT with a kernel and a configuration signed with the
+// key, unless nil.
+func newFit(t *testing.T, algo string, key crypto.Signer) []byte {
+	return newFitITS(t, testITS, algo, key)
+}
+
+func newFitITS(t *testing.T, testITS, algo string, key crypto.Signer) []byte {
+	dir, err := ioutil.TempDir("", "fit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "kernel"), kernelData, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	its := filepath.Join(dir, "test.its")
+	err = ioutil.WriteFile(its, []byte(fmt.Sprintf(testITS, algo)), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := ReadITS(its)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keydir := ""
+	if key != nil {
+		keydir = dir
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, "dev.key"),
+			pem.EncodeToMemory(&pem.Block{
+				Type:  "PRIVATE KEY",
+				Bytes: der,
+			}), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := Make(tree, keydir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func keyring(t *testing.T, name string, pub crypto.PublicKey) Keyring {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var k Keyring
+	err = k.Add(name, pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: der,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		algo string
+		key  crypto.Signer
+	}{
+		{"sha256,rsa2048", rsaKey},
+		{"sha512,rsa2048", rsaKey},
+		{"sha256,ecdsa256", ecKey},
+	} {
+		b := newFit(t, tc.algo, tc.key)
+
+		f := Parse(b)
+		cfg := f.Configs["conf-1"]
+		if !cfg.Signed || cfg.Verified {
+			t.Errorf("%s: verified without keys", tc.algo)
+		}
+
+		f.Verify(keyring(t, "dev", tc.key.Public()))
+		if !cfg.Verified || cfg.KeyName != "dev" {
+			t.Errorf("%s: %v", tc.algo, cfg.SignatureErr)
+		}
+		f.Policy = RequireSigned
+		if err = f.Allowed(cfg); err != nil {
+			t.Error(tc.algo, err)
+		}
+
+		f.Verify(keyring(t, "other", tc.key.Public()))
+		if cfg.Verified {
+			t.Errorf("%s: verified with misnamed key", tc.algo)
+		}
+		if err = f.Allowed(cfg); err == nil {
+			t.Errorf("%s: unverified configuration allowed", tc.algo)
+		}
+
+		// the configuration description is signed
+		i := strings.Index(string(b), "test configuration")
+		b[i] = 'T'
+		f = Parse(b)
+		f.Verify(keyring(t, "", tc.key.Public()))
+		if f.Configs["conf-1"].Verified {
+			t.Errorf("%s: tampered configuration verified", tc.algo)
+		}
+	}
+}
+
+// knownAnswer returns a test image signed without this package, by
+// testdata/mkfit.go, and its public key.
+func knownAnswer(t *testing.T, keyAlgo string) ([]byte, Keyring) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", keyAlgo+".itb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ReadKeyring(filepath.Join("testdata", keyAlgo+".pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, keys
+}
+
+func TestKnownAnswer(t *testing.T) {
+	algos := []string{"rsa2048", "rsa4096", "ecdsa256"}
+	for i, algo := range algos {
+		b, keys := knownAnswer(t, algo)
+		f := Parse(b)
+		cfg := f.Configs["conf-1"]
+		if cfg == nil || len(cfg.ImageList) != 1 ||
+			!bytes.Equal(cfg.ImageList[0].Data, kernelData) {
+			t.Fatal(algo, "no kernel")
+		}
+		if len(cfg.ImageList[0].VerifiedHashes()) != 2 {
+			t.Error(algo, "hashes", cfg.ImageList[0].VerifiedHashes())
+		}
+
+		f.Verify(keys)
+		if !cfg.Verified || cfg.KeyName != "dev" {
+			t.Errorf("%s: %v", algo, cfg.SignatureErr)
+		}
+
+		_, other := knownAnswer(t, algos[(i+1)%len(algos)])
+		f.Verify(other)
+		if cfg.Verified {
+			t.Errorf("%s: verified with another key", algo)
+		}
+
+		b[strings.Index(string(b), "test configuration")] = 'T'
+		f = Parse(b)
+		f.Verify(keys)
+		if f.Configs["conf-1"].Verified {
+			t.Errorf("%s: tampered configuration verified", algo)
+		}
+	}
+}
+
+func TestWeakHash(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, algo := range []string{"sha1", "md5"} {
+		its := strings.Replace(testITS, `algo = "sha256";`,
+			`algo = "`+algo+`";`, 1)
+		f := Parse(newFitITS(t, its, "sha256,ecdsa256", key))
+		cfg := f.Configs["conf-1"]
+		if len(cfg.ImageList[0].VerifiedHashes()) != 2 {
+			t.Fatal(algo, "hashes weren't verified")
+		}
+		f.Verify(keyring(t, "dev", key.Public()))
+		if cfg.Verified {
+			t.Errorf("%s: verified with %s and crc32 hashes", algo,
+				algo)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	f := Parse(newFit(t, "sha256,rsa2048", nil))
+	cfg := f.Configs["conf-1"]
+	if cfg.Verified {
+		t.Fatal("unsigned configuration verified")
+	}
+	if err := f.Allowed(cfg); err != nil {
+		t.Error(err)
+	}
+	f.Policy = RequireSigned
+	if err := f.Allowed(cfg); err == nil {
+		t.Error("unsigned configuration allowed")
+	}
+	if err := f.Allowed(f.Configs["missing"]); err == nil {
+		t.Error("missing configuration allowed")
+	}
+
+	b, keys := knownAnswer(t, "rsa2048")
+	f = Parse(b)
+	f.Policy = RequireSigned
+	cfg = f.Configs["conf-1"]
+	if err := f.Allowed(cfg); err == nil {
+		t.Error("signed configuration allowed without keys")
+	}
+	f.Verify(keys)
+	if err := f.Allowed(cfg); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMake(t *testing.T) {
+	f := Parse(newFit(t, "sha256,rsa2048", nil))
+	i := f.Images["kernel-1"]
+	if i == nil || !bytes.Equal(i.Data, kernelData) {
+		t.Fatal("no kernel")
+	}
+	if i.LoadAddr != 0x10008000 {
+		t.Errorf("load address %#x", i.LoadAddr)
+	}
+	if len(i.VerifiedHashes()) != 2 {
+		t.Error("verified hashes", i.VerifiedHashes())
+	}
+	if f.DefaultConfig != "conf-1" {
+		t.Error("default configuration", f.DefaultConfig)
+	}
+	for _, s := range []string{
+		"/ { x = <1 2; };",
+		"/ { x = \"y; };",
+		"/ { x = [123]; };",
+		"/ { x = /incbin/(\"missing\"); };",
+		"/ { x { }; ",
+		"nothing",
+	} {
+		if _, err := ParseITS(s, "."); err == nil {
+			t.Errorf("%q: parsed", s)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	b := newFit(t, "sha256,rsa2048", nil)
+	i := strings.Index(string(b), string(kernelData))
+	b[i] = 'N'
+	defer func() {
+		if recover() == nil {
+			t.Error("bad sha256 accepted")
+		}
+	}()
+	Parse(b)
+}
+
+func TestParseUnverified(t *testing.T) {
+	b, keys := knownAnswer(t, "rsa2048")
+	b[strings.Index(string(b), string(kernelData))] = 'N'
+	f := ParseUnverified(b)
+	i := f.Images["kernel-1"]
+	if i == nil || i.HashErr == nil {
+		t.Fatal("bad sha256 accepted")
+	}
+	f.Verify(keys)
+	cfg := f.Configs["conf-1"]
+	if !cfg.Signed || cfg.Verified {
+		t.Error("configuration of a bad image verified")
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fit
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/platinasystems/fdt"
+	"github.com/platinasystems/go/internal/memmap"
+)
+
+const (
+	fdtMagic     = 0xd00dfeed
+	fdtHeaderLen = 40
+	rngSeedLen   = 64
+)
+
+// IOMem is the source of the System RAM of a Memory fixup.
+var IOMem = "/proc/iomem"
+
+// Fixups of the device tree of a configuration, applied by KexecLoadConfig.
+// The initrd location is always set from the configuration's ramdisk.
+type Fixups struct {
+	// Bootargs, if not empty, replaces /chosen/bootargs.
+	Bootargs string
+	// Memory replaces the /memory nodes with the System RAM of IOMem.
+	Memory bool
+	// Seeds sets /chosen/kaslr-seed and /chosen/rng-seed with random
+	// bytes.
+	Seeds bool
+	// MACs are the local-mac-address of the respective ethernetN alias.
+	MACs []net.HardwareAddr
+}
+
+// Apply the fixups to the device tree blob, along with the initrd start and
+// end, unless zero.
+func (x *Fixups) Apply(dtb []byte, initrdStart, initrdEnd uint64) (b []byte,
+	err error) {
+	if len(dtb) < fdtHeaderLen ||
+		binary.BigEndian.Uint32(dtb) != fdtMagic {
+		return nil, errors.New("not a device tree blob")
+	}
+	t := &fdt.Tree{Debug: false, IsLittleEndian: false}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("device tree: %v", r)
+		}
+	}()
+	if err = t.Parse(dtb); err != nil {
+		return nil, err
+	}
+	root := t.RootNode
+	chosen := child(root, "chosen")
+
+	if len(x.Bootargs) > 0 {
+		chosen.Properties["bootargs"] = str(x.Bootargs)
+	}
+	if initrdEnd > initrdStart {
+		chosen.Properties["linux,initrd-start"] = u64(initrdStart)
+		chosen.Properties["linux,initrd-end"] = u64(initrdEnd)
+	}
+	if x.Memory {
+		if err = fixupMemory(root); err != nil {
+			return nil, err
+		}
+	}
+	if x.Seeds {
+		for _, seed := range []struct {
+			name string
+			len  int
+		}{
+			{"kaslr-seed", 8},
+			{"rng-seed", rngSeedLen},
+		} {
+			v := make([]byte, seed.len)
+			if _, err = rand.Read(v); err != nil {
+				return nil, err
+			}
+			chosen.Properties[seed.name] = v
+		}
+	}
+	if aliases := root.Children["aliases"]; aliases != nil {
+		for i, mac := range x.MACs {
+			path := propString(aliases, fmt.Sprint("ethernet", i))
+			if n := lookup(root, path); n != nil {
+				if n.Properties == nil {
+					n.Properties = make(map[string][]byte)
+				}
+				n.Properties["local-mac-address"] = []byte(mac)
+			}
+		}
+	}
+	return withMemReserve(t.FlattenTreeToSlice(), dtb), nil
+}
+
+// fixupMemory replaces the memory nodes of the root with one having the
+// System RAM ranges of IOMem.
+func fixupMemory(root *fdt.Node) error {
+	regions, err := memmap.FileToMap(IOMem)
+	if err != nil {
+		return err
+	}
+	ac := cellsOf(root, "#address-cells", 2)
+	sc := cellsOf(root, "#size-cells", 1)
+	var reg []byte
+	var start uintptr
+	for _, r := range regions["System RAM"].Ranges {
+		if r.End <= r.Start {
+			continue
+		}
+		if len(reg) == 0 {
+			start = r.Start
+		}
+		reg = append(reg, cells(ac, uint64(r.Start))...)
+		reg = append(reg, cells(sc, uint64(r.End-r.Start+1))...)
+	}
+	if len(reg) == 0 {
+		return fmt.Errorf("%s: no System RAM", IOMem)
+	}
+	for name := range root.Children {
+		if name == "memory" || strings.HasPrefix(name, "memory@") {
+			delete(root.Children, name)
+		}
+	}
+	mem := child(root, fmt.Sprintf("memory@%x", start))
+	mem.Properties["device_type"] = str("memory")
+	mem.Properties["reg"] = reg
+	return nil
+}
+
+// child returns the named child of the node, adding it if missing.
+func child(n *fdt.Node, name string) *fdt.Node {
+	if n.Children == nil {
+		n.Children = make(map[string]*fdt.Node)
+	}
+	c, found := n.Children[name]
+	if !found {
+		c = &fdt.Node{Name: name, Depth: n.Depth + 1}
+		n.Children[name] = c
+	}
+	if c.Properties == nil {
+		c.Properties = make(map[string][]byte)
+	}
+	return c
+}
+
+// lookup the node of the absolute path.
+func lookup(root *fdt.Node, path string) *fdt.Node {
+	if !strings.HasPrefix(path, "/") {
+		return nil
+	}
+	n := root
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if len(name) == 0 {
+			continue
+		}
+		if n = n.Children[name]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+func cellsOf(n *fdt.Node, name string, dflt int) int {
+	if v := n.Properties[name]; len(v) == 4 {
+		return int(binary.BigEndian.Uint32(v))
+	}
+	return dflt
+}
+
+func cells(n int, v uint64) []byte {
+	if n == 1 {
+		return u32(uint32(v))
+	}
+	return u64(v)
+}
+
+func u64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// withMemReserve replaces the empty memory reservation block and boot CPU
+// of the flattened tree with those of the original blob.
+func withMemReserve(b, orig []byte) []byte {
+	off := int(binary.BigEndian.Uint32(orig[16:]))
+	var rsv []byte
+	for i := off; i+16 <= len(orig); i += 16 {
+		rsv = append(rsv, orig[i:i+16]...)
+		if binary.BigEndian.Uint64(orig[i:]) == 0 &&
+			binary.BigEndian.Uint64(orig[i+8:]) == 0 {
+			break
+		}
+	}
+	if len(rsv) <= 16 {
+		copy(b[28:32], orig[28:32])
+		return b
+	}
+	delta := uint32(len(rsv) - 16)
+	out := make([]byte, 0, len(b)+int(delta))
+	out = append(out, b[:fdtHeaderLen]...)
+	out = append(out, rsv...)
+	out = append(out, b[fdtHeaderLen+16:]...)
+	for _, off := range []int{4, 8, 12} {
+		// totalsize, off_dt_struct and off_dt_strings
+		v := binary.BigEndian.Uint32(out[off:])
+		binary.BigEndian.PutUint32(out[off:], v+delta)
+	}
+	copy(out[28:32], orig[28:32])
+	return out
+}
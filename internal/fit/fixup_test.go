@@ -0,0 +1,146 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platinasystems/fdt"
+)
+
+const testDTS = `/dts-v1/;
+
+/ {
+	#address-cells = <2>;
+	#size-cells = <2>;
+	model = "test";
+
+	aliases {
+		ethernet0 = "/soc/ethernet@2188000";
+		ethernet1 = "/soc/ethernet@218c000";
+	};
+
+	chosen {
+		bootargs = "console=ttymxc0";
+	};
+
+	memory@10000000 {
+		device_type = "memory";
+		reg = <0 0x10000000 0 0x10000000>;
+	};
+
+	soc {
+		ethernet@2188000 {
+			compatible = "fsl,imx6q-fec";
+		};
+	};
+};
+`
+
+const testIOMem = `00000000-00000fff : Reserved
+00001000-0009ffff : System RAM
+00100000-7fffffff : System RAM
+  01000000-01ffffff : Kernel code
+`
+
+func TestFixups(t *testing.T) {
+	tree, err := ParseITS(testDTS, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtb := memReserved(tree.FlattenTreeToSlice(), 0x8000000, 0x100000)
+
+	dir, err := ioutil.TempDir("", "fixup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(s string) { IOMem = s }(IOMem)
+	IOMem = filepath.Join(dir, "iomem")
+	if err = ioutil.WriteFile(IOMem, []byte(testIOMem), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mac, _ := net.ParseMAC("02:46:8a:00:00:01")
+	x := Fixups{
+		Bootargs: "console=ttymxc1 quiet",
+		Memory:   true,
+		Seeds:    true,
+		MACs:     []net.HardwareAddr{mac, mac},
+	}
+	b, err := x.Apply(dtb, 0x12000000, 0x12345678)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := binary.BigEndian.Uint32(b[4:]); int(n) != len(b) {
+		t.Errorf("totalsize %d, len %d", n, len(b))
+	}
+	off := binary.BigEndian.Uint32(b[16:])
+	if binary.BigEndian.Uint64(b[off:]) != 0x8000000 ||
+		binary.BigEndian.Uint64(b[off+8:]) != 0x100000 {
+		t.Error("lost memory reservation")
+	}
+
+	got := &fdt.Tree{}
+	if err = got.Parse(b); err != nil {
+		t.Fatal(err)
+	}
+	root := got.RootNode
+	chosen := root.Children["chosen"]
+	if s := propString(chosen, "bootargs"); s != x.Bootargs {
+		t.Error("bootargs", s)
+	}
+	if !bytes.Equal(chosen.Properties["linux,initrd-start"],
+		u64(0x12000000)) ||
+		!bytes.Equal(chosen.Properties["linux,initrd-end"],
+			u64(0x12345678)) {
+		t.Error("initrd")
+	}
+	if len(chosen.Properties["kaslr-seed"]) != 8 ||
+		len(chosen.Properties["rng-seed"]) != rngSeedLen {
+		t.Error("seeds")
+	}
+	if _, found := root.Children["memory@10000000"]; found {
+		t.Error("old memory node")
+	}
+	mem := root.Children["memory@1000"]
+	if mem == nil {
+		t.Fatal("no memory node")
+	}
+	var want []byte
+	for _, v := range []uint64{0x1000, 0x9f000, 0x100000, 0x7ff00000} {
+		want = append(want, u64(v)...)
+	}
+	if !bytes.Equal(mem.Properties["reg"], want) {
+		t.Errorf("reg %x", mem.Properties["reg"])
+	}
+	eth := root.Children["soc"].Children["ethernet@2188000"]
+	if !bytes.Equal(eth.Properties["local-mac-address"], mac) {
+		t.Error("local-mac-address")
+	}
+
+	if _, err = x.Apply([]byte("not a dtb"), 0, 0); err == nil {
+		t.Error("not a dtb: applied")
+	}
+}
+
+// memReserved returns the blob with a memory reservation.
+func memReserved(b []byte, addr, size uint64) []byte {
+	rsv := append(u64(addr), u64(size)...)
+	out := append([]byte{}, b[:fdtHeaderLen]...)
+	out = append(out, rsv...)
+	out = append(out, b[fdtHeaderLen:]...)
+	for _, off := range []int{4, 8, 12} {
+		v := binary.BigEndian.Uint32(out[off:])
+		binary.BigEndian.PutUint32(out[off:], v+uint32(len(rsv)))
+	}
+	return out
+}
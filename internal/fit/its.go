@@ -0,0 +1,278 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fit
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/platinasystems/fdt"
+)
+
+// ReadITS parses the image tree source file; /incbin/ paths are relative to
+// its directory.
+func ReadITS(fn string) (*fdt.Tree, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ParseITS(string(b), filepath.Dir(fn))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return t, nil
+}
+
+// ParseITS parses the device tree source subset used by image tree sources:
+// nodes, and properties of strings, <cells>, [bytes] and /incbin/("file").
+// Labels, references and expressions aren't supported.
+func ParseITS(s, dir string) (*fdt.Tree, error) {
+	p := &itsParser{s: s, dir: dir, line: 1}
+	if p.skip(); strings.HasPrefix(p.s, "/dts-v1/") {
+		p.s = p.s[len("/dts-v1/"):]
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+	}
+	var root *fdt.Node
+	for p.skip(); len(p.s) > 0; p.skip() {
+		name := p.name()
+		if name != "/" {
+			return nil, p.errorf("expected root node")
+		}
+		if root == nil {
+			root = newNode("/", 0)
+		}
+		// repeated root nodes are merged, as with dtc
+		if err := p.node(root); err != nil {
+			return nil, err
+		}
+	}
+	if root == nil {
+		return nil, p.errorf("no root node")
+	}
+	return &fdt.Tree{RootNode: root}, nil
+}
+
+type itsParser struct {
+	s    string
+	dir  string
+	line int
+}
+
+func newNode(name string, depth int) *fdt.Node {
+	return &fdt.Node{
+		Name:       name,
+		Depth:      depth,
+		Properties: make(map[string][]byte),
+		Children:   make(map[string]*fdt.Node),
+	}
+}
+
+func (p *itsParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *itsParser) advance(n int) {
+	p.line += strings.Count(p.s[:n], "\n")
+	p.s = p.s[n:]
+}
+
+// skip white space and comments
+func (p *itsParser) skip() {
+	for len(p.s) > 0 {
+		switch {
+		case unicode.IsSpace(rune(p.s[0])):
+			p.advance(1)
+		case strings.HasPrefix(p.s, "//"):
+			n := strings.IndexByte(p.s, '\n')
+			if n < 0 {
+				n = len(p.s)
+			}
+			p.advance(n)
+		case strings.HasPrefix(p.s, "/*"):
+			n := strings.Index(p.s, "*/")
+			if n < 0 {
+				n = len(p.s)
+			} else {
+				n += 2
+			}
+			p.advance(n)
+		default:
+			return
+		}
+	}
+}
+
+func (p *itsParser) expect(tok string) error {
+	if p.skip(); !strings.HasPrefix(p.s, tok) {
+		return p.errorf("expected %q", tok)
+	}
+	p.advance(len(tok))
+	return nil
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' || strings.IndexByte(",._+*#?@-", c) >= 0
+}
+
+func (p *itsParser) name() string {
+	p.skip()
+	if strings.HasPrefix(p.s, "/") && !strings.HasPrefix(p.s, "/incbin/") {
+		p.advance(1)
+		return "/"
+	}
+	n := 0
+	for n < len(p.s) && isNameChar(p.s[n]) {
+		n++
+	}
+	name := p.s[:n]
+	p.advance(n)
+	return name
+}
+
+// node parses the body of a node, from its opening brace through the
+// semicolon after its closing brace.
+func (p *itsParser) node(n *fdt.Node) error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		if p.skip(); strings.HasPrefix(p.s, "}") {
+			p.advance(1)
+			return p.expect(";")
+		}
+		name := p.name()
+		if len(name) == 0 {
+			return p.errorf("expected name")
+		}
+		switch p.skip(); {
+		case strings.HasPrefix(p.s, "{"):
+			c := n.Children[name]
+			if c == nil {
+				c = newNode(name, n.Depth+1)
+				n.Children[name] = c
+			}
+			if err := p.node(c); err != nil {
+				return err
+			}
+		case strings.HasPrefix(p.s, ";"):
+			p.advance(1)
+			n.Properties[name] = []byte{}
+		case strings.HasPrefix(p.s, "="):
+			p.advance(1)
+			v, err := p.value()
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			n.Properties[name] = v
+		default:
+			return p.errorf("%s: unexpected %q", name, p.s[:1])
+		}
+	}
+}
+
+// value parses the comma separated property values through the semicolon.
+func (p *itsParser) value() ([]byte, error) {
+	var v []byte
+	for {
+		p.skip()
+		switch {
+		case strings.HasPrefix(p.s, `"`):
+			s, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			v = append(append(v, s...), 0)
+		case strings.HasPrefix(p.s, "<"):
+			n := strings.IndexByte(p.s, '>')
+			if n < 0 {
+				return nil, p.errorf("unterminated cells")
+			}
+			for _, f := range strings.Fields(p.s[1:n]) {
+				u, err := strconv.ParseUint(f, 0, 32)
+				if err != nil {
+					return nil, p.errorf("%s: invalid cell", f)
+				}
+				v = append(v, 0, 0, 0, 0)
+				binary.BigEndian.PutUint32(v[len(v)-4:], uint32(u))
+			}
+			p.advance(n + 1)
+		case strings.HasPrefix(p.s, "["):
+			n := strings.IndexByte(p.s, ']')
+			if n < 0 {
+				return nil, p.errorf("unterminated bytes")
+			}
+			hex := strings.Join(strings.Fields(p.s[1:n]), "")
+			if len(hex)%2 != 0 {
+				return nil, p.errorf("odd length bytes")
+			}
+			for i := 0; i < len(hex); i += 2 {
+				u, err := strconv.ParseUint(hex[i:i+2], 16, 8)
+				if err != nil {
+					return nil, p.errorf("%s: invalid byte",
+						hex[i:i+2])
+				}
+				v = append(v, byte(u))
+			}
+			p.advance(n + 1)
+		case strings.HasPrefix(p.s, "/incbin/"):
+			p.advance(len("/incbin/"))
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			if p.skip(); !strings.HasPrefix(p.s, `"`) {
+				return nil, p.errorf("expected file name")
+			}
+			fn, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(")"); err != nil {
+				return nil, err
+			}
+			if !filepath.IsAbs(fn) {
+				fn = filepath.Join(p.dir, fn)
+			}
+			b, err := ioutil.ReadFile(fn)
+			if err != nil {
+				return nil, err
+			}
+			v = append(v, b...)
+		default:
+			return nil, p.errorf("invalid value")
+		}
+		if p.skip(); strings.HasPrefix(p.s, ",") {
+			p.advance(1)
+			continue
+		}
+		return v, p.expect(";")
+	}
+}
+
+// str parses a quoted string with C escapes.
+func (p *itsParser) str() (string, error) {
+	n := 1
+	for ; n < len(p.s) && p.s[n] != '"'; n++ {
+		if p.s[n] == '\\' {
+			n++
+		}
+	}
+	if n >= len(p.s) {
+		return "", p.errorf("unterminated string")
+	}
+	s, err := strconv.Unquote(p.s[:n+1])
+	if err != nil {
+		return "", p.errorf("%s: %v", p.s[:n+1], err)
+	}
+	p.advance(n + 1)
+	return s, nil
+}
@@ -12,10 +12,27 @@ import (
 )
 
 func (f *Fit) KexecLoadConfig(conf *Config, offset uintptr) (err error) {
+	if err = f.Allowed(conf); err != nil {
+		return
+	}
 	segments := make([]kexec.KexecSegment, 0, len(conf.ImageList))
 
+	var initrdStart, initrdEnd uint64
+	for _, image := range conf.ImageList {
+		if image.Type == "ramdisk" {
+			initrdStart = image.LoadAddr + uint64(offset)
+			initrdEnd = initrdStart + uint64(len(image.Data))
+		}
+	}
 	for _, image := range conf.ImageList {
-		segments = kexec.SliceAddSegment(segments, &image.Data,
+		data := image.Data
+		if image.Type == "flat_dt" {
+			data, err = f.Fixups.Apply(data, initrdStart, initrdEnd)
+			if err != nil {
+				return fmt.Errorf("%s: %v", image.Name, err)
+			}
+		}
+		segments = kexec.SliceAddSegment(segments, &data,
 			uintptr(image.LoadAddr)+offset)
 	}
 	if f.Debug {
@@ -0,0 +1,106 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fit
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// Key is a public key named as the key-name-hint of the signatures that it
+// verifies; an unnamed key is tried with any hint.
+type Key struct {
+	Name string
+	crypto.PublicKey
+}
+
+type Keyring []Key
+
+// DefaultKeyring verifies the signatures of each parsed Fit. A platform
+// may compile in its keys with an init like,
+//
+//	func init() {
+//		err := fit.DefaultKeyring.Add("platina", []byte(platinaPem))
+//		if err != nil {
+//			panic(err)
+//		}
+//	}
+var DefaultKeyring Keyring
+
+// Add each PUBLIC KEY, RSA PUBLIC KEY or CERTIFICATE of the PEM encoded
+// keys. A block's "Key-Name" header overrides the given name.
+func (k *Keyring) Add(name string, pemKeys []byte) error {
+	n := len(*k)
+	for rest := pemKeys; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		var pub crypto.PublicKey
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				pub = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		switch pub.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+		default:
+			return fmt.Errorf("%T: unsupported key", pub)
+		}
+		key := Key{Name: name, PublicKey: pub}
+		if s, found := block.Headers["Key-Name"]; found {
+			key.Name = s
+		}
+		*k = append(*k, key)
+	}
+	if len(*k) == n {
+		return errors.New("no public keys")
+	}
+	return nil
+}
+
+// ReadKeyring returns the unnamed keys of the PEM file unless named by a
+// "Key-Name" header.
+func ReadKeyring(fn string) (Keyring, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var k Keyring
+	if err = k.Add("", b); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return k, nil
+}
+
+// lookup the keys that may verify a signature with the given hint
+func (k Keyring) lookup(hint string) []Key {
+	var keys []Key
+	for _, key := range k {
+		if len(key.Name) == 0 || key.Name == hint {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,281 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fit
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/fdt"
+)
+
+const signerName = "goes mkfit"
+
+// Make returns the flattened image tree after setting the value of each
+// image hash node. With a key directory, as with mkimage, it also signs each
+// configuration signature node with the private key of KEYDIR/HINT.key
+// where HINT is the node's key-name-hint.
+func Make(t *fdt.Tree, keydir string) ([]byte, error) {
+	root := t.RootNode
+	now := u32(uint32(time.Now().Unix()))
+	if _, found := root.Properties["timestamp"]; !found {
+		root.Properties["timestamp"] = now
+	}
+	if _, found := root.Properties["#address-cells"]; !found {
+		root.Properties["#address-cells"] = u32(1)
+	}
+	images := root.Children["images"]
+	if images == nil {
+		return nil, errors.New("no images")
+	}
+	for _, image := range images.Children {
+		data, found := image.Properties["data"]
+		if !found {
+			return nil, fmt.Errorf("%s: no data", image.Name)
+		}
+		for _, h := range image.Children {
+			if !isNode(h.Name, "hash") {
+				continue
+			}
+			v, err := hashValue(propString(h, "algo"), data)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %v", image.Name,
+					h.Name, err)
+			}
+			h.Properties["value"] = v
+		}
+	}
+
+	type pending struct {
+		path   string
+		nodes  []string
+		algo   string
+		signer crypto.Signer
+	}
+	var sigs []pending
+	if confs := root.Children["configurations"]; confs != nil &&
+		len(keydir) > 0 {
+		for _, conf := range confs.Children {
+			for _, sig := range conf.Children {
+				if !isNode(sig.Name, "signature") {
+					continue
+				}
+				path := "/configurations/" + conf.Name + "/" +
+					sig.Name
+				hint := propString(sig, "key-name-hint")
+				signer, err := ReadSigner(filepath.Join(keydir,
+					hint+".key"))
+				if err != nil {
+					return nil, err
+				}
+				algo := propString(sig, "algo")
+				size, err := signatureSize(signer, algo)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", path, err)
+				}
+				nodes, err := hashedNodes(images, conf)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", path, err)
+				}
+				sig.Properties["value"] = make([]byte, size)
+				sig.Properties["hashed-nodes"] =
+					[]byte(strings.Join(nodes, "\x00") + "\x00")
+				sig.Properties["hashed-strings"] = make([]byte, 8)
+				sig.Properties["signer-name"] = str(signerName)
+				sig.Properties["timestamp"] = now
+				sigs = append(sigs, pending{path, nodes, algo, signer})
+			}
+		}
+	}
+
+	b := t.FlattenTreeToSlice()
+	if len(sigs) == 0 {
+		return b, nil
+	}
+	fb, err := newBlob(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, sig := range sigs {
+		off, err := fb.findProp(sig.path, "hashed-strings")
+		if err != nil {
+			return nil, err
+		}
+		_, hs := fb.prop(off)
+		binary.BigEndian.PutUint32(hs[4:], uint32(fb.stringsSize))
+		value, err := signRegions(fb, sig.nodes, sig.algo, sig.signer)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", sig.path, err)
+		}
+		if off, err = fb.findProp(sig.path, "value"); err != nil {
+			return nil, err
+		}
+		_, v := fb.prop(off)
+		copy(v, value)
+	}
+	return b, nil
+}
+
+func str(s string) []byte { return append([]byte(s), 0) }
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func propString(n *fdt.Node, name string) string {
+	return strings.TrimRight(string(n.Properties[name]), "\x00")
+}
+
+func hashValue(algo string, data []byte) ([]byte, error) {
+	if algo == "crc32" {
+		return u32(crc32.ChecksumIEEE(data)), nil
+	}
+	newHash, found := imageHashes[algo]
+	if !found {
+		return nil, fmt.Errorf("%q: unsupported hash", algo)
+	}
+	h := newHash()
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// hashedNodes returns the paths that sign the configuration: the root, the
+// configuration, its kernel, fdt and ramdisk images and their hash nodes.
+func hashedNodes(images, conf *fdt.Node) ([]string, error) {
+	nodes := []string{"/", "/configurations/" + conf.Name}
+	for _, prop := range []string{"kernel", "fdt", "ramdisk"} {
+		name := propString(conf, prop)
+		if len(name) == 0 {
+			continue
+		}
+		image := images.Children[name]
+		if image == nil {
+			return nil, fmt.Errorf("%s: no such image", name)
+		}
+		path := "/images/" + name
+		nodes = append(nodes, path)
+		var hashes []string
+		for _, h := range image.Children {
+			if isNode(h.Name, "hash") {
+				hashes = append(hashes, path+"/"+h.Name)
+			}
+		}
+		if len(hashes) == 0 {
+			return nil, fmt.Errorf("%s: no hash", name)
+		}
+		sort.Strings(hashes)
+		nodes = append(nodes, hashes...)
+	}
+	return nodes, nil
+}
+
+// signatureSize returns the length of the algorithm's signature value.
+func signatureSize(signer crypto.Signer, algo string) (int, error) {
+	fields := strings.Split(algo, ",")
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("%q: invalid algo", algo)
+	}
+	if _, found := cryptoHashes[fields[0]]; !found {
+		return 0, fmt.Errorf("%s: unsupported hash", fields[0])
+	}
+	switch k := signer.Public().(type) {
+	case *rsa.PublicKey:
+		if fields[1] != fmt.Sprint("rsa", k.N.BitLen()) {
+			return 0, fmt.Errorf("%s: %d bit key", fields[1],
+				k.N.BitLen())
+		}
+		return (k.N.BitLen() + 7) / 8, nil
+	case *ecdsa.PublicKey:
+		if fields[1] != "ecdsa256" || k.Curve != elliptic.P256() {
+			return 0, fmt.Errorf("%s: unsupported key", fields[1])
+		}
+		return 64, nil
+	}
+	return 0, fmt.Errorf("%T: unsupported key", signer.Public())
+}
+
+// signRegions signs the hashed nodes and strings of the blob, then verifies
+// the signature as would Verify.
+func signRegions(fb *blob, nodes []string, algo string,
+	signer crypto.Signer) ([]byte, error) {
+	regions, err := fb.findRegions(nodes, excludedProps)
+	if err != nil {
+		return nil, err
+	}
+	regions = append(regions, region{fb.strings, fb.stringsSize})
+	fields := strings.Split(algo, ",")
+	h := cryptoHashes[fields[0]]
+	hh := h.New()
+	for _, r := range regions {
+		hh.Write(fb.b[r.offset : r.offset+r.size])
+	}
+	digest := hh.Sum(nil)
+	var value []byte
+	if k, ok := signer.(*ecdsa.PrivateKey); ok {
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return nil, err
+		}
+		value = make([]byte, 64)
+		r.FillBytes(value[:32])
+		s.FillBytes(value[32:])
+	} else if value, err = signer.Sign(rand.Reader, digest, h); err != nil {
+		return nil, err
+	}
+	err = verifySignature(signer.Public(), fields[1], h, "", digest, value)
+	return value, err
+}
+
+// ReadSigner returns the PEM encoded RSA or ECDSA private key of the file.
+func ReadSigner(fn string) (crypto.Signer, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	for rest := b; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		var key interface{}
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fn, err)
+		}
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case *ecdsa.PrivateKey:
+			return k, nil
+		}
+		return nil, fmt.Errorf("%s: %T: unsupported key", fn, key)
+	}
+	return nil, fmt.Errorf("%s: no private key", fn)
+}
@@ -0,0 +1,246 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Flattened device tree structure tags
+const (
+	fdtBeginNode = 0x1
+	fdtEndNode   = 0x2
+	fdtProp      = 0x3
+	fdtNop       = 0x4
+	fdtEnd       = 0x9
+)
+
+const fdtMaxDepth = 32
+
+var errBadStructure = errors.New("bad device tree structure")
+
+// blob walks the structure block of a flattened device tree with the
+// offsets needed for signatures; github.com/platinasystems/fdt doesn't
+// keep those.
+type blob struct {
+	b           []byte
+	base        int // offset of the structure block
+	strings     int // offset of the strings block
+	structSize  int
+	stringsSize int
+}
+
+func newBlob(b []byte) (*blob, error) {
+	if len(b) < 40 {
+		return nil, errBadStructure
+	}
+	be := binary.BigEndian
+	fb := &blob{
+		b:           b,
+		base:        int(be.Uint32(b[8:])),
+		strings:     int(be.Uint32(b[12:])),
+		stringsSize: int(be.Uint32(b[32:])),
+		structSize:  int(be.Uint32(b[36:])),
+	}
+	if fb.base+fb.structSize > len(b) ||
+		fb.strings+fb.stringsSize > len(b) {
+		return nil, errBadStructure
+	}
+	return fb, nil
+}
+
+func align4(x int) int { return (x + 3) &^ 3 }
+
+// next returns the tag at the given structure offset and that of the next.
+func (fb *blob) next(off int) (uint32, int, error) {
+	p := fb.base + off
+	if off < 0 || off+4 > fb.structSize {
+		return 0, 0, errBadStructure
+	}
+	tag := binary.BigEndian.Uint32(fb.b[p:])
+	next := off + 4
+	switch tag {
+	case fdtBeginNode:
+		i := bytes.IndexByte(fb.b[p+4:fb.base+fb.structSize], 0)
+		if i < 0 {
+			return 0, 0, errBadStructure
+		}
+		next = align4(next + i + 1)
+	case fdtProp:
+		if next+8 > fb.structSize {
+			return 0, 0, errBadStructure
+		}
+		n := int(binary.BigEndian.Uint32(fb.b[p+4:]))
+		next = align4(next + 8 + n)
+	case fdtEndNode, fdtNop, fdtEnd:
+	default:
+		return 0, 0, fmt.Errorf("%#x: unknown tag at %#x", tag, off)
+	}
+	if next > fb.structSize {
+		return 0, 0, errBadStructure
+	}
+	return tag, next, nil
+}
+
+// nodeName of the begin node tag at the structure offset
+func (fb *blob) nodeName(off int) string {
+	p := fb.base + off + 4
+	return string(fb.b[p : p+bytes.IndexByte(fb.b[p:], 0)])
+}
+
+// prop returns the name and value of the property tag at the offset
+func (fb *blob) prop(off int) (string, []byte) {
+	p := fb.base + off
+	n := int(binary.BigEndian.Uint32(fb.b[p+4:]))
+	nameoff := fb.strings + int(binary.BigEndian.Uint32(fb.b[p+8:]))
+	name := ""
+	if nameoff < len(fb.b) {
+		if i := bytes.IndexByte(fb.b[nameoff:], 0); i >= 0 {
+			name = string(fb.b[nameoff : nameoff+i])
+		}
+	}
+	return name, fb.b[p+12 : p+12+n]
+}
+
+// nodePath returns the path of the given node names from the root.
+func nodePath(nodes []string) string {
+	if len(nodes) < 2 {
+		return "/"
+	}
+	return "/" + strings.Join(nodes[1:], "/")
+}
+
+type region struct {
+	offset, size int
+}
+
+// findRegions is libfdt's fdt_find_regions(); it returns the blob regions
+// of the included nodes, their properties other than those excluded, the
+// begin and end tags of their parents and subnodes, and the end tag. This
+// is what U-Boot hashes to sign a configuration.
+func (fb *blob) findRegions(inc, excProp []string) ([]region, error) {
+	in := func(s string, list []string) bool {
+		for _, x := range list {
+			if x == s {
+				return true
+			}
+		}
+		return false
+	}
+	var (
+		regions []region
+		stack   [fdtMaxDepth]int
+		path    []string
+		tag     uint32
+		next    int
+		err     error
+	)
+	start, depth, want := -1, -1, 0
+	for tag != fdtEnd {
+		off := next
+		if tag, next, err = fb.next(off); err != nil {
+			return nil, err
+		}
+		include := 0
+		stopAt := next
+		switch tag {
+		case fdtProp:
+			stopAt = off
+			if want >= 2 {
+				include = 1
+			}
+			if name, _ := fb.prop(off); in(name, excProp) {
+				include = 0
+			}
+		case fdtNop:
+			stopAt = off
+			if want >= 2 {
+				include = 1
+			}
+		case fdtBeginNode:
+			if depth++; depth == fdtMaxDepth {
+				return nil, errBadStructure
+			}
+			path = append(path, fb.nodeName(off))
+			stack[depth] = want
+			if want == 1 {
+				stopAt = off
+			}
+			if in(nodePath(path), inc) {
+				want = 2
+			} else if want > 0 {
+				want--
+			} else {
+				stopAt = off
+			}
+			include = want
+		case fdtEndNode:
+			if depth < 0 {
+				return nil, errBadStructure
+			}
+			include = want
+			want = stack[depth]
+			depth--
+			path = path[:len(path)-1]
+		case fdtEnd:
+			include = 1
+		}
+		if include > 0 && start == -1 {
+			if n := len(regions); n > 0 &&
+				off == regions[n-1].offset+regions[n-1].size-fb.base {
+				// merge with the previous region
+				start = regions[n-1].offset - fb.base
+				regions = regions[:n-1]
+			} else {
+				start = off
+			}
+		}
+		if include == 0 && start != -1 {
+			regions = append(regions, region{
+				offset: fb.base + start,
+				size:   stopAt - start,
+			})
+			start = -1
+		}
+	}
+	if next != fb.structSize {
+		return nil, errBadStructure
+	}
+	// the end tag
+	return append(regions, region{
+		offset: fb.base + start,
+		size:   next - start,
+	}), nil
+}
+
+// findProp returns the structure offset of the named property of the node
+// with the given path.
+func (fb *blob) findProp(path, name string) (int, error) {
+	var nodes []string
+	for off := 0; ; {
+		tag, next, err := fb.next(off)
+		if err != nil {
+			return 0, err
+		}
+		switch tag {
+		case fdtBeginNode:
+			nodes = append(nodes, fb.nodeName(off))
+		case fdtEndNode:
+			nodes = nodes[:len(nodes)-1]
+		case fdtProp:
+			n, _ := fb.prop(off)
+			if n == name && nodePath(nodes) == path {
+				return off, nil
+			}
+		case fdtEnd:
+			return 0, fmt.Errorf("%s/%s: not found", path, name)
+		}
+		off = next
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fit
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/platinasystems/fdt"
+)
+
+// Policy determines which configurations KexecLoadConfig will load.
+type Policy int
+
+const (
+	// AllowUnsigned loads any configuration with valid image hashes.
+	AllowUnsigned Policy = iota
+	// RequireSigned refuses configurations without a signature verified
+	// with a key of the keyring.
+	RequireSigned
+)
+
+// DefaultPolicy is that of each parsed Fit.
+var DefaultPolicy = AllowUnsigned
+
+var errUnsigned = errors.New("unsigned configuration")
+
+// excludedProps aren't hashed for configuration signatures, as with U-Boot
+var excludedProps = []string{
+	"data",
+	"data-size",
+	"data-position",
+	"data-offset",
+}
+
+var cryptoHashes = map[string]crypto.Hash{
+	"sha1":   crypto.SHA1,
+	"sha256": crypto.SHA256,
+	"sha384": crypto.SHA384,
+	"sha512": crypto.SHA512,
+}
+
+// isNode returns true if the node name is the given base name with an
+// optional "@" unit address or "-" index suffix.
+func isNode(name, base string) bool {
+	return name == base || strings.HasPrefix(name, base+"@") ||
+		strings.HasPrefix(name, base+"-")
+}
+
+// Allowed returns nil if the policy permits loading the configuration.
+func (f *Fit) Allowed(conf *Config) error {
+	if conf == nil {
+		return errors.New("configuration not found")
+	}
+	if f.Policy != RequireSigned || conf.Verified {
+		return nil
+	}
+	if conf.SignatureErr != nil {
+		return fmt.Errorf("%s: %v", conf.Name, conf.SignatureErr)
+	}
+	return fmt.Errorf("%s: %v", conf.Name, errUnsigned)
+}
+
+// Verify the signatures of each configuration with the given keys.
+func (f *Fit) Verify(keys Keyring) {
+	conf := f.fdt.RootNode.Children["configurations"]
+	if conf == nil {
+		return
+	}
+	for name, cfg := range f.Configs {
+		cfg.Signed, cfg.Verified = false, false
+		cfg.KeyName, cfg.SignatureErr = "", nil
+		node := conf.Children[name]
+		var sigs []string
+		for _, c := range node.Children {
+			if isNode(c.Name, "signature") {
+				sigs = append(sigs, c.Name)
+			}
+		}
+		sort.Strings(sigs)
+		for _, sig := range sigs {
+			cfg.Signed = true
+			key, err := f.verifyConfig(name, cfg, node.Children[sig],
+				keys)
+			if err == nil {
+				cfg.Verified, cfg.KeyName = true, key
+				cfg.SignatureErr = nil
+				break
+			}
+			cfg.SignatureErr = fmt.Errorf("%s: %v", sig, err)
+		}
+		if f.Debug && cfg.Signed {
+			fmt.Printf("configuration %s: verified %t key %q %v\n",
+				name, cfg.Verified, cfg.KeyName, cfg.SignatureErr)
+		}
+	}
+}
+
+// verifyConfig returns the name of the key that verified the signature of
+// the hashed nodes.
+func (f *Fit) verifyConfig(name string, cfg *Config, sig *fdt.Node,
+	keys Keyring) (string, error) {
+	algo := f.fdt.PropString(sig.Properties["algo"])
+	value := sig.Properties["value"]
+	hint := f.fdt.PropString(sig.Properties["key-name-hint"])
+	if len(value) == 0 {
+		return "", errors.New("no value")
+	}
+	hashed := sig.Properties["hashed-nodes"]
+	if len(hashed) == 0 {
+		return "", errors.New("no hashed-nodes")
+	}
+	nodes := strings.Split(strings.TrimSuffix(string(hashed), "\x00"),
+		"\x00")
+	// The signature must cover the configuration, its images, and
+	// a verified image hash of sha256 or stronger; a signed crc32, md5
+	// or sha1 doesn't protect the image data.
+	covered := make(map[string]bool)
+	for _, n := range nodes {
+		covered[n] = true
+	}
+	if !covered["/configurations/"+name] {
+		return "", errors.New("configuration isn't signed")
+	}
+	for _, i := range cfg.ImageList {
+		path := "/images/" + i.Name
+		if i.HashErr != nil {
+			return "", fmt.Errorf("%s: %v", i.Name, i.HashErr)
+		}
+		if !covered[path] {
+			return "", fmt.Errorf("%s: image isn't signed", i.Name)
+		}
+		ok := false
+		for _, h := range i.strongHashes {
+			if covered[path+"/"+h] {
+				ok = true
+			}
+		}
+		if !ok {
+			return "", fmt.Errorf("%s: no signed and verified hash",
+				i.Name)
+		}
+	}
+
+	fields := strings.Split(algo, ",")
+	if len(fields) != 2 {
+		return "", fmt.Errorf("%q: invalid algo", algo)
+	}
+	h, found := cryptoHashes[fields[0]]
+	if !found {
+		return "", fmt.Errorf("%s: unsupported hash", fields[0])
+	}
+	fb, err := newBlob(f.blob)
+	if err != nil {
+		return "", err
+	}
+	regions, err := fb.findRegions(nodes, excludedProps)
+	if err != nil {
+		return "", err
+	}
+	if strs := sig.Properties["hashed-strings"]; len(strs) == 8 {
+		// mkimage always starts the strings region at 0
+		size := int(binary.BigEndian.Uint32(strs[4:]))
+		if size > fb.stringsSize {
+			return "", errBadStructure
+		}
+		regions = append(regions, region{fb.strings, size})
+	}
+	hh := h.New()
+	for _, r := range regions {
+		hh.Write(f.blob[r.offset : r.offset+r.size])
+	}
+	digest := hh.Sum(nil)
+
+	candidates := keys.lookup(hint)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no key for %q", hint)
+	}
+	padding := f.fdt.PropString(sig.Properties["padding"])
+	for _, key := range candidates {
+		err = verifySignature(key.PublicKey, fields[1], h, padding,
+			digest, value)
+		if err == nil {
+			if len(key.Name) == 0 {
+				return hint, nil
+			}
+			return key.Name, nil
+		}
+	}
+	return "", err
+}
+
+// verifySignature with U-Boot's algorithm names: rsa2048, rsa3072, rsa4096
+// with pkcs-1.5 or pss padding, and ecdsa256, whose value is r followed by
+// s.
+func verifySignature(pub crypto.PublicKey, alg string, h crypto.Hash,
+	padding string, digest, value []byte) error {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		var bits int
+		if _, err := fmt.Sscanf(alg, "rsa%d", &bits); err != nil {
+			return fmt.Errorf("%s: unsupported algorithm", alg)
+		}
+		if k.N.BitLen() != bits {
+			return fmt.Errorf("%s: %d bit key", alg, k.N.BitLen())
+		}
+		switch padding {
+		case "", "pkcs-1.5":
+			return rsa.VerifyPKCS1v15(k, h, digest, value)
+		case "pss":
+			return rsa.VerifyPSS(k, h, digest, value, nil)
+		}
+		return fmt.Errorf("%s: unsupported padding", padding)
+	case *ecdsa.PublicKey:
+		if alg != "ecdsa256" || k.Curve != elliptic.P256() {
+			return fmt.Errorf("%s: unsupported algorithm", alg)
+		}
+		if len(value) != 64 {
+			return fmt.Errorf("%d byte ecdsa256 signature", len(value))
+		}
+		r := new(big.Int).SetBytes(value[:32])
+		s := new(big.Int).SetBytes(value[32:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("ecdsa verification error")
+		}
+		return nil
+	}
+	return fmt.Errorf("%T: unsupported key", pub)
+}
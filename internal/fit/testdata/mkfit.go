@@ -0,0 +1,330 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// +build ignore
+
+// mkfit generates the known answer images of the fit tests without the
+// fit package: it lays out the blob as dtc and mkimage do, finds the
+// signed regions with U-Boot's fdt_find_regions, and has openssl generate
+// the keys and sign. Run it from this directory,
+//
+//	go run mkfit.go
+//
+// to replace ALGO.itb and the public key, ALGO.pem, of each algorithm.
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	fdtBeginNode = 1
+	fdtEndNode   = 2
+	fdtProp      = 3
+	fdtEnd       = 9
+
+	timestamp = 1546300800 // 2019-01-01
+)
+
+var kernelData = []byte("not really a kernel")
+
+type prop struct {
+	name  string
+	value []byte
+}
+
+type node struct {
+	name     string
+	props    []prop
+	children []*node
+}
+
+func (n *node) set(name string, value []byte) {
+	n.props = append(n.props, prop{name, value})
+}
+
+func str(s string) []byte { return append([]byte(s), 0) }
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// blob is the flattened tree; strings are appended as first used, so the
+// offsets of those in the signed strings region don't change when the
+// signature properties are added.
+type blob struct {
+	strs    []byte
+	offsets map[string]int
+}
+
+func (b *blob) nameoff(name string) uint32 {
+	if off, found := b.offsets[name]; found {
+		return uint32(off)
+	}
+	off := len(b.strs)
+	b.offsets[name] = off
+	b.strs = append(b.strs, str(name)...)
+	return uint32(off)
+}
+
+func pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func (b *blob) structure(s []byte, n *node) []byte {
+	s = append(s, u32(fdtBeginNode)...)
+	s = pad(append(s, str(n.name)...))
+	for _, p := range n.props {
+		s = append(s, u32(fdtProp)...)
+		s = append(s, u32(uint32(len(p.value)))...)
+		s = append(s, u32(b.nameoff(p.name))...)
+		s = pad(append(s, p.value...))
+	}
+	for _, c := range n.children {
+		s = b.structure(s, c)
+	}
+	return append(s, u32(fdtEndNode)...)
+}
+
+// flatten returns the blob and the offset of its structure, followed by
+// the strings.
+func (b *blob) flatten(root *node) ([]byte, int) {
+	s := append(b.structure(nil, root), u32(fdtEnd)...)
+	const offRsvmap = 40
+	offStruct := offRsvmap + 16
+	offStrings := offStruct + len(s)
+	total := offStrings + len(b.strs)
+	h := make([]byte, offStruct)
+	for i, v := range []uint32{
+		0xd00dfeed,
+		uint32(total),
+		uint32(offStruct),
+		uint32(offStrings),
+		offRsvmap,
+		17, // version
+		16, // last compatible version
+		0,  // boot cpu
+		uint32(len(b.strs)),
+		uint32(len(s)),
+	} {
+		binary.BigEndian.PutUint32(h[4*i:], v)
+	}
+	return append(append(h, s...), b.strs...), offStruct
+}
+
+// regions returns the data signed by mkimage, as fdt_find_regions with
+// add_string_tab: the included nodes with their properties, except
+// data, the begin and end tags of their parents and other children, the
+// end tag, and the strings.
+func regions(fdt []byte, offStruct int, inc []string,
+	excl []string) []byte {
+	in := func(s string, l []string) bool {
+		for _, x := range l {
+			if x == s {
+				return true
+			}
+		}
+		return false
+	}
+	be := binary.BigEndian
+	base := offStruct
+	var out []byte
+	var stack []int
+	path := ""
+	want, start := 0, -1
+	for off, next := 0, 0; ; {
+		off = next
+		tag := be.Uint32(fdt[base+off:])
+		next = off + 4
+		stopAt := next
+		include := 0
+		switch tag {
+		case fdtProp:
+			size := int(be.Uint32(fdt[base+off+4:]))
+			nameoff := int(be.Uint32(fdt[base+off+8:]))
+			next = (off + 12 + size + 3) &^ 3
+			stopAt = off
+			if want >= 2 {
+				include = 1
+			}
+			name := fdt[int(be.Uint32(fdt[12:]))+nameoff:]
+			name = name[:bytes.IndexByte(name, 0)]
+			if in(string(name), excl) {
+				include = 0
+			}
+		case fdtBeginNode:
+			name := fdt[base+off+4:]
+			name = name[:bytes.IndexByte(name, 0)]
+			next = (off + 4 + len(name) + 1 + 3) &^ 3
+			stopAt = next
+			if len(path) != 1 {
+				path += "/"
+			}
+			path += string(name)
+			stack = append(stack, want)
+			if want == 1 {
+				stopAt = off
+			}
+			if in(path, inc) {
+				want = 2
+			} else if want > 0 {
+				want--
+			} else {
+				stopAt = off
+			}
+			include = want
+		case fdtEndNode:
+			include = want
+			want = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if i := strings.LastIndex(path, "/"); i >= 0 {
+				path = path[:i]
+			}
+		case fdtEnd:
+			include = 1
+		default:
+			panic(fmt.Errorf("tag %d", tag))
+		}
+		if include != 0 && start == -1 {
+			start = off
+		}
+		if include == 0 && start != -1 {
+			out = append(out, fdt[base+start:base+stopAt]...)
+			start = -1
+		}
+		if tag == fdtEnd {
+			// the end tag and the strings that follow it
+			sizeStrings := int(be.Uint32(fdt[32:]))
+			return append(out,
+				fdt[base+start:base+next+sizeStrings]...)
+		}
+	}
+}
+
+func openssl(args ...string) []byte {
+	cmd := exec.Command("openssl", args...)
+	cmd.Stderr = os.Stderr
+	b, err := cmd.Output()
+	if err != nil {
+		panic(fmt.Errorf("openssl %v: %v", args, err))
+	}
+	return b
+}
+
+func mkfit(dir, keyAlgo string) {
+	key := filepath.Join(dir, keyAlgo+".key")
+	if keyAlgo == "ecdsa256" {
+		openssl("genpkey", "-algorithm", "EC",
+			"-pkeyopt", "ec_paramgen_curve:P-256", "-out", key)
+	} else {
+		openssl("genpkey", "-algorithm", "RSA",
+			"-pkeyopt", "rsa_keygen_bits:"+keyAlgo[3:], "-out", key)
+	}
+	err := ioutil.WriteFile(keyAlgo+".pem",
+		openssl("pkey", "-in", key, "-pubout"), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	sum := sha256.Sum256(kernelData)
+	hash1 := &node{name: "hash-1"}
+	hash1.set("value", sum[:])
+	hash1.set("algo", str("sha256"))
+	hash2 := &node{name: "hash-2"}
+	hash2.set("value", u32(crc32.ChecksumIEEE(kernelData)))
+	hash2.set("algo", str("crc32"))
+	kernel := &node{name: "kernel-1", children: []*node{hash1, hash2}}
+	kernel.set("description", str("kernel"))
+	kernel.set("data", kernelData)
+	kernel.set("type", str("kernel"))
+	kernel.set("arch", str("arm"))
+	kernel.set("os", str("linux"))
+	kernel.set("compression", str("none"))
+	kernel.set("load", u32(0x10008000))
+	kernel.set("entry", u32(0x10008000))
+	sig := &node{name: "signature-1"}
+	sig.set("algo", str("sha256,"+keyAlgo))
+	sig.set("key-name-hint", str("dev"))
+	conf := &node{name: "conf-1", children: []*node{sig}}
+	conf.set("description", str("test configuration"))
+	conf.set("kernel", str("kernel-1"))
+	configs := &node{name: "configurations", children: []*node{conf}}
+	configs.set("default", str("conf-1"))
+	root := &node{children: []*node{
+		&node{name: "images", children: []*node{kernel}},
+		configs,
+	}}
+	root.set("timestamp", u32(timestamp))
+	root.set("description", str("test"))
+	root.set("#address-cells", u32(1))
+
+	inc := []string{
+		"/",
+		"/configurations/conf-1",
+		"/images/kernel-1",
+		"/images/kernel-1/hash-1",
+		"/images/kernel-1/hash-2",
+	}
+	b := &blob{offsets: make(map[string]int)}
+	fdt, offStruct := b.flatten(root)
+	sizeStrings := len(b.strs)
+	signed := filepath.Join(dir, keyAlgo+".regions")
+	err = ioutil.WriteFile(signed, regions(fdt, offStruct, inc,
+		[]string{"data", "data-size", "data-position", "data-offset"}),
+		0644)
+	if err != nil {
+		panic(err)
+	}
+	value := openssl("dgst", "-sha256", "-sign", key, signed)
+	if keyAlgo == "ecdsa256" {
+		var rs struct{ R, S *big.Int }
+		if _, err = asn1.Unmarshal(value, &rs); err != nil {
+			panic(err)
+		}
+		value = make([]byte, 64)
+		rs.R.FillBytes(value[:32])
+		rs.S.FillBytes(value[32:])
+	}
+
+	sig.set("value", value)
+	sig.set("signer-name", str("mkfit"))
+	sig.set("timestamp", u32(timestamp))
+	var nodes []byte
+	for _, s := range inc {
+		nodes = append(nodes, str(s)...)
+	}
+	sig.set("hashed-nodes", nodes)
+	sig.set("hashed-strings", append(u32(0), u32(uint32(sizeStrings))...))
+	fdt, _ = b.flatten(root)
+	if err = ioutil.WriteFile(keyAlgo+".itb", fdt, 0644); err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	dir, err := ioutil.TempDir("", "mkfit")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, algo := range []string{"rsa2048", "rsa4096", "ecdsa256"} {
+		mkfit(dir, algo)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fru
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// Types of a field's type/length byte
+const (
+	Binary  = 0x00
+	BCDPlus = 0x40
+	ASCII6  = 0x80
+	Text    = 0xc0
+
+	typeMask    = 0xc0
+	lenMask     = 0x3f
+	endOfFields = Text | 1
+)
+
+// MaxFieldLen of an encoded field value.
+const MaxFieldLen = lenMask
+
+const bcdPlus = "0123456789 -.???"
+
+// Field is a type/length encoded value of an area.
+type Field struct {
+	Type byte
+	Data []byte
+}
+
+// NewText returns an 8-bit ASCII field of the string, truncated to
+// MaxFieldLen. A single character is padded with a space since its
+// type/length byte would otherwise mark the end of fields.
+func NewText(s string) Field {
+	if len(s) > MaxFieldLen {
+		s = s[:MaxFieldLen]
+	}
+	if len(s) == 1 {
+		s += " "
+	}
+	return Field{Text, []byte(s)}
+}
+
+// NewBCDPlus returns a BCD plus field of the digits, space, dash and
+// period in the string.
+func NewBCDPlus(s string) (Field, error) {
+	if len(s)%2 != 0 {
+		s += " "
+	}
+	f := Field{BCDPlus, make([]byte, len(s)/2)}
+	for i := 0; i < len(s); i++ {
+		n := strings.IndexByte(bcdPlus[:13], s[i])
+		if n < 0 {
+			return Field{}, ErrValue
+		}
+		f.Data[i/2] |= byte(n) << uint(4*(1-i%2))
+	}
+	return f, nil
+}
+
+// NewASCII6 returns a packed 6-bit ASCII field of the string, which must
+// have only characters ' ' through '_'.
+func NewASCII6(s string) (Field, error) {
+	f := Field{Type: ASCII6}
+	var acc, n uint
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' || s[i] > '_' {
+			return Field{}, ErrValue
+		}
+		acc |= uint(s[i]-' ') << n
+		for n += 6; n >= 8; n -= 8 {
+			f.Data = append(f.Data, byte(acc))
+			acc >>= 8
+		}
+	}
+	if n > 0 {
+		f.Data = append(f.Data, byte(acc))
+	}
+	return f, nil
+}
+
+// String of the decoded value; trailing spaces of BCD plus and 6-bit ASCII
+// padding are trimmed and binary is hex encoded.
+func (f Field) String() string {
+	switch f.Type {
+	case Text:
+		return string(f.Data)
+	case BCDPlus:
+		b := make([]byte, 0, 2*len(f.Data))
+		for _, c := range f.Data {
+			b = append(b, bcdPlus[c>>4], bcdPlus[c&0xf])
+		}
+		return strings.TrimRight(string(b), " ")
+	case ASCII6:
+		var b []byte
+		var acc, n uint
+		for _, c := range f.Data {
+			acc |= uint(c) << n
+			for n += 8; n >= 6; n -= 6 {
+				b = append(b, byte(acc&0x3f)+' ')
+				acc >>= 6
+			}
+		}
+		return strings.TrimRight(string(b), " ")
+	}
+	return hex.EncodeToString(f.Data)
+}
+
+// append the type/length byte and value of the field.
+func (f Field) append(b []byte) ([]byte, error) {
+	if f.Type&^typeMask != 0 {
+		return nil, ErrValue
+	}
+	if len(f.Data) > MaxFieldLen || (f.Type == Text && len(f.Data) == 1) {
+		return nil, ErrLength
+	}
+	b = append(b, f.Type|byte(len(f.Data)))
+	return append(b, f.Data...), nil
+}
@@ -0,0 +1,391 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package fru encodes and decodes the Field Replaceable Unit information of
+// the IPMI Platform Management FRU Information Storage Definition v1.0.
+package fru
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors of the FRU format and its areas.
+var (
+	ErrFormat   = errors.New("not in IPMI FRU format")
+	ErrChecksum = errors.New("checksum mismatch")
+	ErrLength   = errors.New("invalid length")
+	ErrValue    = errors.New("invalid value")
+)
+
+// AreaError is an error of the named area, e.g. "board".
+type AreaError struct {
+	Area string
+	Err  error
+}
+
+func (e *AreaError) Error() string {
+	return e.Area + ": " + e.Err.Error()
+}
+
+const (
+	// HeaderLen of the common header preceding the areas.
+	HeaderLen = 8
+	// English is the default language code of the board and product.
+	English = 0
+	// RackMount is the SMBIOS chassis type of a rack mount chassis.
+	RackMount = 0x17
+
+	formatVersion = 1
+	recordVersion = 2
+	endOfList     = 0x80
+	recordLen     = 5
+)
+
+// epoch of the board manufacture date, in minutes
+var epoch = time.Date(1996, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Info is the FRU information of the common header's areas; nil areas are
+// absent.
+type Info struct {
+	// Internal use area, less its version
+	Internal []byte
+	Chassis  *Chassis
+	Board    *Board
+	Product  *Product
+	Records  []Record
+}
+
+// Chassis info area
+type Chassis struct {
+	Type         byte
+	PartNumber   Field
+	SerialNumber Field
+	Custom       []Field
+}
+
+// Board info area; a zero MfgDate is unspecified.
+type Board struct {
+	Language     byte
+	MfgDate      time.Time
+	Manufacturer Field
+	ProductName  Field
+	SerialNumber Field
+	PartNumber   Field
+	FileID       Field
+	Custom       []Field
+}
+
+// Product info area
+type Product struct {
+	Language     byte
+	Manufacturer Field
+	Name         Field
+	PartNumber   Field
+	Version      Field
+	SerialNumber Field
+	AssetTag     Field
+	FileID       Field
+	Custom       []Field
+}
+
+// Record of the multirecord area, e.g. an OEM record with a leading 3 byte
+// IANA manufacturer id.
+type Record struct {
+	Type byte
+	Data []byte
+}
+
+// Parse the FRU information of the memory, which may have trailing bytes.
+func Parse(b []byte) (*Info, error) {
+	if len(b) < HeaderLen || b[0]&0xf != formatVersion {
+		return nil, ErrFormat
+	}
+	if checksum(b[:HeaderLen]) != 0 {
+		return nil, &AreaError{"header", ErrChecksum}
+	}
+	var off [5]int
+	for i := range off {
+		off[i] = 8 * int(b[1+i])
+		if off[i] >= len(b) {
+			return nil, &AreaError{"header", ErrLength}
+		}
+	}
+	info := new(Info)
+	if off[0] > 0 {
+		end := len(b)
+		for _, o := range off[1:] {
+			if o > off[0] && o < end {
+				end = o
+			}
+		}
+		if b[off[0]]&0xf != formatVersion {
+			return nil, &AreaError{"internal", ErrFormat}
+		}
+		info.Internal = append([]byte{}, b[off[0]+1:end]...)
+	}
+	if off[1] > 0 {
+		a, err := area(b, off[1], "chassis")
+		if err != nil {
+			return nil, err
+		}
+		f, err := fields(a, 3, 2, "chassis")
+		if err != nil {
+			return nil, err
+		}
+		info.Chassis = &Chassis{
+			Type:         a[2],
+			PartNumber:   f[0],
+			SerialNumber: f[1],
+			Custom:       custom(f[2:]),
+		}
+	}
+	if off[2] > 0 {
+		a, err := area(b, off[2], "board")
+		if err != nil {
+			return nil, err
+		}
+		f, err := fields(a, 6, 5, "board")
+		if err != nil {
+			return nil, err
+		}
+		info.Board = &Board{
+			Language:     a[2],
+			Manufacturer: f[0],
+			ProductName:  f[1],
+			SerialNumber: f[2],
+			PartNumber:   f[3],
+			FileID:       f[4],
+			Custom:       custom(f[5:]),
+		}
+		min := int(a[3]) | int(a[4])<<8 | int(a[5])<<16
+		if min > 0 {
+			info.Board.MfgDate =
+				epoch.Add(time.Duration(min) * time.Minute)
+		}
+	}
+	if off[3] > 0 {
+		a, err := area(b, off[3], "product")
+		if err != nil {
+			return nil, err
+		}
+		f, err := fields(a, 3, 7, "product")
+		if err != nil {
+			return nil, err
+		}
+		info.Product = &Product{
+			Language:     a[2],
+			Manufacturer: f[0],
+			Name:         f[1],
+			PartNumber:   f[2],
+			Version:      f[3],
+			SerialNumber: f[4],
+			AssetTag:     f[5],
+			FileID:       f[6],
+			Custom:       custom(f[7:]),
+		}
+	}
+	if off[4] > 0 {
+		records, err := parseRecords(b[off[4]:])
+		if err != nil {
+			return nil, err
+		}
+		info.Records = records
+	}
+	return info, nil
+}
+
+// area of the memory at the offset, including its version, length and
+// checksum.
+func area(b []byte, off int, name string) ([]byte, error) {
+	if off+2 > len(b) || b[off]&0xf != formatVersion {
+		return nil, &AreaError{name, ErrFormat}
+	}
+	n := 8 * int(b[off+1])
+	if n == 0 || off+n > len(b) {
+		return nil, &AreaError{name, ErrLength}
+	}
+	a := b[off : off+n]
+	if checksum(a) != 0 {
+		return nil, &AreaError{name, ErrChecksum}
+	}
+	return a, nil
+}
+
+// fields of the area beginning at i, of which there must be at least min.
+func fields(a []byte, i, min int, name string) ([]Field, error) {
+	var f []Field
+	for {
+		if i >= len(a)-1 {
+			return nil, &AreaError{name, ErrLength}
+		}
+		if a[i] == endOfFields {
+			break
+		}
+		t, n := a[i]&typeMask, int(a[i]&lenMask)
+		if i+1+n > len(a)-1 {
+			return nil, &AreaError{name, ErrLength}
+		}
+		f = append(f, Field{t, append([]byte{}, a[i+1:i+1+n]...)})
+		i += 1 + n
+	}
+	if len(f) < min {
+		return nil, &AreaError{name, ErrFormat}
+	}
+	return f, nil
+}
+
+// custom fields following those fixed, nil if none
+func custom(f []Field) []Field {
+	if len(f) == 0 {
+		return nil
+	}
+	return f
+}
+
+func parseRecords(b []byte) ([]Record, error) {
+	var records []Record
+	for {
+		if len(b) < recordLen {
+			return nil, &AreaError{"multirecord", ErrLength}
+		}
+		h := b[:recordLen]
+		if checksum(h) != 0 {
+			return nil, &AreaError{"multirecord", ErrChecksum}
+		}
+		if h[1]&0xf != recordVersion {
+			return nil, &AreaError{"multirecord", ErrFormat}
+		}
+		n := int(h[2])
+		if recordLen+n > len(b) {
+			return nil, &AreaError{"multirecord", ErrLength}
+		}
+		data := b[recordLen : recordLen+n]
+		if checksum(data) != h[3] {
+			return nil, &AreaError{"multirecord", ErrChecksum}
+		}
+		records = append(records, Record{h[0], append([]byte{}, data...)})
+		if h[1]&endOfList != 0 {
+			return records, nil
+		}
+		b = b[recordLen+n:]
+	}
+}
+
+// Bytes of the common header and its areas.
+func (info *Info) Bytes() ([]byte, error) {
+	b := make([]byte, HeaderLen, 256)
+	b[0] = formatVersion
+	add := func(i int, a []byte) error {
+		if len(b)/8 > 0xff {
+			return &AreaError{"header", ErrLength}
+		}
+		b[1+i] = byte(len(b) / 8)
+		b = append(b, a...)
+		return nil
+	}
+	if info.Internal != nil {
+		a := append([]byte{formatVersion}, info.Internal...)
+		for len(a)%8 != 0 {
+			a = append(a, 0)
+		}
+		if err := add(0, a); err != nil {
+			return nil, err
+		}
+	}
+	if c := info.Chassis; c != nil {
+		a, err := encodeArea("chassis", []byte{c.Type},
+			append([]Field{c.PartNumber, c.SerialNumber},
+				c.Custom...))
+		if err == nil {
+			err = add(1, a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p := info.Board; p != nil {
+		var min time.Duration
+		if !p.MfgDate.IsZero() {
+			min = p.MfgDate.Sub(epoch) / time.Minute
+			if min <= 0 || min >= 1<<24 {
+				return nil, &AreaError{"board", ErrValue}
+			}
+		}
+		a, err := encodeArea("board",
+			[]byte{p.Language, byte(min), byte(min >> 8),
+				byte(min >> 16)},
+			append([]Field{p.Manufacturer, p.ProductName,
+				p.SerialNumber, p.PartNumber, p.FileID},
+				p.Custom...))
+		if err == nil {
+			err = add(2, a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p := info.Product; p != nil {
+		a, err := encodeArea("product", []byte{p.Language},
+			append([]Field{p.Manufacturer, p.Name, p.PartNumber,
+				p.Version, p.SerialNumber, p.AssetTag,
+				p.FileID}, p.Custom...))
+		if err == nil {
+			err = add(3, a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(info.Records) > 0 {
+		var a []byte
+		for i, r := range info.Records {
+			if len(r.Data) > 0xff {
+				return nil, &AreaError{"multirecord", ErrLength}
+			}
+			h := []byte{r.Type, recordVersion, byte(len(r.Data)),
+				checksum(r.Data), 0}
+			if i == len(info.Records)-1 {
+				h[1] |= endOfList
+			}
+			h[4] = checksum(h[:4])
+			a = append(append(a, h...), r.Data...)
+		}
+		if err := add(4, a); err != nil {
+			return nil, err
+		}
+	}
+	b[7] = checksum(b[:7])
+	return b, nil
+}
+
+// encodeArea of the fixed bytes following the version and length, then
+// the fields, end of fields, padding and checksum.
+func encodeArea(name string, fixed []byte, f []Field) ([]byte, error) {
+	a := append([]byte{formatVersion, 0}, fixed...)
+	for _, x := range f {
+		var err error
+		if a, err = x.append(a); err != nil {
+			return nil, &AreaError{name, err}
+		}
+	}
+	a = append(a, endOfFields)
+	for (len(a)+1)%8 != 0 {
+		a = append(a, 0)
+	}
+	if (len(a)+1)/8 > 0xff {
+		return nil, &AreaError{name, ErrLength}
+	}
+	a[1] = byte((len(a) + 1) / 8)
+	return append(a, checksum(a)), nil
+}
+
+// checksum that zeroes the sum of the bytes
+func checksum(b []byte) byte {
+	var sum byte
+	for _, c := range b {
+		sum += c
+	}
+	return -sum
+}
@@ -0,0 +1,195 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fru
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/go/internal/eeprom"
+)
+
+// board is an image of a board area with empty fields.
+var board = []byte{
+	0x01, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0xfd,
+	0x01, 0x02, 0x19, 0x00, 0x00, 0x00, 0xc0, 0xc0,
+	0xc0, 0xc0, 0xc0, 0xc1, 0x00, 0x00, 0x00, 0x63,
+}
+
+func areaErr(err error) error {
+	if ae, ok := err.(*AreaError); ok {
+		return ae.Err
+	}
+	return err
+}
+
+func TestField(t *testing.T) {
+	for _, x := range []struct {
+		f    Field
+		data []byte
+		s    string
+	}{
+		{NewText("A"), []byte("A "), "A "},
+		{Field{Binary, []byte{0xbc, 0x65}}, []byte{0xbc, 0x65}, "bc65"},
+	} {
+		if !bytes.Equal(x.f.Data, x.data) || x.f.String() != x.s {
+			t.Errorf("%q: got % x %q", x.s, x.f.Data, x.f.String())
+		}
+	}
+	f, err := NewBCDPlus("123-45.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Data, []byte{0x12, 0x3b, 0x45, 0xc6}) ||
+		f.String() != "123-45.6" {
+		t.Errorf("BCD plus: % x %q", f.Data, f.String())
+	}
+	if f, err = NewBCDPlus("123"); err != nil || f.String() != "123" {
+		t.Errorf("BCD plus: %q %v", f.String(), err)
+	}
+	f, err = NewASCII6("IPMI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Data, []byte{0x29, 0xdc, 0xa6}) ||
+		f.String() != "IPMI" {
+		t.Errorf("6-bit ASCII: % x %q", f.Data, f.String())
+	}
+	if f, err = NewASCII6("FRU-1"); err != nil || f.String() != "FRU-1" {
+		t.Errorf("6-bit ASCII: %q %v", f.String(), err)
+	}
+	if _, err = NewBCDPlus("12a"); err != ErrValue {
+		t.Error("BCD plus:", err)
+	}
+	if _, err = NewASCII6("lower"); err != ErrValue {
+		t.Error("6-bit ASCII:", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	info, err := Parse(append(board, 0xff, 0xff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Chassis != nil || info.Product != nil || info.Board == nil {
+		t.Fatalf("%+v", info)
+	}
+	if p := info.Board; p.Language != 25 || !p.MfgDate.IsZero() ||
+		len(p.Custom) != 0 || p.ProductName.Type != Text {
+		t.Errorf("%+v", p)
+	}
+	for _, x := range []struct {
+		name string
+		i    int
+		v    byte
+		err  error
+	}{
+		{"version", 0, 0x02, ErrFormat},
+		{"header", 7, 0xfe, ErrChecksum},
+		{"board", 23, 0x64, ErrChecksum},
+		{"area length", 9, 0x04, ErrLength},
+		{"fields", 16, 0xc1, ErrFormat},
+		{"end of fields", 19, 0xc0, ErrLength},
+	} {
+		b := append([]byte{}, board...)
+		b[x.i] = x.v
+		if x.i != 7 && x.i > 0 && x.i < HeaderLen {
+			b[7] = checksum(b[:7])
+		}
+		if x.i > HeaderLen && x.i != 23 {
+			b[23] = 0
+			b[23] = checksum(b[8:24])
+		}
+		if _, err = Parse(b); areaErr(err) != x.err {
+			t.Errorf("%s: got %v, want %v", x.name, err, x.err)
+		}
+	}
+	if _, err = Parse(board[:20]); areaErr(err) != ErrLength {
+		t.Error("truncated:", err)
+	}
+}
+
+func TestTlvInfo(t *testing.T) {
+	b, err := ioutil.ReadFile("../eeprom/testdata/platina-mk1.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlvs, err := eeprom.ParseTlvInfo(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := FromTlvInfo(tlvs)
+	img, err := info.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum(img[:HeaderLen]) != 0 {
+		t.Errorf("header % x", img[:HeaderLen])
+	}
+	again, err := Parse(append(img, make([]byte, 64)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info, again) {
+		t.Errorf("got %+v\nwant %+v", again, info)
+	}
+	for _, x := range []struct {
+		f    Field
+		want string
+	}{
+		{again.Board.ProductName, "BT-77O"},
+		{again.Chassis.SerialNumber, again.Board.SerialNumber.String()},
+		{again.Product.Version, "1 "},
+	} {
+		if s := x.f.String(); s != x.want {
+			t.Errorf("got %q, want %q", s, x.want)
+		}
+	}
+	date := time.Date(2017, 3, 9, 11, 22, 0, 0, time.UTC)
+	if !again.Board.MfgDate.Equal(date) {
+		t.Error("manufacture date:", again.Board.MfgDate)
+	}
+	mac := []byte{0x65, 0xbc, 0x00, 0x02, 0x46, 0x8a, 0x00, 0x01, 0xfe,
+		0x00, 0x04}
+	if len(again.Records) != 1 || again.Records[0].Type != MACRecord ||
+		!bytes.Equal(again.Records[0].Data, mac) {
+		t.Errorf("records: %+v", again.Records)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	info := &Info{
+		Internal: []byte{1, 2, 3},
+		Product: &Product{
+			Name:   Field{Text, make([]byte, MaxFieldLen+1)},
+			Custom: []Field{NewText("x")},
+		},
+	}
+	if _, err := info.Bytes(); areaErr(err) != ErrLength {
+		t.Error("long field:", err)
+	}
+	info.Product.Name = NewText("switch")
+	info.Board = &Board{MfgDate: epoch.Add(-time.Hour)}
+	if _, err := info.Bytes(); areaErr(err) != ErrValue {
+		t.Error("manufacture date:", err)
+	}
+	info.Board = nil
+	info.Records = []Record{{0xc1, nil}, {0xc2, []byte{9}}}
+	b, err := info.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Internal, []byte{1, 2, 3, 0, 0, 0, 0}) ||
+		len(again.Records) != 2 || again.Product.Custom[0].String() != "x " {
+		t.Errorf("%+v", again)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fru
+
+import (
+	"time"
+
+	"github.com/platinasystems/go/internal/eeprom"
+)
+
+// ONIE TlvInfo types of the FRU fields
+const (
+	onieProductName     = 0x21
+	oniePartNumber      = 0x22
+	onieSerialNumber    = 0x23
+	onieBaseMAC         = 0x24
+	onieManufactureDate = 0x25
+	onieDeviceVersion   = 0x26
+	onieLabelRevision   = 0x27
+	oniePlatformName    = 0x28
+	onieMACs            = 0x2a
+	onieManufacturer    = 0x2b
+	onieVendor          = 0x2d
+	onieServiceTag      = 0x2f
+)
+
+// MACRecord is the OEM multirecord type of the ONIE base MAC address and
+// big-endian count that follow Platina's IANA manufacturer id.
+const MACRecord = 0xc0
+
+// FromTlvInfo returns the FRU information of the ONIE TlvInfo:
+//
+//	chassis: rack mount, part and serial number
+//	board: manufacture date, manufacturer, product name, serial and
+//		part number
+//	product: vendor, platform name, part number, label revision or
+//		device version, serial number and service tag as asset tag
+//	multirecord: the base MAC address and count
+//
+// The manufacture date is taken as UTC and omitted if out of the FRU's
+// range.
+func FromTlvInfo(tlvs *eeprom.TlvInfo) *Info {
+	text := func(types ...byte) Field {
+		for _, t := range types {
+			if tlv, found := tlvs.Get(t); found {
+				return NewText(eeprom.Format(t, tlv.Value))
+			}
+		}
+		return NewText("")
+	}
+	info := &Info{
+		Chassis: &Chassis{
+			Type:         RackMount,
+			PartNumber:   text(oniePartNumber),
+			SerialNumber: text(onieSerialNumber),
+		},
+		Board: &Board{
+			Language:     English,
+			Manufacturer: text(onieManufacturer, onieVendor),
+			ProductName:  text(onieProductName),
+			SerialNumber: text(onieSerialNumber),
+			PartNumber:   text(oniePartNumber),
+			FileID:       NewText(""),
+		},
+		Product: &Product{
+			Language:     English,
+			Manufacturer: text(onieVendor, onieManufacturer),
+			Name:         text(oniePlatformName, onieProductName),
+			PartNumber:   text(oniePartNumber),
+			Version: text(onieLabelRevision,
+				onieDeviceVersion),
+			SerialNumber: text(onieSerialNumber),
+			AssetTag:     text(onieServiceTag),
+			FileID:       NewText(""),
+		},
+	}
+	if tlv, found := tlvs.Get(onieManufactureDate); found {
+		t, err := time.Parse(eeprom.DateFormat, string(tlv.Value))
+		min := t.Sub(epoch) / time.Minute
+		if err == nil && min > 0 && min < 1<<24 {
+			info.Board.MfgDate = t.Truncate(time.Minute)
+		}
+	}
+	if base, found := tlvs.Get(onieBaseMAC); found &&
+		len(base.Value) == 6 {
+		pen := uint32(eeprom.PlatinaPEN)
+		data := []byte{byte(pen), byte(pen >> 8), byte(pen >> 16)}
+		data = append(data, base.Value...)
+		n := []byte{0, 1}
+		if tlv, found := tlvs.Get(onieMACs); found &&
+			len(tlv.Value) == 2 {
+			n = tlv.Value
+		}
+		info.Records = append(info.Records,
+			Record{MACRecord, append(data, n...)})
+	}
+	return info
+}
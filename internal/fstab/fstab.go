@@ -0,0 +1,290 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package fstab parses fstab(5) and /proc/self/mountinfo, and translates
+// mount options to mount(2) flags and data.
+package fstab
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const File = "/etc/fstab"
+
+type Entry struct {
+	Spec    string // device, specifier, or source directory of a bind
+	File    string // mount point
+	Type    string
+	Options []string
+	Freq    int
+	PassNo  int
+}
+
+// Option returns the value of NAME=VALUE and true if the entry has the
+// option; a NAME option has an empty value.
+func (e *Entry) Option(name string) (string, bool) {
+	return Option(e.Options, name)
+}
+
+// Option returns the value of NAME=VALUE and true if the list has the
+// option; a NAME option has an empty value. The last one wins.
+func Option(opts []string, name string) (v string, found bool) {
+	for _, opt := range opts {
+		if opt == name {
+			v, found = "", true
+		} else if strings.HasPrefix(opt, name+"=") {
+			v, found = opt[len(name)+1:], true
+		}
+	}
+	return
+}
+
+func (e *Entry) NoAuto() bool {
+	_, found := e.Option("noauto")
+	return found
+}
+
+func (e *Entry) NoFail() bool {
+	_, found := e.Option("nofail")
+	return found
+}
+
+func (e *Entry) Bind() bool {
+	_, bind := e.Option("bind")
+	_, rbind := e.Option("rbind")
+	return bind || rbind
+}
+
+// Swap entries aren't mounted.
+func (e *Entry) Swap() bool {
+	return e.Type == "swap"
+}
+
+func (e *Entry) String() string {
+	opts := strings.Join(e.Options, ",")
+	if len(opts) == 0 {
+		opts = "defaults"
+	}
+	return fmt.Sprintf("%s %s %s %s %d %d", escape(e.Spec), escape(e.File),
+		e.Type, opts, e.Freq, e.PassNo)
+}
+
+// Read the fstab file.
+func Read(fn string) ([]Entry, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return entries, nil
+}
+
+// Parse fstab lines, skipping blanks and comments. The type defaults to
+// auto, and the options to defaults.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if len(s) == 0 || s[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(s)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: missing mount point",
+				line)
+		}
+		e := Entry{
+			Spec: Unescape(fields[0]),
+			File: Unescape(fields[1]),
+			Type: "auto",
+		}
+		if len(fields) > 2 {
+			e.Type = fields[2]
+		}
+		if len(fields) > 3 {
+			for _, opt := range strings.Split(fields[3], ",") {
+				if len(opt) > 0 && opt != "defaults" {
+					e.Options = append(e.Options, opt)
+				}
+			}
+		}
+		for i, p := range []*int{&e.Freq, &e.PassNo} {
+			if len(fields) > 4+i {
+				n, err := strconv.Atoi(fields[4+i])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v",
+						line, err)
+				}
+				*p = n
+			}
+		}
+		if len(fields) > 6 {
+			return nil, fmt.Errorf("line %d: %v: unexpected", line,
+				fields[6:])
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Unescape the octal escapes of fstab and mountinfo fields, e.g. \040 for
+// space.
+func Unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			c, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+			if err == nil {
+				b = append(b, byte(c))
+				i += 3
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+func escape(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ' ', '\t', '\n', '\\':
+			b = append(b, fmt.Sprintf("\\%03o", c)...)
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+// under returns true if the path is the directory or below it.
+func under(path, dir string) bool {
+	path, dir = filepath.Clean(path), filepath.Clean(dir)
+	return path == dir || dir == "/" ||
+		strings.HasPrefix(path, dir+"/")
+}
+
+// Order returns levels of entries where each level depends only on those
+// of previous levels, and may be mounted in parallel. An entry depends on
+// the entries mounted on its parent directories, the source of a bind, the
+// directories of overlay options, and the paths of its
+// x-systemd.requires-mounts-for options. It also depends on any entry
+// named by its x-systemd.after or x-systemd.requires options. Otherwise
+// entries are kept in file order. Swap entries are dropped.
+func Order(entries []Entry) ([][]Entry, error) {
+	var mounts []Entry
+	for _, e := range entries {
+		if !e.Swap() {
+			mounts = append(mounts, e)
+		}
+	}
+	deps := make([][]int, len(mounts))
+	for i, e := range mounts {
+		var paths []string
+		if e.Bind() {
+			paths = append(paths, e.Spec)
+		}
+		for _, opt := range e.Options {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch kv[0] {
+			case "x-systemd.requires-mounts-for":
+				paths = append(paths, kv[1])
+			case "lowerdir":
+				paths = append(paths, strings.Split(kv[1], ":")...)
+			case "upperdir", "workdir":
+				paths = append(paths, kv[1])
+			}
+		}
+		after := make(map[string]bool)
+		for _, name := range []string{"x-systemd.after",
+			"x-systemd.requires"} {
+			for _, opt := range e.Options {
+				if strings.HasPrefix(opt, name+"=") {
+					after[opt[len(name)+1:]] = true
+				}
+			}
+		}
+		for j, o := range mounts {
+			if i == j || o.File == e.File {
+				continue
+			}
+			dep := under(e.File, o.File) || after[o.File] ||
+				after[o.Spec]
+			for _, p := range paths {
+				if under(p, o.File) {
+					dep = true
+				}
+			}
+			if dep {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+	level := make([]int, len(mounts))
+	for i := range level {
+		level[i] = -1
+	}
+	var visit func(i int, path []bool) error
+	visit = func(i int, path []bool) error {
+		if level[i] >= 0 {
+			return nil
+		}
+		if path[i] {
+			return fmt.Errorf("%s: mount dependency cycle",
+				mounts[i].File)
+		}
+		path[i] = true
+		l := 0
+		for _, j := range deps[i] {
+			if err := visit(j, path); err != nil {
+				return err
+			}
+			if level[j]+1 > l {
+				l = level[j] + 1
+			}
+		}
+		path[i] = false
+		level[i] = l
+		return nil
+	}
+	for i := range mounts {
+		if err := visit(i, make([]bool, len(mounts))); err != nil {
+			return nil, err
+		}
+	}
+	idx := make([]int, len(mounts))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		return level[idx[a]] < level[idx[b]]
+	})
+	var levels [][]Entry
+	for _, i := range idx {
+		if level[i] == len(levels) {
+			levels = append(levels, nil)
+		}
+		levels[level[i]] = append(levels[level[i]], mounts[i])
+	}
+	return levels, nil
+}
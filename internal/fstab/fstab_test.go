@@ -0,0 +1,148 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fstab
+
+import (
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+const testFstab = `
+# comment
+UUID=1234-ABCD	/boot	vfat	defaults,noauto	0 2
+LABEL=root	/	ext4	rw,noatime	0 1
+/dev/sda3	none	swap	sw
+tmpfs		/run	tmpfs	mode=0755,nosuid
+/run/data	/mnt/my\040data	none	bind,nofail
+overlay		/etc	overlay	lowerdir=/ro/etc,upperdir=/run/etc/upper,workdir=/run/etc/work
+proc		/proc	proc
+`
+
+func TestParse(t *testing.T) {
+	entries, err := Parse(strings.NewReader(testFstab))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 7 {
+		t.Fatalf("%d entries", len(entries))
+	}
+	e := entries[0]
+	if e.Spec != "UUID=1234-ABCD" || e.File != "/boot" ||
+		e.Type != "vfat" || !e.NoAuto() || e.PassNo != 2 {
+		t.Error("boot:", e.String())
+	}
+	if e := entries[4]; e.File != "/mnt/my data" || !e.Bind() ||
+		!e.NoFail() {
+		t.Error("bind:", e.String())
+	}
+	if s := entries[4].String(); s != `/run/data /mnt/my\040data none bind,nofail 0 0` {
+		t.Error("String:", s)
+	}
+	if e := entries[6]; e.Type != "proc" || len(e.Options) != 0 {
+		t.Error("proc:", e.String())
+	}
+	if _, err = Parse(strings.NewReader("/dev/sda1\n")); err == nil {
+		t.Error("missing mount point")
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	for s, want := range map[string]string{
+		`/a\040b`:  "/a b",
+		`/a\011b`:  "/a\tb",
+		`/a\134b`:  `/a\b`,
+		`/a\b`:     `/a\b`,
+		`/trail\0`: `/trail\0`,
+	} {
+		if got := Unescape(s); got != want {
+			t.Errorf("%q: got %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	entries, err := Parse(strings.NewReader(testFstab))
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels, err := Order(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][]string
+	for _, level := range levels {
+		var files []string
+		for _, e := range level {
+			files = append(files, e.File)
+		}
+		got = append(got, files)
+	}
+	want := [][]string{
+		{"/"},
+		{"/boot", "/run", "/proc"},
+		{"/mnt/my data", "/etc"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	cycle := []Entry{
+		{Spec: "/b", File: "/a", Type: "none", Options: []string{"bind"}},
+		{Spec: "/a", File: "/b", Type: "none", Options: []string{"bind"}},
+	}
+	if _, err = Order(cycle); err == nil {
+		t.Error("cycle not detected")
+	}
+}
+
+func TestFlags(t *testing.T) {
+	flags, data := Flags(Split("ro,nosuid,noauto,nofail,x-systemd.after=/,mode=0755,loop,offset=512,size=10M"))
+	if want := uintptr(syscall.MS_RDONLY | syscall.MS_NOSUID); flags != want {
+		t.Errorf("flags %#x, want %#x", flags, want)
+	}
+	if data != "mode=0755,size=10M" {
+		t.Error("data", data)
+	}
+	if flags, _ = Flags(Split("ro,rw")); flags != 0 {
+		t.Errorf("ro,rw: %#x", flags)
+	}
+	if opts := Split(""); opts != nil {
+		t.Error("empty:", opts)
+	}
+}
+
+const testMountinfo = `18 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,data=ordered
+19 18 0:17 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+20 18 0:18 / /run rw,nosuid - tmpfs tmpfs rw,mode=755
+21 20 8:1 /srv /mnt/my\040data rw,relatime shared:1 master:2 - ext4 /dev/sda1 rw
+`
+
+func TestParseMountinfo(t *testing.T) {
+	mounts, err := ParseMountinfo(strings.NewReader(testMountinfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 4 {
+		t.Fatalf("%d mounts", len(mounts))
+	}
+	m := Mounted(mounts, "/mnt/my data")
+	if m == nil {
+		t.Fatal("/mnt/my data: not mounted")
+	}
+	if m.ID != 21 || m.Parent != 20 || m.Major != 8 || m.Minor != 1 ||
+		m.Root != "/srv" || m.Type != "ext4" ||
+		m.Source != "/dev/sda1" ||
+		!reflect.DeepEqual(m.Optional, []string{"shared:1", "master:2"}) {
+		t.Errorf("%+v", *m)
+	}
+	if Mounted(mounts, "/boot") != nil {
+		t.Error("/boot: mounted")
+	}
+	if _, err = ParseMountinfo(strings.NewReader("1 2 3\n")); err == nil {
+		t.Error("invalid line")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fstab
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const MountinfoFile = "/proc/self/mountinfo"
+
+// Mount is a line of /proc/self/mountinfo; see proc(5).
+type Mount struct {
+	ID, Parent   int
+	Major, Minor int
+	Root         string // of the mount within its filesystem
+	Target       string
+	Options      []string // per mount
+	Optional     []string // e.g. shared:1
+	Type         string
+	Source       string
+	SuperOptions []string // per super block
+}
+
+// ReadMountinfo reads the mountinfo file.
+func ReadMountinfo(fn string) ([]Mount, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mounts, err := ParseMountinfo(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return mounts, nil
+}
+
+func ParseMountinfo(r io.Reader) ([]Mount, error) {
+	var mounts []Mount
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, f := range fields {
+			if f == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 6 || len(fields) < sep+4 {
+			return nil, fmt.Errorf("line %d: invalid", line)
+		}
+		var m Mount
+		var err error
+		if m.ID, err = strconv.Atoi(fields[0]); err == nil {
+			m.Parent, err = strconv.Atoi(fields[1])
+		}
+		if err == nil {
+			_, err = fmt.Sscanf(fields[2], "%d:%d", &m.Major,
+				&m.Minor)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		m.Root = Unescape(fields[3])
+		m.Target = Unescape(fields[4])
+		m.Options = Split(fields[5])
+		m.Optional = fields[6:sep]
+		m.Type = fields[sep+1]
+		m.Source = Unescape(fields[sep+2])
+		m.SuperOptions = Split(fields[sep+3])
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// Mounted returns the mount of the target, if any; the last mount of a
+// target hides the others.
+func Mounted(mounts []Mount, target string) *Mount {
+	var found *Mount
+	for i := range mounts {
+		if mounts[i].Target == target {
+			found = &mounts[i]
+		}
+	}
+	return found
+}
@@ -0,0 +1,108 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fstab
+
+import (
+	"strings"
+	"syscall"
+)
+
+var optionFlags = map[string]struct {
+	bits uintptr
+	set  bool
+}{
+	"ro":            {syscall.MS_RDONLY, true},
+	"rw":            {syscall.MS_RDONLY, false},
+	"nosuid":        {syscall.MS_NOSUID, true},
+	"suid":          {syscall.MS_NOSUID, false},
+	"nodev":         {syscall.MS_NODEV, true},
+	"dev":           {syscall.MS_NODEV, false},
+	"noexec":        {syscall.MS_NOEXEC, true},
+	"exec":          {syscall.MS_NOEXEC, false},
+	"sync":          {syscall.MS_SYNCHRONOUS, true},
+	"async":         {syscall.MS_SYNCHRONOUS, false},
+	"remount":       {syscall.MS_REMOUNT, true},
+	"mand":          {syscall.MS_MANDLOCK, true},
+	"nomand":        {syscall.MS_MANDLOCK, false},
+	"dirsync":       {syscall.MS_DIRSYNC, true},
+	"noatime":       {syscall.MS_NOATIME, true},
+	"atime":         {syscall.MS_NOATIME, false},
+	"nodiratime":    {syscall.MS_NODIRATIME, true},
+	"diratime":      {syscall.MS_NODIRATIME, false},
+	"bind":          {syscall.MS_BIND, true},
+	"rbind":         {syscall.MS_BIND | syscall.MS_REC, true},
+	"move":          {syscall.MS_MOVE, true},
+	"silent":        {syscall.MS_SILENT, true},
+	"loud":          {syscall.MS_SILENT, false},
+	"relatime":      {syscall.MS_RELATIME, true},
+	"norelatime":    {syscall.MS_RELATIME, false},
+	"strictatime":   {syscall.MS_STRICTATIME, true},
+	"nostrictatime": {syscall.MS_STRICTATIME, false},
+	"iversion":      {syscall.MS_I_VERSION, true},
+	"noiversion":    {syscall.MS_I_VERSION, false},
+	"private":       {syscall.MS_PRIVATE, true},
+	"rprivate":      {syscall.MS_PRIVATE | syscall.MS_REC, true},
+	"slave":         {syscall.MS_SLAVE, true},
+	"rslave":        {syscall.MS_SLAVE | syscall.MS_REC, true},
+	"shared":        {syscall.MS_SHARED, true},
+	"rshared":       {syscall.MS_SHARED | syscall.MS_REC, true},
+	"unbindable":    {syscall.MS_UNBINDABLE, true},
+	"runbindable":   {syscall.MS_UNBINDABLE | syscall.MS_REC, true},
+}
+
+// userspace options are for mount itself, not the kernel
+var userspace = map[string]bool{
+	"defaults":  true,
+	"auto":      true,
+	"noauto":    true,
+	"nofail":    true,
+	"user":      true,
+	"nouser":    true,
+	"users":     true,
+	"owner":     true,
+	"group":     true,
+	"_netdev":   true,
+	"loop":      true,
+	"offset":    true,
+	"sizelimit": true,
+	"comment":   true,
+}
+
+// Flags returns the mount(2) flags and the comma separated filesystem
+// specific data of the options, which excludes userspace options like
+// noauto, nofail, loop and x-*.
+func Flags(opts []string) (flags uintptr, data string) {
+	var fsopts []string
+	for _, opt := range opts {
+		if f, found := optionFlags[opt]; found {
+			if f.set {
+				flags |= f.bits
+			} else {
+				flags &^= f.bits
+			}
+			continue
+		}
+		name := opt
+		if i := strings.IndexByte(opt, '='); i >= 0 {
+			name = opt[:i]
+		}
+		if userspace[name] || strings.HasPrefix(name, "x-") {
+			continue
+		}
+		fsopts = append(fsopts, opt)
+	}
+	return flags, strings.Join(fsopts, ",")
+}
+
+// Split the comma separated options; "" returns nil.
+func Split(s string) []string {
+	var opts []string
+	for _, opt := range strings.Split(s, ",") {
+		if len(opt) > 0 {
+			opts = append(opts, opt)
+		}
+	}
+	return opts
+}
@@ -0,0 +1,81 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package grubenv
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Boot selection variables; entries are a title, --id or menu index, with
+// submenu entries prefaced by their submenu and '>', e.g. "1>2".
+const (
+	// NextEntry is booted once, as set by grub-reboot.
+	NextEntry = "next_entry"
+	// SavedEntry is the default of default, as set by grub-set-default.
+	SavedEntry = "saved_entry"
+	// BootEntry is the entry of the last boot attempt.
+	BootEntry = "boot_entry"
+	// BootCount is the number of boot attempts since the last success.
+	BootCount = "boot_count"
+	// BootLimit is the number of failed boots before falling back to the
+	// KnownGoodEntry, default DefaultBootLimit.
+	BootLimit = "boot_limit"
+	// KnownGoodEntry is the last entry to boot successfully.
+	KnownGoodEntry = "known_good_entry"
+
+	DefaultBootLimit = 3
+)
+
+func atoi(env map[string]string, name string, dflt int) int {
+	if i, err := strconv.Atoi(env[name]); err == nil {
+		return i
+	}
+	return dflt
+}
+
+// Default returns the entry to boot, given the script's default, and why
+// it isn't that default. Like GRUB, this consumes any NextEntry, so the
+// caller should save the updated environment.
+//
+// The choices, in order, are: NextEntry; the KnownGoodEntry after
+// BootLimit failed boots; the SavedEntry if the script's default is empty
+// or "saved"; then the script's default.
+func Default(env map[string]string, def string) (entry, why string) {
+	if next := env[NextEntry]; len(next) > 0 {
+		delete(env, NextEntry)
+		return next, NextEntry
+	}
+	count := atoi(env, BootCount, 0)
+	limit := atoi(env, BootLimit, DefaultBootLimit)
+	if good := env[KnownGoodEntry]; len(good) > 0 && count >= limit {
+		return good, fmt.Sprintf("%d failed boots", count)
+	}
+	if saved := env[SavedEntry]; len(saved) > 0 &&
+		(len(def) == 0 || def == "saved") {
+		return saved, SavedEntry
+	}
+	return def, ""
+}
+
+// Booting records an attempt to boot the entry.
+func Booting(env map[string]string, entry string) {
+	env[BootCount] = strconv.Itoa(atoi(env, BootCount, 0) + 1)
+	if len(entry) > 0 {
+		env[BootEntry] = entry
+	} else {
+		delete(env, BootEntry)
+	}
+}
+
+// Booted marks the last boot attempt as successful, making its entry the
+// KnownGoodEntry. This also clears the recordfail of Debian scripts.
+func Booted(env map[string]string) {
+	delete(env, BootCount)
+	delete(env, "recordfail")
+	if entry := env[BootEntry]; len(entry) > 0 {
+		env[KnownGoodEntry] = entry
+	}
+}
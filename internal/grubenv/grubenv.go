@@ -0,0 +1,162 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package grubenv reads and writes GRUB environment blocks, as with
+// grub-editenv. A block is a 1KiB file that GRUB rewrites in place,
+//
+//	# GRUB Environment Block
+//	NAME=VALUE
+//	...
+//	######...
+//
+// where backslash and newline in values are escaped with backslash.
+package grubenv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	Header = "# GRUB Environment Block\n"
+	Size   = 1024
+)
+
+var ErrTooBig = errors.New("environment block too big")
+
+// Read the environment block file.
+func Read(fn string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	env, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return env, nil
+}
+
+// Parse the environment block.
+func Parse(b []byte) (map[string]string, error) {
+	if !bytes.HasPrefix(b, []byte(Header)) {
+		return nil, errors.New("invalid environment block")
+	}
+	env := make(map[string]string)
+	s := string(b[len(Header):])
+	for len(s) > 0 {
+		var line string
+		line, s = nextLine(s)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 1 {
+			continue
+		}
+		env[line[:eq]] = unescape(line[eq+1:])
+	}
+	return env, nil
+}
+
+// nextLine returns the line up to the first unescaped newline.
+func nextLine(s string) (string, string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\n':
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+func escape(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return strings.Replace(s, "\n", "\\\n", -1)
+}
+
+// Format the sorted variables as an environment block padded with '#'.
+func Format(env map[string]string) ([]byte, error) {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		if len(name) == 0 || strings.ContainsAny(name, "=\n") {
+			return nil, fmt.Errorf("%q: invalid name", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buf := bytes.NewBufferString(Header)
+	for _, name := range names {
+		fmt.Fprintf(buf, "%s=%s\n", name, escape(env[name]))
+	}
+	if buf.Len() > Size {
+		return nil, ErrTooBig
+	}
+	buf.Write(bytes.Repeat([]byte{'#'}, Size-buf.Len()))
+	return buf.Bytes(), nil
+}
+
+// Write the environment block file in place, as GRUB does, so that it
+// keeps its blocks.
+func Write(fn string, env map[string]string) error {
+	b, err := Format(env)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteAt(b, 0); err == nil {
+		err = f.Truncate(Size)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Update the file's environment with the given variables; those with an
+// empty value are removed. A missing file is created.
+func Update(fn string, vars map[string]string) error {
+	env, err := Read(fn)
+	if os.IsNotExist(err) {
+		env, err = make(map[string]string), nil
+	}
+	if err != nil {
+		return err
+	}
+	for k, v := range vars {
+		if len(v) > 0 {
+			env[k] = v
+		} else {
+			delete(env, k)
+		}
+	}
+	return Write(fn, env)
+}
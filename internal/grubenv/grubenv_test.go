@@ -0,0 +1,106 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package grubenv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	env := map[string]string{
+		"saved_entry": "Debian GNU/Linux",
+		"multi":       "line one\nline\\two",
+	}
+	b, err := Format(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != Size {
+		t.Fatal("size", len(b))
+	}
+	want := Header + "multi=line one\\\nline\\\\two\n" +
+		"saved_entry=Debian GNU/Linux\n"
+	if !strings.HasPrefix(string(b), want) ||
+		strings.Trim(string(b[len(want):]), "#") != "" {
+		t.Errorf("%q", b)
+	}
+	got, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, env) {
+		t.Errorf("got %q, want %q", got, env)
+	}
+
+	if _, err = Format(map[string]string{"x": strings.Repeat("x", Size)}); err != ErrTooBig {
+		t.Error("too big:", err)
+	}
+	if _, err = Parse([]byte("saved_entry=0\n")); err == nil {
+		t.Error("parsed without header")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grubenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "grubenv")
+
+	if err = Update(fn, map[string]string{"a": "1", "b": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = Update(fn, map[string]string{"a": ""}); err != nil {
+		t.Fatal(err)
+	}
+	env, err := Read(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(env, map[string]string{"b": "2"}) {
+		t.Error(env)
+	}
+	if fi, _ := os.Stat(fn); fi.Size() != Size {
+		t.Error("size", fi.Size())
+	}
+}
+
+func TestBootCount(t *testing.T) {
+	env := map[string]string{
+		SavedEntry: "A",
+		NextEntry:  "B",
+	}
+	if entry, why := Default(env, ""); entry != "B" || why != NextEntry {
+		t.Fatal("next entry:", entry, why)
+	}
+	if _, found := env[NextEntry]; found {
+		t.Fatal("next entry wasn't consumed")
+	}
+	Booting(env, "B")
+	Booted(env)
+	if env[KnownGoodEntry] != "B" || len(env[BootCount]) > 0 {
+		t.Fatal("booted:", env)
+	}
+
+	for i := 0; i < DefaultBootLimit; i++ {
+		entry, _ := Default(env, "saved")
+		if entry != "A" {
+			t.Fatalf("boot %d: %s", i, entry)
+		}
+		Booting(env, entry)
+	}
+	if entry, why := Default(env, "saved"); entry != "B" || len(why) == 0 {
+		t.Error("fallback:", entry, why)
+	}
+	if entry, _ := Default(map[string]string{SavedEntry: "A"}, "2"); entry != "2" {
+		t.Error("script default:", entry)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package hwmon reads the sensors of the kernel's hwmon devices.
+package hwmon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Root of the hwmon devices.
+const Root = "/sys/class/hwmon"
+
+// Type of sensor with its units and the divisor of its sysfs values.
+type Type struct {
+	Prefix  string
+	Units   string
+	Divisor float64
+}
+
+// Types of sensors that are read.
+var Types = []Type{
+	{"temp", "C", 1000},
+	{"in", "V", 1000},
+	{"fan", "rpm", 1},
+	{"power", "W", 1000000},
+	{"curr", "A", 1000},
+}
+
+// Attrs of each sensor that are read; "input" is the sensor value.
+var Attrs = []string{"input", "average", "min", "max", "lcrit", "crit"}
+
+var attrRe = regexp.MustCompile(`^([a-z]+)([0-9]+)_([a-z]+)$`)
+
+// Reading of a sensor attribute.
+type Reading struct {
+	// Chip is the device name, suffixed by its bus address if other
+	// devices have the same name, e.g. "lm75-1-0048".
+	Chip string
+	// Sensor is the label, or channel if unlabeled, e.g. "front".
+	Sensor string
+	// Channel of the sysfs attribute, e.g. "temp1".
+	Channel string
+	// Attr of the sysfs attribute, e.g. "input" or "max".
+	Attr  string
+	Units string
+	Value float64
+}
+
+// Name of the reading's sensor as "CHIP.SENSOR".
+func (r *Reading) Name() string { return r.Chip + "." + r.Sensor }
+
+// Field of the reading with the given sensor name, e.g.
+//
+//	hwmon.w83795g.front.units.C
+//	hwmon.w83795g.front.max.units.C
+func (r *Reading) Field(name string) string {
+	if r.Attr == "input" {
+		return name + ".units." + r.Units
+	}
+	return name + "." + r.Attr + ".units." + r.Units
+}
+
+type device struct {
+	dir, name, id string
+}
+
+// Read the sensors of the hwmon devices in the root directory. Attributes
+// that fail to read, e.g. of absent fans, are skipped.
+func Read(root string) ([]Reading, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var devs []device
+	count := make(map[string]int)
+	for _, e := range entries {
+		dir := filepath.Join(root, e.Name())
+		name := readString(filepath.Join(dir, "name"))
+		if len(name) == 0 {
+			// older drivers have their attributes in the device
+			dir = filepath.Join(dir, "device")
+			name = readString(filepath.Join(dir, "name"))
+		}
+		if len(name) == 0 {
+			continue
+		}
+		id := e.Name()
+		link, err := os.Readlink(filepath.Join(root, e.Name(), "device"))
+		if err == nil {
+			id = filepath.Base(link)
+		}
+		devs = append(devs, device{dir, name, id})
+		count[name]++
+	}
+	var readings []Reading
+	for _, dev := range devs {
+		chip := dev.name
+		if count[dev.name] > 1 {
+			chip += "-" + dev.id
+		}
+		readings = append(readings, readDevice(dev.dir, chip)...)
+	}
+	return readings, nil
+}
+
+func readDevice(dir, chip string) []Reading {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var readings []Reading
+	for _, fi := range files {
+		m := attrRe.FindStringSubmatch(fi.Name())
+		if m == nil || !isAttr(m[3]) {
+			continue
+		}
+		t := typeOf(m[1])
+		if t == nil {
+			continue
+		}
+		s := readString(filepath.Join(dir, fi.Name()))
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			continue
+		}
+		channel := m[1] + m[2]
+		sensor := label(readString(filepath.Join(dir, channel+"_label")))
+		if len(sensor) == 0 {
+			sensor = channel
+		}
+		readings = append(readings, Reading{
+			Chip:    chip,
+			Sensor:  sensor,
+			Channel: channel,
+			Attr:    m[3],
+			Units:   t.Units,
+			Value:   float64(i) / t.Divisor,
+		})
+	}
+	sort.SliceStable(readings, func(i, j int) bool {
+		return readings[i].Channel < readings[j].Channel
+	})
+	return readings
+}
+
+func isAttr(s string) bool {
+	for _, attr := range Attrs {
+		if s == attr {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(prefix string) *Type {
+	for i := range Types {
+		if Types[i].Prefix == prefix {
+			return &Types[i]
+		}
+	}
+	return nil
+}
+
+func readString(fn string) string {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+var labelRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// label as a field name, e.g. "CPU Core 0" is "cpu_core_0".
+func label(s string) string {
+	s = labelRe.ReplaceAllString(strings.ToLower(s), "_")
+	return strings.Trim(s, "_")
+}
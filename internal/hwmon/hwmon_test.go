@@ -0,0 +1,137 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package hwmon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// testTree of two lm75 and a labeled w83795g with an unreadable fan.
+var testTree = map[string]string{
+	"hwmon0/name":                "lm75",
+	"hwmon0/temp1_input":         "41500",
+	"hwmon0/temp1_max":           "80000",
+	"hwmon1/name":                "lm75",
+	"hwmon1/temp1_input":         "39000",
+	"hwmon2/device/name":         "w83795g",
+	"hwmon2/device/temp1_input":  "45250",
+	"hwmon2/device/temp1_label":  "Front Temp",
+	"hwmon2/device/temp1_crit":   "95000",
+	"hwmon2/device/in3_input":    "1650",
+	"hwmon2/device/fan1_input":   "7200",
+	"hwmon2/device/fan2_input":   "",
+	"hwmon2/device/power1_input": "1500000",
+	"hwmon2/device/curr1_input":  "250",
+	"hwmon2/device/pwm1":         "128",
+	"hwmon2/device/temp1_type":   "3",
+}
+
+func writeTree(t *testing.T) string {
+	root, err := ioutil.TempDir("", "hwmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for fn, s := range testTree {
+		fn = filepath.Join(root, fn)
+		if err = os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(fn, []byte(s+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, id := range []string{"1-0048", "1-0049"} {
+		dev := filepath.Join(root, "devices", id)
+		if err = os.MkdirAll(dev, 0755); err != nil {
+			t.Fatal(err)
+		}
+		link := filepath.Join(root, fmt.Sprint("hwmon", i), "device")
+		if err = os.Symlink(dev, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func sorted(fields map[string]string) []string {
+	var s []string
+	for k, v := range fields {
+		s = append(s, k+": "+v)
+	}
+	sort.Strings(s)
+	return s
+}
+
+func TestRead(t *testing.T) {
+	root := writeTree(t)
+	defer os.RemoveAll(root)
+	readings, err := Read(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := sorted((*Map)(nil).Fields(readings))
+	want := []string{
+		"hwmon.lm75-1-0048.temp1.max.units.C: 80",
+		"hwmon.lm75-1-0048.temp1.units.C: 41.5",
+		"hwmon.lm75-1-0049.temp1.units.C: 39",
+		"hwmon.w83795g.curr1.units.A: 0.25",
+		"hwmon.w83795g.fan1.units.rpm: 7200",
+		"hwmon.w83795g.front_temp.crit.units.C: 95",
+		"hwmon.w83795g.front_temp.units.C: 45.25",
+		"hwmon.w83795g.in3.units.V: 1.65",
+		"hwmon.w83795g.power1.units.W: 1.5",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestMap(t *testing.T) {
+	root := writeTree(t)
+	defer os.RemoveAll(root)
+	readings, err := Read(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Map{
+		Rename: map[string]string{
+			"w83795g":            "hwmon",
+			"w83795g.front_temp": "hwmon.front.temp",
+			"lm75-1-0048.temp1":  "bmc.temperature",
+		},
+		Scale:  map[string]float64{"w83795g.in3": 2},
+		Ignore: []string{"lm75-1-0049", "w83795g.curr1"},
+	}
+	if err = m.Check(); err != nil {
+		t.Fatal(err)
+	}
+	got := sorted(m.Fields(readings))
+	want := []string{
+		"bmc.temperature.max.units.C: 80",
+		"bmc.temperature.units.C: 41.5",
+		"hwmon.fan1.units.rpm: 7200",
+		"hwmon.front.temp.crit.units.C: 95",
+		"hwmon.front.temp.units.C: 45.25",
+		"hwmon.in3.units.V: 3.3",
+		"hwmon.power1.units.W: 1.5",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+	for _, bad := range []*Map{
+		{Rename: map[string]string{"a": ""}},
+		{Rename: map[string]string{"a": "x", "b": "x"}},
+		{Scale: map[string]float64{"a": 0}},
+	} {
+		if bad.Check() == nil {
+			t.Error("checked:", bad)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package hwmon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Map of the machine's sensors to their redis fields. Without a map, the
+// field of each sensor is hwmon.CHIP.SENSOR.
+//
+//	{
+//		"Rename": {
+//			"w83795g": "hwmon",
+//			"lm75-1-0048.temp1": "bmc.temperature"
+//		},
+//		"Scale": { "nct7802.in3": 2 },
+//		"Ignore": [ "imx_thermal_zone" ]
+//	}
+type Map struct {
+	// Rename the "CHIP.SENSOR" or the "CHIP" prefix of its sensors.
+	Rename map[string]string `json:",omitempty"`
+	// Scale the values of the "CHIP.SENSOR", e.g. of a voltage divider.
+	Scale map[string]float64 `json:",omitempty"`
+	// Ignore the "CHIP.SENSOR" or all sensors of the "CHIP".
+	Ignore []string `json:",omitempty"`
+}
+
+// LoadMap from the JSON file.
+func LoadMap(fn string) (*Map, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	m := new(Map)
+	if err = json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	if err = m.Check(); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return m, nil
+}
+
+// Check for empty or duplicate names and zero scales.
+func (m *Map) Check() error {
+	names := make(map[string]string)
+	for k, v := range m.Rename {
+		switch {
+		case len(v) == 0:
+			return fmt.Errorf("%s: empty rename", k)
+		case len(names[v]) > 0:
+			return fmt.Errorf("%s: also rename of %s", v, names[v])
+		}
+		names[v] = k
+	}
+	for k, v := range m.Scale {
+		if v == 0 {
+			return fmt.Errorf("%s: zero scale", k)
+		}
+	}
+	return nil
+}
+
+// Fields of the readings as renamed and scaled by the map, which may be nil.
+func (m *Map) Fields(readings []Reading) map[string]string {
+	fields := make(map[string]string)
+	for i := range readings {
+		r := &readings[i]
+		if m.ignore(r) {
+			continue
+		}
+		name := "hwmon." + r.Name()
+		v := r.Value
+		if m != nil {
+			if s, found := m.Rename[r.Name()]; found {
+				name = s
+			} else if s, found := m.Rename[r.Chip]; found {
+				name = s + "." + r.Sensor
+			}
+			if scale, found := m.Scale[r.Name()]; found {
+				v *= scale
+			}
+		}
+		fields[r.Field(name)] = format(v)
+	}
+	return fields
+}
+
+func (m *Map) ignore(r *Reading) bool {
+	if m == nil {
+		return false
+	}
+	for _, s := range m.Ignore {
+		if s == r.Chip || s == r.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// format the value with at most 3 decimals, e.g. 45.5 or 1200.
+func format(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 3, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
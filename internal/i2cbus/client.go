@@ -0,0 +1,146 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package i2cbus
+
+import (
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/i2c"
+)
+
+// Addr of the i2cd RPC server.
+const Addr = "127.0.0.1:1233"
+
+// Op is a step of a transaction. If the Mux is named, i2cd writes the Select
+// value to it before the op.
+type Op struct {
+	RW     i2c.RW
+	Cmd    uint8
+	Size   i2c.SMBusSize
+	Data   i2c.SMBusData
+	Bus    int
+	Addr   int
+	Mux    string
+	Select byte
+	// Delay after the op
+	Delay time.Duration
+	// Timeout of the op, or i2cd's default if zero
+	Timeout time.Duration
+}
+
+// Tx is a transaction of ops that i2cd does in order while holding all of
+// their buses, including those of their muxes. With Deselect, i2cd writes
+// zero to each of these muxes after the last op.
+type Tx struct {
+	Ops      []Op
+	Deselect bool
+}
+
+// TxReply has the data of each op of the transaction.
+type TxReply struct {
+	Data []i2c.SMBusData
+}
+
+var client struct {
+	sync.Mutex
+	*rpc.Client
+}
+
+func call(method string, args, reply interface{}) error {
+	client.Lock()
+	if client.Client == nil {
+		c, err := rpc.DialHTTP("tcp", Addr)
+		if err != nil {
+			client.Unlock()
+			return err
+		}
+		client.Client = c
+	}
+	c := client.Client
+	client.Unlock()
+	err := c.Call(method, args, reply)
+	if err == rpc.ErrShutdown {
+		client.Lock()
+		if client.Client == c {
+			client.Client = nil
+		}
+		client.Unlock()
+		c.Close()
+	}
+	return err
+}
+
+// Do the transaction with i2cd, returning the data of each op.
+func Do(tx *Tx) ([]i2c.SMBusData, error) {
+	var reply TxReply
+	err := call("I2cReq.Do", tx, &reply)
+	return reply.Data, err
+}
+
+// Pause i2cd clients after the transactions in flight; daemons poll Paused
+// and skip their updates until Resume.
+func Pause() error {
+	var reply int
+	return call("I2cReq.Pause", 0, &reply)
+}
+
+func Resume() error {
+	var reply int
+	return call("I2cReq.Resume", 0, &reply)
+}
+
+func Paused() (bool, error) {
+	var paused bool
+	err := call("I2cReq.Paused", 0, &paused)
+	return paused, err
+}
+
+// Local, if not nil, does the batches of the daemons' ReadWrite instead of
+// i2cd, e.g. a Sim of their tests.
+var Local Backend
+
+// ReadWrite the batch with i2cd, or the Local backend.
+func ReadWrite(g *[MAXOPS]I, f *[MAXOPS]R) error {
+	if Local != nil {
+		_, err := Run(Local, g, f)
+		return err
+	}
+	return call("I2cReq.ReadWrite", g, f)
+}
+
+// Stopped returns true if i2cd is paused, or unreachable, unless there's a
+// Local backend instead.
+func Stopped() bool {
+	if Local != nil {
+		return false
+	}
+	paused, err := Paused()
+	return err != nil || paused
+}
+
+// Remote is the Backend of i2cd, doing each op as a transaction.
+type Remote struct{}
+
+func (Remote) Do(bus, addr int, rw i2c.RW, cmd uint8, size i2c.SMBusSize,
+	data *i2c.SMBusData) error {
+	reply, err := Do(&Tx{
+		Ops: []Op{
+			{
+				RW:   rw,
+				Cmd:  cmd,
+				Size: size,
+				Data: *data,
+				Bus:  bus,
+				Addr: addr,
+			},
+		},
+	})
+	if err == nil && len(reply) > 0 {
+		*data = reply[0]
+	}
+	return err
+}
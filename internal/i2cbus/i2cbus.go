@@ -0,0 +1,87 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package i2cbus has the request batches of i2cd and its clients along with
+// the Backend that does them: Dev for /dev/i2c-N or a Sim for testing off
+// target.
+package i2cbus
+
+import (
+	"time"
+
+	"github.com/platinasystems/i2c"
+)
+
+const MAXOPS = 30
+
+// I is a request of a batch.
+type I struct {
+	InUse     bool
+	RW        i2c.RW
+	RegOffset uint8
+	BusSize   i2c.SMBusSize
+	Data      [34]byte
+	Bus       int
+	Addr      int
+	Delay     int
+}
+
+// R is the reply of the respective request.
+type R struct {
+	D [34]byte
+	E error
+}
+
+type Backend interface {
+	// Do an SMBus transaction with the bus's slave address.
+	Do(bus, addr int, rw i2c.RW, cmd uint8, size i2c.SMBusSize,
+		data *i2c.SMBusData) error
+}
+
+// Default is the Backend of i2cd.
+var Default Backend = Dev{}
+
+// Dev is the Backend of the /dev/i2c-N character devices.
+type Dev struct{}
+
+func (Dev) Do(bus, addr int, rw i2c.RW, cmd uint8, size i2c.SMBusSize,
+	data *i2c.SMBusData) error {
+	var b i2c.Bus
+	if err := b.Open(bus); err != nil {
+		return err
+	}
+	defer b.Close()
+	if err := b.ForceSlaveAddress(addr); err != nil {
+		return err
+	}
+	return b.Do(rw, cmd, size, data)
+}
+
+// Run the in-use requests of the batch in order, copying each result to
+// the respective reply then sleeping for its Delay in milliseconds. Run
+// stops at the first failure, returning its index and error.
+func Run(b Backend, g *[MAXOPS]I, f *[MAXOPS]R) (int, error) {
+	for x := range g {
+		if !g[x].InUse {
+			continue
+		}
+		var data i2c.SMBusData
+		copy(data[:], g[x].Data[:])
+		err := b.Do(g[x].Bus, g[x].Addr, g[x].RW, g[x].RegOffset,
+			g[x].BusSize, &data)
+		if err != nil {
+			return x, err
+		}
+		f[x].D[0] = data[0]
+		f[x].D[1] = data[1]
+		switch g[x].BusSize {
+		case i2c.BlockData, i2c.I2CBlockData:
+			copy(f[x].D[2:], data[2:])
+		}
+		if g[x].Delay > 0 {
+			time.Sleep(time.Duration(g[x].Delay) * time.Millisecond)
+		}
+	}
+	return -1, nil
+}
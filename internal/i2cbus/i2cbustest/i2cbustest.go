@@ -0,0 +1,25 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package i2cbustest has the fixture of the i2c daemon tests.
+package i2cbustest
+
+import (
+	"testing"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+)
+
+// Simulate the devices of the sim script, e.g. testdata/psu.sim, as the
+// i2cbus.Local backend until the end of the test.
+func Simulate(tb testing.TB, fn string) *i2cbus.Sim {
+	tb.Helper()
+	sim, err := i2cbus.LoadSim(fn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	i2cbus.Local = sim
+	tb.Cleanup(func() { i2cbus.Local = nil })
+	return sim
+}
@@ -0,0 +1,523 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package i2cbus
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/platinasystems/i2c"
+)
+
+// Fault kinds injected by a Sim.
+type Fault int
+
+const (
+	NoFault Fault = iota
+	// Nak fails the device's transactions with ENXIO.
+	Nak
+	// Stuck fails every transaction of the device's bus with ETIMEDOUT.
+	Stuck
+	// Garbage reads all ones from the device; only reads count down.
+	Garbage
+)
+
+var faultNames = map[string]Fault{
+	"none":    NoFault,
+	"nak":     Nak,
+	"stuck":   Stuck,
+	"garbage": Garbage,
+}
+
+// Regs are the registers of a device page. Transactions of WordData and
+// BlockData sizes use the respective Words and Blocks if present; otherwise,
+// they use the sequential Bytes like an EEPROM.
+type Regs struct {
+	Bytes  [256]byte
+	Words  map[uint8]uint16
+	Blocks map[uint8][]byte
+}
+
+// Device is a simulated slave. If the PageReg is valid, writes to it select
+// the page of the following transactions by the PageMask of the value.
+type Device struct {
+	Name     string
+	PageReg  int
+	PageMask byte
+
+	mutex  sync.Mutex
+	pages  map[byte]*Regs
+	shared map[uint8]bool
+	sel    byte
+	ptr    byte
+	fault  Fault
+	nfault int
+}
+
+// Sim is a Backend of simulated devices, which may be loaded from a script.
+//
+//	# comment
+//	device BUS ADDR [NAME]
+//	page REG [MASK]
+//	shared REG...
+//	bank N
+//	byte REG VALUE...
+//	word REG VALUE...
+//	block REG VALUE...|"STRING"
+//	string REG "STRING"
+//	hex REG HEX
+//	fault nak|stuck|garbage|none [COUNT]
+//
+// Each line after "device" applies to that device. "page" declares its
+// page select register; "shared" lists registers of page 0 regardless of
+// the selection; and "bank" selects the page of the following registers.
+// "byte", "string" and "hex" set sequential bytes; "word" sets consecutive
+// words; and "block" sets an SMBus block. Without a COUNT, a fault persists.
+type Sim struct {
+	mutex sync.Mutex
+	devs  map[[2]int]*Device
+}
+
+func NewSim() *Sim {
+	return &Sim{devs: make(map[[2]int]*Device)}
+}
+
+// LoadSim returns a new Sim with the script file's devices.
+func LoadSim(fn string) (*Sim, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := NewSim()
+	if err = s.Load(f); err != nil {
+		return nil, fmt.Errorf("%s:%v", fn, err)
+	}
+	return s, nil
+}
+
+// Device returns the simulated slave, adding it if necessary.
+func (s *Sim) Device(bus, addr int) *Device {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	k := [2]int{bus, addr}
+	d, found := s.devs[k]
+	if !found {
+		d = &Device{
+			PageReg: -1,
+			pages:   make(map[byte]*Regs),
+		}
+		s.devs[k] = d
+	}
+	return d
+}
+
+func (s *Sim) lookup(bus, addr int) (*Device, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	d, found := s.devs[[2]int{bus, addr}]
+	return d, found
+}
+
+// stuck returns true if a device of the bus has a Stuck fault.
+func (s *Sim) stuck(bus int) bool {
+	s.mutex.Lock()
+	var devs []*Device
+	for k, d := range s.devs {
+		if k[0] == bus {
+			devs = append(devs, d)
+		}
+	}
+	s.mutex.Unlock()
+	for _, d := range devs {
+		if d.take(Stuck) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sim) Do(bus, addr int, rw i2c.RW, cmd uint8, size i2c.SMBusSize,
+	data *i2c.SMBusData) error {
+	if s.stuck(bus) {
+		return syscall.ETIMEDOUT
+	}
+	d, found := s.lookup(bus, addr)
+	if !found || d.take(Nak) {
+		return syscall.ENXIO
+	}
+	return d.do(rw, cmd, size, data)
+}
+
+// Load the devices of the script.
+func (s *Sim) Load(r io.Reader) error {
+	var d *Device
+	var page byte
+	scan := bufio.NewScanner(r)
+	for line := 1; scan.Scan(); line++ {
+		fields, err := splitFields(scan.Text())
+		if err != nil {
+			return fmt.Errorf("%d: %v", line, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] != "device" && d == nil {
+			return fmt.Errorf("%d: %s: no device", line, fields[0])
+		}
+		err = fmt.Errorf("%d: %v: unexpected", line, fields)
+		switch fields[0] {
+		case "device":
+			if len(fields) < 3 || len(fields) > 4 {
+				return err
+			}
+			bus, berr := strconv.ParseUint(fields[1], 0, 16)
+			addr, aerr := strconv.ParseUint(fields[2], 0, 7)
+			if berr != nil || aerr != nil {
+				return err
+			}
+			d = s.Device(int(bus), int(addr))
+			if len(fields) == 4 {
+				d.Name = fields[3]
+			}
+			page = 0
+		case "page":
+			v, perr := parseBytes(fields[1:])
+			if perr != nil || len(v) < 1 || len(v) > 2 {
+				return err
+			}
+			d.PageReg = int(v[0])
+			d.PageMask = 0xff
+			if len(v) == 2 {
+				d.PageMask = v[1]
+			}
+		case "shared":
+			v, serr := parseBytes(fields[1:])
+			if serr != nil || len(v) == 0 {
+				return err
+			}
+			d.Share(v...)
+		case "bank":
+			v, perr := parseBytes(fields[1:])
+			if perr != nil || len(v) != 1 {
+				return err
+			}
+			page = v[0]
+		case "byte", "string", "hex":
+			if len(fields) < 3 {
+				return err
+			}
+			reg, rerr := strconv.ParseUint(fields[1], 0, 8)
+			var v []byte
+			var verr error
+			switch fields[0] {
+			case "byte":
+				v, verr = parseBytes(fields[2:])
+			case "string":
+				v = []byte(strings.Join(fields[2:], " "))
+			case "hex":
+				v, verr = hex.DecodeString(strings.Join(fields[2:], ""))
+			}
+			if rerr != nil || verr != nil || int(reg)+len(v) > 256 {
+				return err
+			}
+			d.SetBytes(page, uint8(reg), v...)
+		case "word":
+			if len(fields) < 3 {
+				return err
+			}
+			reg, rerr := strconv.ParseUint(fields[1], 0, 8)
+			if rerr != nil {
+				return err
+			}
+			for i, f := range fields[2:] {
+				w, werr := strconv.ParseUint(f, 0, 16)
+				if werr != nil {
+					return err
+				}
+				d.SetWord(page, uint8(int(reg)+i), uint16(w))
+			}
+		case "block":
+			if len(fields) < 3 {
+				return err
+			}
+			reg, rerr := strconv.ParseUint(fields[1], 0, 8)
+			v, verr := parseBytes(fields[2:])
+			if verr != nil {
+				v = []byte(strings.Join(fields[2:], " "))
+			}
+			if rerr != nil || len(v) > 32 {
+				return err
+			}
+			d.SetBlock(page, uint8(reg), v)
+		case "fault":
+			if len(fields) < 2 || len(fields) > 3 {
+				return err
+			}
+			kind, found := faultNames[fields[1]]
+			n := uint64(0)
+			var nerr error
+			if len(fields) == 3 {
+				n, nerr = strconv.ParseUint(fields[2], 0, 16)
+			}
+			if !found || nerr != nil {
+				return err
+			}
+			d.Inject(kind, int(n))
+		default:
+			return err
+		}
+	}
+	return scan.Err()
+}
+
+// Share the page 0 registers with all pages.
+func (d *Device) Share(regs ...uint8) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.shared == nil {
+		d.shared = make(map[uint8]bool)
+	}
+	for _, reg := range regs {
+		d.shared[reg] = true
+	}
+}
+
+// Regs returns the registers of the device page, adding it if necessary.
+func (d *Device) Regs(page byte) *Regs {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.regs(page)
+}
+
+func (d *Device) regs(page byte) *Regs {
+	r, found := d.pages[page]
+	if !found {
+		r = &Regs{
+			Words:  make(map[uint8]uint16),
+			Blocks: make(map[uint8][]byte),
+		}
+		d.pages[page] = r
+	}
+	return r
+}
+
+// SetBytes of the page starting with the given register; a value of the
+// PageReg selects the page instead.
+func (d *Device) SetBytes(page, reg uint8, v ...byte) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	copy(d.regs(page).Bytes[reg:], v)
+	if i := d.PageReg - int(reg); i >= 0 && i < len(v) {
+		d.sel = v[i]
+	}
+}
+
+func (d *Device) SetWord(page, reg uint8, w uint16) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.regs(page).Words[reg] = w
+}
+
+func (d *Device) SetBlock(page, reg uint8, b []byte) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.regs(page).Blocks[reg] = append([]byte{}, b...)
+}
+
+// Byte returns the value of the page register.
+func (d *Device) Byte(page, reg uint8) byte {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.regs(page).Bytes[reg]
+}
+
+// Word returns the value of the page register, which may have been set
+// by either SetWord or SetBytes in little-endian order.
+func (d *Device) Word(page, reg uint8) uint16 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	r := d.regs(page)
+	if w, found := r.Words[reg]; found {
+		return w
+	}
+	return uint16(r.Bytes[reg]) | uint16(r.Bytes[reg+1])<<8
+}
+
+// Inject a fault for the next n transactions, or until cleared if n is 0.
+func (d *Device) Inject(kind Fault, n int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.fault = kind
+	d.nfault = n
+}
+
+// take returns true if the device has the given fault, counting it down.
+func (d *Device) take(kind Fault) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.fault != kind || kind == NoFault {
+		return false
+	}
+	if d.nfault > 0 {
+		d.nfault--
+		if d.nfault == 0 {
+			d.fault = NoFault
+		}
+	}
+	return true
+}
+
+func (d *Device) do(rw i2c.RW, cmd uint8, size i2c.SMBusSize,
+	data *i2c.SMBusData) error {
+	garbage := rw == i2c.Read && d.take(Garbage)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if rw == i2c.Write && d.PageReg == int(cmd) &&
+		(size == i2c.ByteData || size == i2c.WordData) {
+		d.sel = data[0]
+		return nil
+	}
+	if rw == i2c.Read && d.PageReg == int(cmd) && size == i2c.ByteData {
+		data[0] = d.sel
+		return nil
+	}
+	r := d.regs(d.sel & d.PageMask)
+	if d.PageReg < 0 || d.shared[cmd] {
+		r = d.regs(0)
+	}
+	switch size {
+	case i2c.Quick:
+	case i2c.Byte:
+		// the written byte is the pointer of sequential reads
+		if rw == i2c.Write {
+			d.ptr = cmd
+		} else {
+			data[0] = r.Bytes[d.ptr]
+			d.ptr++
+		}
+	case i2c.ByteData:
+		if rw == i2c.Write {
+			r.Bytes[cmd] = data[0]
+		} else {
+			data[0] = r.Bytes[cmd]
+		}
+	case i2c.WordData, i2c.ProcCall:
+		if rw == i2c.Write && size == i2c.WordData {
+			if _, found := r.Words[cmd]; found {
+				r.Words[cmd] = uint16(data[0]) | uint16(data[1])<<8
+			} else {
+				r.Bytes[cmd] = data[0]
+				r.Bytes[cmd+1] = data[1]
+			}
+			break
+		}
+		w, found := r.Words[cmd]
+		if !found {
+			w = uint16(r.Bytes[cmd]) | uint16(r.Bytes[cmd+1])<<8
+		}
+		data[0] = byte(w)
+		data[1] = byte(w >> 8)
+	case i2c.BlockData:
+		if rw == i2c.Write {
+			n := int(data[0])
+			if n > 32 {
+				return syscall.EINVAL
+			}
+			r.Blocks[cmd] = append([]byte{}, data[1:1+n]...)
+			break
+		}
+		b := r.Blocks[cmd]
+		data[0] = byte(len(b))
+		copy(data[1:], b)
+	case i2c.I2CBlockData:
+		n := int(data[0])
+		if n > 32 {
+			n = 32
+		}
+		if rw == i2c.Write {
+			for i := 0; i < n && int(cmd)+i < len(r.Bytes); i++ {
+				r.Bytes[int(cmd)+i] = data[1+i]
+			}
+			break
+		}
+		// an SMBus block reads as its count followed by its bytes
+		var b []byte
+		if blk, found := r.Blocks[cmd]; found {
+			b = append([]byte{byte(len(blk))}, blk...)
+		} else {
+			b = r.Bytes[cmd:]
+		}
+		for i := 0; i < n; i++ {
+			data[1+i] = 0
+			if i < len(b) {
+				data[1+i] = b[i]
+			}
+		}
+		data[0] = byte(n)
+	default:
+		return syscall.EOPNOTSUPP
+	}
+	if garbage {
+		for i := range data {
+			data[i] = 0xff
+		}
+	}
+	return nil
+}
+
+// splitFields of the line, without comments, keeping quoted strings.
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if len(line) == 0 || line[0] == '#' {
+			return fields, nil
+		}
+		if line[0] == '"' {
+			i := 1
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(line) {
+				return nil, fmt.Errorf("%s: unterminated string", line)
+			}
+			s, err := strconv.Unquote(line[:i+1])
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, s)
+			line = line[i+1:]
+			continue
+		}
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			i = len(line)
+		}
+		fields = append(fields, line[:i])
+		line = line[i:]
+	}
+}
+
+func parseBytes(fields []string) ([]byte, error) {
+	v := make([]byte, 0, len(fields))
+	for _, f := range fields {
+		u, err := strconv.ParseUint(f, 0, 8)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, byte(u))
+	}
+	return v, nil
+}
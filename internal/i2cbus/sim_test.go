@@ -0,0 +1,198 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package i2cbus
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/platinasystems/i2c"
+)
+
+const script = `
+# mux
+device 0 0x76 pca9548
+
+# paged sensor
+device 0 0x2f w83795
+page 0 0x07
+shared 0x3c
+byte 0 0x80
+byte 0x3c 0x80
+bank 2
+byte 0x10 0x42 0x43
+
+# pmbus
+device 1 0x58 psu
+word 0x79 0x1234
+block 0x99 "FSP"
+
+# eeprom
+device 1 0x50
+string 0x94 "Platina"
+hex 0x00 0d 00 02
+`
+
+func load(t *testing.T, s string) *Sim {
+	sim := NewSim()
+	if err := sim.Load(strings.NewReader(s)); err != nil {
+		t.Fatal(err)
+	}
+	return sim
+}
+
+func do(t *testing.T, b Backend, bus, addr int, rw i2c.RW, cmd uint8,
+	size i2c.SMBusSize, data ...byte) i2c.SMBusData {
+	var d i2c.SMBusData
+	copy(d[:], data)
+	if err := b.Do(bus, addr, rw, cmd, size, &d); err != nil {
+		t.Fatalf("%d.%#x[%#x]: %v", bus, addr, cmd, err)
+	}
+	return d
+}
+
+func TestSim(t *testing.T) {
+	sim := load(t, script)
+
+	d := do(t, sim, 0, 0x2f, i2c.Read, 0, i2c.ByteData)
+	if d[0] != 0x80 {
+		t.Error("bank select:", d[0])
+	}
+	do(t, sim, 0, 0x2f, i2c.Write, 0, i2c.ByteData, 0x82)
+	d = do(t, sim, 0, 0x2f, i2c.Read, 0x11, i2c.ByteData)
+	if d[0] != 0x43 {
+		t.Error("bank 2:", d[0])
+	}
+	d = do(t, sim, 0, 0x2f, i2c.Read, 0x3c, i2c.ByteData)
+	if d[0] != 0x80 {
+		t.Error("shared:", d[0])
+	}
+	do(t, sim, 0, 0x2f, i2c.Write, 0, i2c.ByteData, 0x80)
+	d = do(t, sim, 0, 0x2f, i2c.Read, 0x11, i2c.ByteData)
+	if d[0] != 0 {
+		t.Error("bank 0:", d[0])
+	}
+
+	d = do(t, sim, 1, 0x58, i2c.Read, 0x79, i2c.WordData)
+	if d[0] != 0x34 || d[1] != 0x12 {
+		t.Error("word:", d[:2])
+	}
+	do(t, sim, 1, 0x58, i2c.Write, 0x79, i2c.WordData, 0x78, 0x56)
+	if w := sim.Device(1, 0x58).Word(0, 0x79); w != 0x5678 {
+		t.Errorf("written word: %#x", w)
+	}
+	d = do(t, sim, 1, 0x58, i2c.Read, 0x99, i2c.BlockData)
+	if string(d[1:1+d[0]]) != "FSP" {
+		t.Errorf("block: %q", d[:4])
+	}
+	d = do(t, sim, 1, 0x58, i2c.Read, 0x99, i2c.I2CBlockData, 15)
+	if d[0] != 15 || d[1] != 3 || string(d[2:5]) != "FSP" {
+		t.Errorf("i2c block: %q", d[:6])
+	}
+
+	d = do(t, sim, 1, 0x50, i2c.Read, 0x94, i2c.I2CBlockData, 7)
+	if string(d[1:8]) != "Platina" {
+		t.Errorf("eeprom: %q", d[1:8])
+	}
+	d = do(t, sim, 1, 0x50, i2c.Read, 0, i2c.WordData)
+	if d[0] != 0x0d || d[1] != 0 {
+		t.Error("eeprom word:", d[:2])
+	}
+}
+
+func TestFaults(t *testing.T) {
+	sim := load(t, script+"fault nak 2\n")
+	var d i2c.SMBusData
+
+	if err := sim.Do(2, 0x50, i2c.Read, 0, i2c.ByteData, &d); err != syscall.ENXIO {
+		t.Error("absent device:", err)
+	}
+	for i := 0; i < 2; i++ {
+		err := sim.Do(1, 0x50, i2c.Read, 0, i2c.ByteData, &d)
+		if err != syscall.ENXIO {
+			t.Error("nak:", i, err)
+		}
+	}
+	do(t, sim, 1, 0x50, i2c.Read, 0, i2c.ByteData)
+
+	sim.Device(1, 0x58).Inject(Stuck, 0)
+	for _, addr := range []int{0x50, 0x58} {
+		err := sim.Do(1, addr, i2c.Read, 0, i2c.ByteData, &d)
+		if err != syscall.ETIMEDOUT {
+			t.Errorf("stuck %#x: %v", addr, err)
+		}
+	}
+	do(t, sim, 0, 0x2f, i2c.Read, 0, i2c.ByteData)
+	sim.Device(1, 0x58).Inject(NoFault, 0)
+
+	sim.Device(1, 0x58).Inject(Garbage, 1)
+	d = do(t, sim, 1, 0x58, i2c.Read, 0x79, i2c.WordData)
+	if d[0] != 0xff || d[1] != 0xff {
+		t.Error("garbage:", d[:2])
+	}
+	d = do(t, sim, 1, 0x58, i2c.Read, 0x79, i2c.WordData)
+	if d[0] != 0x34 {
+		t.Error("after garbage:", d[:2])
+	}
+}
+
+func TestRun(t *testing.T) {
+	var g [MAXOPS]I
+	var f [MAXOPS]R
+	sim := load(t, script)
+
+	g[0] = I{true, i2c.Write, 0, i2c.ByteData, [34]byte{0x82}, 0, 0x2f, 0}
+	g[1] = I{true, i2c.Read, 0x10, i2c.ByteData, [34]byte{0}, 0, 0x2f, 0}
+	g[2] = I{true, i2c.Read, 0x99, i2c.I2CBlockData, [34]byte{15}, 1, 0x58, 0}
+	if x, err := Run(sim, &g, &f); err != nil {
+		t.Fatal(x, err)
+	}
+	if f[1].D[0] != 0x42 || string(f[2].D[2:5]) != "FSP" {
+		t.Error("replies:", f[1].D[0], f[2].D[:5])
+	}
+
+	g[1].Addr = 0x2e
+	if x, err := Run(sim, &g, &f); x != 1 || err != syscall.ENXIO {
+		t.Error("failed request:", x, err)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	var g [MAXOPS]I
+	var f [MAXOPS]R
+	Local = load(t, script)
+	defer func() { Local = nil }()
+
+	if Stopped() {
+		t.Error("stopped with backend")
+	}
+	g[0] = I{true, i2c.Read, 0x79, i2c.WordData, [34]byte{0}, 1, 0x58, 0}
+	if err := ReadWrite(&g, &f); err != nil {
+		t.Fatal(err)
+	}
+	if f[0].D[0] != 0x34 || f[0].D[1] != 0x12 {
+		t.Error("word:", f[0].D[:2])
+	}
+	g[0].Addr = 0x59
+	if err := ReadWrite(&g, &f); err != syscall.ENXIO {
+		t.Error("absent device:", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, s := range []string{
+		"byte 0 1\n",
+		"device 0\n",
+		"device 0 0x50\nbyte 0x100 1\n",
+		"device 0 0x50\nfault bogus\n",
+		"device 0 0x50\nstring 0 \"unterminated\n",
+		"device 0 0x50\nfrob\n",
+	} {
+		if err := NewSim().Load(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: loaded", s)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package i2cbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Mux is an i2c multiplexer, e.g. PCA9548, that selects the channels of its
+// downstream segments by a byte written to its address.
+type Mux struct {
+	Name string
+	Bus  int
+	Addr int
+}
+
+// Topology is the machine description of the i2c muxes.
+//
+//	{
+//		"Muxes": [
+//			{ "Name": "main", "Bus": 0, "Addr": 118 }
+//		]
+//	}
+type Topology struct {
+	Muxes []Mux
+}
+
+// LoadTopology from the JSON file.
+func LoadTopology(fn string) (*Topology, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	t := new(Topology)
+	if err = json.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	if err = t.Check(); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return t, nil
+}
+
+// Check for unnamed or duplicate muxes.
+func (t *Topology) Check() error {
+	names := make(map[string]bool)
+	addrs := make(map[[2]int]bool)
+	for _, m := range t.Muxes {
+		k := [2]int{m.Bus, m.Addr}
+		switch {
+		case len(m.Name) == 0:
+			return fmt.Errorf("%d.%#x: unnamed mux", m.Bus, m.Addr)
+		case names[m.Name]:
+			return fmt.Errorf("%s: duplicate mux", m.Name)
+		case addrs[k]:
+			return fmt.Errorf("%d.%#x: duplicate mux", m.Bus, m.Addr)
+		}
+		names[m.Name] = true
+		addrs[k] = true
+	}
+	return nil
+}
+
+// Mux returns the named mux.
+func (t *Topology) Mux(name string) (*Mux, bool) {
+	if t != nil {
+		for i := range t.Muxes {
+			if t.Muxes[i].Name == name {
+				return &t.Muxes[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// MuxAt returns the mux with the bus address.
+func (t *Topology) MuxAt(bus, addr int) (*Mux, bool) {
+	if t != nil {
+		for i := range t.Muxes {
+			if t.Muxes[i].Bus == bus && t.Muxes[i].Addr == addr {
+				return &t.Muxes[i], true
+			}
+		}
+	}
+	return nil, false
+}
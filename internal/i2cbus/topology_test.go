@@ -0,0 +1,57 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package i2cbus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTopology(t *testing.T) {
+	dir, err := ioutil.TempDir("", "topology")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "mk1-bmc.json")
+	err = ioutil.WriteFile(fn, []byte(`{
+	"Muxes": [
+		{ "Name": "main", "Bus": 0, "Addr": 118 },
+		{ "Name": "fantray", "Bus": 1, "Addr": 114 }
+	]
+}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topo, err := LoadTopology(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, found := topo.Mux("fantray"); !found || m.Addr != 0x72 {
+		t.Error("fantray:", m)
+	}
+	if m, found := topo.MuxAt(0, 0x76); !found || m.Name != "main" {
+		t.Error("0.0x76:", m)
+	}
+	if _, found := topo.Mux("fru"); found {
+		t.Error("found fru")
+	}
+	var none *Topology
+	if _, found := none.Mux("main"); found {
+		t.Error("found main without a topology")
+	}
+
+	for _, muxes := range [][]Mux{
+		{{Bus: 0, Addr: 0x76}},
+		{{"main", 0, 0x76}, {"main", 1, 0x72}},
+		{{"main", 0, 0x76}, {"fantray", 0, 0x76}},
+	} {
+		if err := (&Topology{muxes}).Check(); err == nil {
+			t.Errorf("%v: checked", muxes)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package loop attaches files to loop block devices.
+package loop
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	Control = "/dev/loop-control"
+
+	ctlGetFree    = 0x4c82
+	setFd         = 0x4c00
+	clrFd         = 0x4c01
+	setStatus64   = 0x4c04
+	flagReadOnly  = 1
+	flagAutoClear = 4
+	nameSize      = 64
+	keySize       = 32
+)
+
+// info64 is struct loop_info64 of linux/loop.h
+type info64 struct {
+	device         uint64
+	inode          uint64
+	rdevice        uint64
+	offset         uint64
+	sizelimit      uint64
+	number         uint32
+	encryptType    uint32
+	encryptKeySize uint32
+	flags          uint32
+	fileName       [nameSize]byte
+	cryptName      [nameSize]byte
+	encryptKey     [keySize]byte
+	init           [2]uint64
+}
+
+func ioctl(fd, req, arg uintptr) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// Attach the file to a free loop device, and return the open device. The
+// device is detached when its last user closes it, so the caller must keep
+// it open until its mount.
+func Attach(fn string, offset, sizelimit uint64, readOnly bool) (*os.File, error) {
+	mode := os.O_RDWR
+	if readOnly {
+		mode = os.O_RDONLY
+	}
+	f, err := os.OpenFile(fn, mode, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ctl, err := os.OpenFile(Control, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer ctl.Close()
+
+	for retry := 0; ; retry++ {
+		n, _, e := syscall.Syscall(syscall.SYS_IOCTL, ctl.Fd(),
+			ctlGetFree, 0)
+		if e != 0 {
+			return nil, fmt.Errorf("%s: %v", Control, e)
+		}
+		dev := fmt.Sprint("/dev/loop", n)
+		lf, err := os.OpenFile(dev, mode, 0)
+		if err != nil {
+			return nil, err
+		}
+		err = ioctl(lf.Fd(), setFd, f.Fd())
+		if err == syscall.EBUSY && retry < 3 {
+			// raced with another user of the free device
+			lf.Close()
+			continue
+		}
+		if err != nil {
+			lf.Close()
+			return nil, fmt.Errorf("%s: %v", dev, err)
+		}
+		info := info64{
+			offset:    offset,
+			sizelimit: sizelimit,
+			flags:     flagAutoClear,
+		}
+		if readOnly {
+			info.flags |= flagReadOnly
+		}
+		copy(info.fileName[:nameSize-1], fn)
+		err = ioctl(lf.Fd(), setStatus64,
+			uintptr(unsafe.Pointer(&info)))
+		if err != nil {
+			ioctl(lf.Fd(), clrFd, 0)
+			lf.Close()
+			return nil, fmt.Errorf("%s: %v", dev, err)
+		}
+		return lf, nil
+	}
+}
+
+// Detach the file from the loop device.
+func Detach(dev string) error {
+	f, err := os.Open(dev)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ioctl(f.Fd(), clrFd, 0)
+}
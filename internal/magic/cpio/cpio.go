@@ -0,0 +1,25 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package cpio
+
+import (
+	"bytes"
+)
+
+var (
+	// ASCII headers: new, new with checksum, and old portable
+	Magics = []string{"070701", "070702", "070707"}
+	// old binary headers, little and big endian
+	BinaryMagics = []string{"\xc7\x71", "\x71\xc7"}
+)
+
+func Probe(s []byte) bool {
+	for _, m := range append(Magics, BinaryMagics...) {
+		if bytes.HasPrefix(s, []byte(m)) {
+			return true
+		}
+	}
+	return false
+}
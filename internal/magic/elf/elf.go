@@ -0,0 +1,19 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package elf
+
+import (
+	"bytes"
+)
+
+const (
+	MagicOff = 0
+	Magic    = "\x7fELF"
+)
+
+func Probe(s []byte) bool {
+	return len(s) >= MagicOff+len(Magic) &&
+		bytes.Equal(s[MagicOff:MagicOff+len(Magic)], []byte(Magic))
+}
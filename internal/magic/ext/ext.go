@@ -45,7 +45,8 @@ const (
 )
 
 func Probe(s []byte) bool {
-	if s[MagicOffL] == MagicValL && s[MagicOffM] == MagicValM {
+	if len(s) >= FeatureRoCompatOff+4 &&
+		s[MagicOffL] == MagicValL && s[MagicOffM] == MagicValM {
 		return true
 	}
 	return false
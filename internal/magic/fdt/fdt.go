@@ -0,0 +1,34 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fdt
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	Magic       = 0xd00dfeed
+	OffDtStruct = 8
+	BeginNode   = 1
+)
+
+func Probe(s []byte) bool {
+	return len(s) >= 40 && binary.BigEndian.Uint32(s) == Magic
+}
+
+// IsFIT returns true if the flattened device tree is a Flattened Image Tree;
+// that is, it has an images node.
+func IsFIT(s []byte) bool {
+	if !Probe(s) {
+		return false
+	}
+	off := int(binary.BigEndian.Uint32(s[OffDtStruct:]))
+	if off > len(s) {
+		return false
+	}
+	images := []byte{0, 0, 0, BeginNode, 'i', 'm', 'a', 'g', 'e', 's', 0}
+	return bytes.Contains(s[off:], images)
+}
@@ -0,0 +1,54 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpt
+
+import (
+	"bytes"
+)
+
+const (
+	PartitionTableOff = 0x1be
+	PartitionEntryLen = 16
+	PartitionTypeOff  = 4
+	ProtectiveType    = 0xee
+
+	MagicOffL = 0x1fe
+	MagicValL = 0x55
+	MagicValM = 0xaa
+
+	Signature = "EFI PART"
+)
+
+// HeaderOffs are those of the primary header with 512 and 4096 byte
+// logical blocks.
+var HeaderOffs = []int{512, 4096}
+
+// ProtectiveMBR returns true if the MBR has a GPT protective partition.
+func ProtectiveMBR(s []byte) bool {
+	if len(s) < 512 || s[MagicOffL] != MagicValL ||
+		s[MagicOffL+1] != MagicValM {
+		return false
+	}
+	for i := 0; i < 4; i++ {
+		off := PartitionTableOff + i*PartitionEntryLen
+		if s[off+PartitionTypeOff] == ProtectiveType {
+			return true
+		}
+	}
+	return false
+}
+
+func Probe(s []byte) bool {
+	if !ProtectiveMBR(s) {
+		return false
+	}
+	for _, off := range HeaderOffs {
+		if len(s) >= off+len(Signature) &&
+			bytes.Equal(s[off:off+len(Signature)], []byte(Signature)) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,15 +5,31 @@
 package magic
 
 import (
+	"github.com/platinasystems/go/internal/magic/btrfs"
+	"github.com/platinasystems/go/internal/magic/cpio"
+	"github.com/platinasystems/go/internal/magic/elf"
 	"github.com/platinasystems/go/internal/magic/ext2"
 	"github.com/platinasystems/go/internal/magic/ext3"
 	"github.com/platinasystems/go/internal/magic/ext4"
+	"github.com/platinasystems/go/internal/magic/fdt"
+	"github.com/platinasystems/go/internal/magic/gpt"
+	"github.com/platinasystems/go/internal/magic/gzip"
 	"github.com/platinasystems/go/internal/magic/iso9660"
 	"github.com/platinasystems/go/internal/magic/mbr"
+	"github.com/platinasystems/go/internal/magic/squashfs"
+	"github.com/platinasystems/go/internal/magic/swap"
 	"github.com/platinasystems/go/internal/magic/vfat"
+	"github.com/platinasystems/go/internal/magic/xfs"
+	"github.com/platinasystems/go/internal/magic/xz"
 )
 
+// isoSniffLen covers the last of the iso9660 volume descriptor magics.
+const isoSniffLen = iso9660.MagicOff3 + 5
+
 func IdentifyPartitionMap(sniff []byte) string {
+	if gpt.Probe(sniff) {
+		return "gpt"
+	}
 	if mbr.Probe(sniff) && IdentifyPartition(sniff) == "" {
 		return "mbr"
 	}
@@ -30,15 +46,49 @@ func IdentifyPartition(sniff []byte) string {
 	if ext4.Probe(sniff) {
 		return "ext4"
 	}
+	// before vfat, whose probe is the loosest
+	if squashfs.Probe(sniff) {
+		return "squashfs"
+	}
+	if xfs.Probe(sniff) {
+		return "xfs"
+	}
+	if btrfs.Probe(sniff) {
+		return "btrfs"
+	}
+	if swap.Probe(sniff) {
+		return "swap"
+	}
 	if vfat.Probe(sniff) {
 		return "vfat"
 	}
-	if iso9660.Probe(sniff) {
+	if len(sniff) >= isoSniffLen && iso9660.Probe(sniff) {
 		return "iso9660"
 	}
 	return ""
 }
 
 func IdentifyFile(sniff []byte) string {
+	if elf.Probe(sniff) {
+		return "elf"
+	}
+	if gzip.Probe(sniff) {
+		return "gzip"
+	}
+	if xz.Probe(sniff) {
+		return "xz"
+	}
+	if cpio.Probe(sniff) {
+		return "cpio"
+	}
+	if fdt.IsFIT(sniff) {
+		return "fit"
+	}
+	if fdt.Probe(sniff) {
+		return "dtb"
+	}
+	if len(sniff) >= isoSniffLen && iso9660.Probe(sniff) {
+		return "iso9660"
+	}
 	return ""
 }
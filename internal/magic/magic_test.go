@@ -5,6 +5,7 @@
 package magic
 
 import (
+	"encoding/binary"
 	"io/ioutil"
 	"testing"
 )
@@ -42,7 +43,7 @@ func TestExt4(t *testing.T) {
 }
 
 func TestIso9660(t *testing.T) {
-	testFile(t, "iso9660-sb.dat", "", "iso9660", "")
+	testFile(t, "iso9660-sb.dat", "", "iso9660", "iso9660")
 }
 
 func TestMbr(t *testing.T) {
@@ -52,3 +53,67 @@ func TestMbr(t *testing.T) {
 func TestVfat(t *testing.T) {
 	testFile(t, "vfat-sb.dat", "", "vfat", "")
 }
+
+func TestSwap(t *testing.T) {
+	testFile(t, "swap-sb.dat", "", "swap", "")
+}
+
+func sniff(off int, magic string) []byte {
+	b := make([]byte, 0x11000)
+	copy(b[off:], magic)
+	return b
+}
+
+func TestPartitions(t *testing.T) {
+	for _, tc := range []struct {
+		sniff []byte
+		pType string
+	}{
+		{sniff(0, "hsqs"), "squashfs"},
+		{sniff(0, "XFSB"), "xfs"},
+		{sniff(0x10040, "_BHRfS_M"), "btrfs"},
+		{sniff(0x3ff6, "SWAPSPACE2"), "swap"},
+		{sniff(0, ""), ""},
+	} {
+		if pt := IdentifyPartition(tc.sniff); pt != tc.pType {
+			t.Error("Partition type expected ", tc.pType, " got ", pt)
+		}
+	}
+}
+
+func TestGpt(t *testing.T) {
+	b := sniff(0x200, "EFI PART")
+	b[0x1be+4] = 0xee
+	b[0x1fe], b[0x1ff] = 0x55, 0xaa
+	if pmt := IdentifyPartitionMap(b); pmt != "gpt" {
+		t.Error("Partition map expected gpt got ", pmt)
+	}
+	b[0x1be+4] = 0x83
+	if pmt := IdentifyPartitionMap(b); pmt != "mbr" {
+		t.Error("Partition map expected mbr got ", pmt)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	fit := make([]byte, 0x100)
+	binary.BigEndian.PutUint32(fit, 0xd00dfeed)
+	binary.BigEndian.PutUint32(fit[8:], 0x40)
+	dtb := append([]byte{}, fit...)
+	copy(fit[0x50:], "\x00\x00\x00\x01images\x00")
+	for _, tc := range []struct {
+		sniff []byte
+		fType string
+	}{
+		{[]byte("\x7fELF\x02\x01\x01"), "elf"},
+		{[]byte("\x1f\x8b\x08\x00"), "gzip"},
+		{[]byte("\xfd7zXZ\x00\x00"), "xz"},
+		{[]byte("07070100000001"), "cpio"},
+		{fit, "fit"},
+		{dtb, "dtb"},
+		{[]byte("text"), ""},
+	} {
+		if ft := IdentifyFile(tc.sniff); ft != tc.fType {
+			t.Error("File type expected ", tc.fType, " got ", ft)
+		}
+	}
+}
@@ -17,7 +17,8 @@ func isFatValidMedia(s []byte) bool {
 }
 
 func Probe(s []byte) bool {
-	if s[MagicOffL] == MagicValL && s[MagicOffM] == MagicValM &&
+	if len(s) > MagicOffM &&
+		s[MagicOffL] == MagicValL && s[MagicOffM] == MagicValM &&
 		!isFatValidMedia(s) {
 		return true
 	}
@@ -0,0 +1,36 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package swap
+
+import (
+	"bytes"
+)
+
+const MagicLen = 10
+
+// Magics are at the end of the first page.
+var (
+	Magics    = []string{"SWAPSPACE2", "SWAP-SPACE"}
+	PageSizes = []int{4096, 8192, 16384, 65536}
+)
+
+// PageSize returns the page size of the swap space, or 0 if not swap.
+func PageSize(s []byte) int {
+	for _, ps := range PageSizes {
+		if len(s) < ps {
+			break
+		}
+		for _, m := range Magics {
+			if bytes.Equal(s[ps-MagicLen:ps], []byte(m)) {
+				return ps
+			}
+		}
+	}
+	return 0
+}
+
+func Probe(s []byte) bool {
+	return PageSize(s) != 0
+}
@@ -10,6 +10,9 @@ import (
 
 func checkMagic(s []byte, o int, magics ...[]byte) bool {
 	for _, m := range magics {
+		if len(s) < o+len(m) {
+			continue
+		}
 		chk := s[o : o+len(m)]
 		if bytes.Equal(chk, m) {
 			return true
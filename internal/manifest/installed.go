@@ -0,0 +1,50 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package manifest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// InstalledFile records the artifact installed to each target.
+var InstalledFile = "/etc/goes/installed"
+
+// Installed returns the record of the target's artifact, or nil.
+func Installed(target string) *Artifact {
+	m := readInstalled()
+	if a, found := m[target]; found {
+		return &a
+	}
+	return nil
+}
+
+// Record the artifact as installed to its target.
+func Record(a *Artifact) error {
+	m := readInstalled()
+	m[a.Target] = *a
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(InstalledFile), 0755); err != nil {
+		return err
+	}
+	tmp := InstalledFile + ".tmp"
+	if err = ioutil.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, InstalledFile)
+}
+
+func readInstalled() map[string]Artifact {
+	m := make(map[string]Artifact)
+	if b, err := ioutil.ReadFile(InstalledFile); err == nil {
+		json.Unmarshal(b, &m)
+	}
+	return m
+}
@@ -0,0 +1,29 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package manifest
+
+import "strings"
+
+// Key is the base64 ed25519 public key of the Platina Systems release
+// signer, or a comma separated list of them. Use this build ldflag to
+// configure it,
+//
+// -X github.com/platinasystems/go/internal/manifest.Key=BASE64
+//
+// Without a key, no manifest verifies.
+var Key string
+
+// Keys are the base64 ed25519 public keys of other release signers.
+var Keys []string
+
+func keys() []string {
+	var k []string
+	for _, s := range strings.Split(Key, ",") {
+		if s = strings.TrimSpace(s); len(s) > 0 {
+			k = append(k, s)
+		}
+	}
+	return append(k, Keys...)
+}
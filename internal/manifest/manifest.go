@@ -0,0 +1,248 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package manifest verifies upgrades with the signed manifest of a release,
+//
+//	SERVER/VERSION/MANIFEST
+//	SERVER/VERSION/MANIFEST.sig
+//
+// where MANIFEST is JSON of the form,
+//
+//	{
+//		"Machine": "platina-mk1",
+//		"Version": "v1.2.0",
+//		"Artifacts": [
+//			{
+//				"Name": "goes-platina-mk1",
+//				"Version": "v1.2.0",
+//				"Size": 12345678,
+//				"Sha256": "...",
+//				"Target": "goes"
+//			},
+//			...
+//		]
+//	}
+//
+// and MANIFEST.sig is the base64 ed25519 signature of MANIFEST by the
+// release Key, set at build time, or one of the other Keys.
+package manifest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/platinasystems/go/internal/url"
+)
+
+const (
+	Name    = "MANIFEST"
+	SigName = Name + ".sig"
+)
+
+var (
+	ErrSignature = errors.New("manifest signature verification failed")
+	ErrNoKey     = errors.New("no release key to verify the manifest; " +
+		"build with -ldflags -X " +
+		"github.com/platinasystems/go/internal/manifest.Key=BASE64")
+)
+
+// Artifact is a file of the release installed to its Target, e.g. goes,
+// kernel, coreboot or qspi.
+type Artifact struct {
+	Name    string
+	Version string
+	Size    int64
+	Sha256  string
+	Target  string
+}
+
+type Manifest struct {
+	Machine   string
+	Version   string
+	Artifacts []Artifact
+}
+
+// Parse the manifest after verifying its signature.
+func Parse(b, sig []byte) (*Manifest, error) {
+	if err := Verify(b, sig); err != nil {
+		return nil, err
+	}
+	m := new(Manifest)
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("%s: %v", Name, err)
+	}
+	for i := range m.Artifacts {
+		a := &m.Artifacts[i]
+		if len(a.Name) == 0 || len(a.Target) == 0 {
+			return nil, fmt.Errorf("%s: artifact %d: missing name or target",
+				Name, i)
+		}
+		if _, err := hex.DecodeString(a.Sha256); err != nil ||
+			len(a.Sha256) != 2*sha256.Size {
+			return nil, fmt.Errorf("%s: %s: invalid sha256", Name,
+				a.Name)
+		}
+	}
+	return m, nil
+}
+
+// Verify the base64 signature of the manifest with the release Key or
+// other Keys.
+func Verify(b, sig []byte) error {
+	keys := keys()
+	if len(keys) == 0 {
+		return ErrNoKey
+	}
+	s, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+	if err != nil || len(s) != ed25519.SignatureSize {
+		return ErrSignature
+	}
+	for _, k := range keys {
+		pub, err := base64.StdEncoding.DecodeString(k)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			continue
+		}
+		if ed25519.Verify(ed25519.PublicKey(pub), b, s) {
+			return nil
+		}
+	}
+	return ErrSignature
+}
+
+// Sign returns the base64 signature of the manifest.
+func Sign(key ed25519.PrivateKey, b []byte) []byte {
+	s := base64.StdEncoding.EncodeToString(ed25519.Sign(key, b))
+	return []byte(s + "\n")
+}
+
+// Get and verify the manifest of the server's version.
+func Get(server, version string, tftp bool) (*Manifest, error) {
+	b, err := get(server, version, tftp, Name)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := get(server, version, tftp, SigName)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b, sig)
+}
+
+func get(server, version string, tftp bool, fn string) ([]byte, error) {
+	r, err := url.Open(URL(server, version, tftp, fn))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// GetArtifact downloads the target's artifact of the server version's
+// manifest for the machine to the file, verifying its size and sum.
+func GetArtifact(server, version string, tftp bool, machine, target,
+	fn string) (*Artifact, error) {
+	m, err := Get(server, version, tftp)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.Check(machine); err != nil {
+		return nil, err
+	}
+	a := m.Lookup(target)
+	if a == nil {
+		return nil, fmt.Errorf("%s: %s: not found", Name, target)
+	}
+	if err = a.Download(server, version, tftp, fn); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// URL of the file of the server's version.
+func URL(server, version string, tftp bool, fn string) string {
+	scheme := "http://"
+	if tftp {
+		scheme = "tftp://"
+	}
+	return scheme + server + "/" + version + "/" + fn
+}
+
+// Lookup the first artifact with the target.
+func (m *Manifest) Lookup(target string) *Artifact {
+	for i := range m.Artifacts {
+		if m.Artifacts[i].Target == target {
+			return &m.Artifacts[i]
+		}
+	}
+	return nil
+}
+
+// Check the manifest's machine.
+func (m *Manifest) Check(machine string) error {
+	if m.Machine != machine {
+		return fmt.Errorf("%s: %s is for %s", Name, machine, m.Machine)
+	}
+	return nil
+}
+
+// Download the artifact from the server's version to the file, which is
+// removed unless it has the manifest's size and sum.
+func (a *Artifact) Download(server, version string, tftp bool,
+	fn string) error {
+	r, err := url.Open(URL(server, version, tftp, a.Name))
+	if err != nil {
+		return fmt.Errorf("%s: %v", a.Name, err)
+	}
+	defer r.Close()
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), io.LimitReader(r, a.Size+1))
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = a.Verify(fn)
+	}
+	if err != nil {
+		os.Remove(fn)
+	}
+	return err
+}
+
+// Verify the file with the artifact's size and sum.
+func (a *Artifact) Verify(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if n != a.Size {
+		return fmt.Errorf("%s: size %d, expected %d", a.Name, n, a.Size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != a.Sha256 {
+		return fmt.Errorf("%s: sha256 %s, expected %s", a.Name, sum,
+			a.Sha256)
+	}
+	return nil
+}
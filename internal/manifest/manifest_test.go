@@ -0,0 +1,196 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package manifest
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T) ed25519.PrivateKey {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Keys = append(Keys, base64.StdEncoding.EncodeToString(pub))
+	return key
+}
+
+func TestNoKey(t *testing.T) {
+	defer func(k string, ks []string) { Key, Keys = k, ks }(Key, Keys)
+	Key, Keys = "", nil
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := []byte(`{"Machine": "platina-mk1"}`)
+	if err = Verify(b, Sign(key, b)); err != ErrNoKey {
+		t.Error("without a release key:", err)
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	Key = " " + base64.StdEncoding.EncodeToString(pub) + ","
+	if err = Verify(b, Sign(key, b)); err != nil {
+		t.Error("with -X Key:", err)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	key := testKey(t)
+	goes := []byte("goes binary")
+	sum := sha256.Sum256(goes)
+	b, err := json.Marshal(&Manifest{
+		Machine: "platina-mk1",
+		Version: "v1.2.0",
+		Artifacts: []Artifact{{
+			Name:    "goes-platina-mk1",
+			Version: "v1.2.0",
+			Size:    int64(len(goes)),
+			Sha256:  hex.EncodeToString(sum[:]),
+			Target:  "goes",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.2.0/" + Name:
+			w.Write(b)
+		case "/v1.2.0/" + SigName:
+			w.Write(Sign(key, b))
+		case "/v1.2.0/goes-platina-mk1":
+			w.Write(goes)
+		case "/bad/goes-platina-mk1":
+			w.Write([]byte("goes binarx"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	server := strings.TrimPrefix(srv.URL, "http://")
+
+	m, err := Get(server, "v1.2.0", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Check("platina-mk2"); err == nil {
+		t.Error("wrong machine")
+	}
+	a := m.Lookup("goes")
+	if a == nil {
+		t.Fatal("goes artifact not found")
+	}
+
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, a.Name)
+	if err = a.Download(server, "v1.2.0", false, fn); err != nil {
+		t.Fatal(err)
+	}
+	if err = a.Download(server, "bad", false, fn); err == nil {
+		t.Error("downloaded corrupt artifact")
+	}
+	if _, err = os.Stat(fn); !os.IsNotExist(err) {
+		t.Error("corrupt artifact remains")
+	}
+
+	InstalledFile = filepath.Join(dir, "installed")
+	if err = Record(a); err != nil {
+		t.Fatal(err)
+	}
+	if x := Installed("goes"); x == nil || *x != *a {
+		t.Error("installed:", x)
+	}
+
+	tampered := []byte(strings.Replace(string(b), "v1.2.0", "v9.9.9", -1))
+	if _, err = Parse(tampered, Sign(key, b)); err != ErrSignature {
+		t.Error("tampered manifest:", err)
+	}
+	_, other, _ := ed25519.GenerateKey(nil)
+	if _, err = Parse(b, Sign(other, b)); err != ErrSignature {
+		t.Error("unknown key:", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	for _, x := range []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.0", "v1.2.0", 0},
+		{"v1.2", "1.2", 0},
+		{"v1.2.0", "v1.10.0", -1},
+		{"v1.2-3-gabcdef", "v1.2", 1},
+		{"v1.2-10-g1234", "v1.2-9-gffff", 1},
+		{"20180315", "20171231", 1},
+		{"v0.3", "v0.3.1", -1},
+		{"v0.3", "20170901", -1},
+		{"20170901", "20170830", 1},
+	} {
+		if got := Compare(x.a, x.b); got != x.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", x.a, x.b,
+				got, x.want)
+		}
+	}
+	if err := Upgrade("v1.2", "v1.1", false); err != ErrDowngrade {
+		t.Error("downgrade:", err)
+	}
+	if err := Upgrade("v1.2", "v1.1", true); err != nil {
+		t.Error("forced downgrade:", err)
+	}
+	if err := Upgrade("v1.2", "v1.2", false); err != ErrSame {
+		t.Error("same:", err)
+	}
+	if err := Upgrade("", "v1.1", false); err != ErrUnknown {
+		t.Error("unknown:", err)
+	}
+	if err := Upgrade("", "v1.1", true); err != nil {
+		t.Error("forced unknown:", err)
+	}
+	erased := string([]byte{0xff, 0xff, 0xff, 0xff})
+	for _, x := range []struct {
+		cur, new string
+		want     error
+	}{
+		// those of the BMC upgrade before the manifest, with the
+		// version of an erased QSPI
+		{"v0.2", "v0.3", nil},
+		{"v0.3", "v0.2", ErrDowngrade},
+		{"v0.3", "20170901", nil},
+		{"20170901", "v0.2", ErrDowngrade},
+		{"20170901", "20170902", nil},
+		{"20170901", "20170830", ErrDowngrade},
+		{erased, "20170830", nil},
+		{"20170830", erased, ErrDowngrade},
+		{"dev", "20170830", nil},
+		{"20170830", "dev", nil},
+	} {
+		if err := Upgrade(x.cur, x.new, false); err != x.want {
+			t.Errorf("Upgrade(%q, %q): %v, want %v", x.cur, x.new,
+				err, x.want)
+		}
+	}
+	a := &Artifact{Version: "v1.1"}
+	if ok, err := CheckVersion("Goes", "", a, false); ok || err == nil {
+		t.Error("unknown version checked", ok, err)
+	}
+	if ok, err := CheckVersion("Goes", "v1.1", a, false); ok || err != nil {
+		t.Error("same version checked", ok, err)
+	}
+}
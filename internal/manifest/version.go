@@ -0,0 +1,126 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrSame      = errors.New("versions match")
+	ErrDowngrade = errors.New("downgrade")
+	ErrUnknown   = errors.New("unknown current version")
+)
+
+// Compare versions, e.g. v1.2.3, v1.2-4-gabcdef or YYYYMMDD, by their
+// runs of digits and other characters; returns -1, 0, or 1 if a is older,
+// the same, or newer than b.
+func Compare(a, b string) int {
+	if ea, eb := Erased(a), Erased(b); ea || eb {
+		switch {
+		case ea && eb:
+			return 0
+		case ea:
+			return -1
+		}
+		return 1
+	}
+	ta := tokens(strings.TrimPrefix(strings.TrimSpace(a), "v"))
+	tb := tokens(strings.TrimPrefix(strings.TrimSpace(b), "v"))
+	for i := 0; i < len(ta) && i < len(tb); i++ {
+		na, aerr := strconv.ParseUint(ta[i], 10, 64)
+		nb, berr := strconv.ParseUint(tb[i], 10, 64)
+		switch {
+		case aerr == nil && berr == nil:
+			if na != nb {
+				return cmp(na < nb)
+			}
+		case ta[i] != tb[i]:
+			return cmp(ta[i] < tb[i])
+		}
+	}
+	switch {
+	case len(ta) < len(tb):
+		return -1
+	case len(ta) > len(tb):
+		return 1
+	}
+	return 0
+}
+
+func cmp(less bool) int {
+	if less {
+		return -1
+	}
+	return 1
+}
+
+func tokens(s string) []string {
+	var t []string
+	for i := 0; i < len(s); {
+		j := i + 1
+		digit := isDigit(s[i])
+		for j < len(s) && isDigit(s[j]) == digit {
+			j++
+		}
+		t = append(t, s[i:j])
+		i = j
+	}
+	return t
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// Erased returns true if the version is that of erased flash, all ones,
+// which is older than any other.
+func Erased(v string) bool {
+	return len(v) > 0 && len(strings.Trim(v, "\xff")) == 0
+}
+
+// Upgrade returns nil if the new version may replace the current one;
+// otherwise, ErrSame, ErrDowngrade, or ErrUnknown if the current version
+// is empty, unless forced. "dev" and Erased versions may always be
+// replaced.
+func Upgrade(cur, new string, force bool) error {
+	switch {
+	case force, Erased(cur):
+		return nil
+	case len(cur) == 0:
+		return ErrUnknown
+	case cur == "dev" || new == "dev":
+		return nil
+	}
+	switch Compare(new, cur) {
+	case 0:
+		return ErrSame
+	case -1:
+		return ErrDowngrade
+	}
+	return nil
+}
+
+// CheckVersion returns true if the artifact should replace the current
+// version of what; downgrades, and upgrades of an unknown version, are an
+// error unless forced.
+func CheckVersion(what, cur string, a *Artifact, force bool) (bool, error) {
+	fmt.Printf("    %s version currently:  %s\n", what, cur)
+	fmt.Printf("    %s version on server:  %s\n", what, a.Version)
+	switch err := Upgrade(cur, a.Version, force); err {
+	case nil:
+		return true, nil
+	case ErrSame:
+		fmt.Printf("    Versions match, skipping %s upgrade\n\n", what)
+		return false, nil
+	case ErrUnknown:
+		return false, fmt.Errorf("%s version is unknown, "+
+			"use -f to upgrade to %s", what, a.Version)
+	default:
+		return false, fmt.Errorf("%s %s is older than %s, use -f to %v",
+			what, a.Version, cur, err)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package nl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Multicast groups of NETLINK_KOBJECT_UEVENT
+const (
+	UEVENT_KERNEL_GROUP = 1
+	UEVENT_UDEV_GROUP   = 2
+)
+
+// Uevent is a kobject_uevent message of the kernel, e.g.
+//
+//	add@/devices/.../block/sdb/sdb1
+//	ACTION=add
+//	DEVPATH=/devices/.../block/sdb/sdb1
+//	SUBSYSTEM=block
+//	MAJOR=8
+//	MINOR=17
+//	DEVNAME=sdb1
+//	DEVTYPE=partition
+//	SEQNUM=1234
+type Uevent struct {
+	Action  string // add, remove, change, move, online, offline, ...
+	DevPath string // below /sys
+	Env     map[string]string
+}
+
+// ParseUevent parses the NUL separated header and KEY=VALUE environment of
+// the message.
+func ParseUevent(b []byte) (*Uevent, error) {
+	fields := bytes.Split(bytes.TrimRight(b, "\x00"), []byte{0})
+	header := string(fields[0])
+	at := strings.IndexByte(header, '@')
+	if at < 1 {
+		return nil, fmt.Errorf("%q: not a uevent", header)
+	}
+	u := &Uevent{
+		Action:  header[:at],
+		DevPath: header[at+1:],
+		Env:     make(map[string]string),
+	}
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(string(f), "=", 2)
+		if len(kv) == 2 {
+			u.Env[kv[0]] = kv[1]
+		}
+	}
+	if action, found := u.Env["ACTION"]; found && action != u.Action {
+		return nil, fmt.Errorf("%s: ACTION=%s: mismatch", header, action)
+	}
+	return u, nil
+}
+
+func (u *Uevent) Subsystem() string { return u.Env["SUBSYSTEM"] }
+
+func (u *Uevent) String() string {
+	keys := make([]string, 0, len(u.Env))
+	for k := range u.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	s := u.Action + "@" + u.DevPath
+	for _, k := range keys {
+		s += " " + k + "=" + u.Env[k]
+	}
+	return s
+}
+
+// UeventSock receives kernel uevents on RxCh, which is closed with the
+// socket or on a receive error noted in Err. Unlike Sock, uevents aren't
+// framed by netlink headers; and messages from anything other than the
+// kernel, like udevd, are dropped.
+type UeventSock struct {
+	RxCh <-chan *Uevent
+	rxch chan<- *Uevent
+	Err  error
+	fd   int
+	// use a pipe to signal close to gorx
+	pr      *os.File
+	pw      *os.File
+	closing chan struct{}
+	rxdone  chan struct{}
+}
+
+// NewUeventSock listens to the kernel uevent group with a receive channel
+// of the given depth.
+func NewUeventSock(depth int) (*UeventSock, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM,
+		NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	defer func() {
+		if err != nil {
+			syscall.Close(fd)
+		}
+	}()
+	sa := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: UEVENT_KERNEL_GROUP,
+	}
+	if err = syscall.Bind(fd, sa); err != nil {
+		return nil, os.NewSyscallError("bind", err)
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	rxch := make(chan *Uevent, depth)
+	sock := &UeventSock{
+		RxCh:    rxch,
+		rxch:    rxch,
+		fd:      fd,
+		pr:      pr,
+		pw:      pw,
+		closing: make(chan struct{}),
+		rxdone:  make(chan struct{}),
+	}
+	go sock.gorx()
+	return sock, nil
+}
+
+func (sock *UeventSock) Close() error {
+	if sock.pw == nil {
+		return Eclosed
+	}
+	close(sock.closing)
+	sock.pw.Close()
+	sock.pw = nil
+	<-sock.rxdone
+	return nil
+}
+
+func (sock *UeventSock) gorx() {
+	defer close(sock.rxdone)
+	defer close(sock.rxch)
+	defer sock.pr.Close()
+	defer syscall.Close(sock.fd)
+
+	b := make([]byte, PAGE.Size())
+	prfd := int(sock.pr.Fd())
+
+	for {
+		var rfds syscall.FdSet
+		FD_ZERO(&rfds)
+		FD_SET(&rfds, sock.fd)
+		FD_SET(&rfds, prfd)
+		maxfd := prfd
+		if sock.fd > maxfd {
+			maxfd = sock.fd
+		}
+		tv := syscall.Timeval{Sec: 10}
+		n, err := syscall.Select(maxfd+1, &rfds, nil, nil, &tv)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			sock.Err = err
+			return
+		}
+		if n == 0 {
+			continue
+		}
+		if FD_ISSET(&rfds, prfd) {
+			// UeventSock.Close
+			sock.Err = io.EOF
+			return
+		}
+		if !FD_ISSET(&rfds, sock.fd) {
+			continue
+		}
+		n, from, err := syscall.Recvfrom(sock.fd, b,
+			syscall.MSG_DONTWAIT)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.ENOBUFS {
+			// overrun; the lost events are gone for good
+			continue
+		}
+		if err != nil {
+			sock.Err = err
+			return
+		}
+		if sa, ok := from.(*syscall.SockaddrNetlink); !ok || sa.Pid != 0 {
+			continue
+		}
+		u, err := ParseUevent(b[:n])
+		if err != nil {
+			continue
+		}
+		select {
+		case sock.rxch <- u:
+		case <-sock.closing:
+			sock.Err = io.EOF
+			return
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package nl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseUevent(t *testing.T) {
+	msg := strings.Join([]string{
+		"add@/devices/pci0000:00/usb1/1-1/host6/block/sdb/sdb1",
+		"ACTION=add",
+		"DEVPATH=/devices/pci0000:00/usb1/1-1/host6/block/sdb/sdb1",
+		"SUBSYSTEM=block",
+		"MAJOR=8",
+		"MINOR=17",
+		"DEVNAME=sdb1",
+		"DEVTYPE=partition",
+		"SEQNUM=2345",
+	}, "\x00") + "\x00"
+	u, err := ParseUevent([]byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Action != "add" || u.Subsystem() != "block" ||
+		u.Env["DEVNAME"] != "sdb1" || u.Env["MINOR"] != "17" ||
+		!strings.HasSuffix(u.DevPath, "/sdb/sdb1") {
+		t.Error(u)
+	}
+	for _, bad := range []string{
+		"libudev\x00\xfe\xed\xca\xfe",
+		"add@/devices/x\x00ACTION=remove\x00",
+	} {
+		if _, err = ParseUevent([]byte(bad)); err == nil {
+			t.Errorf("%q: parsed", bad)
+		}
+	}
+}
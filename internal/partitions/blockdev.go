@@ -0,0 +1,303 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package partitions
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/platinasystems/go/internal/magic"
+)
+
+// These are variables for tests.
+var (
+	SysBlock   = "/sys/block"
+	DevDir     = "/dev"
+	ProcMounts = "/proc/self/mounts"
+)
+
+const (
+	sectorSize = 512
+	mbrSigOff  = 0x1b8
+)
+
+// BlockDevice is a disk or partition as listed by blkid and lsblk.
+type BlockDevice struct {
+	Name      string // e.g. sda1
+	Path      string // e.g. /dev/sda1
+	Parent    string // the disk of a partition
+	Type      string // disk or part
+	Size      int64  // bytes
+	ReadOnly  bool
+	Removable bool
+	// PTType is the partition table of a disk: gpt or dos
+	PTType     string
+	FsType     string
+	UUID       string
+	Label      string
+	PartUUID   string
+	PartLabel  string
+	MountPoint string
+}
+
+// Blkid returns the blkid(8) style description of the device.
+func (d *BlockDevice) Blkid() string {
+	s := d.Path + ":"
+	for _, x := range []struct{ k, v string }{
+		{"LABEL", d.Label},
+		{"UUID", d.UUID},
+		{"TYPE", d.FsType},
+		{"PTTYPE", d.PTType},
+		{"PARTLABEL", d.PartLabel},
+		{"PARTUUID", d.PartUUID},
+	} {
+		if len(x.v) > 0 {
+			s += fmt.Sprintf(" %s=%q", x.k, x.v)
+		}
+	}
+	return s
+}
+
+// Match returns true if the device is the given path or NAME=VALUE
+// specifier of UUID, LABEL, PARTUUID or PARTLABEL.
+func (d *BlockDevice) Match(spec string) bool {
+	i := strings.IndexByte(spec, '=')
+	if i < 0 {
+		return spec == d.Path || spec == d.Name
+	}
+	v := strings.Trim(spec[i+1:], `"`)
+	switch strings.ToUpper(spec[:i]) {
+	case "UUID":
+		return len(d.UUID) > 0 && strings.EqualFold(v, d.UUID)
+	case "LABEL":
+		return len(d.Label) > 0 && v == d.Label
+	case "PARTUUID":
+		return len(d.PartUUID) > 0 && strings.EqualFold(v, d.PartUUID)
+	case "PARTLABEL":
+		return len(d.PartLabel) > 0 && v == d.PartLabel
+	}
+	return false
+}
+
+// IsSpecifier returns true if the device name is a NAME=VALUE specifier.
+func IsSpecifier(spec string) bool {
+	for _, s := range []string{"UUID=", "LABEL=", "PARTUUID=", "PARTLABEL="} {
+		if strings.HasPrefix(strings.ToUpper(spec), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the first of the devices matching the specifier.
+func Find(devs []*BlockDevice, spec string) (*BlockDevice, error) {
+	for _, d := range devs {
+		if d.Match(spec) {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: not found", spec)
+}
+
+// Resolve returns the device path of the specifier; a path is returned as
+// is.
+func Resolve(spec string) (string, error) {
+	if !IsSpecifier(spec) {
+		return spec, nil
+	}
+	devs, err := BlockDevices()
+	if err != nil {
+		return "", err
+	}
+	d, err := Find(devs, spec)
+	if err != nil {
+		return "", err
+	}
+	return d.Path, nil
+}
+
+func readSys(dir, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func readSysInt(dir, name string) int64 {
+	i, _ := strconv.ParseInt(readSys(dir, name), 0, 64)
+	return i
+}
+
+// BlockDevices returns the disks of SysBlock, each followed by its
+// partitions. Disks without media are skipped.
+func BlockDevices() ([]*BlockDevice, error) {
+	fis, err := ioutil.ReadDir(SysBlock)
+	if err != nil {
+		return nil, err
+	}
+	mounts := mountPoints()
+	var devs []*BlockDevice
+	for _, fi := range fis {
+		dir := filepath.Join(SysBlock, fi.Name())
+		disk := &BlockDevice{
+			Name:      fi.Name(),
+			Path:      filepath.Join(DevDir, fi.Name()),
+			Type:      "disk",
+			Size:      readSysInt(dir, "size") * sectorSize,
+			ReadOnly:  readSys(dir, "ro") == "1",
+			Removable: readSys(dir, "removable") == "1",
+		}
+		if disk.Size == 0 {
+			continue
+		}
+		disk.MountPoint = mounts[disk.Path]
+		devs = append(devs, disk)
+
+		var parts []*BlockDevice
+		subs, _ := ioutil.ReadDir(dir)
+		for _, sub := range subs {
+			pdir := filepath.Join(dir, sub.Name())
+			if !strings.HasPrefix(sub.Name(), disk.Name) ||
+				len(readSys(pdir, "partition")) == 0 {
+				continue
+			}
+			part := &BlockDevice{
+				Name:      sub.Name(),
+				Path:      filepath.Join(DevDir, sub.Name()),
+				Parent:    disk.Name,
+				Type:      "part",
+				Size:      readSysInt(pdir, "size") * sectorSize,
+				ReadOnly:  readSys(pdir, "ro") == "1",
+				Removable: disk.Removable,
+			}
+			part.MountPoint = mounts[part.Path]
+			parts = append(parts, part)
+		}
+		sort.Slice(parts, func(i, j int) bool {
+			return readSysInt(filepath.Join(dir, parts[i].Name),
+				"partition") <
+				readSysInt(filepath.Join(dir, parts[j].Name),
+					"partition")
+		})
+
+		disk.probe()
+		var table *GPT
+		var mbrSig uint32
+		switch disk.PTType {
+		case "gpt":
+			table, _ = ReadGPT(disk.Path)
+		case "dos":
+			mbrSig = readMBRSignature(disk.Path)
+		}
+		for _, part := range parts {
+			part.probe()
+			n := int(readSysInt(filepath.Join(dir, part.Name),
+				"partition"))
+			if table != nil {
+				for _, p := range table.Partitions {
+					if p.Number == n {
+						part.PartUUID = p.GUID.String()
+						part.PartLabel = p.Name
+					}
+				}
+			} else if mbrSig != 0 {
+				part.PartUUID = fmt.Sprintf("%08x-%02x", mbrSig, n)
+			}
+			devs = append(devs, part)
+		}
+	}
+	return devs, nil
+}
+
+// probe the device's partition map or filesystem
+func (d *BlockDevice) probe() {
+	f, err := os.Open(d.Path)
+	if err != nil {
+		return
+	}
+	sniff := make([]byte, superBlockSniffSz)
+	_, err = io.ReadFull(f, sniff)
+	f.Close()
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return
+	}
+	switch magic.IdentifyPartitionMap(sniff) {
+	case "gpt":
+		d.PTType = "gpt"
+		return
+	case "mbr":
+		d.PTType = "dos"
+		return
+	}
+	sb, err := SuperBlock(sniff)
+	if err != nil {
+		return
+	}
+	d.FsType = sb.Kind()
+	d.UUID = sb.ID()
+	d.Label = sb.Label()
+}
+
+func readMBRSignature(dev string) uint32 {
+	f, err := os.Open(dev)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	b := make([]byte, 4)
+	if _, err = f.ReadAt(b, mbrSigOff); err != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+// mountPoints maps device paths to their first mount point.
+func mountPoints() map[string]string {
+	m := make(map[string]string)
+	f, err := os.Open(ProcMounts)
+	if err != nil {
+		return m
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if _, found := m[fields[0]]; !found {
+			m[fields[0]] = unescapeMount(fields[1])
+		}
+	}
+	return m
+}
+
+// unescapeMount replaces the octal escapes of /proc/mounts fields.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b = append(b, byte(c))
+				i += 3
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
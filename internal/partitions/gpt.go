@@ -0,0 +1,212 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package partitions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"unicode/utf16"
+
+	"github.com/platinasystems/go/internal/magic/gpt"
+	"github.com/satori/go.uuid"
+)
+
+var ErrNotGPT = errors.New("not a GUID partition table")
+
+const (
+	gptHeaderMinLen   = 92
+	gptEntryMinLen    = 128
+	gptMaxEntries     = 1024
+	gptNameOff        = 56
+	gptNameLen        = 72
+	gptSniffLen       = 8192
+	gptRequiredAttr   = 1 << 0
+	gptLegacyBootAttr = 1 << 2
+)
+
+// Well known partition type GUIDs
+var GPTTypes = map[string]string{
+	"c12a7328-f81f-11d2-ba4b-00a0c93ec93b": "efi-system",
+	"21686148-6449-6e6f-744e-656564454649": "bios-boot",
+	"0fc63daf-8483-4772-8e79-3d69d8477de4": "linux-filesystem",
+	"0657fd6d-a4ab-43c4-84e5-0933c84b4f4f": "linux-swap",
+	"e6d6d379-f507-44c2-a23c-238f2a3df928": "linux-lvm",
+	"a19d880f-05fc-4d3b-a006-743f0f84911e": "linux-raid",
+	"4f68bce3-e8cd-4db1-96e7-fbcaf984b709": "linux-root-x86-64",
+	"b921b045-1df0-41c3-af44-4c6f280d3fae": "linux-root-arm64",
+	"933ac7e1-2eb4-4f13-b844-0e14e2aef915": "linux-home",
+	"ebd0a0a2-b9e5-4433-87c0-68b6b72699c7": "microsoft-basic-data",
+}
+
+type GPTPartition struct {
+	Number     int // from 1
+	Type       uuid.UUID
+	GUID       uuid.UUID
+	FirstLBA   uint64
+	LastLBA    uint64
+	Attributes uint64
+	Name       string
+}
+
+// TypeName returns the well known name of the partition type, or its GUID.
+func (p *GPTPartition) TypeName() string {
+	if s, found := GPTTypes[p.Type.String()]; found {
+		return s
+	}
+	return p.Type.String()
+}
+
+// Required partitions are needed by the platform firmware.
+func (p *GPTPartition) Required() bool {
+	return p.Attributes&gptRequiredAttr != 0
+}
+
+// LegacyBootable partitions are marked bootable for legacy BIOS.
+func (p *GPTPartition) LegacyBootable() bool {
+	return p.Attributes&gptLegacyBootAttr != 0
+}
+
+type GPT struct {
+	DiskGUID       uuid.UUID
+	BlockSize      int
+	FirstUsableLBA uint64
+	LastUsableLBA  uint64
+	// Backup is true if the primary header or entries are corrupt.
+	Backup     bool
+	Partitions []GPTPartition
+}
+
+// guid converts the mixed endian on disk GUID to a UUID.
+func guid(b []byte) uuid.UUID {
+	var u uuid.UUID
+	binary.BigEndian.PutUint32(u[0:], binary.LittleEndian.Uint32(b[0:]))
+	binary.BigEndian.PutUint16(u[4:], binary.LittleEndian.Uint16(b[4:]))
+	binary.BigEndian.PutUint16(u[6:], binary.LittleEndian.Uint16(b[6:]))
+	copy(u[8:], b[8:16])
+	return u
+}
+
+// ReadGPT returns the GUID partition table of the disk.
+func ReadGPT(dev string) (*GPT, error) {
+	f, err := os.Open(dev)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGPT(f, size)
+}
+
+// ParseGPT returns the GUID partition table of the disk image of the given
+// size. It falls back to the backup header at the last block if the
+// primary is corrupt or wiped.
+func ParseGPT(r io.ReaderAt, size int64) (*GPT, error) {
+	sniff := make([]byte, gptSniffLen)
+	n, err := r.ReadAt(sniff, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	sniff = sniff[:n]
+	// A wiped primary header leaves the protective MBR and backup.
+	if !gpt.ProtectiveMBR(sniff) {
+		return nil, ErrNotGPT
+	}
+	var firstErr error
+	for _, bs := range gpt.HeaderOffs {
+		t, err := parseGPT(r, int64(bs), int64(bs))
+		if err == nil {
+			return t, nil
+		}
+		if firstErr == nil && len(sniff) >= bs+len(gpt.Signature) &&
+			bytes.Equal(sniff[bs:bs+len(gpt.Signature)],
+				[]byte(gpt.Signature)) {
+			firstErr = err
+		}
+		if size >= 2*int64(bs) {
+			last := (size/int64(bs) - 1) * int64(bs)
+			if t, err = parseGPT(r, int64(bs), last); err == nil {
+				t.Backup = true
+				return t, nil
+			}
+		}
+	}
+	if firstErr == nil {
+		firstErr = ErrNotGPT
+	}
+	return nil, firstErr
+}
+
+func parseGPT(r io.ReaderAt, bs, off int64) (*GPT, error) {
+	h := make([]byte, bs)
+	if _, err := r.ReadAt(h, off); err != nil {
+		return nil, err
+	}
+	le := binary.LittleEndian
+	hlen := le.Uint32(h[12:])
+	if !bytes.Equal(h[:8], []byte(gpt.Signature)) ||
+		hlen < gptHeaderMinLen || int64(hlen) > bs {
+		return nil, fmt.Errorf("%#x: invalid GPT header", off)
+	}
+	sum := le.Uint32(h[16:])
+	le.PutUint32(h[16:], 0)
+	if crc32.ChecksumIEEE(h[:hlen]) != sum {
+		return nil, fmt.Errorf("%#x: GPT header checksum error", off)
+	}
+	if uint64(off/bs) != le.Uint64(h[24:]) {
+		return nil, fmt.Errorf("%#x: misplaced GPT header", off)
+	}
+	t := &GPT{
+		DiskGUID:       guid(h[56:]),
+		BlockSize:      int(bs),
+		FirstUsableLBA: le.Uint64(h[40:]),
+		LastUsableLBA:  le.Uint64(h[48:]),
+	}
+	entriesLBA := le.Uint64(h[72:])
+	count := le.Uint32(h[80:])
+	esize := le.Uint32(h[84:])
+	if esize < gptEntryMinLen || esize%8 != 0 || count > gptMaxEntries {
+		return nil, fmt.Errorf("%#x: invalid GPT entries", off)
+	}
+	entries := make([]byte, int(count)*int(esize))
+	if _, err := r.ReadAt(entries, int64(entriesLBA)*bs); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(entries) != le.Uint32(h[88:]) {
+		return nil, fmt.Errorf("%#x: GPT entries checksum error", off)
+	}
+	for i := 0; i < int(count); i++ {
+		e := entries[i*int(esize):]
+		p := GPTPartition{
+			Number:     i + 1,
+			Type:       guid(e[0:]),
+			GUID:       guid(e[16:]),
+			FirstLBA:   le.Uint64(e[32:]),
+			LastLBA:    le.Uint64(e[40:]),
+			Attributes: le.Uint64(e[48:]),
+		}
+		if uuid.Equal(p.Type, uuid.Nil) {
+			continue
+		}
+		name := make([]uint16, 0, gptNameLen/2)
+		for j := 0; j < gptNameLen; j += 2 {
+			c := le.Uint16(e[gptNameOff+j:])
+			if c == 0 {
+				break
+			}
+			name = append(name, c)
+		}
+		p.Name = string(utf16.Decode(name))
+		t.Partitions = append(t.Partitions, p)
+	}
+	return t, nil
+}
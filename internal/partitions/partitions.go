@@ -5,18 +5,27 @@
 package partitions
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 
 	"github.com/platinasystems/go/internal/magic"
+	"github.com/platinasystems/go/internal/magic/swap"
 	"github.com/satori/go.uuid"
 )
 
 var ErrNotFilesystem = errors.New("not a filesystem")
 var ErrNotSupported = errors.New("filesystem feature not supported")
+var ErrTruncated = errors.New("truncated super block")
 
 type superBlock interface {
 	UUID() (uuid.UUID, error)
+	// ID is the UUID as shown by blkid, or "" if none.
+	ID() string
+	Label() string
 	Kind() string
 }
 
@@ -28,40 +37,150 @@ func (sb *unknownSB) UUID() (uuid.UUID, error) {
 	return uuid.Nil, ErrNotSupported
 }
 
+func (sb *unknownSB) ID() string {
+	return ""
+}
+
+func (sb *unknownSB) Label() string {
+	return ""
+}
+
 func (sb *unknownSB) Kind() string {
 	return sb.kind
 }
 
 type ext234 struct {
 	sUUID uuid.UUID
+	label string
 	kind  string
 }
 
 const (
 	ext234SUUIDOff = 0x468
 	ext234SUUIDLen = 16
+	ext234LabelOff = 0x478
+	ext234LabelLen = 16
 )
 
 func (sb *ext234) UUID() (uuid.UUID, error) {
 	return sb.sUUID, nil
 }
 
+func (sb *ext234) ID() string {
+	return sb.sUUID.String()
+}
+
+func (sb *ext234) Label() string {
+	return sb.label
+}
+
 func (sb *ext234) Kind() string {
 	return sb.kind
 }
 
-func ReadSuperBlock(dev string) (superBlock, error) {
-	f, err := os.Open(dev)
+// uuidSB is a filesystem or swap space with a 128 bit UUID and a label.
+type uuidSB struct {
+	sUUID uuid.UUID
+	label string
+	kind  string
+}
+
+const (
+	xfsUUIDOff    = 0x20
+	xfsLabelOff   = 0x6c
+	xfsLabelLen   = 12
+	btrfsUUIDOff  = 0x10020
+	btrfsLabelOff = 0x1012b
+	btrfsLabelLen = 256
+	swapUUIDOff   = 0x40c
+	swapLabelOff  = 0x41c
+	swapLabelLen  = 16
+	uuidLen       = 16
+)
+
+func (sb *uuidSB) UUID() (uuid.UUID, error) {
+	return sb.sUUID, nil
+}
+
+func (sb *uuidSB) ID() string {
+	return sb.sUUID.String()
+}
+
+func (sb *uuidSB) Label() string {
+	return sb.label
+}
+
+func (sb *uuidSB) Kind() string {
+	return sb.kind
+}
+
+// vfatSB has a 32 bit volume serial number instead of a UUID.
+type vfatSB struct {
+	serial uint32
+	label  string
+}
+
+const (
+	vfatFatSz16Off    = 0x16
+	vfatSerialOff     = 0x27
+	vfatLabelOff      = 0x2b
+	vfat32SerialOff   = 0x43
+	vfat32LabelOff    = 0x47
+	vfatLabelLen      = 11
+	vfatNoLabel       = "NO NAME"
+	superBlockSniffSz = 0x11000
+)
+
+func (sb *vfatSB) UUID() (uuid.UUID, error) {
+	return uuid.Nil, ErrNotSupported
+}
+
+func (sb *vfatSB) ID() string {
+	return fmt.Sprintf("%04X-%04X", sb.serial>>16, sb.serial&0xffff)
+}
+
+func (sb *vfatSB) Label() string {
+	return sb.label
+}
+
+func (sb *vfatSB) Kind() string {
+	return "vfat"
+}
+
+// cString returns the NUL terminated or padded string of the field.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// field returns the n bytes at off, or ErrTruncated if s is too short.
+func field(s []byte, off, n int) ([]byte, error) {
+	if off < 0 || n < 0 || off+n > len(s) {
+		return nil, ErrTruncated
+	}
+	return s[off : off+n], nil
+}
+
+func newUUIDSB(s []byte, kind string, uuidOff, labelOff, labelLen int) (*uuidSB, error) {
+	u, err := field(s, uuidOff, uuidLen)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	fsHeader := make([]byte, 0x10000)
-	_, err = f.Read(fsHeader)
+	label, err := field(s, labelOff, labelLen)
 	if err != nil {
 		return nil, err
 	}
+	return &uuidSB{
+		sUUID: uuid.FromBytesOrNil(u),
+		label: cString(label),
+		kind:  kind,
+	}, nil
+}
 
+// SuperBlock returns the filesystem details of the sniffed block device.
+func SuperBlock(fsHeader []byte) (superBlock, error) {
 	partitionMapType := magic.IdentifyPartitionMap(fsHeader)
 	partitionType := magic.IdentifyPartition(fsHeader)
 
@@ -69,12 +188,80 @@ func ReadSuperBlock(dev string) (superBlock, error) {
 		return nil, ErrNotFilesystem
 	}
 
-	if partitionType == "ext2" || partitionType == "ext3" || partitionType == "ext4" {
+	switch partitionType {
+	case "ext2", "ext3", "ext4":
+		u, err := field(fsHeader, ext234SUUIDOff, ext234SUUIDLen)
+		if err != nil {
+			return nil, err
+		}
+		label, err := field(fsHeader, ext234LabelOff, ext234LabelLen)
+		if err != nil {
+			return nil, err
+		}
 		sb := &ext234{}
-		sb.sUUID = uuid.FromBytesOrNil(fsHeader[ext234SUUIDOff : ext234SUUIDOff+ext234SUUIDLen])
+		sb.sUUID = uuid.FromBytesOrNil(u)
+		sb.label = cString(label)
 		sb.kind = partitionType
 		return sb, nil
+	case "xfs":
+		return newUUIDSB(fsHeader, partitionType, xfsUUIDOff,
+			xfsLabelOff, xfsLabelLen)
+	case "btrfs":
+		return newUUIDSB(fsHeader, partitionType, btrfsUUIDOff,
+			btrfsLabelOff, btrfsLabelLen)
+	case "swap":
+		ps := swap.PageSize(fsHeader)
+		m, err := field(fsHeader, ps-swap.MagicLen, swap.MagicLen)
+		if err != nil {
+			return nil, err
+		}
+		if string(m) != swap.Magics[0] {
+			// version 0 has neither UUID nor label
+			return &unknownSB{kind: partitionType}, nil
+		}
+		return newUUIDSB(fsHeader, partitionType, swapUUIDOff,
+			swapLabelOff, swapLabelLen)
+	case "vfat":
+		fatSz16, err := field(fsHeader, vfatFatSz16Off, 2)
+		if err != nil {
+			return nil, err
+		}
+		serialOff, labelOff := vfatSerialOff, vfatLabelOff
+		if binary.LittleEndian.Uint16(fatSz16) == 0 {
+			serialOff, labelOff = vfat32SerialOff, vfat32LabelOff
+		}
+		serial, err := field(fsHeader, serialOff, 4)
+		if err != nil {
+			return nil, err
+		}
+		label, err := field(fsHeader, labelOff, vfatLabelLen)
+		if err != nil {
+			return nil, err
+		}
+		sb := &vfatSB{
+			serial: binary.LittleEndian.Uint32(serial),
+			label:  string(bytes.TrimRight(label, " ")),
+		}
+		if sb.label == vfatNoLabel {
+			sb.label = ""
+		}
+		return sb, nil
 	}
-
+	// squashfs and iso9660 have neither UUID nor label here
 	return &unknownSB{kind: partitionType}, nil
 }
+
+func ReadSuperBlock(dev string) (superBlock, error) {
+	f, err := os.Open(dev)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// sniff through the btrfs super block; zero fill small devices
+	fsHeader := make([]byte, superBlockSniffSz)
+	_, err = io.ReadFull(f, fsHeader)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return SuperBlock(fsHeader)
+}
@@ -0,0 +1,249 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package partitions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/satori/go.uuid"
+)
+
+const (
+	testDiskGUID = "5a8c3e2f-1b4d-4e6a-9f70-112233445566"
+	testPartGUID = "9d3e1f20-7c6b-4a58-8e91-aabbccddeeff"
+	linuxFsType  = "0fc63daf-8483-4772-8e79-3d69d8477de4"
+	testBlocks   = 256
+)
+
+// putGUID is the inverse of guid.
+func putGUID(b []byte, s string) {
+	u := uuid.FromStringOrNil(s)
+	binary.LittleEndian.PutUint32(b[0:], binary.BigEndian.Uint32(u[0:]))
+	binary.LittleEndian.PutUint16(b[4:], binary.BigEndian.Uint16(u[4:]))
+	binary.LittleEndian.PutUint16(b[6:], binary.BigEndian.Uint16(u[6:]))
+	copy(b[8:], u[8:])
+}
+
+// newGPT returns a disk image with one partition named "root".
+func newGPT() []byte {
+	const bs = 512
+	disk := make([]byte, testBlocks*bs)
+	disk[0x1be+4] = 0xee
+	disk[0x1fe], disk[0x1ff] = 0x55, 0xaa
+
+	entries := make([]byte, 4*128)
+	putGUID(entries[0:], linuxFsType)
+	putGUID(entries[16:], testPartGUID)
+	binary.LittleEndian.PutUint64(entries[32:], 34)
+	binary.LittleEndian.PutUint64(entries[40:], 93)
+	binary.LittleEndian.PutUint64(entries[48:], 1<<2)
+	for i, c := range utf16.Encode([]rune("root")) {
+		binary.LittleEndian.PutUint16(entries[56+2*i:], c)
+	}
+
+	header := func(self, alt, entriesLBA uint64) []byte {
+		h := make([]byte, 92)
+		copy(h, "EFI PART")
+		binary.LittleEndian.PutUint32(h[8:], 0x10000)
+		binary.LittleEndian.PutUint32(h[12:], 92)
+		binary.LittleEndian.PutUint64(h[24:], self)
+		binary.LittleEndian.PutUint64(h[32:], alt)
+		binary.LittleEndian.PutUint64(h[40:], 34)
+		binary.LittleEndian.PutUint64(h[48:], testBlocks-34)
+		putGUID(h[56:], testDiskGUID)
+		binary.LittleEndian.PutUint64(h[72:], entriesLBA)
+		binary.LittleEndian.PutUint32(h[80:], 4)
+		binary.LittleEndian.PutUint32(h[84:], 128)
+		binary.LittleEndian.PutUint32(h[88:],
+			crc32.ChecksumIEEE(entries))
+		binary.LittleEndian.PutUint32(h[16:], crc32.ChecksumIEEE(h))
+		return h
+	}
+	copy(disk[bs:], header(1, testBlocks-1, 2))
+	copy(disk[2*bs:], entries)
+	copy(disk[(testBlocks-1)*bs:], header(testBlocks-1, 1, testBlocks-2))
+	copy(disk[(testBlocks-2)*bs:], entries)
+	return disk
+}
+
+func TestGPT(t *testing.T) {
+	disk := newGPT()
+	for i, backup := range []bool{false, true, true} {
+		switch i {
+		case 1:
+			// corrupt the primary entries
+			disk[2*512+32]++
+		case 2:
+			// wipe the primary header
+			copy(disk[512:1024], make([]byte, 512))
+		}
+		g, err := ParseGPT(bytes.NewReader(disk), int64(len(disk)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.Backup != backup {
+			t.Error("backup", g.Backup)
+		}
+		if g.DiskGUID.String() != testDiskGUID {
+			t.Error("disk GUID", g.DiskGUID)
+		}
+		if len(g.Partitions) != 1 {
+			t.Fatal("partitions", g.Partitions)
+		}
+		p := g.Partitions[0]
+		if p.Number != 1 || p.Name != "root" ||
+			p.GUID.String() != testPartGUID ||
+			p.TypeName() != "linux-filesystem" ||
+			p.FirstLBA != 34 || p.LastLBA != 93 ||
+			!p.LegacyBootable() || p.Required() {
+			t.Errorf("partition %+v", p)
+		}
+	}
+
+	if _, err := SuperBlock(newGPT()[:0x11000]); err != ErrNotFilesystem {
+		t.Error("GPT disk superblock", err)
+	}
+	disk[0x1be+4] = 0x83
+	if _, err := ParseGPT(bytes.NewReader(disk), int64(len(disk))); err != ErrNotGPT {
+		t.Error("MBR disk", err)
+	}
+}
+
+func TestSuperBlock(t *testing.T) {
+	const (
+		fsUUID = "0e5a0c0b-3f59-4c4e-8b3a-5d2f8c9e1a7b"
+		label  = "goes"
+	)
+	u := uuid.FromStringOrNil(fsUUID)
+	sniff := func(magicOff int, magic string, uuidOff, labelOff int) []byte {
+		b := make([]byte, superBlockSniffSz)
+		copy(b[magicOff:], magic)
+		copy(b[uuidOff:], u[:])
+		copy(b[labelOff:], label)
+		return b
+	}
+	fat32 := sniff(0x52, "FAT32   ", 0x200, vfat32LabelOff)
+	fat32[0] = 0xeb
+	binary.LittleEndian.PutUint32(fat32[vfat32SerialOff:], 0x1234abcd)
+	copy(fat32[vfat32LabelOff+len(label):], "       ")
+	for _, tc := range []struct {
+		sniff []byte
+		kind  string
+		id    string
+		label string
+	}{
+		{sniff(0, "XFSB", xfsUUIDOff, xfsLabelOff), "xfs", fsUUID, label},
+		{sniff(0x10040, "_BHRfS_M", btrfsUUIDOff, btrfsLabelOff),
+			"btrfs", fsUUID, label},
+		{sniff(0xff6, "SWAPSPACE2", swapUUIDOff, swapLabelOff),
+			"swap", fsUUID, label},
+		{sniff(0, "hsqs", 0x200, 0x300), "squashfs", "", ""},
+		{fat32, "vfat", "1234-ABCD", label},
+	} {
+		sb, err := SuperBlock(tc.sniff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sb.Kind() != tc.kind || sb.ID() != tc.id ||
+			sb.Label() != tc.label {
+			t.Errorf("%s: got %s %q %q", tc.kind, sb.Kind(), sb.ID(),
+				sb.Label())
+		}
+	}
+}
+
+func TestTruncatedSuperBlock(t *testing.T) {
+	for _, tc := range []struct {
+		kind  string
+		sniff []byte
+	}{
+		{"xfs", []byte("XFSB")},
+		{"btrfs", append(make([]byte, 0x10040), "_BHRfS_M"...)},
+		{"ext2", append(append(make([]byte, 0x438), 0x53, 0xef),
+			make([]byte, 0x36)...)},
+	} {
+		_, err := SuperBlock(tc.sniff)
+		if err != ErrTruncated {
+			t.Errorf("%s: got %v", tc.kind, err)
+		}
+	}
+}
+
+func TestBlockDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "partitions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(sys, dev, mounts string) {
+		SysBlock, DevDir, ProcMounts = sys, dev, mounts
+	}(SysBlock, DevDir, ProcMounts)
+	SysBlock = filepath.Join(dir, "sys")
+	DevDir = filepath.Join(dir, "dev")
+	ProcMounts = filepath.Join(dir, "mounts")
+
+	xfs := make([]byte, superBlockSniffSz)
+	copy(xfs, "XFSB")
+	copy(xfs[xfsUUIDOff:], uuid.FromStringOrNil(testDiskGUID).Bytes())
+	copy(xfs[xfsLabelOff:], "data")
+	for name, content := range map[string]string{
+		"sys/sda/size":           "256",
+		"sys/sda/ro":             "0",
+		"sys/sda/removable":      "1",
+		"sys/sda/sda1/partition": "1",
+		"sys/sda/sda1/size":      "60",
+		"sys/loop0/size":         "0",
+		"dev/sda":                string(newGPT()),
+		"dev/sda1":               string(xfs),
+		"mounts":                 DevDir + "/sda1 /mnt/my\\040data xfs rw 0 0\n",
+	} {
+		fn := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	devs, err := BlockDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 2 {
+		t.Fatal("devices", devs)
+	}
+	disk, part := devs[0], devs[1]
+	if disk.Name != "sda" || disk.PTType != "gpt" ||
+		disk.Size != 256*512 || !disk.Removable {
+		t.Errorf("disk %+v", disk)
+	}
+	if part.Parent != "sda" || part.FsType != "xfs" ||
+		part.UUID != testDiskGUID || part.Label != "data" ||
+		part.PartUUID != testPartGUID || part.PartLabel != "root" ||
+		part.MountPoint != "/mnt/my data" {
+		t.Errorf("partition %+v", part)
+	}
+	for _, spec := range []string{
+		"UUID=" + testDiskGUID,
+		"LABEL=data",
+		"PARTUUID=" + testPartGUID,
+		"PARTLABEL=root",
+	} {
+		if fn, err := Resolve(spec); err != nil || fn != part.Path {
+			t.Error(spec, fn, err)
+		}
+	}
+	if _, err = Resolve("LABEL=missing"); err == nil {
+		t.Error("missing label resolved")
+	}
+}
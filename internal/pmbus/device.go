@@ -0,0 +1,366 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pmbus
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/i2c"
+)
+
+var ErrPEC = errors.New("PEC mismatch")
+
+// Device is a PMBus slave.
+type Device struct {
+	Bus  int
+	Addr int
+	// PEC appends the packet error code to writes and checks that of
+	// reads; the transactions are I2C block transfers with the code as
+	// their last byte.
+	PEC bool
+	// Backend of the device's bus, i2cbus.Default if nil
+	Backend i2cbus.Backend
+}
+
+func (d *Device) do(rw i2c.RW, c Cmd, size i2c.SMBusSize,
+	data *i2c.SMBusData) error {
+	b := d.Backend
+	if b == nil {
+		b = i2cbus.Default
+	}
+	err := b.Do(d.Bus, d.Addr, rw, uint8(c), size, data)
+	if err != nil {
+		return fmt.Errorf("%d.%#x %v: %v", d.Bus, d.Addr, c, err)
+	}
+	return nil
+}
+
+func (d *Device) wr() byte { return byte(d.Addr << 1) }
+func (d *Device) rd() byte { return byte(d.Addr<<1 | 1) }
+
+// read the fixed length data of the command.
+func (d *Device) read(c Cmd, n int) ([]byte, error) {
+	var data i2c.SMBusData
+	if !d.PEC {
+		size := i2c.ByteData
+		if n == 2 {
+			size = i2c.WordData
+		}
+		if err := d.do(i2c.Read, c, size, &data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	}
+	data[0] = byte(n + 1)
+	if err := d.do(i2c.Read, c, i2c.I2CBlockData, &data); err != nil {
+		return nil, err
+	}
+	b := data[1 : 1+n]
+	pec := PEC(append([]byte{d.wr(), byte(c), d.rd()}, b...)...)
+	if pec != data[1+n] {
+		return nil, fmt.Errorf("%d.%#x %v: %v", d.Bus, d.Addr, c, ErrPEC)
+	}
+	return b, nil
+}
+
+// write the data of the command.
+func (d *Device) write(c Cmd, size i2c.SMBusSize, b []byte) error {
+	var data i2c.SMBusData
+	if !d.PEC {
+		switch size {
+		case i2c.Byte:
+			return d.do(i2c.Write, c, i2c.Byte, &data)
+		case i2c.BlockData:
+			data[0] = byte(len(b))
+			copy(data[1:], b)
+		default:
+			copy(data[:], b)
+		}
+		return d.do(i2c.Write, c, size, &data)
+	}
+	if size == i2c.BlockData {
+		b = append([]byte{byte(len(b))}, b...)
+	}
+	if len(b)+1 > i2c.BlockMax {
+		return fmt.Errorf("%v: too long", c)
+	}
+	data[0] = byte(len(b) + 1)
+	copy(data[1:], b)
+	data[1+len(b)] = PEC(append([]byte{d.wr(), byte(c)}, b...)...)
+	return d.do(i2c.Write, c, i2c.I2CBlockData, &data)
+}
+
+func (d *Device) ReadByteData(c Cmd) (byte, error) {
+	b, err := d.read(c, 1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (d *Device) ReadWordData(c Cmd) (uint16, error) {
+	b, err := d.read(c, 2)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(b[0]) | uint16(b[1])<<8, nil
+}
+
+// ReadBlockData returns the data following the byte count. With PEC, the
+// block may have at most 30 bytes.
+func (d *Device) ReadBlockData(c Cmd) ([]byte, error) {
+	var data i2c.SMBusData
+	if !d.PEC {
+		if err := d.do(i2c.Read, c, i2c.BlockData, &data); err != nil {
+			return nil, err
+		}
+		n := int(data[0])
+		if n > i2c.BlockMax {
+			return nil, fmt.Errorf("%v: count %d: %v", c, n,
+				ErrRange)
+		}
+		return append([]byte{}, data[1:1+n]...), nil
+	}
+	data[0] = i2c.BlockMax
+	if err := d.do(i2c.Read, c, i2c.I2CBlockData, &data); err != nil {
+		return nil, err
+	}
+	n := int(data[1])
+	if n > i2c.BlockMax-2 {
+		return nil, fmt.Errorf("%v: count %d: %v", c, n, ErrRange)
+	}
+	b := data[2 : 2+n]
+	pec := PEC(append([]byte{d.wr(), byte(c), d.rd()}, data[1:2+n]...)...)
+	if pec != data[2+n] {
+		return nil, fmt.Errorf("%d.%#x %v: %v", d.Bus, d.Addr, c, ErrPEC)
+	}
+	return append([]byte{}, b...), nil
+}
+
+// Send the command, e.g. CLEAR_FAULTS, without data.
+func (d *Device) Send(c Cmd) error {
+	return d.write(c, i2c.Byte, nil)
+}
+
+func (d *Device) WriteByteData(c Cmd, v byte) error {
+	return d.write(c, i2c.ByteData, []byte{v})
+}
+
+func (d *Device) WriteWordData(c Cmd, v uint16) error {
+	return d.write(c, i2c.WordData, []byte{byte(v), byte(v >> 8)})
+}
+
+func (d *Device) WriteBlockData(c Cmd, b []byte) error {
+	return d.write(c, i2c.BlockData, b)
+}
+
+// SetPage of the following paged commands.
+func (d *Device) SetPage(page uint8) error {
+	return d.WriteByteData(Page, page)
+}
+
+// Mode of the output voltage commands of the current page.
+func (d *Device) Mode() (Mode, error) {
+	b, err := d.ReadByteData(VoutMode)
+	return Mode(b), err
+}
+
+// Value of a command.
+type Value struct {
+	Cmd
+	Info
+	// Raw bytes, least significant first
+	Raw []byte
+	// Real value of Linear and Vout formats
+	Real float64
+	// Faults of Status format
+	Faults []string
+}
+
+func (v *Value) String() string {
+	switch v.Format {
+	case Linear, Vout:
+		s := strconv.FormatFloat(v.Real, 'f', 3, 64)
+		if len(v.Units) > 0 {
+			s += " " + v.Units
+		}
+		return s
+	case Ascii:
+		return Text(v.Raw)
+	case Status:
+		s := "0x" + hex(v.Raw)
+		if len(v.Faults) > 0 {
+			s += " " + strings.Join(v.Faults, ",")
+		}
+		return s
+	}
+	if v.Size == Block {
+		var ss []string
+		for _, b := range v.Raw {
+			ss = append(ss, fmt.Sprintf("%02x", b))
+		}
+		return strings.Join(ss, " ")
+	}
+	return "0x" + hex(v.Raw)
+}
+
+// hex of the least significant first bytes.
+func hex(b []byte) string {
+	var s string
+	for i := len(b) - 1; i >= 0; i-- {
+		s += fmt.Sprintf("%02x", b[i])
+	}
+	return s
+}
+
+// Text of the block without its padding of NUL, 0xff, space or '#'.
+func Text(b []byte) string {
+	return strings.Trim(string(b), "\x00\xff #")
+}
+
+// Read and decode the standard command.
+func (d *Device) Read(c Cmd) (*Value, error) {
+	info, found := Commands[c]
+	if !found {
+		return nil, fmt.Errorf("%v: unknown", c)
+	}
+	if info.Access&R == 0 {
+		return nil, fmt.Errorf("%v: write only", c)
+	}
+	v := &Value{Cmd: c, Info: info}
+	var err error
+	switch info.Size {
+	case Byte:
+		v.Raw, err = d.read(c, 1)
+	case Word:
+		v.Raw, err = d.read(c, 2)
+	case Block:
+		v.Raw, err = d.ReadBlockData(c)
+	default:
+		err = fmt.Errorf("%v: unsupported", c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var u uint16
+	for i := len(v.Raw) - 1; i >= 0 && i < 2; i-- {
+		u = u<<8 | uint16(v.Raw[i])
+	}
+	switch info.Format {
+	case Linear:
+		v.Real = Linear11(u)
+	case Vout:
+		mode, err := d.Mode()
+		if err != nil {
+			return nil, err
+		}
+		if v.Real, err = mode.Linear16(u); err != nil {
+			return nil, fmt.Errorf("%v: %v", c, err)
+		}
+	case Status:
+		v.Faults = Faults(c, u)
+	}
+	return v, nil
+}
+
+// Write the standard command with the encoded value, a number or string
+// for Block commands. Send commands have an empty value.
+func (d *Device) Write(c Cmd, s string) error {
+	info, found := Commands[c]
+	if !found {
+		return fmt.Errorf("%v: unknown", c)
+	}
+	if info.Access&W == 0 {
+		return fmt.Errorf("%v: read only", c)
+	}
+	switch info.Size {
+	case Send:
+		if len(s) > 0 {
+			return fmt.Errorf("%v: %s: unexpected", c, s)
+		}
+		return d.Send(c)
+	case Byte:
+		u, err := strconv.ParseUint(s, 0, 8)
+		if err != nil {
+			return fmt.Errorf("%v: %v", c, err)
+		}
+		return d.WriteByteData(c, uint8(u))
+	case Word:
+		var u uint16
+		switch info.Format {
+		case Linear, Vout:
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("%v: %v", c, err)
+			}
+			if info.Format == Linear {
+				u, err = ToLinear11(f)
+			} else {
+				var mode Mode
+				if mode, err = d.Mode(); err == nil {
+					u, err = mode.ToLinear16(f)
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("%v: %v", c, err)
+			}
+		default:
+			u64, err := strconv.ParseUint(s, 0, 16)
+			if err != nil {
+				return fmt.Errorf("%v: %v", c, err)
+			}
+			u = uint16(u64)
+		}
+		return d.WriteWordData(c, u)
+	case Block:
+		return d.WriteBlockData(c, []byte(s))
+	}
+	return fmt.Errorf("%v: unsupported", c)
+}
+
+// Faults of the current page from STATUS_WORD, or STATUS_BYTE if that isn't
+// supported, and the STATUS registers of its summary bits, e.g.
+// STATUS_VOUT.VOUT_OV_FAULT.
+func (d *Device) Faults() ([]string, error) {
+	summary := StatusWord
+	u, err := d.ReadWordData(StatusWord)
+	if err != nil {
+		b, berr := d.ReadByteData(StatusByte)
+		if berr != nil {
+			return nil, err
+		}
+		summary, u = StatusByte, uint16(b)
+	}
+	var faults []string
+	for _, name := range Faults(summary, u) {
+		c, found := Detail[name]
+		if !found {
+			faults = append(faults, summary.String()+"."+name)
+			continue
+		}
+		details := []Cmd{c}
+		if c == StatusFans12 {
+			details = append(details, StatusFans34)
+		}
+		n := len(faults)
+		for _, c := range details {
+			b, err := d.ReadByteData(c)
+			if err != nil {
+				continue
+			}
+			for _, fault := range Faults(c, uint16(b)) {
+				faults = append(faults, c.String()+"."+fault)
+			}
+		}
+		if len(faults) == n {
+			faults = append(faults, summary.String()+"."+name)
+		}
+	}
+	return faults, nil
+}
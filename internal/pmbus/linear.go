@@ -0,0 +1,162 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pmbus
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrVid    = errors.New("VID output voltage mode")
+	ErrDirect = errors.New("direct output voltage mode")
+	ErrRange  = errors.New("out of range")
+)
+
+// Linear11 decodes the signed 5-bit exponent and 11-bit mantissa.
+func Linear11(v uint16) float64 {
+	n := int(int16(v) >> 11)
+	y := int(int16(v<<5) >> 5)
+	return math.Ldexp(float64(y), n)
+}
+
+// ToLinear11 encodes the value with the smallest exponent that fits its
+// mantissa for the best precision.
+func ToLinear11(f float64) (uint16, error) {
+	for n := -16; n < 16; n++ {
+		y := math.Floor(math.Ldexp(f, -n) + 0.5)
+		if y >= -1024 && y < 1024 {
+			return uint16(n)<<11 | uint16(int(y))&0x7ff, nil
+		}
+	}
+	return 0, ErrRange
+}
+
+// Mode is the VOUT_MODE of the LINEAR16, VID, direct or IEEE half
+// precision output voltage formats.
+type Mode uint8
+
+const (
+	ModeLinear Mode = 0 << 5
+	ModeVid    Mode = 1 << 5
+	ModeDirect Mode = 2 << 5
+	ModeHalf   Mode = 3 << 5
+)
+
+// Format of the output voltage commands.
+func (m Mode) Format() Mode { return m & 0xe0 }
+
+// Exponent of the LINEAR16 format, the signed low five bits.
+func (m Mode) Exponent() int { return int(int8(m<<3) >> 3) }
+
+func (m Mode) String() string {
+	switch m.Format() {
+	case ModeLinear:
+		return fmt.Sprintf("linear 2^%d", m.Exponent())
+	case ModeVid:
+		return fmt.Sprintf("vid %#x", uint8(m&0x1f))
+	case ModeDirect:
+		return "direct"
+	case ModeHalf:
+		return "half"
+	}
+	return fmt.Sprintf("%#02x", uint8(m))
+}
+
+// Linear16 decodes the output voltage of the mode.
+func (m Mode) Linear16(v uint16) (float64, error) {
+	switch m.Format() {
+	case ModeLinear:
+		return math.Ldexp(float64(v), m.Exponent()), nil
+	case ModeHalf:
+		return Half(v), nil
+	case ModeVid:
+		return 0, ErrVid
+	}
+	return 0, ErrDirect
+}
+
+// ToLinear16 encodes the output voltage of the mode.
+func (m Mode) ToLinear16(f float64) (uint16, error) {
+	switch m.Format() {
+	case ModeLinear:
+		y := math.Floor(math.Ldexp(f, -m.Exponent()) + 0.5)
+		if y < 0 || y > math.MaxUint16 {
+			return 0, ErrRange
+		}
+		return uint16(y), nil
+	case ModeHalf:
+		return ToHalf(f), nil
+	case ModeVid:
+		return 0, ErrVid
+	}
+	return 0, ErrDirect
+}
+
+// Half decodes the IEEE 754 half precision value.
+func Half(v uint16) float64 {
+	sign := 1.0
+	if v&0x8000 != 0 {
+		sign = -1
+	}
+	e := int(v>>10) & 0x1f
+	f := float64(v & 0x3ff)
+	switch e {
+	case 0:
+		return sign * math.Ldexp(f, -24)
+	case 0x1f:
+		if f != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	}
+	return sign * math.Ldexp(f+1024, e-25)
+}
+
+// ToHalf encodes the IEEE 754 half precision value rounded toward zero.
+func ToHalf(f float64) uint16 {
+	var sign uint16
+	if math.Signbit(f) {
+		sign, f = 0x8000, -f
+	}
+	switch {
+	case math.IsNaN(f):
+		return 0x7e00
+	case f >= 65520:
+		return sign | 0x7c00
+	case f < math.Ldexp(1, -14):
+		return sign | uint16(math.Ldexp(f, 24))
+	}
+	frac, e := math.Frexp(f)
+	return sign | uint16(e+14)<<10 | uint16(math.Ldexp(frac, 11))&0x3ff
+}
+
+// Direct are the coefficients of the DIRECT format, e.g. from the
+// COEFFICIENTS command or the device's data sheet.
+type Direct struct {
+	M int16
+	B int16
+	R int8
+}
+
+// Decode the value, X = (Y * 10^-R - B) / M.
+func (c Direct) Decode(v uint16) float64 {
+	if c.M == 0 {
+		return math.NaN()
+	}
+	y := float64(int16(v))
+	return (y*math.Pow10(-int(c.R)) - float64(c.B)) / float64(c.M)
+}
+
+// Encode the value, Y = (M * X + B) * 10^R.
+func (c Direct) Encode(f float64) (uint16, error) {
+	y := math.Floor((float64(c.M)*f+float64(c.B))*
+		math.Pow10(int(c.R)) + 0.5)
+	if y < math.MinInt16 || y > math.MaxInt16 {
+		return 0, ErrRange
+	}
+	return uint16(int16(y)), nil
+}
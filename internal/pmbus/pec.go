@@ -0,0 +1,22 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pmbus
+
+// PEC is the SMBus packet error code, a CRC-8 of polynomial x^8+x^2+x+1,
+// of all bytes of the transaction, including the addresses.
+func PEC(b ...byte) byte {
+	var crc byte
+	for _, v := range b {
+		crc ^= v
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
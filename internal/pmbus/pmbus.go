@@ -0,0 +1,408 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package pmbus provides the PMBus 1.3 standard command table, data formats,
+// packet error codes and status decoding along with a Device to read and
+// write any PMBus power supply, sequencer or regulator through an
+// i2cbus.Backend.
+package pmbus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cmd is a PMBus command code.
+type Cmd uint8
+
+const (
+	Page                  Cmd = 0x00
+	Operation             Cmd = 0x01
+	OnOffConfig           Cmd = 0x02
+	ClearFaults           Cmd = 0x03
+	Phase                 Cmd = 0x04
+	PagePlusWrite         Cmd = 0x05
+	PagePlusRead          Cmd = 0x06
+	WriteProtect          Cmd = 0x10
+	StoreDefaultAll       Cmd = 0x11
+	RestoreDefaultAll     Cmd = 0x12
+	StoreDefaultCode      Cmd = 0x13
+	RestoreDefaultCode    Cmd = 0x14
+	StoreUserAll          Cmd = 0x15
+	RestoreUserAll        Cmd = 0x16
+	StoreUserCode         Cmd = 0x17
+	RestoreUserCode       Cmd = 0x18
+	Capability            Cmd = 0x19
+	Query                 Cmd = 0x1a
+	SmbalertMask          Cmd = 0x1b
+	VoutMode              Cmd = 0x20
+	VoutCommand           Cmd = 0x21
+	VoutTrim              Cmd = 0x22
+	VoutCalOffset         Cmd = 0x23
+	VoutMax               Cmd = 0x24
+	VoutMarginHigh        Cmd = 0x25
+	VoutMarginLow         Cmd = 0x26
+	VoutTransitionRate    Cmd = 0x27
+	VoutDroop             Cmd = 0x28
+	VoutScaleLoop         Cmd = 0x29
+	VoutScaleMonitor      Cmd = 0x2a
+	VoutMin               Cmd = 0x2b
+	Coefficients          Cmd = 0x30
+	PoutMax               Cmd = 0x31
+	MaxDuty               Cmd = 0x32
+	FrequencySwitch       Cmd = 0x33
+	PowerMode             Cmd = 0x34
+	VinOn                 Cmd = 0x35
+	VinOff                Cmd = 0x36
+	Interleave            Cmd = 0x37
+	IoutCalGain           Cmd = 0x38
+	IoutCalOffset         Cmd = 0x39
+	FanConfig12           Cmd = 0x3a
+	FanCommand1           Cmd = 0x3b
+	FanCommand2           Cmd = 0x3c
+	FanConfig34           Cmd = 0x3d
+	FanCommand3           Cmd = 0x3e
+	FanCommand4           Cmd = 0x3f
+	VoutOvFaultLimit      Cmd = 0x40
+	VoutOvFaultResponse   Cmd = 0x41
+	VoutOvWarnLimit       Cmd = 0x42
+	VoutUvWarnLimit       Cmd = 0x43
+	VoutUvFaultLimit      Cmd = 0x44
+	VoutUvFaultResponse   Cmd = 0x45
+	IoutOcFaultLimit      Cmd = 0x46
+	IoutOcFaultResponse   Cmd = 0x47
+	IoutOcLvFaultLimit    Cmd = 0x48
+	IoutOcLvFaultResponse Cmd = 0x49
+	IoutOcWarnLimit       Cmd = 0x4a
+	IoutUcFaultLimit      Cmd = 0x4b
+	IoutUcFaultResponse   Cmd = 0x4c
+	OtFaultLimit          Cmd = 0x4f
+	OtFaultResponse       Cmd = 0x50
+	OtWarnLimit           Cmd = 0x51
+	UtWarnLimit           Cmd = 0x52
+	UtFaultLimit          Cmd = 0x53
+	UtFaultResponse       Cmd = 0x54
+	VinOvFaultLimit       Cmd = 0x55
+	VinOvFaultResponse    Cmd = 0x56
+	VinOvWarnLimit        Cmd = 0x57
+	VinUvWarnLimit        Cmd = 0x58
+	VinUvFaultLimit       Cmd = 0x59
+	VinUvFaultResponse    Cmd = 0x5a
+	IinOcFaultLimit       Cmd = 0x5b
+	IinOcFaultResponse    Cmd = 0x5c
+	IinOcWarnLimit        Cmd = 0x5d
+	PowerGoodOn           Cmd = 0x5e
+	PowerGoodOff          Cmd = 0x5f
+	TonDelay              Cmd = 0x60
+	TonRise               Cmd = 0x61
+	TonMaxFaultLimit      Cmd = 0x62
+	TonMaxFaultResponse   Cmd = 0x63
+	ToffDelay             Cmd = 0x64
+	ToffFall              Cmd = 0x65
+	ToffMaxWarnLimit      Cmd = 0x66
+	PoutOpFaultLimit      Cmd = 0x68
+	PoutOpFaultResponse   Cmd = 0x69
+	PoutOpWarnLimit       Cmd = 0x6a
+	PinOpWarnLimit        Cmd = 0x6b
+	StatusByte            Cmd = 0x78
+	StatusWord            Cmd = 0x79
+	StatusVout            Cmd = 0x7a
+	StatusIout            Cmd = 0x7b
+	StatusInput           Cmd = 0x7c
+	StatusTemperature     Cmd = 0x7d
+	StatusCml             Cmd = 0x7e
+	StatusOther           Cmd = 0x7f
+	StatusMfrSpecific     Cmd = 0x80
+	StatusFans12          Cmd = 0x81
+	StatusFans34          Cmd = 0x82
+	ReadEin               Cmd = 0x86
+	ReadEout              Cmd = 0x87
+	ReadVin               Cmd = 0x88
+	ReadIin               Cmd = 0x89
+	ReadVcap              Cmd = 0x8a
+	ReadVout              Cmd = 0x8b
+	ReadIout              Cmd = 0x8c
+	ReadTemperature1      Cmd = 0x8d
+	ReadTemperature2      Cmd = 0x8e
+	ReadTemperature3      Cmd = 0x8f
+	ReadFanSpeed1         Cmd = 0x90
+	ReadFanSpeed2         Cmd = 0x91
+	ReadFanSpeed3         Cmd = 0x92
+	ReadFanSpeed4         Cmd = 0x93
+	ReadDutyCycle         Cmd = 0x94
+	ReadFrequency         Cmd = 0x95
+	ReadPout              Cmd = 0x96
+	ReadPin               Cmd = 0x97
+	PmbusRevision         Cmd = 0x98
+	MfrId                 Cmd = 0x99
+	MfrModel              Cmd = 0x9a
+	MfrRevision           Cmd = 0x9b
+	MfrLocation           Cmd = 0x9c
+	MfrDate               Cmd = 0x9d
+	MfrSerial             Cmd = 0x9e
+	AppProfileSupport     Cmd = 0x9f
+	MfrVinMin             Cmd = 0xa0
+	MfrVinMax             Cmd = 0xa1
+	MfrIinMax             Cmd = 0xa2
+	MfrPinMax             Cmd = 0xa3
+	MfrVoutMin            Cmd = 0xa4
+	MfrVoutMax            Cmd = 0xa5
+	MfrIoutMax            Cmd = 0xa6
+	MfrPoutMax            Cmd = 0xa7
+	MfrTambientMax        Cmd = 0xa8
+	MfrTambientMin        Cmd = 0xa9
+	MfrEfficiencyLl       Cmd = 0xaa
+	MfrEfficiencyHl       Cmd = 0xab
+	MfrPinAccuracy        Cmd = 0xac
+	IcDeviceId            Cmd = 0xad
+	IcDeviceRev           Cmd = 0xae
+	UserData00            Cmd = 0xb0
+	UserData15            Cmd = 0xbf
+	MfrMaxTemp1           Cmd = 0xc0
+	MfrMaxTemp2           Cmd = 0xc1
+	MfrMaxTemp3           Cmd = 0xc2
+	MfrSpecific00         Cmd = 0xd0
+	MfrSpecific45         Cmd = 0xfd
+	MfrSpecificCommandExt Cmd = 0xfe
+	PmbusCommandExt       Cmd = 0xff
+)
+
+// Size of the command's SMBus transaction.
+type Size uint8
+
+const (
+	Unsupported Size = iota
+	// Send is a send byte of just the command code
+	Send
+	Byte
+	Word
+	Block
+	// Call is a block process call, e.g. QUERY
+	Call
+)
+
+// Format of the command's data.
+type Format uint8
+
+const (
+	Raw Format = iota
+	// Linear is the 5-bit exponent, 11-bit mantissa LINEAR11 format
+	Linear
+	// Vout is the LINEAR16 mantissa of the VOUT_MODE exponent
+	Vout
+	// Status are the fault bits decoded by Faults
+	Status
+	Ascii
+)
+
+// Access of the command.
+type Access uint8
+
+const (
+	R Access = 1 << iota
+	W
+	RW = R | W
+)
+
+// Info of a standard command.
+type Info struct {
+	Name   string
+	Size   Size
+	Format Format
+	Access Access
+	// Units of Linear and Vout formats
+	Units string
+}
+
+// Commands is the standard command table of PMBus 1.3 Part II, Appendix I.
+var Commands = map[Cmd]Info{
+	Page:                  {"PAGE", Byte, Raw, RW, ""},
+	Operation:             {"OPERATION", Byte, Raw, RW, ""},
+	OnOffConfig:           {"ON_OFF_CONFIG", Byte, Raw, RW, ""},
+	ClearFaults:           {"CLEAR_FAULTS", Send, Raw, W, ""},
+	Phase:                 {"PHASE", Byte, Raw, RW, ""},
+	PagePlusWrite:         {"PAGE_PLUS_WRITE", Block, Raw, W, ""},
+	PagePlusRead:          {"PAGE_PLUS_READ", Call, Raw, R, ""},
+	WriteProtect:          {"WRITE_PROTECT", Byte, Raw, RW, ""},
+	StoreDefaultAll:       {"STORE_DEFAULT_ALL", Send, Raw, W, ""},
+	RestoreDefaultAll:     {"RESTORE_DEFAULT_ALL", Send, Raw, W, ""},
+	StoreDefaultCode:      {"STORE_DEFAULT_CODE", Byte, Raw, W, ""},
+	RestoreDefaultCode:    {"RESTORE_DEFAULT_CODE", Byte, Raw, W, ""},
+	StoreUserAll:          {"STORE_USER_ALL", Send, Raw, W, ""},
+	RestoreUserAll:        {"RESTORE_USER_ALL", Send, Raw, W, ""},
+	StoreUserCode:         {"STORE_USER_CODE", Byte, Raw, W, ""},
+	RestoreUserCode:       {"RESTORE_USER_CODE", Byte, Raw, W, ""},
+	Capability:            {"CAPABILITY", Byte, Raw, R, ""},
+	Query:                 {"QUERY", Call, Raw, R, ""},
+	SmbalertMask:          {"SMBALERT_MASK", Word, Raw, RW, ""},
+	VoutMode:              {"VOUT_MODE", Byte, Raw, RW, ""},
+	VoutCommand:           {"VOUT_COMMAND", Word, Vout, RW, "V"},
+	VoutTrim:              {"VOUT_TRIM", Word, Vout, RW, "V"},
+	VoutCalOffset:         {"VOUT_CAL_OFFSET", Word, Vout, RW, "V"},
+	VoutMax:               {"VOUT_MAX", Word, Vout, RW, "V"},
+	VoutMarginHigh:        {"VOUT_MARGIN_HIGH", Word, Vout, RW, "V"},
+	VoutMarginLow:         {"VOUT_MARGIN_LOW", Word, Vout, RW, "V"},
+	VoutTransitionRate:    {"VOUT_TRANSITION_RATE", Word, Linear, RW, "mV/us"},
+	VoutDroop:             {"VOUT_DROOP", Word, Linear, RW, "mV/A"},
+	VoutScaleLoop:         {"VOUT_SCALE_LOOP", Word, Linear, RW, ""},
+	VoutScaleMonitor:      {"VOUT_SCALE_MONITOR", Word, Linear, RW, ""},
+	VoutMin:               {"VOUT_MIN", Word, Vout, RW, "V"},
+	Coefficients:          {"COEFFICIENTS", Call, Raw, R, ""},
+	PoutMax:               {"POUT_MAX", Word, Linear, RW, "W"},
+	MaxDuty:               {"MAX_DUTY", Word, Linear, RW, "%"},
+	FrequencySwitch:       {"FREQUENCY_SWITCH", Word, Linear, RW, "kHz"},
+	PowerMode:             {"POWER_MODE", Byte, Raw, RW, ""},
+	VinOn:                 {"VIN_ON", Word, Linear, RW, "V"},
+	VinOff:                {"VIN_OFF", Word, Linear, RW, "V"},
+	Interleave:            {"INTERLEAVE", Word, Raw, RW, ""},
+	IoutCalGain:           {"IOUT_CAL_GAIN", Word, Linear, RW, "mOhm"},
+	IoutCalOffset:         {"IOUT_CAL_OFFSET", Word, Linear, RW, "A"},
+	FanConfig12:           {"FAN_CONFIG_1_2", Byte, Raw, RW, ""},
+	FanCommand1:           {"FAN_COMMAND_1", Word, Linear, RW, ""},
+	FanCommand2:           {"FAN_COMMAND_2", Word, Linear, RW, ""},
+	FanConfig34:           {"FAN_CONFIG_3_4", Byte, Raw, RW, ""},
+	FanCommand3:           {"FAN_COMMAND_3", Word, Linear, RW, ""},
+	FanCommand4:           {"FAN_COMMAND_4", Word, Linear, RW, ""},
+	VoutOvFaultLimit:      {"VOUT_OV_FAULT_LIMIT", Word, Vout, RW, "V"},
+	VoutOvFaultResponse:   {"VOUT_OV_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	VoutOvWarnLimit:       {"VOUT_OV_WARN_LIMIT", Word, Vout, RW, "V"},
+	VoutUvWarnLimit:       {"VOUT_UV_WARN_LIMIT", Word, Vout, RW, "V"},
+	VoutUvFaultLimit:      {"VOUT_UV_FAULT_LIMIT", Word, Vout, RW, "V"},
+	VoutUvFaultResponse:   {"VOUT_UV_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	IoutOcFaultLimit:      {"IOUT_OC_FAULT_LIMIT", Word, Linear, RW, "A"},
+	IoutOcFaultResponse:   {"IOUT_OC_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	IoutOcLvFaultLimit:    {"IOUT_OC_LV_FAULT_LIMIT", Word, Vout, RW, "V"},
+	IoutOcLvFaultResponse: {"IOUT_OC_LV_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	IoutOcWarnLimit:       {"IOUT_OC_WARN_LIMIT", Word, Linear, RW, "A"},
+	IoutUcFaultLimit:      {"IOUT_UC_FAULT_LIMIT", Word, Linear, RW, "A"},
+	IoutUcFaultResponse:   {"IOUT_UC_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	OtFaultLimit:          {"OT_FAULT_LIMIT", Word, Linear, RW, "C"},
+	OtFaultResponse:       {"OT_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	OtWarnLimit:           {"OT_WARN_LIMIT", Word, Linear, RW, "C"},
+	UtWarnLimit:           {"UT_WARN_LIMIT", Word, Linear, RW, "C"},
+	UtFaultLimit:          {"UT_FAULT_LIMIT", Word, Linear, RW, "C"},
+	UtFaultResponse:       {"UT_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	VinOvFaultLimit:       {"VIN_OV_FAULT_LIMIT", Word, Linear, RW, "V"},
+	VinOvFaultResponse:    {"VIN_OV_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	VinOvWarnLimit:        {"VIN_OV_WARN_LIMIT", Word, Linear, RW, "V"},
+	VinUvWarnLimit:        {"VIN_UV_WARN_LIMIT", Word, Linear, RW, "V"},
+	VinUvFaultLimit:       {"VIN_UV_FAULT_LIMIT", Word, Linear, RW, "V"},
+	VinUvFaultResponse:    {"VIN_UV_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	IinOcFaultLimit:       {"IIN_OC_FAULT_LIMIT", Word, Linear, RW, "A"},
+	IinOcFaultResponse:    {"IIN_OC_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	IinOcWarnLimit:        {"IIN_OC_WARN_LIMIT", Word, Linear, RW, "A"},
+	PowerGoodOn:           {"POWER_GOOD_ON", Word, Vout, RW, "V"},
+	PowerGoodOff:          {"POWER_GOOD_OFF", Word, Vout, RW, "V"},
+	TonDelay:              {"TON_DELAY", Word, Linear, RW, "ms"},
+	TonRise:               {"TON_RISE", Word, Linear, RW, "ms"},
+	TonMaxFaultLimit:      {"TON_MAX_FAULT_LIMIT", Word, Linear, RW, "ms"},
+	TonMaxFaultResponse:   {"TON_MAX_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	ToffDelay:             {"TOFF_DELAY", Word, Linear, RW, "ms"},
+	ToffFall:              {"TOFF_FALL", Word, Linear, RW, "ms"},
+	ToffMaxWarnLimit:      {"TOFF_MAX_WARN_LIMIT", Word, Linear, RW, "ms"},
+	PoutOpFaultLimit:      {"POUT_OP_FAULT_LIMIT", Word, Linear, RW, "W"},
+	PoutOpFaultResponse:   {"POUT_OP_FAULT_RESPONSE", Byte, Raw, RW, ""},
+	PoutOpWarnLimit:       {"POUT_OP_WARN_LIMIT", Word, Linear, RW, "W"},
+	PinOpWarnLimit:        {"PIN_OP_WARN_LIMIT", Word, Linear, RW, "W"},
+	StatusByte:            {"STATUS_BYTE", Byte, Status, RW, ""},
+	StatusWord:            {"STATUS_WORD", Word, Status, RW, ""},
+	StatusVout:            {"STATUS_VOUT", Byte, Status, RW, ""},
+	StatusIout:            {"STATUS_IOUT", Byte, Status, RW, ""},
+	StatusInput:           {"STATUS_INPUT", Byte, Status, RW, ""},
+	StatusTemperature:     {"STATUS_TEMPERATURE", Byte, Status, RW, ""},
+	StatusCml:             {"STATUS_CML", Byte, Status, RW, ""},
+	StatusOther:           {"STATUS_OTHER", Byte, Status, RW, ""},
+	StatusMfrSpecific:     {"STATUS_MFR_SPECIFIC", Byte, Status, RW, ""},
+	StatusFans12:          {"STATUS_FANS_1_2", Byte, Status, RW, ""},
+	StatusFans34:          {"STATUS_FANS_3_4", Byte, Status, RW, ""},
+	ReadEin:               {"READ_EIN", Block, Raw, R, ""},
+	ReadEout:              {"READ_EOUT", Block, Raw, R, ""},
+	ReadVin:               {"READ_VIN", Word, Linear, R, "V"},
+	ReadIin:               {"READ_IIN", Word, Linear, R, "A"},
+	ReadVcap:              {"READ_VCAP", Word, Linear, R, "V"},
+	ReadVout:              {"READ_VOUT", Word, Vout, R, "V"},
+	ReadIout:              {"READ_IOUT", Word, Linear, R, "A"},
+	ReadTemperature1:      {"READ_TEMPERATURE_1", Word, Linear, R, "C"},
+	ReadTemperature2:      {"READ_TEMPERATURE_2", Word, Linear, R, "C"},
+	ReadTemperature3:      {"READ_TEMPERATURE_3", Word, Linear, R, "C"},
+	ReadFanSpeed1:         {"READ_FAN_SPEED_1", Word, Linear, R, "RPM"},
+	ReadFanSpeed2:         {"READ_FAN_SPEED_2", Word, Linear, R, "RPM"},
+	ReadFanSpeed3:         {"READ_FAN_SPEED_3", Word, Linear, R, "RPM"},
+	ReadFanSpeed4:         {"READ_FAN_SPEED_4", Word, Linear, R, "RPM"},
+	ReadDutyCycle:         {"READ_DUTY_CYCLE", Word, Linear, R, "%"},
+	ReadFrequency:         {"READ_FREQUENCY", Word, Linear, R, "kHz"},
+	ReadPout:              {"READ_POUT", Word, Linear, R, "W"},
+	ReadPin:               {"READ_PIN", Word, Linear, R, "W"},
+	PmbusRevision:         {"PMBUS_REVISION", Byte, Raw, R, ""},
+	MfrId:                 {"MFR_ID", Block, Ascii, RW, ""},
+	MfrModel:              {"MFR_MODEL", Block, Ascii, RW, ""},
+	MfrRevision:           {"MFR_REVISION", Block, Ascii, RW, ""},
+	MfrLocation:           {"MFR_LOCATION", Block, Ascii, RW, ""},
+	MfrDate:               {"MFR_DATE", Block, Ascii, RW, ""},
+	MfrSerial:             {"MFR_SERIAL", Block, Ascii, RW, ""},
+	AppProfileSupport:     {"APP_PROFILE_SUPPORT", Block, Raw, R, ""},
+	MfrVinMin:             {"MFR_VIN_MIN", Word, Linear, R, "V"},
+	MfrVinMax:             {"MFR_VIN_MAX", Word, Linear, R, "V"},
+	MfrIinMax:             {"MFR_IIN_MAX", Word, Linear, R, "A"},
+	MfrPinMax:             {"MFR_PIN_MAX", Word, Linear, R, "W"},
+	MfrVoutMin:            {"MFR_VOUT_MIN", Word, Vout, R, "V"},
+	MfrVoutMax:            {"MFR_VOUT_MAX", Word, Vout, R, "V"},
+	MfrIoutMax:            {"MFR_IOUT_MAX", Word, Linear, R, "A"},
+	MfrPoutMax:            {"MFR_POUT_MAX", Word, Linear, R, "W"},
+	MfrTambientMax:        {"MFR_TAMBIENT_MAX", Word, Linear, R, "C"},
+	MfrTambientMin:        {"MFR_TAMBIENT_MIN", Word, Linear, R, "C"},
+	MfrEfficiencyLl:       {"MFR_EFFICIENCY_LL", Block, Raw, R, ""},
+	MfrEfficiencyHl:       {"MFR_EFFICIENCY_HL", Block, Raw, R, ""},
+	MfrPinAccuracy:        {"MFR_PIN_ACCURACY", Byte, Raw, R, ""},
+	IcDeviceId:            {"IC_DEVICE_ID", Block, Ascii, R, ""},
+	IcDeviceRev:           {"IC_DEVICE_REV", Block, Ascii, R, ""},
+	MfrMaxTemp1:           {"MFR_MAX_TEMP_1", Word, Linear, RW, "C"},
+	MfrMaxTemp2:           {"MFR_MAX_TEMP_2", Word, Linear, RW, "C"},
+	MfrMaxTemp3:           {"MFR_MAX_TEMP_3", Word, Linear, RW, "C"},
+	MfrSpecificCommandExt: {"MFR_SPECIFIC_COMMAND_EXT", Unsupported, Raw, RW, ""},
+	PmbusCommandExt:       {"PMBUS_COMMAND_EXT", Unsupported, Raw, RW, ""},
+}
+
+func init() {
+	for c := UserData00; c <= UserData15; c++ {
+		Commands[c] = Info{
+			Name:   fmt.Sprintf("USER_DATA_%02d", c-UserData00),
+			Size:   Block,
+			Format: Raw,
+			Access: RW,
+		}
+	}
+	for c := MfrSpecific00; c <= MfrSpecific45; c++ {
+		Commands[c] = Info{
+			Name:   fmt.Sprintf("MFR_SPECIFIC_%02d", c-MfrSpecific00),
+			Size:   Unsupported,
+			Format: Raw,
+			Access: RW,
+		}
+	}
+}
+
+func (c Cmd) String() string {
+	if info, found := Commands[c]; found {
+		return info.Name
+	}
+	return fmt.Sprintf("%#02x", uint8(c))
+}
+
+// Lookup the command by its name, e.g. READ_VOUT or read_vout, or code,
+// e.g. 0x8b.
+func Lookup(s string) (Cmd, bool) {
+	name := strings.ToUpper(s)
+	for c, info := range Commands {
+		if info.Name == name {
+			return c, true
+		}
+	}
+	u, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return 0, false
+	}
+	return Cmd(u), true
+}
@@ -0,0 +1,236 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pmbus
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/i2c"
+)
+
+func TestLinear(t *testing.T) {
+	for _, x := range []struct {
+		v uint16
+		f float64
+	}{
+		{0xf398, 230},
+		{0xd3c0, 15},
+		{0xe2b0, 43},
+		{0x03ff, 1023},
+		{0x0400, -1024},
+		{0xc7ff, -1.0 / 256},
+	} {
+		if f := Linear11(x.v); f != x.f {
+			t.Errorf("%#x: %v != %v", x.v, f, x.f)
+		}
+	}
+	for _, f := range []float64{230, 12.5, -3.25, 0.01, 40000} {
+		v, err := ToLinear11(f)
+		if err != nil {
+			t.Fatal(f, err)
+		}
+		if g := Linear11(v); math.Abs(g-f) > math.Abs(f)/1000 {
+			t.Errorf("%v: %#x: %v", f, v, g)
+		}
+	}
+	if _, err := ToLinear11(1e12); err != ErrRange {
+		t.Error("1e12:", err)
+	}
+
+	mode := Mode(0x17)
+	if mode.Exponent() != -9 || mode.Format() != ModeLinear {
+		t.Error("mode:", mode)
+	}
+	if f, _ := mode.Linear16(0x1800); f != 12 {
+		t.Error("linear16:", f)
+	}
+	if v, _ := mode.ToLinear16(3.3); v != 0x069a {
+		t.Errorf("to linear16: %#x", v)
+	}
+	if _, err := Mode(0x20).Linear16(0); err != ErrVid {
+		t.Error("vid:", err)
+	}
+	for _, f := range []float64{1, 12, 0.5, -2.25, 65504} {
+		if g := Half(ToHalf(f)); g != f {
+			t.Errorf("half %v: %#x: %v", f, ToHalf(f), g)
+		}
+	}
+	c := Direct{M: 1, B: 0, R: 2}
+	if v, _ := c.Encode(1.23); c.Decode(v) != 1.23 || v != 123 {
+		t.Errorf("direct: %d %v", v, c.Decode(v))
+	}
+}
+
+func TestPEC(t *testing.T) {
+	if pec := PEC([]byte("123456789")...); pec != 0xf4 {
+		t.Errorf("check: %#x", pec)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	got := Faults(StatusWord, 0x8840)
+	want := []string{"VOUT", "POWER_GOOD#", "OFF"}
+	if !reflect.DeepEqual(got, want) {
+		t.Error(got)
+	}
+	if got = Faults(StatusTemperature, 0x81); got[0] != "OT_FAULT" ||
+		got[1] != "RESERVED" {
+		t.Error(got)
+	}
+	for c, bits := range Bits {
+		n := 8
+		if c == StatusWord {
+			n = 16
+		}
+		if len(bits) != n {
+			t.Error(c, len(bits))
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	if c, found := Lookup("read_vout"); !found || c != ReadVout {
+		t.Error("read_vout:", c)
+	}
+	if c, found := Lookup("0xd0"); !found || c.String() != "MFR_SPECIFIC_00" {
+		t.Error("0xd0:", c)
+	}
+	if _, found := Lookup("READ_VOLTS"); found {
+		t.Error("found READ_VOLTS")
+	}
+	names := make(map[string]bool)
+	for c, info := range Commands {
+		if names[info.Name] {
+			t.Error("duplicate", info.Name)
+		}
+		names[info.Name] = true
+		if info.Format == Status && c < StatusByte {
+			t.Error("status", info.Name)
+		}
+	}
+}
+
+const script = `
+device 1 0x58 psu
+byte 0x20 0x17
+word 0x8b 0x1800
+word 0x88 0xf398
+word 0x79 0x8840
+byte 0x7a 0x80
+block 0x99 "FSP"
+block 0x9a "YM-2651Y####"
+`
+
+func TestDevice(t *testing.T) {
+	sim := i2cbus.NewSim()
+	if err := sim.Load(strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+	d := &Device{Bus: 1, Addr: 0x58, Backend: sim}
+
+	for _, x := range []struct {
+		c Cmd
+		s string
+	}{
+		{ReadVout, "12.000 V"},
+		{ReadVin, "230.000 V"},
+		{StatusWord, "0x8840 VOUT,POWER_GOOD#,OFF"},
+		{MfrId, "FSP"},
+		{MfrModel, "YM-2651Y"},
+		{VoutMode, "0x17"},
+	} {
+		v, err := d.Read(x.c)
+		if err != nil {
+			t.Fatal(x.c, err)
+		}
+		if s := v.String(); s != x.s {
+			t.Errorf("%v: %q != %q", x.c, s, x.s)
+		}
+	}
+	if _, err := d.Read(ClearFaults); err == nil {
+		t.Error("read CLEAR_FAULTS")
+	}
+
+	faults, err := d.Faults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"STATUS_VOUT.VOUT_OV_FAULT",
+		"STATUS_WORD.POWER_GOOD#",
+		"STATUS_WORD.OFF",
+	}
+	if !reflect.DeepEqual(faults, want) {
+		t.Error(faults)
+	}
+
+	if err = d.Write(VoutCommand, "3.3"); err != nil {
+		t.Fatal(err)
+	}
+	if w := sim.Device(1, 0x58).Word(0, uint8(VoutCommand)); w != 0x069a {
+		t.Errorf("VOUT_COMMAND: %#x", w)
+	}
+	if err = d.Write(ReadVout, "1"); err == nil {
+		t.Error("wrote READ_VOUT")
+	}
+	if err = d.Write(ClearFaults, ""); err != nil {
+		t.Error(err)
+	}
+}
+
+// pecDev responds to I2C block reads with the word and its PEC, and checks
+// the PEC of I2C block writes.
+type pecDev struct {
+	word    uint16
+	corrupt bool
+}
+
+func (p *pecDev) Do(bus, addr int, rw i2c.RW, cmd uint8, size i2c.SMBusSize,
+	data *i2c.SMBusData) error {
+	w, r := byte(addr<<1), byte(addr<<1|1)
+	if size != i2c.I2CBlockData {
+		return syscall.EINVAL
+	}
+	if rw == i2c.Write {
+		n := int(data[0])
+		b := append([]byte{w, cmd}, data[1:n]...)
+		if PEC(b...) != data[n] {
+			return ErrPEC
+		}
+		p.word = uint16(data[1]) | uint16(data[2])<<8
+		return nil
+	}
+	data[1], data[2] = byte(p.word), byte(p.word>>8)
+	data[3] = PEC(w, cmd, r, data[1], data[2])
+	if p.corrupt {
+		data[3]++
+	}
+	return nil
+}
+
+func TestDevicePEC(t *testing.T) {
+	p := new(pecDev)
+	d := &Device{Bus: 1, Addr: 0x58, PEC: true, Backend: p}
+	if err := d.WriteWordData(ReadVin, 0xf398); err != nil {
+		t.Fatal(err)
+	}
+	v, err := d.Read(ReadVin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Real != 230 {
+		t.Error("vin:", v)
+	}
+	p.corrupt = true
+	if _, err = d.ReadWordData(ReadVin); err == nil ||
+		!strings.Contains(err.Error(), ErrPEC.Error()) {
+		t.Error("corrupt:", err)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pmbus
+
+// Bits of each STATUS register from the most significant, "" if reserved.
+var Bits = map[Cmd][]string{
+	StatusByte: statusByte,
+	StatusWord: append([]string{
+		"VOUT",
+		"IOUT_POUT",
+		"INPUT",
+		"MFR_SPECIFIC",
+		"POWER_GOOD#",
+		"FANS",
+		"OTHER",
+		"UNKNOWN",
+	}, statusByte...),
+	StatusVout: {
+		"VOUT_OV_FAULT",
+		"VOUT_OV_WARNING",
+		"VOUT_UV_WARNING",
+		"VOUT_UV_FAULT",
+		"VOUT_MAX_MIN_WARNING",
+		"TON_MAX_FAULT",
+		"TOFF_MAX_WARNING",
+		"VOUT_TRACKING_ERROR",
+	},
+	StatusIout: {
+		"IOUT_OC_FAULT",
+		"IOUT_OC_LV_FAULT",
+		"IOUT_OC_WARNING",
+		"IOUT_UC_FAULT",
+		"CURRENT_SHARE_FAULT",
+		"POWER_LIMITING",
+		"POUT_OP_FAULT",
+		"POUT_OP_WARNING",
+	},
+	StatusInput: {
+		"VIN_OV_FAULT",
+		"VIN_OV_WARNING",
+		"VIN_UV_WARNING",
+		"VIN_UV_FAULT",
+		"UNIT_OFF_LOW_VIN",
+		"IIN_OC_FAULT",
+		"IIN_OC_WARNING",
+		"PIN_OP_WARNING",
+	},
+	StatusTemperature: {
+		"OT_FAULT",
+		"OT_WARNING",
+		"UT_WARNING",
+		"UT_FAULT",
+		"",
+		"",
+		"",
+		"",
+	},
+	StatusCml: {
+		"INVALID_COMMAND",
+		"INVALID_DATA",
+		"PEC_FAILED",
+		"MEMORY_FAULT",
+		"PROCESSOR_FAULT",
+		"",
+		"OTHER_COMMUNICATION_FAULT",
+		"OTHER_MEMORY_OR_LOGIC_FAULT",
+	},
+	StatusOther: {
+		"",
+		"",
+		"INPUT_A_FUSE_FAULT",
+		"INPUT_B_FUSE_FAULT",
+		"INPUT_A_ORING_FAULT",
+		"INPUT_B_ORING_FAULT",
+		"OUTPUT_ORING_FAULT",
+		"FIRST_TO_ASSERT_SMBALERT",
+	},
+	StatusMfrSpecific: {
+		"MFR_7",
+		"MFR_6",
+		"MFR_5",
+		"MFR_4",
+		"MFR_3",
+		"MFR_2",
+		"MFR_1",
+		"MFR_0",
+	},
+	StatusFans12: {
+		"FAN_1_FAULT",
+		"FAN_2_FAULT",
+		"FAN_1_WARNING",
+		"FAN_2_WARNING",
+		"FAN_1_SPEED_OVERRIDDEN",
+		"FAN_2_SPEED_OVERRIDDEN",
+		"AIRFLOW_FAULT",
+		"AIRFLOW_WARNING",
+	},
+	StatusFans34: {
+		"FAN_3_FAULT",
+		"FAN_4_FAULT",
+		"FAN_3_WARNING",
+		"FAN_4_WARNING",
+		"FAN_3_SPEED_OVERRIDDEN",
+		"FAN_4_SPEED_OVERRIDDEN",
+		"",
+		"",
+	},
+}
+
+var statusByte = []string{
+	"BUSY",
+	"OFF",
+	"VOUT_OV_FAULT",
+	"IOUT_OC_FAULT",
+	"VIN_UV_FAULT",
+	"TEMPERATURE",
+	"CML",
+	"NONE_OF_THE_ABOVE",
+}
+
+// Detail is the STATUS register of each STATUS_WORD summary bit.
+var Detail = map[string]Cmd{
+	"VOUT":         StatusVout,
+	"IOUT_POUT":    StatusIout,
+	"INPUT":        StatusInput,
+	"MFR_SPECIFIC": StatusMfrSpecific,
+	"FANS":         StatusFans12,
+	"OTHER":        StatusOther,
+	"TEMPERATURE":  StatusTemperature,
+	"CML":          StatusCml,
+}
+
+// Faults returns the names of the set bits of the STATUS register.
+func Faults(c Cmd, v uint16) []string {
+	bits := Bits[c]
+	var faults []string
+	for i, name := range bits {
+		if v&(1<<uint(len(bits)-1-i)) != 0 {
+			if len(name) == 0 {
+				name = "RESERVED"
+			}
+			faults = append(faults, name)
+		}
+	}
+	return faults
+}
@@ -0,0 +1,357 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package slot keeps A/B image slots so that an upgrade never overwrites
+// the images that booted. Images are staged to the inactive slot,
+// verified, then activated by atomically replacing the DIR/current symlink.
+// The new slot is pending until committed; otherwise, it's rolled back
+// after its deadline.
+//
+//	DIR/a/IMAGE...
+//	DIR/b/IMAGE...
+//	DIR/current -> a
+//	DIR/state
+//
+// With a Grubenv, the saved_entry and known_good_entry are those of the
+// slots, so grub menuentries should have "--id a" and "--id b".
+package slot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/platinasystems/go/internal/grubenv"
+)
+
+const (
+	A         = "a"
+	B         = "b"
+	Current   = "current"
+	StateFile = "state"
+)
+
+var (
+	ErrPending    = errors.New("slot pending commit or rollback")
+	ErrNotPending = errors.New("no pending slot")
+)
+
+// Image is the record of a staged file.
+type Image struct {
+	Version string
+	Size    int64
+	Sha256  string
+}
+
+// State is saved as DIR/state.
+type State struct {
+	Active   string
+	Previous string
+	Pending  bool
+	Deadline time.Time
+	// Images of each slot by name
+	Images map[string]map[string]Image
+}
+
+type Slots struct {
+	Dir     string
+	Grubenv string
+	State
+}
+
+// Open the slots of the directory, creating them if necessary.
+func Open(dir string) (*Slots, error) {
+	s := &Slots{Dir: dir}
+	for _, slot := range []string{A, B} {
+		if err := os.MkdirAll(filepath.Join(dir, slot), 0755); err != nil {
+			return nil, err
+		}
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, StateFile))
+	switch {
+	case err == nil:
+		if err = json.Unmarshal(b, &s.State); err != nil {
+			return nil, fmt.Errorf("%s: %v", StateFile, err)
+		}
+	case os.IsNotExist(err):
+		s.Active = A
+	default:
+		return nil, err
+	}
+	if s.Active != A && s.Active != B {
+		return nil, fmt.Errorf("%s: %q: invalid active slot", StateFile,
+			s.Active)
+	}
+	if s.Images == nil {
+		s.Images = make(map[string]map[string]Image)
+	}
+	// The state is saved before DIR/current is replaced, so repair the
+	// link of an interrupted Switch or Rollback.
+	if slot, err := os.Readlink(filepath.Join(dir, Current)); err != nil ||
+		slot != s.Active {
+		if err = s.link(s.Active); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Inactive returns the slot that may be staged.
+func (s *Slots) Inactive() string {
+	if s.Active == A {
+		return B
+	}
+	return A
+}
+
+// Path of the named image in the slot.
+func (s *Slots) Path(slot, name string) string {
+	return filepath.Join(s.Dir, slot, name)
+}
+
+// Names of the slot's images.
+func (s *Slots) Names(slot string) []string {
+	var names []string
+	for name := range s.Images[slot] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Prepare the inactive slot for staging by replacing its images with copies
+// of those of the active slot.
+func (s *Slots) Prepare() error {
+	if s.Pending {
+		return ErrPending
+	}
+	slot := s.Inactive()
+	for _, name := range s.Names(slot) {
+		os.Remove(s.Path(slot, name))
+	}
+	delete(s.Images, slot)
+	for _, name := range s.Names(s.Active) {
+		f, err := os.Open(s.Path(s.Active, name))
+		if err != nil {
+			return err
+		}
+		img := s.Images[s.Active][name]
+		err = s.stage(slot, name, img.Version, f, img.Sha256)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return s.Save()
+}
+
+// Stage the named image to the inactive slot. If not empty, the image must
+// have the given SHA-256 sum.
+func (s *Slots) Stage(name, version string, r io.Reader, sum string) error {
+	if s.Pending {
+		return ErrPending
+	}
+	if err := s.stage(s.Inactive(), name, version, r, sum); err != nil {
+		return err
+	}
+	return s.Save()
+}
+
+func (s *Slots) stage(slot, name, version string, r io.Reader,
+	sum string) error {
+	fn := s.Path(slot, name)
+	tmp := fn + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	got := hex.EncodeToString(h.Sum(nil))
+	if len(sum) > 0 && got != sum {
+		return fmt.Errorf("%s: sha256 %s, expected %s", name, got, sum)
+	}
+	if err = os.Rename(tmp, fn); err != nil {
+		return err
+	}
+	if s.Images[slot] == nil {
+		s.Images[slot] = make(map[string]Image)
+	}
+	s.Images[slot][name] = Image{
+		Version: version,
+		Size:    n,
+		Sha256:  got,
+	}
+	return nil
+}
+
+// Verify the slot's images with their recorded size and sum.
+func (s *Slots) Verify(slot string) error {
+	for _, name := range s.Names(slot) {
+		img := s.Images[slot][name]
+		f, err := os.Open(s.Path(slot, name))
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if n != img.Size || hex.EncodeToString(h.Sum(nil)) != img.Sha256 {
+			return fmt.Errorf("%s/%s: corrupt", slot, name)
+		}
+	}
+	return nil
+}
+
+// Switch to the verified inactive slot, which remains pending until
+// committed; otherwise, it's Expired after the timeout.
+func (s *Slots) Switch(timeout time.Duration) error {
+	if s.Pending {
+		return ErrPending
+	}
+	slot := s.Inactive()
+	if len(s.Images[slot]) == 0 {
+		return fmt.Errorf("%s: empty slot", slot)
+	}
+	if err := s.Verify(slot); err != nil {
+		return err
+	}
+	prev := s.State
+	s.Previous, s.Active = s.Active, slot
+	s.Pending = true
+	s.Deadline = time.Now().Add(timeout)
+	if err := s.Save(); err != nil {
+		s.State = prev
+		return err
+	}
+	if err := s.link(slot); err != nil {
+		s.State = prev
+		s.Save()
+		return err
+	}
+	return s.updateGrubenv(map[string]string{
+		grubenv.SavedEntry:     s.Active,
+		grubenv.KnownGoodEntry: s.Previous,
+		grubenv.BootCount:      "",
+		grubenv.BootEntry:      "",
+	})
+}
+
+// Commit the pending slot, making it the known good.
+func (s *Slots) Commit() error {
+	if !s.Pending {
+		return ErrNotPending
+	}
+	s.Pending = false
+	s.Deadline = time.Time{}
+	if err := s.Save(); err != nil {
+		return err
+	}
+	return s.updateGrubenv(map[string]string{
+		grubenv.KnownGoodEntry: s.Active,
+		grubenv.BootCount:      "",
+	})
+}
+
+// Rollback to the previous slot.
+func (s *Slots) Rollback() error {
+	if len(s.Previous) == 0 {
+		return errors.New("no previous slot")
+	}
+	prev := s.State
+	s.Previous, s.Active = s.Active, s.Previous
+	s.Pending = false
+	s.Deadline = time.Time{}
+	if err := s.Save(); err != nil {
+		s.State = prev
+		return err
+	}
+	if err := s.link(s.Active); err != nil {
+		s.State = prev
+		s.Save()
+		return err
+	}
+	return s.updateGrubenv(map[string]string{
+		grubenv.SavedEntry:     s.Active,
+		grubenv.KnownGoodEntry: s.Active,
+		grubenv.BootCount:      "",
+		grubenv.BootEntry:      "",
+	})
+}
+
+// Expired returns true if the pending slot wasn't committed by its deadline.
+func (s *Slots) Expired(now time.Time) bool {
+	return s.Pending && now.After(s.Deadline)
+}
+
+// Save the state by atomically replacing DIR/state.
+func (s *Slots) Save() error {
+	b, err := json.MarshalIndent(&s.State, "", "\t")
+	if err != nil {
+		return err
+	}
+	fn := filepath.Join(s.Dir, StateFile)
+	tmp := fn + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, fn)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// link atomically replaces DIR/current with a symlink to the slot.
+func (s *Slots) link(slot string) error {
+	fn := filepath.Join(s.Dir, Current)
+	tmp := fn + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(slot, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, fn); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (s *Slots) updateGrubenv(vars map[string]string) error {
+	if len(s.Grubenv) == 0 {
+		return nil
+	}
+	return grubenv.Update(s.Grubenv, vars)
+}
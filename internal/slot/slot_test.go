@@ -0,0 +1,124 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package slot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/go/internal/grubenv"
+)
+
+func current(t *testing.T, s *Slots) string {
+	slot, err := os.Readlink(filepath.Join(s.Dir, Current))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return slot
+}
+
+func TestSlots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Grubenv = filepath.Join(dir, "grubenv")
+	if s.Active != A || current(t, s) != A {
+		t.Fatal("initial slot:", s.Active, current(t, s))
+	}
+	if err = s.Switch(time.Minute); err == nil {
+		t.Fatal("switched to empty slot")
+	}
+	err = s.Stage("goes", "v1.0", strings.NewReader("goes"), "0123")
+	if err == nil {
+		t.Fatal("staged with wrong sum")
+	}
+	for _, name := range []string{"goes", "vmlinuz"} {
+		err = s.Stage(name, "v1.1", strings.NewReader(name), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.Switch(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if s.Active != B || !s.Pending || current(t, s) != B {
+		t.Fatal("switch:", s.State)
+	}
+	if err = s.Prepare(); err != ErrPending {
+		t.Fatal("prepared pending:", err)
+	}
+	env, err := grubenv.Read(s.Grubenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env[grubenv.SavedEntry] != B || env[grubenv.KnownGoodEntry] != A {
+		t.Fatal("grubenv:", env)
+	}
+
+	// reopen, as after reboot, repairing the link of a switch interrupted
+	// after saving the state
+	if err = s.link(A); err != nil {
+		t.Fatal(err)
+	}
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current(t, s) != B {
+		t.Fatal("interrupted switch:", current(t, s))
+	}
+	if !s.Pending || s.Expired(time.Now()) ||
+		!s.Expired(time.Now().Add(time.Hour)) {
+		t.Fatal("deadline:", s.State)
+	}
+	if err = s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Commit(); err != ErrNotPending {
+		t.Fatal("recommit:", err)
+	}
+
+	// upgrade just the kernel
+	if err = s.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	err = s.Stage("vmlinuz", "v1.2", strings.NewReader("vmlinuz2"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Images[A]["goes"].Version; v != "v1.1" {
+		t.Fatal("prepared goes version:", v)
+	}
+	if err = ioutil.WriteFile(s.Path(A, "goes"), []byte("bad"),
+		0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Switch(time.Minute); err == nil {
+		t.Fatal("switched to corrupt slot")
+	}
+	if err = s.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Switch(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Active != B || s.Pending || current(t, s) != B {
+		t.Fatal("rollback:", s.State)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package telnet
+
+import (
+	"fmt"
+
+	"github.com/platinasystems/go/internal/telnet/command"
+)
+
+// The option negotiation follows the "Q Method" of RFC 1143 so that neither
+// side can be driven into a negotiation loop.
+type qstate uint8
+
+const (
+	qNo qstate = iota
+	qYes
+	qWantNo
+	qWantYes
+)
+
+var qstateNames = []string{
+	qNo:      "no",
+	qYes:     "yes",
+	qWantNo:  "want-no",
+	qWantYes: "want-yes",
+}
+
+func (s qstate) String() string {
+	if int(s) < len(qstateNames) {
+		return qstateNames[s]
+	}
+	return fmt.Sprint("qstate(", uint8(s), ")")
+}
+
+// q is one side of an option, either ours (us) or the peer's (him).
+type q struct {
+	state qstate
+	// opposite is the RFC 1143 queue bit; if set, the opposite of the
+	// pending request is to be negotiated once it is answered.
+	opposite bool
+}
+
+// verbs for each side of an option; "enable" is the request received or
+// sent to turn the option on, "ack" and "nak" are the replies.
+type verbs struct {
+	enable, disable byte
+	ack, nak        byte
+}
+
+var (
+	// negotiation of the peer's options, we send DO/DONT
+	himVerbs = verbs{
+		enable:  command.WILL,
+		disable: command.WONT,
+		ack:     command.DO,
+		nak:     command.DONT,
+	}
+	// negotiation of our options, we send WILL/WONT
+	usVerbs = verbs{
+		enable:  command.DO,
+		disable: command.DONT,
+		ack:     command.WILL,
+		nak:     command.WONT,
+	}
+)
+
+// recvEnable handles WILL (for him) or DO (for us) and returns the reply
+// verb, if any, and whether the option was just enabled.
+func (s *q) recvEnable(allow bool, v verbs) (reply byte, enabled bool) {
+	switch s.state {
+	case qNo:
+		if allow {
+			s.state = qYes
+			return v.ack, true
+		}
+		return v.nak, false
+	case qWantNo:
+		if s.opposite {
+			s.state = qYes
+			s.opposite = false
+			return 0, true
+		}
+		// the peer answered our disable with an enable; RFC 1143
+		// says to treat this as a refusal.
+		s.state = qNo
+	case qWantYes:
+		if s.opposite {
+			s.state = qWantNo
+			s.opposite = false
+			return v.nak, false
+		}
+		s.state = qYes
+		return 0, true
+	}
+	return 0, false
+}
+
+// recvDisable handles WONT (for him) or DONT (for us) and returns the reply
+// verb, if any, and whether the option was just disabled.
+func (s *q) recvDisable(v verbs) (reply byte, disabled bool) {
+	switch s.state {
+	case qYes:
+		s.state = qNo
+		return v.nak, true
+	case qWantNo:
+		if s.opposite {
+			s.state = qWantYes
+			s.opposite = false
+			return v.ack, true
+		}
+		s.state = qNo
+		return 0, true
+	case qWantYes:
+		s.state = qNo
+		s.opposite = false
+	}
+	return 0, false
+}
+
+// askEnable starts, or queues, negotiation to enable the option and
+// returns the verb to send, if any.
+func (s *q) askEnable(v verbs) (byte, error) {
+	switch s.state {
+	case qNo:
+		s.state = qWantYes
+		return v.ack, nil
+	case qYes:
+		return 0, errAlreadyEnabled
+	case qWantNo:
+		if s.opposite {
+			return 0, errAlreadyQueued
+		}
+		s.opposite = true
+	case qWantYes:
+		if !s.opposite {
+			return 0, errAlreadyNegotiating
+		}
+		s.opposite = false
+	}
+	return 0, nil
+}
+
+// askDisable starts, or queues, negotiation to disable the option and
+// returns the verb to send, if any.
+func (s *q) askDisable(v verbs) (byte, error) {
+	switch s.state {
+	case qNo:
+		return 0, errAlreadyDisabled
+	case qYes:
+		s.state = qWantNo
+		return v.nak, nil
+	case qWantNo:
+		if !s.opposite {
+			return 0, errAlreadyNegotiating
+		}
+		s.opposite = false
+	case qWantYes:
+		if s.opposite {
+			return 0, errAlreadyQueued
+		}
+		s.opposite = true
+	}
+	return 0, nil
+}
+
+func (s *q) pending() bool {
+	return s.state == qWantNo || s.state == qWantYes
+}
@@ -0,0 +1,494 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package telnet provides a telnet (RFC 854) connection that negotiates
+// options with the RFC 1143 state machine and strips or escapes IAC
+// sequences in both directions.
+//
+// Besides the basic ECHO, SGA and BINARY options, a Conn handles the
+// subnegotiation of NAWS (RFC 1073), TERMINAL-TYPE (RFC 1091) and
+// NEW-ENVIRON (RFC 1572).
+package telnet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/go/internal/telnet/command"
+	"github.com/platinasystems/go/internal/telnet/option"
+)
+
+// TERMINAL-TYPE and NEW-ENVIRON subnegotiation codes
+const (
+	sbIS   byte = 0
+	sbSEND byte = 1
+	sbINFO byte = 2
+)
+
+// NEW-ENVIRON type codes
+const (
+	envVAR     byte = 0
+	envVALUE   byte = 1
+	envESC     byte = 2
+	envUSERVAR byte = 3
+)
+
+// maxSB limits the length of a subnegotiation that we'll buffer.
+const maxSB = 1024
+
+var (
+	errAlreadyEnabled     = errors.New("option already enabled")
+	errAlreadyDisabled    = errors.New("option already disabled")
+	errAlreadyNegotiating = errors.New("option already negotiating")
+	errAlreadyQueued      = errors.New("option already queued")
+)
+
+type rxstate uint8
+
+const (
+	rxData rxstate = iota
+	rxCR
+	rxIAC
+	rxVerb
+	rxSB
+	rxSBData
+	rxSBIAC
+)
+
+type Conn struct {
+	// Local and Remote list the options that we'll agree to enable on
+	// our or the peer's side.
+	Local, Remote [256]bool
+
+	// Term is the terminal type reported by the peer, if any.
+	Term string
+
+	// Env has the variables reported by the peer with NEW-ENVIRON.
+	Env map[string]string
+
+	// Cols and Rows are the last window size reported by the peer.
+	Cols, Rows uint16
+
+	// Resize, if not nil, is called with every NAWS window size.
+	Resize func(cols, rows uint16)
+
+	rw io.ReadWriter
+
+	mu  sync.Mutex
+	us  [256]q
+	him [256]q
+
+	// expecting a TERMINAL-TYPE or NEW-ENVIRON reply
+	awaitTerm, awaitEnv bool
+
+	// the last write ended with a CR, to be followed by LF or NUL
+	txCR bool
+
+	rx   rxstate
+	verb byte
+	sb   bytes.Buffer
+	data []byte
+	rbuf []byte
+}
+
+// New returns a Conn for the given connection that agrees to nothing until
+// the Local and Remote options are set.
+func New(rw io.ReadWriter) *Conn {
+	return &Conn{
+		rw:   rw,
+		Env:  make(map[string]string),
+		rbuf: make([]byte, 4096),
+	}
+}
+
+// Server returns a Conn that agrees to ECHO, SGA and BINARY on our side and
+// SGA, BINARY, NAWS, TERMINAL-TYPE and NEW-ENVIRON from the client.
+func Server(rw io.ReadWriter) *Conn {
+	c := New(rw)
+	for _, opt := range []byte{
+		option.BINARY,
+		option.ECHO,
+		option.SGA,
+	} {
+		c.Local[opt] = true
+	}
+	for _, opt := range []byte{
+		option.BINARY,
+		option.SGA,
+		option.NAWS,
+		option.TYPE,
+		option.NEWENV,
+	} {
+		c.Remote[opt] = true
+	}
+	return c
+}
+
+// Will asks to enable the option on our side.
+func (c *Conn) Will(opt byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	verb, err := c.us[opt].askEnable(usVerbs)
+	return c.reply(verb, opt, err)
+}
+
+// Wont asks to disable the option on our side.
+func (c *Conn) Wont(opt byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	verb, err := c.us[opt].askDisable(usVerbs)
+	return c.reply(verb, opt, err)
+}
+
+// Do asks the peer to enable the option.
+func (c *Conn) Do(opt byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	verb, err := c.him[opt].askEnable(himVerbs)
+	return c.reply(verb, opt, err)
+}
+
+// Dont asks the peer to disable the option.
+func (c *Conn) Dont(opt byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	verb, err := c.him[opt].askDisable(himVerbs)
+	return c.reply(verb, opt, err)
+}
+
+// Us reports whether the option is enabled on our side.
+func (c *Conn) Us(opt byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.us[opt].state == qYes
+}
+
+// Him reports whether the option is enabled on the peer's side.
+func (c *Conn) Him(opt byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.him[opt].state == qYes
+}
+
+// Negotiate reads from the connection until all pending option requests
+// and subnegotiations have been answered or the timeout expires. Any data
+// received in the meantime is returned by subsequent Reads.  Negotiate
+// just returns if the connection doesn't support read deadlines.
+func (c *Conn) Negotiate(timeout time.Duration) error {
+	dl, ok := c.rw.(interface {
+		SetReadDeadline(time.Time) error
+	})
+	if !ok {
+		return nil
+	}
+	if err := dl.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	defer dl.SetReadDeadline(time.Time{})
+	for c.pending() {
+		n, err := c.rw.Read(c.rbuf)
+		c.parse(c.rbuf[:n])
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Conn) pending() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.awaitTerm || c.awaitEnv {
+		return true
+	}
+	for i := range c.us {
+		if c.us[i].pending() || c.him[i].pending() {
+			return true
+		}
+	}
+	return false
+}
+
+// Read returns data received from the peer stripped of telnet commands.
+func (c *Conn) Read(b []byte) (int, error) {
+	for len(c.data) == 0 {
+		n, err := c.rw.Read(c.rbuf)
+		c.parse(c.rbuf[:n])
+		if err != nil && len(c.data) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(b, c.data)
+	c.data = c.data[n:]
+	return n, nil
+}
+
+// Write sends data to the peer with IAC escaped and, unless we are in
+// BINARY mode, bare CR followed by NUL. A CR ending one write is followed
+// by the NUL at the start of the next, unless that begins with LF.
+func (c *Conn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	binary := c.us[option.BINARY].state == qYes
+	buf := make([]byte, 0, len(b)+8)
+	if c.txCR && len(b) > 0 {
+		if b[0] != '\n' && !binary {
+			buf = append(buf, 0)
+		}
+		c.txCR = false
+	}
+	for i, x := range b {
+		switch {
+		case x == command.IAC:
+			buf = append(buf, command.IAC, command.IAC)
+		case x == '\r' && !binary && i+1 == len(b):
+			buf = append(buf, '\r')
+			c.txCR = true
+		case x == '\r' && !binary && b[i+1] != '\n':
+			buf = append(buf, '\r', 0)
+		default:
+			buf = append(buf, x)
+		}
+	}
+	if _, err := c.rw.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// parse the received bytes, appending data to c.data
+func (c *Conn) parse(b []byte) {
+	for _, x := range b {
+		switch c.rx {
+		case rxCR:
+			c.rx = rxData
+			if x == '\n' || x == 0 {
+				continue
+			}
+			fallthrough
+		case rxData:
+			switch x {
+			case command.IAC:
+				c.rx = rxIAC
+			case '\r':
+				c.data = append(c.data, x)
+				if !c.Him(option.BINARY) {
+					c.rx = rxCR
+				}
+			default:
+				c.data = append(c.data, x)
+			}
+		case rxIAC:
+			c.rx = rxData
+			switch x {
+			case command.IAC:
+				c.data = append(c.data, x)
+			case command.WILL, command.WONT,
+				command.DO, command.DONT:
+				c.verb = x
+				c.rx = rxVerb
+			case command.SB:
+				c.rx = rxSB
+			default:
+				c.command(x)
+			}
+		case rxVerb:
+			c.rx = rxData
+			c.option(c.verb, x)
+		case rxSB:
+			c.sb.Reset()
+			c.sb.WriteByte(x)
+			c.rx = rxSBData
+		case rxSBData:
+			if x == command.IAC {
+				c.rx = rxSBIAC
+			} else if c.sb.Len() < maxSB {
+				c.sb.WriteByte(x)
+			}
+		case rxSBIAC:
+			switch x {
+			case command.IAC:
+				if c.sb.Len() < maxSB {
+					c.sb.WriteByte(x)
+				}
+				c.rx = rxSBData
+			case command.SE:
+				c.rx = rxData
+				c.subnegotiation(c.sb.Bytes())
+			default:
+				// RFC 855 says that this is an error; the
+				// best recovery is to end the subnegotiation
+				// and process the command.
+				c.rx = rxData
+				c.subnegotiation(c.sb.Bytes())
+				c.rx = rxIAC
+				c.parse([]byte{x})
+			}
+		}
+	}
+}
+
+// command handles the IAC commands other than option negotiation
+func (c *Conn) command(x byte) {
+	switch x {
+	case command.IP, command.BRK:
+		c.data = append(c.data, 0x03) // ^C
+	case command.EC:
+		c.data = append(c.data, 0x7f) // DEL
+	case command.EL:
+		c.data = append(c.data, 0x15) // ^U
+	case command.AYT:
+		c.mu.Lock()
+		c.rw.Write([]byte("\r\n[Yes]\r\n"))
+		c.mu.Unlock()
+	}
+}
+
+// option handles a WILL, WONT, DO or DONT received for the given option.
+func (c *Conn) option(verb, opt byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var reply byte
+	switch verb {
+	case command.WILL:
+		var enabled bool
+		reply, enabled = c.him[opt].recvEnable(c.Remote[opt], himVerbs)
+		c.reply(reply, opt, nil)
+		if enabled {
+			c.himEnabled(opt)
+		}
+	case command.WONT:
+		var disabled bool
+		reply, disabled = c.him[opt].recvDisable(himVerbs)
+		c.reply(reply, opt, nil)
+		if disabled {
+			switch opt {
+			case option.TYPE:
+				c.awaitTerm = false
+			case option.NEWENV:
+				c.awaitEnv = false
+			}
+		}
+	case command.DO:
+		reply, _ = c.us[opt].recvEnable(c.Local[opt], usVerbs)
+		c.reply(reply, opt, nil)
+	case command.DONT:
+		reply, _ = c.us[opt].recvDisable(usVerbs)
+		c.reply(reply, opt, nil)
+	}
+}
+
+// himEnabled requests the subnegotiation of options that need it; it must
+// be called with c.mu held.
+func (c *Conn) himEnabled(opt byte) {
+	switch opt {
+	case option.TYPE:
+		c.awaitTerm = true
+		c.rw.Write([]byte{
+			command.IAC, command.SB, option.TYPE, sbSEND,
+			command.IAC, command.SE,
+		})
+	case option.NEWENV:
+		c.awaitEnv = true
+		c.rw.Write([]byte{
+			command.IAC, command.SB, option.NEWENV, sbSEND,
+			command.IAC, command.SE,
+		})
+	}
+}
+
+// reply sends the verb, if any, for the option; it must be called with
+// c.mu held.
+func (c *Conn) reply(verb, opt byte, err error) error {
+	if err != nil || verb == 0 {
+		return err
+	}
+	_, err = c.rw.Write([]byte{command.IAC, verb, opt})
+	return err
+}
+
+func (c *Conn) subnegotiation(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	opt, b := b[0], b[1:]
+	if !c.Him(opt) {
+		return
+	}
+	switch opt {
+	case option.NAWS:
+		if len(b) < 4 {
+			return
+		}
+		c.Cols = binary.BigEndian.Uint16(b[0:2])
+		c.Rows = binary.BigEndian.Uint16(b[2:4])
+		if c.Resize != nil {
+			c.Resize(c.Cols, c.Rows)
+		}
+	case option.TYPE:
+		if len(b) < 1 || b[0] != sbIS {
+			return
+		}
+		c.mu.Lock()
+		c.Term = string(b[1:])
+		c.awaitTerm = false
+		c.mu.Unlock()
+	case option.NEWENV:
+		if len(b) < 1 || (b[0] != sbIS && b[0] != sbINFO) {
+			return
+		}
+		c.mu.Lock()
+		parseEnv(c.Env, b[1:])
+		if b[0] == sbIS {
+			c.awaitEnv = false
+		}
+		c.mu.Unlock()
+	}
+}
+
+// parseEnv adds the VAR and USERVAR definitions of a NEW-ENVIRON IS or INFO
+// to the given map.
+func parseEnv(env map[string]string, b []byte) {
+	var name, value []byte
+	var inValue, started bool
+	flush := func() {
+		if started && len(name) > 0 {
+			env[string(name)] = string(value)
+		}
+		name, value = nil, nil
+		inValue, started = false, false
+	}
+	for i := 0; i < len(b); i++ {
+		switch x := b[i]; x {
+		case envVAR, envUSERVAR:
+			flush()
+			started = true
+		case envVALUE:
+			inValue = true
+		case envESC:
+			if i++; i < len(b) {
+				x = b[i]
+			}
+			fallthrough
+		default:
+			if !started {
+				continue
+			}
+			if inValue {
+				value = append(value, x)
+			} else {
+				name = append(name, x)
+			}
+		}
+	}
+	flush()
+}
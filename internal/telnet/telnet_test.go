@@ -0,0 +1,252 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package telnet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/go/internal/telnet/command"
+	"github.com/platinasystems/go/internal/telnet/option"
+)
+
+type peer struct {
+	in  bytes.Buffer
+	out bytes.Buffer
+}
+
+func (p *peer) Read(b []byte) (int, error)  { return p.in.Read(b) }
+func (p *peer) Write(b []byte) (int, error) { return p.out.Write(b) }
+
+const (
+	IAC  = command.IAC
+	WILL = command.WILL
+	WONT = command.WONT
+	DO   = command.DO
+	DONT = command.DONT
+	SB   = command.SB
+	SE   = command.SE
+)
+
+func expect(t *testing.T, what string, got, want []byte) {
+	t.Helper()
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: got %q, want %q", what, got, want)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	p := new(peer)
+	c := Server(p)
+	c.Write([]byte{'a', IAC, 'b', '\r', '\n', 'c', '\r', 'd', '\r'})
+	expect(t, "write", p.out.Bytes(),
+		[]byte{'a', IAC, IAC, 'b', '\r', '\n', 'c', '\r', 0, 'd', '\r'})
+
+	// a CR at the end of one write and LF at the start of the next
+	p.out.Reset()
+	c.Write([]byte{'\n', 'e', '\r'})
+	c.Write([]byte{'f'})
+	expect(t, "split write", p.out.Bytes(),
+		[]byte{'\n', 'e', '\r', 0, 'f'})
+
+	p.out.Reset()
+	p.in.Write([]byte{IAC, DO, option.BINARY})
+	ioutil.ReadAll(c)
+	p.out.Reset()
+	c.Write([]byte{IAC, '\r'})
+	expect(t, "binary write", p.out.Bytes(), []byte{IAC, IAC, '\r'})
+}
+
+func TestRead(t *testing.T) {
+	p := new(peer)
+	c := Server(p)
+	p.in.Write([]byte{
+		'a', IAC, IAC, 'b',
+		IAC, command.NOP,
+		'c', '\r', 0, 'd', '\r', '\n', 'e',
+		IAC, command.IP,
+	})
+	b, err := ioutil.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "read", b, []byte{'a', IAC, 'b', 'c', '\r', 'd', '\r',
+		'e', 0x03})
+	if p.out.Len() != 0 {
+		t.Errorf("unexpected reply %q", p.out.Bytes())
+	}
+}
+
+func TestRefuse(t *testing.T) {
+	p := new(peer)
+	c := Server(p)
+	p.in.Write([]byte{
+		IAC, WILL, option.LINEMODE,
+		IAC, DO, option.TIMING,
+		IAC, WONT, option.LINEMODE,
+	})
+	ioutil.ReadAll(c)
+	expect(t, "refuse", p.out.Bytes(), []byte{
+		IAC, DONT, option.LINEMODE,
+		IAC, WONT, option.TIMING,
+	})
+	if c.Him(option.LINEMODE) || c.Us(option.TIMING) {
+		t.Error("refused option enabled")
+	}
+}
+
+func TestNoLoop(t *testing.T) {
+	p := new(peer)
+	c := Server(p)
+	if err := c.Will(option.ECHO); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Will(option.ECHO); err == nil {
+		t.Error("expected error for repeated request")
+	}
+	expect(t, "will", p.out.Bytes(), []byte{IAC, WILL, option.ECHO})
+	p.out.Reset()
+	// the peer acknowledges twice; the second DO must be ignored
+	p.in.Write([]byte{IAC, DO, option.ECHO, IAC, DO, option.ECHO})
+	ioutil.ReadAll(c)
+	if p.out.Len() != 0 {
+		t.Errorf("unexpected reply %q", p.out.Bytes())
+	}
+	if !c.Us(option.ECHO) {
+		t.Error("ECHO not enabled")
+	}
+	// a request to disable, queued with a change of mind, and refused
+	if err := c.Wont(option.ECHO); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Will(option.ECHO); err != nil {
+		t.Fatal(err)
+	}
+	p.in.Write([]byte{IAC, DONT, option.ECHO})
+	ioutil.ReadAll(c)
+	expect(t, "requeue", p.out.Bytes(), []byte{
+		IAC, WONT, option.ECHO,
+		IAC, WILL, option.ECHO,
+	})
+	p.in.Write([]byte{IAC, DONT, option.ECHO})
+	ioutil.ReadAll(c)
+	if c.Us(option.ECHO) {
+		t.Error("ECHO still enabled")
+	}
+}
+
+func TestNAWS(t *testing.T) {
+	p := new(peer)
+	c := Server(p)
+	var cols, rows uint16
+	c.Resize = func(c, r uint16) { cols, rows = c, r }
+	c.Do(option.NAWS)
+	p.out.Reset()
+	p.in.Write([]byte{
+		IAC, WILL, option.NAWS,
+		IAC, SB, option.NAWS, 0, 132, 0, IAC, IAC, IAC, SE,
+		'x',
+	})
+	b, _ := ioutil.ReadAll(c)
+	expect(t, "data", b, []byte{'x'})
+	if p.out.Len() != 0 {
+		t.Errorf("unexpected reply %q", p.out.Bytes())
+	}
+	if cols != 132 || rows != 255 {
+		t.Errorf("resize: got %dx%d", cols, rows)
+	}
+	if c.Cols != 132 || c.Rows != 255 {
+		t.Errorf("size: got %dx%d", c.Cols, c.Rows)
+	}
+}
+
+func TestTerminalType(t *testing.T) {
+	p := new(peer)
+	c := Server(p)
+	c.Do(option.TYPE)
+	p.out.Reset()
+	p.in.Write([]byte{IAC, WILL, option.TYPE})
+	ioutil.ReadAll(c)
+	expect(t, "send", p.out.Bytes(), []byte{
+		IAC, SB, option.TYPE, sbSEND, IAC, SE,
+	})
+	if !c.pending() {
+		t.Error("not awaiting terminal type")
+	}
+	p.in.Write([]byte{IAC, SB, option.TYPE, sbIS})
+	p.in.WriteString("XTERM-256COLOR")
+	p.in.Write([]byte{IAC, SE})
+	ioutil.ReadAll(c)
+	if c.Term != "XTERM-256COLOR" {
+		t.Errorf("term: %q", c.Term)
+	}
+	if c.pending() {
+		t.Error("still pending")
+	}
+}
+
+func TestNewEnviron(t *testing.T) {
+	p := new(peer)
+	c := Server(p)
+	c.Do(option.NEWENV)
+	p.in.Write([]byte{IAC, WILL, option.NEWENV})
+	p.in.Write([]byte{IAC, SB, option.NEWENV, sbIS, envVAR})
+	p.in.WriteString("USER")
+	p.in.Write([]byte{envVALUE})
+	p.in.WriteString("root")
+	p.in.Write([]byte{envUSERVAR})
+	p.in.WriteString("A")
+	p.in.Write([]byte{envESC, envVAR, envVALUE, 'b', envVAR})
+	p.in.WriteString("EMPTY")
+	p.in.Write([]byte{IAC, SE})
+	ioutil.ReadAll(c)
+	for k, v := range map[string]string{
+		"USER":  "root",
+		"A\x00": "b",
+		"EMPTY": "",
+	} {
+		if got, found := c.Env[k]; !found || got != v {
+			t.Errorf("%q: got %q, want %q", k, got, v)
+		}
+	}
+	if c.pending() {
+		t.Error("still pending")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	c := Server(server)
+	go func() {
+		b := make([]byte, 3)
+		for {
+			if _, err := client.Read(b); err != nil {
+				return
+			}
+			if b[1] == DO {
+				client.Write([]byte{IAC, WONT, b[2]})
+			}
+		}
+	}()
+	c.Do(option.NAWS)
+	done := make(chan error)
+	go func() { done <- c.Negotiate(time.Second) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("negotiation didn't finish")
+	}
+	if c.Him(option.NAWS) {
+		t.Error("NAWS enabled")
+	}
+}
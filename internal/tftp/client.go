@@ -5,11 +5,8 @@
 package tftp
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net"
 	"os"
 	"strings"
@@ -22,21 +19,47 @@ const (
 	OpcodeData  = 3
 	OpcodeAck   = 4
 	OpcodeError = 5
+	OpcodeOack  = 6
 	Timeout     = 5 * time.Second
 	Retries     = 5
 	MaxPktSize  = 516
 )
 
+// DefaultOptions are those requested by a NewClient. A block size of 1428
+// fits an ethernet frame with either IPv4 or IPv6 headers.
+var DefaultOptions = Options{
+	BlockSize:  1428,
+	WindowSize: 8,
+}
+
 type Client struct {
 	addr *net.UDPAddr
+	// Options requested of the server; with nil, the client sends plain
+	// RFC 1350 requests. If the server rejects the options, the client
+	// retries without.
+	Options *Options
+	// Timeout and Retries of each packet; zero for Timeout and Retries.
+	Timeout time.Duration
+	Retries int
 }
 
-func GetFile(host string, source string, file string) (err error, size int) {
-	client, err := dialTFTP(host)
+// NewClient returns a client of the given "HOST[:PORT]" server that
+// requests the DefaultOptions.
+func NewClient(server string) (*Client, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "69")
+	}
+	c, err := dialTFTP(server)
 	if err != nil {
-		return err, 0
+		return nil, err
 	}
-	r, _, err := client.recv(source)
+	opts := DefaultOptions
+	c.Options = &opts
+	return c, nil
+}
+
+func GetFile(host string, source string, file string) (err error, size int) {
+	client, err := NewClient(host)
 	if err != nil {
 		return err, 0
 	}
@@ -45,7 +68,7 @@ func GetFile(host string, source string, file string) (err error, size int) {
 		return err, 0
 	}
 	defer f.Close()
-	s, err := r.WriteTo(f)
+	s, err := client.Get(source, f)
 	if err != nil {
 		return err, 0
 	}
@@ -54,22 +77,11 @@ func GetFile(host string, source string, file string) (err error, size int) {
 
 func GetFileRC(host string) (io.ReadCloser, error) {
 	x := strings.Split(host, ":")
-	ip := x[0] + ":69"
-	source := x[1]
-	client, err := dialTFTP(ip)
-	if err != nil {
-		return nil, err
-	}
-	r, _, err := client.recv(source)
+	client, err := NewClient(x[0])
 	if err != nil {
 		return nil, err
 	}
-	var b bytes.Buffer
-	if _, err := r.WriteTo(&b); err != nil {
-		return nil, err
-	}
-	rc := ioutil.NopCloser(&b)
-	return rc, nil
+	return client.Reader(x[1])
 }
 
 func dialTFTP(addr string) (*Client, error) {
@@ -80,54 +92,163 @@ func dialTFTP(addr string) (*Client, error) {
 	return &Client{addr: a}, nil
 }
 
-func (c Client) recv(filename string) (io.WriterTo, int, error) {
-	con, err := net.ListenUDP("udp", &net.UDPAddr{})
+// Get copies the remote file to w.
+func (c *Client) Get(filename string, w io.Writer) (int64, error) {
+	r, err := c.Reader(filename)
 	if err != nil {
-		return nil, 0, err
+		return 0, err
 	}
-	r := &receiverInfo{
-		conn:    con,
-		addr:    c.addr,
-		block:   1,
-		sendBuf: make([]byte, MaxPktSize),
-		recvBuf: make([]byte, MaxPktSize),
+	defer r.Close()
+	return io.Copy(w, r)
+}
+
+// Put copies r to the remote file; size is the expected length, or zero if
+// unknown.
+func (c *Client) Put(filename string, r io.Reader, size int64) (int64, error) {
+	w, err := c.writer(filename, size)
+	if err != nil {
+		return 0, err
 	}
-	p := setPktRRQ(r.sendBuf, filename)
-	l, a, err := r.receivePacket(p)
+	n, err := io.Copy(w, r)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// Reader returns the content of the remote file once the server accepts
+// the read request.
+func (c *Client) Reader(filename string) (io.ReadCloser, error) {
+	var o *Options
+	if c.Options != nil {
+		opts := *c.Options
+		opts.TransferSize = -1
+		o = &opts
+	}
+	t, p, err := c.request(OpcodeRead, filename, o)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
+	pr, pw := io.Pipe()
+	go func() {
+		defer t.conn.Close()
+		if p == nil {
+			// acknowledge the OACK
+			if err := t.send(pktAck(0)); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		_, err := t.receiveTo(pw, p)
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// Writer returns a writer of the remote file once the server accepts the
+// write request. The transfer completes with Close.
+func (c *Client) Writer(filename string) (io.WriteCloser, error) {
+	return c.writer(filename, 0)
+}
 
-	r.length = l
-	r.addr = a
-	return r, l, nil
+type writer struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (w *writer) Close() error {
+	w.PipeWriter.Close()
+	return <-w.done
+}
+
+func (c *Client) writer(filename string, size int64) (io.WriteCloser, error) {
+	var o *Options
+	if c.Options != nil {
+		opts := *c.Options
+		opts.TransferSize = size
+		o = &opts
+	}
+	t, _, err := c.request(OpcodeWrite, filename, o)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	w := &writer{pw, make(chan error, 1)}
+	go func() {
+		defer t.conn.Close()
+		_, err := t.sendFrom(pr)
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
 }
 
-//RRQ,WRQ |  2 bytes   |  string  | 1 byte  | string | 1 byte |
-//RRQ,WRQ | opcode 1,2 | filename |  0x0    |  mode  |  0x0   |
-//
-//Data    |  2 bytes   |  2 bytes | N byte  |
-//Data    |  opcode 3  |  block#  |  Data   |
-//
-//Ack     |  2 bytes   |  2 bytes |
-//Ack     |  opcode 4  |  block#  |
-//
-//Error   |  2 bytes   |  2 bytes | string  | 1 byte |
-//Error   |  opcode 5  | err code | err msg |  0x0   |
-
-func setPktRRQ(p []byte, filename string) int {
-	binary.BigEndian.PutUint16(p, OpcodeRead)
-	copy(p[2:], filename)
-	p[(2 + len(filename))] = 0
-	copy(p[(3+len(filename)):], "octet")
-	p[(8 + len(filename))] = 0
-	return (9 + len(filename))
+// request returns the transfer accepted by the server along with the first
+// data packet of a read request that wasn't answered with an OACK.
+func (c *Client) request(op uint16, filename string, o *Options) (*transfer,
+	[]byte, error) {
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	t := newTransfer(conn, c.addr, c.Timeout, c.Retries)
+	p, err := t.exchange(pktRequest(op, filename, o.encode()),
+		func(p []byte) bool {
+			switch opcode(p) {
+			case OpcodeOack:
+				return true
+			case OpcodeData:
+				return op == OpcodeRead && blockNumber(p) == 1
+			case OpcodeAck:
+				return op == OpcodeWrite && blockNumber(p) == 0
+			}
+			return false
+		})
+	if err != nil {
+		conn.Close()
+		if e, ok := err.(*Error); ok && e.Code == ErrOption && o != nil {
+			return c.request(op, filename, nil)
+		}
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	switch opcode(p) {
+	case OpcodeOack:
+		if err = t.accept(p, o); err != nil {
+			t.abort(ErrOption, err)
+			conn.Close()
+			return nil, nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		p = nil
+	case OpcodeAck:
+		p = nil
+	}
+	return t, p, nil
 }
 
-func setPktError(p []byte, errorCode uint16, message string) int {
-	binary.BigEndian.PutUint16(p, OpcodeError)
-	binary.BigEndian.PutUint16(p[2:], errorCode)
-	copy(p[4:], message)
-	p[(4 + len(message))] = 0
-	return (5 + len(message))
+// accept the server's OACK if its options are within those requested.
+func (t *transfer) accept(p []byte, req *Options) error {
+	if req == nil {
+		req = new(Options)
+	}
+	m, err := parseOack(p)
+	if err != nil {
+		return err
+	}
+	o, err := decodeOptions(m)
+	if err != nil {
+		return err
+	}
+	switch {
+	case o.BlockSize > req.BlockSize:
+		return fmt.Errorf("blksize %d exceeds %d", o.BlockSize,
+			req.BlockSize)
+	case o.WindowSize > req.WindowSize:
+		return fmt.Errorf("windowsize %d exceeds %d", o.WindowSize,
+			req.WindowSize)
+	case o.Timeout != 0 && o.Timeout != req.Timeout:
+		return fmt.Errorf("timeout %v differs from %v", o.Timeout,
+			req.Timeout)
+	}
+	t.setOptions(o)
+	return nil
 }
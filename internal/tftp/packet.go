@@ -0,0 +1,270 @@
+// Copyright © 2017 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package tftp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//RRQ,WRQ |  2 bytes   |  string  | 1 byte  | string | 1 byte | opt | 0 | value | 0 | ...
+//RRQ,WRQ | opcode 1,2 | filename |  0x0    |  mode  |  0x0   |
+//
+//Data    |  2 bytes   |  2 bytes | N byte  |
+//Data    |  opcode 3  |  block#  |  Data   |
+//
+//Ack     |  2 bytes   |  2 bytes |
+//Ack     |  opcode 4  |  block#  |
+//
+//Error   |  2 bytes   |  2 bytes | string  | 1 byte |
+//Error   |  opcode 5  | err code | err msg |  0x0   |
+//
+//Oack    |  2 bytes   | opt | 0 | value | 0 | ...
+//Oack    |  opcode 6  |
+
+// Error codes
+const (
+	ErrUndefined = iota
+	ErrNotFound
+	ErrAccess
+	ErrDiskFull
+	ErrIllegalOp
+	ErrUnknownTID
+	ErrExists
+	ErrNoUser
+	ErrOption // RFC 2347
+)
+
+// Block sizes of RFC 2348 and window sizes of RFC 7440
+const (
+	DefaultBlockSize = 512
+	MinBlockSize     = 8
+	MaxBlockSize     = 65464
+	MaxWindowSize    = 65535
+)
+
+var errShort = errors.New("short packet")
+
+// Error is a TFTP error packet received from, or sent to, the peer.
+type Error struct {
+	Code    uint16
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("tftp error %d: %s", e.Code, e.Message)
+}
+
+// Options are those of RFC 2347, 2348, 2349 and 7440. The zero value of
+// each means that it isn't requested.
+type Options struct {
+	// BlockSize is the "blksize" of data packets.
+	BlockSize int
+	// Timeout is the "timeout" before retransmission, in whole seconds.
+	Timeout time.Duration
+	// TransferSize is the "tsize" of the file. A reader requests it
+	// with -1.
+	TransferSize int64
+	// WindowSize is the "windowsize", the number of data packets sent
+	// before waiting for an acknowledgement.
+	WindowSize int
+}
+
+// encode the non-zero options
+func (o *Options) encode() map[string]string {
+	m := make(map[string]string)
+	if o == nil {
+		return m
+	}
+	if o.BlockSize > 0 {
+		m["blksize"] = strconv.Itoa(o.BlockSize)
+	}
+	if s := int(o.Timeout / time.Second); s > 0 {
+		m["timeout"] = strconv.Itoa(s)
+	}
+	if o.TransferSize < 0 {
+		m["tsize"] = "0"
+	} else if o.TransferSize > 0 {
+		m["tsize"] = strconv.FormatInt(o.TransferSize, 10)
+	}
+	if o.WindowSize > 0 {
+		m["windowsize"] = strconv.Itoa(o.WindowSize)
+	}
+	return m
+}
+
+// decodeOptions returns the recognized options ignoring all others as
+// required by RFC 2347.
+func decodeOptions(m map[string]string) (*Options, error) {
+	o := new(Options)
+	for k, v := range m {
+		switch k {
+		case "blksize", "timeout", "tsize", "windowsize":
+		default:
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q: %v", k, v, err)
+		}
+		switch k {
+		case "blksize":
+			if n < MinBlockSize || n > MaxBlockSize {
+				return nil, fmt.Errorf("blksize: %d: out of range", n)
+			}
+			o.BlockSize = int(n)
+		case "timeout":
+			if n < 1 || n > 255 {
+				return nil, fmt.Errorf("timeout: %d: out of range", n)
+			}
+			o.Timeout = time.Duration(n) * time.Second
+		case "tsize":
+			if n < 0 {
+				return nil, fmt.Errorf("tsize: %d: out of range", n)
+			}
+			o.TransferSize = n
+			if n == 0 {
+				// a reader's request for the size
+				o.TransferSize = -1
+			}
+		case "windowsize":
+			if n < 1 || n > MaxWindowSize {
+				return nil, fmt.Errorf("windowsize: %d: out of range",
+					n)
+			}
+			o.WindowSize = int(n)
+		}
+	}
+	return o, nil
+}
+
+func appendOptions(p []byte, m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p = append(p, k...)
+		p = append(p, 0)
+		p = append(p, m[k]...)
+		p = append(p, 0)
+	}
+	return p
+}
+
+// parseStrings splits the NUL terminated strings of the packet.
+func parseStrings(p []byte) ([]string, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	if p[len(p)-1] != 0 {
+		return nil, errors.New("unterminated string")
+	}
+	return strings.Split(string(p[:len(p)-1]), "\x00"), nil
+}
+
+func parseOptions(s []string) (map[string]string, error) {
+	if len(s)%2 != 0 {
+		return nil, errors.New("option without value")
+	}
+	m := make(map[string]string)
+	for i := 0; i < len(s); i += 2 {
+		m[strings.ToLower(s[i])] = s[i+1]
+	}
+	return m, nil
+}
+
+func pktRequest(op uint16, filename string, opts map[string]string) []byte {
+	p := make([]byte, 2, 2+len(filename)+1+len("octet")+1)
+	binary.BigEndian.PutUint16(p, op)
+	p = append(p, filename...)
+	p = append(p, 0)
+	p = append(p, "octet"...)
+	p = append(p, 0)
+	return appendOptions(p, opts)
+}
+
+// parseRequest returns the filename, mode and options of a RRQ or WRQ.
+func parseRequest(p []byte) (string, string, map[string]string, error) {
+	if len(p) < 4 {
+		return "", "", nil, errShort
+	}
+	s, err := parseStrings(p[2:])
+	if err != nil {
+		return "", "", nil, err
+	}
+	if len(s) < 2 {
+		return "", "", nil, errShort
+	}
+	opts, err := parseOptions(s[2:])
+	return s[0], strings.ToLower(s[1]), opts, err
+}
+
+func pktData(block uint16, data []byte) []byte {
+	p := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint16(p, OpcodeData)
+	binary.BigEndian.PutUint16(p[2:], block)
+	copy(p[4:], data)
+	return p
+}
+
+func pktAck(block uint16) []byte {
+	p := make([]byte, 4)
+	binary.BigEndian.PutUint16(p, OpcodeAck)
+	binary.BigEndian.PutUint16(p[2:], block)
+	return p
+}
+
+func pktOack(opts map[string]string) []byte {
+	p := make([]byte, 2)
+	binary.BigEndian.PutUint16(p, OpcodeOack)
+	return appendOptions(p, opts)
+}
+
+func parseOack(p []byte) (map[string]string, error) {
+	s, err := parseStrings(p[2:])
+	if err != nil {
+		return nil, err
+	}
+	return parseOptions(s)
+}
+
+func pktError(code uint16, message string) []byte {
+	p := make([]byte, 4, 5+len(message))
+	binary.BigEndian.PutUint16(p, OpcodeError)
+	binary.BigEndian.PutUint16(p[2:], code)
+	p = append(p, message...)
+	return append(p, 0)
+}
+
+func parseError(p []byte) *Error {
+	e := &Error{Code: ErrUndefined}
+	if len(p) >= 4 {
+		e.Code = binary.BigEndian.Uint16(p[2:])
+		e.Message = string(bytes.TrimRight(p[4:], "\x00"))
+	}
+	return e
+}
+
+func opcode(p []byte) uint16 {
+	if len(p) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(p)
+}
+
+func blockNumber(p []byte) uint16 {
+	if len(p) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(p[2:])
+}
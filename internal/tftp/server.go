@@ -0,0 +1,265 @@
+// Copyright © 2017 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package tftp
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Server serves the files of Root.
+type Server struct {
+	Root string
+	// Writable permits write requests to create or replace files.
+	Writable bool
+	// MaxBlockSize and MaxWindowSize limit those requested by clients;
+	// zero for the protocol limits.
+	MaxBlockSize, MaxWindowSize int
+	// Timeout and Retries of each packet unless negotiated by the client;
+	// zero for Timeout and Retries.
+	Timeout time.Duration
+	Retries int
+	// Logf, if not nil, records each request and its outcome.
+	Logf func(format string, args ...interface{})
+}
+
+// ListenAndServe serves requests received on the given UDP address, or
+// ":69" if empty.
+func (s *Server) ListenAndServe(addr string) error {
+	if len(addr) == 0 {
+		addr = ":69"
+	}
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", a)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return s.Serve(conn)
+}
+
+// Serve each request received on conn from its own transfer socket until
+// conn is closed.
+func (s *Server) Serve(conn *net.UDPConn) error {
+	buf := make([]byte, MaxPktSize)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+		go s.serve(addr, append([]byte{}, buf[:n]...))
+	}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+	}
+}
+
+func (s *Server) serve(peer *net.UDPAddr, p []byte) {
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		s.logf("%s: %v", peer, err)
+		return
+	}
+	defer conn.Close()
+	t := newTransfer(conn, peer, s.Timeout, s.Retries)
+	t.locked = true
+	op := opcode(p)
+	if op != OpcodeRead && op != OpcodeWrite {
+		t.abort(ErrIllegalOp, errors.New("illegal operation"))
+		return
+	}
+	name, mode, m, err := parseRequest(p)
+	if err != nil {
+		t.abort(ErrIllegalOp, err)
+		s.logf("%s: %v", peer, err)
+		return
+	}
+	if mode != "octet" && mode != "netascii" {
+		t.abort(ErrIllegalOp, errors.New(mode+": unsupported mode"))
+		return
+	}
+	req, err := decodeOptions(m)
+	if err != nil {
+		t.abort(ErrOption, err)
+		s.logf("%s: %s: %v", peer, name, err)
+		return
+	}
+	fn, err := s.resolve(name, op == OpcodeWrite)
+	if err != nil {
+		t.abort(errCode(err), errors.New(errMessage(err)))
+		s.logf("%s: %s: %v", peer, name, err)
+		return
+	}
+	var n int64
+	if op == OpcodeRead {
+		n, err = s.read(t, fn, req)
+	} else {
+		n, err = s.write(t, fn, req)
+	}
+	verb := map[uint16]string{OpcodeRead: "read", OpcodeWrite: "write"}[op]
+	if err != nil {
+		s.logf("%s: %s %s: %v", peer, verb, name, err)
+	} else {
+		s.logf("%s: %s %s: %d bytes", peer, verb, name, n)
+	}
+}
+
+// resolve the name to a path of Root, following symlinks, which mustn't
+// lead out of Root. A file to be written needn't exist, but its directory
+// must.
+func (s *Server) resolve(name string, write bool) (string, error) {
+	root, err := filepath.EvalSymlinks(s.Root)
+	if err != nil {
+		return "", err
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return "", err
+	}
+	fn := filepath.Join(root, filepath.Clean("/"+name))
+	if write {
+		dir, err := filepath.EvalSymlinks(filepath.Dir(fn))
+		if err != nil {
+			return "", err
+		}
+		fn = filepath.Join(dir, filepath.Base(fn))
+		if _, err = os.Lstat(fn); os.IsNotExist(err) {
+			return fn, inRoot(root, dir)
+		}
+	}
+	if fn, err = filepath.EvalSymlinks(fn); err != nil {
+		return "", err
+	}
+	return fn, inRoot(root, fn)
+}
+
+// inRoot returns a permission error unless the path is in the root.
+func inRoot(root, fn string) error {
+	rel, err := filepath.Rel(root, fn)
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &os.PathError{
+			Op:   "resolve",
+			Path: fn,
+			Err:  os.ErrPermission,
+		}
+	}
+	return nil
+}
+
+// negotiate the requested options, returning those to acknowledge.
+func (s *Server) negotiate(t *transfer, req *Options, size int64) map[string]string {
+	o := *req
+	if s.MaxBlockSize >= MinBlockSize && o.BlockSize > s.MaxBlockSize {
+		o.BlockSize = s.MaxBlockSize
+	}
+	if s.MaxWindowSize > 0 && o.WindowSize > s.MaxWindowSize {
+		o.WindowSize = s.MaxWindowSize
+	}
+	if o.TransferSize != 0 {
+		o.TransferSize = size
+	}
+	t.setOptions(&o)
+	return o.encode()
+}
+
+func (s *Server) read(t *transfer, fn string, req *Options) (int64, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		t.abort(errCode(err), errors.New(errMessage(err)))
+		return 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err == nil && !fi.Mode().IsRegular() {
+		err = errors.New("not a regular file")
+	}
+	if err != nil {
+		t.abort(ErrAccess, err)
+		return 0, err
+	}
+	if m := s.negotiate(t, req, fi.Size()); len(m) > 0 {
+		_, err = t.exchange(pktOack(m), func(p []byte) bool {
+			return opcode(p) == OpcodeAck && blockNumber(p) == 0
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return t.sendFrom(f)
+}
+
+// write to a temporary file that replaces the named file once the transfer
+// completes.
+func (s *Server) write(t *transfer, fn string, req *Options) (int64, error) {
+	if !s.Writable {
+		err := errors.New(errMessage(os.ErrPermission))
+		t.abort(ErrAccess, err)
+		return 0, err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(fn), "."+filepath.Base(fn))
+	if err != nil {
+		t.abort(errCode(err), errors.New(errMessage(err)))
+		return 0, err
+	}
+	tmp := f.Name()
+	t.commit = func() error {
+		err := f.Close()
+		if err == nil {
+			err = os.Chmod(tmp, 0644)
+		}
+		if err == nil {
+			err = os.Rename(tmp, fn)
+		}
+		return err
+	}
+	if m := s.negotiate(t, req, req.TransferSize); len(m) > 0 {
+		err = t.send(pktOack(m))
+	} else {
+		err = t.send(pktAck(0))
+	}
+	var n int64
+	if err == nil {
+		n, err = t.receiveTo(f, nil)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return n, err
+	}
+	t.dally()
+	return n, nil
+}
+
+func errCode(err error) uint16 {
+	switch {
+	case os.IsNotExist(err):
+		return ErrNotFound
+	case os.IsPermission(err):
+		return ErrAccess
+	}
+	return ErrUndefined
+}
+
+// errMessage doesn't reveal the server's path.
+func errMessage(err error) string {
+	switch errCode(err) {
+	case ErrNotFound:
+		return "file not found"
+	case ErrAccess:
+		return "access violation"
+	}
+	return "not defined"
+}
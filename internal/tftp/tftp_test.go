@@ -0,0 +1,324 @@
+// Copyright © 2017 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package tftp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOptions(t *testing.T) {
+	o := &Options{
+		BlockSize:    1428,
+		Timeout:      3 * time.Second,
+		TransferSize: -1,
+		WindowSize:   4,
+	}
+	name, mode, m, err := parseRequest(pktRequest(OpcodeRead, "boot/x",
+		o.encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "boot/x" || mode != "octet" {
+		t.Error("request:", name, mode)
+	}
+	got, err := decodeOptions(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, o) {
+		t.Errorf("got %+v, want %+v", got, o)
+	}
+	if _, err = decodeOptions(map[string]string{"blksize": "4"}); err == nil {
+		t.Error("blksize 4 accepted")
+	}
+	if _, err = decodeOptions(map[string]string{"unknown": "x"}); err != nil {
+		t.Error("unknown option not ignored:", err)
+	}
+	e := parseError(pktError(ErrNotFound, "file not found"))
+	if e.Code != ErrNotFound || e.Message != "file not found" {
+		t.Error("error:", e)
+	}
+}
+
+func testServer(t *testing.T, s *Server) string {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	go s.Serve(conn)
+	return conn.LocalAddr().String()
+}
+
+func TestTransfer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	addr := testServer(t, &Server{
+		Root:          dir,
+		Writable:      true,
+		MaxWindowSize: 4,
+	})
+	for _, tc := range []struct {
+		name string
+		size int
+		opts *Options
+	}{
+		{"plain", 3000, nil},
+		{"aligned", 4 * DefaultBlockSize, nil},
+		{"empty", 0, nil},
+		{"options", 100000, &Options{BlockSize: 1428, WindowSize: 8}},
+		{"aligned-window", 8 * 1024, &Options{BlockSize: 1024, WindowSize: 2}},
+	} {
+		c, err := NewClient(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Options = tc.opts
+		want := make([]byte, tc.size)
+		rand.Read(want)
+		fn := "/sub/../" + tc.name
+		n, err := c.Put(fn, bytes.NewReader(want), int64(len(want)))
+		if err != nil {
+			t.Fatal(tc.name, ": put: ", err)
+		}
+		if n != int64(len(want)) {
+			t.Error(tc.name, ": put", n, "bytes")
+		}
+		got, err := ioutil.ReadFile(filepath.Join(dir, tc.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Error(tc.name, ": put mismatch")
+		}
+		var b bytes.Buffer
+		if _, err = c.Get(tc.name, &b); err != nil {
+			t.Fatal(tc.name, ": get: ", err)
+		}
+		if !bytes.Equal(b.Bytes(), want) {
+			t.Error(tc.name, ": get mismatch")
+		}
+	}
+}
+
+// lossyRelay forwards one transfer between client and server, dropping
+// every nth packet in each direction.
+func lossyRelay(t *testing.T, server string, nth int) (string, func()) {
+	lo := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	a, err := net.ListenUDP("udp", lo)
+	if err != nil {
+		t.Skip(err)
+	}
+	b, err := net.ListenUDP("udp", lo)
+	if err != nil {
+		t.Skip(err)
+	}
+	srv, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var client, peer *net.UDPAddr
+	relay := func(from, to *net.UDPConn, fromClient bool) {
+		buf := make([]byte, 4+MaxBlockSize)
+		for i := 1; ; i++ {
+			n, addr, err := from.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			var dst *net.UDPAddr
+			if fromClient {
+				client, dst = addr, peer
+				if dst == nil {
+					dst = srv
+				}
+			} else {
+				peer, dst = addr, client
+			}
+			mu.Unlock()
+			if i%nth != 0 {
+				to.WriteToUDP(buf[:n], dst)
+			}
+		}
+	}
+	go relay(a, b, true)
+	go relay(b, a, false)
+	return a.LocalAddr().String(), func() {
+		a.Close()
+		b.Close()
+	}
+}
+
+func TestLoss(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	server := testServer(t, &Server{
+		Root:     dir,
+		Writable: true,
+		Timeout:  20 * time.Millisecond,
+		Retries:  10,
+	})
+	want := make([]byte, 50000)
+	rand.Read(want)
+	for _, window := range []int{1, 4} {
+		opts := &Options{BlockSize: 1000, WindowSize: window}
+		addr, stop := lossyRelay(t, server, 7)
+		c, err := NewClient(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Options = opts
+		c.Timeout = 20 * time.Millisecond
+		c.Retries = 10
+		_, err = c.Put("x", bytes.NewReader(want), int64(len(want)))
+		stop()
+		if err != nil {
+			t.Fatal("window", window, "put:", err)
+		}
+		addr, stop = lossyRelay(t, server, 5)
+		if c, err = NewClient(addr); err != nil {
+			t.Fatal(err)
+		}
+		c.Options = opts
+		c.Timeout = 20 * time.Millisecond
+		c.Retries = 10
+		var b bytes.Buffer
+		_, err = c.Get("x", &b)
+		stop()
+		if err != nil {
+			t.Fatal("window", window, "get:", err)
+		}
+		if !bytes.Equal(b.Bytes(), want) {
+			t.Error("window", window, "mismatch")
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := NewClient(testServer(t, &Server{Root: dir}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get("missing", ioutil.Discard); err == nil {
+		t.Error("missing file read")
+	}
+	_, err = c.Put("x", bytes.NewReader([]byte("x")), 1)
+	if err == nil {
+		t.Error("read only server accepted write")
+	}
+}
+
+func TestSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "root")
+	out := filepath.Join(dir, "out")
+	for _, d := range []string{root, out} {
+		if err = os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret := filepath.Join(out, "secret")
+	err = ioutil.WriteFile(secret, []byte("secret"), 0644)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(root, "file"),
+			[]byte("file"), 0644)
+	}
+	for _, l := range [][2]string{
+		{secret, "secret"},
+		{out, "out"},
+		{"file", "in"},
+	} {
+		if err == nil {
+			err = os.Symlink(l[0], filepath.Join(root, l[1]))
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(testServer(t, &Server{Root: root, Writable: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if _, err = c.Get("in", &b); err != nil || b.String() != "file" {
+		t.Errorf("symlink in root: %q, %v", b.String(), err)
+	}
+	for _, name := range []string{"secret", "out/secret"} {
+		if _, err = c.Get(name, ioutil.Discard); err == nil {
+			t.Error(name, ": read out of root")
+		}
+		_, err = c.Put(name, bytes.NewReader([]byte("x")), 1)
+		if err == nil {
+			t.Error(name, ": written out of root")
+		}
+	}
+	if _, err = c.Put("out/new", bytes.NewReader([]byte("x")), 1); err == nil {
+		t.Error("created out of root")
+	}
+	if b, _ := ioutil.ReadFile(secret); string(b) != "secret" {
+		t.Errorf("secret: %q", b)
+	}
+	if _, err = os.Stat(filepath.Join(out, "new")); err == nil {
+		t.Error("new file out of root")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	c, err := NewClient(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Timeout = 10 * time.Millisecond
+	c.Retries = 2
+	start := time.Now()
+	if _, err = c.Reader("x"); err == nil {
+		t.Fatal("no timeout")
+	}
+	// 10 + 20 + 40 ms of backoff, during which the request is sent thrice
+	if d := time.Since(start); d < 70*time.Millisecond {
+		t.Error("no backoff:", d)
+	}
+	buf := make([]byte, MaxPktSize)
+	n := 0
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	for {
+		if _, _, err = conn.ReadFromUDP(buf); err != nil {
+			break
+		}
+		n++
+	}
+	if n != 3 {
+		t.Error("sent", n, "requests")
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright © 2017 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package tftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// MaxTimeout limits the retransmission backoff.
+const MaxTimeout = 30 * time.Second
+
+var errTimeout = errors.New("tftp: timeout")
+
+// A transfer is one side of a read or write request after the initial
+// packet; it sends or receives the data with the negotiated options.
+type transfer struct {
+	conn *net.UDPConn
+	peer *net.UDPAddr
+	// locked is set once the peer's transfer identifier (port) is known
+	locked bool
+
+	blksize int
+	window  int
+	timeout time.Duration
+	retries int
+
+	buf []byte
+	// last are the packets retransmitted on timeout
+	last [][]byte
+	// commit, if not nil, is called by the receiver before acknowledging
+	// the final block so that the peer knows whether the data was kept
+	commit func() error
+}
+
+func newTransfer(conn *net.UDPConn, peer *net.UDPAddr, timeout time.Duration,
+	retries int) *transfer {
+	if timeout <= 0 {
+		timeout = Timeout
+	}
+	if retries <= 0 {
+		retries = Retries
+	}
+	return &transfer{
+		conn:    conn,
+		peer:    peer,
+		blksize: DefaultBlockSize,
+		window:  1,
+		timeout: timeout,
+		retries: retries,
+		buf:     make([]byte, 4+MaxBlockSize),
+	}
+}
+
+func (t *transfer) setOptions(o *Options) {
+	if o.BlockSize > 0 {
+		t.blksize = o.BlockSize
+	}
+	if o.WindowSize > 0 {
+		t.window = o.WindowSize
+	}
+	if o.Timeout > 0 {
+		t.timeout = o.Timeout
+	}
+}
+
+func (t *transfer) write(ps ...[]byte) error {
+	for _, p := range ps {
+		if _, err := t.conn.WriteToUDP(p, t.peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send packets that are retransmitted if there isn't a reply in time
+func (t *transfer) send(ps ...[]byte) error {
+	t.last = ps
+	return t.write(ps...)
+}
+
+// abort the transfer with an error packet
+func (t *transfer) abort(code uint16, err error) {
+	t.write(pktError(code, err.Error()))
+}
+
+// read the next packet from the peer, rejecting those of other transfers
+func (t *transfer) read(deadline time.Time) ([]byte, error) {
+	if err := t.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	for {
+		n, addr, err := t.conn.ReadFromUDP(t.buf)
+		if err != nil {
+			return nil, err
+		}
+		if !addr.IP.Equal(t.peer.IP) {
+			continue
+		}
+		if !t.locked {
+			t.peer, t.locked = addr, true
+		} else if addr.Port != t.peer.Port {
+			t.conn.WriteToUDP(pktError(ErrUnknownTID,
+				"unknown transfer id"), addr)
+			continue
+		}
+		if n >= 2 {
+			return t.buf[:n], nil
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+func backoff(timeout time.Duration) time.Duration {
+	if timeout *= 2; timeout > MaxTimeout {
+		timeout = MaxTimeout
+	}
+	return timeout
+}
+
+// exchange sends the packet then returns the first reply accepted by the
+// given function.
+func (t *transfer) exchange(p []byte, accept func([]byte) bool) ([]byte, error) {
+	if err := t.send(p); err != nil {
+		return nil, err
+	}
+	return t.await(accept)
+}
+
+// await returns the first accepted packet, retransmitting the last with
+// exponential backoff until that's received, or the peer sends an error,
+// or the retries are exhausted.
+func (t *transfer) await(accept func([]byte) bool) ([]byte, error) {
+	timeout := t.timeout
+	deadline := time.Now().Add(timeout)
+	for tries := 0; ; {
+		p, err := t.read(deadline)
+		if isTimeout(err) {
+			if tries++; tries > t.retries {
+				return nil, errTimeout
+			}
+			timeout = backoff(timeout)
+			deadline = time.Now().Add(timeout)
+			if err = t.write(t.last...); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if opcode(p) == OpcodeError {
+			return nil, parseError(p)
+		}
+		if accept(p) {
+			return p, nil
+		}
+	}
+}
+
+// sendFrom sends the data of the reader, a window of blocks at a time,
+// returning the number of bytes acknowledged by the peer.
+func (t *transfer) sendFrom(r io.Reader) (int64, error) {
+	var (
+		n      int64
+		block  uint16 // last acknowledged
+		window [][]byte
+		sizes  []int
+		eof    bool
+	)
+	b := make([]byte, t.blksize)
+	for {
+		for !eof && len(window) < t.window {
+			m, err := io.ReadFull(r, b)
+			switch err {
+			case nil:
+			case io.EOF, io.ErrUnexpectedEOF:
+				eof = true
+			default:
+				t.abort(ErrUndefined, err)
+				return n, err
+			}
+			next := block + uint16(len(window)) + 1
+			window = append(window, pktData(next, b[:m]))
+			sizes = append(sizes, m)
+		}
+		if len(window) == 0 {
+			return n, nil
+		}
+		if err := t.send(window...); err != nil {
+			return n, err
+		}
+		// An acknowledgement of fewer blocks than sent means the rest
+		// were lost; those are resent along with the next. Duplicates
+		// are ignored rather than answered to avoid the Sorcerer's
+		// Apprentice Syndrome of RFC 1123.
+		p, err := t.await(func(p []byte) bool {
+			d := int(blockNumber(p) - block)
+			return opcode(p) == OpcodeAck && d > 0 && d <= len(window)
+		})
+		if err != nil {
+			return n, err
+		}
+		d := int(blockNumber(p) - block)
+		for _, m := range sizes[:d] {
+			n += int64(m)
+		}
+		block = blockNumber(p)
+		window, sizes = window[d:], sizes[d:]
+	}
+}
+
+// receiveTo writes received data to w, acknowledging each window of blocks.
+// The optional packet is the first already received.
+func (t *transfer) receiveTo(w io.Writer, p []byte) (n int64, err error) {
+	var block uint16 // last received in sequence
+	count := 0       // blocks received since the last acknowledgement
+	timeout := t.timeout
+	deadline := time.Now().Add(timeout)
+	for tries := 0; ; p = nil {
+		if p == nil {
+			p, err = t.read(deadline)
+			if isTimeout(err) {
+				if tries++; tries > t.retries {
+					return n, errTimeout
+				}
+				timeout = backoff(timeout)
+				deadline = time.Now().Add(timeout)
+				count = 0
+				if err = t.write(t.last...); err != nil {
+					return n, err
+				}
+				continue
+			}
+			if err != nil {
+				return n, err
+			}
+		}
+		switch opcode(p) {
+		case OpcodeError:
+			return n, parseError(p)
+		case OpcodeData:
+		default:
+			continue
+		}
+		if blockNumber(p) != block+1 {
+			// a duplicate or gap; acknowledge the last in sequence
+			// so that the peer resends what follows
+			if err = t.send(pktAck(block)); err != nil {
+				return n, err
+			}
+			count = 0
+			continue
+		}
+		data := p[4:]
+		if len(data) > t.blksize {
+			err = fmt.Errorf("block %d exceeds %d bytes", block+1,
+				t.blksize)
+			t.abort(ErrIllegalOp, err)
+			return n, err
+		}
+		var m int
+		m, err = w.Write(data)
+		n += int64(m)
+		if err != nil {
+			t.abort(ErrUndefined, err)
+			return n, err
+		}
+		block++
+		count++
+		final := len(data) < t.blksize
+		if final && t.commit != nil {
+			if err = t.commit(); err != nil {
+				t.abort(ErrUndefined, err)
+				return n, err
+			}
+		}
+		if final || count == t.window {
+			if err = t.send(pktAck(block)); err != nil {
+				return n, err
+			}
+			count, tries = 0, 0
+			timeout = t.timeout
+			deadline = time.Now().Add(timeout)
+		}
+		if final {
+			return n, nil
+		}
+	}
+}
+
+// dally to acknowledge the final block again should the peer have missed
+// the first.
+func (t *transfer) dally() {
+	p, err := t.read(time.Now().Add(t.timeout))
+	if err == nil && opcode(p) == OpcodeData {
+		t.write(t.last...)
+	}
+}
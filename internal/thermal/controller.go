@@ -0,0 +1,137 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package thermal
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/log"
+)
+
+// Prefix of the redis fields of the policy settings, e.g.
+// thermal.host.curve.
+const Prefix = "thermal."
+
+// Controller steps the Policy of a fan controller daemon with the
+// temperatures reported by hset, or those of a Replay, and the daemon's
+// other sources. Its methods may be called concurrently, e.g. by the
+// daemon's update and its Hset.
+type Controller struct {
+	Policy *Policy
+
+	mutex       sync.Mutex
+	reported    Samples
+	replay      *Replay
+	replayStart time.Time
+	fault       string
+}
+
+// Replay the recording from now rather than the reported temperatures;
+// stop replaying if nil.
+func (c *Controller) Replay(r *Replay) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.replay, c.replayStart = r, time.Now()
+}
+
+// Report the value of the field, e.g. written by hset.
+func (c *Controller) Report(field, value string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.reported == nil {
+		c.reported = make(Samples)
+	}
+	c.reported[field] = Sample{Value: value, Time: time.Now()}
+}
+
+// Reported value of the field, empty if it hasn't been reported.
+func (c *Controller) Reported(field string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.reported[field].Value
+}
+
+// Step the policy to the reported samples, or those of the replay, and
+// then those of the source. Changes to and from the safe duty are logged.
+func (c *Controller) Step(src Source, now time.Time) Result {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	src = Sources{c.reported, src}
+	if c.replay != nil {
+		c.replay.Advance(c.replayStart, now.Sub(c.replayStart))
+		src = c.replay
+	}
+	r := c.Policy.Step(src, now)
+	if r.Fault != c.fault {
+		if r.Safe() {
+			log.Print("warning: fan safe speed: ", r.Fault)
+		} else {
+			log.Print("notice: fan control restored")
+		}
+		c.fault = r.Fault
+	}
+	return r
+}
+
+// Reset the policy, e.g. after manual fan control.
+func (c *Controller) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Policy.Reset()
+}
+
+// SetTrays of the policy to the status of n fan trays unless it has its
+// own.
+func (c *Controller) SetTrays(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.Policy.Trays) > 0 {
+		return
+	}
+	for i := 1; i <= n; i++ {
+		c.Policy.Trays = append(c.Policy.Trays,
+			"fan_tray."+strconv.Itoa(i)+".status")
+	}
+}
+
+// Set the policy's thermal.FIELD, with or without Prefix, and return the
+// Fields.
+func (c *Controller) Set(field, value string) (map[string]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	err := c.Policy.Set(strings.TrimPrefix(field, Prefix), value)
+	if err != nil {
+		return nil, err
+	}
+	return c.fields(), nil
+}
+
+// Fields of the policy settings as thermal.FIELD.
+func (c *Controller) Fields() map[string]string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.fields()
+}
+
+func (c *Controller) fields() map[string]string {
+	m := make(map[string]string)
+	for k, v := range c.Policy.Fields() {
+		m[Prefix+k] = v
+	}
+	return m
+}
+
+// Target of the named zone, 0 if none.
+func (c *Controller) Target(zone string) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if z := c.Policy.Zone(zone); z != nil {
+		return z.Target()
+	}
+	return 0
+}
@@ -0,0 +1,148 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package thermal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Point of a piecewise-linear curve, the fan duty in percent at the
+// temperature in °C.
+type Point struct {
+	Temp float64
+	Duty float64
+}
+
+// Curve is a piecewise-linear map of temperature to duty, sorted by
+// temperature. Below the first and above the last point, the duty is that of
+// the end point.
+type Curve []Point
+
+func (c Curve) Duty(t float64) float64 {
+	if len(c) == 0 {
+		return 0
+	}
+	if t <= c[0].Temp {
+		return c[0].Duty
+	}
+	for i := 1; i < len(c); i++ {
+		if t <= c[i].Temp {
+			a, b := c[i-1], c[i]
+			return a.Duty + (t-a.Temp)*(b.Duty-a.Duty)/(b.Temp-a.Temp)
+		}
+	}
+	return c[len(c)-1].Duty
+}
+
+// Shift the curve so that its first point is at the temperature.
+func (c Curve) Shift(t float64) {
+	if len(c) == 0 {
+		return
+	}
+	d := t - c[0].Temp
+	for i := range c {
+		c[i].Temp += d
+	}
+}
+
+// Check that the curve has increasing temperatures and valid duties.
+func (c Curve) Check() error {
+	if len(c) == 0 {
+		return fmt.Errorf("empty curve")
+	}
+	for i, p := range c {
+		if p.Duty < 0 || p.Duty > 100 {
+			return fmt.Errorf("%g: duty out of range", p.Duty)
+		}
+		if i > 0 && p.Temp <= c[i-1].Temp {
+			return fmt.Errorf("%g: temperature out of order", p.Temp)
+		}
+	}
+	return nil
+}
+
+func (c Curve) String() string {
+	var ss []string
+	for _, p := range c {
+		ss = append(ss, format(p.Temp)+":"+format(p.Duty))
+	}
+	return strings.Join(ss, ",")
+}
+
+// ParseCurve of comma separated TEMP:DUTY points, e.g. "50:30,65:100".
+func ParseCurve(s string) (Curve, error) {
+	var c Curve
+	for _, field := range strings.Split(s, ",") {
+		tv := strings.Split(strings.TrimSpace(field), ":")
+		if len(tv) != 2 {
+			return nil, fmt.Errorf("%s: invalid point", field)
+		}
+		t, err := strconv.ParseFloat(tv[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", field, err)
+		}
+		d, err := strconv.ParseFloat(tv[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", field, err)
+		}
+		c = append(c, Point{t, d})
+	}
+	if err := c.Check(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// PID controls the duty to hold the temperature at the Target. The error is
+// the temperature above the target so the gains are positive; Ki is per
+// second and Kd in seconds.
+type PID struct {
+	Target float64
+	Kp     float64
+	Ki     float64
+	Kd     float64
+
+	integral float64
+	prev     float64
+	primed   bool
+}
+
+// Duty after dt seconds at temperature t, limited to min and max. The
+// integral stops accumulating while the output is saturated.
+func (p *PID) Duty(t, dt, min, max float64) float64 {
+	e := t - p.Target
+	var de float64
+	if p.primed && dt > 0 {
+		de = (e - p.prev) / dt
+	}
+	p.prev, p.primed = e, true
+	i := p.integral + e*dt
+	u := p.Kp*e + p.Ki*i + p.Kd*de
+	if !(u > max && e > 0) && !(u < min && e < 0) {
+		p.integral = i
+	}
+	return clamp(p.Kp*e+p.Ki*p.integral+p.Kd*de, min, max)
+}
+
+// Reset the integral and derivative state.
+func (p *PID) Reset() {
+	p.integral, p.prev, p.primed = 0, 0, false
+}
+
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func format(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,137 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package thermal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Replay is a Source of recorded samples. The recording has a line for each
+// sample of the seconds since its start, the field and value. A field
+// without value is no longer reported. Blank lines and '#' comments are
+// ignored.
+//
+//	# hot host with a failing fan tray
+//	0	hwmon.front.temp.units.C	38.25
+//	0	host.temp.units.C		65
+//	0	fan_tray.1.status		ok.front->back
+//	30	host.temp.units.C		74.5
+//	60	fan_tray.1.status		not installed
+//	90	host.temp.units.C
+type Replay struct {
+	Samples
+	events []event
+	i      int
+}
+
+type event struct {
+	at    time.Duration
+	field string
+	value string
+	unset bool
+}
+
+// LoadReplay of the recording file.
+func LoadReplay(fn string) (*Replay, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := ReadReplay(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return r, nil
+}
+
+// ReadReplay of the recording.
+func ReadReplay(rd io.Reader) (*Replay, error) {
+	r := &Replay{Samples: make(Samples)}
+	scanner := bufio.NewScanner(rd)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%d: missing field", n)
+		}
+		secs, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %v", n, err)
+		}
+		e := event{
+			at:    time.Duration(secs * float64(time.Second)),
+			field: fields[1],
+			value: strings.Join(fields[2:], " "),
+			unset: len(fields) == 2,
+		}
+		if l := len(r.events); l > 0 && e.at < r.events[l-1].at {
+			return nil, fmt.Errorf("%d: out of order", n)
+		}
+		r.events = append(r.events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Advance the samples to those recorded by the elapsed time since start.
+func (r *Replay) Advance(start time.Time, elapsed time.Duration) {
+	for ; r.i < len(r.events) && r.events[r.i].at <= elapsed; r.i++ {
+		e := r.events[r.i]
+		if e.unset {
+			delete(r.Samples, e.field)
+		} else {
+			r.Samples[e.field] = Sample{e.value, start.Add(e.at)}
+		}
+	}
+}
+
+// Length of the recording.
+func (r *Replay) Length() time.Duration {
+	if len(r.events) == 0 {
+		return 0
+	}
+	return r.events[len(r.events)-1].at
+}
+
+// Simulate the policy with the replay at each tick through its length and
+// print the elapsed time, duty and controlling zone or fault with the zone
+// temperatures.
+func Simulate(w io.Writer, p *Policy, r *Replay, tick time.Duration) error {
+	if tick <= 0 {
+		return fmt.Errorf("%v: invalid tick", tick)
+	}
+	var start time.Time
+	for elapsed := time.Duration(0); elapsed <= r.Length(); elapsed += tick {
+		r.Advance(start, elapsed)
+		res := p.Step(r, start.Add(elapsed))
+		var temps []string
+		for _, z := range p.Zones {
+			if t, found := res.Temps[z.Name]; found {
+				temps = append(temps, z.Name+"="+format(t))
+			}
+		}
+		_, err := fmt.Fprintf(w, "%v\t%.1f%%\t%s\t%s\n", elapsed,
+			res.Duty, strings.Join(temps, " "), res.Status())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
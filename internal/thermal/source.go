@@ -0,0 +1,118 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package thermal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/redis"
+)
+
+// ErrAbsent is returned by a Source for a field that hasn't been reported.
+var ErrAbsent = errors.New("absent")
+
+// Sample is a reported field value and when it was reported.
+type Sample struct {
+	Value string
+	Time  time.Time
+}
+
+// Source of the policy's sensor and fan tray fields.
+type Source interface {
+	Get(field string) (Sample, error)
+}
+
+// Samples is a Source of the last reported values, e.g. those written by
+// redis hset or replayed from a recording.
+type Samples map[string]Sample
+
+func (m Samples) Get(field string) (Sample, error) {
+	s, found := m[field]
+	if !found {
+		return Sample{}, ErrAbsent
+	}
+	return s, nil
+}
+
+// TimeSuffix of the field that a publisher may update with the unix time
+// of each sample, e.g. fan_tray.1.hwmon.front.temp.units.C.time.
+const TimeSuffix = ".time"
+
+// Redis is a Source of the fields of the default hash published by other
+// daemons. A sample is as old as its TimeSuffix field; otherwise, as its
+// publisher may only write changes, since its value last changed.
+type Redis struct{}
+
+var changed = struct {
+	sync.Mutex
+	samples Samples
+}{samples: make(Samples)}
+
+func (Redis) Get(field string) (Sample, error) {
+	s, err := redis.Hget(redis.DefaultHash, field)
+	if err != nil {
+		return Sample{}, err
+	}
+	if len(s) == 0 {
+		return Sample{}, ErrAbsent
+	}
+	ts, err := redis.Hget(redis.DefaultHash, field+TimeSuffix)
+	if err != nil {
+		ts = ""
+	}
+	return redisSample(field, s, ts, time.Now())
+}
+
+// redisSample of the field's value and the publisher's time, if any;
+// otherwise, the time its value changed to that of the last call.
+func redisSample(field, value, ts string, now time.Time) (Sample, error) {
+	if len(ts) > 0 {
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return Sample{}, fmt.Errorf("%s%s: %v", field, TimeSuffix,
+				err)
+		}
+		return Sample{value, time.Unix(sec, 0)}, nil
+	}
+	changed.Lock()
+	defer changed.Unlock()
+	last, found := changed.samples[field]
+	if !found || last.Value != value {
+		last = Sample{value, now}
+		changed.samples[field] = last
+	}
+	return last, nil
+}
+
+// MinRpm of the fan controller's policy as published in thermal.minrpm, or
+// DefaultMinRpm if it isn't.
+func MinRpm() float64 {
+	s, err := Redis{}.Get(Prefix + "minrpm")
+	if err != nil {
+		return DefaultMinRpm
+	}
+	f, err := strconv.ParseFloat(s.Value, 64)
+	if err != nil || f < 0 {
+		return DefaultMinRpm
+	}
+	return f
+}
+
+// Sources tries each source in turn for a field until one has it.
+type Sources []Source
+
+func (l Sources) Get(field string) (Sample, error) {
+	for _, src := range l {
+		s, err := src.Get(field)
+		if err != ErrAbsent {
+			return s, err
+		}
+	}
+	return Sample{}, ErrAbsent
+}
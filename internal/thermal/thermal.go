@@ -0,0 +1,478 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package thermal is a closed-loop fan control policy. Each zone maps the
+// hottest of its sensors to a fan duty by a piecewise-linear curve or PID
+// loop and the fans run at the greatest duty of all zones. A fan tray that
+// isn't ok, a failed sensor or stale data force the safe duty instead.
+package thermal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMinRpm of the fans of an ok fan tray.
+const DefaultMinRpm = 2000
+
+// Zone is a set of sensors, redis fields of °C, and the map of their hottest
+// temperature to fan duty.
+type Zone struct {
+	Name    string
+	Sensors []string
+	// Curve of the zone if it doesn't have a PID.
+	Curve Curve `json:",omitempty"`
+	PID   *PID  `json:",omitempty"`
+	// Hyst is the degrees that the temperature must fall below that of
+	// the last increase before the duty decreases.
+	Hyst float64
+	// Min and Max duty in percent; Max is 100 if zero.
+	Min float64
+	Max float64
+
+	duty   float64
+	peak   float64
+	primed bool
+}
+
+// Policy of all zones of a fan controller.
+//
+//	{
+//		"Zones": [
+//			{
+//				"Name": "host",
+//				"Sensors": [ "host.temp.units.C" ],
+//				"Curve": [
+//					{ "Temp": 70, "Duty": 30 },
+//					{ "Temp": 85, "Duty": 100 }
+//				],
+//				"Hyst": 5,
+//				"Min": 30
+//			}
+//		],
+//		"Trays": [ "fan_tray.1.status", "fan_tray.2.status" ],
+//		"MinRpm": 2000,
+//		"Stale": 60
+//	}
+type Policy struct {
+	Zones []*Zone
+	// Trays are status fields of the fan trays; one that is reported
+	// but not "ok" forces the safe duty.
+	Trays []string
+	// MinRpm of the fans of an ok fan tray; DefaultMinRpm if zero.
+	MinRpm float64
+	// Safe duty in percent; 100 if zero.
+	Safe float64
+	// Stale is the seconds after which a sensor's sample forces the safe
+	// duty; zero if samples never go stale.
+	Stale float64
+
+	// seen sensors that fail rather than skip their zone when absent
+	seen map[string]bool
+	last time.Time
+}
+
+// Result of a policy step.
+type Result struct {
+	// Duty in percent
+	Duty float64
+	// Zone with the greatest duty, empty if Safe
+	Zone string
+	// Fault that forced the safe duty
+	Fault string
+	// Temps is the hottest sensor of each zone with a sample.
+	Temps map[string]float64
+}
+
+func (r *Result) Safe() bool { return len(r.Fault) > 0 }
+
+// Status is the controlling zone or, if Safe, "safe: FAULT".
+func (r *Result) Status() string {
+	if r.Safe() {
+		return "safe: " + r.Fault
+	}
+	return r.Zone
+}
+
+// LoadPolicy from the JSON file.
+func LoadPolicy(fn string) (*Policy, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	p := new(Policy)
+	if err = json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	if err = p.Check(); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return p, nil
+}
+
+// Check for unnamed, duplicate or incomplete zones and invalid duties.
+func (p *Policy) Check() error {
+	if err := checkDuty(p.Safe); err != nil {
+		return fmt.Errorf("safe: %v", err)
+	}
+	if p.Stale < 0 {
+		return fmt.Errorf("stale: negative")
+	}
+	if p.MinRpm < 0 {
+		return fmt.Errorf("minrpm: negative")
+	}
+	names := make(map[string]bool)
+	for _, z := range p.Zones {
+		switch {
+		case len(z.Name) == 0:
+			return fmt.Errorf("unnamed zone")
+		case strings.Contains(z.Name, "."):
+			return fmt.Errorf("%s: invalid zone name", z.Name)
+		case names[z.Name]:
+			return fmt.Errorf("%s: duplicate zone", z.Name)
+		case len(z.Sensors) == 0:
+			return fmt.Errorf("%s: no sensors", z.Name)
+		}
+		names[z.Name] = true
+		if err := z.check(); err != nil {
+			return fmt.Errorf("%s: %v", z.Name, err)
+		}
+	}
+	return nil
+}
+
+func (z *Zone) check() error {
+	if z.PID == nil {
+		if err := z.Curve.Check(); err != nil {
+			return err
+		}
+	}
+	if err := checkDuty(z.Min); err != nil {
+		return fmt.Errorf("min: %v", err)
+	}
+	if err := checkDuty(z.Max); err != nil {
+		return fmt.Errorf("max: %v", err)
+	}
+	if z.Min > z.max() {
+		return fmt.Errorf("min exceeds max")
+	}
+	if z.Hyst < 0 {
+		return fmt.Errorf("hyst: negative")
+	}
+	return nil
+}
+
+func checkDuty(d float64) error {
+	if d < 0 || d > 100 {
+		return fmt.Errorf("%g: duty out of range", d)
+	}
+	return nil
+}
+
+func (z *Zone) max() float64 {
+	if z.Max == 0 {
+		return 100
+	}
+	return z.Max
+}
+
+func (p *Policy) safe() float64 {
+	if p.Safe == 0 {
+		return 100
+	}
+	return p.Safe
+}
+
+func (p *Policy) minRpm() float64 {
+	if p.MinRpm == 0 {
+		return DefaultMinRpm
+	}
+	return p.MinRpm
+}
+
+// Zone of the name or nil if there isn't one.
+func (p *Policy) Zone(name string) *Zone {
+	for _, z := range p.Zones {
+		if z.Name == name {
+			return z
+		}
+	}
+	return nil
+}
+
+// Target is the PID setpoint or the temperature of the first point of the
+// curve.
+func (z *Zone) Target() float64 {
+	if z.PID != nil {
+		return z.PID.Target
+	}
+	if len(z.Curve) > 0 {
+		return z.Curve[0].Temp
+	}
+	return 0
+}
+
+// Step the policy to the current samples of the source.
+func (p *Policy) Step(src Source, now time.Time) Result {
+	if p.seen == nil {
+		p.seen = make(map[string]bool)
+	}
+	var dt float64
+	if !p.last.IsZero() {
+		dt = now.Sub(p.last).Seconds()
+	}
+	p.last = now
+
+	r := Result{Temps: make(map[string]float64)}
+	fault := func(format string, args ...interface{}) {
+		if len(r.Fault) == 0 {
+			r.Fault = fmt.Sprintf(format, args...)
+		}
+	}
+	for _, field := range p.Trays {
+		s, err := src.Get(field)
+		switch {
+		case err == ErrAbsent:
+		case err != nil:
+			fault("%s: %v", field, err)
+		case !strings.HasPrefix(s.Value, "ok"):
+			fault("%s: %s", field, s.Value)
+		}
+	}
+	for _, z := range p.Zones {
+		t, found, err := p.temp(z, src, now)
+		if err != nil {
+			fault("%s: %v", z.Name, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		r.Temps[z.Name] = t
+		if d := z.step(t, dt); len(r.Zone) == 0 || d > r.Duty {
+			r.Duty, r.Zone = d, z.Name
+		}
+	}
+	if len(r.Zone) == 0 {
+		fault("no samples")
+	}
+	if r.Safe() {
+		r.Duty, r.Zone = p.safe(), ""
+	}
+	return r
+}
+
+// temp of the zone's hottest sensor; not found if none have been reported.
+func (p *Policy) temp(z *Zone, src Source, now time.Time) (float64,
+	bool, error) {
+	var t float64
+	found := false
+	for _, field := range z.Sensors {
+		s, err := src.Get(field)
+		if err == ErrAbsent && !p.seen[field] {
+			continue
+		}
+		if err != nil {
+			return 0, false, fmt.Errorf("%s: %v", field, err)
+		}
+		p.seen[field] = true
+		if p.Stale > 0 && now.Sub(s.Time).Seconds() > p.Stale {
+			return 0, false, fmt.Errorf("%s: stale", field)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(s.Value), 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("%s: %v", field, err)
+		}
+		if !found || v > t {
+			t, found = v, true
+		}
+	}
+	return t, found, nil
+}
+
+// step the zone to temperature t after dt seconds. The duty only decreases
+// once t has fallen Hyst degrees below that of the last increase.
+func (z *Zone) step(t, dt float64) float64 {
+	var d float64
+	if z.PID != nil {
+		d = z.PID.Duty(t, dt, z.Min, z.max())
+	} else {
+		d = clamp(z.Curve.Duty(t), z.Min, z.max())
+	}
+	if !z.primed || d >= z.duty || t <= z.peak-z.Hyst {
+		z.duty, z.peak, z.primed = d, t, true
+	}
+	return z.duty
+}
+
+// Reset the zones to their current curves, e.g. after manual fan control.
+func (p *Policy) Reset() {
+	for _, z := range p.Zones {
+		z.primed = false
+		if z.PID != nil {
+			z.PID.Reset()
+		}
+	}
+	p.last = time.Time{}
+}
+
+// Fields of the policy settings, e.g. "host.curve", "safe".
+func (p *Policy) Fields() map[string]string {
+	m := map[string]string{
+		"safe":   format(p.safe()),
+		"stale":  format(p.Stale),
+		"minrpm": format(p.minRpm()),
+	}
+	for _, z := range p.Zones {
+		k := z.Name + "."
+		m[k+"sensors"] = strings.Join(z.Sensors, ",")
+		if z.PID != nil {
+			m[k+"pid"] = fmt.Sprintf("%s,%s,%s,%s",
+				format(z.PID.Target), format(z.PID.Kp),
+				format(z.PID.Ki), format(z.PID.Kd))
+		} else {
+			m[k+"curve"] = z.Curve.String()
+		}
+		m[k+"target"] = format(z.Target())
+		m[k+"hyst"] = format(z.Hyst)
+		m[k+"min"] = format(z.Min)
+		m[k+"max"] = format(z.max())
+	}
+	return m
+}
+
+// Set a field of the policy to the value:
+//
+//	safe		duty of faults
+//	stale		seconds after which a sample is stale, 0 never
+//	minrpm		fan speed below which a fan tray isn't ok
+//	ZONE.sensors	comma separated redis fields
+//	ZONE.curve	comma separated TEMP:DUTY points
+//	ZONE.pid	TARGET,KP,KI,KD
+//	ZONE.target	PID setpoint or temperature to shift the curve to
+//	ZONE.hyst	degrees of hysteresis
+//	ZONE.min	minimum duty
+//	ZONE.max	maximum duty
+func (p *Policy) Set(field, value string) error {
+	value = strings.TrimSpace(value)
+	switch field {
+	case "safe":
+		f, err := parseDuty(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", field, err)
+		}
+		p.Safe = f
+		return nil
+	case "stale":
+		f, err := strconv.ParseFloat(value, 64)
+		if err == nil && f < 0 {
+			err = fmt.Errorf("%s: negative", value)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", field, err)
+		}
+		p.Stale = f
+		return nil
+	case "minrpm":
+		f, err := strconv.ParseFloat(value, 64)
+		if err == nil && f < 0 {
+			err = fmt.Errorf("%s: negative", value)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", field, err)
+		}
+		p.MinRpm = f
+		return nil
+	}
+	i := strings.LastIndex(field, ".")
+	if i < 0 {
+		return fmt.Errorf("%s: unknown", field)
+	}
+	z := p.Zone(field[:i])
+	if z == nil {
+		return fmt.Errorf("%s: no such zone", field[:i])
+	}
+	// change a copy so that an invalid setting doesn't stick
+	nz := *z
+	if z.PID != nil {
+		pid := *z.PID
+		nz.PID = &pid
+	}
+	nz.Curve = append(Curve{}, z.Curve...)
+	var err error
+	switch param := field[i+1:]; param {
+	case "sensors":
+		nz.Sensors = nil
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); len(s) > 0 {
+				nz.Sensors = append(nz.Sensors, s)
+			}
+		}
+		if len(nz.Sensors) == 0 {
+			err = fmt.Errorf("no sensors")
+		}
+	case "curve":
+		nz.Curve, err = ParseCurve(value)
+		nz.PID = nil
+	case "pid":
+		var f []float64
+		for _, s := range strings.Split(value, ",") {
+			var v float64
+			v, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				break
+			}
+			f = append(f, v)
+		}
+		if err == nil && len(f) != 4 {
+			err = fmt.Errorf("%s: want TARGET,KP,KI,KD", value)
+		}
+		if err == nil {
+			nz.PID = &PID{Target: f[0], Kp: f[1], Ki: f[2], Kd: f[3]}
+		}
+	case "target", "hyst", "min", "max":
+		var f float64
+		f, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			break
+		}
+		switch param {
+		case "target":
+			if nz.PID != nil {
+				nz.PID.Target = f
+			} else {
+				nz.Curve.Shift(f)
+			}
+		case "hyst":
+			nz.Hyst = f
+		case "min":
+			nz.Min = f
+		case "max":
+			nz.Max = f
+		}
+	default:
+		return fmt.Errorf("%s: unknown", field)
+	}
+	if err == nil {
+		err = nz.check()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", field, err)
+	}
+	nz.primed = false
+	*z = nz
+	return nil
+}
+
+func parseDuty(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return f, checkDuty(f)
+}
@@ -0,0 +1,304 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package thermal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCurve(t *testing.T) {
+	c, err := ParseCurve("50:30, 60:50,70:100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []struct{ t, d float64 }{
+		{20, 30},
+		{50, 30},
+		{55, 40},
+		{65, 75},
+		{90, 100},
+	} {
+		if d := c.Duty(x.t); d != x.d {
+			t.Errorf("%g°C: %g%%, want %g%%", x.t, d, x.d)
+		}
+	}
+	if s := c.String(); s != "50:30,60:50,70:100" {
+		t.Error("String:", s)
+	}
+	for _, s := range []string{"", "50", "60:30,50:100", "50:130"} {
+		if _, err = ParseCurve(s); err == nil {
+			t.Errorf("%q: parsed", s)
+		}
+	}
+}
+
+func TestPID(t *testing.T) {
+	p := &PID{Target: 60, Kp: 4, Ki: 0.5}
+	if d := p.Duty(60, 0, 20, 100); d != 20 {
+		t.Error("at target:", d)
+	}
+	// the integral raises the duty while hot
+	d0 := p.Duty(65, 10, 20, 100)
+	d1 := p.Duty(65, 10, 20, 100)
+	if d0 != 45 || d1 != 70 {
+		t.Error("hot:", d0, d1)
+	}
+	// but stops winding up once saturated
+	for i := 0; i < 100; i++ {
+		p.Duty(80, 10, 20, 100)
+	}
+	if d := p.Duty(60, 10, 20, 100); d == 100 {
+		t.Error("wound up:", d)
+	}
+}
+
+func testPolicy() *Policy {
+	return &Policy{
+		Zones: []*Zone{
+			{
+				Name: "hwmon",
+				Sensors: []string{
+					"hwmon.front.temp.units.C",
+					"hwmon.rear.temp.units.C",
+				},
+				Curve: Curve{{40, 30}, {60, 100}},
+				Hyst:  5,
+				Min:   30,
+			},
+			{
+				Name:    "host",
+				Sensors: []string{"host.temp.units.C"},
+				Curve:   Curve{{70, 30}, {80, 80}},
+				Hyst:    5,
+				Min:     30,
+			},
+		},
+		Trays: []string{"fan_tray.1.status", "fan_tray.2.status"},
+		Stale: 30,
+	}
+}
+
+func TestStep(t *testing.T) {
+	p := testPolicy()
+	if err := p.Check(); err != nil {
+		t.Fatal(err)
+	}
+	var now time.Time
+	src := Samples{}
+	set := func(field, value string) {
+		src[field] = Sample{value, now}
+	}
+	step := func(want float64, zone string) {
+		t.Helper()
+		r := p.Step(src, now)
+		if r.Duty != want || r.Zone != zone {
+			t.Errorf("%v: %g%% %q %q, want %g%% %q", now, r.Duty,
+				r.Zone, r.Fault, want, zone)
+		}
+	}
+
+	step(100, "")
+	set("hwmon.front.temp.units.C", "38")
+	set("hwmon.rear.temp.units.C", "50")
+	set("fan_tray.1.status", "ok.front->back")
+	step(65, "hwmon")
+
+	// hysteresis holds the duty until 5° below the last increase
+	set("hwmon.rear.temp.units.C", "47")
+	step(65, "hwmon")
+	set("hwmon.rear.temp.units.C", "44")
+	step(44, "hwmon")
+
+	// an unreported host is skipped but a reported one controls
+	set("host.temp.units.C", "78")
+	step(70, "host")
+
+	// the host stopped reporting
+	now = now.Add(time.Minute)
+	set("hwmon.front.temp.units.C", "38")
+	set("hwmon.rear.temp.units.C", "44")
+	if r := p.Step(src, now); r.Duty != 100 ||
+		!strings.Contains(r.Fault, "stale") {
+		t.Errorf("stale: %+v", r)
+	}
+	set("host.temp.units.C", "60")
+	step(44, "hwmon")
+
+	// a once reported sensor has failed if absent
+	delete(src, "host.temp.units.C")
+	step(100, "")
+	set("host.temp.units.C", "bad")
+	step(100, "")
+	set("host.temp.units.C", "60")
+	step(44, "hwmon")
+
+	set("fan_tray.2.status", "not installed")
+	if r := p.Step(src, now); r.Fault !=
+		"fan_tray.2.status: not installed" {
+		t.Errorf("tray: %+v", r)
+	}
+}
+
+func TestSet(t *testing.T) {
+	p := testPolicy()
+	for _, x := range []struct{ field, value string }{
+		{"safe", "90"},
+		{"stale", "10"},
+		{"minrpm", "1500"},
+		{"host.target", "60"},
+		{"hwmon.min", "40"},
+		{"hwmon.sensors", "a, b"},
+	} {
+		if err := p.Set(x.field, x.value); err != nil {
+			t.Error(err)
+		}
+	}
+	m := p.Fields()
+	for k, want := range map[string]string{
+		"safe":          "90",
+		"stale":         "10",
+		"minrpm":        "1500",
+		"host.curve":    "60:30,70:80",
+		"host.target":   "60",
+		"hwmon.min":     "40",
+		"hwmon.max":     "100",
+		"hwmon.sensors": "a,b",
+	} {
+		if m[k] != want {
+			t.Errorf("%s: %q, want %q", k, m[k], want)
+		}
+	}
+	if err := p.Set("host.pid", "65,4,0.5,0"); err != nil {
+		t.Fatal(err)
+	}
+	if z := p.Zone("host"); z.PID == nil || z.Target() != 65 {
+		t.Error("pid:", z.PID)
+	}
+	for _, x := range []struct{ field, value string }{
+		{"safe", "110"},
+		{"other.min", "10"},
+		{"host.speed", "10"},
+		{"hwmon.min", "x"},
+		{"hwmon.max", "30"},
+		{"hwmon.curve", "60:30,50:100"},
+		{"host.pid", "65,4"},
+	} {
+		if err := p.Set(x.field, x.value); err == nil {
+			t.Errorf("%s=%s: set", x.field, x.value)
+		}
+	}
+	if z := p.Zone("hwmon"); z.Min != 40 || z.Max != 0 {
+		t.Error("invalid setting stuck:", z.Min, z.Max)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	r, err := ReadReplay(strings.NewReader(`
+# host heats up then its reports stop
+0	hwmon.front.temp.units.C	38
+0	fan_tray.1.status		ok.front->back
+10	host.temp.units.C		75
+20	host.temp.units.C		72
+30	hwmon.front.temp.units.C	39.5
+30	fan_tray.1.status		not installed
+40	fan_tray.1.status		ok.back->front
+40	host.temp.units.C
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = Simulate(&out, testPolicy(), r, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	want := `0s	30.0%	hwmon=38	hwmon
+10s	55.0%	hwmon=38 host=75	host
+20s	55.0%	hwmon=38 host=72	host
+30s	100.0%	hwmon=39.5 host=72	safe: fan_tray.1.status: not installed
+40s	100.0%	hwmon=39.5	safe: host: host.temp.units.C: absent
+`
+	if s := out.String(); s != want {
+		t.Errorf("got:\n%swant:\n%s", s, want)
+	}
+	if _, err = ReadReplay(strings.NewReader("10 a 1\n5 a 2\n")); err == nil {
+		t.Error("out of order")
+	}
+}
+
+func TestController(t *testing.T) {
+	c := &Controller{Policy: testPolicy()}
+	c.Policy.Trays = nil
+	c.SetTrays(3)
+	if len(c.Policy.Trays) != 3 ||
+		c.Policy.Trays[2] != "fan_tray.3.status" {
+		t.Error("trays:", c.Policy.Trays)
+	}
+
+	now := time.Now()
+	own := Samples{"hwmon.front.temp.units.C": {"45", now}}
+	c.Report("host.temp.units.C", "75")
+	if v := c.Reported("host.temp.units.C"); v != "75" {
+		t.Errorf("reported: %q", v)
+	}
+	if r := c.Step(own, now); r.Duty != 55 || r.Status() != "host" {
+		t.Errorf("step: %+v", r)
+	}
+
+	m, err := c.Set(Prefix+"host.target", "60")
+	if err != nil || m[Prefix+"host.target"] != "60" {
+		t.Errorf("set: %v, %v", m[Prefix+"host.target"], err)
+	}
+	if v := c.Target("host"); v != 60 {
+		t.Error("target:", v)
+	}
+	if _, err = c.Set("bogus.min", "1"); err == nil {
+		t.Error("set bogus zone")
+	}
+
+	r, err := ReadReplay(strings.NewReader(`
+0	hwmon.front.temp.units.C	45
+0	host.temp.units.C		65
+0	fan_tray.1.status		not installed
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Replay(r)
+	res := c.Step(own, time.Now())
+	if !res.Safe() || res.Status() !=
+		"safe: fan_tray.1.status: not installed" {
+		t.Errorf("replay: %+v", res)
+	}
+	c.Replay(nil)
+	c.Reset()
+	if r := c.Step(own, time.Now()); r.Safe() || r.Zone != "host" {
+		t.Errorf("after replay: %+v", r)
+	}
+}
+
+func TestRedisSample(t *testing.T) {
+	const field = "test.temp.units.C"
+	now := time.Unix(1000, 0)
+	s, err := redisSample(field, "40", "900", now)
+	if err != nil || !s.Time.Equal(time.Unix(900, 0)) {
+		t.Fatal("published time:", s, err)
+	}
+	if _, err = redisSample(field, "40", "x", now); err == nil {
+		t.Error("bad time accepted")
+	}
+	// without a published time, samples age from their last change
+	redisSample(field, "40", "", now)
+	s, _ = redisSample(field, "40", "", now.Add(time.Minute))
+	if !s.Time.Equal(now) {
+		t.Error("unchanged:", s)
+	}
+	s, _ = redisSample(field, "41", "", now.Add(time.Minute))
+	if !s.Time.Equal(now.Add(time.Minute)) {
+		t.Error("changed:", s)
+	}
+}
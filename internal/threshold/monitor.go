@@ -0,0 +1,177 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package threshold
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alarm of a sensor.
+type Alarm struct {
+	Level
+	// Upper if the alarm is of an upper limit.
+	Upper bool
+	// Limit that the value crossed; zero for states.
+	Limit float64
+}
+
+// Event of a sensor's alarm change.
+type Event struct {
+	*Sensor
+	Time  time.Time
+	Value string
+	From  Alarm
+	To    Alarm
+}
+
+// Priority of the event's log message.
+func (e *Event) Priority() string {
+	switch e.To.Level {
+	case Warning:
+		return "warning"
+	case Critical:
+		return "crit"
+	case Fatal:
+		return "alert"
+	}
+	return "notice"
+}
+
+// String of the event, e.g.
+//
+//	hwmon.front.temp.units.C: critical, 66.5 above 65
+//	hwmon.front.temp.units.C: ok, 58.25, was critical
+//	psu1.status: critical, powered_off
+func (e *Event) String() string {
+	s := fmt.Sprint(e.Field, ": ", e.To.Level, ", ", e.Value)
+	if e.To.Level != OK && len(e.States) == 0 {
+		side := " below "
+		if e.To.Upper {
+			side = " above "
+		}
+		s += side + strconv.FormatFloat(e.To.Limit, 'f', -1, 64)
+	}
+	if e.To.Level < e.From.Level {
+		s += fmt.Sprint(", was ", e.From.Level)
+	}
+	return s
+}
+
+type state struct {
+	Alarm
+	pending Alarm
+	count   int
+}
+
+// Monitor the alarms of the table's sensors.
+type Monitor struct {
+	*Table
+	states map[string]*state
+}
+
+func NewMonitor(t *Table) *Monitor {
+	return &Monitor{
+		Table:  t,
+		states: make(map[string]*state),
+	}
+}
+
+// Alarm of the sensor field.
+func (m *Monitor) Alarm(field string) Alarm {
+	if st, found := m.states[field]; found {
+		return st.Alarm
+	}
+	return Alarm{}
+}
+
+// Worst alarm level of all sensors.
+func (m *Monitor) Worst() Level {
+	worst := OK
+	for _, st := range m.states {
+		if st.Level > worst {
+			worst = st.Level
+		}
+	}
+	return worst
+}
+
+// Update the alarms of the sensors with the values of get and return their
+// changes. A sensor without a value keeps its alarm.
+func (m *Monitor) Update(get func(field string) (string, error),
+	now time.Time) []Event {
+	var events []Event
+	for _, s := range m.Sensors {
+		v, err := get(s.Field)
+		v = strings.TrimSpace(v)
+		if err != nil || len(v) == 0 {
+			continue
+		}
+		st, found := m.states[s.Field]
+		if !found {
+			st = new(state)
+			m.states[s.Field] = st
+		}
+		var a Alarm
+		if len(s.States) > 0 {
+			a.Level = s.state(v)
+		} else {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			a = s.alarm(f, st.Alarm)
+		}
+		if a.Level == st.Level {
+			st.count = 0
+			continue
+		}
+		if a == st.pending {
+			st.count++
+		} else {
+			st.pending, st.count = a, 1
+		}
+		if st.count < s.Debounce {
+			continue
+		}
+		events = append(events, Event{
+			Sensor: s,
+			Time:   now,
+			Value:  v,
+			From:   st.Alarm,
+			To:     a,
+		})
+		st.Alarm, st.count = a, 0
+	}
+	return events
+}
+
+// alarm of the value given the current alarm, which holds until the value
+// is back within its limit by the sensor's hysteresis.
+func (s *Sensor) alarm(v float64, cur Alarm) Alarm {
+	var upper, lower Alarm
+	for lvl := Fatal; lvl > OK; lvl-- {
+		t, found := s.Upper.At(lvl)
+		held := cur.Upper && cur.Level >= lvl && v > t-s.Hyst
+		if found && (v >= t || held) {
+			upper = Alarm{lvl, true, t}
+			break
+		}
+	}
+	for lvl := Fatal; lvl > OK; lvl-- {
+		t, found := s.Lower.At(lvl)
+		held := !cur.Upper && cur.Level >= lvl && v < t+s.Hyst
+		if found && (v <= t || held) {
+			lower = Alarm{lvl, false, t}
+			break
+		}
+	}
+	if lower.Level > upper.Level {
+		return lower
+	}
+	return upper
+}
@@ -0,0 +1,91 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package threshold
+
+import (
+	"github.com/platinasystems/atsock"
+)
+
+// SelSocket is the name of the ipmigod rpc socket that adds SEL records.
+const SelSocket = "ipmigod"
+
+// IPMI event/reading type codes
+const (
+	threshold = 0x01
+	severity  = 0x07
+	deassert  = 0x80
+)
+
+// offsets of the threshold event going beyond the lower or upper limit of
+// each level
+var lowerOffset = [...]uint8{Warning: 0x00, Critical: 0x02, Fatal: 0x04}
+var upperOffset = [...]uint8{Warning: 0x07, Critical: 0x09, Fatal: 0x0b}
+
+// Sel is the IPMI system event record of the event; the SEL fills the
+// record id and timestamp. Limit events are threshold type and assert the
+// new level or deassert the old one if less severe. State events are
+// generic severity type. The readings aren't in SDR units so the event
+// data has only the offset.
+func (e *Event) Sel() [16]byte {
+	var rec [16]byte
+	rec[2] = 0x02 // system event record
+	rec[7] = 0x20 // BMC
+	rec[9] = 0x04 // IPMI 2.0 event message format
+	rec[10] = e.Type
+	rec[11] = e.Num
+	if len(e.States) > 0 {
+		rec[12] = severity
+		rec[13] = severityOffset(e.From.Level, e.To.Level)
+	} else {
+		a := e.To
+		rec[12] = threshold
+		if e.To.Level < e.From.Level {
+			a = e.From
+			rec[12] |= deassert
+		}
+		if a.Upper {
+			rec[13] = upperOffset[a.Level]
+		} else {
+			rec[13] = lowerOffset[a.Level]
+		}
+	}
+	rec[14] = 0xff
+	rec[15] = 0xff
+	return rec
+}
+
+func severityOffset(from, to Level) uint8 {
+	switch to {
+	case Warning:
+		if from == OK {
+			return 1
+		}
+		return 4
+	case Critical:
+		if from < Critical {
+			return 2
+		}
+		return 5
+	case Fatal:
+		return 3
+	}
+	return 0
+}
+
+// SendSel adds the event's record to the ipmigod SEL and returns its
+// record id. Events of sensors without number are ignored.
+func SendSel(e *Event) (uint16, error) {
+	if e.Num == 0 {
+		return 0, nil
+	}
+	cl, err := atsock.NewRpcClient(SelSocket)
+	if err != nil {
+		return 0, err
+	}
+	defer cl.Close()
+	var id uint16
+	err = cl.Call("Sel.Add", e.Sel(), &id)
+	return id, err
+}
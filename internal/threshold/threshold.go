@@ -0,0 +1,218 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package threshold compares the redis values of sensors to their warning,
+// critical and fatal limits and generates events of their alarm changes.
+package threshold
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Level of an alarm.
+type Level int
+
+const (
+	OK Level = iota
+	Warning
+	Critical
+	Fatal
+)
+
+var levels = []string{"ok", "warning", "critical", "fatal"}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levels) {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levels[l]
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(b []byte) error {
+	lvl, err := ParseLevel(string(b))
+	if err == nil {
+		*l = lvl
+	}
+	return err
+}
+
+// ParseLevel of its name, e.g. "critical".
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levels {
+		if s == name {
+			return Level(i), nil
+		}
+	}
+	return OK, fmt.Errorf("%s: invalid level", s)
+}
+
+// Limit of each level on one side of the sensor value, nil if none.
+type Limit struct {
+	Warning  *float64 `json:",omitempty"`
+	Critical *float64 `json:",omitempty"`
+	Fatal    *float64 `json:",omitempty"`
+}
+
+// At returns the limit of the level, if any.
+func (l *Limit) At(lvl Level) (float64, bool) {
+	var p *float64
+	switch lvl {
+	case Warning:
+		p = l.Warning
+	case Critical:
+		p = l.Critical
+	case Fatal:
+		p = l.Fatal
+	}
+	if p == nil {
+		return 0, false
+	}
+	return *p, true
+}
+
+// Sensor is a redis field with a numeric value and its limits, or a status
+// string and the level of each of its states.
+type Sensor struct {
+	Field string
+	Lower Limit
+	Upper Limit
+	// States of a status string by value prefix, e.g.
+	// "powered_off": "critical"; others are ok.
+	States map[string]Level `json:",omitempty"`
+	// Hyst is how far the value must come back within a limit to clear
+	// its alarm.
+	Hyst float64
+	// Debounce is the number of consecutive samples of a new level
+	// before the alarm changes; 0 and 1 change at once.
+	Debounce int
+	// Type and Num are the IPMI sensor type and number of the SEL
+	// events, none if Num is 0.
+	Type uint8
+	Num  uint8
+}
+
+// Table of the machine's sensors.
+//
+//	{
+//		"Sensors": [
+//			{
+//				"Field": "hwmon.front.temp.units.C",
+//				"Upper": { "Warning": 55, "Critical": 65 },
+//				"Hyst": 2,
+//				"Debounce": 2,
+//				"Type": 1,
+//				"Num": 1
+//			},
+//			{
+//				"Field": "psu1.status",
+//				"States": { "powered_off": "critical" }
+//			}
+//		]
+//	}
+type Table struct {
+	Sensors []*Sensor
+}
+
+// LoadTable from the JSON file.
+func LoadTable(fn string) (*Table, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	t := new(Table)
+	if err = json.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	if err = t.Check(); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return t, nil
+}
+
+// Check for unnamed or duplicate sensors and out of order limits.
+func (t *Table) Check() error {
+	fields := make(map[string]bool)
+	nums := make(map[uint8]bool)
+	for _, s := range t.Sensors {
+		switch {
+		case len(s.Field) == 0:
+			return fmt.Errorf("unnamed sensor")
+		case fields[s.Field]:
+			return fmt.Errorf("%s: duplicate sensor", s.Field)
+		case s.Num != 0 && nums[s.Num]:
+			return fmt.Errorf("%s: duplicate number %d", s.Field,
+				s.Num)
+		case s.Hyst < 0:
+			return fmt.Errorf("%s: negative hysteresis", s.Field)
+		case s.Debounce < 0:
+			return fmt.Errorf("%s: negative debounce", s.Field)
+		}
+		fields[s.Field] = true
+		nums[s.Num] = s.Num != 0
+		if err := s.check(); err != nil {
+			return fmt.Errorf("%s: %v", s.Field, err)
+		}
+	}
+	return nil
+}
+
+func (s *Sensor) check() error {
+	var lower, upper []float64
+	for lvl := Warning; lvl <= Fatal; lvl++ {
+		if v, found := s.Lower.At(lvl); found {
+			if len(lower) > 0 && v > lower[len(lower)-1] {
+				return fmt.Errorf("lower %v above less severe", lvl)
+			}
+			lower = append(lower, v)
+		}
+		if v, found := s.Upper.At(lvl); found {
+			if len(upper) > 0 && v < upper[len(upper)-1] {
+				return fmt.Errorf("upper %v below less severe", lvl)
+			}
+			upper = append(upper, v)
+		}
+	}
+	if len(lower) > 0 && len(upper) > 0 && lower[0] >= upper[0] {
+		return fmt.Errorf("lower limits above upper")
+	}
+	if len(s.States) > 0 && len(lower)+len(upper) > 0 {
+		return fmt.Errorf("both states and limits")
+	}
+	return nil
+}
+
+// state of the status value, that of its longest matching prefix.
+func (s *Sensor) state(v string) Level {
+	var prefixes []string
+	for prefix := range s.States {
+		if strings.HasPrefix(v, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) == 0 {
+		return OK
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+	return s.States[prefixes[0]]
+}
+
+// Sensor of the field or nil if there isn't one.
+func (t *Table) Sensor(field string) *Sensor {
+	for _, s := range t.Sensors {
+		if s.Field == field {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package threshold
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+const testTable = `{
+	"Sensors": [
+		{
+			"Field": "hwmon.front.temp.units.C",
+			"Lower": { "Warning": 5 },
+			"Upper": { "Warning": 55, "Critical": 65, "Fatal": 75 },
+			"Hyst": 2,
+			"Debounce": 2,
+			"Type": 1,
+			"Num": 1
+		},
+		{
+			"Field": "psu1.status",
+			"States": {
+				"powered_off": "critical",
+				"not_installed": "warning"
+			},
+			"Type": 8,
+			"Num": 2
+		}
+	]
+}`
+
+func loadTestTable(t *testing.T) *Table {
+	tbl := new(Table)
+	if err := json.Unmarshal([]byte(testTable), tbl); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Check(); err != nil {
+		t.Fatal(err)
+	}
+	return tbl
+}
+
+func TestCheck(t *testing.T) {
+	tbl := loadTestTable(t)
+	if s := tbl.Sensor("psu1.status"); s == nil ||
+		s.States["powered_off"] != Critical {
+		t.Error("states:", s)
+	}
+	for _, s := range []string{
+		`{"Sensors":[{"Upper":{"Warning":1}}]}`,
+		`{"Sensors":[{"Field":"a"},{"Field":"a"}]}`,
+		`{"Sensors":[{"Field":"a","Num":1},{"Field":"b","Num":1}]}`,
+		`{"Sensors":[{"Field":"a","Upper":{"Warning":60,"Critical":50}}]}`,
+		`{"Sensors":[{"Field":"a","Lower":{"Warning":5,"Critical":10}}]}`,
+		`{"Sensors":[{"Field":"a","Lower":{"Warning":60},"Upper":{"Warning":50}}]}`,
+		`{"Sensors":[{"Field":"a","States":{"x":"bad"}}]}`,
+	} {
+		tbl = new(Table)
+		err := json.Unmarshal([]byte(s), tbl)
+		if err == nil {
+			err = tbl.Check()
+		}
+		if err == nil {
+			t.Error("checked:", s)
+		}
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	m := NewMonitor(loadTestTable(t))
+	values := map[string]string{}
+	var now time.Time
+	update := func(temp, status string, want ...string) {
+		t.Helper()
+		values["hwmon.front.temp.units.C"] = temp
+		values["psu1.status"] = status
+		now = now.Add(time.Second)
+		events := m.Update(func(field string) (string, error) {
+			return values[field], nil
+		}, now)
+		var got []string
+		for _, e := range events {
+			got = append(got, e.String())
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s %s: got %q, want %q", temp, status, got,
+				want)
+		}
+	}
+
+	update("40", "powered_on")
+	// debounced
+	update("66", "powered_on")
+	update("66.5", "powered_off",
+		"hwmon.front.temp.units.C: critical, 66.5 above 65",
+		"psu1.status: critical, powered_off")
+	// held by hysteresis
+	update("64", "powered_off")
+	update("64", "powered_off")
+	update("62", "not_installed",
+		"psu1.status: warning, not_installed, was critical")
+	update("62", "",
+		"hwmon.front.temp.units.C: warning, 62 above 55, was critical")
+	if lvl := m.Worst(); lvl != Warning {
+		t.Error("worst:", lvl)
+	}
+	update("80", "")
+	update("80", "",
+		"hwmon.front.temp.units.C: fatal, 80 above 75")
+	update("20", "powered_on",
+		"psu1.status: ok, powered_on, was warning")
+	update("4", "powered_on")
+	update("4", "powered_on",
+		"hwmon.front.temp.units.C: warning, 4 below 5, was fatal")
+	if a := m.Alarm("hwmon.front.temp.units.C"); a.Upper || a.Limit != 5 {
+		t.Error("alarm:", a)
+	}
+}
+
+func TestSel(t *testing.T) {
+	s := &Sensor{Field: "a", Type: 1, Num: 3}
+	e := &Event{
+		Sensor: s,
+		From:   Alarm{Level: Warning, Upper: true, Limit: 55},
+		To:     Alarm{Level: Critical, Upper: true, Limit: 65},
+	}
+	want := [16]byte{2: 0x02, 7: 0x20, 9: 0x04, 10: 1, 11: 3, 12: 0x01,
+		13: 0x09, 14: 0xff, 15: 0xff}
+	if rec := e.Sel(); rec != want {
+		t.Errorf("assert: % x", rec)
+	}
+	e.From, e.To = e.To, Alarm{}
+	want[12] = 0x81
+	if rec := e.Sel(); rec != want {
+		t.Errorf("deassert: % x", rec)
+	}
+	e.To = Alarm{Level: Warning}
+	e.From = Alarm{Level: Critical}
+	s.States = map[string]Level{"x": Warning}
+	want[12], want[13] = 0x07, 4
+	if rec := e.Sel(); rec != want {
+		t.Errorf("state: % x", rec)
+	}
+}
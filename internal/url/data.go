@@ -0,0 +1,44 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package url
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// openData returns the content of an RFC 2397 URL,
+//
+//	data:[MEDIATYPE][;base64],DATA
+//
+// The media type is ignored.
+func openData(s string) (io.ReadCloser, error) {
+	s = strings.TrimPrefix(s, "data:")
+	comma := strings.Index(s, ",")
+	if comma < 0 {
+		return nil, errors.New("data: missing comma")
+	}
+	header, data := s[:comma], s[comma+1:]
+	var b []byte
+	var err error
+	if strings.HasSuffix(strings.ToLower(header), ";base64") {
+		data, err = url.PathUnescape(data)
+		if err == nil {
+			b, err = base64.StdEncoding.DecodeString(data)
+		}
+	} else {
+		data, err = url.PathUnescape(data)
+		b = []byte(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
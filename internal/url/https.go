@@ -0,0 +1,152 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package url
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// CABundle is a PEM file of the certificate authorities trusted for https
+// instead of the system's; the CA_BUNDLE environment variable names another.
+//
+// CertPins is a file of pinned server certificates, one SHA-256 fingerprint
+// per line, in hexadecimal with or without colons as printed by
+// "openssl x509 -noout -fingerprint -sha256". The CERT_PINS environment
+// variable may instead have a comma separated list of fingerprints. If
+// there are any pins, https only accepts servers that present a pinned
+// certificate, either itself or one that signs its chain.
+const (
+	CABundle = "/etc/goes/ca.pem"
+	CertPins = "/etc/goes/cert-pins"
+)
+
+var errNotPinned = errors.New("server certificate isn't pinned")
+
+// HTTPClient returns a client with the configured CAs and pins.
+func HTTPClient() (*http.Client, error) {
+	conf, err := tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: conf,
+		},
+	}, nil
+}
+
+func tlsConfig() (*tls.Config, error) {
+	conf := new(tls.Config)
+	fn := os.Getenv("CA_BUNDLE")
+	explicit := len(fn) > 0
+	if !explicit {
+		fn = CABundle
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err == nil {
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%s: no certificates", fn)
+		}
+	} else if explicit || !os.IsNotExist(err) {
+		return nil, err
+	}
+	pins, err := certPins()
+	if err != nil {
+		return nil, err
+	}
+	if len(pins) > 0 {
+		// Go's verification is replaced, not skipped, by that of the
+		// pinned chain.
+		conf.InsecureSkipVerify = true
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPinned(cs, pins)
+		}
+	}
+	return conf, nil
+}
+
+func verifyPinned(cs tls.ConnectionState, pins map[string]bool) error {
+	certs := cs.PeerCertificates
+	if len(certs) == 0 {
+		return errNotPinned
+	}
+	if pins[fingerprint(certs[0])] {
+		return nil
+	}
+	for i, cert := range certs[1:] {
+		if !pins[fingerprint(cert)] {
+			continue
+		}
+		opts := x509.VerifyOptions{
+			DNSName:       cs.ServerName,
+			Roots:         x509.NewCertPool(),
+			Intermediates: x509.NewCertPool(),
+		}
+		opts.Roots.AddCert(cert)
+		for _, intermediate := range certs[1 : i+1] {
+			opts.Intermediates.AddCert(intermediate)
+		}
+		_, err := certs[0].Verify(opts)
+		return err
+	}
+	return errNotPinned
+}
+
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// certPins returns the set of normalized fingerprints.
+func certPins() (map[string]bool, error) {
+	var lines []string
+	if s := os.Getenv("CERT_PINS"); len(s) > 0 {
+		lines = strings.Split(s, ",")
+	} else if f, err := os.Open(CertPins); err == nil {
+		defer f.Close()
+		scan := bufio.NewScanner(f)
+		for scan.Scan() {
+			lines = append(lines, scan.Text())
+		}
+		if err = scan.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	pins := make(map[string]bool)
+	for _, line := range lines {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if i := strings.Index(line, "="); i >= 0 {
+			// "SHA256 Fingerprint=..." from openssl
+			line = line[i+1:]
+		}
+		line = strings.ToLower(strings.Replace(line, ":", "", -1))
+		if len(line) == 0 {
+			continue
+		}
+		if b, err := hex.DecodeString(line); err != nil ||
+			len(b) != sha256.Size {
+			return nil, fmt.Errorf("%q: invalid fingerprint", line)
+		}
+		pins[line] = true
+	}
+	return pins, nil
+}
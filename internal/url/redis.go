@@ -0,0 +1,91 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package url
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/platinasystems/redis"
+)
+
+// redisKeyField returns the key and field of redis://KEY/FIELD; an empty KEY
+// is the default hash and an empty FIELD is a string KEY.
+func redisKeyField(u *url.URL) (string, string) {
+	key := u.Host
+	field := strings.TrimPrefix(u.Path, "/")
+	if len(key) == 0 {
+		key = redis.DefaultHash
+	}
+	return key, field
+}
+
+// openRedis returns the value of the key or hash field followed by newline.
+func openRedis(u *url.URL) (io.ReadCloser, error) {
+	if err := redis.IsReady(); err != nil {
+		return nil, err
+	}
+	key, field := redisKeyField(u)
+	var s string
+	var err error
+	if len(field) == 0 {
+		s, err = redis.Get(key)
+	} else {
+		var i int
+		if i, err = redis.Hexists(key, field); err == nil && i == 0 {
+			err = fmt.Errorf("%s: not found", u)
+		}
+		if err == nil {
+			s, err = redis.Hget(key, field)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader(s + "\n")), nil
+}
+
+// redisWriter sets the key or field with Close; like hset, this is how a
+// published value is changed by the daemon that owns it.
+type redisWriter struct {
+	buf bytes.Buffer
+	u   *url.URL
+}
+
+func createRedis(u *url.URL, isAppend bool) (io.WriteCloser, error) {
+	if err := redis.IsReady(); err != nil {
+		return nil, err
+	}
+	w := &redisWriter{u: u}
+	if isAppend {
+		r, err := openRedis(u)
+		if err == nil {
+			_, err = io.Copy(&w.buf, r)
+		}
+		if err != nil {
+			return nil, err
+		}
+		w.buf.Truncate(w.buf.Len() - 1)
+	}
+	return w, nil
+}
+
+func (w *redisWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *redisWriter) Close() error {
+	key, field := redisKeyField(w.u)
+	v := strings.TrimSuffix(w.buf.String(), "\n")
+	var err error
+	if len(field) == 0 {
+		_, err = redis.Set(key, v)
+	} else {
+		_, err = redis.Hset(key, field, v)
+	}
+	return err
+}
@@ -0,0 +1,504 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package url
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SSH is the OpenSSH client run for these URLs,
+//
+//	scp://[USER@]HOST[:PORT]/PATH
+//	sftp://[USER@]HOST[:PORT]/PATH
+//
+// with public key authentication only; SSH_IDENTITY may name the private key
+// file, otherwise ssh uses those of ~/.ssh and its configuration, including
+// known hosts. As with scp and sftp, the path is relative to the remote home
+// directory unless it begins with a second slash.
+const SSH = "ssh"
+
+func sshArgs(u *url.URL, opts ...string) []string {
+	args := append([]string{"-o", "BatchMode=yes"}, opts...)
+	if id := os.Getenv("SSH_IDENTITY"); len(id) > 0 {
+		args = append(args, "-i", id)
+	}
+	if port := u.Port(); len(port) > 0 {
+		args = append(args, "-p", port)
+	}
+	host := u.Hostname()
+	if u.User != nil && len(u.User.Username()) > 0 {
+		host = u.User.Username() + "@" + host
+	}
+	return append(args, host)
+}
+
+func remotePath(u *url.URL) string {
+	p := strings.TrimPrefix(u.Path, "/")
+	if len(p) == 0 {
+		return "."
+	}
+	return p
+}
+
+// sshCmd starts ssh with the given remote command.
+type sshCmd struct {
+	*exec.Cmd
+	r      *bufio.Reader
+	w      io.WriteCloser
+	stderr bytes.Buffer
+}
+
+func startSSH(u *url.URL, opts []string, remote ...string) (*sshCmd, error) {
+	c := new(sshCmd)
+	c.Cmd = exec.Command(SSH, append(sshArgs(u, opts...), remote...)...)
+	c.Cmd.Stderr = &c.stderr
+	w, err := c.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = c.Start(); err != nil {
+		return nil, err
+	}
+	c.r, c.w = bufio.NewReader(r), w
+	return c, nil
+}
+
+func (c *sshCmd) Read(p []byte) (int, error)  { return c.r.Read(p) }
+func (c *sshCmd) Write(p []byte) (int, error) { return c.w.Write(p) }
+
+// Close ssh, returning its error message should it fail.
+func (c *sshCmd) Close() error {
+	c.w.Close()
+	if err := c.Wait(); err != nil {
+		if msg := strings.TrimSpace(c.stderr.String()); len(msg) > 0 {
+			return errors.New(msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// shellQuote the path for the remote shell, leaving a leading tilde.
+func shellQuote(s string) string {
+	prefix := ""
+	if strings.HasPrefix(s, "~/") {
+		prefix, s = "~/", s[2:]
+	}
+	return prefix + "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// scpReader is the sink of "scp -f".
+type scpReader struct {
+	conn   io.ReadWriteCloser
+	r      *bufio.Reader
+	remain int64
+}
+
+func openSCP(u *url.URL) (io.ReadCloser, error) {
+	c, err := startSSH(u, nil, "scp", "-f", shellQuote(remotePath(u)))
+	if err != nil {
+		return nil, err
+	}
+	r, err := newSCPReader(c, c.r)
+	if err != nil {
+		if cerr := c.Close(); cerr != nil {
+			err = cerr
+		}
+		return nil, fmt.Errorf("%s: %v", u, err)
+	}
+	return r, nil
+}
+
+func newSCPReader(conn io.ReadWriteCloser, r *bufio.Reader) (*scpReader, error) {
+	if _, err := conn.Write([]byte{0}); err != nil {
+		return nil, err
+	}
+	for {
+		line, err := scpLine(r)
+		if err != nil {
+			return nil, err
+		}
+		switch line[0] {
+		case 'T':
+			// times; acknowledge and continue
+			if _, err = conn.Write([]byte{0}); err != nil {
+				return nil, err
+			}
+			continue
+		case 'C':
+		case 'D':
+			return nil, errors.New("is a directory")
+		default:
+			return nil, fmt.Errorf("%q: unexpected", line)
+		}
+		// C0644 SIZE NAME
+		fields := strings.SplitN(line[1:], " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%q: invalid", line)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = conn.Write([]byte{0}); err != nil {
+			return nil, err
+		}
+		s := &scpReader{conn: conn, r: r, remain: size}
+		if size == 0 {
+			err = s.done()
+		}
+		return s, err
+	}
+}
+
+// scpLine returns the next protocol line or the peer's error.
+func scpLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	if len(line) == 0 {
+		return "", errors.New("empty scp message")
+	}
+	if line[0] == 1 || line[0] == 2 {
+		return "", errors.New(line[1:])
+	}
+	return line, nil
+}
+
+// scpAck reads the peer's status.
+func scpAck(r *bufio.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b == 0 {
+		return nil
+	}
+	r.UnreadByte()
+	_, err = scpLine(r)
+	return err
+}
+
+func (s *scpReader) Read(p []byte) (int, error) {
+	if s.remain == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > s.remain {
+		p = p[:s.remain]
+	}
+	n, err := s.r.Read(p)
+	s.remain -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil && s.remain == 0 {
+		err = s.done()
+	}
+	return n, err
+}
+
+// done acknowledges the status that follows the content.
+func (s *scpReader) done() error {
+	err := scpAck(s.r)
+	if err == nil {
+		_, err = s.conn.Write([]byte{0})
+	}
+	return err
+}
+
+func (s *scpReader) Close() error { return s.conn.Close() }
+
+// scpWriter is the source of "scp -t"; since scp sends the size first, the
+// content is buffered until Close.
+type scpWriter struct {
+	buf  bytes.Buffer
+	u    *url.URL
+	name string
+}
+
+func (w *scpWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *scpWriter) Close() error {
+	c, err := startSSH(w.u, nil, "scp", "-t", shellQuote(remotePath(w.u)))
+	if err != nil {
+		return err
+	}
+	err = w.send(c, c.r)
+	if cerr := c.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", w.u, err)
+	}
+	return nil
+}
+
+func (w *scpWriter) send(conn io.Writer, r *bufio.Reader) error {
+	if err := scpAck(r); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(conn, "C0644 %d %s\n", w.buf.Len(), w.name)
+	if err != nil {
+		return err
+	}
+	if err = scpAck(r); err != nil {
+		return err
+	}
+	if _, err = conn.Write(append(w.buf.Bytes(), 0)); err != nil {
+		return err
+	}
+	return scpAck(r)
+}
+
+func createSCP(u *url.URL, isAppend bool) (io.WriteCloser, error) {
+	if isAppend {
+		return nil, fmt.Errorf("%s: can't append with scp", u)
+	}
+	return &scpWriter{u: u, name: path.Base(remotePath(u))}, nil
+}
+
+// SFTP version 3 as described by draft-ietf-secsh-filexfer-02
+const (
+	sshFxpInit    = 1
+	sshFxpVersion = 2
+	sshFxpOpen    = 3
+	sshFxpClose   = 4
+	sshFxpRead    = 5
+	sshFxpWrite   = 6
+	sshFxpStatus  = 101
+	sshFxpHandle  = 102
+	sshFxpData    = 103
+
+	sshFxfRead   = 0x01
+	sshFxfWrite  = 0x02
+	sshFxfAppend = 0x04
+	sshFxfCreat  = 0x08
+	sshFxfTrunc  = 0x10
+
+	sshFxOk               = 0
+	sshFxEOF              = 1
+	sshFxNoSuchFile       = 2
+	sshFxPermissionDenied = 3
+
+	sftpChunk = 32 << 10
+)
+
+type sftpFile struct {
+	conn   io.ReadWriteCloser
+	name   string
+	id     uint32
+	handle string
+	offset uint64
+}
+
+func openSFTP(u *url.URL, flags uint32) (*sftpFile, error) {
+	c, err := startSSH(u, []string{"-s"}, "sftp")
+	if err != nil {
+		return nil, err
+	}
+	f, err := newSFTPFile(c, strings.TrimPrefix(remotePath(u), "~/"),
+		flags)
+	if err != nil {
+		if cerr := c.Close(); cerr != nil {
+			err = cerr
+		}
+		return nil, fmt.Errorf("%s: %v", u, err)
+	}
+	return f, nil
+}
+
+func newSFTPFile(conn io.ReadWriteCloser, name string, flags uint32) (*sftpFile, error) {
+	f := &sftpFile{conn: conn, name: name}
+	if err := f.send(sshFxpInit, uint32(3)); err != nil {
+		return nil, err
+	}
+	if typ, _, err := f.recv(); err != nil {
+		return nil, err
+	} else if typ != sshFxpVersion {
+		return nil, fmt.Errorf("sftp: unexpected packet %d", typ)
+	}
+	// no attributes
+	b, err := f.call(sshFxpOpen, sshFxpHandle, name, flags, uint32(0))
+	if err != nil {
+		return nil, err
+	}
+	handle, _, err := sftpString(b)
+	if err != nil {
+		return nil, err
+	}
+	f.handle = handle
+	return f, nil
+}
+
+func (f *sftpFile) send(typ byte, fields ...interface{}) error {
+	b := []byte{0, 0, 0, 0, typ}
+	for _, v := range fields {
+		switch t := v.(type) {
+		case uint32:
+			b = append(b, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(b[len(b)-4:], t)
+		case uint64:
+			b = append(b, 0, 0, 0, 0, 0, 0, 0, 0)
+			binary.BigEndian.PutUint64(b[len(b)-8:], t)
+		case string:
+			b = append(b, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(t)))
+			b = append(b, t...)
+		case []byte:
+			b = append(b, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(t)))
+			b = append(b, t...)
+		}
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	_, err := f.conn.Write(b)
+	return err
+}
+
+func (f *sftpFile) recv() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(f.conn, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n < 1 || n > 1<<20 {
+		return 0, nil, fmt.Errorf("sftp: %d byte packet", n)
+	}
+	b := make([]byte, n-1)
+	_, err := io.ReadFull(f.conn, b)
+	return hdr[4], b, err
+}
+
+// call sends a request and returns the payload of its reply of the wanted
+// type following the request id; a status reply other than OK is returned as
+// an error.
+func (f *sftpFile) call(typ, want byte, fields ...interface{}) ([]byte, error) {
+	f.id++
+	if err := f.send(typ, append([]interface{}{f.id}, fields...)...); err != nil {
+		return nil, err
+	}
+	rtyp, b, err := f.recv()
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 4 || binary.BigEndian.Uint32(b) != f.id {
+		return nil, errors.New("sftp: mismatched reply")
+	}
+	b = b[4:]
+	if rtyp == sshFxpStatus {
+		return nil, f.status(b)
+	}
+	if rtyp != want {
+		return nil, fmt.Errorf("sftp: unexpected packet %d", rtyp)
+	}
+	return b, nil
+}
+
+func (f *sftpFile) status(b []byte) error {
+	if len(b) < 4 {
+		return errors.New("sftp: short status")
+	}
+	code := binary.BigEndian.Uint32(b)
+	msg, _, _ := sftpString(b[4:])
+	switch code {
+	case sshFxOk:
+		return nil
+	case sshFxEOF:
+		return io.EOF
+	case sshFxNoSuchFile:
+		return &os.PathError{Op: "open", Path: f.name, Err: os.ErrNotExist}
+	case sshFxPermissionDenied:
+		return &os.PathError{Op: "open", Path: f.name, Err: os.ErrPermission}
+	}
+	if len(msg) == 0 {
+		msg = fmt.Sprint("status ", code)
+	}
+	return fmt.Errorf("%s: %s", f.name, msg)
+}
+
+func sftpString(b []byte) (string, []byte, error) {
+	if len(b) < 4 {
+		return "", nil, errors.New("sftp: short string")
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return "", nil, errors.New("sftp: short string")
+	}
+	return string(b[4 : 4+n]), b[4+n:], nil
+}
+
+func (f *sftpFile) Read(p []byte) (int, error) {
+	if len(p) > sftpChunk {
+		p = p[:sftpChunk]
+	}
+	b, err := f.call(sshFxpRead, sshFxpData, f.handle, f.offset,
+		uint32(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	data, _, err := sftpString(b)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data)
+	f.offset += uint64(n)
+	return n, nil
+}
+
+func (f *sftpFile) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > sftpChunk {
+			chunk = chunk[:sftpChunk]
+		}
+		if _, err := f.call(sshFxpWrite, sshFxpStatus, f.handle, f.offset,
+			chunk); err != nil {
+			return n, err
+		}
+		f.offset += uint64(len(chunk))
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+func (f *sftpFile) Close() error {
+	_, err := f.call(sshFxpClose, sshFxpStatus, f.handle)
+	if cerr := f.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func createSFTP(u *url.URL, isAppend bool) (io.WriteCloser, error) {
+	flags := uint32(sshFxfWrite | sshFxfCreat)
+	if isAppend {
+		flags |= sshFxfAppend
+	} else {
+		flags |= sshFxfTrunc
+	}
+	return openSFTP(u, flags)
+}
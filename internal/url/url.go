@@ -3,6 +3,18 @@
 // LICENSE file.
 
 // Package url returns reader/writers for a given url.
+//
+// Besides file paths, these URLs are supported:
+//
+//	file:///PATH
+//	http://... and https://...
+//	tftp://HOST[:PORT]/PATH
+//	scp://[USER@]HOST[:PORT]/PATH
+//	sftp://[USER@]HOST[:PORT]/PATH
+//	redis://[KEY]/[FIELD]
+//	data:[MEDIATYPE][;base64],DATA (read only)
+//
+// See CABundle and CertPins for https and SSH for scp and sftp.
 package url
 
 import (
@@ -13,6 +25,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/cavaliercoder/grab"
@@ -25,6 +38,12 @@ func Open(path string) (io.ReadCloser, error) {
 		r   *http.Response
 		err error
 	)
+	// Handle data:... URLs before parsing since the data needn't be
+	// escaped.
+	if strings.HasPrefix(path, "data:") {
+		return openData(path)
+	}
+
 	u, err = url.Parse(path)
 
 	// It's not a URL its a file.
@@ -39,15 +58,28 @@ func Open(path string) (io.ReadCloser, error) {
 
 	// Handle tftp://... URLs
 	if u.Scheme == "tftp" {
-		rc, err := tftp.GetFileRC(u.Host + ":" + u.Path)
+		c, err := tftp.NewClient(u.Host)
 		if err != nil {
 			return nil, err
 		}
-		return rc, nil
+		return c.Reader(u.Path)
+	}
+
+	switch u.Scheme {
+	case "scp":
+		return openSCP(u)
+	case "sftp":
+		return openSFTP(u, sshFxfRead)
+	case "redis":
+		return openRedis(u)
 	}
 
 	// Get URL from server.
-	r, err = http.Get(u.String())
+	client, err := HTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	r, err = client.Get(u.String())
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +118,10 @@ func (w *httpWriter) Close() error {
 		return err
 	}
 
-	client := &http.Client{}
+	client, err := HTTPClient()
+	if err != nil {
+		return err
+	}
 	res, err := client.Do(req)
 	if err != nil {
 		return err
@@ -113,6 +148,29 @@ func create(path string, isAppend bool) (io.WriteCloser, error) {
 		return createFile(u.Path, isAppend)
 	}
 
+	// Handle tftp://... URLs
+	if u.Scheme == "tftp" {
+		if isAppend {
+			return nil, fmt.Errorf("%s: can't append with tftp", path)
+		}
+		c, err := tftp.NewClient(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return c.Writer(u.Path)
+	}
+
+	switch u.Scheme {
+	case "scp":
+		return createSCP(u, isAppend)
+	case "sftp":
+		return createSFTP(u, isAppend)
+	case "redis":
+		return createRedis(u, isAppend)
+	case "data":
+		return nil, fmt.Errorf("%s: read only", path)
+	}
+
 	return &httpWriter{buf: &bytes.Buffer{}, url: path, isAppend: isAppend}, err
 }
 
@@ -126,6 +184,9 @@ func FetchReqs(parallel int, reqs []*grab.Request) (successes int, err error) {
 	// create a custom client
 	client := grab.NewClient()
 	client.UserAgent = "Platina Go-ES"
+	if client.HTTPClient, err = HTTPClient(); err != nil {
+		return 0, err
+	}
 
 	// start file downloads at the requested batch size
 	fmt.Printf("Downloading %d files...\n", len(reqs))
@@ -0,0 +1,246 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package url
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readAll(t *testing.T, s string) string {
+	r, err := Open(s)
+	if err != nil {
+		t.Fatal(s, ": ", err)
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(s, ": ", err)
+	}
+	return string(b)
+}
+
+func TestData(t *testing.T) {
+	for _, tc := range []struct{ url, want string }{
+		{"data:,hello%20world", "hello world"},
+		{"data:text/plain;charset=utf-8,a+b", "a+b"},
+		{"data:;base64,aGVsbG8K", "hello\n"},
+		{"data:,", ""},
+	} {
+		if got := readAll(t, tc.url); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.url, got, tc.want)
+		}
+	}
+	if _, err := Open("data:nocomma"); err == nil {
+		t.Error("missing comma accepted")
+	}
+	if _, err := Create("data:,x"); err == nil {
+		t.Error("data URL written")
+	}
+}
+
+func TestHTTPS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "secure")
+		}))
+	defer srv.Close()
+	defer os.Unsetenv("CA_BUNDLE")
+	defer os.Unsetenv("CERT_PINS")
+
+	cert := srv.Certificate()
+	os.Setenv("CERT_PINS", fingerprint(cert))
+	if got := readAll(t, srv.URL); got != "secure" {
+		t.Error("pinned:", got)
+	}
+	os.Setenv("CERT_PINS", "00"+fingerprint(cert)[2:])
+	if _, err := Open(srv.URL); err == nil {
+		t.Error("unpinned certificate accepted")
+	}
+	os.Setenv("CERT_PINS", "xyz")
+	if _, err := Open(srv.URL); err == nil {
+		t.Error("invalid pin accepted")
+	}
+	os.Unsetenv("CERT_PINS")
+
+	dir, err := ioutil.TempDir("", "url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bundle := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: cert.Raw,
+	}), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CA_BUNDLE", bundle)
+	if got := readAll(t, srv.URL); got != "secure" {
+		t.Error("bundle:", got)
+	}
+	os.Setenv("CA_BUNDLE", filepath.Join(dir, "missing.pem"))
+	if _, err := Open(srv.URL); err == nil {
+		t.Error("missing bundle ignored")
+	}
+}
+
+func TestSCP(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		b := make([]byte, 1)
+		io.ReadFull(r, b)
+		fmt.Fprint(server, "T0 0 0 0\n")
+		io.ReadFull(r, b)
+		fmt.Fprint(server, "C0644 5 x\n")
+		io.ReadFull(r, b)
+		server.Write([]byte("hello\x00"))
+		io.ReadFull(r, b)
+	}()
+	s, err := newSCPReader(client, bufio.NewReader(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(s)
+	if err != nil || string(b) != "hello" {
+		t.Errorf("got %q, %v", b, err)
+	}
+	s.Close()
+
+	client, server = net.Pipe()
+	var got bytes.Buffer
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		server.Write([]byte{0})
+		line, _ := r.ReadString('\n')
+		got.WriteString(line)
+		server.Write([]byte{0})
+		b := make([]byte, 6)
+		io.ReadFull(r, b)
+		got.Write(b)
+		server.Write([]byte("\x01scp: x: Permission denied\n"))
+	}()
+	w := &scpWriter{name: "x"}
+	w.buf.WriteString("hello")
+	err = w.send(client, bufio.NewReader(client))
+	if err == nil || err.Error() != "scp: x: Permission denied" {
+		t.Error("error:", err)
+	}
+	client.Close()
+	if got.String() != "C0644 5 x\nhello\x00" {
+		t.Errorf("sent %q", got.String())
+	}
+}
+
+// sftpServer serves one file until the connection closes.
+func sftpServer(conn net.Conn, content *bytes.Buffer) {
+	defer conn.Close()
+	f := &sftpFile{conn: conn}
+	reply := func(typ byte, id uint32, fields ...interface{}) {
+		f.send(typ, append([]interface{}{id}, fields...)...)
+	}
+	for {
+		typ, b, err := f.recv()
+		if err != nil {
+			return
+		}
+		if typ == sshFxpInit {
+			f.send(sshFxpVersion, uint32(3))
+			continue
+		}
+		id := binary.BigEndian.Uint32(b)
+		b = b[4:]
+		switch typ {
+		case sshFxpOpen:
+			name, b, _ := sftpString(b)
+			if name != "dir/file" {
+				reply(sshFxpStatus, id, uint32(sshFxNoSuchFile),
+					"no such file", "")
+				continue
+			}
+			if binary.BigEndian.Uint32(b)&sshFxfTrunc != 0 {
+				content.Reset()
+			}
+			reply(sshFxpHandle, id, "h")
+		case sshFxpRead:
+			_, b, _ = sftpString(b)
+			off := binary.BigEndian.Uint64(b)
+			n := binary.BigEndian.Uint32(b[8:])
+			if off >= uint64(content.Len()) {
+				reply(sshFxpStatus, id, uint32(sshFxEOF), "", "")
+				continue
+			}
+			data := content.Bytes()[off:]
+			if uint32(len(data)) > n {
+				data = data[:n]
+			}
+			reply(sshFxpData, id, data)
+		case sshFxpWrite:
+			_, b, _ = sftpString(b)
+			data, _, _ := sftpString(b[8:])
+			content.WriteString(data)
+			reply(sshFxpStatus, id, uint32(sshFxOk), "", "")
+		case sshFxpClose:
+			reply(sshFxpStatus, id, uint32(sshFxOk), "", "")
+		}
+	}
+}
+
+func TestSFTP(t *testing.T) {
+	var content bytes.Buffer
+	content.WriteString("old")
+	want := bytes.Repeat([]byte("0123456789"), 10000)
+
+	client, server := net.Pipe()
+	go sftpServer(server, &content)
+	f, err := newSFTPFile(client, "dir/file",
+		sshFxfWrite|sshFxfCreat|sshFxfTrunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write(want); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	client, server = net.Pipe()
+	go sftpServer(server, &content)
+	if f, err = newSFTPFile(client, "dir/file", sshFxfRead); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if !bytes.Equal(got, want) {
+		t.Error("mismatch")
+	}
+
+	client, server = net.Pipe()
+	go sftpServer(server, &content)
+	_, err = newSFTPFile(client, "missing", sshFxfRead)
+	if !os.IsNotExist(err) {
+		t.Error("missing:", err)
+	}
+	client.Close()
+}
@@ -0,0 +1,210 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package xcvr
+
+import (
+	"fmt"
+)
+
+// CMIS module states of byte 3, bits 3-1.
+var cmisStates = codes{
+	0x01: "low power",
+	0x02: "power up",
+	0x03: "ready",
+	0x04: "power down",
+	0x05: "fault",
+}
+
+// cmisLanes of each bank
+const cmisLanes = 8
+
+// decodeCMIS of the module memory, lower and page 0, with the advertising
+// of page 1, thresholds of page 2, and lane monitors of page 0x11 of each
+// bank, if present.
+func decodeCMIS(img *Image) (*Module, error) {
+	b := img.view(0, 0)
+	if b == nil {
+		return nil, fmt.Errorf("%s: no page 0", CMIS)
+	}
+	m := &Module{
+		Identifier: Identifiers.name(b[128]),
+		Spec:       CMIS,
+		Revision:   fmt.Sprint(b[1]>>4, ".", b[1]&0xf),
+		Connector:  Connectors.name(b[203]),
+		Vendor:     text(b, 129, 16),
+		OUI:        oui(b, 145),
+		PN:         text(b, 148, 16),
+		Rev:        text(b, 164, 2),
+		SN:         text(b, 166, 16),
+		Date:       date(b, 182),
+		MaxPower:   float64(b[201]) / 4,
+		State:      cmisStates.name(b[3] >> 1 & 0x7),
+		Length:     make(map[string]float64),
+	}
+	media := b[85]
+	m.Compliance = append(m.Compliance, MediaTypes.name(media))
+	// application descriptors of host interface, media interface, host
+	// and media lane counts, and host lane assignment
+	lanes := 0
+	for off := 86; off < 118; off += 4 {
+		host := b[off]
+		if host == 0 || host == 0xff {
+			break
+		}
+		if n := int(b[off+2] & 0xf); n > lanes {
+			lanes = n
+		}
+		m.Applications = append(m.Applications, fmt.Sprint(
+			HostInterfaces.name(host), ", ",
+			MediaInterfaces[media].name(b[off+1])))
+	}
+	tx := b[212]
+	m.Transmitter = Transmitters.name(tx)
+	// cable assembly length of bits 5-0 with multiplier of bits 7-6
+	if n := b[202] & 0x3f; n != 0 {
+		mult := []float64{0.1, 1, 10, 100}[b[202]>>6]
+		m.Length["cable"] = float64(n) * mult
+	}
+	if !checksum(b, 128, 222) {
+		m.Errors = append(m.Errors, "page 0 checksum mismatch")
+	}
+	// flat memory has only page 0
+	if b[2]&0x80 != 0 {
+		m.DOM = &DOM{Temp: temp(b, 14), Vcc: vcc(b, 16)}
+		m.DOM.Alarms = cmisModuleAlarms(b)
+		return m, nil
+	}
+	p1 := img.view(0, 1)
+	if p1 == nil {
+		return m, nil
+	}
+	if tx < copper {
+		m.Wavelength = float64(word(p1, 138)) / 20
+	}
+	// SMF length of bits 5-0 with multiplier of bits 7-6
+	if n := p1[132] & 0x3f; n != 0 {
+		mult := []float64{0.1, 1, 0, 0}[p1[132]>>6]
+		m.Length["SMF"] = float64(n) * mult * 1000
+	}
+	for _, x := range []struct {
+		medium string
+		off    int
+		units  float64
+	}{
+		{"OM5", 133, 2},
+		{"OM4", 134, 2},
+		{"OM3", 135, 2},
+		{"OM2", 136, 1},
+	} {
+		if p1[x.off] != 0 {
+			m.Length[x.medium] = float64(p1[x.off]) * x.units
+		}
+	}
+	// monitors of each media lane
+	m.DOM = cmisDOM(img, b, p1, lanes)
+	return m, nil
+}
+
+// cmisModuleAlarms of the temperature and vcc flags of byte 9.
+func cmisModuleAlarms(b []byte) []string {
+	s := alarms("temp", b[9], 0, 1, 2, 3)
+	return append(s, alarms("vcc", b[9], 4, 5, 6, 7)...)
+}
+
+// cmisDOM of the lower memory with the monitors that page 1 advertises,
+// the thresholds of page 2, and lane monitors and flags of page 0x11 of
+// each bank.
+func cmisDOM(img *Image, b, p1 []byte, lanes int) *DOM {
+	dom := &DOM{Alarms: cmisModuleAlarms(b)}
+	if p1[159]&0x01 != 0 {
+		dom.Temp = temp(b, 14)
+	}
+	if p1[159]&0x02 != 0 {
+		dom.Vcc = vcc(b, 16)
+	}
+	hasBias := p1[160]&0x01 != 0
+	hasTx := p1[160]&0x02 != 0
+	hasRx := p1[160]&0x04 != 0
+	// bias scale of bits 4-3
+	scale := float64(uint(1) << (p1[160] >> 3 & 0x3))
+	scaled := func(b []byte, off int) float64 {
+		return bias(b, off) * scale
+	}
+	if p2 := img.view(0, 2); p2 != nil {
+		dom.Thresholds = map[string]Threshold{
+			"temp": thresholds(p2, 128, temp),
+			"vcc":  thresholds(p2, 136, vcc),
+		}
+		if hasTx {
+			dom.Thresholds["tx power"] = thresholds(p2, 176, power)
+		}
+		if hasBias {
+			dom.Thresholds["bias"] = thresholds(p2, 184, scaled)
+		}
+		if hasRx {
+			dom.Thresholds["rx power"] = thresholds(p2, 192, power)
+		}
+	}
+	// banks of bits 1-0
+	banks := []int{1, 2, 4, 1}[p1[142]&0x3]
+	if lanes == 0 {
+		lanes = cmisLanes
+	}
+	if lanes > banks*cmisLanes {
+		lanes = banks * cmisLanes
+	}
+	for lane := 0; lane < lanes; lane++ {
+		p := img.view(uint8(lane/cmisLanes), 0x11)
+		if p == nil {
+			break
+		}
+		i := lane % cmisLanes
+		var l Lane
+		if hasTx {
+			l.TxPower = power(p, 154+2*i)
+		}
+		if hasBias {
+			l.Bias = scaled(p, 170+2*i)
+		}
+		if hasRx {
+			l.RxPower = power(p, 186+2*i)
+		}
+		dom.Lanes = append(dom.Lanes, l)
+		dom.Alarms = append(dom.Alarms, cmisLaneAlarms(p, i, lane+1)...)
+	}
+	return dom
+}
+
+// cmisLaneAlarms of the flags of page 0x11 with a bit for each lane.
+func cmisLaneAlarms(p []byte, i, lane int) []string {
+	var s []string
+	for _, x := range []struct {
+		off    int
+		format string
+	}{
+		{135, "tx%d fault"},
+		{136, "tx%d los"},
+		{137, "tx%d cdr lol"},
+		{139, "tx%d power high alarm"},
+		{140, "tx%d power low alarm"},
+		{141, "tx%d power high warning"},
+		{142, "tx%d power low warning"},
+		{143, "tx%d bias high alarm"},
+		{144, "tx%d bias low alarm"},
+		{145, "tx%d bias high warning"},
+		{146, "tx%d bias low warning"},
+		{147, "rx%d los"},
+		{148, "rx%d cdr lol"},
+		{149, "rx%d power high alarm"},
+		{150, "rx%d power low alarm"},
+		{151, "rx%d power high warning"},
+		{152, "rx%d power low warning"},
+	} {
+		if p[x.off]&(1<<uint(i)) != 0 {
+			s = append(s, fmt.Sprintf(x.format, lane))
+		}
+	}
+	return s
+}
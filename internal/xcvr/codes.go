@@ -0,0 +1,346 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package xcvr
+
+import "fmt"
+
+// codes name the values of a byte.
+type codes map[byte]string
+
+// name of the value or its hex if unknown.
+func (c codes) name(v byte) string {
+	if s, found := c[v]; found {
+		return s
+	}
+	return fmt.Sprintf("unknown (%#02x)", v)
+}
+
+// bits name each bit of a byte, 0 through 7; empty if reserved.
+type bits [8]string
+
+// names of the value's set bits, most significant first.
+func (b *bits) names(v byte) []string {
+	var s []string
+	for i := 7; i >= 0; i-- {
+		if v&(1<<uint(i)) != 0 && len(b[i]) > 0 {
+			s = append(s, b[i])
+		}
+	}
+	return s
+}
+
+// Identifiers of SFF-8024 table 4-1.
+var Identifiers = codes{
+	0x00: "Unknown",
+	0x01: "GBIC",
+	0x02: "Soldered",
+	0x03: "SFP",
+	0x04: "300 pin XBI",
+	0x05: "XENPAK",
+	0x06: "XFP",
+	0x07: "XFF",
+	0x08: "XFP-E",
+	0x09: "XPAK",
+	0x0a: "X2",
+	0x0b: "DWDM-SFP",
+	0x0c: "QSFP",
+	0x0d: "QSFP+",
+	0x0e: "CXP",
+	0x0f: "Shielded Mini Multilane HD 4X",
+	0x10: "Shielded Mini Multilane HD 8X",
+	0x11: "QSFP28",
+	0x12: "CXP2",
+	0x13: "CDFP",
+	0x14: "Shielded Mini Multilane HD 4X Fanout",
+	0x15: "Shielded Mini Multilane HD 8X Fanout",
+	0x16: "CDFP Style 3",
+	0x17: "microQSFP",
+	0x18: "QSFP-DD",
+	0x19: "OSFP",
+	0x1a: "SFP-DD",
+	0x1b: "DSFP",
+	0x1c: "MiniLink x4",
+	0x1d: "MiniLink x8",
+	0x1e: "QSFP+ CMIS",
+}
+
+// Connectors of SFF-8024 table 4-3.
+var Connectors = codes{
+	0x00: "Unknown",
+	0x01: "SC",
+	0x02: "FC Style 1 copper",
+	0x03: "FC Style 2 copper",
+	0x04: "BNC/TNC",
+	0x05: "FC coax",
+	0x06: "Fiber Jack",
+	0x07: "LC",
+	0x08: "MT-RJ",
+	0x09: "MU",
+	0x0a: "SG",
+	0x0b: "Optical pigtail",
+	0x0c: "MPO 1x12",
+	0x0d: "MPO 2x16",
+	0x20: "HSSDC II",
+	0x21: "Copper pigtail",
+	0x22: "RJ45",
+	0x23: "No separable connector",
+	0x24: "MXC 2x16",
+	0x25: "CS",
+	0x26: "SN",
+	0x27: "MPO 2x12",
+	0x28: "MPO 1x16",
+}
+
+// ExtCompliance codes of SFF-8024 table 4-4.
+var ExtCompliance = codes{
+	0x00: "Unspecified",
+	0x01: "100G AOC or 25GAUI C2M AOC (BER 5e-5)",
+	0x02: "100GBASE-SR4 or 25GBASE-SR",
+	0x03: "100GBASE-LR4 or 25GBASE-LR",
+	0x04: "100GBASE-ER4 or 25GBASE-ER",
+	0x05: "100GBASE-SR10",
+	0x06: "100G CWDM4",
+	0x07: "100G PSM4 Parallel SMF",
+	0x08: "100G ACC or 25GAUI C2M ACC (BER 5e-5)",
+	0x0b: "100GBASE-CR4, 25GBASE-CR CA-L or 50GBASE-CR2 with RS FEC",
+	0x0c: "25GBASE-CR CA-S or 50GBASE-CR2 with BASE-R FEC",
+	0x0d: "25GBASE-CR CA-N or 50GBASE-CR2 without FEC",
+	0x10: "40GBASE-ER4",
+	0x11: "4 x 10GBASE-SR",
+	0x12: "40G PSM4 Parallel SMF",
+	0x13: "G959.1 profile P1I1-2D1",
+	0x14: "G959.1 profile P1S1-2D2",
+	0x15: "G959.1 profile P1L1-2D2",
+	0x16: "10GBASE-T with SFI",
+	0x17: "100G CLR4",
+	0x18: "100G AOC or 25GAUI C2M AOC (BER 1e-12)",
+	0x19: "100G ACC or 25GAUI C2M ACC (BER 1e-12)",
+	0x1a: "100GE-DWDM2",
+	0x1b: "100G 1550nm WDM",
+	0x1c: "10GBASE-T Short Reach",
+	0x1d: "5GBASE-T",
+	0x1e: "2.5GBASE-T",
+	0x1f: "40G SWDM4",
+	0x20: "100G SWDM4",
+	0x21: "100G PAM4 BiDi",
+	0x22: "4WDM-10 MSA",
+	0x23: "4WDM-20 MSA",
+	0x24: "4WDM-40 MSA",
+	0x25: "100GBASE-DR",
+	0x26: "100G-FR or 100GBASE-FR1",
+	0x27: "100G-LR or 100GBASE-LR1",
+	0x30: "ACC with 50GAUI, 100GAUI-2 or 200GAUI-4 C2M (BER 1e-6)",
+	0x31: "AOC with 50GAUI, 100GAUI-2 or 200GAUI-4 C2M (BER 1e-6)",
+	0x32: "ACC with 50GAUI, 100GAUI-2 or 200GAUI-4 C2M (BER 2.6e-4)",
+	0x33: "AOC with 50GAUI, 100GAUI-2 or 200GAUI-4 C2M (BER 2.6e-4)",
+	0x40: "50GBASE-CR, 100GBASE-CR2 or 200GBASE-CR4",
+	0x41: "50GBASE-SR, 100GBASE-SR2 or 200GBASE-SR4",
+	0x42: "50GBASE-FR or 200GBASE-DR4",
+	0x43: "200GBASE-FR4",
+	0x44: "200G 1550nm PSM4",
+	0x45: "50GBASE-LR",
+	0x46: "200GBASE-LR4",
+}
+
+// Encodings of SFF-8024 table 4-2 for SFF-8472.
+var Encodings = codes{
+	0x00: "Unspecified",
+	0x01: "8B/10B",
+	0x02: "4B/5B",
+	0x03: "NRZ",
+	0x04: "Manchester",
+	0x05: "SONET Scrambled",
+	0x06: "64B/66B",
+	0x07: "256B/257B",
+	0x08: "PAM4",
+}
+
+// Transmitters of the SFF-8636 and CMIS media interface technology.
+var Transmitters = codes{
+	0x00: "850 nm VCSEL",
+	0x01: "1310 nm VCSEL",
+	0x02: "1550 nm VCSEL",
+	0x03: "1310 nm FP",
+	0x04: "1310 nm DFB",
+	0x05: "1550 nm DFB",
+	0x06: "1310 nm EML",
+	0x07: "1550 nm EML",
+	0x08: "Other",
+	0x09: "1490 nm DFB",
+	0x0a: "Copper cable unequalized",
+	0x0b: "Copper cable passive equalized",
+	0x0c: "Copper cable near and far end limiting active equalizers",
+	0x0d: "Copper cable far end limiting active equalizers",
+	0x0e: "Copper cable near end limiting active equalizers",
+	0x0f: "Copper cable linear active equalizers",
+	0x10: "C-band tunable laser",
+	0x11: "L-band tunable laser",
+}
+
+// copper transmitters have attenuation rather than wavelength
+const copper = 0x0a
+
+// Fibre Channel compliance common to SFF-8472 bytes 7-10 and SFF-8636
+// bytes 135-138.
+var (
+	fcLength = bits{
+		0: "FC Electrical inter-enclosure (EL)",
+		1: "FC Longwave laser (LC)",
+		2: "FC Shortwave laser, linear Rx (SA)",
+		3: "FC medium distance (M)",
+		4: "FC long distance (L)",
+		5: "FC intermediate distance (I)",
+		6: "FC short distance (S)",
+		7: "FC very long distance (V)",
+	}
+	fcTransmitter = bits{
+		4: "FC Longwave laser (LL)",
+		5: "FC Shortwave laser with OFC (SL)",
+		6: "FC Shortwave laser without OFC (SN)",
+		7: "FC Electrical intra-enclosure (EL)",
+	}
+	fcMedia = bits{
+		0: "FC Single Mode (SM)",
+		2: "FC Multimode 50um (M5)",
+		3: "FC Multimode 62.5um (M6)",
+		4: "FC Video Coax (TV)",
+		5: "FC Miniature Coax (MI)",
+		6: "FC Twisted Pair (TP)",
+		7: "FC Twin Axial Pair (TW)",
+	}
+	fcSpeed = bits{
+		0: "FC 100 MBytes/sec",
+		2: "FC 200 MBytes/sec",
+		3: "FC 3200 MBytes/sec",
+		4: "FC 400 MBytes/sec",
+		5: "FC 1600 MBytes/sec",
+		6: "FC 800 MBytes/sec",
+		7: "FC 1200 MBytes/sec",
+	}
+)
+
+// HostInterfaces of CMIS application descriptors, SFF-8024 table 4-5.
+var HostInterfaces = codes{
+	0x01: "1000BASE-CX",
+	0x02: "XAUI",
+	0x03: "XFI",
+	0x04: "SFI",
+	0x05: "25GAUI C2M",
+	0x06: "XLAUI C2M",
+	0x07: "XLPPI",
+	0x08: "LAUI-2 C2M",
+	0x09: "50GAUI-2 C2M",
+	0x0a: "50GAUI-1 C2M",
+	0x0b: "CAUI-4 C2M",
+	0x0c: "100GAUI-4 C2M",
+	0x0d: "100GAUI-2 C2M",
+	0x0e: "200GAUI-8 C2M",
+	0x0f: "200GAUI-4 C2M",
+	0x10: "400GAUI-16 C2M",
+	0x11: "400GAUI-8 C2M",
+	0x13: "10GBASE-CX4",
+	0x14: "25GBASE-CR CA-L",
+	0x15: "25GBASE-CR CA-S",
+	0x16: "25GBASE-CR CA-N",
+	0x17: "40GBASE-CR4",
+	0x18: "50GBASE-CR",
+	0x1a: "100GBASE-CR10",
+	0x1b: "100GBASE-CR4",
+	0x1c: "100GBASE-CR2",
+	0x1d: "200GBASE-CR4",
+	0x1e: "400G CR8",
+	0x1f: "1000BASE-T",
+	0x20: "2.5GBASE-T",
+	0x21: "5GBASE-T",
+	0x22: "10GBASE-T",
+	0x23: "25GBASE-T",
+	0x24: "40GBASE-T",
+	0x25: "50GBASE-T",
+}
+
+// MediaInterfaces of CMIS application descriptors by the module media
+// type, SFF-8024 tables 4-6 through 4-10.
+var MediaInterfaces = map[byte]codes{
+	// multimode fiber
+	0x01: {
+		0x01: "10GBASE-SW",
+		0x02: "10GBASE-SR",
+		0x03: "25GBASE-SR",
+		0x04: "40GBASE-SR4",
+		0x05: "40GE SWDM4",
+		0x06: "40GE BiDi",
+		0x07: "50GBASE-SR",
+		0x08: "100GBASE-SR10",
+		0x09: "100GBASE-SR4",
+		0x0a: "100GE SWDM4",
+		0x0b: "100GE BiDi",
+		0x0c: "100GBASE-SR2",
+		0x0d: "100G-SR",
+		0x0e: "200GBASE-SR4",
+		0x0f: "400GBASE-SR16",
+		0x10: "400GBASE-SR8",
+	},
+	// single mode fiber
+	0x02: {
+		0x01: "10GBASE-LW",
+		0x02: "10GBASE-EW",
+		0x03: "10G-ZW",
+		0x04: "10GBASE-LR",
+		0x05: "10GBASE-ER",
+		0x06: "10G-ZR",
+		0x07: "25GBASE-LR",
+		0x08: "25GBASE-ER",
+		0x09: "40GBASE-LR4",
+		0x0a: "40GBASE-FR",
+		0x0b: "50GBASE-FR",
+		0x0c: "50GBASE-LR",
+		0x0d: "100GBASE-LR4",
+		0x0e: "100GBASE-ER4",
+		0x0f: "100G PSM4",
+		0x10: "100G CWDM4",
+		0x11: "100G 4WDM-10",
+		0x12: "100G 4WDM-20",
+		0x13: "100G 4WDM-40",
+		0x14: "100GBASE-DR",
+		0x15: "100G-FR or 100GBASE-FR1",
+		0x16: "100G-LR or 100GBASE-LR1",
+		0x17: "200GBASE-DR4",
+		0x18: "200GBASE-FR4",
+		0x19: "200GBASE-LR4",
+		0x1a: "400GBASE-FR8",
+		0x1b: "400GBASE-LR8",
+		0x1c: "400GBASE-DR4",
+		0x1d: "400G-FR4 or 400GBASE-FR4",
+		0x1e: "400G-LR4-10",
+	},
+	// passive copper
+	0x03: {
+		0x01: "Copper cable",
+	},
+	// active cable
+	0x04: {
+		0x01: "Active cable (BER 1e-12)",
+		0x02: "Active cable (BER 5e-5)",
+		0x03: "Active cable (BER 2.6e-4)",
+		0x04: "Active cable (BER 1e-6)",
+	},
+	// BASE-T
+	0x05: {
+		0x01: "1000BASE-T",
+		0x02: "2.5GBASE-T",
+		0x03: "5GBASE-T",
+		0x04: "10GBASE-T",
+	},
+}
+
+// MediaTypes of CMIS byte 85.
+var MediaTypes = codes{
+	0x00: "Undefined",
+	0x01: "Optical MMF",
+	0x02: "Optical SMF",
+	0x03: "Passive copper",
+	0x04: "Active cable",
+	0x05: "BASE-T",
+}
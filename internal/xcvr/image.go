@@ -0,0 +1,257 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package xcvr
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Page of the upper memory, 128-255, selected by CMIS bytes 126 and 127 or
+// SFF-8636 byte 127. The upper half of an SFP's A0 memory is page 0.
+type Page struct {
+	Bank, Page uint8
+}
+
+// Image of a module's memory.
+type Image struct {
+	// Lower memory, 0-127, of the module address.
+	Lower []byte
+	// Upper pages, 128-255, of the module address.
+	Pages map[Page][]byte
+	// A2 is the SFF-8472 diagnostic memory, 0-255, of an SFP.
+	A2 []byte
+}
+
+func NewImage() *Image {
+	return &Image{
+		Lower: make([]byte, 128),
+		Pages: make(map[Page][]byte),
+	}
+}
+
+// Identifier of the module, the first byte of its memory.
+func (img *Image) Identifier() byte {
+	if len(img.Lower) == 0 {
+		return 0
+	}
+	return img.Lower[0]
+}
+
+// view of the lower memory with the upper page, addressed 0-255; nil if
+// the page is absent.
+func (img *Image) view(bank, page uint8) []byte {
+	upper, found := img.Pages[Page{bank, page}]
+	if !found {
+		return nil
+	}
+	b := make([]byte, 256)
+	copy(b, img.Lower)
+	copy(b[128:], upper)
+	return b
+}
+
+// LoadImage of the hex dump file.
+func LoadImage(fn string) (*Image, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := ReadImage(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", fn, err)
+	}
+	return img, nil
+}
+
+// ReadImage of a hex dump. Each section of memory starts with a line of
+// "lower", "page PAGE [BANK]" or "a2" followed by lines of bytes at a hex
+// offset, e.g.
+//
+//	# comment
+//	lower
+//	00: 11 07 06 00 00 00 00 00 00 00 00 00 00 00 00 00
+//	page 0
+//	80: 11 8c 23 80 00 00 00 00 00 00 00 05 ff 00 00 00
+//
+// The offsets of pages are those of the upper memory, 0x80 through 0xff.
+//
+// ReadImage also reads the flat dump of "ethtool -m PORT hex on", e.g.
+//
+//	Offset		Values
+//	------		------
+//	0x0000:		03 04 07 10 00 00 00 00 00 00 00 06 67 00 00 00
+//
+// of the lower memory and page 0, followed by the A2 memory of an SFP.
+// The pages after the first 256 bytes of other modules are ignored since
+// their layout depends on the driver.
+func ReadImage(r io.Reader) (*Image, error) {
+	img := &Image{Pages: make(map[Page][]byte)}
+	var mem, flat []byte
+	var base int
+	scan := bufio.NewScanner(r)
+	for line := 1; scan.Scan(); line++ {
+		s := scan.Text()
+		if i := strings.Index(s, "#"); i >= 0 {
+			s = s[:i]
+		}
+		fields := strings.Fields(s)
+		if len(fields) == 0 {
+			continue
+		}
+		err := fmt.Errorf("%d: %v: unexpected", line, fields)
+		if fields[0] == "Offset" || strings.HasPrefix(fields[0], "---") {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "0x") {
+			if mem != nil || !strings.HasSuffix(fields[0], ":") {
+				return nil, err
+			}
+			off, perr := strconv.ParseUint(
+				strings.TrimSuffix(fields[0], ":"), 0, 16)
+			b, herr := hex.DecodeString(strings.Join(fields[1:], ""))
+			if perr != nil || herr != nil || int(off) != len(flat) {
+				return nil, err
+			}
+			flat = append(flat, b...)
+			continue
+		}
+		if flat != nil {
+			return nil, err
+		}
+		switch fields[0] {
+		case "lower":
+			if len(fields) != 1 {
+				return nil, err
+			}
+			img.Lower = make([]byte, 128)
+			mem, base = img.Lower, 0
+			continue
+		case "a2":
+			if len(fields) != 1 {
+				return nil, err
+			}
+			img.A2 = make([]byte, 256)
+			mem, base = img.A2, 0
+			continue
+		case "page":
+			var v [2]uint64
+			if len(fields) < 2 || len(fields) > 3 {
+				return nil, err
+			}
+			for i, f := range fields[1:] {
+				u, perr := strconv.ParseUint(f, 0, 8)
+				if perr != nil {
+					return nil, err
+				}
+				v[i] = u
+			}
+			mem, base = make([]byte, 128), 128
+			img.Pages[Page{uint8(v[1]), uint8(v[0])}] = mem
+			continue
+		}
+		if mem == nil || !strings.HasSuffix(fields[0], ":") {
+			return nil, err
+		}
+		off, perr := strconv.ParseUint(strings.TrimSuffix(fields[0], ":"),
+			16, 8)
+		b, herr := hex.DecodeString(strings.Join(fields[1:], ""))
+		if perr != nil || herr != nil || int(off) < base ||
+			int(off)-base+len(b) > len(mem) {
+			return nil, err
+		}
+		copy(mem[int(off)-base:], b)
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+	if len(flat) > 0 {
+		img.unflatten(flat)
+	}
+	if len(img.Lower) == 0 {
+		return nil, fmt.Errorf("no lower memory")
+	}
+	return img, nil
+}
+
+// unflatten the ethtool dump of the lower memory, page 0 and, of an SFP,
+// the A2 memory.
+func (img *Image) unflatten(b []byte) {
+	section := func(from, to int) []byte {
+		mem := make([]byte, to-from)
+		if from < len(b) {
+			copy(mem, b[from:])
+		}
+		return mem
+	}
+	img.Lower = section(0, 128)
+	if len(b) > 128 {
+		img.Pages[Page{0, 0}] = section(128, 256)
+	}
+	if len(b) > 256 && Spec(img.Identifier()) == SFF8472 {
+		img.A2 = section(256, 512)
+	}
+}
+
+// WriteHex of the image in the format of ReadImage.
+func (img *Image) WriteHex(w io.Writer) error {
+	dump := func(b []byte, base int) error {
+		for i := 0; i < len(b); i += 16 {
+			j := i + 16
+			if j > len(b) {
+				j = len(b)
+			}
+			_, err := fmt.Fprintf(w, "%02x: % x\n", base+i, b[i:j])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, err := fmt.Fprintln(w, "lower"); err != nil {
+		return err
+	}
+	if err := dump(img.Lower, 0); err != nil {
+		return err
+	}
+	pages := make([]Page, 0, len(img.Pages))
+	for p := range img.Pages {
+		pages = append(pages, p)
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		if pages[i].Bank != pages[j].Bank {
+			return pages[i].Bank < pages[j].Bank
+		}
+		return pages[i].Page < pages[j].Page
+	})
+	for _, p := range pages {
+		var err error
+		if p.Bank == 0 {
+			_, err = fmt.Fprintf(w, "page %#x\n", p.Page)
+		} else {
+			_, err = fmt.Fprintf(w, "page %#x %d\n", p.Page, p.Bank)
+		}
+		if err != nil {
+			return err
+		}
+		if err = dump(img.Pages[p], 128); err != nil {
+			return err
+		}
+	}
+	if len(img.A2) > 0 {
+		if _, err := fmt.Fprintln(w, "a2"); err != nil {
+			return err
+		}
+		return dump(img.A2, 0)
+	}
+	return nil
+}
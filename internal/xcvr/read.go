@@ -0,0 +1,185 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package xcvr
+
+import (
+	"fmt"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/i2c"
+)
+
+// I2C addresses of the module memory and SFF-8472 diagnostics.
+const (
+	Addr     = 0x50
+	DiagAddr = 0x51
+)
+
+// page and bank select registers
+const (
+	bankReg = 126
+	pageReg = 127
+)
+
+// blockSize of each I2C block read
+const blockSize = 32
+
+// Reader of a module's memory on an i2c bus, maybe behind a mux.
+type Reader struct {
+	Bus int
+	// Mux is the i2cd name of a mux with the Select value of the module.
+	Mux    string
+	Select byte
+	// Backend of the ops, e.g. an i2cbus.Sim; otherwise, each page is
+	// read in an i2cd transaction.
+	Backend i2cbus.Backend
+}
+
+// Read the module's memory: the A0 and A2 memory of an SFP; the lower
+// memory, page 0, and page 3 of a paged QSFP; and the lower memory, pages
+// 0, 1, 2, and page 0x11 of each bank of a paged CMIS module.
+func (r *Reader) Read() (*Image, error) {
+	img := NewImage()
+	lower, err := r.read(Addr, nil, 0, 128)
+	if err != nil {
+		return nil, err
+	}
+	img.Lower = lower
+	id := lower[0]
+	switch Spec(id) {
+	case SFF8472:
+		if err = r.page(img, 0, 0, false); err != nil {
+			return nil, err
+		}
+		// diagnostic monitoring type of byte 92
+		if img.view(0, 0)[92]&0x40 != 0 {
+			img.A2, err = r.read(DiagAddr, nil, 0, 256)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case SFF8636:
+		flat := lower[2]&0x04 != 0
+		if err = r.page(img, 0, 0, !flat); err != nil {
+			return nil, err
+		}
+		if !flat {
+			if err = r.page(img, 0, 3, true); err != nil {
+				return nil, err
+			}
+		}
+	case CMIS:
+		flat := lower[2]&0x80 != 0
+		if err = r.page(img, 0, 0, !flat); err != nil {
+			return nil, err
+		}
+		if flat {
+			break
+		}
+		for _, page := range []uint8{1, 2} {
+			if err = r.page(img, 0, page, true); err != nil {
+				return nil, err
+			}
+		}
+		banks := []int{1, 2, 4, 1}[img.Pages[Page{0, 1}][142-128]&0x3]
+		for bank := 0; bank < banks; bank++ {
+			err = r.page(img, uint8(bank), 0x11, true)
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported identifier",
+			Identifiers.name(id))
+	}
+	return img, nil
+}
+
+// page of the upper memory, selected if paged, then restored to page 0.
+func (r *Reader) page(img *Image, bank, page uint8, paged bool) error {
+	var sel []i2cbus.Op
+	if paged {
+		if bank != 0 {
+			sel = append(sel, r.writeOp(bankReg, bank))
+		}
+		sel = append(sel, r.writeOp(pageReg, page))
+	}
+	b, err := r.read(Addr, sel, 128, 128)
+	if paged && (page != 0 || bank != 0) {
+		restore := []i2cbus.Op{r.writeOp(pageReg, 0)}
+		if bank != 0 {
+			restore = append(restore, r.writeOp(bankReg, 0))
+		}
+		if _, rerr := r.do(restore); err == nil {
+			err = rerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	img.Pages[Page{bank, page}] = b
+	return nil
+}
+
+func (r *Reader) writeOp(reg, v uint8) i2cbus.Op {
+	op := i2cbus.Op{
+		RW:   i2c.Write,
+		Cmd:  reg,
+		Size: i2c.ByteData,
+		Bus:  r.Bus,
+		Addr: Addr,
+	}
+	op.Data[0] = v
+	return op
+}
+
+// read n bytes of the address from the offset after the select ops.
+func (r *Reader) read(addr int, sel []i2cbus.Op, off, n int) ([]byte, error) {
+	ops := append([]i2cbus.Op{}, sel...)
+	for i := 0; i < n; i += blockSize {
+		op := i2cbus.Op{
+			RW:   i2c.Read,
+			Cmd:  uint8(off + i),
+			Size: i2c.I2CBlockData,
+			Bus:  r.Bus,
+			Addr: addr,
+		}
+		op.Data[0] = blockSize
+		ops = append(ops, op)
+	}
+	data, err := r.do(ops)
+	if err != nil {
+		return nil, fmt.Errorf("%d.%#x: %v", r.Bus, addr, err)
+	}
+	b := make([]byte, 0, n)
+	for _, d := range data[len(sel):] {
+		b = append(b, d[1:1+blockSize]...)
+	}
+	return b, nil
+}
+
+// do the ops with the backend or as an i2cd transaction.
+func (r *Reader) do(ops []i2cbus.Op) ([]i2c.SMBusData, error) {
+	if r.Backend == nil {
+		for i := range ops {
+			ops[i].Mux = r.Mux
+			ops[i].Select = r.Select
+		}
+		return i2cbus.Do(&i2cbus.Tx{
+			Ops:      ops,
+			Deselect: len(r.Mux) > 0,
+		})
+	}
+	data := make([]i2c.SMBusData, len(ops))
+	for i := range ops {
+		data[i] = ops[i].Data
+		err := r.Backend.Do(ops[i].Bus, ops[i].Addr, ops[i].RW,
+			ops[i].Cmd, ops[i].Size, &data[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,246 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package xcvr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// SFF-8472 compliance of A0 byte 94.
+var sff8472Revisions = codes{
+	0x01: "9.3",
+	0x02: "9.5",
+	0x03: "10.2",
+	0x04: "10.4",
+	0x05: "11.0",
+	0x06: "11.3",
+	0x07: "11.4",
+	0x08: "12.3",
+	0x09: "12.4",
+}
+
+// SFP transceiver compliance of A0 bytes 3 through 10.
+var sfpCompliance = [8]bits{
+	{
+		0: "Infiniband 1X Copper Passive",
+		1: "Infiniband 1X Copper Active",
+		2: "Infiniband 1X LX",
+		3: "Infiniband 1X SX",
+		4: "10GBASE-SR",
+		5: "10GBASE-LR",
+		6: "10GBASE-LRM",
+		7: "10GBASE-ER",
+	},
+	{
+		0: "OC-48 short reach",
+		1: "OC-48 intermediate reach",
+		2: "OC-48 long reach",
+		5: "OC-192 short reach",
+		6: "ESCON SMF 1310nm laser",
+		7: "ESCON MMF 1310nm LED",
+	},
+	{
+		0: "OC-3 short reach",
+		1: "OC-3 single mode intermediate reach",
+		2: "OC-3 single mode long reach",
+		4: "OC-12 short reach",
+		5: "OC-12 single mode intermediate reach",
+		6: "OC-12 single mode long reach",
+	},
+	{
+		0: "1000BASE-SX",
+		1: "1000BASE-LX",
+		2: "1000BASE-CX",
+		3: "1000BASE-T",
+		4: "100BASE-LX/LX10",
+		5: "100BASE-FX",
+		6: "BASE-BX10",
+		7: "BASE-PX",
+	},
+	fcLength,
+	{
+		2: "SFP+ Passive Cable",
+		3: "SFP+ Active Cable",
+		4: fcTransmitter[4],
+		5: fcTransmitter[5],
+		6: fcTransmitter[6],
+		7: fcTransmitter[7],
+	},
+	fcMedia,
+	fcSpeed,
+}
+
+// decodeSFF8472 of the SFP's A0 memory, lower and page 0, and its A2
+// diagnostics.
+func decodeSFF8472(img *Image) (*Module, error) {
+	a0 := img.view(0, 0)
+	if a0 == nil {
+		return nil, fmt.Errorf("%s: no upper memory", SFF8472)
+	}
+	m := &Module{
+		Identifier: Identifiers.name(a0[0]),
+		Spec:       SFF8472,
+		Connector:  Connectors.name(a0[2]),
+		Vendor:     text(a0, 20, 16),
+		OUI:        oui(a0, 37),
+		PN:         text(a0, 40, 16),
+		Rev:        text(a0, 56, 4),
+		SN:         text(a0, 68, 16),
+		Date:       date(a0, 84),
+		Encoding:   Encodings.name(a0[11]),
+		Length:     make(map[string]float64),
+	}
+	if rev, found := sff8472Revisions[a0[94]]; found {
+		m.Revision = rev
+	}
+	for i, b := range sfpCompliance {
+		m.Compliance = append(m.Compliance, b.names(a0[3+i])...)
+	}
+	if a0[36] != 0 {
+		m.Compliance = append(m.Compliance, ExtCompliance.name(a0[36]))
+	}
+	switch a0[12] {
+	case 0:
+	case 0xff:
+		m.BitRate = int(a0[66]) * 250
+	default:
+		m.BitRate = int(a0[12]) * 100
+	}
+	cable := a0[8]&0x0c != 0
+	if !cable {
+		m.Wavelength = float64(word(a0, 60))
+	}
+	if a0[14] != 0 {
+		m.Length["SMF"] = float64(a0[14]) * 1000
+	} else if a0[15] != 0 {
+		m.Length["SMF"] = float64(a0[15]) * 100
+	}
+	for medium, off := range map[string]int{"OM2": 16, "OM1": 17, "OM3": 19} {
+		if a0[off] != 0 {
+			m.Length[medium] = float64(a0[off]) * 10
+		}
+	}
+	if a0[18] != 0 {
+		if cable {
+			m.Length["copper"] = float64(a0[18])
+		} else {
+			m.Length["OM4"] = float64(a0[18]) * 10
+		}
+	}
+	if !checksum(a0, 0, 63) {
+		m.Errors = append(m.Errors, "CC_BASE mismatch")
+	}
+	if !checksum(a0, 64, 95) {
+		m.Errors = append(m.Errors, "CC_EXT mismatch")
+	}
+	if a0[92]&0x40 != 0 && len(img.A2) >= 128 {
+		d := sff8472{img.A2, a0[92]&0x10 != 0}
+		m.DOM = d.dom()
+	}
+	return m, nil
+}
+
+// sff8472 diagnostics of the A2 memory, which may be externally calibrated.
+type sff8472 struct {
+	a2  []byte
+	ext bool
+}
+
+// linear value at the offset in the units of the memory, with the slope
+// and offset calibration at cal.
+func (d sff8472) linear(off, cal int, signed bool) float64 {
+	v := float64(word(d.a2, off))
+	if signed {
+		v = float64(int16(word(d.a2, off)))
+	}
+	if d.ext {
+		slope := float64(word(d.a2, cal)) / 256
+		v = v*slope + float64(int16(word(d.a2, cal+2)))
+	}
+	return v
+}
+
+func (d sff8472) temp(_ []byte, off int) float64 {
+	return d.linear(off, 84, true) / 256
+}
+
+func (d sff8472) vcc(_ []byte, off int) float64 {
+	return d.linear(off, 88, false) / 10000
+}
+
+func (d sff8472) bias(_ []byte, off int) float64 {
+	return d.linear(off, 76, false) * 0.002
+}
+
+func (d sff8472) txPower(_ []byte, off int) float64 {
+	return d.linear(off, 80, false) / 10000
+}
+
+// rxPower at the offset in mW with the polynomial calibration of bytes 56
+// through 75, Rx_PWR(4) first.
+func (d sff8472) rxPower(_ []byte, off int) float64 {
+	v := float64(word(d.a2, off))
+	if d.ext {
+		p := 0.0
+		for i := 0; i < 5; i++ {
+			bits := binary.BigEndian.Uint32(d.a2[56+4*i:])
+			p = p*v + float64(math.Float32frombits(bits))
+		}
+		v = p
+	}
+	return v / 10000
+}
+
+func (d sff8472) dom() *DOM {
+	b := d.a2
+	dom := &DOM{
+		Temp: d.temp(b, 96),
+		Vcc:  d.vcc(b, 98),
+		Lanes: []Lane{
+			{
+				Bias:    d.bias(b, 100),
+				TxPower: d.txPower(b, 102),
+				RxPower: d.rxPower(b, 104),
+			},
+		},
+		Thresholds: map[string]Threshold{
+			"temp":     thresholds(b, 0, d.temp),
+			"vcc":      thresholds(b, 8, d.vcc),
+			"bias":     thresholds(b, 16, d.bias),
+			"tx power": thresholds(b, 24, d.txPower),
+			"rx power": thresholds(b, 32, d.rxPower),
+		},
+	}
+	// high and low flags of each monitor from bit 15 of the alarm and
+	// warning words
+	aw, ww := word(b, 112), word(b, 116)
+	for i, name := range []string{"temp", "vcc", "bias", "tx power",
+		"rx power"} {
+		bit := uint(15 - 2*i)
+		for _, x := range []struct {
+			flags uint16
+			bit   uint
+			what  string
+		}{
+			{aw, bit, " high alarm"},
+			{aw, bit - 1, " low alarm"},
+			{ww, bit, " high warning"},
+			{ww, bit - 1, " low warning"},
+		} {
+			if x.flags&(1<<x.bit) != 0 {
+				dom.Alarms = append(dom.Alarms, name+x.what)
+			}
+		}
+	}
+	if b[110]&0x04 != 0 {
+		dom.Alarms = append(dom.Alarms, "tx1 fault")
+	}
+	if b[110]&0x02 != 0 {
+		dom.Alarms = append(dom.Alarms, "rx1 los")
+	}
+	return dom
+}
@@ -0,0 +1,208 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package xcvr
+
+import (
+	"fmt"
+)
+
+// SFF-8636 revision compliance of byte 1.
+var sff8636Revisions = codes{
+	0x01: "SFF-8436 4.8",
+	0x02: "SFF-8436 4.8",
+	0x03: "1.3",
+	0x04: "1.4",
+	0x05: "1.5",
+	0x06: "2.0",
+	0x07: "2.5",
+	0x08: "2.8",
+}
+
+// SFF-8636 encodings of byte 139, which differ from those of SFF-8472.
+var qsfpEncodings = codes{
+	0x00: "Unspecified",
+	0x01: "8B/10B",
+	0x02: "4B/5B",
+	0x03: "NRZ",
+	0x04: "SONET Scrambled",
+	0x05: "64B/66B",
+	0x06: "Manchester",
+	0x07: "256B/257B",
+	0x08: "PAM4",
+}
+
+// QSFP specification compliance of bytes 131 through 138.
+var qsfpCompliance = [8]bits{
+	{
+		0: "40G Active Cable (XLPPI)",
+		1: "40GBASE-LR4",
+		2: "40GBASE-SR4",
+		3: "40GBASE-CR4",
+		4: "10GBASE-SR",
+		5: "10GBASE-LR",
+		6: "10GBASE-LRM",
+	},
+	{
+		0: "OC-48 short reach",
+		1: "OC-48 intermediate reach",
+		2: "OC-48 long reach",
+	},
+	{
+		4: "SAS 3.0G",
+		5: "SAS 6.0G",
+		6: "SAS 12.0G",
+		7: "SAS 24.0G",
+	},
+	{
+		0: "1000BASE-SX",
+		1: "1000BASE-LX",
+		2: "1000BASE-CX",
+		3: "1000BASE-T",
+	},
+	fcLength,
+	fcTransmitter,
+	fcMedia,
+	fcSpeed,
+}
+
+// decodeSFF8636 of the QSFP memory, lower and page 0, with the thresholds
+// of page 3, if present.
+func decodeSFF8636(img *Image) (*Module, error) {
+	b := img.view(0, 0)
+	if b == nil {
+		return nil, fmt.Errorf("%s: no page 0", SFF8636)
+	}
+	m := &Module{
+		Identifier: Identifiers.name(b[128]),
+		Spec:       SFF8636,
+		Connector:  Connectors.name(b[130]),
+		Vendor:     text(b, 148, 16),
+		OUI:        oui(b, 165),
+		PN:         text(b, 168, 16),
+		Rev:        text(b, 184, 2),
+		SN:         text(b, 196, 16),
+		Date:       date(b, 212),
+		Encoding:   qsfpEncodings.name(b[139]),
+		Length:     make(map[string]float64),
+	}
+	if rev, found := sff8636Revisions[b[1]]; found {
+		m.Revision = rev
+	}
+	for i, bits := range qsfpCompliance {
+		m.Compliance = append(m.Compliance, bits.names(b[131+i])...)
+	}
+	// the extended compliance of byte 192 is valid with bit 7 of byte 131
+	if b[131]&0x80 != 0 {
+		m.Compliance = append(m.Compliance, ExtCompliance.name(b[192]))
+	}
+	switch b[140] {
+	case 0:
+	case 0xff:
+		m.BitRate = int(b[222]) * 250
+	default:
+		m.BitRate = int(b[140]) * 100
+	}
+	tx := b[147] >> 4
+	m.Transmitter = Transmitters.name(tx)
+	if tx < copper {
+		m.Wavelength = float64(word(b, 186)) / 20
+	}
+	for _, x := range []struct {
+		medium string
+		off    int
+		units  float64
+	}{
+		{"SMF", 142, 1000},
+		{"OM3", 143, 2},
+		{"OM2", 144, 1},
+		{"OM1", 145, 1},
+	} {
+		if b[x.off] != 0 {
+			m.Length[x.medium] = float64(b[x.off]) * x.units
+		}
+	}
+	if b[146] != 0 {
+		if tx >= copper {
+			m.Length["copper"] = float64(b[146])
+		} else {
+			m.Length["OM4"] = float64(b[146]) * 2
+		}
+	}
+	// power classes 1-4 of bits 7-6, 5-7 of bits 1-0, or 8 of bit 5 and
+	// byte 107 in 0.1 W
+	switch class := b[129]; {
+	case class&0x20 != 0 && b[107] != 0:
+		m.MaxPower = float64(b[107]) / 10
+	case class&0x03 != 0:
+		m.MaxPower = []float64{4.0, 4.5, 5.0}[class&0x03-1]
+	default:
+		m.MaxPower = []float64{1.5, 2.0, 2.5, 3.5}[class>>6]
+	}
+	if !checksum(b, 128, 191) {
+		m.Errors = append(m.Errors, "CC_BASE mismatch")
+	}
+	if !checksum(b, 192, 223) {
+		m.Errors = append(m.Errors, "CC_EXT mismatch")
+	}
+	m.DOM = sff8636DOM(b, img.view(0, 3), b[220]&0x04 != 0)
+	return m, nil
+}
+
+// sff8636DOM of the lower memory and thresholds of page 3, if any.
+func sff8636DOM(b, p3 []byte, txPower bool) *DOM {
+	dom := &DOM{
+		Temp: temp(b, 22),
+		Vcc:  vcc(b, 26),
+	}
+	for i := 0; i < 4; i++ {
+		l := Lane{
+			RxPower: power(b, 34+2*i),
+			Bias:    bias(b, 42+2*i),
+		}
+		if txPower {
+			l.TxPower = power(b, 50+2*i)
+		}
+		dom.Lanes = append(dom.Lanes, l)
+	}
+	if p3 != nil {
+		dom.Thresholds = map[string]Threshold{
+			"temp":     thresholds(p3, 128, temp),
+			"vcc":      thresholds(p3, 144, vcc),
+			"rx power": thresholds(p3, 176, power),
+			"bias":     thresholds(p3, 184, bias),
+		}
+		if txPower {
+			dom.Thresholds["tx power"] = thresholds(p3, 192, power)
+		}
+	}
+	dom.Alarms = append(dom.Alarms, alarms("temp", b[6], 7, 6, 5, 4)...)
+	dom.Alarms = append(dom.Alarms, alarms("vcc", b[7], 7, 6, 5, 4)...)
+	for i := 0; i < 4; i++ {
+		// two lanes of each flags byte, the first in the high nibble
+		shift := uint(4 * (1 - i%2))
+		lane := func(format string, off int) []string {
+			flags := b[off+i/2] >> shift
+			return alarms(fmt.Sprintf(format, i+1), flags, 3, 2, 1, 0)
+		}
+		dom.Alarms = append(dom.Alarms, lane("rx%d power", 9)...)
+		dom.Alarms = append(dom.Alarms, lane("tx%d bias", 11)...)
+		if txPower {
+			dom.Alarms = append(dom.Alarms, lane("tx%d power", 13)...)
+		}
+	}
+	for i := uint(0); i < 4; i++ {
+		if b[3]&(1<<i) != 0 {
+			dom.Alarms = append(dom.Alarms, fmt.Sprint("rx", i+1, " los"))
+		}
+		if b[3]&(0x10<<i) != 0 {
+			dom.Alarms = append(dom.Alarms, fmt.Sprint("tx", i+1, " los"))
+		}
+		if b[4]&(1<<i) != 0 {
+			dom.Alarms = append(dom.Alarms,
+				fmt.Sprint("tx", i+1, " fault"))
+		}
+	}
+	return dom
+}
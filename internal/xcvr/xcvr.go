@@ -0,0 +1,290 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package xcvr decodes the memory of SFP (SFF-8472), QSFP+ and QSFP28
+// (SFF-8636), and QSFP-DD and OSFP (CMIS) transceiver modules.
+package xcvr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Specifications of the module memory maps.
+const (
+	SFF8472 = "SFF-8472"
+	SFF8636 = "SFF-8636"
+	CMIS    = "CMIS"
+)
+
+// Spec of the module identifier's memory map, or empty if unsupported.
+func Spec(id byte) string {
+	switch id {
+	case 0x02, 0x03, 0x0b:
+		return SFF8472
+	case 0x0c, 0x0d, 0x11:
+		return SFF8636
+	case 0x18, 0x19, 0x1e:
+		return CMIS
+	}
+	return ""
+}
+
+// Module is the decoded memory of a transceiver.
+type Module struct {
+	Identifier string
+	Spec       string
+	// Revision of the spec that the module complies with, if given.
+	Revision   string `json:",omitempty"`
+	Connector  string
+	Vendor     string
+	OUI        string
+	PN         string
+	Rev        string
+	SN         string
+	Date       string
+	Compliance []string `json:",omitempty"`
+	// Applications of a CMIS module, e.g. "400GAUI-8 C2M, 400GBASE-DR4".
+	Applications []string `json:",omitempty"`
+	Transmitter  string   `json:",omitempty"`
+	Encoding     string   `json:",omitempty"`
+	// BitRate in Mb/s
+	BitRate int `json:",omitempty"`
+	// Wavelength in nm
+	Wavelength float64 `json:",omitempty"`
+	// Length of each supported medium in m, e.g. "OM3" or "copper".
+	Length map[string]float64 `json:",omitempty"`
+	// MaxPower in W
+	MaxPower float64 `json:",omitempty"`
+	// State of a CMIS module, e.g. "ready".
+	State string `json:",omitempty"`
+	// Errors of the memory, e.g. checksum mismatch.
+	Errors []string `json:",omitempty"`
+	DOM    *DOM     `json:",omitempty"`
+}
+
+// DOM has the digital optical monitors of a module.
+type DOM struct {
+	// Temp in degrees C
+	Temp float64
+	// Vcc in V
+	Vcc   float64
+	Lanes []Lane
+	// Thresholds of the "temp", "vcc", "bias", "tx power" and "rx power"
+	// monitors.
+	Thresholds map[string]Threshold `json:",omitempty"`
+	// Alarms and warnings of the monitors, e.g. "rx2 power low alarm",
+	// and lane status, e.g. "rx3 los".
+	Alarms []string `json:",omitempty"`
+}
+
+// Lane monitors.
+type Lane struct {
+	// Bias in mA
+	Bias float64
+	// TxPower and RxPower in mW
+	TxPower float64
+	RxPower float64
+}
+
+// Threshold of a monitor.
+type Threshold struct {
+	HighAlarm   float64
+	HighWarning float64
+	LowWarning  float64
+	LowAlarm    float64
+}
+
+// Decode the image of the module memory.
+func Decode(img *Image) (*Module, error) {
+	id := img.Identifier()
+	switch Spec(id) {
+	case SFF8472:
+		return decodeSFF8472(img)
+	case SFF8636:
+		return decodeSFF8636(img)
+	case CMIS:
+		return decodeCMIS(img)
+	}
+	return nil, fmt.Errorf("%s: unsupported identifier",
+		Identifiers.name(id))
+}
+
+// word at the offset of the memory.
+func word(b []byte, off int) uint16 {
+	return binary.BigEndian.Uint16(b[off:])
+}
+
+// temp at the offset of the memory in degrees C.
+func temp(b []byte, off int) float64 {
+	return float64(int16(word(b, off))) / 256
+}
+
+// vcc at the offset of the memory in V.
+func vcc(b []byte, off int) float64 { return float64(word(b, off)) / 10000 }
+
+// bias at the offset of the memory in mA.
+func bias(b []byte, off int) float64 { return float64(word(b, off)) * 0.002 }
+
+// power at the offset of the memory in mW.
+func power(b []byte, off int) float64 { return float64(word(b, off)) / 10000 }
+
+// text of the memory, without padding.
+func text(b []byte, off, n int) string {
+	return strings.TrimRight(string(b[off:off+n]), " \x00")
+}
+
+// oui at the offset of the memory, e.g. "00:90:65".
+func oui(b []byte, off int) string {
+	return fmt.Sprintf("%02x:%02x:%02x", b[off], b[off+1], b[off+2])
+}
+
+// date code at the offset of the memory, YYMMDD[LL], as 20YY-MM-DD[ LL].
+func date(b []byte, off int) string {
+	s := text(b, off, 8)
+	if len(s) < 6 {
+		return s
+	}
+	d := "20" + s[:2] + "-" + s[2:4] + "-" + s[4:6]
+	if lot := strings.TrimSpace(s[6:]); len(lot) > 0 {
+		d += " " + lot
+	}
+	return d
+}
+
+// checksum of the memory from the offset up to its sum.
+func checksum(b []byte, from, sum int) bool {
+	var cc byte
+	for _, v := range b[from:sum] {
+		cc += v
+	}
+	return cc == b[sum]
+}
+
+// thresholds at the offset of the memory in order of high alarm, low
+// alarm, high warning and low warning.
+func thresholds(b []byte, off int, f func([]byte, int) float64) Threshold {
+	return Threshold{
+		HighAlarm:   f(b, off),
+		LowAlarm:    f(b, off+2),
+		HighWarning: f(b, off+4),
+		LowWarning:  f(b, off+6),
+	}
+}
+
+// alarms of the flags with the bits of the high alarm, low alarm, high
+// warning and low warning.
+func alarms(prefix string, flags byte, ha, la, hw, lw uint) []string {
+	var s []string
+	for _, x := range []struct {
+		bit  uint
+		name string
+	}{
+		{ha, "high alarm"},
+		{la, "low alarm"},
+		{hw, "high warning"},
+		{lw, "low warning"},
+	} {
+		if flags&(1<<x.bit) != 0 {
+			s = append(s, prefix+" "+x.name)
+		}
+	}
+	return s
+}
+
+// DBm of the power in mW.
+func DBm(mW float64) float64 {
+	if mW <= 0 {
+		return math.Inf(-1)
+	}
+	return 10 * math.Log10(mW)
+}
+
+// Print the module's description, with its monitors if dom.
+func (m *Module) Print(w io.Writer, dom bool) error {
+	var lines [][2]string
+	add := func(k, v string) {
+		if len(v) > 0 {
+			lines = append(lines, [2]string{k, v})
+		}
+	}
+	spec := m.Spec
+	if len(m.Revision) > 0 {
+		spec += " " + m.Revision
+	}
+	add("Identifier", m.Identifier)
+	add("Spec", spec)
+	add("Connector", m.Connector)
+	add("Vendor", m.Vendor)
+	add("OUI", m.OUI)
+	add("PN", m.PN)
+	add("Rev", m.Rev)
+	add("SN", m.SN)
+	add("Date", m.Date)
+	for _, s := range m.Compliance {
+		add("Compliance", s)
+	}
+	for i, s := range m.Applications {
+		add(fmt.Sprint("Application ", i+1), s)
+	}
+	add("Transmitter", m.Transmitter)
+	add("Encoding", m.Encoding)
+	if m.BitRate > 0 {
+		add("Bit rate", fmt.Sprint(m.BitRate, " Mb/s"))
+	}
+	if m.Wavelength > 0 {
+		add("Wavelength", fmt.Sprint(m.Wavelength, " nm"))
+	}
+	media := make([]string, 0, len(m.Length))
+	for k := range m.Length {
+		media = append(media, k)
+	}
+	sort.Strings(media)
+	for _, k := range media {
+		add("Length "+k, fmt.Sprint(m.Length[k], " m"))
+	}
+	if m.MaxPower > 0 {
+		add("Max power", fmt.Sprint(m.MaxPower, " W"))
+	}
+	add("State", m.State)
+	for _, s := range m.Errors {
+		add("Error", s)
+	}
+	if dom && m.DOM != nil {
+		d := m.DOM
+		add("Temperature", fmt.Sprintf("%.2f C", d.Temp))
+		add("Vcc", fmt.Sprintf("%.4f V", d.Vcc))
+		for i, l := range d.Lanes {
+			add(fmt.Sprint("Lane ", i+1), fmt.Sprintf(
+				"bias %.3f mA, tx %.4f mW (%.2f dBm), rx %.4f mW (%.2f dBm)",
+				l.Bias, l.TxPower, DBm(l.TxPower), l.RxPower,
+				DBm(l.RxPower)))
+		}
+		names := make([]string, 0, len(d.Thresholds))
+		for k := range d.Thresholds {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			t := d.Thresholds[k]
+			add("Threshold "+k, fmt.Sprintf(
+				"alarm %.4g/%.4g, warning %.4g/%.4g",
+				t.LowAlarm, t.HighAlarm, t.LowWarning,
+				t.HighWarning))
+		}
+		for _, s := range d.Alarms {
+			add("Alarm", s)
+		}
+	}
+	for _, l := range lines {
+		if _, err := fmt.Fprintf(w, "%-20s%s\n", l[0]+":", l[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
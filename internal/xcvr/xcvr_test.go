@@ -0,0 +1,347 @@
+// Copyright © 2018 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package xcvr
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/platinasystems/go/internal/i2cbus"
+	"github.com/platinasystems/i2c"
+)
+
+// The images of testdata are synthetic, built to the specifications rather
+// than captured; captured dumps are checked by TestCaptured.
+func decode(t *testing.T, fn string) *Module {
+	img, err := LoadImage(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Decode(img)
+	if err != nil {
+		t.Fatal(fn, ": ", err)
+	}
+	return m
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 0.0005
+}
+
+func TestSFF8472(t *testing.T) {
+	m := decode(t, "testdata/sfp-10g-sr.hex")
+	for _, x := range []struct{ got, want string }{
+		{m.Identifier, "SFP"},
+		{m.Spec, SFF8472},
+		{m.Revision, "11.3"},
+		{m.Connector, "LC"},
+		{m.Vendor, "ACME OPTICS"},
+		{m.OUI, "00:17:6a"},
+		{m.PN, "SFP-10G-SR"},
+		{m.Rev, "A"},
+		{m.SN, "ACM1711000042"},
+		{m.Date, "2017-03-15"},
+		{m.Encoding, "64B/66B"},
+	} {
+		if x.got != x.want {
+			t.Errorf("got %q, want %q", x.got, x.want)
+		}
+	}
+	if !reflect.DeepEqual(m.Compliance, []string{"10GBASE-SR"}) {
+		t.Error("compliance:", m.Compliance)
+	}
+	if m.BitRate != 10300 || m.Wavelength != 850 {
+		t.Error("bit rate:", m.BitRate, "wavelength:", m.Wavelength)
+	}
+	want := map[string]float64{"OM1": 30, "OM2": 80, "OM3": 300}
+	if !reflect.DeepEqual(m.Length, want) {
+		t.Error("length:", m.Length)
+	}
+	if len(m.Errors) > 0 {
+		t.Error(m.Errors)
+	}
+	d := m.DOM
+	if d == nil || len(d.Lanes) != 1 {
+		t.Fatal("dom:", d)
+	}
+	l := d.Lanes[0]
+	if !near(d.Temp, 34.5) || !near(d.Vcc, 3.3) || !near(l.Bias, 6.75) ||
+		!near(l.TxPower, 0.5012) || !near(l.RxPower, 0.0123) {
+		t.Errorf("monitors: %+v", d)
+	}
+	if th := d.Thresholds["temp"]; th.HighAlarm != 78 || th.LowAlarm != -13 {
+		t.Errorf("temp thresholds: %+v", th)
+	}
+	alarms := []string{"rx power low alarm", "rx power low warning",
+		"rx1 los"}
+	if !reflect.DeepEqual(d.Alarms, alarms) {
+		t.Error("alarms:", d.Alarms)
+	}
+}
+
+func TestSFF8472External(t *testing.T) {
+	m := decode(t, "testdata/sfp-1g-lx.hex")
+	if m.Length["SMF"] != 10000 || m.Date != "2015-02-12 A1" {
+		t.Error("length:", m.Length, "date:", m.Date)
+	}
+	l := m.DOM.Lanes[0]
+	if !near(m.DOM.Temp, 31) || !near(l.Bias, 10) || !near(l.TxPower, 0.3) ||
+		!near(l.RxPower, 0.301) {
+		t.Errorf("calibrated monitors: %+v", m.DOM)
+	}
+}
+
+func TestSFF8636(t *testing.T) {
+	m := decode(t, "testdata/qsfp28-100g-sr4.hex")
+	if m.Identifier != "QSFP28" || m.Revision != "2.5" ||
+		m.Connector != "MPO 1x12" || m.Encoding != "64B/66B" {
+		t.Errorf("%+v", m)
+	}
+	if !reflect.DeepEqual(m.Compliance, []string{"100GBASE-SR4 or 25GBASE-SR"}) {
+		t.Error("compliance:", m.Compliance)
+	}
+	if m.BitRate != 25750 || m.Wavelength != 850 || m.MaxPower != 3.5 {
+		t.Error("bit rate:", m.BitRate, "wavelength:", m.Wavelength,
+			"max power:", m.MaxPower)
+	}
+	length := map[string]float64{"OM3": 70, "OM4": 100}
+	if !reflect.DeepEqual(m.Length, length) {
+		t.Error("length:", m.Length)
+	}
+	if len(m.Errors) > 0 {
+		t.Error(m.Errors)
+	}
+	d := m.DOM
+	if len(d.Lanes) != 4 || !near(d.Temp, 41.25) || !near(d.Vcc, 3.29) {
+		t.Fatalf("dom: %+v", d)
+	}
+	if l := d.Lanes[0]; !near(l.RxPower, 0.8123) || !near(l.Bias, 7.5) ||
+		!near(l.TxPower, 0.912) {
+		t.Errorf("lane 1: %+v", l)
+	}
+	if th := d.Thresholds["bias"]; th.HighAlarm != 15 || th.LowWarning != 3 {
+		t.Errorf("bias thresholds: %+v", th)
+	}
+	want := []string{
+		"rx3 power low alarm",
+		"rx3 power low warning",
+		"rx4 power low alarm",
+		"rx4 power low warning",
+		"rx4 los",
+	}
+	if !reflect.DeepEqual(d.Alarms, want) {
+		t.Error("alarms:", d.Alarms)
+	}
+}
+
+func TestCMIS(t *testing.T) {
+	m := decode(t, "testdata/qsfp-dd-400g-dr4.hex")
+	if m.Identifier != "QSFP-DD" || m.Revision != "4.0" ||
+		m.State != "ready" || m.MaxPower != 12 {
+		t.Errorf("%+v", m)
+	}
+	apps := []string{
+		"400GAUI-8 C2M, 400GBASE-DR4",
+		"100GAUI-2 C2M, 100GBASE-DR",
+	}
+	if !reflect.DeepEqual(m.Applications, apps) {
+		t.Error("applications:", m.Applications)
+	}
+	if m.Wavelength != 1311 || m.Length["SMF"] != 2000 {
+		t.Error("wavelength:", m.Wavelength, "length:", m.Length)
+	}
+	if len(m.Errors) > 0 {
+		t.Error(m.Errors)
+	}
+	d := m.DOM
+	if len(d.Lanes) != 4 || !near(d.Temp, 38.5) || !near(d.Vcc, 3.31) {
+		t.Fatalf("dom: %+v", d)
+	}
+	if l := d.Lanes[1]; !near(l.TxPower, 1.8621) || !near(l.Bias, 46.2) ||
+		!near(l.RxPower, 1.1749) {
+		t.Errorf("lane 2: %+v", l)
+	}
+	if th := d.Thresholds["vcc"]; !near(th.HighAlarm, 3.465) {
+		t.Errorf("vcc thresholds: %+v", th)
+	}
+	want := []string{
+		"rx3 los",
+		"rx3 power low alarm",
+		"rx3 power low warning",
+	}
+	if !reflect.DeepEqual(d.Alarms, want) {
+		t.Error("alarms:", d.Alarms)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	img, err := LoadImage("testdata/qsfp28-100g-sr4.hex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img.Pages[Page{0, 0}][168-128] = 'X'
+	m, err := Decode(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.Errors, []string{"CC_BASE mismatch"}) {
+		t.Error("errors:", m.Errors)
+	}
+}
+
+func TestHex(t *testing.T) {
+	for _, fn := range []string{
+		"testdata/sfp-10g-sr.hex",
+		"testdata/qsfp-dd-400g-dr4.hex",
+	} {
+		img, err := LoadImage(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err = img.WriteHex(&buf); err != nil {
+			t.Fatal(err)
+		}
+		again, err := ReadImage(&buf)
+		if err != nil {
+			t.Fatal(fn, ": ", err)
+		}
+		if !reflect.DeepEqual(img, again) {
+			t.Error(fn, ": mismatch")
+		}
+	}
+	for _, s := range []string{
+		"page 0\n80: 00\n",
+		"lower\n00: zz\n",
+		"lower\n80: 00\n",
+		"bogus\n",
+	} {
+		if _, err := ReadImage(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
+
+func TestEthtool(t *testing.T) {
+	want, err := LoadImage("testdata/sfp-10g-sr.hex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flat []byte
+	flat = append(flat, want.Lower...)
+	flat = append(flat, want.Pages[Page{0, 0}]...)
+	flat = append(flat, want.A2...)
+	var buf bytes.Buffer
+	buf.WriteString("Offset\t\tValues\n------\t\t------\n")
+	for i := 0; i < len(flat); i += 16 {
+		fmt.Fprintf(&buf, "0x%04x:\t\t% x\n", i, flat[i:i+16])
+	}
+	img, err := ReadImage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(img, want) {
+		t.Error("mismatch")
+	}
+	for _, s := range []string{
+		"0x0000:\t\t03 04\n0x0004:\t\t00\n",
+		"lower\n00: 03\n0x0001:\t\t04\n",
+	} {
+		if _, err := ReadImage(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
+
+// TestCaptured decodes each NAME.ethtool dump of "ethtool -m PORT hex on"
+// in testdata, comparing the fields of the reference decode, "ethtool -m
+// PORT", in NAME.ethtool.txt.
+func TestCaptured(t *testing.T) {
+	dumps, err := filepath.Glob("testdata/*.ethtool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) == 0 {
+		t.Skip("no captured dumps")
+	}
+	for _, fn := range dumps {
+		m := decode(t, fn)
+		b, err := ioutil.ReadFile(fn + ".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			kv := strings.SplitN(line, ":", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			k, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+			var f float64
+			ok := true
+			switch k {
+			case "Vendor name":
+				ok = v == m.Vendor
+			case "Vendor OUI":
+				ok = v == m.OUI
+			case "Vendor PN":
+				ok = v == m.PN
+			case "Vendor rev":
+				ok = v == m.Rev
+			case "Vendor SN":
+				ok = v == m.SN
+			case "Date code":
+				ok = date([]byte(v+"        "), 0) == m.Date
+			case "Laser wavelength":
+				ok = v == fmt.Sprintf("%gnm", m.Wavelength)
+			case "Module temperature":
+				fmt.Sscanf(v, "%f degrees C", &f)
+				ok = m.DOM != nil && math.Abs(f-m.DOM.Temp) < 0.01
+			case "Module voltage":
+				fmt.Sscanf(v, "%f V", &f)
+				ok = m.DOM != nil && math.Abs(f-m.DOM.Vcc) < 0.0001
+			}
+			if !ok {
+				t.Errorf("%s: %s: %q, decoded %+v", fn, k, v, m)
+			}
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	for _, x := range []struct {
+		sim, image string
+	}{
+		{"testdata/sfp-10g-sr.sim", "testdata/sfp-10g-sr.hex"},
+		{"testdata/qsfp28-100g-sr4.sim", "testdata/qsfp28-100g-sr4.hex"},
+	} {
+		sim, err := i2cbus.LoadSim(x.sim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := Reader{Backend: sim}
+		img, err := r.Read()
+		if err != nil {
+			t.Fatal(x.sim, ": ", err)
+		}
+		want, err := LoadImage(x.image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(img, want) {
+			t.Error(x.sim, ": mismatch")
+		}
+		var d i2c.SMBusData
+		err = sim.Do(0, Addr, i2c.Read, pageReg, i2c.ByteData, &d)
+		if err != nil || d[0] != 0 {
+			t.Error(x.sim, ": page", d[0], "still selected")
+		}
+	}
+}